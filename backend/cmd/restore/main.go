@@ -0,0 +1,121 @@
+// Command restore rebuilds the database and blob store from a backup created
+// by the admin backup job.
+//
+// Usage:
+//
+//	go run ./cmd/restore -list
+//	go run ./cmd/restore -backup 20250101-020000-1a2b3c4d
+//	go run ./cmd/restore -backup 20250101-020000-1a2b3c4d -skip-db
+//
+// The backup location defaults to BACKUP_PATH and blobs are restored into
+// STORAGE_PATH. The server should be stopped while a restore runs.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/pkg/backup"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	envPaths := []string{".env", "../../.env", "../../../.env"}
+	for _, path := range envPaths {
+		if err := godotenv.Load(path); err == nil {
+			log.Printf("Loaded .env from: %s", path)
+			break
+		}
+	}
+
+	cfg := config.Load()
+
+	location := flag.String("location", cfg.BackupPath, "backup location containing backups and the blobs directory")
+	name := flag.String("backup", "", "name of the backup directory to restore")
+	list := flag.Bool("list", false, "list available backups and exit")
+	skipDB := flag.Bool("skip-db", false, "do not restore the database")
+	skipBlobs := flag.Bool("skip-blobs", false, "do not restore blobs")
+	flag.Parse()
+
+	if *list {
+		listBackups(*location)
+		return
+	}
+
+	if *name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	backupDir := filepath.Join(*location, *name)
+	manifest, err := backup.ReadManifest(backupDir)
+	if err != nil {
+		log.Fatalf("Failed to load backup %s: %v", *name, err)
+	}
+
+	log.Printf("Restoring %s backup %s created at %s (%d blobs)", manifest.Mode, *name, manifest.CreatedAt, len(manifest.Blobs))
+
+	if !*skipDB {
+		dumpPath := filepath.Join(backupDir, manifest.DatabaseDump)
+		dumpHash, err := backup.FileSHA256(dumpPath)
+		if err != nil {
+			log.Fatalf("Failed to read database dump: %v", err)
+		}
+		if dumpHash != manifest.DatabaseSHA256 {
+			log.Fatalf("Database dump checksum mismatch: expected %s, got %s", manifest.DatabaseSHA256, dumpHash)
+		}
+
+		log.Printf("Restoring database from %s", dumpPath)
+		if err := backup.RestoreDatabase(cfg, dumpPath); err != nil {
+			log.Fatalf("Failed to restore database: %v", err)
+		}
+	}
+
+	if !*skipBlobs {
+		restored, failed := 0, 0
+		for _, blob := range manifest.Blobs {
+			if _, err := backup.RestoreBlob(*location, blob, cfg.StoragePath); err != nil {
+				log.Printf("Failed to restore blob %s: %v", blob.Hash, err)
+				failed++
+				continue
+			}
+			restored++
+		}
+
+		log.Printf("Restored %d blob(s) into %s", restored, cfg.StoragePath)
+		if failed > 0 {
+			log.Fatalf("%d blob(s) could not be restored", failed)
+		}
+	}
+
+	log.Printf("Restore of %s complete", *name)
+}
+
+// listBackups prints every backup in the location that has a readable manifest
+func listBackups(location string) {
+	entries, err := os.ReadDir(location)
+	if err != nil {
+		log.Fatalf("Failed to read backup location: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != backup.BlobDir {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		manifest, err := backup.ReadManifest(filepath.Join(location, name))
+		if err != nil {
+			continue
+		}
+		log.Printf("%s  mode=%s  blobs=%d  created=%s", name, manifest.Mode, len(manifest.Blobs), manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+}
@@ -3,21 +3,21 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/events"
 	"file-vault-system/backend/internal/handlers"
 	"file-vault-system/backend/internal/middleware"
 	"file-vault-system/backend/internal/services"
 	"file-vault-system/backend/pkg/database"
+	"file-vault-system/backend/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Set Gin to debug mode for detailed logging
-	gin.SetMode(gin.DebugMode)
-
 	// Load environment variables - try multiple paths
 	envPaths := []string{".env", "../../.env", "../../../.env"}
 	for _, path := range envPaths {
@@ -27,8 +27,29 @@ func main() {
 		}
 	}
 
-	// Load configuration
+	// Load configuration and refuse to start with settings that are unsafe
+	// or cannot work
 	cfg := config.Load()
+	fatal := false
+	for _, problem := range cfg.Validate() {
+		if problem.Fatal {
+			fatal = true
+			log.Printf("Config error: %s %s", problem.Setting, problem.Message)
+		} else {
+			log.Printf("Config warning: %s %s", problem.Setting, problem.Message)
+		}
+	}
+	if fatal {
+		log.Fatalf("Refusing to start with an unsafe configuration; fix the settings above")
+	}
+
+	// Gin logs every route and warning in debug mode, which is only wanted
+	// while diagnosing problems
+	if cfg.DebugMode {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
 	// Initialize database
 	db, err := database.Initialize(cfg)
@@ -41,37 +62,245 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Folder share link passwords used to be stored in plaintext
+	if hashed, err := services.HashPlaintextSharePasswords(db); err != nil {
+		log.Printf("Failed to hash plaintext share link passwords: %v", err)
+	} else if hashed > 0 {
+		log.Printf("Hashed %d plaintext share link password(s)", hashed)
+	}
+
+	// Sign login tokens with rotating keys named by their kid, keeping tokens
+	// signed with JWT_SECRET alone valid for the grace period
+	middleware.InitializeSigningKeys(db, cfg)
+	signingKeyService := services.NewSigningKeyService(db, cfg)
+	if err := signingKeyService.EnsureCurrent(); err != nil {
+		log.Fatalf("Failed to set up JWT signing keys: %v", err)
+	}
+	if signingKeyService.Enabled() && cfg.JWTKeyRotationInterval > 0 {
+		signingKeyService.Start(time.Duration(cfg.JWTKeyRotationInterval) * time.Hour)
+	}
+
+	if cfg.IsNullStorage() {
+		log.Printf("Null storage backend: uploaded content is discarded and cannot be downloaded")
+	}
+	if _, err := services.OpenBlobStore(cfg); err != nil {
+		log.Fatalf("Failed to open blob storage: %v", err)
+	}
+	if cfg.IsObjectStorage() {
+		log.Printf("S3 storage backend: blobs are stored in bucket %s at %s", cfg.S3Bucket, cfg.S3Endpoint)
+	}
+
+	// Finish uploads that committed just before a crash, then clear out
+	// uploads left half-staged by a previous run
+	if placed, removed, err := services.RecoverStagedUploads(db, cfg); err != nil {
+		log.Printf("Failed to recover staged uploads: %v", err)
+	} else if placed > 0 || removed > 0 {
+		log.Printf("Recovered %d staged upload(s) and discarded %d uncommitted one(s)", placed, removed)
+	}
+	if removed, err := utils.SweepStaleTempFiles(cfg.UploadTempDir, time.Duration(cfg.UploadTempMaxAge)*time.Hour); err != nil {
+		log.Printf("Failed to sweep upload temp directory: %v", err)
+	} else if removed > 0 {
+		log.Printf("Removed %d stale staged upload(s) from %s", removed, cfg.UploadTempDir)
+	}
+
 	// Initialize services
-	auditService := services.NewAuditService(db)
+	auditService := services.NewAuditService(db, cfg)
+	notificationService := services.NewNotificationService(db, cfg)
+	storageHealthService := services.NewStorageHealthService(db, cfg, notificationService)
+	backupService := services.NewBackupService(db, cfg, notificationService)
+	replicationService := services.NewReplicationService(db, cfg)
+	archiveService := services.NewArchiveService(db, cfg, notificationService)
+	uploadPolicyService := services.NewUploadPolicyService(db)
+	dlpService := services.NewDLPService(cfg, auditService)
+	quarantineService := services.NewQuarantineService(db, notificationService, auditService)
+	malwareScanService := services.NewMalwareScanService(db, cfg, quarantineService)
+	healthService := services.NewHealthService(db, cfg)
+	policyService := services.NewPolicyService(db)
+	activityService := services.NewActivityService(db)
+	fileEventService := services.NewFileEventService(db)
+	accessService := services.NewAccessService(db)
+	uploadRejectionService := services.NewUploadRejectionService(db)
+	planService := services.NewPlanService(db)
+	contentIndexService := services.NewContentIndexService(db, cfg)
+	classificationService := services.NewClassificationService(db, cfg)
+	mailService := services.NewMailService(cfg)
+	quotaGraceService := services.NewQuotaGraceService(db, cfg, notificationService, mailService)
+	linkService := services.NewLinkService(db, cfg)
+	downloadNotifier := services.NewDownloadNotifier(db, notificationService, mailService, linkService)
+	shareNotifier := services.NewShareNotifier(db, cfg, notificationService, mailService)
+	integrationService := services.NewIntegrationService(db, cfg)
+	guestService := services.NewGuestService(db, cfg)
+	samlService := services.NewSAMLService(db, cfg)
+	deviceService := services.NewDeviceService(db)
+	loginHistoryService := services.NewLoginHistoryService(db, notificationService, mailService)
+	accountLifecycleService := services.NewAccountLifecycleService(db, cfg, notificationService, mailService)
+
+	// Move committed audit entries into the audit log and seal its hash chain
+	auditService.Start()
+
+	// Periodically check storage capacity and alert admins
+	if cfg.StorageMonitorInterval > 0 {
+		storageHealthService.StartMonitor(time.Duration(cfg.StorageMonitorInterval) * time.Minute)
+	}
+
+	// Snapshot global storage totals for the dedup savings trend
+	if cfg.StorageRollupInterval > 0 {
+		services.NewStorageRollupService(db).Start(time.Duration(cfg.StorageRollupInterval) * time.Minute)
+	}
+
+	// Delete the content of blobs no file references any more
+	if cfg.OrphanBlobSweepInterval > 0 {
+		services.NewOrphanBlobService(db, cfg).Start(time.Duration(cfg.OrphanBlobSweepInterval) * time.Minute)
+	}
+
+	// Move blobs stored flat under storage/{hash} into sharded directories
+	if cfg.MigrateBlobLayout {
+		services.NewBlobLayoutService(db, cfg).Start()
+	}
+
+	// Asynchronously replicate blobs to the secondary storage location
+	if cfg.EnableReplication {
+		replicationService.Start()
+	}
+
+	// Extract searchable text and run OCR on new uploads
+	if contentIndexService.Enabled() {
+		contentIndexService.Start()
+	}
+
+	// Scan stored blobs for malware and quarantine infected files. Null
+	// storage keeps no blobs, though uploads are still scanned. Without the
+	// background scan, files waiting for it would never be cleared.
+	if malwareScanService.Enabled() && !cfg.IsNullStorage() {
+		malwareScanService.Start()
+	} else if err := malwareScanService.ClearPending(); err != nil {
+		log.Printf("Failed to clear pending malware scans: %v", err)
+	}
+
+	// Tag files as invoices, contracts, photos or screenshots
+	if classificationService.Enabled() {
+		classificationService.Start()
+	}
+
+	// Revoke storage quota grace once its window has passed
+	if quotaGraceService.Enabled() && cfg.QuotaGraceCheckInterval > 0 {
+		quotaGraceService.Start(time.Duration(cfg.QuotaGraceCheckInterval) * time.Minute)
+	}
+
+	// Warn, restrict and archive accounts nobody has signed in to for a while
+	if accountLifecycleService.Enabled() && cfg.InactivityCheckInterval > 0 {
+		accountLifecycleService.Start(time.Duration(cfg.InactivityCheckInterval) * time.Hour)
+	}
+
+	// Move stale blobs to cold storage and serve restore requests
+	if cfg.EnableArchiving {
+		archiveService.Start()
+	}
+
+	// Disable guest accounts once they expire
+	if cfg.GuestExpiryCheckInterval > 0 {
+		guestService.Start(time.Duration(cfg.GuestExpiryCheckInterval) * time.Minute)
+	}
+
+	// Move files matching users' cleanup rules to the trash
+	cleanupRuleService := services.NewCleanupRuleService(db, auditService)
+	if cfg.CleanupRuleInterval > 0 {
+		cleanupRuleService.Start(time.Duration(cfg.CleanupRuleInterval) * time.Minute)
+	}
+
+	// Discard resumable uploads that were never completed, and their chunks
+	services.NewUploadSessionService(db, cfg).Start(time.Hour)
+	services.NewTusUploadService(db, cfg).Start(time.Hour)
+	services.NewDirectUploadService(db, cfg).Start(time.Hour)
+
+	// Fail URL imports a restart interrupted and remove old finished ones
+	services.NewURLImportService(db, cfg).Start(time.Hour)
+
+	// Remove IP addresses and user agents from access logs past retention
+	accessLogRetentionService := services.NewAccessLogRetentionService(db, cfg.AccessLogRetentionDays)
+	if accessLogRetentionService.Enabled() && cfg.AccessLogRetentionInterval > 0 {
+		accessLogRetentionService.Start(time.Duration(cfg.AccessLogRetentionInterval) * time.Hour)
+	}
+
+	// Tell owners about downloads of the files and share links they watch
+	downloadNotifier.Start(events.Default)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, cfg)
-	fileHandler := handlers.NewFileHandler(db, cfg, auditService)
-	folderHandler := handlers.NewFolderHandler(db, cfg)
-	adminHandler := handlers.NewAdminHandler(db, cfg, auditService)
+	authHandler := handlers.NewAuthHandler(db, cfg, samlService, deviceService, loginHistoryService)
+	fileHandler := handlers.NewFileHandler(db, cfg, auditService, dlpService, quarantineService)
+	folderHandler := handlers.NewFolderHandler(db, cfg, auditService)
+	adminHandler := handlers.NewAdminHandler(db, cfg, auditService, storageHealthService, dlpService, quarantineService, healthService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	integrationHandler := handlers.NewIntegrationHandler(integrationService)
+	cleanupRuleHandler := handlers.NewCleanupRuleHandler(db, cleanupRuleService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(services.NewAPIKeyService(db))
+	triggerHandler := handlers.NewTriggerHandler(services.NewTriggerService(db))
+	backupHandler := handlers.NewBackupHandler(backupService)
+	replicationHandler := handlers.NewReplicationHandler(replicationService)
+	archiveHandler := handlers.NewArchiveHandler(archiveService)
+	uploadPolicyHandler := handlers.NewUploadPolicyHandler(db, uploadPolicyService)
+	quarantineHandler := handlers.NewQuarantineHandler(quarantineService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	policyHandler := handlers.NewPolicyHandler(policyService, auditService)
+	activityHandler := handlers.NewActivityHandler(activityService)
+	opsHandler := handlers.NewOpsHandler(events.Default)
+	uploadRejectionHandler := handlers.NewUploadRejectionHandler(uploadRejectionService)
+	planHandler := handlers.NewPlanHandler(planService, auditService)
+	tenantHandler := handlers.NewTenantHandler(services.NewTenantService(db, cfg), auditService)
+	signingKeyHandler := handlers.NewSigningKeyHandler(signingKeyService, auditService)
+	vaultHandler := handlers.NewVaultHandler(services.NewVaultService(db, cfg), auditService)
+	rateLimitOverrideHandler := handlers.NewRateLimitOverrideHandler(services.NewRateLimitOverrideService(db), auditService)
+	contentIndexHandler := handlers.NewContentIndexHandler(contentIndexService)
+	settingsHandler := handlers.NewSettingsHandler(db, classificationService)
+	fileEventHandler := handlers.NewFileEventHandler(db, fileEventService)
+	accessHandler := handlers.NewAccessHandler(accessService)
+	deviceHandler := handlers.NewDeviceHandler(deviceService)
+	loginHistoryHandler := handlers.NewLoginHistoryHandler(loginHistoryService)
+	usageHandler := handlers.NewUsageHandler(db, cfg)
 
 	// Initialize sharing service and handler
-	sharingService := services.NewSharingService(db)
-	sharingHandler := handlers.NewSharingHandler(sharingService)
+	sharingService := services.NewSharingService(db, cfg)
+	sharingHandler := handlers.NewSharingHandler(sharingService, guestService, auditService, services.NewFileStreamService(db, cfg), services.NewPrewarmService(db, cfg), linkService, shareNotifier, integrationService)
 
 	// Initialize folder sharing service and handler
-	folderSharingService := services.NewFolderSharingService(db)
-	folderSharingHandler := handlers.NewFolderSharingHandler(db, folderSharingService)
+	folderSharingService := services.NewFolderSharingService(db, cfg)
+	folderSharingHandler := handlers.NewFolderSharingHandler(db, cfg, folderSharingService, guestService, auditService, linkService, shareNotifier, integrationService)
 
 	// Set up Gin router
 	router := gin.Default()
+	router.MaxMultipartMemory = cfg.MultipartMemoryLimit
 	router.Use(middleware.CORS())
+	router.Use(middleware.ClientCountry(cfg))
+	// Serve only share links on custom share domains
+	router.Use(middleware.ServeShareDomains(db, cfg))
+	// Turn errors handlers attach with c.Error into catalog responses
+	router.Use(middleware.ErrorHandler(cfg))
+	// Cancel queries of disconnected clients and answer timed out ones with 503
+	router.Use(middleware.QueryTimeouts(cfg))
 
 	// Initialize rate limiter with config
 	if cfg.EnableRateLimit {
 		middleware.InitializeRateLimiter(cfg)
+		middleware.InitializeRateLimitOverrides(db)
 		if cfg.RateLimitMode == "database" {
 			router.Use(middleware.DatabaseRateLimit(db, cfg))
+			// Drop windows that ended long ago so the table stays small
+			if cfg.RateLimitPurgeInterval > 0 {
+				services.NewRateLimitWindowService(db).Start(time.Duration(cfg.RateLimitPurgeInterval) * time.Minute)
+			}
 		} else {
 			router.Use(middleware.RateLimit())
 		}
 	}
 
+	// Truncate the client addresses stored in logs when privacy mode is on and
+	// keep of anonymous visits only what tracking allows
+	middleware.InitializePrivacy(cfg)
+
+	// Reject tokens of revoked devices and track when devices were last seen
+	middleware.InitializeDeviceTracking(db)
+
 	// Add quota info to all authenticated responses
 	router.Use(middleware.QuotaInfoMiddleware(db))
 
@@ -83,8 +312,25 @@ func main() {
 		})
 	})
 
+	// Kubernetes-style probes: liveness never touches dependencies, readiness
+	// checks the database, storage and background jobs
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+
+	// Profiling and runtime variables for diagnosing production issues
+	if cfg.EnableDebugEndpoints {
+		debug := router.Group("/debug")
+		debug.Use(middleware.AuthMiddleware())
+		debug.Use(middleware.RequireAdmin())
+		handlers.RegisterDebugRoutes(debug)
+	}
+
 	// API routes
 	api := router.Group("/api/v1")
+	// Work out which organization each request is for
+	if cfg.EnableMultiTenancy {
+		api.Use(middleware.ResolveTenant(db, cfg))
+	}
 	{
 		// Auth routes
 		auth := api.Group("/auth")
@@ -93,29 +339,84 @@ func main() {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/logout", middleware.AuthMiddleware(), authHandler.Logout)
 			auth.GET("/me", middleware.AuthMiddleware(), authHandler.GetMe)
+
+			// SAML single sign-on
+			if samlService.Enabled() {
+				auth.GET("/saml/metadata", authHandler.SAMLMetadata)
+				auth.GET("/saml/login", authHandler.SAMLLogin)
+				auth.POST("/saml/acs", authHandler.SAMLACS)
+			}
+		}
+
+		// Resumable uploads over the tus protocol. Clients may discover the
+		// protocol with OPTIONS before signing in
+		api.OPTIONS("/uploads", fileHandler.TusOptions)
+		uploads := api.Group("/uploads")
+		uploads.Use(middleware.AuthMiddleware())
+		uploads.Use(middleware.RestrictGuests())
+		uploads.Use(middleware.RestrictReadOnly(db))
+		if cfg.EnableRateLimit {
+			uploads.Use(middleware.PlanRateLimit(db))
+		}
+		{
+			uploads.POST("", middleware.RequirePolicyAcceptance(policyService), fileHandler.CreateTusUpload)
+			uploads.HEAD("/:id", fileHandler.HeadTusUpload)
+			uploads.PATCH("/:id", middleware.RequirePolicyAcceptance(policyService), fileHandler.PatchTusUpload)
+			uploads.DELETE("/:id", fileHandler.DeleteTusUpload)
 		}
 
 		// Protected file routes
 		files := api.Group("/files")
 		files.Use(middleware.AuthMiddleware())
+		files.Use(middleware.RestrictGuests())
+		files.Use(middleware.RestrictReadOnly(db))
+		if cfg.EnableRateLimit {
+			files.Use(middleware.PlanRateLimit(db))
+		}
 
-		// Apply storage quota and file size limits to upload endpoints
+		// Apply storage quota to upload endpoints
 		if cfg.EnableQuotaCheck {
 			files.Use(middleware.StorageQuotaMiddleware(db, cfg))
-			files.Use(middleware.FileUploadSizeLimit(cfg))
 		}
 
 		{
-			files.POST("/upload", fileHandler.UploadFile)
+			files.POST("/upload", middleware.RequirePolicyAcceptance(policyService), middleware.FileUploadSizeLimit(cfg), fileHandler.UploadFile)
+			files.POST("/uploads", middleware.RequirePolicyAcceptance(policyService), fileHandler.CreateUploadSession)
+			files.GET("/uploads/:id", fileHandler.GetUploadSession)
+			files.PUT("/uploads/:id/chunks/:index", fileHandler.UploadChunk)
+			files.POST("/uploads/:id/complete", middleware.RequirePolicyAcceptance(policyService), fileHandler.CompleteUploadSession)
+			files.DELETE("/uploads/:id", fileHandler.AbortUploadSession)
+			files.POST("/direct-uploads", middleware.RequirePolicyAcceptance(policyService), fileHandler.CreateDirectUpload)
+			files.POST("/direct-uploads/:id/complete", middleware.RequirePolicyAcceptance(policyService), fileHandler.CompleteDirectUpload)
+			files.DELETE("/direct-uploads/:id", fileHandler.DeleteDirectUpload)
+			files.POST("/import-url", middleware.RequirePolicyAcceptance(policyService), fileHandler.ImportURL)
+			files.GET("/import-url/:id", fileHandler.GetURLImport)
 			files.GET("/", fileHandler.ListFiles)
+			files.GET("/gallery", fileHandler.ListGallery)
+			files.GET("/timeline", fileHandler.GetTimeline)
+			files.POST("/thumbnails", fileHandler.SignThumbnails)
 			files.POST("/search", fileHandler.SearchFiles) // Advanced search endpoint
+			files.POST("/download-zip", fileHandler.DownloadFilesZip)
 			files.GET("/public", fileHandler.GetPublicFiles)
 			files.GET("/stats", fileHandler.GetUserStats)
+			files.GET("/usage-breakdown", fileHandler.GetUsageBreakdown)
+			files.GET("/upload-policies", uploadPolicyHandler.GetMyPolicies)
+			files.POST("/upload-policies/preview", uploadPolicyHandler.PreviewPolicies)
 			files.GET("/download-stats", fileHandler.GetFileDownloadStats)
+			files.GET("/changes", middleware.RequireSyncDevice(), fileEventHandler.GetFileChanges)
 			files.GET("/:id", fileHandler.GetFile)
 			files.GET("/:id/view", fileHandler.ViewFile)
 			files.GET("/:id/download", fileHandler.DownloadFile)
+			files.GET("/:id/download-manifest", fileHandler.GetDownloadManifest)
+			files.GET("/:id/checksum", fileHandler.GetFileChecksum)
+			files.GET("/:id/ocr", fileHandler.GetOCRStatus)
+			files.POST("/:id/ocr", fileHandler.ReindexFile)
+			files.POST("/:id/verify", fileHandler.VerifyFile)
 			files.POST("/:id/move", fileHandler.MoveFile)
+			files.PUT("/:id/download-notifications", fileHandler.SetDownloadNotifications)
+			files.POST("/:id/restore-from-archive", archiveHandler.RestoreFromArchive)
+			files.GET("/:id/history", fileEventHandler.GetFileHistory)
+			files.GET("/:id/access-heatmap", fileHandler.GetAccessHeatmap)
 			files.DELETE("/:id", fileHandler.DeleteFile)
 
 			// File sharing routes
@@ -127,29 +428,149 @@ func main() {
 		// Sharing routes under /api/v1
 		api.GET("/shared-files", middleware.AuthMiddleware(), sharingHandler.GetSharedFiles)
 		api.GET("/shared-folders", middleware.AuthMiddleware(), folderSharingHandler.GetSharedFolders)
+		api.POST("/shared-files/:id/:action", middleware.AuthMiddleware(), sharingHandler.RespondToSharedFile)
+		api.POST("/shared-folders/:id/:action", middleware.AuthMiddleware(), folderSharingHandler.RespondToSharedFolder)
 		api.GET("/share-links", middleware.AuthMiddleware(), sharingHandler.GetShareLinks)
 		api.GET("/folder-share-links", middleware.AuthMiddleware(), folderSharingHandler.GetFolderShareLinks)
-		api.DELETE("/shares/:id", middleware.AuthMiddleware(), sharingHandler.RevokeFileShare)
-		api.DELETE("/folder-shares/:id", middleware.AuthMiddleware(), folderSharingHandler.RemoveFolderShare)
-		api.DELETE("/share-links/:id", middleware.AuthMiddleware(), sharingHandler.RevokeShareLink)
-		api.DELETE("/folder-share-links/:id", middleware.AuthMiddleware(), folderSharingHandler.RemoveFolderShareLink)
+		api.DELETE("/shares/:id", middleware.AuthMiddleware(), middleware.RestrictGuests(), sharingHandler.RevokeFileShare)
+		api.DELETE("/folder-shares/:id", middleware.AuthMiddleware(), middleware.RestrictGuests(), folderSharingHandler.RemoveFolderShare)
+		api.DELETE("/share-links/:id", middleware.AuthMiddleware(), middleware.RestrictGuests(), sharingHandler.RevokeShareLink)
+		api.PUT("/share-links/:id/download-notifications", middleware.AuthMiddleware(), middleware.RestrictGuests(), sharingHandler.SetShareLinkNotifications)
+		api.DELETE("/folder-share-links/:id", middleware.AuthMiddleware(), middleware.RestrictGuests(), folderSharingHandler.RemoveFolderShareLink)
 
 		// Protected folder routes
 		folders := api.Group("/folders")
 		folders.Use(middleware.AuthMiddleware())
+		folders.Use(middleware.RestrictGuests())
+		folders.Use(middleware.RestrictReadOnly(db))
+		if cfg.EnableRateLimit {
+			folders.Use(middleware.PlanRateLimit(db))
+		}
 		{
 			folders.POST("/", folderHandler.CreateFolder)
 			folders.GET("/", folderHandler.ListFolders)
 			folders.GET("/tree", folderHandler.GetFolderTree)
 			folders.GET("/:id", folderHandler.GetFolder)
+			folders.GET("/:id/download", folderHandler.DownloadFolderZip)
 			folders.PUT("/:id", folderHandler.UpdateFolder)
 			folders.POST("/:id/move", folderHandler.MoveFolder)
 			folders.DELETE("/:id", folderHandler.DeleteFolder)
+			folders.POST("/:id/worm", folderHandler.EnableWORM)
+			folders.PUT("/:id/size-limit", folderHandler.SetSizeLimit)
 
 			// Folder sharing routes
 			folders.POST("/:id/share", folderSharingHandler.ShareFolderWithUser)
 			folders.POST("/:id/share-link", folderSharingHandler.CreateFolderShareLink)
 			folders.GET("/:id/shares", folderSharingHandler.GetFolderShares)
+
+			// Keys of end-to-end encrypted folders
+			folders.GET("/:id/vault-key", vaultHandler.GetMyVaultKey)
+			folders.GET("/:id/vault-keys", vaultHandler.ListVaultKeys)
+			folders.PUT("/:id/vault-keys/:userId", vaultHandler.GrantVaultKey)
+			folders.DELETE("/:id/vault-keys/:userId", vaultHandler.RevokeVaultKey)
+		}
+
+		// Public keys that the keys of encrypted folders are wrapped with.
+		// Guests register one too, so folders can be shared with them.
+		vault := api.Group("/vault")
+		vault.Use(middleware.AuthMiddleware())
+		{
+			vault.GET("/config", vaultHandler.GetVaultConfig)
+			vault.GET("/public-key", vaultHandler.GetMyPublicKey)
+			vault.PUT("/public-key", vaultHandler.SetPublicKey)
+			vault.GET("/public-keys/:userId", vaultHandler.GetUserPublicKey)
+		}
+
+		// Current user's activity timeline
+		api.GET("/me/activity", middleware.AuthMiddleware(), activityHandler.GetMyActivity)
+
+		// Current user's preferences
+		api.GET("/me/settings", middleware.AuthMiddleware(), settingsHandler.GetSettings)
+		api.GET("/me/admin-access", middleware.AuthMiddleware(), auditHandler.GetMyAdminAccess)
+		api.PUT("/me/settings", middleware.AuthMiddleware(), settingsHandler.UpdateSettings)
+		api.GET("/me/upload-rules", middleware.AuthMiddleware(), settingsHandler.GetUploadRules)
+		api.PUT("/me/upload-rules", middleware.AuthMiddleware(), settingsHandler.UpdateUploadRules)
+
+		// Current user's cleanup rules, applied on a schedule
+		api.GET("/me/cleanup-rules", middleware.AuthMiddleware(), cleanupRuleHandler.ListCleanupRules)
+		api.POST("/me/cleanup-rules", middleware.AuthMiddleware(), cleanupRuleHandler.CreateCleanupRule)
+		api.PUT("/me/cleanup-rules/:id", middleware.AuthMiddleware(), cleanupRuleHandler.UpdateCleanupRule)
+		api.DELETE("/me/cleanup-rules/:id", middleware.AuthMiddleware(), cleanupRuleHandler.DeleteCleanupRule)
+		api.GET("/me/cleanup-rules/:id/preview", middleware.AuthMiddleware(), cleanupRuleHandler.PreviewCleanupRule)
+
+		// Which notifications the current user also gets by email
+		api.GET("/me/notification-settings", middleware.AuthMiddleware(), notificationHandler.GetNotificationSettings)
+		api.PUT("/me/notification-settings", middleware.AuthMiddleware(), notificationHandler.UpdateNotificationSettings)
+
+		// Current user's Slack and Teams integrations
+		api.GET("/me/integrations", middleware.AuthMiddleware(), integrationHandler.ListIntegrations)
+		api.POST("/me/integrations", middleware.AuthMiddleware(), integrationHandler.CreateIntegration)
+		api.PUT("/me/integrations/:id", middleware.AuthMiddleware(), integrationHandler.UpdateIntegration)
+		api.DELETE("/me/integrations/:id", middleware.AuthMiddleware(), integrationHandler.DeleteIntegration)
+		api.POST("/me/integrations/:id/test", middleware.AuthMiddleware(), integrationHandler.TestIntegration)
+
+		// API keys the current user connects automation services with
+		api.GET("/me/api-keys", middleware.AuthMiddleware(), apiKeyHandler.GetMyAPIKeys)
+		api.POST("/me/api-keys", middleware.AuthMiddleware(), middleware.RestrictGuests(), apiKeyHandler.CreateAPIKey)
+		api.DELETE("/me/api-keys/:id", middleware.AuthMiddleware(), apiKeyHandler.RevokeAPIKey)
+
+		// Polling triggers for Zapier, IFTTT and similar services, by API key
+		triggers := api.Group("/triggers")
+		triggers.Use(middleware.APIKeyMiddleware(db))
+		{
+			triggers.GET("/me", triggerHandler.GetMe)
+			triggers.GET("/new-files", triggerHandler.GetNewFiles)
+			triggers.GET("/new-shares", triggerHandler.GetNewShares)
+		}
+
+		// Devices the current user is signed in from
+		api.GET("/me/devices", middleware.AuthMiddleware(), deviceHandler.GetMyDevices)
+		api.DELETE("/me/devices/:id", middleware.AuthMiddleware(), deviceHandler.RevokeDevice)
+
+		// Current user's sign-in attempts
+		api.GET("/me/login-history", middleware.AuthMiddleware(), loginHistoryHandler.GetMyLoginHistory)
+
+		// Current user's rate limit consumption
+		api.GET("/me/usage", middleware.AuthMiddleware(), usageHandler.GetMyUsage)
+
+		// Terms-of-service and privacy policy acceptance
+		policies := api.Group("/policies")
+		policies.Use(middleware.AuthMiddleware())
+		{
+			policies.GET("/current", policyHandler.GetCurrentPolicies)
+			policies.POST("/accept", policyHandler.AcceptPolicies)
+			policies.GET("/acceptances", policyHandler.GetMyAcceptances)
+		}
+
+		// Notification routes
+		notifications := api.Group("/notifications")
+		notifications.Use(middleware.AuthMiddleware())
+		{
+			notifications.GET("/", notificationHandler.GetNotifications)
+			notifications.POST("/read-all", notificationHandler.MarkAllNotificationsRead)
+			notifications.POST("/:id/read", notificationHandler.MarkNotificationRead)
+		}
+
+		// Tenant branding and self-service administration
+		if cfg.EnableMultiTenancy {
+			api.GET("/tenant", tenantHandler.GetBranding)
+
+			tenant := api.Group("/tenant")
+			tenant.Use(middleware.AuthMiddleware())
+			tenant.Use(middleware.RequireTenantAdmin(db))
+			{
+				tenant.GET("/settings", tenantHandler.GetSettings)
+				tenant.PUT("/settings", tenantHandler.UpdateSettings)
+				tenant.GET("/users", tenantHandler.ListUsers)
+				tenant.PUT("/users/:id", tenantHandler.UpdateUser)
+
+				// Team Slack and Teams integrations
+				tenant.GET("/integrations", integrationHandler.ListIntegrations)
+				tenant.POST("/integrations", integrationHandler.CreateIntegration)
+				tenant.PUT("/integrations/:id", integrationHandler.UpdateIntegration)
+				tenant.DELETE("/integrations/:id", integrationHandler.DeleteIntegration)
+				tenant.POST("/integrations/:id/test", integrationHandler.TestIntegration)
+			}
 		}
 
 		// Admin routes
@@ -160,17 +581,100 @@ func main() {
 		{
 			admin.GET("/stats", adminHandler.GetStats)
 			admin.GET("/users", adminHandler.GetUsers)
+			admin.GET("/users/export", adminHandler.ExportUsers)
 			admin.GET("/users/:id", adminHandler.GetUserDetails)
+			admin.PUT("/users/:id", adminHandler.UpdateUser)
+			admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
+			admin.DELETE("/users/:id", adminHandler.DeleteUser)
 			admin.GET("/files", adminHandler.GetAllFilesWithStats)
+			admin.GET("/files/all", adminHandler.GetAllFiles)
 			admin.GET("/files/:id/stats", adminHandler.GetFileStats)
 			admin.GET("/files/:id/view", adminHandler.ViewFileAsAdmin)
 			admin.GET("/files/:id/download", adminHandler.DownloadFileAsAdmin)
+			admin.PUT("/folders/:id/size-limit", folderHandler.SetSizeLimitAsAdmin)
+			admin.GET("/system/health", adminHandler.GetSystemHealth)
+			admin.GET("/config/effective", adminHandler.GetEffectiveConfig)
+			admin.GET("/storage/health", adminHandler.GetStorageHealth)
+			admin.GET("/storage/replication", replicationHandler.GetReplicationStatus)
+
+			// Live operations console
+			admin.GET("/events/stream", opsHandler.StreamEvents)
+			admin.GET("/events/recent", opsHandler.GetRecentEvents)
+
+			// Upload policy routes
+			admin.GET("/upload-policies", uploadPolicyHandler.ListPolicies)
+			admin.POST("/upload-policies", uploadPolicyHandler.CreatePolicy)
+			admin.PUT("/upload-policies/:id", uploadPolicyHandler.UpdatePolicy)
+			admin.DELETE("/upload-policies/:id", uploadPolicyHandler.DeletePolicy)
+			admin.GET("/upload-rejections", uploadRejectionHandler.ListRejections)
+
+			// Quota plans
+			admin.GET("/plans", planHandler.ListPlans)
+			admin.POST("/plans", planHandler.CreatePlan)
+			admin.PUT("/plans/:id", planHandler.UpdatePlan)
+			admin.DELETE("/plans/:id", planHandler.DeletePlan)
+			admin.POST("/plans/:id/migrate", planHandler.MigrateUsers)
+			admin.PUT("/users/:id/plan", planHandler.AssignUserPlan)
+
+			// Tenants hosted on this deployment
+			if cfg.EnableMultiTenancy {
+				admin.GET("/tenants", tenantHandler.ListTenants)
+				admin.POST("/tenants", tenantHandler.CreateTenant)
+				admin.GET("/tenants/:id", tenantHandler.GetTenant)
+				admin.PUT("/tenants/:id", tenantHandler.UpdateTenant)
+			}
+
+			// Platform Slack and Teams integrations
+			admin.GET("/integrations", integrationHandler.ListIntegrations)
+			admin.POST("/integrations", integrationHandler.CreateIntegration)
+			admin.PUT("/integrations/:id", integrationHandler.UpdateIntegration)
+			admin.DELETE("/integrations/:id", integrationHandler.DeleteIntegration)
+			admin.POST("/integrations/:id/test", integrationHandler.TestIntegration)
+
+			// Login token signing keys
+			admin.GET("/jwt-keys", signingKeyHandler.ListSigningKeys)
+			admin.POST("/jwt-keys/rotate", signingKeyHandler.RotateSigningKey)
+
+			// Escrow copies of encrypted folder keys
+			admin.GET("/folders/:id/vault-key/escrow", vaultHandler.GetEscrowVaultKey)
+
+			// Per-user rate limit overrides
+			admin.GET("/rate-limit-overrides", rateLimitOverrideHandler.ListOverrides)
+			admin.PUT("/users/:id/rate-limit-override", rateLimitOverrideHandler.SetOverride)
+			admin.DELETE("/users/:id/rate-limit-override", rateLimitOverrideHandler.DeleteOverride)
+
+			// Content index and OCR
+			admin.GET("/content-index", contentIndexHandler.GetSummary)
+			admin.POST("/content-index/backfill", contentIndexHandler.Backfill)
+
+			// Policy versions and acceptance tracking
+			admin.GET("/policies", policyHandler.ListPolicies)
+			admin.POST("/policies", policyHandler.PublishPolicy)
+			admin.GET("/users/:id/policy-acceptances", policyHandler.GetUserAcceptances)
+
+			// Access simulator for "shared file not visible" reports
+			admin.GET("/users/:id/effective-access", accessHandler.GetEffectiveAccess)
+
+			// Quarantine review queue
+			admin.GET("/quarantine", quarantineHandler.ListQuarantine)
+			admin.POST("/quarantine/:id/release", quarantineHandler.ReleaseQuarantine)
+			admin.POST("/quarantine/:id/reject", quarantineHandler.RejectQuarantine)
+			admin.POST("/quarantine/:id/delete", quarantineHandler.DeleteQuarantine)
+
+			// Audit log integrity
+			admin.GET("/audit-logs", auditHandler.GetAdminAuditLogs)
+			admin.GET("/audit-logs/verify-chain", auditHandler.VerifyAuditChain)
+
+			// Backup routes
+			admin.POST("/backups", backupHandler.StartBackup)
+			admin.GET("/backups", backupHandler.ListBackups)
+			admin.GET("/backups/:id", backupHandler.GetBackup)
 
 			// Admin file upload with quota and size limits
 			if cfg.EnableQuotaCheck {
-				admin.POST("/files/upload", middleware.StorageQuotaMiddleware(db, cfg), middleware.FileUploadSizeLimit(cfg), adminHandler.UploadFileAsAdmin)
+				admin.POST("/files/upload", middleware.RequirePolicyAcceptance(policyService), middleware.StorageQuotaMiddleware(db, cfg), middleware.FileUploadSizeLimit(cfg), adminHandler.UploadFileAsAdmin)
 			} else {
-				admin.POST("/files/upload", adminHandler.UploadFileAsAdmin)
+				admin.POST("/files/upload", middleware.RequirePolicyAcceptance(policyService), middleware.FileUploadSizeLimit(cfg), adminHandler.UploadFileAsAdmin)
 			}
 
 			admin.POST("/files/:id/share", adminHandler.ShareFileAsAdmin)
@@ -180,6 +684,7 @@ func main() {
 
 			// Deduplication routes
 			admin.GET("/deduplication/summary", adminHandler.GetUserDeduplicationSummary)
+			admin.GET("/deduplication/top-hashes", adminHandler.GetTopDeduplicatedHashes)
 			admin.GET("/deduplication/users/:userId", adminHandler.GetUserDeduplicationDetails)
 
 			// Analytics routes
@@ -191,17 +696,32 @@ func main() {
 			admin.GET("/analytics/top-files", handlers.GetTopFiles)
 			admin.GET("/analytics/user-activity", handlers.GetUserActivity)
 			admin.GET("/analytics/storage-usage-trend", handlers.GetStorageUsageTrend)
+			admin.GET("/analytics/dedup-savings-trend", handlers.GetDedupSavingsTrend)
 		}
 	}
 
 	// Public sharing routes (no auth required)
-	router.GET("/share/:token", sharingHandler.AccessSharedFile)
-	router.GET("/share/:token/download", sharingHandler.DownloadSharedFile)
-	router.GET("/folder-share/:token", folderSharingHandler.AccessSharedFolderByLink)
+	router.GET("/share/:token", middleware.AnonymousAccess(), sharingHandler.AccessSharedFile)
+	router.GET("/share/:token/download", middleware.AnonymousAccess(), sharingHandler.DownloadSharedFile)
+	router.GET("/share/:token/preview", middleware.AnonymousAccess(), sharingHandler.PreviewSharedFile)
+	router.GET("/share/:token/pages/:n", middleware.AnonymousAccess(), sharingHandler.ViewSharedPage)
+	router.POST("/share/:token/unlock", middleware.AnonymousAccess(), sharingHandler.UnlockSharedFile)
+	router.GET("/folder-share/:token", middleware.AnonymousAccess(), folderSharingHandler.AccessSharedFolderByLink)
+	router.POST("/folder-share/:token/unlock", middleware.AnonymousAccess(), folderSharingHandler.UnlockSharedFolderByLink)
+	router.GET("/folder-share/:token/items/:fileId", middleware.AnonymousAccess(), folderSharingHandler.ViewAlbumItem)
+	router.POST("/folder-share/:token/download-zip", middleware.AnonymousAccess(), folderSharingHandler.DownloadAlbumZip)
+
+	// Public file routes (no auth required). A tenant's public files are
+	// only served for that tenant
+	publicFiles := router.Group("/public-files", middleware.AnonymousAccess())
+	if cfg.EnableMultiTenancy {
+		publicFiles.Use(middleware.ResolveTenant(db, cfg))
+	}
+	publicFiles.GET("/:id/view", fileHandler.ViewPublicFile)
+	publicFiles.GET("/:id/download", fileHandler.DownloadPublicFile)
 
-	// Public file routes (no auth required)
-	router.GET("/public-files/:id/view", fileHandler.ViewPublicFile)
-	router.GET("/public-files/:id/download", fileHandler.DownloadPublicFile)
+	// Gallery thumbnails, authorized by the signature in their URL
+	router.GET("/thumbnails/:id", middleware.AnonymousAccess(), fileHandler.GetThumbnail)
 
 	log.Printf("Server starting on port %s", cfg.Port)
 	log.Fatal(router.Run(":8080"))
@@ -0,0 +1,120 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// client calls the API of the server under test
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newClient(baseURL string) *client {
+	return &client{
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// response is an API response with its body read
+type response struct {
+	Status int
+	Body   []byte
+}
+
+// decode unmarshals the JSON body into v
+func (r *response) decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// String summarizes the response for failure messages
+func (r *response) String() string {
+	body := string(r.Body)
+	if len(body) > 300 {
+		body = body[:300] + "..."
+	}
+	return fmt.Sprintf("%d %s", r.Status, body)
+}
+
+// do sends a request, authenticated when token is set
+func (c *client) do(method, path, token, contentType string, body io.Reader) (*response, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s %s response: %w", method, path, err)
+	}
+	return &response{Status: resp.StatusCode, Body: data}, nil
+}
+
+// doJSON sends a request with a JSON body, or none when payload is nil
+func (c *client) doJSON(method, path, token string, payload interface{}) (*response, error) {
+	if payload == nil {
+		return c.do(method, path, token, "", nil)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(method, path, token, "application/json", bytes.NewReader(data))
+}
+
+// upload sends one file to the upload endpoint
+func (c *client) upload(token, filename string, content []byte) (*response, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+	return c.do(http.MethodPost, "/api/v1/files/upload", token, form.FormDataContentType(), &body)
+}
+
+// waitReady polls the health check until the server answers or the timeout
+// passes
+func (c *client) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := c.do(http.MethodGet, "/health", "", "", nil)
+		if err == nil && resp.Status == http.StatusOK {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("health check answered %s", resp)
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,216 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// user is a registered account and its session token
+type user struct {
+	ID    string
+	Email string
+	Token string
+}
+
+// uploadedFile is the part of an upload result the tests check
+type uploadedFile struct {
+	ID             string `json:"id"`
+	Size           int64  `json:"size"`
+	ContentHash    string `json:"content_hash"`
+	IsDuplicate    bool   `json:"is_duplicate"`
+	SavedBytes     int64  `json:"saved_bytes"`
+	StorageCharged int64  `json:"storage_charged"`
+}
+
+// storageStats is the part of the storage statistics the tests check
+type storageStats struct {
+	StorageUsed        int64 `json:"storage_used"`
+	StorageQuota       int64 `json:"storage_quota"`
+	RemainingStorage   int64 `json:"remaining_storage"`
+	FileCount          int64 `json:"file_count"`
+	ActualStorageBytes int64 `json:"actual_storage_bytes"`
+	SavedBytes         int64 `json:"saved_bytes"`
+}
+
+// listedFile is the part of a file listing entry the tests check
+type listedFile struct {
+	ID         string `json:"id"`
+	Size       int64  `json:"size"`
+	FileHashID string `json:"file_hash_id"`
+}
+
+// call sends a JSON request and fails the test if it cannot be sent
+func (r *runner) call(t *testing.T, method, path, token string, payload interface{}) *response {
+	t.Helper()
+	resp, err := r.client.doJSON(method, path, token, payload)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+// expectStatus fails the test unless the response has the given status
+func expectStatus(t *testing.T, what string, resp *response, status int) {
+	t.Helper()
+	if resp.Status != status {
+		t.Fatalf("%s: expected status %d, got %s", what, status, resp)
+	}
+}
+
+// decodeKeys decodes a JSON object and checks that it has the given keys,
+// which API clients rely on
+func decodeKeys(t *testing.T, what string, data []byte, keys ...string) map[string]json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("%s: invalid JSON object: %v", what, err)
+	}
+	for _, key := range keys {
+		if _, ok := fields[key]; !ok {
+			t.Fatalf("%s: missing %q in %s", what, key, data)
+		}
+	}
+	return fields
+}
+
+// decodeInto unmarshals a JSON value and fails the test if it does not fit v
+func decodeInto(t *testing.T, what string, data []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v in %s", what, err, data)
+	}
+}
+
+// register creates a user whose name is unique to this run
+func (r *runner) register(t *testing.T, name string) *user {
+	t.Helper()
+	username := name + "-" + r.run
+	email := username + "@e2e.example.com"
+	resp := r.call(t, http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"username": username,
+		"email":    email,
+		"password": "E2e-password-" + r.run,
+	})
+	expectStatus(t, "register "+name, resp, http.StatusCreated)
+
+	body := decodeKeys(t, "register "+name, resp.Body, "token", "user")
+	decodeKeys(t, "registered user", body["user"], "id", "username", "email", "role", "storage_quota", "storage_used")
+
+	var result struct {
+		Token string `json:"token"`
+		User  struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	decodeInto(t, "register "+name, resp.Body, &result)
+	return &user{ID: result.User.ID, Email: email, Token: result.Token}
+}
+
+// upload uploads one file and returns its upload result
+func (r *runner) upload(t *testing.T, u *user, filename string, content []byte) uploadedFile {
+	t.Helper()
+	resp := r.tryUpload(t, u, filename, content)
+	expectStatus(t, "upload "+filename, resp, http.StatusOK)
+
+	body := decodeKeys(t, "upload "+filename, resp.Body, "files", "total_size", "total_saved_bytes", "total_storage_charged", "uploaded_files_count")
+	var entries []json.RawMessage
+	decodeInto(t, "uploaded files", body["files"], &entries)
+	if len(entries) != 1 {
+		t.Fatalf("upload %s: expected 1 file, got %d", filename, len(entries))
+	}
+	decodeKeys(t, "uploaded file", entries[0], "id", "filename", "original_filename", "size", "mime_type", "content_hash", "is_duplicate", "saved_bytes", "storage_charged")
+
+	var file uploadedFile
+	decodeInto(t, "uploaded file", entries[0], &file)
+	if file.Size != int64(len(content)) {
+		t.Fatalf("upload %s: expected size %d, got %d", filename, len(content), file.Size)
+	}
+	return file
+}
+
+// tryUpload uploads one file and returns the response, whatever its status
+func (r *runner) tryUpload(t *testing.T, u *user, filename string, content []byte) *response {
+	t.Helper()
+	resp, err := r.client.upload(u.Token, filename, content)
+	if err != nil {
+		t.Fatalf("upload %s: %v", filename, err)
+	}
+	return resp
+}
+
+// stats returns the user's storage statistics
+func (r *runner) stats(t *testing.T, u *user) storageStats {
+	t.Helper()
+	resp := r.call(t, http.MethodGet, "/api/v1/files/stats", u.Token, nil)
+	expectStatus(t, "storage stats", resp, http.StatusOK)
+	decodeKeys(t, "storage stats", resp.Body, "storage_used", "storage_quota", "remaining_storage", "file_count", "actual_storage_bytes", "saved_bytes", "total_uploaded_bytes")
+
+	var stats storageStats
+	decodeInto(t, "storage stats", resp.Body, &stats)
+	return stats
+}
+
+// listFiles returns the user's files
+func (r *runner) listFiles(t *testing.T, u *user) []listedFile {
+	t.Helper()
+	resp := r.call(t, http.MethodGet, "/api/v1/files/?limit=100&page=1", u.Token, nil)
+	expectStatus(t, "list files", resp, http.StatusOK)
+
+	var result struct {
+		Files []listedFile `json:"files"`
+	}
+	decodeKeys(t, "list files", resp.Body, "files", "total_count")
+	decodeInto(t, "list files", resp.Body, &result)
+	return result.Files
+}
+
+// deleteFile deletes a file and expects the given status
+func (r *runner) deleteFile(t *testing.T, u *user, fileID string, status int) {
+	t.Helper()
+	resp := r.call(t, http.MethodDelete, "/api/v1/files/"+fileID, u.Token, nil)
+	expectStatus(t, "delete file", resp, status)
+}
+
+// share shares a file with another user and returns the share ID
+func (r *runner) share(t *testing.T, owner *user, fileID string, with *user, permission string) string {
+	t.Helper()
+	resp := r.call(t, http.MethodPost, "/api/v1/files/"+fileID+"/share", owner.Token, map[string]string{
+		"email":      with.Email,
+		"permission": permission,
+	})
+	expectStatus(t, "share file", resp, http.StatusCreated)
+
+	body := decodeKeys(t, "share file", resp.Body, "share")
+	decodeKeys(t, "share", body["share"], "id", "file_id")
+	var share struct {
+		ID string `json:"id"`
+	}
+	decodeInto(t, "share", body["share"], &share)
+	return share.ID
+}
+
+// expectDownload downloads a file and checks that its content is unchanged
+func (r *runner) expectDownload(t *testing.T, u *user, fileID string, content []byte) {
+	t.Helper()
+	resp := r.call(t, http.MethodGet, "/api/v1/files/"+fileID+"/download", u.Token, nil)
+	expectStatus(t, "download file", resp, http.StatusOK)
+	if string(resp.Body) != string(content) {
+		t.Fatalf("download file: got %d bytes that differ from the %d uploaded", len(resp.Body), len(content))
+	}
+}
+
+// textContent returns n bytes of random hex text. Each call gives new
+// content, and text passes the upload's file type checks everywhere.
+func textContent(n int) []byte {
+	raw := make([]byte, (n+1)/2)
+	if _, err := rand.Read(raw); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	return []byte(hex.EncodeToString(raw)[:n])
+}
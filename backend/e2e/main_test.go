@@ -0,0 +1,66 @@
+//go:build e2e
+
+// Package e2e holds end-to-end API contract tests: uploading, deduplicating,
+// deleting and uploading content again, the file permission matrix, and the
+// storage accounting behind quotas.
+//
+// They only build with the e2e tag, so `go test ./...` leaves them out. Run
+// them with `make e2e`: TestMain starts a disposable PostgreSQL with
+// testcontainers, builds the server and runs it against that database and a
+// temporary storage directory, and removes all of it afterwards. With
+// E2E_BASE_URL set the tests call that server instead and need no Docker;
+// every run registers its own users, so any server will do as long as its
+// DEFAULT_USER_QUOTA is at most 8 MB.
+package e2e
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// api is the server under test, set up by TestMain
+var api *runner
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+// run sets up the server under test, runs the tests and tears the server
+// down again, returning the exit code
+func run(m *testing.M) (code int) {
+	baseURL := strings.TrimRight(os.Getenv("E2E_BASE_URL"), "/")
+	if baseURL == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		env, err := startStack(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Starting the test stack: %v", err)
+			return 1
+		}
+		// The server's log explains most failures
+		defer func() { env.stop(code != 0) }()
+		baseURL = env.baseURL
+	}
+
+	c := newClient(baseURL)
+	if err := c.waitReady(2 * time.Minute); err != nil {
+		log.Printf("Server at %s is not up: %v", baseURL, err)
+		return 1
+	}
+	api = &runner{
+		client: c,
+		run:    strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+	return m.Run()
+}
+
+// runner calls the server under test on behalf of the tests
+type runner struct {
+	client *client
+	run    string // keeps the users of this run apart from earlier runs
+}
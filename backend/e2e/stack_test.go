@@ -0,0 +1,154 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// testQuota is the user quota the server runs with. The quota test fills it,
+// so it is kept small
+const testQuota = 1 << 20
+
+// stack is a disposable PostgreSQL and a server running against it
+type stack struct {
+	baseURL  string
+	database *postgres.PostgresContainer
+	server   *exec.Cmd
+	dir      string // holds the server binary, its log and its storage
+}
+
+// startStack starts PostgreSQL in a container, then builds the server and
+// runs it against that database with storage in a temporary directory
+func startStack(ctx context.Context) (*stack, error) {
+	dir, err := os.MkdirTemp("", "filevault-e2e-")
+	if err != nil {
+		return nil, err
+	}
+	s := &stack{dir: dir}
+
+	s.database, err = postgres.Run(ctx, "postgres:15-alpine",
+		postgres.WithDatabase("filevault"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("e2e"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute)),
+	)
+	if err != nil {
+		s.stop(false)
+		return nil, fmt.Errorf("starting PostgreSQL: %w", err)
+	}
+	host, err := s.database.Host(ctx)
+	if err != nil {
+		s.stop(false)
+		return nil, err
+	}
+	dbPort, err := s.database.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		s.stop(false)
+		return nil, err
+	}
+
+	// The tests run in backend/e2e; the server is built and run from
+	// backend, where it finds its migrations
+	binary := filepath.Join(dir, "server")
+	build := exec.CommandContext(ctx, "go", "build", "-o", binary, "./cmd/server")
+	build.Dir = ".."
+	if out, err := build.CombinedOutput(); err != nil {
+		s.stop(false)
+		return nil, fmt.Errorf("building the server: %w\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		s.stop(false)
+		return nil, err
+	}
+	storage := filepath.Join(dir, "storage")
+	if err := os.MkdirAll(storage, 0o755); err != nil {
+		s.stop(false)
+		return nil, err
+	}
+	logFile, err := os.Create(filepath.Join(dir, "server.log"))
+	if err != nil {
+		s.stop(false)
+		return nil, err
+	}
+
+	s.server = exec.Command(binary)
+	s.server.Dir = ".."
+	s.server.Stdout, s.server.Stderr = logFile, logFile
+	s.server.Env = append(os.Environ(),
+		"ENVIRONMENT=development",
+		"PORT="+strconv.Itoa(port),
+		"DB_DRIVER=postgres",
+		"DB_HOST="+host,
+		"DB_PORT="+dbPort.Port(),
+		"DB_USER=postgres",
+		"DB_PASSWORD=e2e",
+		"DB_NAME=filevault",
+		"DB_SSL_MODE=disable",
+		"JWT_SECRET=e2e-jwt-secret",
+		"STORAGE_PATH="+storage,
+		"UPLOAD_TEMP_DIR="+filepath.Join(dir, "staging"),
+		"ENABLE_RATE_LIMIT=false",
+		"ENABLE_QUOTA_CHECK=true",
+		"DEFAULT_USER_QUOTA="+strconv.Itoa(testQuota),
+	)
+	if err := s.server.Start(); err != nil {
+		logFile.Close()
+		s.stop(false)
+		return nil, fmt.Errorf("starting the server: %w", err)
+	}
+	logFile.Close()
+
+	s.baseURL = fmt.Sprintf("http://localhost:%d", port)
+	return s, nil
+}
+
+// stop stops the server and the database and removes the temporary
+// directory, printing the server's log first when asked to
+func (s *stack) stop(printLog bool) {
+	if s.server != nil && s.server.Process != nil {
+		s.server.Process.Kill()
+		s.server.Wait()
+	}
+	if s.database != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := s.database.Terminate(ctx); err != nil {
+			log.Printf("Stopping PostgreSQL: %v", err)
+		}
+		cancel()
+	}
+	if printLog {
+		if out, err := os.ReadFile(filepath.Join(s.dir, "server.log")); err == nil {
+			log.Printf("Server log:\n%s", bytes.TrimSpace(out))
+		}
+	}
+	os.RemoveAll(s.dir)
+}
+
+// freePort returns a local TCP port that nothing listens on
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,201 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+// maxQuota is the largest user quota the quota test fills in a few uploads
+const maxQuota = 8 << 20
+
+// TestContentLifecycle uploads content, uploads it again as another user,
+// deletes both copies and checks that the content is stored afresh afterwards
+func TestContentLifecycle(t *testing.T) {
+	r := api
+	alice := r.register(t, "alice")
+	bob := r.register(t, "bob")
+	content := textContent(4096)
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	first := r.upload(t, alice, "report.txt", content)
+	if first.IsDuplicate || first.ContentHash != hash || first.StorageCharged != first.Size {
+		t.Fatalf("first upload: expected new content with hash %s charged in full, got %+v", hash, first)
+	}
+
+	resp := r.call(t, http.MethodGet, "/api/v1/files/"+first.ID, alice.Token, nil)
+	expectStatus(t, "get file", resp, http.StatusOK)
+	body := decodeKeys(t, "get file", resp.Body, "file")
+	fields := decodeKeys(t, "file", body["file"], "id", "original_filename", "size", "mime_type", "sha256", "owner_id", "created_at")
+	var sha string
+	decodeInto(t, "file sha256", fields["sha256"], &sha)
+	if sha != hash {
+		t.Fatalf("get file: expected sha256 %s, got %s", hash, sha)
+	}
+	r.expectDownload(t, alice, first.ID, content)
+
+	// Deduplication saves storage, but each user pays for content they hold
+	second := r.upload(t, bob, "copy.txt", content)
+	if !second.IsDuplicate || second.SavedBytes != second.Size || second.StorageCharged != second.Size {
+		t.Fatalf("second upload: expected a duplicate charged in full, got %+v", second)
+	}
+
+	r.deleteFile(t, alice, first.ID, http.StatusOK)
+	resp = r.call(t, http.MethodGet, "/api/v1/files/"+first.ID, alice.Token, nil)
+	expectStatus(t, "get deleted file", resp, http.StatusNotFound)
+	r.expectDownload(t, bob, second.ID, content)
+
+	// With no file left pointing at it the content is gone, so it is not
+	// a duplicate when it comes back
+	r.deleteFile(t, bob, second.ID, http.StatusOK)
+	again := r.upload(t, alice, "report.txt", content)
+	if again.IsDuplicate || again.StorageCharged != again.Size {
+		t.Fatalf("upload after delete: expected new content charged in full, got %+v", again)
+	}
+	r.expectDownload(t, alice, again.ID, content)
+	r.deleteFile(t, alice, again.ID, http.StatusOK)
+}
+
+// TestPermissionMatrix checks what owners, share recipients and strangers
+// can do with a file
+func TestPermissionMatrix(t *testing.T) {
+	r := api
+	owner := r.register(t, "owner")
+	viewer := r.register(t, "viewer")
+	downloader := r.register(t, "downloader")
+	stranger := r.register(t, "stranger")
+
+	content := textContent(1024)
+	file := r.upload(t, owner, "plans.txt", content)
+	viewShare := r.share(t, owner, file.ID, viewer, "view")
+	r.share(t, owner, file.ID, downloader, "download")
+
+	// Files a user cannot see at all answer as if they did not exist
+	matrix := []struct {
+		name     string
+		user     *user
+		get      int
+		download int
+		delete   int
+	}{
+		{"stranger", stranger, http.StatusNotFound, http.StatusNotFound, http.StatusNotFound},
+		{"viewer", viewer, http.StatusOK, http.StatusForbidden, http.StatusForbidden},
+		{"downloader", downloader, http.StatusOK, http.StatusOK, http.StatusForbidden},
+	}
+	for _, row := range matrix {
+		resp := r.call(t, http.MethodGet, "/api/v1/files/"+file.ID, row.user.Token, nil)
+		expectStatus(t, row.name+" get", resp, row.get)
+		resp = r.call(t, http.MethodGet, "/api/v1/files/"+file.ID+"/download", row.user.Token, nil)
+		expectStatus(t, row.name+" download", resp, row.download)
+		if row.download == http.StatusOK && string(resp.Body) != string(content) {
+			t.Fatalf("%s download: content differs from the upload", row.name)
+		}
+		r.deleteFile(t, row.user, file.ID, row.delete)
+	}
+	r.expectDownload(t, owner, file.ID, content)
+
+	resp := r.call(t, http.MethodPost, "/api/v1/files/"+file.ID+"/share", downloader.Token, map[string]string{
+		"email":      stranger.Email,
+		"permission": "view",
+	})
+	expectStatus(t, "downloader reshare", resp, http.StatusNotFound)
+
+	resp = r.call(t, http.MethodDelete, "/api/v1/shares/"+viewShare, owner.Token, nil)
+	expectStatus(t, "revoke share", resp, http.StatusOK)
+	resp = r.call(t, http.MethodGet, "/api/v1/files/"+file.ID, viewer.Token, nil)
+	expectStatus(t, "viewer get after revoke", resp, http.StatusNotFound)
+
+	r.deleteFile(t, owner, file.ID, http.StatusOK)
+	resp = r.call(t, http.MethodGet, "/api/v1/files/"+file.ID, downloader.Token, nil)
+	expectStatus(t, "downloader get after delete", resp, http.StatusNotFound)
+}
+
+// TestQuotaAccounting checks that usage always matches the user's files,
+// that content the user already holds is not counted twice, and that uploads
+// over the quota are refused without changing usage
+func TestQuotaAccounting(t *testing.T) {
+	r := api
+	u := r.register(t, "quota")
+	start := r.checkUsage(t, u)
+	if start.StorageUsed != 0 || start.FileCount != 0 {
+		t.Fatalf("new user: expected no usage, got %+v", start)
+	}
+	quota := start.StorageQuota
+	if quota <= 0 || quota > maxQuota {
+		t.Fatalf("quota of %d bytes: run the server with DEFAULT_USER_QUOTA of at most %d", quota, maxQuota)
+	}
+
+	small := textContent(1000)
+	a := r.upload(t, u, "a.txt", small)
+	b := r.upload(t, u, "b.txt", small)
+	if b.StorageCharged != 0 {
+		t.Fatalf("second copy: expected nothing charged, got %+v", b)
+	}
+	stats := r.checkUsage(t, u)
+	if stats.StorageUsed != 1000 || stats.ActualStorageBytes != 1000 || stats.SavedBytes != 1000 {
+		t.Fatalf("after uploading one file twice: expected 1000 used, 1000 stored and 1000 saved, got %+v", stats)
+	}
+
+	// The content stays charged while either copy is left
+	r.deleteFile(t, u, a.ID, http.StatusOK)
+	if stats := r.checkUsage(t, u); stats.StorageUsed != 1000 {
+		t.Fatalf("after deleting one copy: expected 1000 used, got %+v", stats)
+	}
+
+	big := r.upload(t, u, "big.txt", textContent(int(quota*6/10)))
+	before := r.checkUsage(t, u)
+
+	other := textContent(int(quota * 6 / 10))
+	resp := r.tryUpload(t, u, "other.txt", other)
+	expectStatus(t, "upload over quota", resp, http.StatusForbidden)
+	fields := decodeKeys(t, "upload over quota", resp.Body, "code")
+	var code string
+	decodeInto(t, "upload over quota code", fields["code"], &code)
+	if code != "QUOTA_EXCEEDED" {
+		t.Fatalf("upload over quota: expected code QUOTA_EXCEEDED, got %s", code)
+	}
+	if after := r.checkUsage(t, u); after.StorageUsed != before.StorageUsed {
+		t.Fatalf("refused upload changed usage from %d to %d", before.StorageUsed, after.StorageUsed)
+	}
+
+	r.deleteFile(t, u, big.ID, http.StatusOK)
+	replacement := r.upload(t, u, "other.txt", other)
+	r.checkUsage(t, u)
+
+	for _, id := range []string{b.ID, replacement.ID} {
+		r.deleteFile(t, u, id, http.StatusOK)
+	}
+	if end := r.checkUsage(t, u); end.StorageUsed != 0 || end.ActualStorageBytes != 0 {
+		t.Fatalf("after deleting everything: expected no usage, got %+v", end)
+	}
+}
+
+// checkUsage returns the user's storage statistics after checking that they
+// agree with the user's files, counting each distinct content once
+func (r *runner) checkUsage(t *testing.T, u *user) storageStats {
+	t.Helper()
+	stats := r.stats(t, u)
+	files := r.listFiles(t, u)
+
+	var total int64
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if !seen[file.FileHashID] {
+			seen[file.FileHashID] = true
+			total += file.Size
+		}
+	}
+	if stats.StorageUsed != total || stats.FileCount != int64(len(files)) {
+		t.Fatalf("usage of %d bytes in %d files does not match the %d bytes of distinct content in %d listed files",
+			stats.StorageUsed, stats.FileCount, total, len(files))
+	}
+	if stats.RemainingStorage != stats.StorageQuota-stats.StorageUsed {
+		t.Fatalf("remaining storage %d is not quota %d less usage %d",
+			stats.RemainingStorage, stats.StorageQuota, stats.StorageUsed)
+	}
+	return stats
+}
@@ -0,0 +1,180 @@
+// Package apperrors is the catalog of errors services return when a request
+// cannot be served. Each carries the HTTP status and the body clients see, so
+// handlers hand errors to the ErrorHandler middleware instead of writing their
+// own JSON. The underlying cause is kept for logs and never sent to clients.
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+
+	"file-vault-system/backend/pkg/database"
+)
+
+// Error is an application error with its HTTP mapping
+type Error struct {
+	Status  int    // HTTP status of the response
+	Type    string // broad category, sent as "type"
+	Code    string // specific reason clients switch on, sent as "code"
+	Message string // short summary, sent as "error"
+	Detail  string // longer explanation, sent as "message"; defaults to Message
+
+	Details map[string]interface{} // extra fields merged into the body
+
+	kind  *Error // catalog entry this error was derived from
+	cause error
+}
+
+// Catalog entries. Services derive specific errors from these with With or
+// WithCode; errors.Is matches a derived error against its entry.
+var (
+	ErrInvalidInput = &Error{
+		Status: http.StatusBadRequest, Type: "INVALID_REQUEST", Code: "INVALID_REQUEST",
+		Message: "Invalid request",
+	}
+	ErrUnauthorized = &Error{
+		Status: http.StatusUnauthorized, Type: "UNAUTHORIZED", Code: "UNAUTHORIZED",
+		Message: "Authentication required",
+	}
+	ErrForbidden = &Error{
+		Status: http.StatusForbidden, Type: "ACCESS_DENIED", Code: "ACCESS_DENIED",
+		Message: "Access denied", Detail: "Your access to this resource does not allow this action",
+	}
+	ErrNotFound = &Error{
+		Status: http.StatusNotFound, Type: "NOT_FOUND", Code: "NOT_FOUND",
+		Message: "Not found",
+	}
+	ErrConflict = &Error{
+		Status: http.StatusConflict, Type: "CONFLICT", Code: "CONFLICT",
+		Message: "The request conflicts with the current state",
+	}
+	ErrDuplicateName = &Error{
+		Status: http.StatusConflict, Type: "DUPLICATE_NAME", Code: "DUPLICATE_NAME",
+		Message: "An item with this name already exists",
+	}
+	ErrGone = &Error{
+		Status: http.StatusGone, Type: "GONE", Code: "GONE",
+		Message: "No longer available",
+	}
+	ErrQuotaExceeded = &Error{
+		Status: http.StatusForbidden, Type: "STORAGE_QUOTA_EXCEEDED", Code: "QUOTA_EXCEEDED",
+		Message: "Storage quota exceeded",
+	}
+	ErrTimeout = &Error{
+		Status: http.StatusServiceUnavailable, Type: "SERVICE_UNAVAILABLE", Code: "QUERY_TIMEOUT",
+		Message: "Request timed out", Detail: "The server is busy and could not finish the request in time. Please try again shortly",
+	}
+	ErrInternal = &Error{
+		Status: http.StatusInternalServerError, Type: "INTERNAL_ERROR", Code: "INTERNAL_ERROR",
+		Message: "Internal server error", Detail: "Something went wrong while processing the request",
+	}
+)
+
+// Error returns the message followed by the cause, for logs
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is the catalog entry e was derived from
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+// derive copies e as a new error of the same catalog kind
+func (e *Error) derive() *Error {
+	derived := *e
+	if e.kind == nil {
+		derived.kind = e
+	}
+	if e.Details != nil {
+		derived.Details = make(map[string]interface{}, len(e.Details))
+		for k, v := range e.Details {
+			derived.Details[k] = v
+		}
+	}
+	return &derived
+}
+
+// With returns an error of the same kind with a specific message
+func (e *Error) With(message string) *Error {
+	derived := e.derive()
+	derived.Message = message
+	derived.Detail = ""
+	return derived
+}
+
+// WithCode returns an error of the same kind with a specific code and message
+func (e *Error) WithCode(code, message string) *Error {
+	derived := e.With(message)
+	derived.Code = code
+	return derived
+}
+
+// Explain returns a copy with a longer explanation for the user
+func (e *Error) Explain(detail string) *Error {
+	derived := e.derive()
+	derived.Detail = detail
+	return derived
+}
+
+// WithDetail returns a copy with an extra field in the response body
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	derived := e.derive()
+	if derived.Details == nil {
+		derived.Details = make(map[string]interface{})
+	}
+	derived.Details[key] = value
+	return derived
+}
+
+// Wrap returns a copy that records cause for logging
+func (e *Error) Wrap(cause error) *Error {
+	derived := e.derive()
+	derived.cause = cause
+	return derived
+}
+
+// Internal wraps an unexpected error as an internal error, or as ErrTimeout
+// when a database query ran out of time
+func Internal(cause error) *Error {
+	if database.IsTimeout(cause) {
+		return ErrTimeout.Wrap(cause)
+	}
+	return ErrInternal.Wrap(cause)
+}
+
+// From returns err as an application error. Errors outside the catalog are
+// treated as internal so their text never reaches clients.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
+
+// Body returns the JSON response body for the error
+func (e *Error) Body() map[string]interface{} {
+	detail := e.Detail
+	if detail == "" {
+		detail = e.Message
+	}
+	body := map[string]interface{}{
+		"error":   e.Message,
+		"type":    e.Type,
+		"message": detail,
+		"code":    e.Code,
+	}
+	for k, v := range e.Details {
+		body[k] = v
+	}
+	return body
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -16,6 +18,8 @@ type Config struct {
 	IdleTimeout  int
 
 	// Database configuration
+	DatabaseDriver   string // "postgres" or "sqlite"
+	SQLitePath       string // database file when DatabaseDriver is "sqlite"
 	DatabaseURL      string
 	DatabaseHost     string
 	DatabasePort     string
@@ -24,40 +28,283 @@ type Config struct {
 	DatabaseName     string
 	DatabaseSSLMode  string
 
+	// Database connection pool
+	DBMaxOpenConns    int // connections the pool may open; requests beyond this wait for one
+	DBMaxIdleConns    int // connections kept open while idle
+	DBConnMaxLifetime int // in minutes before a connection is closed and replaced; 0 keeps it forever
+	DBConnMaxIdleTime int // in minutes an idle connection is kept; 0 keeps it forever
+
+	// Database timeouts
+	DBStatementTimeout  int // in seconds a single SQL statement may run before it is canceled; 0 disables
+	DBTimeoutRetryAfter int // in seconds clients are told to wait after a request times out
+
 	// JWT configuration
-	JWTSecret     string
-	JWTExpiration int // in hours
+	JWTSecret              string
+	JWTExpiration          int // in hours
+	JWTKeyRotationDays     int // days a signing key is used before a new one replaces it; 0 rotates only on request
+	JWTKeyGraceHours       int // hours tokens signed with a replaced key keep working; defaults to JWTExpiration
+	JWTKeyRotationInterval int // in hours between checks for a signing key due for rotation
 
 	// Rate limiting configuration
-	RateLimit       int    // requests per second (default: 2)
-	RateLimitWindow int    // window in seconds (default: 1)
-	RateLimitBurst  int    // burst capacity (default: 5)
-	EnableRateLimit bool   // enable/disable rate limiting
-	RateLimitMode   string // "memory" or "database"
+	RateLimit              int    // requests per second (default: 2)
+	RateLimitWindow        int    // window in seconds (default: 1)
+	RateLimitBurst         int    // burst capacity (default: 5)
+	EnableRateLimit        bool   // enable/disable rate limiting
+	RateLimitMode          string // "memory" or "database"
+	RateLimitPurgeInterval int    // in minutes between purges of ended database rate limit windows
 
 	// Storage configuration
 	StoragePath      string
+	StorageBackend   string // "disk", "s3" to keep blobs in an S3-compatible bucket, or "null" to discard blob content during load tests
 	AllowedMimeTypes []string
 
+	// S3-compatible object storage, used when StorageBackend is "s3"
+	S3Endpoint        string // such as https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	S3Region          string
+	S3Bucket          string
+	S3Prefix          string // prepended to every blob key, for sharing a bucket
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3ForcePathStyle  bool   // address the bucket in the URL path, as MinIO expects
+	S3PublicEndpoint  string // the endpoint clients reach for direct uploads, when not S3Endpoint
+
+	// MIME sniffing configuration
+	MimeSniffBytes       int      // leading bytes of each upload inspected to detect its type
+	MimeDeepInspectTypes []string // types whose structure is checked instead of trusting the extension
+
+	// Blob layout configuration
+	MigrateBlobLayout   bool // move blobs stored flat under storage/{hash} into sharded directories at startup
+	BlobLayoutBatchSize int  // blobs moved per migration batch
+
+	// Orphan blob sweep configuration
+	OrphanBlobSweepInterval int // in minutes between sweeps removing the content of unreferenced blobs; 0 disables them
+
+	// Upload staging configuration
+	MultipartMemoryLimit int64  // bytes of multipart data buffered in memory before spilling to disk
+	UploadTempDir        string // directory used to stage uploads before they are committed to storage
+	UploadTempMaxAge     int    // in hours; staged files older than this are swept at startup
+	UploadChunkSize      int64  // default chunk size of resumable uploads in bytes
+	UploadChunkMaxSize   int64  // largest chunk size clients may choose for a resumable upload
+	UploadSessionTTL     int    // in hours; resumable uploads not completed by then are discarded
+
+	// Remote URL import configuration
+	URLImportTimeout      int  // in seconds a URL import may spend downloading
+	URLImportMaxActive    int  // URL imports one user may have running at once; 0 for no limit
+	URLImportAllowPrivate bool // allow imports from addresses other than public unicast ones
+
+	// Storage capacity monitoring configuration
+	StorageWarningPercent   int // disk usage percentage that raises a warning alert
+	StorageCriticalPercent  int // disk usage percentage that raises a critical alert
+	StorageExhaustionDays   int // alert when the disk is projected to fill within this many days
+	StorageGrowthWindowDays int // days of blob growth used to project exhaustion
+	StorageMonitorInterval  int // in minutes; 0 disables the background capacity check
+	StorageRollupInterval   int // in minutes between refreshes of today's storage rollup; 0 disables them
+
+	// Blob replication configuration
+	EnableReplication      bool   // asynchronously copy blobs to the replica
+	ReplicaStoragePath     string // secondary storage location mirroring StoragePath
+	ReplicationInterval    int    // in seconds between replication passes
+	ReplicationBatchSize   int    // blobs copied per pass
+	ReplicationMaxAttempts int    // failed blobs are retried until this many attempts
+
+	// Cold storage tiering configuration
+	EnableArchiving    bool   // move blobs that have not been accessed recently to cold storage
+	ArchiveStoragePath string // cold storage location for archived blobs
+	ArchiveAfterDays   int    // blobs not accessed for this many days are archived
+	ArchiveInterval    int    // in hours between lifecycle passes
+
+	// Sensitive content (DLP) scanning configuration
+	EnableDLP          bool     // inspect text-extractable uploads for sensitive data
+	DLPAction          string   // warn, quarantine or block when findings are reported
+	DLPRules           []string // built-in pattern rules to run (ssn, credit_card)
+	DLPMaxScanBytes    int64    // bytes read from the start of each file for inspection
+	DLPExternalURL     string   // optional external DLP API receiving file content
+	DLPExternalToken   string   // bearer token sent to the external DLP API
+	DLPExternalTimeout int      // in seconds per external DLP request
+	DLPFailClosed      bool     // reject uploads when a scanner errors instead of accepting them
+
+	// Malware scanning configuration
+	EnableMalwareScan      bool   // scan uploads and stored blobs for malware
+	MalwareScanner         string // clamd or external
+	ClamdAddress           string // tcp://host:port or unix:///path/to/clamd.sock
+	MalwareExternalURL     string // external malware scanning API receiving file content
+	MalwareExternalToken   string // bearer token sent to the external malware API
+	MalwareScanTimeout     int    // in seconds per file
+	MalwareFailClosed      bool   // reject uploads when the scanner errors instead of scanning them later
+	MalwareScanInterval    int    // in seconds between scans of stored blobs
+	MalwareRescanHours     int    // rescan stored blobs this often to apply new signatures; 0 scans each blob once
+	MalwareStrictDownloads bool   // refuse to serve files until they are scanned clean
+
+	// Content indexing and OCR configuration
+	EnableContentIndex   bool     // extract searchable text from uploads in the background
+	ContentIndexInterval int      // in seconds between indexing passes
+	ContentIndexMaxChars int      // characters of extracted text kept per blob
+	OCREngine            string   // tesseract, external or none
+	TesseractPath        string   // tesseract binary used for OCR
+	PdftoppmPath         string   // pdftoppm binary used to render PDF pages for OCR and view-only share links
+	OCRLanguages         []string // tesseract language packs, e.g. eng,deu
+	OCRMaxPDFPages       int      // pages of each PDF sent to OCR
+	OCRTimeout           int      // in seconds per file
+	OCRExternalURL       string   // external OCR API receiving file content
+	OCRExternalToken     string   // bearer token sent to the external OCR API
+
+	// Automatic classification configuration
+	EnableAutoTagging bool // tag files by type, name and content in the background
+	AutoTagInterval   int  // in seconds between classification passes
+
+	// Audit configuration
+	AuditVerboseAccess        bool // also audit views, metadata reads, listings and searches
+	AuditAccessSamplePercent  int  // percent of reads recorded in verbose mode; 100 records every read
+	AuditOutboxInterval       int  // in seconds between moves of committed audit entries into the audit log
+	AdminAccessVisibleToOwner bool // let users see when an admin opened or downloaded their files
+
+	// Write-once (WORM) folder configuration
+	WORMDefaultRetentionDays int // retention applied when a WORM folder is created without one
+
+	// End-to-end encrypted folder configuration
+	EnableEncryptedFolders bool   // let users create folders whose files are encrypted by their clients
+	VaultEscrowPublicKey   string // public key every encrypted folder's key must also be wrapped for; empty disables escrow
+
+	// Concurrency control configuration
+	RequireIfMatch bool // reject metadata updates sent without an If-Match header
+
+	// Outgoing email configuration; email is disabled while SMTPHost is empty
+	SMTPHost          string
+	SMTPPort          int
+	SMTPUsername      string
+	SMTPPassword      string
+	SMTPFrom          string // sender address of notification emails
+	EmailTemplatesDir string // directory of replacements for the built-in email templates
+
+	// IntegrationWebhookHosts are the hosts Slack and Teams integrations may
+	// post to; "*.example.com" matches any subdomain of example.com
+	IntegrationWebhookHosts []string
+
+	// Download notification configuration
+	CountryHeader string // request header in which a CDN or proxy reports the client's country code
+
+	// Guest account configuration
+	GuestAccountDays         int // default lifetime of a guest account created when sharing
+	GuestAccountMaxDays      int // longest lifetime an owner may give a guest account
+	GuestExpiryCheckInterval int // in minutes between passes disabling expired guest accounts
+
+	// Cleanup rule configuration
+	CleanupRuleInterval int // in minutes between passes applying users' cleanup rules
+
+	// Multi-tenancy configuration
+	EnableMultiTenancy bool   // host several organizations, each resolved per request
+	TenantBaseDomain   string // requests to <slug>.<domain> belong to that tenant; empty disables subdomains
+	TenantHeader       string // request header naming the tenant slug, checked before the host
+
+	// PublicBaseURL is the scheme and host clients reach the server on, such as
+	// https://vault.example.com. Links in API responses and emails are built
+	// on it; empty keeps them relative
+	PublicBaseURL string
+
+	// Share link domains, served behind a reverse proxy that terminates TLS
+	ShareDomain     string   // host of share links made by platform users; empty keeps links relative
+	ShareDomains    []string // further hosts that tenants may serve their share links on
+	ShareLinkScheme string   // scheme of share link URLs
+
+	// Password-protected share links
+	ShareUnlockTTL          int  // in minutes the access token from unlocking a link stays valid
+	AllowSharePasswordQuery bool // still accept the deprecated ?password= on share links
+
+	// Share link previews
+	WatermarkMaxFileSize int64 // largest file in bytes stamped with a watermark; larger ones are refused on watermarked links
+	SharePreviewSize     int   // longest side in pixels of pages rendered for view-only links
+	SharePreviewTimeout  int   // in seconds per rendered page
+
+	// Gallery thumbnails
+	ThumbnailSize   int // longest side in pixels of gallery thumbnails
+	ThumbnailURLTTL int // in minutes signed thumbnail URLs stay valid
+
+	// Privacy configuration
+	AnonymizeIPs               bool // store client IP addresses truncated to the prefixes below
+	AnonymizeIPv4Prefix        int  // leading bits of IPv4 addresses kept when anonymizing
+	AnonymizeIPv6Prefix        int  // leading bits of IPv6 addresses kept when anonymizing
+	AccessLogRetentionDays     int  // days download and share link access logs keep IP addresses and user agents; 0 keeps them
+	AccessLogRetentionInterval int  // in hours between access log retention passes
+
+	// AnonymousAccessTracking is what is kept of visits to share links and
+	// public files: "full" logs each visit with its IP address and user
+	// agent, "minimal" logs each visit without them, "counts" only counts
+	// visits per file or folder and day
+	AnonymousAccessTracking string
+
+	// SAML single sign-on configuration
+	EnableSAML             bool     // act as a SAML service provider
+	SAMLRootURL            string   // public base URL of this server, used to build the SP endpoints
+	SAMLEntityID           string   // SP entity ID; defaults to the metadata URL
+	SAMLIDPMetadataURL     string   // where to fetch the identity provider's metadata
+	SAMLIDPMetadataFile    string   // local copy of the identity provider's metadata, used instead of the URL
+	SAMLCertFile           string   // SP certificate advertised in the metadata
+	SAMLKeyFile            string   // SP private key signing requests and decrypting assertions
+	SAMLAllowIDPInitiated  bool     // accept assertions that do not answer a request from this server
+	SAMLUsernameAttribute  string   // assertion attribute holding the username
+	SAMLEmailAttribute     string   // assertion attribute holding the email; the NameID is used when absent
+	SAMLFirstNameAttribute string   // assertion attribute holding the first name
+	SAMLLastNameAttribute  string   // assertion attribute holding the last name
+	SAMLRoleAttribute      string   // assertion attribute holding roles or groups; empty leaves roles alone
+	SAMLAdminValues        []string // values of the role attribute that make a user an admin
+	SAMLJITProvisioning    bool     // create users on their first SSO login
+	SAMLEnforce            bool     // disable password login and registration for regular users
+	SAMLAllowAdminPassword bool     // let admins keep password login while SSO is enforced
+	SAMLLoginRedirectURL   string   // frontend page receiving the token after SSO login
+
+	// Backup configuration
+	BackupPath    string // secondary location receiving database dumps and blob copies
+	PgDumpPath    string // pg_dump binary used to snapshot the database
+	PgRestorePath string // pg_restore binary used by the restore command
+
 	// Storage quota configuration
 	DefaultUserQuota int64 // default quota for new users in bytes
 	MaxFileSize      int64 // maximum individual file size in bytes
 	AdminQuota       int64 // default quota for admin users in bytes
 	EnableQuotaCheck bool  // enable/disable quota enforcement
 
+	// Storage quota grace overage
+	QuotaGracePercent       int // percent over quota tolerated temporarily; 0 disables grace
+	QuotaGraceHours         int // hours an over-quota user may stay in grace before it is revoked
+	QuotaGraceCheckInterval int // in minutes between grace enforcement passes
+
+	// Inactive account lifecycle
+	InactiveWarnDays        int // days without login before the user is warned; 0 disables the policy
+	InactiveReadOnlyDays    int // days without login before the account becomes read-only; 0 skips this stage
+	InactiveArchiveDays     int // days without login before the account is archived; 0 skips this stage
+	InactivityCheckInterval int // in hours between inactivity passes
+
 	// CORS configuration
 	AllowedOrigins []string
 	AllowedMethods []string
 	AllowedHeaders []string
 
 	// File serving
-	MaxDownloadSize int64 // in bytes
-	DownloadTimeout int   // in seconds
+	MaxDownloadSize           int64 // in bytes
+	DownloadTimeout           int   // in seconds
+	DownloadManifestThreshold int64 // files at least this many bytes get a segmented download manifest
+	DownloadManifestChunkSize int64 // bytes per download manifest segment
+	ZipCompressionWorkers     int   // entries compressed in parallel for ZIP downloads; 0 uses every CPU
+	ZipMaxEntries             int   // entries allowed in one ZIP download or extracted upload
+	BlobReadConcurrency       int   // concurrent disk reads of one blob; 0 disables the limit
+	BlobReadWaitTimeout       int   // in seconds a read waits for a free slot before the server answers busy
+	HotBlobCacheSize          int64 // bytes of small blobs kept in memory; 0 disables the cache
+	HotBlobMaxSize            int64 // largest blob, in bytes, kept in the hot blob cache
+
+	// Content pre-warm configuration
+	EnablePrewarm  bool   // warm caches and the CDN origin when a file is made public or a share link is created
+	CDNOriginURL   string // CDN origin receiving blobs by PUT to {url}/{hash}; empty skips the push
+	CDNOriginToken string // bearer token sent to the CDN origin
+	CDNPushTimeout int    // in seconds per blob pushed to the CDN origin
+
+	// Diagnostics
+	EnableDebugEndpoints bool // mount pprof and expvar under /debug for admins
+	DebugMode            bool // log the cause of every failed request and run Gin in debug mode
 }
 
 // Load loads configuration from environment variables with defaults
 func Load() *Config {
-	return &Config{
+	cfg := &Config{
 		// Server configuration
 		Environment:  getEnv("ENVIRONMENT", "development"),
 		Port:         getEnv("PORT", "8080"),
@@ -66,6 +313,8 @@ func Load() *Config {
 		IdleTimeout:  getEnvAsInt("IDLE_TIMEOUT", 120),
 
 		// Database configuration
+		DatabaseDriver:   getEnv("DB_DRIVER", "postgres"),
+		SQLitePath:       getEnv("SQLITE_PATH", "./data/filevault.db"),
 		DatabaseURL:      getEnv("DATABASE_URL", ""),
 		DatabaseHost:     getEnv("DB_HOST", "localhost"),
 		DatabasePort:     getEnv("DB_PORT", "5432"),
@@ -74,19 +323,34 @@ func Load() *Config {
 		DatabaseName:     getEnv("DB_NAME", "filevault"),
 		DatabaseSSLMode:  getEnv("DB_SSL_MODE", "disable"),
 
+		// Database connection pool
+		DBMaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvAsInt("DB_CONN_MAX_LIFETIME", 30),
+		DBConnMaxIdleTime: getEnvAsInt("DB_CONN_MAX_IDLE_TIME", 5),
+
+		// Database timeouts
+		DBStatementTimeout:  getEnvAsInt("DB_STATEMENT_TIMEOUT", 60),
+		DBTimeoutRetryAfter: getEnvAsInt("DB_TIMEOUT_RETRY_AFTER", 5),
+
 		// JWT configuration
-		JWTSecret:     getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
-		JWTExpiration: getEnvAsInt("JWT_EXPIRATION", 24), // 24 hours
+		JWTSecret:              getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
+		JWTExpiration:          getEnvAsInt("JWT_EXPIRATION", 24), // 24 hours
+		JWTKeyRotationDays:     getEnvAsInt("JWT_KEY_ROTATION_DAYS", 0),
+		JWTKeyGraceHours:       getEnvAsInt("JWT_KEY_GRACE_HOURS", -1),
+		JWTKeyRotationInterval: getEnvAsInt("JWT_KEY_ROTATION_INTERVAL", 1),
 
 		// Rate limiting configuration
-		RateLimit:       getEnvAsInt("RATE_LIMIT", 2),            // 2 requests per second
-		RateLimitWindow: getEnvAsInt("RATE_LIMIT_WINDOW", 1),     // 1 second window
-		RateLimitBurst:  getEnvAsInt("RATE_LIMIT_BURST", 5),      // burst of 5
-		EnableRateLimit: getEnvAsBool("ENABLE_RATE_LIMIT", true), // enabled by default
-		RateLimitMode:   getEnv("RATE_LIMIT_MODE", "memory"),     // "memory" or "database"
+		RateLimit:              getEnvAsInt("RATE_LIMIT", 2),                 // 2 requests per second
+		RateLimitWindow:        getEnvAsInt("RATE_LIMIT_WINDOW", 1),          // 1 second window
+		RateLimitBurst:         getEnvAsInt("RATE_LIMIT_BURST", 5),           // burst of 5
+		EnableRateLimit:        getEnvAsBool("ENABLE_RATE_LIMIT", true),      // enabled by default
+		RateLimitMode:          getEnv("RATE_LIMIT_MODE", "memory"),          // "memory" or "database"
+		RateLimitPurgeInterval: getEnvAsInt("RATE_LIMIT_PURGE_INTERVAL", 60), // hourly
 
 		// Storage configuration
-		StoragePath: getEnv("STORAGE_PATH", "./uploads"),
+		StoragePath:    getEnv("STORAGE_PATH", "./uploads"),
+		StorageBackend: getEnv("STORAGE_BACKEND", getEnv("STORAGE_DRIVER", "disk")),
 		AllowedMimeTypes: getEnvAsSlice("ALLOWED_MIME_TYPES", []string{
 			"image/jpeg", "image/png", "image/gif", "image/webp",
 			"application/pdf", "text/plain", "text/csv",
@@ -103,12 +367,222 @@ func Load() *Config {
 			"application/vnd.openxmlformats-officedocument.presentationml.presentation",
 		}),
 
+		// S3-compatible object storage
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3Prefix:          getEnv("S3_PREFIX", ""),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3ForcePathStyle:  getEnvAsBool("S3_FORCE_PATH_STYLE", false),
+		S3PublicEndpoint:  getEnv("S3_PUBLIC_ENDPOINT", ""),
+
+		// MIME sniffing configuration
+		MimeSniffBytes: getEnvAsInt("MIME_SNIFF_BYTES", 8192),
+		MimeDeepInspectTypes: getEnvAsSlice("MIME_DEEP_INSPECT_TYPES", []string{
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+			"image/svg+xml", "application/json",
+		}),
+
+		// Blob layout configuration
+		MigrateBlobLayout:   getEnvAsBool("MIGRATE_BLOB_LAYOUT", true),
+		BlobLayoutBatchSize: getEnvAsInt("BLOB_LAYOUT_BATCH_SIZE", 500),
+
+		// Orphan blob sweep configuration
+		OrphanBlobSweepInterval: getEnvAsInt("ORPHAN_BLOB_SWEEP_INTERVAL", 60), // hourly
+
+		// Upload staging configuration
+		MultipartMemoryLimit: getEnvAsInt64("MULTIPART_MEMORY_LIMIT", 32<<20), // 32MB
+		UploadTempDir:        getEnv("UPLOAD_TEMP_DIR", ""),
+		UploadTempMaxAge:     getEnvAsInt("UPLOAD_TEMP_MAX_AGE", 24),         // 24 hours
+		UploadChunkSize:      getEnvAsInt64("UPLOAD_CHUNK_SIZE", 8<<20),      // 8MB
+		UploadChunkMaxSize:   getEnvAsInt64("UPLOAD_CHUNK_MAX_SIZE", 64<<20), // 64MB
+		UploadSessionTTL:     getEnvAsInt("UPLOAD_SESSION_TTL", 24),          // 24 hours
+
+		// Remote URL import configuration
+		URLImportTimeout:      getEnvAsInt("URL_IMPORT_TIMEOUT", 600), // 10 minutes
+		URLImportMaxActive:    getEnvAsInt("URL_IMPORT_MAX_ACTIVE", 3),
+		URLImportAllowPrivate: getEnvAsBool("URL_IMPORT_ALLOW_PRIVATE", false),
+
+		// Storage capacity monitoring configuration
+		StorageWarningPercent:   getEnvAsInt("STORAGE_WARNING_PERCENT", 80),
+		StorageCriticalPercent:  getEnvAsInt("STORAGE_CRITICAL_PERCENT", 90),
+		StorageExhaustionDays:   getEnvAsInt("STORAGE_EXHAUSTION_DAYS", 30),
+		StorageGrowthWindowDays: getEnvAsInt("STORAGE_GROWTH_WINDOW_DAYS", 30),
+		StorageMonitorInterval:  getEnvAsInt("STORAGE_MONITOR_INTERVAL", 60), // hourly
+		StorageRollupInterval:   getEnvAsInt("STORAGE_ROLLUP_INTERVAL", 60),  // hourly
+
+		// Blob replication configuration
+		EnableReplication:      getEnvAsBool("ENABLE_REPLICATION", false),
+		ReplicaStoragePath:     getEnv("REPLICA_STORAGE_PATH", ""),
+		ReplicationInterval:    getEnvAsInt("REPLICATION_INTERVAL", 60),
+		ReplicationBatchSize:   getEnvAsInt("REPLICATION_BATCH_SIZE", 100),
+		ReplicationMaxAttempts: getEnvAsInt("REPLICATION_MAX_ATTEMPTS", 5),
+
+		// Cold storage tiering configuration
+		EnableArchiving:    getEnvAsBool("ENABLE_ARCHIVING", false),
+		ArchiveStoragePath: getEnv("ARCHIVE_STORAGE_PATH", ""),
+		ArchiveAfterDays:   getEnvAsInt("ARCHIVE_AFTER_DAYS", 90),
+		ArchiveInterval:    getEnvAsInt("ARCHIVE_INTERVAL", 24), // daily
+
+		// Sensitive content (DLP) scanning configuration
+		EnableDLP:          getEnvAsBool("ENABLE_DLP", false),
+		DLPAction:          getEnv("DLP_ACTION", "warn"),
+		DLPRules:           getEnvAsSlice("DLP_RULES", []string{"ssn", "credit_card"}),
+		DLPMaxScanBytes:    getEnvAsInt64("DLP_MAX_SCAN_BYTES", 10485760), // 10MB
+		DLPExternalURL:     getEnv("DLP_EXTERNAL_URL", ""),
+		DLPExternalToken:   getEnv("DLP_EXTERNAL_TOKEN", ""),
+		DLPExternalTimeout: getEnvAsInt("DLP_EXTERNAL_TIMEOUT", 10),
+		DLPFailClosed:      getEnvAsBool("DLP_FAIL_CLOSED", false),
+
+		// Malware scanning configuration
+		EnableMalwareScan:      getEnvAsBool("ENABLE_MALWARE_SCAN", false),
+		MalwareScanner:         getEnv("MALWARE_SCANNER", "clamd"),
+		ClamdAddress:           getEnv("CLAMD_ADDRESS", "tcp://localhost:3310"),
+		MalwareExternalURL:     getEnv("MALWARE_EXTERNAL_URL", ""),
+		MalwareExternalToken:   getEnv("MALWARE_EXTERNAL_TOKEN", ""),
+		MalwareScanTimeout:     getEnvAsInt("MALWARE_SCAN_TIMEOUT", 60),
+		MalwareFailClosed:      getEnvAsBool("MALWARE_FAIL_CLOSED", false),
+		MalwareScanInterval:    getEnvAsInt("MALWARE_SCAN_INTERVAL", 300),
+		MalwareRescanHours:     getEnvAsInt("MALWARE_RESCAN_HOURS", 0),
+		MalwareStrictDownloads: getEnvAsBool("MALWARE_STRICT_DOWNLOADS", false),
+
+		// Content indexing and OCR configuration
+		EnableContentIndex:   getEnvAsBool("ENABLE_CONTENT_INDEX", false),
+		ContentIndexInterval: getEnvAsInt("CONTENT_INDEX_INTERVAL", 30),
+		ContentIndexMaxChars: getEnvAsInt("CONTENT_INDEX_MAX_CHARS", 1000000),
+		OCREngine:            getEnv("OCR_ENGINE", "tesseract"),
+		TesseractPath:        getEnv("TESSERACT_PATH", "tesseract"),
+		PdftoppmPath:         getEnv("PDFTOPPM_PATH", "pdftoppm"),
+		OCRLanguages:         getEnvAsSlice("OCR_LANGUAGES", []string{"eng"}),
+		OCRMaxPDFPages:       getEnvAsInt("OCR_MAX_PDF_PAGES", 20),
+		OCRTimeout:           getEnvAsInt("OCR_TIMEOUT", 120),
+		OCRExternalURL:       getEnv("OCR_EXTERNAL_URL", ""),
+		OCRExternalToken:     getEnv("OCR_EXTERNAL_TOKEN", ""),
+
+		// Automatic classification configuration
+		EnableAutoTagging: getEnvAsBool("ENABLE_AUTO_TAGGING", true),
+		AutoTagInterval:   getEnvAsInt("AUTO_TAG_INTERVAL", 60),
+
+		// Audit configuration
+		AuditVerboseAccess:        getEnvAsBool("AUDIT_VERBOSE_ACCESS", false),
+		AuditAccessSamplePercent:  getEnvAsInt("AUDIT_ACCESS_SAMPLE_PERCENT", 100),
+		AuditOutboxInterval:       getEnvAsInt("AUDIT_OUTBOX_INTERVAL", 5),
+		AdminAccessVisibleToOwner: getEnvAsBool("ADMIN_ACCESS_VISIBLE_TO_OWNER", true),
+
+		// Write-once (WORM) folder configuration
+		WORMDefaultRetentionDays: getEnvAsInt("WORM_DEFAULT_RETENTION_DAYS", 365),
+
+		// End-to-end encrypted folder configuration
+		EnableEncryptedFolders: getEnvAsBool("ENABLE_ENCRYPTED_FOLDERS", true),
+		VaultEscrowPublicKey:   strings.TrimSpace(getEnv("VAULT_ESCROW_PUBLIC_KEY", "")),
+
+		// Concurrency control configuration
+		RequireIfMatch: getEnvAsBool("REQUIRE_IF_MATCH", false),
+
+		// Outgoing email configuration
+		SMTPHost:          getEnv("SMTP_HOST", ""),
+		SMTPPort:          getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:          getEnv("SMTP_FROM", "noreply@filevault.local"),
+		EmailTemplatesDir: getEnv("EMAIL_TEMPLATES_DIR", ""),
+
+		IntegrationWebhookHosts: getEnvAsSlice("INTEGRATION_WEBHOOK_HOSTS", []string{
+			"hooks.slack.com",
+			"*.webhook.office.com",
+			"*.logic.azure.com",
+			"*.api.powerplatform.com",
+		}),
+
+		// Download notification configuration
+		CountryHeader: getEnv("COUNTRY_HEADER", "CF-IPCountry"),
+
+		// Guest account configuration
+		GuestAccountDays:         getEnvAsInt("GUEST_ACCOUNT_DAYS", 30),
+		GuestAccountMaxDays:      getEnvAsInt("GUEST_ACCOUNT_MAX_DAYS", 90),
+		GuestExpiryCheckInterval: getEnvAsInt("GUEST_EXPIRY_CHECK_INTERVAL", 60),
+
+		// Cleanup rule configuration
+		CleanupRuleInterval: getEnvAsInt("CLEANUP_RULE_INTERVAL", 60), // hourly
+
+		// Multi-tenancy configuration
+		EnableMultiTenancy: getEnvAsBool("ENABLE_MULTI_TENANCY", false),
+		TenantBaseDomain:   getEnv("TENANT_BASE_DOMAIN", ""),
+		TenantHeader:       getEnv("TENANT_HEADER", "X-Tenant"),
+
+		PublicBaseURL: getEnv("PUBLIC_BASE_URL", ""),
+
+		// Share link domains
+		ShareDomain:     getEnv("SHARE_DOMAIN", ""),
+		ShareDomains:    getEnvAsSlice("SHARE_DOMAINS", nil),
+		ShareLinkScheme: getEnv("SHARE_LINK_SCHEME", "https"),
+
+		// Password-protected share links
+		ShareUnlockTTL:          getEnvAsInt("SHARE_UNLOCK_TTL", 15),
+		AllowSharePasswordQuery: getEnvAsBool("ALLOW_SHARE_PASSWORD_QUERY", true),
+
+		// Share link previews
+		WatermarkMaxFileSize: getEnvAsInt64("WATERMARK_MAX_FILE_SIZE", 52428800), // 50MB
+		SharePreviewSize:     getEnvAsInt("SHARE_PREVIEW_SIZE", 1600),
+		SharePreviewTimeout:  getEnvAsInt("SHARE_PREVIEW_TIMEOUT", 30),
+
+		// Gallery thumbnails
+		ThumbnailSize:   getEnvAsInt("THUMBNAIL_SIZE", 256),
+		ThumbnailURLTTL: getEnvAsInt("THUMBNAIL_URL_TTL", 60), // 1 hour
+
+		// Privacy configuration
+		AnonymizeIPs:               getEnvAsBool("ANONYMIZE_IPS", false),
+		AnonymizeIPv4Prefix:        getEnvAsInt("ANONYMIZE_IPV4_PREFIX", 24),
+		AnonymizeIPv6Prefix:        getEnvAsInt("ANONYMIZE_IPV6_PREFIX", 48),
+		AccessLogRetentionDays:     getEnvAsInt("ACCESS_LOG_RETENTION_DAYS", 0), // kept by default
+		AccessLogRetentionInterval: getEnvAsInt("ACCESS_LOG_RETENTION_INTERVAL", 6),
+		AnonymousAccessTracking:    strings.ToLower(getEnv("ANONYMOUS_ACCESS_TRACKING", "full")),
+
+		// SAML single sign-on configuration
+		EnableSAML:             getEnvAsBool("ENABLE_SAML", false),
+		SAMLRootURL:            getEnv("SAML_ROOT_URL", ""),
+		SAMLEntityID:           getEnv("SAML_ENTITY_ID", ""),
+		SAMLIDPMetadataURL:     getEnv("SAML_IDP_METADATA_URL", ""),
+		SAMLIDPMetadataFile:    getEnv("SAML_IDP_METADATA_FILE", ""),
+		SAMLCertFile:           getEnv("SAML_CERT_FILE", ""),
+		SAMLKeyFile:            getEnv("SAML_KEY_FILE", ""),
+		SAMLAllowIDPInitiated:  getEnvAsBool("SAML_ALLOW_IDP_INITIATED", false),
+		SAMLUsernameAttribute:  getEnv("SAML_USERNAME_ATTRIBUTE", "uid"),
+		SAMLEmailAttribute:     getEnv("SAML_EMAIL_ATTRIBUTE", "email"),
+		SAMLFirstNameAttribute: getEnv("SAML_FIRST_NAME_ATTRIBUTE", "givenName"),
+		SAMLLastNameAttribute:  getEnv("SAML_LAST_NAME_ATTRIBUTE", "sn"),
+		SAMLRoleAttribute:      getEnv("SAML_ROLE_ATTRIBUTE", ""),
+		SAMLAdminValues:        getEnvAsSlice("SAML_ADMIN_VALUES", []string{"admin"}),
+		SAMLJITProvisioning:    getEnvAsBool("SAML_JIT_PROVISIONING", true),
+		SAMLEnforce:            getEnvAsBool("SAML_ENFORCE", false),
+		SAMLAllowAdminPassword: getEnvAsBool("SAML_ALLOW_ADMIN_PASSWORD", true),
+		SAMLLoginRedirectURL:   getEnv("SAML_LOGIN_REDIRECT_URL", ""),
+
+		// Backup configuration
+		BackupPath:    getEnv("BACKUP_PATH", "./backups"),
+		PgDumpPath:    getEnv("PG_DUMP_PATH", "pg_dump"),
+		PgRestorePath: getEnv("PG_RESTORE_PATH", "pg_restore"),
+
 		// Storage quota configuration
 		DefaultUserQuota: getEnvAsInt64("DEFAULT_USER_QUOTA", 10485760), // 10MB default
 		MaxFileSize:      getEnvAsInt64("MAX_FILE_SIZE", 104857600),     // 100MB max file
 		AdminQuota:       getEnvAsInt64("ADMIN_QUOTA", 107374182400),    // 100GB for admins
 		EnableQuotaCheck: getEnvAsBool("ENABLE_QUOTA_CHECK", true),      // enabled by default
 
+		// Storage quota grace overage
+		QuotaGracePercent:       getEnvAsInt("QUOTA_GRACE_PERCENT", 0), // disabled by default
+		QuotaGraceHours:         getEnvAsInt("QUOTA_GRACE_HOURS", 48),
+		QuotaGraceCheckInterval: getEnvAsInt("QUOTA_GRACE_CHECK_INTERVAL", 60), // hourly
+
+		// Inactive account lifecycle
+		InactiveWarnDays:        getEnvAsInt("INACTIVE_WARN_DAYS", 0), // disabled by default
+		InactiveReadOnlyDays:    getEnvAsInt("INACTIVE_READ_ONLY_DAYS", 0),
+		InactiveArchiveDays:     getEnvAsInt("INACTIVE_ARCHIVE_DAYS", 0),
+		InactivityCheckInterval: getEnvAsInt("INACTIVITY_CHECK_INTERVAL", 24), // daily
+
 		// CORS configuration
 		AllowedOrigins: getEnvAsSlice("ALLOWED_ORIGINS", []string{"http://localhost:3000"}),
 		AllowedMethods: getEnvAsSlice("ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
@@ -117,9 +591,183 @@ func Load() *Config {
 		}),
 
 		// File serving
-		MaxDownloadSize: getEnvAsInt64("MAX_DOWNLOAD_SIZE", 1073741824), // 1GB
-		DownloadTimeout: getEnvAsInt("DOWNLOAD_TIMEOUT", 300),           // 5 minutes
+		MaxDownloadSize:           getEnvAsInt64("MAX_DOWNLOAD_SIZE", 1073741824),           // 1GB
+		DownloadTimeout:           getEnvAsInt("DOWNLOAD_TIMEOUT", 300),                     // 5 minutes
+		DownloadManifestThreshold: getEnvAsInt64("DOWNLOAD_MANIFEST_THRESHOLD", 1073741824), // 1GB
+		DownloadManifestChunkSize: getEnvAsInt64("DOWNLOAD_MANIFEST_CHUNK_SIZE", 67108864),  // 64MB
+		ZipCompressionWorkers:     getEnvAsInt("ZIP_COMPRESSION_WORKERS", 0),
+		ZipMaxEntries:             getEnvAsInt("ZIP_MAX_ENTRIES", 100000),
+		BlobReadConcurrency:       getEnvAsInt("BLOB_READ_CONCURRENCY", 8),
+		BlobReadWaitTimeout:       getEnvAsInt("BLOB_READ_WAIT_TIMEOUT", 10),
+		HotBlobCacheSize:          getEnvAsInt64("HOT_BLOB_CACHE_SIZE", 67108864), // 64MB
+		HotBlobMaxSize:            getEnvAsInt64("HOT_BLOB_MAX_SIZE", 1048576),    // 1MB
+
+		// Content pre-warm configuration
+		EnablePrewarm:  getEnvAsBool("ENABLE_PREWARM", false),
+		CDNOriginURL:   getEnv("CDN_ORIGIN_URL", ""),
+		CDNOriginToken: getEnv("CDN_ORIGIN_TOKEN", ""),
+		CDNPushTimeout: getEnvAsInt("CDN_PUSH_TIMEOUT", 300),
+
+		// Diagnostics
+		EnableDebugEndpoints: getEnvAsBool("ENABLE_DEBUG_ENDPOINTS", false),
+		DebugMode:            getEnvAsBool("DEBUG_MODE", false),
+	}
+
+	// Stage uploads next to the blob store by default so committing a staged
+	// file is a cheap rename rather than a cross-device copy
+	if cfg.UploadTempDir == "" {
+		cfg.UploadTempDir = filepath.Join(cfg.StoragePath, "tmp")
+	}
+
+	// Replication and archiving need somewhere to put blobs
+	if cfg.ReplicaStoragePath == "" {
+		cfg.EnableReplication = false
+	}
+	if cfg.ArchiveStoragePath == "" {
+		cfg.EnableArchiving = false
+	}
+
+	// Resumable uploads must make progress, in chunks clients are allowed
+	// to choose, and stay open long enough to resume
+	if cfg.UploadChunkMaxSize <= 0 {
+		cfg.UploadChunkMaxSize = 64 << 20
+	}
+	if cfg.UploadChunkSize <= 0 || cfg.UploadChunkSize > cfg.UploadChunkMaxSize {
+		cfg.UploadChunkSize = min(8<<20, cfg.UploadChunkMaxSize)
 	}
+	if cfg.UploadSessionTTL <= 0 {
+		cfg.UploadSessionTTL = 24
+	}
+	if cfg.URLImportTimeout <= 0 {
+		cfg.URLImportTimeout = 600
+	}
+
+	// Manifest segments must make progress
+	if cfg.DownloadManifestChunkSize <= 0 {
+		cfg.DownloadManifestChunkSize = 67108864
+	}
+
+	// An unlocked share link must stay open long enough to download from it
+	if cfg.ShareUnlockTTL <= 0 {
+		cfg.ShareUnlockTTL = 15
+	}
+
+	// Rendered pages must be legible and get time to render
+	if cfg.SharePreviewSize < 200 {
+		cfg.SharePreviewSize = 1600
+	}
+	if cfg.SharePreviewTimeout <= 0 {
+		cfg.SharePreviewTimeout = 30
+	}
+	if cfg.ThumbnailSize < 32 {
+		cfg.ThumbnailSize = 256
+	}
+	if cfg.ThumbnailURLTTL <= 0 {
+		cfg.ThumbnailURLTTL = 60
+	}
+
+	// Tokens signed with a replaced key keep working until they would have
+	// expired anyway, unless a grace period is configured
+	if cfg.JWTKeyGraceHours < 0 {
+		cfg.JWTKeyGraceHours = cfg.JWTExpiration
+	}
+
+	// Anonymized addresses keep at most the whole address and never less
+	// than nothing
+	cfg.AnonymizeIPv4Prefix = min(max(cfg.AnonymizeIPv4Prefix, 0), 32)
+	cfg.AnonymizeIPv6Prefix = min(max(cfg.AnonymizeIPv6Prefix, 0), 128)
+
+	// Unknown anonymous access tracking keeps the full logs
+	switch cfg.AnonymousAccessTracking {
+	case "full", "minimal", "counts":
+	default:
+		cfg.AnonymousAccessTracking = "full"
+	}
+
+	// Unknown DLP actions fall back to the least disruptive one
+	switch cfg.DLPAction {
+	case "warn", "quarantine", "block":
+	default:
+		cfg.DLPAction = "warn"
+	}
+
+	// Null storage loses every upload, so production always keeps blobs on
+	// disk. Without content there is nothing to replicate, archive, index,
+	// tag or re-lay out
+	switch backend := strings.ToLower(cfg.StorageBackend); {
+	case backend == "null" && !cfg.IsProduction():
+		cfg.StorageBackend = "null"
+		cfg.EnableReplication = false
+		cfg.EnableArchiving = false
+		cfg.EnableContentIndex = false
+		cfg.EnableAutoTagging = false
+		cfg.MigrateBlobLayout = false
+		cfg.OrphanBlobSweepInterval = 0
+	case backend == "s3":
+		// Replicas, cold storage and the blob layout are directories beside
+		// the primary store; a bucket gets those from the object store itself
+		cfg.StorageBackend = "s3"
+		cfg.EnableReplication = false
+		cfg.EnableArchiving = false
+		cfg.MigrateBlobLayout = false
+		if cfg.S3Endpoint == "" {
+			cfg.S3Endpoint = "https://s3." + cfg.S3Region + ".amazonaws.com"
+		}
+		if cfg.S3PublicEndpoint == "" {
+			cfg.S3PublicEndpoint = cfg.S3Endpoint
+		}
+	default:
+		cfg.StorageBackend = "disk"
+	}
+
+	// Browsers on other origins may only send the tenant header when CORS
+	// allows it
+	if cfg.EnableMultiTenancy && cfg.TenantHeader != "" {
+		allowed := false
+		for _, header := range cfg.AllowedHeaders {
+			if strings.EqualFold(header, cfg.TenantHeader) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			cfg.AllowedHeaders = append(cfg.AllowedHeaders, cfg.TenantHeader)
+		}
+	}
+	cfg.TenantBaseDomain = strings.ToLower(strings.Trim(cfg.TenantBaseDomain, "."))
+
+	// Links append their path to the base URL, and the SAML endpoints live on
+	// it unless configured apart
+	cfg.PublicBaseURL = strings.TrimRight(strings.TrimSpace(cfg.PublicBaseURL), "/")
+	if cfg.SAMLRootURL == "" {
+		cfg.SAMLRootURL = cfg.PublicBaseURL
+	}
+	if cfg.SAMLRootURL == "" {
+		cfg.SAMLRootURL = "http://localhost:8080"
+	}
+
+	// Share domains are compared as lowercase hosts, and the platform's own
+	// share domain is always one of them
+	cfg.ShareDomain = normalizeDomain(cfg.ShareDomain)
+	var shareDomains []string
+	for _, domain := range append([]string{cfg.ShareDomain}, cfg.ShareDomains...) {
+		if domain = normalizeDomain(domain); domain != "" && !containsString(shareDomains, domain) {
+			shareDomains = append(shareDomains, domain)
+		}
+	}
+	cfg.ShareDomains = shareDomains
+	if cfg.ShareLinkScheme != "http" {
+		cfg.ShareLinkScheme = "https"
+	}
+
+	// Anything but SQLite is served by PostgreSQL
+	switch strings.ToLower(cfg.DatabaseDriver) {
+	case "sqlite", "sqlite3":
+		cfg.DatabaseDriver = "sqlite"
+	default:
+		cfg.DatabaseDriver = "postgres"
+	}
+
+	return cfg
 }
 
 // GetDatabaseDSN returns the database connection string
@@ -136,6 +784,31 @@ func (c *Config) GetDatabaseDSN() string {
 		" sslmode=" + c.DatabaseSSLMode
 }
 
+// IsSQLite reports whether the server stores its data in a SQLite file
+// instead of PostgreSQL
+func (c *Config) IsSQLite() bool {
+	return c.DatabaseDriver == "sqlite"
+}
+
+// IsNullStorage reports whether uploaded content is hashed and then
+// discarded instead of stored, so load tests exercise the upload pipeline
+// without filling the disk
+func (c *Config) IsNullStorage() bool {
+	return c.StorageBackend == "null"
+}
+
+// IsObjectStorage reports whether blob content is kept in an S3-compatible
+// bucket instead of under StoragePath
+func (c *Config) IsObjectStorage() bool {
+	return c.StorageBackend == "s3"
+}
+
+// IsShareDomain reports whether host, which may carry a port, is one of the
+// domains serving share links
+func (c *Config) IsShareDomain(host string) bool {
+	return containsString(c.ShareDomains, normalizeDomain(host))
+}
+
 // IsProduction returns true if running in production environment
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
@@ -188,3 +861,21 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// normalizeDomain lowercases a host and drops its port and trailing dot
+func normalizeDomain(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// publicJWTSecrets are the JWT_SECRET default and the examples in the
+// repository. Anyone can sign tokens with them, so they must never reach
+// production
+var publicJWTSecrets = []string{
+	"your-super-secret-jwt-key-change-in-production",
+	"your-super-secret-jwt-key-for-development",
+	"your-very-strong-production-secret",
+}
+
+// Problem is a setting Validate found unsafe or unusable
+type Problem struct {
+	Setting string `json:"setting"` // environment variable at fault
+	Message string `json:"message"`
+	Fatal   bool   `json:"fatal"` // the server refuses to start with it
+}
+
+// Validate checks the configuration for settings that are unsafe or cannot
+// work. The server refuses to start while any problem is fatal and logs the
+// rest as warnings. Storage locations are created when missing and checked
+// for being writable.
+func (c *Config) Validate() []Problem {
+	var problems []Problem
+	add := func(setting, message string, fatal bool) {
+		problems = append(problems, Problem{Setting: setting, Message: message, Fatal: fatal})
+	}
+
+	switch {
+	case containsString(publicJWTSecrets, c.JWTSecret):
+		add("JWT_SECRET", "is a published example, so anyone can sign tokens", c.IsProduction())
+	case len(c.JWTSecret) < 32:
+		add("JWT_SECRET", "is shorter than 32 characters", false)
+	}
+	if c.JWTKeyGraceHours < c.JWTExpiration {
+		add("JWT_KEY_GRACE_HOURS", "is shorter than JWT_EXPIRATION, so rotating the signing key logs out users whose tokens have not expired yet", false)
+	}
+
+	if strings.TrimSpace(c.StoragePath) == "" {
+		add("STORAGE_PATH", "is empty", true)
+	} else if err := checkWritableDir(c.StoragePath); err != nil {
+		add("STORAGE_PATH", "is not a writable directory: "+err.Error(), true)
+	}
+	if err := checkWritableDir(c.UploadTempDir); err != nil {
+		add("UPLOAD_TEMP_DIR", "is not a writable directory: "+err.Error(), true)
+	}
+	if c.IsObjectStorage() {
+		if strings.TrimSpace(c.S3Bucket) == "" {
+			add("S3_BUCKET", "is empty, so blobs have nowhere to go", true)
+		}
+		if endpoint, err := url.Parse(c.S3Endpoint); err != nil || endpoint.Host == "" {
+			add("S3_ENDPOINT", "is not a URL", true)
+		} else if endpoint.Scheme != "https" && c.IsProduction() {
+			add("S3_ENDPOINT", "is not HTTPS, so blob content crosses the network unencrypted", false)
+		}
+		if c.S3AccessKeyID == "" || c.S3SecretAccessKey == "" {
+			add("S3_ACCESS_KEY_ID", "or S3_SECRET_ACCESS_KEY is empty, so requests to the bucket cannot be signed", true)
+		}
+	}
+
+	if !c.EnableRateLimit {
+		add("ENABLE_RATE_LIMIT", "is false, so logins and share link passwords can be guessed without limit", c.IsProduction())
+	}
+
+	if c.AllowSharePasswordQuery && c.IsProduction() {
+		add("ALLOW_SHARE_PASSWORD_QUERY", "is true, so share link passwords may be sent in URLs and end up in access logs", false)
+	}
+	if c.EnableDebugEndpoints && c.IsProduction() {
+		add("ENABLE_DEBUG_ENDPOINTS", "is true, so profiling endpoints are mounted", false)
+	}
+	if containsString(c.AllowedOrigins, "*") && c.IsProduction() {
+		add("ALLOWED_ORIGINS", "allows every origin", false)
+	}
+
+	return problems
+}
+
+// checkWritableDir creates dir when missing and checks a file can be written
+// in it
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".selfcheck-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// redacted replaces the value of secret settings in Effective
+const redacted = "[redacted]"
+
+// Effective returns the configuration in effect, keyed by field name, with
+// secrets redacted: passwords, secrets and tokens show only whether they are
+// set, and credentials in URLs are masked
+func (c *Config) Effective() map[string]interface{} {
+	effective := map[string]interface{}{}
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Name
+		field := value.Field(i).Interface()
+
+		if s, ok := field.(string); ok && s != "" {
+			switch {
+			case strings.HasSuffix(name, "Password"), strings.HasSuffix(name, "Secret"), strings.HasSuffix(name, "Token"):
+				field = redacted
+			case strings.HasSuffix(name, "URL"):
+				field = redactURL(s)
+			}
+		}
+		effective[name] = field
+	}
+	return effective
+}
+
+// redactURL masks the password in a URL. Values that are not a URL with a
+// scheme, such as key=value database DSNs, are redacted whole
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return redacted
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	return u.Redacted()
+}
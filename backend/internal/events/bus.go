@@ -0,0 +1,216 @@
+// Package events is an in-process bus for operational events such as uploads,
+// failed logins and storage errors. Publishers never block: a subscriber that
+// falls behind loses events rather than slowing down requests.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type identifies what happened
+type Type string
+
+const (
+	TypeUpload       Type = "upload"
+	TypeDownload     Type = "download"
+	TypeLoginFailed  Type = "login_failed"
+	TypeRateLimited  Type = "rate_limited"
+	TypeStorageError Type = "storage_error"
+)
+
+// Types lists every event type, used to validate filters
+var Types = []Type{TypeUpload, TypeDownload, TypeLoginFailed, TypeRateLimited, TypeStorageError}
+
+// Severity ranks events so operators can hide routine traffic during an incident
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Rank orders severities from least to most severe
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// recentCapacity is how many events are kept for clients that connect mid-incident
+const recentCapacity = 200
+
+// Event is a single operational event
+type Event struct {
+	ID        uuid.UUID              `json:"id"`
+	Type      Type                   `json:"type"`
+	Severity  Severity               `json:"severity"`
+	Timestamp time.Time              `json:"timestamp"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty"`
+	IPAddress string                 `json:"ip_address,omitempty"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Filter selects events for a subscriber. Empty fields match everything.
+type Filter struct {
+	Types       []Type
+	MinSeverity Severity
+	UserID      *uuid.UUID
+}
+
+// Match reports whether the event passes the filter
+func (f Filter) Match(e Event) bool {
+	if len(f.Types) > 0 {
+		matched := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if e.Severity.Rank() < f.MinSeverity.Rank() {
+		return false
+	}
+	if f.UserID != nil && (e.UserID == nil || *e.UserID != *f.UserID) {
+		return false
+	}
+	return true
+}
+
+// Subscription receives matching events on C until it is closed
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	filter  Filter
+	dropped atomic.Int64
+}
+
+// Dropped returns how many events were discarded because the subscriber fell behind
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Bus fans events out to subscribers and keeps the most recent ones
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	recent      []Event
+	next        int
+}
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+		recent:      make([]Event, 0, recentCapacity),
+	}
+}
+
+// Default is the process-wide bus used by Publish
+var Default = NewBus()
+
+// Publish sends an event on the default bus
+func Publish(e Event) {
+	Default.Publish(e)
+}
+
+// Publish records the event and delivers it to every matching subscriber
+func (b *Bus) Publish(e Event) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	if e.Severity == "" {
+		e.Severity = SeverityInfo
+	}
+
+	b.mu.Lock()
+	if len(b.recent) < recentCapacity {
+		b.recent = append(b.recent, e)
+	} else {
+		b.recent[b.next] = e
+	}
+	b.next = (b.next + 1) % recentCapacity
+	b.mu.Unlock()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe registers a subscriber with room for buffer pending events
+func (b *Bus) Subscribe(filter Filter, buffer int) *Subscription {
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe stops delivery to the subscriber and closes its channel
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Recent returns up to limit of the most recent matching events, oldest first
+func (b *Bus) Recent(filter Filter, limit int) []Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	ordered := make([]Event, 0, len(b.recent))
+	if len(b.recent) == recentCapacity {
+		ordered = append(ordered, b.recent[b.next:]...)
+		ordered = append(ordered, b.recent[:b.next]...)
+	} else {
+		ordered = append(ordered, b.recent...)
+	}
+
+	var matched []Event
+	for _, e := range ordered {
+		if filter.Match(e) {
+			matched = append(matched, e)
+		}
+	}
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched
+}
+
+// SubscriberCount returns how many subscribers are connected
+func (b *Bus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
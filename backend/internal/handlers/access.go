@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/services"
+)
+
+type AccessHandler struct {
+	accessService *services.AccessService
+}
+
+func NewAccessHandler(accessService *services.AccessService) *AccessHandler {
+	return &AccessHandler{
+		accessService: accessService,
+	}
+}
+
+// GetEffectiveAccess shows what a user can do with a file and why, tracing
+// ownership, direct shares, folder shares, public status and the checks that
+// block content, to debug files users expect to see but cannot (admin only)
+// GET /api/v1/admin/users/:id/effective-access?file_id=
+func (h *AccessHandler) GetEffectiveAccess(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	fileID, err := uuid.Parse(c.Query("file_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file_id query parameter must be a valid file ID"})
+		return
+	}
+
+	result, err := h.accessService.EffectiveFileAccess(c.Request.Context(), userID, fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAccessUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, services.ErrAccessFileNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate access"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondAccessError writes the response for a failed access check. Files
+// and folders the user cannot see are reported as not found.
+func respondAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAccessFileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	case errors.Is(err, services.ErrAccessFolderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+	case errors.Is(err, services.ErrAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Access denied",
+			"type":    "ACCESS_DENIED",
+			"message": "Your access to this resource does not allow this action",
+			"code":    "ACCESS_DENIED",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check access"})
+	}
+}
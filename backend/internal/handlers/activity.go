@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/services"
+)
+
+type ActivityHandler struct {
+	activityService *services.ActivityService
+}
+
+func NewActivityHandler(activityService *services.ActivityService) *ActivityHandler {
+	return &ActivityHandler{
+		activityService: activityService,
+	}
+}
+
+// GetMyActivity returns the user's activity timeline: their own actions,
+// downloads of their files by others, shares sent or received and changes
+// others made to their files, newest first.
+// Filter with ?types=download,share_received and ?since= / ?until= (RFC 3339).
+// GET /api/v1/me/activity
+func (h *ActivityHandler) GetMyActivity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	filter := services.ActivityFilter{
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	if typesParam := c.Query("types"); typesParam != "" {
+		for _, name := range strings.Split(typesParam, ",") {
+			activityType := services.ActivityType(strings.TrimSpace(name))
+			if !isActivityType(activityType) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid activity type: " + string(activityType)})
+				return
+			}
+			filter.Types = append(filter.Types, activityType)
+		}
+	}
+
+	var ok bool
+	if filter.Since, ok = timeQuery(c, "since"); !ok {
+		return
+	}
+	if filter.Until, ok = timeQuery(c, "until"); !ok {
+		return
+	}
+
+	result, err := h.activityService.GetTimeline(c.Request.Context(), userID.(uuid.UUID), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"activities": NewActivityDTOs(result.Items),
+		"total":      result.Total,
+		"has_more":   result.HasMore,
+		"page":       result.Page,
+		"limit":      result.Limit,
+	})
+}
+
+// isActivityType reports whether t is a known timeline entry type
+func isActivityType(t services.ActivityType) bool {
+	for _, known := range services.ActivityTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// timeQuery parses an optional RFC 3339 query parameter, writing a 400
+// response and returning false when it is malformed
+func timeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " timestamp, expected RFC 3339"})
+		return nil, false
+	}
+	return &parsed, true
+}
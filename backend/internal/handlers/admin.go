@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
 	"fmt"
 	"net/http"
-	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,19 +17,38 @@ import (
 	"file-vault-system/backend/internal/config"
 	"file-vault-system/backend/internal/models"
 	"file-vault-system/backend/internal/services"
+	"file-vault-system/backend/pkg/database"
 )
 
 type AdminHandler struct {
-	db           *gorm.DB
-	cfg          *config.Config
-	auditService *services.AuditService
+	db                   *gorm.DB
+	cfg                  *config.Config
+	auditService         *services.AuditService
+	storageHealthService *services.StorageHealthService
+	uploadPolicyService  *services.UploadPolicyService
+	dlpService           *services.DLPService
+	quarantineService    *services.QuarantineService
+	healthService        *services.HealthService
+	fileStreamService    *services.FileStreamService
+	prewarmService       *services.PrewarmService
+	linkService          *services.LinkService
+	accessService        *services.AccessService
 }
 
-func NewAdminHandler(db *gorm.DB, cfg *config.Config, auditService *services.AuditService) *AdminHandler {
+func NewAdminHandler(db *gorm.DB, cfg *config.Config, auditService *services.AuditService, storageHealthService *services.StorageHealthService, dlpService *services.DLPService, quarantineService *services.QuarantineService, healthService *services.HealthService) *AdminHandler {
 	return &AdminHandler{
-		db:           db,
-		cfg:          cfg,
-		auditService: auditService,
+		db:                   db,
+		cfg:                  cfg,
+		auditService:         auditService,
+		storageHealthService: storageHealthService,
+		uploadPolicyService:  services.NewUploadPolicyService(db),
+		dlpService:           dlpService,
+		quarantineService:    quarantineService,
+		healthService:        healthService,
+		fileStreamService:    services.NewFileStreamService(db, cfg),
+		prewarmService:       services.NewPrewarmService(db, cfg),
+		linkService:          services.NewLinkService(db, cfg),
+		accessService:        services.NewAccessService(db),
 	}
 }
 
@@ -49,41 +71,41 @@ func (h *AdminHandler) GetStats(c *gin.Context) {
 	var stats SystemStats
 
 	// Get total users - handle potential errors
-	if err := h.db.Model(&models.User{}).Count(&stats.TotalUsers).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Count(&stats.TotalUsers).Error; err != nil {
 		stats.TotalUsers = 0
 	}
 
 	// Get total files - handle potential errors
-	if err := h.db.Model(&models.File{}).Where("is_deleted = false").Count(&stats.TotalFiles).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.File{}).Count(&stats.TotalFiles).Error; err != nil {
 		stats.TotalFiles = 0
 	}
 
 	// Get total storage used - handle potential errors
 	var totalStorage int64
-	if err := h.db.Model(&models.User{}).Select("COALESCE(SUM(storage_used), 0)").Scan(&totalStorage).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Select("COALESCE(SUM(storage_used), 0)").Scan(&totalStorage).Error; err == nil {
 		stats.TotalStorage = totalStorage
 	} else {
 		stats.TotalStorage = 0
 	}
 
 	// Get active users (users who logged in within last 30 days) - handle potential errors
-	if err := h.db.Model(&models.User{}).Where("last_login > ?", time.Now().AddDate(0, 0, -30)).Count(&stats.ActiveUsers).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Where("last_login > ?", time.Now().AddDate(0, 0, -30)).Count(&stats.ActiveUsers).Error; err != nil {
 		stats.ActiveUsers = 0
 	}
 
 	// Get files uploaded today - handle potential errors
 	today := time.Now().Truncate(24 * time.Hour)
-	if err := h.db.Model(&models.File{}).Where("created_at >= ? AND is_deleted = false", today).Count(&stats.FilesUploadedToday).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.File{}).Where("created_at >= ?", today).Count(&stats.FilesUploadedToday).Error; err != nil {
 		stats.FilesUploadedToday = 0
 	}
 
 	// Get total folders - handle potential errors
-	if err := h.db.Model(&models.Folder{}).Count(&stats.TotalFolders).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.Folder{}).Count(&stats.TotalFolders).Error; err != nil {
 		stats.TotalFolders = 0
 	}
 
 	// Get total shared links - handle potential errors
-	if err := h.db.Model(&models.ShareLink{}).Count(&stats.TotalSharedLinks).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.ShareLink{}).Count(&stats.TotalSharedLinks).Error; err != nil {
 		stats.TotalSharedLinks = 0
 	}
 
@@ -91,17 +113,17 @@ func (h *AdminHandler) GetStats(c *gin.Context) {
 	var totalUploadedBytes, actualStorageBytes, savedBytes int64
 
 	// Sum all users' uploaded bytes
-	if err := h.db.Model(&models.User{}).Select("COALESCE(SUM(total_uploaded_bytes), 0)").Scan(&totalUploadedBytes).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Select("COALESCE(SUM(total_uploaded_bytes), 0)").Scan(&totalUploadedBytes).Error; err == nil {
 		stats.TotalUploadedBytes = totalUploadedBytes
 	}
 
 	// Sum all users' actual storage bytes
-	if err := h.db.Model(&models.User{}).Select("COALESCE(SUM(actual_storage_bytes), 0)").Scan(&actualStorageBytes).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Select("COALESCE(SUM(actual_storage_bytes), 0)").Scan(&actualStorageBytes).Error; err == nil {
 		stats.ActualStorageBytes = actualStorageBytes
 	}
 
 	// Sum all users' saved bytes
-	if err := h.db.Model(&models.User{}).Select("COALESCE(SUM(saved_bytes), 0)").Scan(&savedBytes).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Select("COALESCE(SUM(saved_bytes), 0)").Scan(&savedBytes).Error; err == nil {
 		stats.GlobalSavedBytes = savedBytes
 	}
 
@@ -113,46 +135,225 @@ func (h *AdminHandler) GetStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-// GetUsers returns a list of users (admin only)
+// userListColumns are the user fields returned by the admin user listing
+const userListColumns = "id, username, email, first_name, last_name, role, storage_quota, storage_used, plan_id, tenant_id, total_uploaded_bytes, actual_storage_bytes, saved_bytes, is_active, email_verified, last_login, quota_grace_expires_at, quota_grace_revoked_at, lifecycle_state, lifecycle_changed_at, auto_tagging_enabled, created_at"
+
+// userListSorts are the sorts of the admin user listing
+var userListSorts = sortSpec{
+	fields: map[string]string{
+		"username":      "username",
+		"email":         "email",
+		"role":          "role",
+		"storage_used":  "storage_used",
+		"storage_quota": "storage_quota",
+		"last_login":    "last_login",
+		"created_at":    "created_at",
+	},
+	defaultSort:  "created_at",
+	defaultOrder: "desc",
+	tieBreak:     "id",
+}
+
+// userListQuery builds the filtered and sorted user query shared by the
+// listing and its CSV export. It returns an error message for bad parameters.
+func (h *AdminHandler) userListQuery(c *gin.Context) (*gorm.DB, string) {
+	query := h.db.WithContext(c.Request.Context()).Model(&models.User{})
+
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		pattern := "%" + search + "%"
+		like := database.ILike(h.db)
+		query = query.Where("(username "+like+" ? OR email "+like+" ?)", pattern, pattern)
+	}
+
+	if role := c.Query("role"); role != "" {
+		switch models.UserRoleType(role) {
+		case models.RoleAdmin, models.RoleUser, models.RoleGuest, models.RoleTenantAdmin:
+		default:
+			return nil, "Invalid role filter"
+		}
+		query = query.Where("role = ?", role)
+	}
+
+	// "platform" selects users outside every tenant
+	if tenant := c.Query("tenant_id"); tenant != "" {
+		if tenant == "platform" {
+			query = query.Scopes(models.InTenant(nil))
+		} else {
+			tenantID, err := uuid.Parse(tenant)
+			if err != nil {
+				return nil, "Invalid tenant_id filter"
+			}
+			query = query.Scopes(models.InTenant(&tenantID))
+		}
+	}
+
+	if active := c.Query("is_active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			return nil, "Invalid is_active filter"
+		}
+		query = query.Where("is_active = ?", isActive)
+	}
+
+	if state := c.Query("lifecycle_state"); state != "" {
+		switch models.LifecycleState(state) {
+		case models.LifecycleActive, models.LifecycleWarned, models.LifecycleReadOnly, models.LifecycleArchived:
+		default:
+			return nil, "Invalid lifecycle_state filter"
+		}
+		query = query.Where("lifecycle_state = ?", state)
+	}
+
+	if overQuota := c.Query("over_quota"); overQuota != "" {
+		isOver, err := strconv.ParseBool(overQuota)
+		if err != nil {
+			return nil, "Invalid over_quota filter"
+		}
+		if isOver {
+			query = query.Where("storage_used > storage_quota")
+		} else {
+			query = query.Where("storage_used <= storage_quota")
+		}
+	}
+
+	sort, err := userListSorts.parse(c.Query("sort_by"), c.Query("sort_order"))
+	if err != nil {
+		return nil, sortErrorMessage(err)
+	}
+	return query.Order(sort.clause), ""
+}
+
+// GetUsers returns a paginated, searchable list of users (admin only)
+// GET /api/v1/admin/users
 func (h *AdminHandler) GetUsers(c *gin.Context) {
-	var users []models.User
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 
-	if err := h.db.Select("id, username, email, first_name, last_name, role, storage_quota, storage_used, total_uploaded_bytes, actual_storage_bytes, saved_bytes, is_active, email_verified, last_login, created_at").Find(&users).Error; err != nil {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	query, errMsg := h.userListQuery(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
+		return
+	}
+
+	var users []models.User
+	if err := query.Select(userListColumns).Limit(limit).Offset((page - 1) * limit).Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"users": users,
+		"users":      NewUserDTOs(users),
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + int64(limit) - 1) / int64(limit),
 	})
 }
 
+// ExportUsers streams the filtered user list as CSV (admin only)
+// GET /api/v1/admin/users/export
+func (h *AdminHandler) ExportUsers(c *gin.Context) {
+	query, errMsg := h.userListQuery(c)
+	if errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	rows, err := query.Select(userListColumns).Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users"})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("users-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{
+		"id", "username", "email", "first_name", "last_name", "role",
+		"storage_quota", "storage_used", "over_quota", "is_active",
+		"lifecycle_state", "email_verified", "last_login", "created_at",
+	})
+
+	for rows.Next() {
+		var user models.User
+		if err := h.db.WithContext(c.Request.Context()).ScanRows(rows, &user); err != nil {
+			fmt.Printf("Failed to scan user for export: %v\n", err)
+			break
+		}
+
+		lastLogin := ""
+		if user.LastLogin != nil {
+			lastLogin = user.LastLogin.Format(time.RFC3339)
+		}
+
+		writer.Write([]string{
+			user.ID.String(),
+			user.Username,
+			user.Email,
+			user.FirstName,
+			user.LastName,
+			string(user.Role),
+			strconv.FormatInt(user.StorageQuota, 10),
+			strconv.FormatInt(user.StorageUsed, 10),
+			strconv.FormatBool(user.StorageUsed > user.StorageQuota),
+			strconv.FormatBool(user.IsActive),
+			string(user.LifecycleState),
+			strconv.FormatBool(user.EmailVerified),
+			lastLogin,
+			user.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Printf("Failed to write user export: %v\n", err)
+	}
+}
+
 // GetAllFiles returns a list of all files in the system (admin only)
+// GET /api/v1/admin/files/all
 func (h *AdminHandler) GetAllFiles(c *gin.Context) {
 	var files []models.File
 
-	if err := h.db.Preload("Owner", func(db *gorm.DB) *gorm.DB {
+	if err := h.db.WithContext(c.Request.Context()).Preload("Owner", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, username, email, first_name, last_name")
-	}).Where("is_deleted = false").Find(&files).Error; err != nil {
+	}).Find(&files).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get files"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"files": files,
+		"files": NewFileDTOs(files),
 	})
 }
 
 // UpdateUserRole updates a user's role (admin only)
+// PUT /api/v1/admin/users/:id/role
 func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 	userID := c.Param("id")
 
 	var request struct {
-		Role string `json:"role" binding:"required,oneof=user admin"`
+		Role string `json:"role" binding:"required,oneof=user admin tenant_admin"`
 	}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &request) {
 		return
 	}
 
@@ -165,19 +366,39 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 
 	// Check if user exists and get current info
 	var user models.User
-	if err := h.db.First(&user, uid).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&user, uid).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Don't allow changing the system admin user's role
-	if user.Username == "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot modify the system admin user's role"})
+	// Platform admins stay outside tenants, and tenant admins need a tenant
+	// to manage
+	if request.Role == string(models.RoleAdmin) && user.TenantID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Members of a tenant cannot be platform admins; use tenant_admin"})
+		return
+	}
+	if request.Role == string(models.RoleTenantAdmin) && user.TenantID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only members of a tenant can be tenant admins"})
 		return
 	}
 
+	// Admins cannot demote themselves, and the last active admin must stay an admin
+	if user.Role == models.RoleAdmin && request.Role != string(models.RoleAdmin) {
+		if h.isCurrentUser(c, uid) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot remove your own admin role"})
+			return
+		}
+		if ok, err := h.hasOtherActiveAdmin(c.Request.Context(), uid); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check admin users"})
+			return
+		} else if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot demote the last active admin"})
+			return
+		}
+	}
+
 	// Update user role
-	if err := h.db.Model(&models.User{}).Where("id = ?", uid).Update("role", request.Role).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Where("id = ?", uid).Update("role", request.Role).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
 		return
 	}
@@ -187,7 +408,140 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 	})
 }
 
+// UpdateUser edits a user's profile, storage quota or active status (admin only)
+// PUT /api/v1/admin/users/:id
+func (h *AdminHandler) UpdateUser(c *gin.Context) {
+	uid, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var request struct {
+		FirstName    *string `json:"firstName" binding:"omitempty,max=100"`
+		LastName     *string `json:"lastName" binding:"omitempty,max=100"`
+		Email        *string `json:"email" binding:"omitempty,email,max=255"`
+		StorageQuota *int64  `json:"storageQuota" binding:"omitempty,min=0"`
+		IsActive     *bool   `json:"isActive"`
+	}
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).First(&user, uid).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if request.FirstName != nil {
+		updates["first_name"] = strings.TrimSpace(*request.FirstName)
+	}
+	if request.LastName != nil {
+		updates["last_name"] = strings.TrimSpace(*request.LastName)
+	}
+	if request.Email != nil && *request.Email != user.Email {
+		var existing int64
+		if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Where("email = ? AND id <> ?", *request.Email, uid).Count(&existing).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
+			return
+		}
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		updates["email"] = *request.Email
+	}
+	if request.StorageQuota != nil {
+		updates["storage_quota"] = *request.StorageQuota
+	}
+	if request.IsActive != nil && *request.IsActive != user.IsActive {
+		// Deactivating an admin must leave at least one other active admin
+		if !*request.IsActive && user.Role == models.RoleAdmin {
+			if h.isCurrentUser(c, uid) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Cannot deactivate your own account"})
+				return
+			}
+			if ok, err := h.hasOtherActiveAdmin(c.Request.Context(), uid); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check admin users"})
+				return
+			} else if !ok {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Cannot deactivate the last active admin"})
+				return
+			}
+		}
+		updates["is_active"] = *request.IsActive
+		// Reactivating ends any inactivity stage, including archival
+		if *request.IsActive && user.LifecycleState != models.LifecycleActive {
+			updates["lifecycle_state"] = models.LifecycleActive
+			updates["lifecycle_changed_at"] = time.Now()
+		}
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	// Record the change in the same transaction so it is never unaudited
+	details := models.AuditLogDetails{"timestamp": time.Now().Unix()}
+	for field, value := range updates {
+		details[field] = value
+	}
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		adminID, exists := c.Get("user_id")
+		if !exists {
+			return nil
+		}
+		return h.auditService.EnqueueFromGin(tx, c, services.LogActivityParams{
+			UserID:       adminID.(uuid.UUID),
+			Action:       models.AuditActionUpdate,
+			ResourceType: models.AuditResourceUser,
+			ResourceID:   &user.ID,
+			ResourceName: &user.Username,
+			Details:      details,
+			Status:       models.AuditStatusSuccess,
+		})
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	if err := h.db.WithContext(c.Request.Context()).Select(userListColumns).First(&user, uid).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User updated successfully",
+		"user":    NewUserDTO(&user),
+	})
+}
+
+// isCurrentUser reports whether the request was made by the given user
+func (h *AdminHandler) isCurrentUser(c *gin.Context, userID uuid.UUID) bool {
+	currentID, exists := c.Get("user_id")
+	return exists && currentID.(uuid.UUID) == userID
+}
+
+// hasOtherActiveAdmin reports whether an active admin other than the given user exists
+func (h *AdminHandler) hasOtherActiveAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var count int64
+	if err := h.db.WithContext(ctx).Model(&models.User{}).
+		Where("role = ? AND is_active = true AND id <> ?", models.RoleAdmin, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // DeleteUser deletes a user account (admin only)
+// DELETE /api/v1/admin/users/:id
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
 	userID := c.Param("id")
 
@@ -200,25 +554,32 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 
 	// Check if user exists
 	var user models.User
-	if err := h.db.First(&user, uid).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&user, uid).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Don't allow deletion of admin user
-	if user.Username == "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete the system admin user"})
+	// Admins must be demoted before they can be deleted
+	if user.Role == models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete admin users"})
 		return
 	}
 
-	// Don't allow deletion of admin users
-	if user.Role == models.RoleAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete admin users"})
+	// Users holding WORM-retained files cannot be removed until retention ends
+	retained, err := services.NewRetentionService(h.db, h.auditService).CountRetainedFiles(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check file retention"})
+		return
+	}
+	if retained > 0 {
+		response := retentionLockedResponse("User owns files under WORM retention", nil)
+		response["retained_files"] = retained
+		c.JSON(http.StatusForbidden, response)
 		return
 	}
 
 	// Soft delete user
-	if err := h.db.Delete(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Delete(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
@@ -241,14 +602,14 @@ func (h *AdminHandler) GetUserDetails(c *gin.Context) {
 
 	// Get user with detailed storage information
 	var user models.User
-	if err := h.db.Select("id, username, email, first_name, last_name, role, storage_quota, storage_used, total_uploaded_bytes, actual_storage_bytes, saved_bytes, is_active, email_verified, last_login, created_at").First(&user, uid).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Select("id, username, email, first_name, last_name, role, storage_quota, storage_used, total_uploaded_bytes, actual_storage_bytes, saved_bytes, is_active, email_verified, last_login, created_at").First(&user, uid).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
 	// Get user's files with file hash information for deduplication stats
 	var files []models.File
-	if err := h.db.Preload("FileHash").Where("owner_id = ? AND is_deleted = false", uid).Find(&files).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Preload("FileHash").Where("owner_id = ?", uid).Find(&files).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user files"})
 		return
 	}
@@ -277,8 +638,8 @@ func (h *AdminHandler) GetUserDetails(c *gin.Context) {
 	}
 
 	response := gin.H{
-		"user":  user,
-		"files": files,
+		"user":  NewUserDTO(&user),
+		"files": NewFileDTOs(files),
 		"statistics": gin.H{
 			"totalFiles":         totalFiles,
 			"uniqueFiles":        uniqueFiles,
@@ -294,28 +655,51 @@ func (h *AdminHandler) GetUserDetails(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GetSystemHealth returns system health information (admin only)
+// GetSystemHealth returns component-level system health information (admin only)
+// GET /api/v1/admin/system/health
 func (h *AdminHandler) GetSystemHealth(c *gin.Context) {
-	health := gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now(),
-		"database":  "connected",
-		"uptime":    time.Since(startTime).String(),
+	c.JSON(http.StatusOK, h.healthService.Readiness(c.Request.Context()))
+}
+
+// GetEffectiveConfig shows the configuration the server runs with, secrets
+// redacted, and the problems Validate finds in it (admin only)
+// GET /api/v1/admin/config/effective
+func (h *AdminHandler) GetEffectiveConfig(c *gin.Context) {
+	problems := h.cfg.Validate()
+	if problems == nil {
+		problems = []config.Problem{}
 	}
+	c.JSON(http.StatusOK, gin.H{
+		"environment": h.cfg.Environment,
+		"config":      h.cfg.Effective(),
+		"problems":    problems,
+	})
+}
 
-	// Check database connection
-	if sqlDB, err := h.db.DB(); err != nil {
-		health["database"] = "disconnected"
-		health["status"] = "degraded"
-	} else if err := sqlDB.Ping(); err != nil {
-		health["database"] = "error"
-		health["status"] = "degraded"
+// GetStorageHealth reports storage capacity, blob growth and projected exhaustion (admin only)
+func (h *AdminHandler) GetStorageHealth(c *gin.Context) {
+	report, err := h.storageHealthService.Check()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage health"})
+		return
 	}
 
-	c.JSON(http.StatusOK, health)
+	c.JSON(http.StatusOK, report)
 }
 
-var startTime = time.Now()
+// adminFileSorts are the sorts of the admin file listing, newest first by default
+var adminFileSorts = sortSpec{
+	fields: map[string]string{
+		"name":     "files.original_filename",
+		"size":     "files.size",
+		"date":     "files.created_at",
+		"modified": "files.updated_at",
+		"mime":     "files.mime_type",
+	},
+	defaultSort:  "date",
+	defaultOrder: "desc",
+	tieBreak:     "files.id",
+}
 
 // GetAllFilesWithStats returns all files with owner details and download statistics (admin only)
 func (h *AdminHandler) GetAllFilesWithStats(c *gin.Context) {
@@ -332,16 +716,23 @@ func (h *AdminHandler) GetAllFilesWithStats(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
+	sort, err := adminFileSorts.parse(c.Query("sort_by"), c.Query("sort_order"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": sortErrorMessage(err)})
+		return
+	}
+
 	// Base query
-	query := h.db.Preload("Owner", func(db *gorm.DB) *gorm.DB {
+	query := h.db.WithContext(c.Request.Context()).Preload("Owner", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, username, email, first_name, last_name")
 	}).Preload("Folder", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, name, path")
-	}).Where("is_deleted = false")
+	})
 
 	// Add search functionality
 	if search != "" {
-		query = query.Where("original_filename ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
+		like := database.ILike(h.db)
+		query = query.Where("original_filename "+like+" ? OR description "+like+" ?", "%"+search+"%", "%"+search+"%")
 	}
 
 	// Get total count
@@ -353,28 +744,22 @@ func (h *AdminHandler) GetAllFilesWithStats(c *gin.Context) {
 
 	// Get files with pagination
 	var files []models.File
-	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&files).Error; err != nil {
+	if err := query.Order(sort.clause).Limit(limit).Offset(offset).Find(&files).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get files"})
 		return
 	}
 
 	// Enhance files with download statistics
-	type FileWithStats struct {
-		models.File
-		DownloadCount     int64      `json:"download_count"`
-		LastDownload      *time.Time `json:"last_download"`
-		UniqueDownloaders int64      `json:"unique_downloaders"`
-	}
-
-	filesWithStats := make([]FileWithStats, len(files))
+	filesWithStats := make([]FileWithStatsDTO, len(files))
 	for i, file := range files {
 		// Get download count
 		var downloadCount int64
-		h.db.Model(&models.DownloadStat{}).Where("file_id = ?", file.ID).Count(&downloadCount)
+		h.db.WithContext(c.Request.Context()).Model(&models.DownloadStat{}).Where("file_id = ?", file.ID).Count(&downloadCount)
+		downloadCount += countedFileDownloads(h.db.WithContext(c.Request.Context()), file.ID)
 
 		// Get last download
 		var lastDownload time.Time
-		err := h.db.Model(&models.DownloadStat{}).
+		err := h.db.WithContext(c.Request.Context()).Model(&models.DownloadStat{}).
 			Where("file_id = ?", file.ID).
 			Order("downloaded_at DESC").
 			Limit(1).
@@ -383,13 +768,13 @@ func (h *AdminHandler) GetAllFilesWithStats(c *gin.Context) {
 
 		// Get unique downloaders count
 		var uniqueDownloaders int64
-		h.db.Model(&models.DownloadStat{}).
+		h.db.WithContext(c.Request.Context()).Model(&models.DownloadStat{}).
 			Where("file_id = ? AND downloaded_by IS NOT NULL", file.ID).
 			Distinct("downloaded_by").
 			Count(&uniqueDownloaders)
 
-		filesWithStats[i] = FileWithStats{
-			File:              file,
+		filesWithStats[i] = FileWithStatsDTO{
+			FileDTO:           NewFileDTO(&file),
 			DownloadCount:     downloadCount,
 			UniqueDownloaders: uniqueDownloaders,
 		}
@@ -423,9 +808,9 @@ func (h *AdminHandler) GetFileStats(c *gin.Context) {
 
 	// Get file with owner info
 	var file models.File
-	if err := h.db.Preload("Owner", func(db *gorm.DB) *gorm.DB {
+	if err := h.db.WithContext(c.Request.Context()).Preload("Owner", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, username, email, first_name, last_name")
-	}).Where("id = ? AND is_deleted = false", fid).First(&file).Error; err != nil {
+	}).Where("id = ?", fid).First(&file).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 			return
@@ -436,7 +821,7 @@ func (h *AdminHandler) GetFileStats(c *gin.Context) {
 
 	// Get download statistics
 	var downloadStats []models.DownloadStat
-	if err := h.db.Preload("User", func(db *gorm.DB) *gorm.DB {
+	if err := h.db.WithContext(c.Request.Context()).Preload("User", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, username, email, first_name, last_name")
 	}).Where("file_id = ?", fid).Order("downloaded_at DESC").Find(&downloadStats).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get download stats"})
@@ -462,13 +847,13 @@ func (h *AdminHandler) GetFileStats(c *gin.Context) {
 
 	// Get sharing information
 	var shareCount int64
-	h.db.Model(&models.FileShare{}).Where("file_id = ?", fid).Count(&shareCount)
+	h.db.WithContext(c.Request.Context()).Model(&models.FileShare{}).Where("file_id = ?", fid).Count(&shareCount)
 
 	var linkCount int64
-	h.db.Model(&models.ShareLink{}).Where("file_id = ?", fid).Count(&linkCount)
+	h.db.WithContext(c.Request.Context()).Model(&models.ShareLink{}).Where("file_id = ?", fid).Count(&linkCount)
 
 	c.JSON(http.StatusOK, gin.H{
-		"file": file,
+		"file": NewFileDTO(&file),
 		"stats": gin.H{
 			"total_downloads":        totalDownloads,
 			"unique_downloaders":     len(uniqueDownloaders),
@@ -477,7 +862,7 @@ func (h *AdminHandler) GetFileStats(c *gin.Context) {
 			"share_count":            shareCount,
 			"link_count":             linkCount,
 		},
-		"recent_downloads": downloadStats[:min(10, len(downloadStats))], // Last 10 downloads
+		"recent_downloads": NewDownloadStatDTOs(downloadStats[:min(10, len(downloadStats))]), // Last 10 downloads
 	})
 }
 
@@ -491,8 +876,7 @@ func (h *AdminHandler) ShareFileAsAdmin(c *gin.Context) {
 		Message    string                 `json:"message"`
 	}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &request) {
 		return
 	}
 
@@ -507,15 +891,12 @@ func (h *AdminHandler) ShareFileAsAdmin(c *gin.Context) {
 	adminUserID, _ := c.Get("user_id")
 
 	// Check if file exists
-	var file models.File
-	if err := h.db.Where("id = ? AND is_deleted = false", fid).First(&file).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+	access, err := h.accessService.ResolveFileAsAdmin(c.Request.Context(), adminUserID.(uuid.UUID), fid)
+	if err != nil {
+		respondAccessError(c, err)
 		return
 	}
+	file := *access.File
 
 	// Create shares for each user
 	var successCount int
@@ -524,14 +905,14 @@ func (h *AdminHandler) ShareFileAsAdmin(c *gin.Context) {
 	for _, userID := range request.SharedWith {
 		// Check if user exists
 		var user models.User
-		if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if err := h.db.WithContext(c.Request.Context()).Where("id = ?", userID).First(&user).Error; err != nil {
 			errors = append(errors, fmt.Sprintf("User %s not found", userID))
 			continue
 		}
 
 		// Check if already shared
 		var existingShare models.FileShare
-		if err := h.db.Where("file_id = ? AND shared_with = ?", fid, userID).First(&existingShare).Error; err == nil {
+		if err := h.db.WithContext(c.Request.Context()).Where("file_id = ? AND shared_with = ?", fid, userID).First(&existingShare).Error; err == nil {
 			errors = append(errors, fmt.Sprintf("File already shared with %s", user.Username))
 			continue
 		}
@@ -546,7 +927,12 @@ func (h *AdminHandler) ShareFileAsAdmin(c *gin.Context) {
 			IsActive:   true,
 		}
 
-		if err := h.db.Create(&share).Error; err != nil {
+		if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&share).Error; err != nil {
+				return err
+			}
+			return services.RecordFileShared(tx, &file, share.SharedBy, services.FileSharePayload(&share))
+		}); err != nil {
 			errors = append(errors, fmt.Sprintf("Failed to share with %s: %v", user.Username, err))
 			continue
 		}
@@ -597,7 +983,7 @@ func (h *AdminHandler) UploadFileAsAdmin(c *gin.Context) {
 	}
 
 	// Create a file handler instance and delegate to the regular upload
-	fileHandler := NewFileHandler(h.db, h.cfg, h.auditService)
+	fileHandler := NewFileHandler(h.db, h.cfg, h.auditService, h.dlpService, h.quarantineService)
 
 	// Set context to indicate this is an admin upload
 	c.Set("admin_upload", true)
@@ -624,7 +1010,7 @@ func (h *AdminHandler) GetUserFiles(c *gin.Context) {
 
 	// Check if user exists
 	var user models.User
-	if err := h.db.First(&user, uid).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&user, uid).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
@@ -637,13 +1023,12 @@ func (h *AdminHandler) GetUserFiles(c *gin.Context) {
 	// Query files for this user with stats
 	var files []struct {
 		models.File
-		DownloadCount     int64       `json:"downloadCount"`
-		UniqueDownloaders int64       `json:"uniqueDownloaders"`
-		LastDownload      *time.Time  `json:"lastDownload"`
-		Owner             models.User `json:"owner"`
+		DownloadCount     int64
+		UniqueDownloaders int64
+		LastDownload      *time.Time
 	}
 
-	query := h.db.Table("files").
+	query := h.db.WithContext(c.Request.Context()).Table("files").
 		Select("files.*, "+
 			"COALESCE(download_stats.download_count, 0) as download_count, "+
 			"COALESCE(download_stats.unique_downloaders, 0) as unique_downloaders, "+
@@ -656,7 +1041,8 @@ func (h *AdminHandler) GetUserFiles(c *gin.Context) {
 			"FROM download_stats "+
 			"GROUP BY file_id"+
 			") download_stats ON files.id = download_stats.file_id").
-		Where("files.owner_id = ? AND files.is_deleted = false", uid).
+		Where("files.owner_id = ?", uid).
+		Scopes(models.ActiveFiles).
 		Offset(offset).
 		Limit(limit)
 
@@ -665,20 +1051,24 @@ func (h *AdminHandler) GetUserFiles(c *gin.Context) {
 		return
 	}
 
-	// Load owner information for each file
+	// Every file belongs to the requested user, so attach them as the owner
+	filesWithStats := make([]FileWithStatsDTO, len(files))
 	for i := range files {
-		if err := h.db.First(&files[i].Owner, files[i].File.OwnerID).Error; err != nil {
-			// Set empty owner if not found
-			files[i].Owner = models.User{Username: "Unknown"}
+		files[i].File.Owner = user
+		filesWithStats[i] = FileWithStatsDTO{
+			FileDTO:           NewFileDTO(&files[i].File),
+			DownloadCount:     files[i].DownloadCount,
+			UniqueDownloaders: files[i].UniqueDownloaders,
+			LastDownload:      files[i].LastDownload,
 		}
 	}
 
 	// Get total count for this user
 	var total int64
-	h.db.Model(&models.File{}).Where("owner_id = ? AND is_deleted = false", uid).Count(&total)
+	h.db.WithContext(c.Request.Context()).Model(&models.File{}).Where("owner_id = ?", uid).Count(&total)
 
 	c.JSON(http.StatusOK, gin.H{
-		"files": files,
+		"files": filesWithStats,
 		"pagination": gin.H{
 			"page":       page,
 			"limit":      limit,
@@ -696,6 +1086,7 @@ func (h *AdminHandler) GetUserFiles(c *gin.Context) {
 // MakeFilePublic makes a file publicly accessible (admin only)
 func (h *AdminHandler) MakeFilePublic(c *gin.Context) {
 	fileID := c.Param("id")
+	createdBy := c.MustGet("user_id").(uuid.UUID)
 
 	// Parse file ID
 	fid, err := uuid.Parse(fileID)
@@ -705,23 +1096,55 @@ func (h *AdminHandler) MakeFilePublic(c *gin.Context) {
 	}
 
 	// Check if file exists
-	var file models.File
-	if err := h.db.First(&file, fid).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	access, err := h.accessService.ResolveFileAsAdmin(c.Request.Context(), createdBy, fid)
+	if err != nil {
+		respondAccessError(c, err)
 		return
 	}
-
-	// Update file to be public
-	if err := h.db.Model(&file).Update("is_public", true).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file visibility"})
+	file := access.File
+	if file.IsEncrypted {
+		c.Error(services.ErrEncryptedNotPublic)
 		return
 	}
 
-	// Get current user ID
-	userIDStr := c.GetString("userID")
-	createdBy, err := uuid.Parse(userIDStr)
+	// Respect upload policies that forbid public sharing for this file type
+	var owner models.User
+	if err := h.db.WithContext(c.Request.Context()).Select("id", "role", "plan_id").Preload("Plan").First(&owner, "id = ?", file.OwnerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file owner"})
+		return
+	}
+	if !owner.AllowsPublicSharing() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Public sharing is not allowed",
+			"type":    "PLAN_RESTRICTION",
+			"message": "The file owner's plan does not allow public files",
+			"code":    "PUBLIC_SHARING_NOT_IN_PLAN",
+		})
+		return
+	}
+	policies, err := h.uploadPolicyService.ListPolicies(string(owner.Role))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user session"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load upload policies"})
+		return
+	}
+	decision := services.EvaluatePolicies(policies, services.PolicyFile{
+		Filename: file.OriginalFilename,
+		MimeType: file.MimeType,
+		Size:     file.Size,
+	}, true)
+	if !decision.PublicAllowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Public sharing is not allowed for this file type",
+			"type":    "UPLOAD_POLICY_VIOLATION",
+			"message": "An upload policy forbids making files of this type public",
+			"code":    services.PolicyCodePublicNotAllowed,
+		})
+		return
+	}
+
+	// Update file to be public
+	if err := h.db.WithContext(c.Request.Context()).Model(file).Update("is_public", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file visibility"})
 		return
 	}
 
@@ -735,17 +1158,28 @@ func (h *AdminHandler) MakeFilePublic(c *gin.Context) {
 		IsActive:   true,
 	}
 
-	if err := h.db.Create(&shareLink).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shareLink).Error; err != nil {
+			return err
+		}
+		payload := services.ShareLinkPayload(&shareLink)
+		payload["public"] = true
+		return services.RecordFileShared(tx, file, createdBy, payload)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
 		return
 	}
 
+	// Get the file ready for its first visitor
+	h.prewarmService.WarmShareLink(&shareLink)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":   "File made public successfully",
-		"file_id":   file.ID,
-		"is_public": true,
-		"shareLink": shareLink.ShareToken,
-		"publicUrl": fmt.Sprintf("/share/%s", shareLink.ShareToken),
+		"message":     "File made public successfully",
+		"file_id":     file.ID,
+		"is_public":   true,
+		"warm_status": shareLink.WarmStatus,
+		"shareLink":   shareLink.ShareToken,
+		"publicUrl":   h.linkService.ShareLinkURL(shareLink.CreatedBy, shareLink.ShareToken),
 	})
 }
 
@@ -761,20 +1195,21 @@ func (h *AdminHandler) MakeFilePrivate(c *gin.Context) {
 	}
 
 	// Check if file exists
-	var file models.File
-	if err := h.db.First(&file, fid).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	access, err := h.accessService.ResolveFileAsAdmin(c.Request.Context(), c.MustGet("user_id").(uuid.UUID), fid)
+	if err != nil {
+		respondAccessError(c, err)
 		return
 	}
+	file := access.File
 
 	// Update file to be private
-	if err := h.db.Model(&file).Update("is_public", false).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(file).Update("is_public", false).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file visibility"})
 		return
 	}
 
 	// Optionally deactivate existing share links for this file
-	if err := h.db.Model(&models.ShareLink{}).Where("file_id = ?", file.ID).Update("is_active", false).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.ShareLink{}).Where("file_id = ?", file.ID).Update("is_active", false).Error; err != nil {
 		// Log error but don't fail the request
 		fmt.Printf("Warning: Failed to deactivate share links for file %s: %v\n", file.ID, err)
 	}
@@ -786,81 +1221,100 @@ func (h *AdminHandler) MakeFilePrivate(c *gin.Context) {
 	})
 }
 
-// ViewFileAsAdmin serves file content for admin preview/viewing (bypasses ownership checks)
-func (h *AdminHandler) ViewFileAsAdmin(c *gin.Context) {
-	fmt.Printf("DEBUG ViewFileAsAdmin: Function called\n")
-	fileID := c.Param("id")
-	fmt.Printf("DEBUG ViewFileAsAdmin: File ID: %s\n", fileID)
+// recordContentAccess requires a reason for an admin to open another user's
+// file and records the access in the audit log before any content is served.
+// It reports whether the access may go ahead
+func (h *AdminHandler) recordContentAccess(c *gin.Context, access *services.FileAccess, mode string) bool {
+	if access.Via == services.AccessViaOwner {
+		return true
+	}
+	adminID := c.MustGet("user_id").(uuid.UUID)
 
-	// Get file record without ownership checks (admin can view any file)
-	var file models.File
-	if err := h.db.Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
-		fmt.Printf("DEBUG ViewFileAsAdmin: Database error: %v\n", err)
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+	reason := strings.TrimSpace(c.Query("reason"))
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "A reason is required to access another user's file",
+			"code":  "ACCESS_REASON_REQUIRED",
+		})
+		return false
+	}
+	if utf8.RuneCountInString(reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason must be at most 500 characters"})
+		return false
 	}
 
-	fmt.Printf("DEBUG ViewFileAsAdmin: Found file: %s, FileHashID: %s\n", file.ID, file.FileHashID)
+	if err := h.auditService.LogAdminFileAccess(c, adminID, access.File, mode, reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record file access"})
+		return false
+	}
+	return true
+}
 
-	// Get file hash to locate physical file
-	var fileHash models.FileHash
-	if err := h.db.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
-		fmt.Printf("DEBUG ViewFileAsAdmin: File hash error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "File hash not found"})
-		return
+// adminFile resolves the file of an admin file route, which admins may open
+// whoever owns it. It answers the request when the file cannot be loaded
+func (h *AdminHandler) adminFile(c *gin.Context) (*services.FileAccess, bool) {
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return nil, false
 	}
+	access, err := h.accessService.ResolveFileAsAdmin(c.Request.Context(), c.MustGet("user_id").(uuid.UUID), fileID)
+	if err != nil {
+		respondAccessError(c, err)
+		return nil, false
+	}
+	return access, true
+}
 
-	fmt.Printf("DEBUG ViewFileAsAdmin: Found file hash: %s, StoragePath: %s\n", fileHash.ID, fileHash.StoragePath)
+// ViewFileAsAdmin serves file content for admin preview/viewing (bypasses
+// ownership checks). Viewing another user's file requires a reason, which is
+// audited
+func (h *AdminHandler) ViewFileAsAdmin(c *gin.Context) {
+	access, ok := h.adminFile(c)
+	if !ok {
+		return
+	}
+	file := access.File
+	if respondIfEncrypted(c, file) {
+		return
+	}
 
-	// Build full file path like in regular ViewFile
-	filePath := filepath.Join(h.cfg.StoragePath, fileHash.StoragePath)
-	fmt.Printf("DEBUG ViewFileAsAdmin: Full file path: %s\n", filePath)
+	// Locate the blob the same way user views do
+	stream, err := h.fileStreamService.Open(file)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
 
-	// Set appropriate headers for file viewing
-	c.Header("Content-Type", file.MimeType)
-	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", file.OriginalFilename))
-	c.Header("Cache-Control", "private, max-age=3600")
+	if !h.recordContentAccess(c, access, "view") {
+		return
+	}
 
-	// Serve the file
-	c.File(filePath)
+	h.fileStreamService.Serve(c, stream, services.StreamInline)
 }
 
-// DownloadFileAsAdmin serves file content for admin download (bypasses ownership checks)
+// DownloadFileAsAdmin serves file content for admin download (bypasses
+// ownership checks). Downloading another user's file requires a reason, which
+// is audited
 func (h *AdminHandler) DownloadFileAsAdmin(c *gin.Context) {
-	fileID := c.Param("id")
-
-	// Get file record without ownership checks (admin can download any file)
-	var file models.File
-	if err := h.db.Where("id = ? AND is_deleted = false", fileID).First(&file).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	access, ok := h.adminFile(c)
+	if !ok {
 		return
 	}
+	file := access.File
 
-	// Get file hash to locate physical file
-	var fileHash models.FileHash
-	if err := h.db.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "File hash not found"})
+	// Locate the blob the same way user downloads do
+	stream, err := h.fileStreamService.Open(file)
+	if err != nil {
+		respondStreamError(c, err)
 		return
 	}
 
-	// Build full file path
-	filePath := filepath.Join(h.cfg.StoragePath, fileHash.StoragePath)
-
-	// Set appropriate headers for file download
-	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.OriginalFilename))
-	c.Header("Cache-Control", "private, max-age=3600")
+	if !h.recordContentAccess(c, access, "download") {
+		return
+	}
 
-	// Serve the file for download
-	c.File(filePath)
+	h.fileStreamService.Serve(c, stream, services.StreamAttachment)
 }
 
 // UserDeduplicationSummary represents deduplication statistics for a single user
@@ -881,73 +1335,265 @@ type UserDeduplicationSummary struct {
 	IsActive           bool       `json:"isActive"`
 }
 
-// GetUserDeduplicationSummary returns deduplication statistics for all users
-func (h *AdminHandler) GetUserDeduplicationSummary(c *gin.Context) {
-	var users []models.User
-	var summaries []UserDeduplicationSummary
+// HashDeduplicationUser is a user referencing a deduplicated blob
+type HashDeduplicationUser struct {
+	UserID    uuid.UUID `json:"userId"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	FileCount int64     `json:"fileCount"`
+}
+
+// HashDeduplicationStats describes how effectively a single blob is deduplicated
+type HashDeduplicationStats struct {
+	FileHashID     uuid.UUID               `json:"fileHashId"`
+	Hash           string                  `json:"hash"`
+	Size           int64                   `json:"size"`
+	ReferenceCount int64                   `json:"referenceCount"`
+	UserCount      int64                   `json:"userCount"`
+	BytesSaved     int64                   `json:"bytesSaved"`
+	SampleFilename string                  `json:"sampleFilename"`
+	MimeType       string                  `json:"mimeType"`
+	FirstUploaded  time.Time               `json:"firstUploaded"`
+	Users          []HashDeduplicationUser `json:"users" gorm:"-"`
+}
+
+// deduplicatedHashSorts are the sorts of the top deduplicated blobs
+var deduplicatedHashSorts = sortSpec{
+	fields: map[string]string{
+		"reference_count": "reference_count",
+		"bytes_saved":     "bytes_saved",
+		"size":            "fh.size",
+		"user_count":      "user_count",
+	},
+	defaultSort:  "reference_count,bytes_saved",
+	defaultOrder: "desc",
+	tieBreak:     "fh.id",
+}
+
+// GetTopDeduplicatedHashes lists the blobs with the most references and the
+// bytes each one saves (admin only)
+// GET /api/v1/admin/deduplication/top-hashes
+func (h *AdminHandler) GetTopDeduplicatedHashes(c *gin.Context) {
+	pageNum := 1
+	limitNum := 20
+	minReferences := 2
+
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil && p > 0 {
+			pageNum = p
+		}
+	}
 
-	// Get all users with their basic info
-	if err := h.db.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil && l > 0 && l <= 100 {
+			limitNum = l
+		}
+	}
+
+	if minRefs := c.Query("min_references"); minRefs != "" {
+		if m, err := strconv.Atoi(minRefs); err == nil && m > 0 {
+			minReferences = m
+		}
+	}
+
+	sort, err := deduplicatedHashSorts.parse(c.Query("sort_by"), c.Query("sort_order"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": sortErrorMessage(err)})
 		return
 	}
 
-	// Calculate deduplication stats for each user
-	for _, user := range users {
-		summary := UserDeduplicationSummary{
-			UserID:    user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			IsActive:  user.IsActive,
+	// References are counted from live files so deleted uploads do not inflate savings
+	base := h.db.WithContext(c.Request.Context()).Table("files f").
+		Joins("JOIN file_hashes fh ON fh.id = f.file_hash_id").
+		Where("f.deleted_at IS NULL").
+		Group("fh.id, fh.hash, fh.size").
+		Having("COUNT(*) >= ?", minReferences)
+
+	var totalHashes int64
+	if err := h.db.WithContext(c.Request.Context()).Table("(?) AS deduplicated", base.Session(&gorm.Session{}).Select("fh.id")).
+		Count(&totalHashes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count deduplicated hashes"})
+		return
+	}
+
+	var stats []HashDeduplicationStats
+	if err := base.Session(&gorm.Session{}).
+		Select(`fh.id AS file_hash_id,
+			fh.hash,
+			fh.size,
+			COUNT(*) AS reference_count,
+			COUNT(DISTINCT f.owner_id) AS user_count,
+			fh.size * (COUNT(*) - 1) AS bytes_saved,
+			MIN(f.original_filename) AS sample_filename,
+			MIN(f.mime_type) AS mime_type,
+			MIN(f.created_at) AS first_uploaded`).
+		Order(sort.clause).
+		Offset((pageNum - 1) * limitNum).
+		Limit(limitNum).
+		Scan(&stats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deduplicated hashes"})
+		return
+	}
+
+	if len(stats) > 0 {
+		hashIDs := make([]uuid.UUID, 0, len(stats))
+		for _, stat := range stats {
+			hashIDs = append(hashIDs, stat.FileHashID)
+		}
+
+		var refs []struct {
+			FileHashID uuid.UUID
+			HashDeduplicationUser
 		}
+		if err := h.db.WithContext(c.Request.Context()).Table("files f").
+			Select("f.file_hash_id, u.id AS user_id, u.username, u.email, COUNT(*) AS file_count").
+			Joins("JOIN users u ON u.id = f.owner_id").
+			Where("f.deleted_at IS NULL AND f.file_hash_id IN ?", hashIDs).
+			Group("f.file_hash_id, u.id, u.username, u.email").
+			Order("file_count DESC, u.username ASC").
+			Scan(&refs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch referencing users"})
+			return
+		}
+
+		usersByHash := make(map[uuid.UUID][]HashDeduplicationUser)
+		for _, ref := range refs {
+			usersByHash[ref.FileHashID] = append(usersByHash[ref.FileHashID], ref.HashDeduplicationUser)
+		}
+		for i := range stats {
+			stats[i].Users = usersByHash[stats[i].FileHashID]
+		}
+	}
+
+	var totalBytesSaved int64
+	if err := h.db.WithContext(c.Request.Context()).Table("(?) AS deduplicated", base.Session(&gorm.Session{}).
+		Select("fh.size * (COUNT(*) - 1) AS bytes_saved")).
+		Select("COALESCE(SUM(bytes_saved), 0)").
+		Scan(&totalBytesSaved).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate bytes saved"})
+		return
+	}
 
-		// Get total files count for this user
-		var fileCount int64
-		h.db.Model(&models.File{}).Where("owner_id = ? AND is_deleted = false", user.ID).Count(&fileCount)
-		summary.TotalFiles = fileCount
+	totalPages := int((totalHashes + int64(limitNum) - 1) / int64(limitNum))
 
-		// Get the latest file upload timestamp
-		var latestFile models.File
-		if err := h.db.Where("owner_id = ? AND is_deleted = false", user.ID).
-			Order("created_at DESC").First(&latestFile).Error; err == nil {
-			summary.LastFileUpload = &latestFile.CreatedAt
+	c.JSON(http.StatusOK, gin.H{
+		"hashes":          stats,
+		"totalHashes":     totalHashes,
+		"totalBytesSaved": totalBytesSaved,
+		"page":            pageNum,
+		"limit":           limitNum,
+		"totalPages":      totalPages,
+	})
+}
+
+// deduplicationSummarySorts are the sorts of the per-user deduplication summary
+var deduplicationSummarySorts = sortSpec{
+	fields: map[string]string{
+		"username":            "u.username",
+		"total_files":         "total_files",
+		"uploaded_bytes":      "u.total_uploaded_bytes",
+		"storage_bytes":       "u.actual_storage_bytes",
+		"saved_bytes":         "u.saved_bytes",
+		"deduplication_ratio": "deduplication_ratio",
+		"last_upload":         "last_file_upload",
+	},
+	defaultSort:  "saved_bytes",
+	defaultOrder: "desc",
+	tieBreak:     "u.id",
+}
+
+// GetUserDeduplicationSummary returns paginated deduplication statistics for all users
+// GET /api/v1/admin/deduplication/summary
+func (h *AdminHandler) GetUserDeduplicationSummary(c *gin.Context) {
+	pageNum := 1
+	limitNum := 50
+
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil && p > 0 {
+			pageNum = p
 		}
+	}
 
-		// Use the deduplication tracking fields from the user model
-		summary.TotalUploadedBytes = user.TotalUploadedBytes
-		summary.ActualStorageBytes = user.ActualStorageBytes
-		summary.SavedBytes = user.SavedBytes
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil && l > 0 && l <= 100 {
+			limitNum = l
+		}
+	}
+
+	sort, err := deduplicationSummarySorts.parse(c.Query("sort_by"), c.Query("sort_order"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": sortErrorMessage(err)})
+		return
+	}
+
+	var totalUsers int64
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.User{}).Count(&totalUsers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
+		return
+	}
+
+	// Per-user file counts come from one grouped pass over files instead of
+	// several queries per user
+	fileStats := h.db.WithContext(c.Request.Context()).Table("files").
+		Select("owner_id, COUNT(*) AS total_files, COUNT(DISTINCT file_hash_id) AS unique_files, MAX(created_at) AS last_file_upload").
+		Scopes(models.ActiveFiles).
+		Group("owner_id")
+
+	var rows []struct {
+		UserDeduplicationSummary
+		UniqueFiles int64
+	}
+	if err := h.db.WithContext(c.Request.Context()).Table("users u").
+		Select(`u.id AS user_id,
+			u.username,
+			u.email,
+			u.first_name,
+			u.last_name,
+			u.is_active,
+			u.total_uploaded_bytes,
+			u.actual_storage_bytes,
+			u.saved_bytes,
+			COALESCE(fs.total_files, 0) AS total_files,
+			COALESCE(fs.unique_files, 0) AS unique_files,
+			fs.last_file_upload,
+			CASE WHEN u.total_uploaded_bytes > 0
+				THEN u.saved_bytes * 100.0 / u.total_uploaded_bytes
+				ELSE 0 END AS deduplication_ratio`).
+		Joins("LEFT JOIN (?) AS fs ON fs.owner_id = u.id", fileStats).
+		Where("u.deleted_at IS NULL").
+		Order(sort.clause).
+		Offset((pageNum - 1) * limitNum).
+		Limit(limitNum).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deduplication summaries"})
+		return
+	}
+
+	summaries := make([]UserDeduplicationSummary, 0, len(rows))
+	for _, row := range rows {
+		summary := row.UserDeduplicationSummary
 
 		// Calculate deduplication ratio (percentage of storage saved)
 		if summary.TotalUploadedBytes > 0 {
-			summary.DeduplicationRatio = float64(summary.SavedBytes) / float64(summary.TotalUploadedBytes) * 100
 			summary.StorageEfficiency = float64(summary.ActualStorageBytes) / float64(summary.TotalUploadedBytes) * 100
-		} else {
-			summary.DeduplicationRatio = 0.0
-			summary.StorageEfficiency = 0.0
 		}
 
-		// Calculate unique files ratio (count of unique file hashes user has uploaded)
-		var uniqueHashCount int64
-		h.db.Table("files").
-			Select("COUNT(DISTINCT file_hash_id)").
-			Where("owner_id = ? AND is_deleted = false", user.ID).
-			Count(&uniqueHashCount)
-
+		// Unique files ratio (count of unique file hashes user has uploaded)
 		if summary.TotalFiles > 0 {
-			summary.UniqueFilesRatio = float64(uniqueHashCount) / float64(summary.TotalFiles) * 100
-		} else {
-			summary.UniqueFilesRatio = 0.0
+			summary.UniqueFilesRatio = float64(row.UniqueFiles) / float64(summary.TotalFiles) * 100
 		}
 
 		summaries = append(summaries, summary)
 	}
 
+	totalPages := int((totalUsers + int64(limitNum) - 1) / int64(limitNum))
+
 	c.JSON(http.StatusOK, gin.H{
 		"userDeduplicationSummaries": summaries,
-		"totalUsers":                 len(summaries),
+		"totalUsers":                 totalUsers,
+		"page":                       pageNum,
+		"limit":                      limitNum,
+		"totalPages":                 totalPages,
 	})
 }
 
@@ -968,7 +1614,7 @@ func (h *AdminHandler) GetUserDeduplicationDetails(c *gin.Context) {
 
 	// Get user details
 	var user models.User
-	if err := h.db.First(&user, parsedUserID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&user, parsedUserID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
@@ -1004,11 +1650,11 @@ func (h *AdminHandler) GetUserDeduplicationDetails(c *gin.Context) {
 			f.created_at
 		FROM files f
 		JOIN file_hashes fh ON f.file_hash_id = fh.id
-		WHERE f.owner_id = ? AND f.is_deleted = false
+		WHERE f.owner_id = ? AND f.deleted_at IS NULL
 		ORDER BY f.created_at DESC
 	`
 
-	if err := h.db.Raw(query, parsedUserID).Scan(&fileDetails).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Raw(query, parsedUserID).Scan(&fileDetails).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file details"})
 		return
 	}
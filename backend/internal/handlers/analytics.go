@@ -5,9 +5,12 @@ import (
 	"strconv"
 	"time"
 
+	"file-vault-system/backend/internal/middleware"
 	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -37,6 +40,11 @@ type AnalyticsData struct {
 
 	// Activity Analytics
 	ActiveSessions int64 `json:"activeSessions"`
+
+	// What is kept of anonymous visits: "full", "minimal" or "counts". Unless
+	// full, anonymous downloads have no IP address or user agent; with counts
+	// they are in the download figures as daily counts only
+	AnonymousAccessTracking string `json:"anonymousAccessTracking"`
 }
 
 type TimeSeriesData struct {
@@ -111,9 +119,9 @@ func GetAnalyticsOverview(c *gin.Context) {
 	db.Model(&DownloadStat{}).Where("downloaded_at >= ?", today).Count(&downloadStats.DownloadsToday)
 	db.Model(&DownloadStat{}).Where("downloaded_at >= ?", weekStart).Count(&downloadStats.DownloadsThisWeek)
 
-	analytics.TotalDownloads = downloadStats.TotalDownloads
-	analytics.DownloadsToday = downloadStats.DownloadsToday
-	analytics.DownloadsThisWeek = downloadStats.DownloadsThisWeek
+	analytics.TotalDownloads = downloadStats.TotalDownloads + countedDownloads(db, time.Time{}, time.Time{})
+	analytics.DownloadsToday = downloadStats.DownloadsToday + countedDownloads(db, today, time.Time{})
+	analytics.DownloadsThisWeek = downloadStats.DownloadsThisWeek + countedDownloads(db, weekStart, time.Time{})
 
 	// Unique downloaders
 	db.Model(&DownloadStat{}).Distinct("downloaded_by").Count(&analytics.UniqueDownloaders)
@@ -122,9 +130,37 @@ func GetAnalyticsOverview(c *gin.Context) {
 	lastHour := time.Now().Add(-1 * time.Hour)
 	db.Model(&User{}).Where("updated_at >= ? AND is_active = ?", lastHour, true).Count(&analytics.ActiveSessions)
 
+	analytics.AnonymousAccessTracking = middleware.AnonymousAccessTracking()
+
 	c.JSON(http.StatusOK, analytics)
 }
 
+// countedDownloads sums the anonymous file downloads that were only counted
+// per day, on the days from since up to before until; zero times leave that
+// end open
+func countedDownloads(db *gorm.DB, since, until time.Time) int64 {
+	query := db.Model(&models.AccessCount{}).Where("resource_type = ?", models.AccessCountFile)
+	if !since.IsZero() {
+		query = query.Where("day >= ?", since)
+	}
+	if !until.IsZero() {
+		query = query.Where("day < ?", until)
+	}
+	var total int64
+	query.Select("COALESCE(SUM(downloads), 0)").Scan(&total)
+	return total
+}
+
+// countedFileDownloads sums the anonymous downloads of one file that were
+// only counted per day
+func countedFileDownloads(db *gorm.DB, fileID uuid.UUID) int64 {
+	var total int64
+	db.Model(&models.AccessCount{}).
+		Where("resource_type = ? AND resource_id = ?", models.AccessCountFile, fileID).
+		Select("COALESCE(SUM(downloads), 0)").Scan(&total)
+	return total
+}
+
 func GetUserRegistrationTrend(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 
@@ -199,6 +235,7 @@ func GetDownloadTrend(c *gin.Context) {
 
 		var count int64
 		db.Model(&DownloadStat{}).Where("downloaded_at >= ? AND downloaded_at < ?", date, nextDate).Count(&count)
+		count += countedDownloads(db, date, nextDate)
 
 		trends = append(trends, TimeSeriesData{
 			Date:  date.Format("2006-01-02"),
@@ -214,9 +251,11 @@ func GetFileTypeDistribution(c *gin.Context) {
 
 	var distributions []FileTypeDistribution
 
+	// The top-level type, e.g. "image" for "image/png"
+	mediaType := database.PrefixBefore(db, "mime_type", "'/'")
 	rows, err := db.Model(&File{}).
-		Select("SUBSTRING(mime_type FROM 1 FOR POSITION('/' IN mime_type) - 1) as type, COUNT(*) as count, SUM(size) as size").
-		Group("SUBSTRING(mime_type FROM 1 FOR POSITION('/' IN mime_type) - 1)").
+		Select(mediaType + " as type, COUNT(*) as count, SUM(size) as size").
+		Group(mediaType).
 		Order("count DESC").
 		Rows()
 
@@ -253,7 +292,7 @@ func GetTopFiles(c *gin.Context) {
 	var topFiles []TopFile
 
 	err := db.Model(&File{}).
-		Select("files.id, files.original_filename, COALESCE(COUNT(download_stats.id), 0) as download_count, files.size, users.username as owner").
+		Select("files.id, files.original_filename, COALESCE(COUNT(download_stats.id), 0) + (SELECT COALESCE(SUM(downloads), 0) FROM access_counts WHERE resource_type = ? AND resource_id = files.id) as download_count, files.size, users.username as owner", models.AccessCountFile).
 		Joins("LEFT JOIN users ON files.owner_id = users.id").
 		Joins("LEFT JOIN download_stats ON files.id = download_stats.file_id").
 		Group("files.id, files.original_filename, files.size, users.username").
@@ -327,3 +366,54 @@ func GetStorageUsageTrend(c *gin.Context) {
 
 	c.JSON(http.StatusOK, trends)
 }
+
+// DedupSavingsPoint is one day of global storage from the daily rollups
+type DedupSavingsPoint struct {
+	Date         string  `json:"date"`
+	LogicalBytes int64   `json:"logicalBytes"`
+	ActualBytes  int64   `json:"actualBytes"`
+	SavedBytes   int64   `json:"savedBytes"`
+	SavedPercent float64 `json:"savedPercent"`
+	FileCount    int64   `json:"fileCount"`
+	BlobCount    int64   `json:"blobCount"`
+}
+
+// GetDedupSavingsTrend charts what deduplication saves: the logical size of
+// all files against the actual size of their blobs, per day, read from the
+// daily storage rollups. Days without a rollup are left out.
+// GET /api/v1/admin/analytics/dedup-savings-trend
+func GetDedupSavingsTrend(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	days := 30
+	if d := c.Query("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+
+	since := time.Now().AddDate(0, 0, -(days - 1)).Truncate(24 * time.Hour)
+	var rollups []models.StorageRollup
+	if err := db.Where("day >= ?", since).Order("day ASC").Find(&rollups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load storage rollups"})
+		return
+	}
+
+	trend := make([]DedupSavingsPoint, len(rollups))
+	for i, rollup := range rollups {
+		point := DedupSavingsPoint{
+			Date:         rollup.Day.Format("2006-01-02"),
+			LogicalBytes: rollup.LogicalBytes,
+			ActualBytes:  rollup.ActualBytes,
+			SavedBytes:   rollup.SavedBytes,
+			FileCount:    rollup.FileCount,
+			BlobCount:    rollup.BlobCount,
+		}
+		if rollup.LogicalBytes > 0 {
+			point.SavedPercent = float64(rollup.SavedBytes) / float64(rollup.LogicalBytes) * 100
+		}
+		trend[i] = point
+	}
+
+	c.JSON(http.StatusOK, trend)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// createAPIKeyRequest names a new API key after the service it is for
+type createAPIKeyRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// GetMyAPIKeys returns the current user's API keys, without the keys themselves
+// GET /api/v1/me/api-keys
+func (h *APIKeyHandler) GetMyAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListAPIKeys(c.MustGet("user_id").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey issues an API key. The key is only ever returned here
+// POST /api/v1/me/api-keys
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	apiKey, key, err := h.apiKeyService.CreateAPIKey(c.MustGet("user_id").(uuid.UUID), req.Name)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyLimit) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No more API keys can be created; revoke one first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": apiKey,
+		"key":     key,
+	})
+}
+
+// RevokeAPIKey deletes one of the current user's API keys; it stops working
+// immediately
+// DELETE /api/v1/me/api-keys/:id
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	if err := h.apiKeyService.DeleteAPIKey(c.MustGet("user_id").(uuid.UUID), keyID); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
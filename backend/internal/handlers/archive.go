@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type ArchiveHandler struct {
+	archiveService *services.ArchiveService
+}
+
+func NewArchiveHandler(archiveService *services.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{
+		archiveService: archiveService,
+	}
+}
+
+// RestoreFromArchive requests that an archived file be brought back from cold storage
+// POST /api/v1/files/:id/restore-from-archive
+func (h *ArchiveHandler) RestoreFromArchive(c *gin.Context) {
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tier, err := h.archiveService.RequestRestore(fileID, userID.(uuid.UUID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		case errors.Is(err, services.ErrArchivingDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cold storage is not available"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request restore"})
+		}
+		return
+	}
+
+	switch tier {
+	case models.StorageTierRestoring:
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":      "Restore requested. You will be notified when the file is available.",
+			"file_id":      fileID,
+			"storage_tier": tier,
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "File is not archived",
+			"file_id":      fileID,
+			"storage_tier": tier,
+		})
+	}
+}
@@ -119,7 +119,13 @@ func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, gin.H{
+		"activities": NewAuditLogDTOs(result.Activities),
+		"total":      result.Total,
+		"has_more":   result.HasMore,
+		"page":       result.Page,
+		"limit":      result.Limit,
+	})
 }
 
 // GetUserActivitySummary handles GET /api/v1/audit-logs/summary
@@ -164,15 +170,9 @@ func (h *AuditHandler) GetUserActivitySummary(c *gin.Context) {
 	c.JSON(http.StatusOK, summary)
 }
 
-// GetAdminAuditLogs handles GET /admin/audit-logs (admin only)
+// GetAdminAuditLogs handles GET /admin/audit-logs (admin only). Filter with
+// action=admin_file_access to review admins opening users' files
 func (h *AuditHandler) GetAdminAuditLogs(c *gin.Context) {
-	// Check admin role (this should be done in middleware)
-	userRole, exists := c.Get("user_role")
-	if !exists || userRole != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-		return
-	}
-
 	// Build filter from query parameters (similar to GetAuditLogs but without user restrictions)
 	filter := models.AuditLogFilter{}
 
@@ -242,7 +242,13 @@ func (h *AuditHandler) GetAdminAuditLogs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, gin.H{
+		"activities": NewAuditLogDTOs(result.Activities),
+		"total":      result.Total,
+		"has_more":   result.HasMore,
+		"page":       result.Page,
+		"limit":      result.Limit,
+	})
 }
 
 // DeleteOldAuditLogs handles DELETE /admin/audit-logs/cleanup (admin only)
@@ -262,8 +268,10 @@ func (h *AuditHandler) DeleteOldAuditLogs(c *gin.Context) {
 		}
 	}
 
+	adminID, _ := c.Get("user_id")
+
 	// Delete old audit logs
-	deletedCount, err := h.auditService.DeleteOldAuditLogs(c.Request.Context(), days)
+	deletedCount, err := h.auditService.DeleteOldAuditLogs(c.Request.Context(), days, adminID.(uuid.UUID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete old audit logs"})
 		return
@@ -275,3 +283,95 @@ func (h *AuditHandler) DeleteOldAuditLogs(c *gin.Context) {
 		"older_than_days": days,
 	})
 }
+
+// VerifyAuditChain recomputes the audit log's hash chain and reports any
+// entry that was modified, removed or reordered. Passing the head_hash and
+// last_sequence of an earlier report as anchor_hash and anchor_sequence also
+// detects entries removed from the end (admin only)
+// GET /api/v1/admin/audit-logs/verify-chain
+func (h *AuditHandler) VerifyAuditChain(c *gin.Context) {
+	var anchor *services.ChainAnchor
+	if sequenceParam := c.Query("anchor_sequence"); sequenceParam != "" {
+		sequence, err := strconv.ParseInt(sequenceParam, 10, 64)
+		hash := c.Query("anchor_hash")
+		if err != nil || sequence < 1 || hash == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "anchor_sequence must be a positive integer and anchor_hash is required with it"})
+			return
+		}
+		anchor = &services.ChainAnchor{Sequence: sequence, Hash: hash}
+	}
+
+	result, err := h.auditService.VerifyChain(c.Request.Context(), anchor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// AdminAccessDTO is an admin's access to one of the user's files, without
+// the admin's client details
+type AdminAccessDTO struct {
+	ID        uuid.UUID       `json:"id"`
+	FileID    *uuid.UUID      `json:"file_id,omitempty"`
+	Filename  *string         `json:"filename,omitempty"`
+	Mode      string          `json:"mode"`
+	Reason    string          `json:"reason"`
+	Admin     *UserSummaryDTO `json:"admin,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// GetMyAdminAccess lists when admins opened or downloaded the current user's
+// files and the reasons they gave
+// GET /api/v1/me/admin-access
+func (h *AuditHandler) GetMyAdminAccess(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if !h.auditService.AdminAccessVisibleToOwner() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access history is not available"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	logs, total, err := h.auditService.ListAdminAccessForOwner(c.Request.Context(), userID.(uuid.UUID), limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch admin access history"})
+		return
+	}
+
+	accesses := make([]AdminAccessDTO, len(logs))
+	for i := range logs {
+		entry := &logs[i]
+		mode, _ := entry.Details["mode"].(string)
+		reason, _ := entry.Details["reason"].(string)
+		accesses[i] = AdminAccessDTO{
+			ID:        entry.ID,
+			FileID:    entry.ResourceID,
+			Filename:  entry.ResourceName,
+			Mode:      mode,
+			Reason:    reason,
+			Admin:     NewUserSummaryDTO(&entry.User),
+			CreatedAt: entry.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accesses": accesses,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -11,19 +13,27 @@ import (
 	"gorm.io/gorm"
 
 	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/events"
 	"file-vault-system/backend/internal/middleware"
 	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
 )
 
 type AuthHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db            *gorm.DB
+	cfg           *config.Config
+	samlService   *services.SAMLService
+	deviceService *services.DeviceService
+	loginHistory  *services.LoginHistoryService
 }
 
-func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, cfg *config.Config, samlService *services.SAMLService, deviceService *services.DeviceService, loginHistory *services.LoginHistoryService) *AuthHandler {
 	return &AuthHandler{
-		db:  db,
-		cfg: cfg,
+		db:            db,
+		cfg:           cfg,
+		samlService:   samlService,
+		deviceService: deviceService,
+		loginHistory:  loginHistory,
 	}
 }
 
@@ -38,24 +48,42 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+
+	// Device the token is issued to; sync clients must send all three
+	Client         string `json:"client"` // "web" (default) or "sync"
+	DeviceName     string `json:"device_name"`
+	DevicePlatform string `json:"device_platform"`
 }
 
 type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token string  `json:"token"`
+	User  UserDTO `json:"user"`
 }
 
 // Register handles user registration
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	// Accounts come from the identity provider while SSO is enforced
+	if h.samlService.Enforced() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is disabled; sign in with single sign-on"})
+		return
+	}
+
+	// Tenants may close self-service sign-up and add members themselves
+	tenant := middleware.CurrentTenant(c)
+	if tenant != nil && !tenant.AllowRegistration {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is disabled for this organization"})
 		return
 	}
 
-	// Check if user already exists
+	// Check if user already exists. Usernames and emails are unique across
+	// the whole deployment, not per tenant
 	var existingUser models.User
-	if err := h.db.Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
 	}
@@ -79,17 +107,34 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		LastName:     req.LastName,
 		StorageQuota: h.cfg.DefaultUserQuota,
 		IsActive:     true,
+		TenantID:     middleware.TenantID(c),
+	}
+
+	// New users start on their tenant's quota, or else the default plan when
+	// one is configured
+	if tenant != nil && tenant.DefaultUserQuota > 0 {
+		user.StorageQuota = tenant.DefaultUserQuota
+	} else {
+		defaultPlan, err := services.NewPlanService(h.db).DefaultPlan()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load default plan"})
+			return
+		}
+		if defaultPlan != nil {
+			user.PlanID = &defaultPlan.ID
+			user.StorageQuota = defaultPlan.StorageQuota
+		}
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Create(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
 	// Assign default user role
 	var userRole models.Role
-	if err := h.db.Where("name = ?", "user").First(&userRole).Error; err == nil {
-		h.db.Create(&models.UserRole{
+	if err := h.db.WithContext(c.Request.Context()).Where("name = ?", "user").First(&userRole).Error; err == nil {
+		h.db.WithContext(c.Request.Context()).Create(&models.UserRole{
 			ID:     uuid.New(),
 			UserID: user.ID,
 			RoleID: userRole.ID,
@@ -97,73 +142,98 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Generate JWT token
-	token, err := h.generateToken(user.ID)
+	token, err := h.issueToken(c, &user, services.DeviceInfo{}, models.LoginMethodPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Remove password hash from response
-	user.PasswordHash = ""
-
 	c.JSON(http.StatusCreated, AuthResponse{
 		Token: token,
-		User:  user,
+		User:  NewUserDTO(&user),
 	})
 }
 
 // Login handles user login
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	// Find user by email
+	// Find user by email among the members of the request's tenant
 	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Scopes(models.InTenant(middleware.TenantID(c))).
+		Where("email = ?", req.Email).First(&user).Error; err != nil {
+		h.loginFailed(c, req.Email, nil, "unknown_email", models.LoginMethodPassword)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check if user is active
 	if !user.IsActive {
+		h.loginFailed(c, req.Email, &user.ID, "account_disabled", models.LoginMethodPassword)
+		if user.LifecycleState == models.LifecycleArchived {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account was archived after a long period of inactivity; contact an administrator to restore it"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled"})
 		return
 	}
 
+	// Guest accounts stop working once they expire, even before the
+	// background pass disables them
+	if user.GuestExpired(time.Now()) {
+		h.loginFailed(c, req.Email, &user.ID, "guest_expired", models.LoginMethodPassword)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Guest account has expired"})
+		return
+	}
+
+	if !h.samlService.PasswordLoginAllowed(&user) {
+		h.loginFailed(c, req.Email, &user.ID, "sso_enforced", models.LoginMethodPassword)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password login is disabled; sign in with single sign-on"})
+		return
+	}
+
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		h.loginFailed(c, req.Email, &user.ID, "invalid_password", models.LoginMethodPassword)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Update last login
-	now := time.Now()
-	h.db.Model(&user).Update("last_login", now)
-	user.LastLogin = &now
+	// Update last login; signing in ends any inactivity warning
+	services.MarkSignedIn(h.db, &user, time.Now())
 
-	// Generate JWT token
-	token, err := h.generateToken(user.ID)
+	// Generate JWT token bound to the device logging in
+	token, err := h.issueToken(c, &user, services.DeviceInfo{
+		Name:     req.DeviceName,
+		Platform: req.DevicePlatform,
+		Client:   models.DeviceClient(req.Client),
+	}, models.LoginMethodPassword)
 	if err != nil {
+		if errors.Is(err, services.ErrSyncDeviceInfo) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Remove password hash from response
-	user.PasswordHash = ""
-
 	c.JSON(http.StatusOK, AuthResponse{
 		Token: token,
-		User:  user,
+		User:  NewUserDTO(&user),
 	})
 }
 
-// Logout handles user logout
+// Logout handles user logout by revoking the device the token was issued to
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// In a real application, you might want to blacklist the token
-	// For now, we'll just return a success message
+	if deviceID, ok := c.Get("device_id"); ok {
+		if err := h.deviceService.Revoke(c.MustGet("user_id").(uuid.UUID), deviceID.(uuid.UUID)); err != nil && !errors.Is(err, services.ErrDeviceNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
@@ -185,19 +255,78 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 
 	// Find user by ID
 	var user models.User
-	if err := h.db.Preload("Roles").Where("id = ?", userUUID).First(&user).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Preload("Roles").Where("id = ?", userUUID).First(&user).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Remove password hash from response
-	user.PasswordHash = ""
+	c.JSON(http.StatusOK, NewUserDTO(&user))
+}
+
+// loginFailed records a rejected login in the user's login history and
+// reports it to the operations feed
+func (h *AuthHandler) loginFailed(c *gin.Context, email string, userID *uuid.UUID, reason string, method models.LoginMethod) {
+	if err := h.loginHistory.Record(&models.LoginEvent{
+		UserID:        userID,
+		Email:         email,
+		FailureReason: reason,
+		Method:        method,
+		IPAddress:     middleware.StoredClientIP(c),
+		UserAgent:     c.GetHeader("User-Agent"),
+		Country:       c.GetString("client_country"),
+	}); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+	publishLoginFailed(c, email, userID, reason)
+}
+
+// publishLoginFailed reports a rejected login to the operations feed
+func publishLoginFailed(c *gin.Context, email string, userID *uuid.UUID, reason string) {
+	who := email
+	if who == "" {
+		who = "an unidentified user"
+	}
+	events.Publish(events.Event{
+		Type:      events.TypeLoginFailed,
+		Severity:  events.SeverityWarning,
+		UserID:    userID,
+		IPAddress: middleware.StoredClientIP(c),
+		Message:   "Failed login for " + who,
+		Details: map[string]interface{}{
+			"email":  email,
+			"reason": reason,
+		},
+	})
+}
+
+// issueToken registers the device signing in, records the login in the
+// user's history and creates a token bound to the device
+func (h *AuthHandler) issueToken(c *gin.Context, user *models.User, info services.DeviceInfo, method models.LoginMethod) (string, error) {
+	info.UserAgent = c.GetHeader("User-Agent")
+	info.IPAddress = middleware.StoredClientIP(c)
+	device, err := h.deviceService.Register(user.ID, info)
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.loginHistory.Record(&models.LoginEvent{
+		UserID:    &user.ID,
+		Email:     user.Email,
+		Success:   true,
+		Method:    method,
+		IPAddress: info.IPAddress,
+		UserAgent: info.UserAgent,
+		Country:   c.GetString("client_country"),
+		DeviceID:  &device.ID,
+	}); err != nil {
+		log.Printf("Failed to record login of user %s: %v", user.ID, err)
+	}
 
-	c.JSON(http.StatusOK, user)
+	return h.generateToken(user.ID, device.ID)
 }
 
 // generateToken creates a JWT token for the user
-func (h *AuthHandler) generateToken(userID uuid.UUID) (string, error) {
+func (h *AuthHandler) generateToken(userID, deviceID uuid.UUID) (string, error) {
 	// Get user roles for the token
 	var user models.User
 	var roles []string
@@ -207,6 +336,12 @@ func (h *AuthHandler) generateToken(userID uuid.UUID) (string, error) {
 		}
 	}
 
+	// A guest's token never outlives the guest account
+	expiresAt := time.Now().Add(time.Duration(h.cfg.JWTExpiration) * time.Hour)
+	if user.Role == models.RoleGuest && user.GuestExpiresAt != nil && user.GuestExpiresAt.Before(expiresAt) {
+		expiresAt = *user.GuestExpiresAt
+	}
+
 	// Create claims
 	claims := &middleware.JWTClaims{
 		UserID:   userID,
@@ -214,12 +349,13 @@ func (h *AuthHandler) generateToken(userID uuid.UUID) (string, error) {
 		Email:    user.Email,
 		Role:     string(user.Role), // Set the simple role field
 		Roles:    roles,
+		DeviceID: deviceID,
+		TenantID: user.TenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(h.cfg.JWTExpiration) * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.cfg.JWTSecret))
+	return middleware.SignJWT(claims)
 }
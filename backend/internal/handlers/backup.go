@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type BackupHandler struct {
+	backupService *services.BackupService
+}
+
+func NewBackupHandler(backupService *services.BackupService) *BackupHandler {
+	return &BackupHandler{
+		backupService: backupService,
+	}
+}
+
+// StartBackup triggers a new backup job (admin only)
+// POST /api/v1/admin/backups
+func (h *BackupHandler) StartBackup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	mode := models.BackupModeFull
+	switch req.Mode {
+	case "", string(models.BackupModeFull):
+	case string(models.BackupModeIncremental):
+		mode = models.BackupModeIncremental
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be 'full' or 'incremental'"})
+		return
+	}
+
+	job, err := h.backupService.StartBackup(mode, userID.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, services.ErrBackupInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A backup is already in progress"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start backup"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Backup started",
+		"backup":  job,
+	})
+}
+
+// ListBackups returns recent backup jobs (admin only)
+// GET /api/v1/admin/backups
+func (h *BackupHandler) ListBackups(c *gin.Context) {
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	jobs, err := h.backupService.ListBackups(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch backups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backups": jobs})
+}
+
+// GetBackup returns a single backup job (admin only)
+// GET /api/v1/admin/backups/:id
+func (h *BackupHandler) GetBackup(c *gin.Context) {
+	backupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid backup ID"})
+		return
+	}
+
+	job, err := h.backupService.GetBackup(backupID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch backup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
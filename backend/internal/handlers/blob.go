@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// respondStreamError answers a file whose content could not be opened for
+// streaming
+func respondStreamError(c *gin.Context, err error) {
+	var archived *services.BlobArchivedError
+	switch {
+	case errors.As(err, &archived):
+		c.JSON(http.StatusConflict, archivedResponse(archived.Tier))
+	case errors.Is(err, services.ErrBlobMissing):
+		c.Error(apperrors.ErrNotFound.WithCode("FILE_CONTENT_MISSING", "File not found on disk").Wrap(err))
+	default:
+		c.Error(apperrors.Internal(err))
+	}
+}
+
+// isContinuationRange reports whether a request asks for a byte range that
+// does not start at the beginning of the file, as the later segments of a
+// segmented download do
+func isContinuationRange(c *gin.Context) bool {
+	rangeHeader := c.GetHeader("Range")
+	return rangeHeader != "" && !strings.HasPrefix(rangeHeader, "bytes=0-")
+}
+
+// respondIfArchived rejects reads of blobs that currently live in cold
+// storage and reports whether a response was written
+func respondIfArchived(c *gin.Context, fileHash *models.FileHash) bool {
+	if fileHash.StorageTier == "" || fileHash.StorageTier == models.StorageTierHot {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, archivedResponse(fileHash.StorageTier))
+	return true
+}
+
+// archivedResponse describes a read rejected because the content is in cold
+// storage
+func archivedResponse(tier models.StorageTier) gin.H {
+	return gin.H{
+		"error":        "File is archived",
+		"type":         "FILE_ARCHIVED",
+		"message":      "This file has been moved to cold storage and must be restored before it can be accessed",
+		"storage_tier": tier,
+		"code":         "FILE_ARCHIVED",
+	}
+}
+
+// respondIfQuarantined rejects reads of files locked pending quarantine
+// review and reports whether a response was written
+func respondIfQuarantined(c *gin.Context, file *models.File) bool {
+	if !file.IsQuarantined {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "File is quarantined",
+		"type":    "FILE_QUARANTINED",
+		"message": "This file has been quarantined pending review by an administrator",
+		"code":    "FILE_QUARANTINED",
+	})
+	return true
+}
+
+// respondIfUnscanned rejects reads of files that have not been scanned clean
+// while strict downloads are on and reports whether a response was written
+func respondIfUnscanned(c *gin.Context, streams *services.FileStreamService, file *models.File) bool {
+	if err := streams.CheckScanned(file); err != nil {
+		c.Error(err)
+		return true
+	}
+	return false
+}
+
+// respondIfEncrypted rejects server-side processing of end-to-end encrypted
+// content, which the server cannot read, and reports whether a response was
+// written
+func respondIfEncrypted(c *gin.Context, file *models.File) bool {
+	if !file.IsEncrypted {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "File is end-to-end encrypted",
+		"type":    "FILE_ENCRYPTED",
+		"message": "This file is encrypted by its owner's devices and must be downloaded and decrypted to be viewed",
+		"code":    "FILE_ENCRYPTED",
+	})
+	return true
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// CleanupRuleHandler manages the current user's cleanup rules, which move
+// their old files to the trash on a schedule
+type CleanupRuleHandler struct {
+	db                 *gorm.DB
+	cleanupRuleService *services.CleanupRuleService
+}
+
+func NewCleanupRuleHandler(db *gorm.DB, cleanupRuleService *services.CleanupRuleService) *CleanupRuleHandler {
+	return &CleanupRuleHandler{db: db, cleanupRuleService: cleanupRuleService}
+}
+
+// cleanupRuleRequest creates or replaces a cleanup rule. A new rule is
+// active unless is_active is false; an update leaves it as it is
+type cleanupRuleRequest struct {
+	Name              string     `json:"name" binding:"required,max=100"`
+	FolderID          *uuid.UUID `json:"folder_id"`
+	IncludeSubfolders bool       `json:"include_subfolders"`
+	NamePattern       string     `json:"name_pattern" binding:"max=255"`
+	MimeType          string     `json:"mime_type" binding:"max=100"`
+	Tag               string     `json:"tag" binding:"max=50"`
+	OlderThanDays     int        `json:"older_than_days" binding:"required,min=1,max=3650"`
+	IsActive          *bool      `json:"is_active"`
+}
+
+// rule returns the cleanup rule a request describes, active unless it says
+// otherwise
+func (r *cleanupRuleRequest) rule(isActive bool) models.CleanupRule {
+	if r.IsActive != nil {
+		isActive = *r.IsActive
+	}
+	return models.CleanupRule{
+		Name:              r.Name,
+		FolderID:          r.FolderID,
+		IncludeSubfolders: r.IncludeSubfolders,
+		NamePattern:       r.NamePattern,
+		MimeType:          r.MimeType,
+		Tag:               r.Tag,
+		OlderThanDays:     r.OlderThanDays,
+		IsActive:          isActive,
+	}
+}
+
+// cleanupRuleID parses the rule ID of the request path
+func cleanupRuleID(c *gin.Context) (uuid.UUID, bool) {
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(services.ErrCleanupRuleNotFound)
+		return uuid.Nil, false
+	}
+	return ruleID, true
+}
+
+// folderPaths returns the paths of the user's folders that the rules cover
+func (h *CleanupRuleHandler) folderPaths(c *gin.Context, userID uuid.UUID, rules []models.CleanupRule) (map[uuid.UUID]string, error) {
+	ids := make([]uuid.UUID, 0, len(rules))
+	for _, rule := range rules {
+		if rule.FolderID != nil {
+			ids = append(ids, *rule.FolderID)
+		}
+	}
+	paths := make(map[uuid.UUID]string, len(ids))
+	if len(ids) == 0 {
+		return paths, nil
+	}
+	var folders []models.Folder
+	if err := h.db.WithContext(c.Request.Context()).Select("id", "path").
+		Where("id IN ? AND owner_id = ?", ids, userID).Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	for _, folder := range folders {
+		paths[folder.ID] = folder.Path
+	}
+	return paths, nil
+}
+
+// respondCleanupRule writes a cleanup rule with the path of its folder
+func (h *CleanupRuleHandler) respondCleanupRule(c *gin.Context, status int, userID uuid.UUID, rule *models.CleanupRule) {
+	paths, err := h.folderPaths(c, userID, []models.CleanupRule{*rule})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var path string
+	if rule.FolderID != nil {
+		path = paths[*rule.FolderID]
+	}
+	c.JSON(status, NewCleanupRuleDTO(rule, path))
+}
+
+// ListCleanupRules returns the current user's cleanup rules, oldest first
+// GET /api/v1/me/cleanup-rules
+func (h *CleanupRuleHandler) ListCleanupRules(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	rules, err := h.cleanupRuleService.List(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	paths, err := h.folderPaths(c, userID, rules)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	dtos := make([]CleanupRuleDTO, len(rules))
+	for i := range rules {
+		var path string
+		if rules[i].FolderID != nil {
+			path = paths[*rules[i].FolderID]
+		}
+		dtos[i] = NewCleanupRuleDTO(&rules[i], path)
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": dtos})
+}
+
+// CreateCleanupRule adds a cleanup rule. Files in the rule's folder (the
+// root folder when folder_id is null), and below it with
+// include_subfolders, that were uploaded more than older_than_days ago and
+// match name_pattern, mime_type and tag are moved to the trash on every
+// cleanup pass. Create a rule with is_active false to preview it first
+// POST /api/v1/me/cleanup-rules
+func (h *CleanupRuleHandler) CreateCleanupRule(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req cleanupRuleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rule, err := h.cleanupRuleService.Create(userID, req.rule(true))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondCleanupRule(c, http.StatusCreated, userID, rule)
+}
+
+// UpdateCleanupRule replaces what a cleanup rule matches, and turns it on or
+// off when is_active is given
+// PUT /api/v1/me/cleanup-rules/:id
+func (h *CleanupRuleHandler) UpdateCleanupRule(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	ruleID, ok := cleanupRuleID(c)
+	if !ok {
+		return
+	}
+
+	var req cleanupRuleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	existing, err := h.cleanupRuleService.Get(userID, ruleID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	rule, err := h.cleanupRuleService.Update(userID, ruleID, req.rule(existing.IsActive))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondCleanupRule(c, http.StatusOK, userID, rule)
+}
+
+// DeleteCleanupRule removes a cleanup rule. Files it already moved to the
+// trash stay there
+// DELETE /api/v1/me/cleanup-rules/:id
+func (h *CleanupRuleHandler) DeleteCleanupRule(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	ruleID, ok := cleanupRuleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.cleanupRuleService.Delete(userID, ruleID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cleanup rule deleted"})
+}
+
+// PreviewCleanupRule is a dry run of a cleanup rule, active or not: it
+// returns the files the rule would move to the trash if it ran now, oldest
+// first, without changing anything
+// GET /api/v1/me/cleanup-rules/:id/preview
+func (h *CleanupRuleHandler) PreviewCleanupRule(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	ruleID, ok := cleanupRuleID(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.cleanupRuleService.Get(userID, ruleID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	preview, err := h.cleanupRuleService.Preview(rule)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, CleanupPreviewDTO{
+		RuleID:     rule.ID,
+		Cutoff:     preview.Cutoff,
+		TotalFiles: preview.TotalFiles,
+		TotalBytes: preview.TotalBytes,
+		Files:      NewFileDTOs(preview.Files),
+	})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"file-vault-system/backend/internal/services"
+)
+
+type ContentIndexHandler struct {
+	contentIndexService *services.ContentIndexService
+}
+
+func NewContentIndexHandler(contentIndexService *services.ContentIndexService) *ContentIndexHandler {
+	return &ContentIndexHandler{contentIndexService: contentIndexService}
+}
+
+// GetSummary reports how many blobs are pending, indexed, failed or unsupported (admin only)
+// GET /api/v1/admin/content-index
+func (h *ContentIndexHandler) GetSummary(c *gin.Context) {
+	summary, err := h.contentIndexService.Summary()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get content index status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// Backfill queues every blob uploaded before indexing was enabled (admin only)
+// POST /api/v1/admin/content-index/backfill
+func (h *ContentIndexHandler) Backfill(c *gin.Context) {
+	if !h.contentIndexService.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Content indexing is not enabled"})
+		return
+	}
+
+	queued, err := h.contentIndexService.Backfill()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue blobs for indexing"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Blobs queued for indexing",
+		"queued":  queued,
+	})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"expvar"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var debugStartTime = time.Now()
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("uptime_seconds", expvar.Func(func() interface{} {
+		return int64(time.Since(debugStartTime).Seconds())
+	}))
+}
+
+// RegisterDebugRoutes mounts the pprof profiles and expvar runtime variables
+// on a group that must already require admin access
+// GET /debug/vars
+// GET /debug/pprof/
+func RegisterDebugRoutes(debug *gin.RouterGroup) {
+	debug.GET("/vars", gin.WrapH(expvar.Handler()))
+
+	debug.GET("/pprof/", gin.WrapF(pprof.Index))
+	debug.GET("/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/pprof/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/pprof/trace", gin.WrapF(pprof.Trace))
+
+	// Named runtime profiles: heap, goroutine, allocs, block, mutex, threadcreate
+	debug.GET("/pprof/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+}
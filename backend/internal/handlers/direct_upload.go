@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// createDirectUploadRequest describes a file the client uploads to the
+// object store itself
+type createDirectUploadRequest struct {
+	Filename         string     `json:"filename" binding:"required,max=255"`
+	MimeType         string     `json:"mime_type" binding:"max=100"`
+	Size             int64      `json:"size" binding:"min=0"`
+	SHA256           string     `json:"sha256" binding:"required,len=64,hexadecimal"`
+	FolderID         *uuid.UUID `json:"folder_id"`
+	IsPublic         bool       `json:"is_public"`
+	EncryptionHeader string     `json:"encryption_header" binding:"max=8192"`
+}
+
+// CreateDirectUpload starts an upload the client sends straight to the
+// object store, so large files never pass through the server. The response
+// carries a presigned URL to PUT the content to, with headers the PUT must
+// carry; the store refuses content of another size or SHA-256. The same
+// checks are made up front as for POST /api/v1/files/uploads. Only available
+// with object storage
+// POST /api/v1/files/direct-uploads
+func (h *FileHandler) CreateDirectUpload(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req createDirectUploadRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !h.cfg.IsObjectStorage() {
+		c.Error(services.ErrDirectUploadsUnavailable)
+		return
+	}
+	check := createUploadSessionRequest{
+		Filename:         req.Filename,
+		MimeType:         req.MimeType,
+		Size:             req.Size,
+		FolderID:         req.FolderID,
+		IsPublic:         req.IsPublic,
+		EncryptionHeader: req.EncryptionHeader,
+	}
+	if !h.checkResumableUpload(c, userID, &check) {
+		return
+	}
+
+	upload, uploadURL, uploadHeader, err := h.directUploadService.Create(services.CreateDirectUploadParams{
+		OwnerID:          userID,
+		FolderID:         check.FolderID,
+		Filename:         check.Filename,
+		MimeType:         check.MimeType,
+		Size:             check.Size,
+		SHA256:           strings.ToLower(req.SHA256),
+		IsPublic:         check.IsPublic,
+		EncryptionHeader: check.EncryptionHeader,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"upload": NewDirectUploadDTO(upload, uploadURL, uploadHeader)})
+}
+
+// CompleteDirectUpload finalizes a direct upload once its content is in the
+// object store. The content is checked against the declared size and
+// SHA-256, then validated and stored as a regular upload of the file would
+// be, without passing through the server unless a scanner needs it on disk.
+// The response is that of a regular upload. The upload is kept when the file
+// is refused, so it can be finalized again once the cause is resolved
+// POST /api/v1/files/direct-uploads/:id/complete
+func (h *FileHandler) CompleteDirectUpload(c *gin.Context) {
+	upload, ok := h.directUpload(c)
+	if !ok {
+		return
+	}
+
+	h.storeUpload(c, upload.OwnerID, assembledUpload{
+		filename:         upload.Filename,
+		mimeType:         upload.MimeType,
+		size:             upload.Size,
+		folderID:         upload.FolderID,
+		isPublic:         upload.IsPublic,
+		encryptionHeader: upload.EncryptionHeader,
+		direct:           upload,
+	}.request())
+}
+
+// DeleteDirectUpload discards a direct upload and any content sent for it
+// DELETE /api/v1/files/direct-uploads/:id
+func (h *FileHandler) DeleteDirectUpload(c *gin.Context) {
+	upload, ok := h.directUpload(c)
+	if !ok {
+		return
+	}
+
+	if err := h.directUploadService.Delete(upload); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload discarded"})
+}
+
+// directUpload loads the current user's direct upload named by the :id parameter
+func (h *FileHandler) directUpload(c *gin.Context) (*models.DirectUpload, bool) {
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(services.ErrDirectUploadNotFound)
+		return nil, false
+	}
+
+	upload, err := h.directUploadService.Get(c.MustGet("user_id").(uuid.UUID), uploadID)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return upload, true
+}
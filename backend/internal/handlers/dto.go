@@ -0,0 +1,1164 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+	"file-vault-system/backend/pkg/dlp"
+)
+
+// Response DTOs give every resource the same snake_case JSON shape. Models
+// carry GORM concerns and a mix of casings, so handlers map them through
+// these types instead of serialising models directly.
+
+// UserDTO is a full user record, returned to admins and the user themselves
+type UserDTO struct {
+	ID                 uuid.UUID             `json:"id"`
+	Username           string                `json:"username"`
+	Email              string                `json:"email"`
+	FirstName          string                `json:"first_name"`
+	LastName           string                `json:"last_name"`
+	Role               models.UserRoleType   `json:"role"`
+	StorageQuota       int64                 `json:"storage_quota"`
+	StorageUsed        int64                 `json:"storage_used"`
+	PlanID             *uuid.UUID            `json:"plan_id,omitempty"`
+	TenantID           *uuid.UUID            `json:"tenant_id,omitempty"`
+	TotalUploadedBytes int64                 `json:"total_uploaded_bytes"`
+	ActualStorageBytes int64                 `json:"actual_storage_bytes"`
+	SavedBytes         int64                 `json:"saved_bytes"`
+	IsActive           bool                  `json:"is_active"`
+	EmailVerified      bool                  `json:"email_verified"`
+	LastLogin          *time.Time            `json:"last_login,omitempty"`
+	QuotaGraceExpires  *time.Time            `json:"quota_grace_expires_at,omitempty"`
+	QuotaGraceRevoked  *time.Time            `json:"quota_grace_revoked_at,omitempty"`
+	LifecycleState     models.LifecycleState `json:"lifecycle_state"`
+	LifecycleChangedAt *time.Time            `json:"lifecycle_changed_at,omitempty"`
+	AutoTaggingEnabled bool                  `json:"auto_tagging_enabled"`
+	GuestExpiresAt     *time.Time            `json:"guest_expires_at,omitempty"`
+	InvitedBy          *uuid.UUID            `json:"invited_by,omitempty"`
+	CreatedAt          time.Time             `json:"created_at"`
+	UpdatedAt          time.Time             `json:"updated_at"`
+}
+
+// UserSummaryDTO identifies a user embedded in another resource
+type UserSummaryDTO struct {
+	ID        uuid.UUID           `json:"id"`
+	Username  string              `json:"username"`
+	Email     string              `json:"email"`
+	FirstName string              `json:"first_name"`
+	LastName  string              `json:"last_name"`
+	Role      models.UserRoleType `json:"role,omitempty"`
+}
+
+// FolderSummaryDTO identifies a folder embedded in another resource
+type FolderSummaryDTO struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Path string    `json:"path"`
+}
+
+// FolderDTO is a folder with its optional relationships
+type FolderDTO struct {
+	ID            uuid.UUID         `json:"id"`
+	Name          string            `json:"name"`
+	ParentID      *uuid.UUID        `json:"parent_id,omitempty"`
+	OwnerID       uuid.UUID         `json:"owner_id"`
+	Path          string            `json:"path"`
+	IsWORM        bool              `json:"is_worm"`
+	RetentionDays int               `json:"retention_days"`
+	WORMEnabledAt *time.Time        `json:"worm_enabled_at,omitempty"`
+	SizeLimit     *int64            `json:"size_limit,omitempty"`
+	Encrypted     bool              `json:"encrypted"`          // files in it are end-to-end encrypted
+	VaultID       *uuid.UUID        `json:"vault_id,omitempty"` // top encrypted folder, which holds the keys
+	Stats         *FolderStatsDTO   `json:"stats,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Parent        *FolderSummaryDTO `json:"parent,omitempty"`
+	Owner         *UserSummaryDTO   `json:"owner,omitempty"`
+	Children      []FolderDTO       `json:"children"`
+	Files         []FileDTO         `json:"files"`
+}
+
+// FolderStatsDTO is the size and contents of a folder's subtree
+type FolderStatsDTO struct {
+	FileCount    int64     `json:"file_count"`
+	TotalSize    int64     `json:"total_size"`
+	ChildCount   int64     `json:"child_count"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// FileDTO is a file with its owner and folder
+type FileDTO struct {
+	ID               uuid.UUID               `json:"id"`
+	Filename         string                  `json:"filename"`
+	OriginalFilename string                  `json:"original_filename"`
+	MimeType         string                  `json:"mime_type"`
+	Size             int64                   `json:"size"`
+	FileHashID       uuid.UUID               `json:"file_hash_id"`
+	OwnerID          uuid.UUID               `json:"owner_id"`
+	FolderID         *uuid.UUID              `json:"folder_id,omitempty"`
+	Tags             []string                `json:"tags"`
+	AutoTags         []string                `json:"auto_tags"` // applied by the classifier
+	Description      string                  `json:"description"`
+	IsPublic         bool                    `json:"is_public"`
+	StorageTier      models.StorageTier      `json:"storage_tier"`
+	IsQuarantined    bool                    `json:"is_quarantined"`
+	ProcessingStatus models.ProcessingStatus `json:"processing_status"`
+	NotifyOnDownload bool                    `json:"notify_on_download"`
+	SHA256           string                  `json:"sha256,omitempty"`
+	WORMLockedAt     *time.Time              `json:"worm_locked_at,omitempty"`
+	RetainUntil      *time.Time              `json:"retain_until,omitempty"`
+	IsEncrypted      bool                    `json:"is_encrypted"`
+	EncryptionHeader string                  `json:"encryption_header,omitempty"` // client's per-file key material, opaque to the server
+	ShareCount       int                     `json:"share_count"`
+	IsShared         bool                    `json:"is_shared"`
+	CreatedAt        time.Time               `json:"created_at"`
+	UpdatedAt        time.Time               `json:"updated_at"`
+	Owner            *UserSummaryDTO         `json:"owner,omitempty"`
+	Folder           *FolderSummaryDTO       `json:"folder,omitempty"`
+}
+
+// SearchMatchDTO is a field a search result matched. Snippet is HTML-escaped
+// with the matched text wrapped in <mark>.
+type SearchMatchDTO struct {
+	Field   string `json:"field"` // name, description, content or tag
+	Snippet string `json:"snippet"`
+}
+
+// SearchResultDTO is a file found by search with the fields it matched
+type SearchResultDTO struct {
+	FileDTO
+	Matches []SearchMatchDTO `json:"matches"`
+}
+
+// ShareDTO is a file shared directly with another user
+type ShareDTO struct {
+	ID             uuid.UUID              `json:"id"`
+	FileID         uuid.UUID              `json:"file_id"`
+	SharedBy       uuid.UUID              `json:"shared_by"`
+	SharedWith     uuid.UUID              `json:"shared_with"`
+	Permission     models.SharePermission `json:"permission"`
+	Message        string                 `json:"message"`
+	ExpiresAt      *time.Time             `json:"expires_at,omitempty"`
+	IsActive       bool                   `json:"is_active"`
+	Status         models.ShareStatus     `json:"status"`
+	RespondedAt    *time.Time             `json:"responded_at,omitempty"`
+	Hidden         bool                   `json:"hidden"`
+	CreatedAt      time.Time              `json:"created_at"`
+	File           *FileDTO               `json:"file,omitempty"`
+	SharedByUser   *UserSummaryDTO        `json:"shared_by_user,omitempty"`
+	SharedWithUser *UserSummaryDTO        `json:"shared_with_user,omitempty"`
+}
+
+// ShareLinkDTO is a public link to a file
+type ShareLinkDTO struct {
+	ID               uuid.UUID              `json:"id"`
+	FileID           uuid.UUID              `json:"file_id"`
+	CreatedBy        uuid.UUID              `json:"created_by"`
+	ShareToken       string                 `json:"share_token"`
+	Permission       models.SharePermission `json:"permission"`
+	HasPassword      bool                   `json:"has_password"`
+	MaxDownloads     *int                   `json:"max_downloads,omitempty"`
+	DownloadCount    int                    `json:"download_count"`
+	ExpiresAt        *time.Time             `json:"expires_at,omitempty"`
+	IsActive         bool                   `json:"is_active"`
+	LastAccessedAt   *time.Time             `json:"last_accessed_at,omitempty"`
+	NotifyOnDownload bool                   `json:"notify_on_download"`
+	Watermark        bool                   `json:"watermark"`
+	WatermarkText    string                 `json:"watermark_text,omitempty"`
+	WarmStatus       models.WarmStatus      `json:"warm_status,omitempty"`
+	WarmedAt         *time.Time             `json:"warmed_at,omitempty"`
+	WarmError        string                 `json:"warm_error,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	File             *FileDTO               `json:"file,omitempty"`
+}
+
+// FolderShareDTO is a folder shared directly with another user
+type FolderShareDTO struct {
+	ID             uuid.UUID              `json:"id"`
+	FolderID       uuid.UUID              `json:"folder_id"`
+	SharedBy       uuid.UUID              `json:"shared_by"`
+	SharedWith     uuid.UUID              `json:"shared_with"`
+	Permission     models.SharePermission `json:"permission"`
+	Message        string                 `json:"message"`
+	Status         models.ShareStatus     `json:"status"`
+	RespondedAt    *time.Time             `json:"responded_at,omitempty"`
+	Hidden         bool                   `json:"hidden"`
+	CreatedAt      time.Time              `json:"created_at"`
+	Folder         *FolderSummaryDTO      `json:"folder,omitempty"`
+	SharedByUser   *UserSummaryDTO        `json:"shared_by_user,omitempty"`
+	SharedWithUser *UserSummaryDTO        `json:"shared_with_user,omitempty"`
+}
+
+// FolderShareLinkDTO is a public link to a folder
+type FolderShareLinkDTO struct {
+	ID            uuid.UUID                  `json:"id"`
+	FolderID      uuid.UUID                  `json:"folder_id"`
+	CreatedBy     uuid.UUID                  `json:"created_by"`
+	Token         string                     `json:"token"`
+	Permission    models.SharePermission     `json:"permission"`
+	Mode          models.FolderShareLinkMode `json:"mode"`
+	HasPassword   bool                       `json:"has_password"`
+	MaxDownloads  *int                       `json:"max_downloads,omitempty"`
+	DownloadCount int                        `json:"download_count"`
+	ExpiresAt     *time.Time                 `json:"expires_at,omitempty"`
+	IsActive      bool                       `json:"is_active"`
+	CreatedAt     time.Time                  `json:"created_at"`
+	Folder        *FolderSummaryDTO          `json:"folder,omitempty"`
+}
+
+// DownloadStatDTO is a single recorded download
+type DownloadStatDTO struct {
+	ID           uuid.UUID       `json:"id"`
+	FileID       uuid.UUID       `json:"file_id"`
+	DownloadedBy *uuid.UUID      `json:"downloaded_by,omitempty"`
+	SharedLinkID *uuid.UUID      `json:"shared_link_id,omitempty"`
+	IPAddress    string          `json:"ip_address"`
+	UserAgent    string          `json:"user_agent"`
+	DownloadSize int64           `json:"download_size"`
+	DownloadedAt time.Time       `json:"downloaded_at"`
+	User         *UserSummaryDTO `json:"user,omitempty"`
+}
+
+// FileWithStatsDTO is a file with its download statistics, used by admin listings
+type FileWithStatsDTO struct {
+	FileDTO
+	DownloadCount     int64      `json:"download_count"`
+	UniqueDownloaders int64      `json:"unique_downloaders"`
+	LastDownload      *time.Time `json:"last_download"`
+}
+
+// AuditLogDTO is an audit log entry with the acting user
+type AuditLogDTO struct {
+	ID           uuid.UUID                   `json:"id"`
+	UserID       uuid.UUID                   `json:"user_id"`
+	Action       models.AuditLogAction       `json:"action"`
+	ResourceType models.AuditLogResourceType `json:"resource_type"`
+	ResourceID   *uuid.UUID                  `json:"resource_id,omitempty"`
+	ResourceName *string                     `json:"resource_name,omitempty"`
+	Details      models.AuditLogDetails      `json:"details,omitempty"`
+	IPAddress    *string                     `json:"ip_address,omitempty"`
+	UserAgent    *string                     `json:"user_agent,omitempty"`
+	Status       models.AuditLogStatus       `json:"status"`
+	CreatedAt    time.Time                   `json:"created_at"`
+	Sequence     *int64                      `json:"sequence,omitempty"`
+	PrevHash     string                      `json:"prev_hash,omitempty"`
+	Hash         string                      `json:"hash,omitempty"`
+	User         *UserSummaryDTO             `json:"user,omitempty"`
+}
+
+// QuarantineDTO is an entry in the quarantine review queue
+type QuarantineDTO struct {
+	ID         uuid.UUID                 `json:"id"`
+	FileID     uuid.UUID                 `json:"file_id"`
+	OwnerID    uuid.UUID                 `json:"owner_id"`
+	Source     models.QuarantineSource   `json:"source"`
+	Reason     string                    `json:"reason"`
+	Findings   models.QuarantineFindings `json:"findings"`
+	Status     models.QuarantineStatus   `json:"status"`
+	ReviewedBy *uuid.UUID                `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time                `json:"reviewed_at,omitempty"`
+	ReviewNote string                    `json:"review_note,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	File       *FileDTO                  `json:"file,omitempty"`
+	Owner      *UserSummaryDTO           `json:"owner,omitempty"`
+}
+
+// ActivityDTO is an entry of a user's activity timeline. Type tells the
+// client how to render it; user is the other person involved, if any.
+type ActivityDTO struct {
+	ID           uuid.UUID              `json:"id"`
+	Type         services.ActivityType  `json:"type"`
+	OccurredAt   time.Time              `json:"occurred_at"`
+	Action       string                 `json:"action"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   *uuid.UUID             `json:"resource_id,omitempty"`
+	ResourceName string                 `json:"resource_name"`
+	User         *UserSummaryDTO        `json:"user,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+}
+
+// FileEventDTO is an entry in a file's history; user is who made the change,
+// absent for changes made by the system
+type FileEventDTO struct {
+	ID        uuid.UUID               `json:"id"`
+	Sequence  int64                   `json:"sequence"`
+	FileID    uuid.UUID               `json:"file_id"`
+	Type      models.FileEventType    `json:"type"`
+	Payload   models.FileEventPayload `json:"payload,omitempty"`
+	ActorID   *uuid.UUID              `json:"actor_id,omitempty"`
+	User      *UserSummaryDTO         `json:"user,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// UploadRejectionDTO is a refused upload with the user who attempted it
+type UploadRejectionDTO struct {
+	ID               uuid.UUID                    `json:"id"`
+	UserID           uuid.UUID                    `json:"user_id"`
+	Reason           models.UploadRejectionReason `json:"reason"`
+	Code             string                       `json:"code,omitempty"`
+	Message          string                       `json:"message"`
+	Filename         string                       `json:"filename"`
+	DeclaredMimeType string                       `json:"declared_mime_type,omitempty"`
+	DetectedMimeType string                       `json:"detected_mime_type,omitempty"`
+	Size             int64                        `json:"size"`
+	IPAddress        string                       `json:"ip_address"`
+	UserAgent        string                       `json:"user_agent"`
+	CreatedAt        time.Time                    `json:"created_at"`
+	User             *UserSummaryDTO              `json:"user,omitempty"`
+}
+
+// DeviceDTO is a client the user is signed in from
+type DeviceDTO struct {
+	ID         uuid.UUID           `json:"id"`
+	Name       string              `json:"name"`
+	Platform   string              `json:"platform,omitempty"`
+	Client     models.DeviceClient `json:"client"`
+	UserAgent  string              `json:"user_agent,omitempty"`
+	LastIP     string              `json:"last_ip"`
+	LastSeenAt time.Time           `json:"last_seen_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+	Current    bool                `json:"current"` // the device making this request
+}
+
+// NewUserDTO maps a user to its full response shape
+func NewUserDTO(user *models.User) UserDTO {
+	return UserDTO{
+		ID:                 user.ID,
+		Username:           user.Username,
+		Email:              user.Email,
+		FirstName:          user.FirstName,
+		LastName:           user.LastName,
+		Role:               user.Role,
+		StorageQuota:       user.StorageQuota,
+		StorageUsed:        user.StorageUsed,
+		PlanID:             user.PlanID,
+		TenantID:           user.TenantID,
+		TotalUploadedBytes: user.TotalUploadedBytes,
+		ActualStorageBytes: user.ActualStorageBytes,
+		SavedBytes:         user.SavedBytes,
+		IsActive:           user.IsActive,
+		EmailVerified:      user.EmailVerified,
+		LastLogin:          user.LastLogin,
+		QuotaGraceExpires:  user.QuotaGraceExpiresAt,
+		QuotaGraceRevoked:  user.QuotaGraceRevokedAt,
+		LifecycleState:     user.LifecycleState,
+		LifecycleChangedAt: user.LifecycleChangedAt,
+		AutoTaggingEnabled: user.AutoTaggingEnabled,
+		GuestExpiresAt:     user.GuestExpiresAt,
+		InvitedBy:          user.InvitedBy,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+	}
+}
+
+// NewUserDTOs maps a list of users
+func NewUserDTOs(users []models.User) []UserDTO {
+	result := make([]UserDTO, len(users))
+	for i := range users {
+		result[i] = NewUserDTO(&users[i])
+	}
+	return result
+}
+
+// NewUserSummaryDTO maps an embedded user, returning nil when the
+// relationship was not loaded
+func NewUserSummaryDTO(user *models.User) *UserSummaryDTO {
+	if user == nil || user.ID == uuid.Nil {
+		return nil
+	}
+	return &UserSummaryDTO{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Role:      user.Role,
+	}
+}
+
+// NewFolderSummaryDTO maps an embedded folder, returning nil when the
+// relationship was not loaded
+func NewFolderSummaryDTO(folder *models.Folder) *FolderSummaryDTO {
+	if folder == nil || folder.ID == uuid.Nil {
+		return nil
+	}
+	return &FolderSummaryDTO{
+		ID:   folder.ID,
+		Name: folder.Name,
+		Path: folder.Path,
+	}
+}
+
+// NewFolderDTO maps a folder and whichever relationships were loaded
+func NewFolderDTO(folder *models.Folder) FolderDTO {
+	dto := FolderDTO{
+		ID:            folder.ID,
+		Name:          folder.Name,
+		ParentID:      folder.ParentID,
+		OwnerID:       folder.OwnerID,
+		Path:          folder.Path,
+		IsWORM:        folder.IsWORM,
+		RetentionDays: folder.RetentionDays,
+		WORMEnabledAt: folder.WORMEnabledAt,
+		SizeLimit:     folder.SizeLimit,
+		Encrypted:     folder.IsEncrypted(),
+		VaultID:       folder.VaultID,
+		CreatedAt:     folder.CreatedAt,
+		UpdatedAt:     folder.UpdatedAt,
+		Parent:        NewFolderSummaryDTO(folder.Parent),
+		Owner:         NewUserSummaryDTO(&folder.Owner),
+		Stats:         NewFolderStatsDTO(folder.Stats),
+	}
+	if folder.Children != nil {
+		dto.Children = NewFolderDTOs(folder.Children)
+	}
+	if folder.Files != nil {
+		dto.Files = NewFileDTOs(folder.Files)
+	}
+	return dto
+}
+
+// NewFolderStatsDTO maps a folder's running totals if they were loaded
+func NewFolderStatsDTO(stats *models.FolderStats) *FolderStatsDTO {
+	if stats == nil {
+		return nil
+	}
+	return &FolderStatsDTO{
+		FileCount:    stats.FileCount,
+		TotalSize:    stats.TotalSize,
+		ChildCount:   stats.ChildCount,
+		LastModified: stats.LastModified,
+	}
+}
+
+// NewFolderDTOs maps a list of folders
+func NewFolderDTOs(folders []models.Folder) []FolderDTO {
+	result := make([]FolderDTO, len(folders))
+	for i := range folders {
+		result[i] = NewFolderDTO(&folders[i])
+	}
+	return result
+}
+
+// NewFileDTO maps a file with its owner and folder if they were loaded
+func NewFileDTO(file *models.File) FileDTO {
+	return FileDTO{
+		ID:               file.ID,
+		Filename:         file.Filename,
+		OriginalFilename: file.OriginalFilename,
+		MimeType:         file.MimeType,
+		Size:             file.Size,
+		FileHashID:       file.FileHashID,
+		OwnerID:          file.OwnerID,
+		FolderID:         file.FolderID,
+		Tags:             file.Tags,
+		AutoTags:         file.AutoTags,
+		Description:      file.Description,
+		IsPublic:         file.IsPublic,
+		StorageTier:      file.StorageTier,
+		IsQuarantined:    file.IsQuarantined,
+		ProcessingStatus: file.ProcessingStatus,
+		NotifyOnDownload: file.NotifyOnDownload,
+		SHA256:           file.SHA256,
+		WORMLockedAt:     file.WORMLockedAt,
+		RetainUntil:      file.RetainUntil,
+		IsEncrypted:      file.IsEncrypted,
+		EncryptionHeader: file.EncryptionHeader,
+		ShareCount:       file.ShareCount,
+		IsShared:         file.IsShared,
+		CreatedAt:        file.CreatedAt,
+		UpdatedAt:        file.UpdatedAt,
+		Owner:            NewUserSummaryDTO(&file.Owner),
+		Folder:           NewFolderSummaryDTO(file.Folder),
+	}
+}
+
+// NewFileDTOs maps a list of files
+func NewFileDTOs(files []models.File) []FileDTO {
+	result := make([]FileDTO, len(files))
+	for i := range files {
+		result[i] = NewFileDTO(&files[i])
+	}
+	return result
+}
+
+// newEmbeddedFileDTO maps a file relationship, returning nil when it was not loaded
+func newEmbeddedFileDTO(file *models.File) *FileDTO {
+	if file == nil || file.ID == uuid.Nil {
+		return nil
+	}
+	dto := NewFileDTO(file)
+	return &dto
+}
+
+// NewShareDTO maps a user-to-user file share
+func NewShareDTO(share *models.FileShare) ShareDTO {
+	return ShareDTO{
+		ID:             share.ID,
+		FileID:         share.FileID,
+		SharedBy:       share.SharedBy,
+		SharedWith:     share.SharedWith,
+		Permission:     share.Permission,
+		Message:        share.Message,
+		ExpiresAt:      share.ExpiresAt,
+		IsActive:       share.IsActive,
+		Status:         share.Status,
+		RespondedAt:    share.RespondedAt,
+		Hidden:         share.HiddenAt != nil,
+		CreatedAt:      share.CreatedAt,
+		File:           newEmbeddedFileDTO(&share.File),
+		SharedByUser:   NewUserSummaryDTO(&share.SharedByUser),
+		SharedWithUser: NewUserSummaryDTO(&share.SharedWithUser),
+	}
+}
+
+// NewShareDTOs maps a list of file shares
+func NewShareDTOs(shares []models.FileShare) []ShareDTO {
+	result := make([]ShareDTO, len(shares))
+	for i := range shares {
+		result[i] = NewShareDTO(&shares[i])
+	}
+	return result
+}
+
+// NewShareLinkDTO maps a file share link without its password hash
+func NewShareLinkDTO(link *models.ShareLink) ShareLinkDTO {
+	return ShareLinkDTO{
+		ID:               link.ID,
+		FileID:           link.FileID,
+		CreatedBy:        link.CreatedBy,
+		ShareToken:       link.ShareToken,
+		Permission:       link.Permission,
+		HasPassword:      link.PasswordHash != "",
+		MaxDownloads:     link.MaxDownloads,
+		DownloadCount:    link.DownloadCount,
+		ExpiresAt:        link.ExpiresAt,
+		IsActive:         link.IsActive,
+		LastAccessedAt:   link.LastAccessedAt,
+		NotifyOnDownload: link.NotifyOnDownload,
+		Watermark:        link.Watermark,
+		WatermarkText:    link.WatermarkText,
+		WarmStatus:       link.WarmStatus,
+		WarmedAt:         link.WarmedAt,
+		WarmError:        link.WarmError,
+		CreatedAt:        link.CreatedAt,
+		File:             newEmbeddedFileDTO(&link.File),
+	}
+}
+
+// NewShareLinkDTOs maps a list of file share links
+func NewShareLinkDTOs(links []models.ShareLink) []ShareLinkDTO {
+	result := make([]ShareLinkDTO, len(links))
+	for i := range links {
+		result[i] = NewShareLinkDTO(&links[i])
+	}
+	return result
+}
+
+// NewFolderShareDTO maps a user-to-user folder share
+func NewFolderShareDTO(share *models.FolderShare) FolderShareDTO {
+	return FolderShareDTO{
+		ID:             share.ID,
+		FolderID:       share.FolderID,
+		SharedBy:       share.SharedBy,
+		SharedWith:     share.SharedWith,
+		Permission:     share.Permission,
+		Message:        share.Message,
+		Status:         share.Status,
+		RespondedAt:    share.RespondedAt,
+		Hidden:         share.HiddenAt != nil,
+		CreatedAt:      share.CreatedAt,
+		Folder:         NewFolderSummaryDTO(&share.Folder),
+		SharedByUser:   NewUserSummaryDTO(&share.SharedByUser),
+		SharedWithUser: NewUserSummaryDTO(&share.SharedWithUser),
+	}
+}
+
+// NewFolderShareDTOs maps a list of folder shares
+func NewFolderShareDTOs(shares []models.FolderShare) []FolderShareDTO {
+	result := make([]FolderShareDTO, len(shares))
+	for i := range shares {
+		result[i] = NewFolderShareDTO(&shares[i])
+	}
+	return result
+}
+
+// NewFolderShareLinkDTO maps a folder share link without its password hash
+func NewFolderShareLinkDTO(link *models.FolderShareLink) FolderShareLinkDTO {
+	return FolderShareLinkDTO{
+		ID:            link.ID,
+		FolderID:      link.FolderID,
+		CreatedBy:     link.CreatedBy,
+		Token:         link.Token,
+		Permission:    link.Permission,
+		Mode:          link.Mode,
+		HasPassword:   link.PasswordHash != "",
+		MaxDownloads:  link.MaxDownloads,
+		DownloadCount: link.DownloadCount,
+		ExpiresAt:     link.ExpiresAt,
+		IsActive:      link.IsActive,
+		CreatedAt:     link.CreatedAt,
+		Folder:        NewFolderSummaryDTO(&link.Folder),
+	}
+}
+
+// NewFolderShareLinkDTOs maps a list of folder share links
+func NewFolderShareLinkDTOs(links []models.FolderShareLink) []FolderShareLinkDTO {
+	result := make([]FolderShareLinkDTO, len(links))
+	for i := range links {
+		result[i] = NewFolderShareLinkDTO(&links[i])
+	}
+	return result
+}
+
+// NewDownloadStatDTOs maps a list of download records
+func NewDownloadStatDTOs(stats []models.DownloadStat) []DownloadStatDTO {
+	result := make([]DownloadStatDTO, len(stats))
+	for i, stat := range stats {
+		result[i] = DownloadStatDTO{
+			ID:           stat.ID,
+			FileID:       stat.FileID,
+			DownloadedBy: stat.DownloadedBy,
+			SharedLinkID: stat.SharedLinkID,
+			IPAddress:    stat.IPAddress,
+			UserAgent:    stat.UserAgent,
+			DownloadSize: stat.DownloadSize,
+			DownloadedAt: stat.DownloadedAt,
+			User:         NewUserSummaryDTO(stat.User),
+		}
+	}
+	return result
+}
+
+// NewAuditLogDTOs maps a page of audit log entries
+func NewAuditLogDTOs(logs []models.AuditLog) []AuditLogDTO {
+	result := make([]AuditLogDTO, len(logs))
+	for i := range logs {
+		entry := &logs[i]
+		result[i] = AuditLogDTO{
+			ID:           entry.ID,
+			UserID:       entry.UserID,
+			Action:       entry.Action,
+			ResourceType: entry.ResourceType,
+			ResourceID:   entry.ResourceID,
+			ResourceName: entry.ResourceName,
+			Details:      entry.Details,
+			IPAddress:    entry.IPAddress,
+			UserAgent:    entry.UserAgent,
+			Status:       entry.Status,
+			CreatedAt:    entry.CreatedAt,
+			Sequence:     entry.Sequence,
+			PrevHash:     entry.PrevHash,
+			Hash:         entry.Hash,
+			User:         NewUserSummaryDTO(&entry.User),
+		}
+	}
+	return result
+}
+
+// NewQuarantineDTO maps a quarantine entry and its file and owner if loaded
+func NewQuarantineDTO(entry *models.FileQuarantine) QuarantineDTO {
+	return QuarantineDTO{
+		ID:         entry.ID,
+		FileID:     entry.FileID,
+		OwnerID:    entry.OwnerID,
+		Source:     entry.Source,
+		Reason:     entry.Reason,
+		Findings:   entry.Findings,
+		Status:     entry.Status,
+		ReviewedBy: entry.ReviewedBy,
+		ReviewedAt: entry.ReviewedAt,
+		ReviewNote: entry.ReviewNote,
+		CreatedAt:  entry.CreatedAt,
+		File:       newEmbeddedFileDTO(entry.File),
+		Owner:      NewUserSummaryDTO(entry.Owner),
+	}
+}
+
+// NewQuarantineDTOs maps a page of quarantine entries
+func NewQuarantineDTOs(entries []models.FileQuarantine) []QuarantineDTO {
+	result := make([]QuarantineDTO, len(entries))
+	for i := range entries {
+		result[i] = NewQuarantineDTO(&entries[i])
+	}
+	return result
+}
+
+// NewActivityDTOs maps a page of the activity timeline
+func NewActivityDTOs(items []services.ActivityItem) []ActivityDTO {
+	result := make([]ActivityDTO, len(items))
+	for i := range items {
+		item := &items[i]
+		result[i] = ActivityDTO{
+			ID:           item.ID,
+			Type:         item.Type,
+			OccurredAt:   item.OccurredAt,
+			Action:       item.Action,
+			ResourceType: item.ResourceType,
+			ResourceID:   item.ResourceID,
+			ResourceName: item.ResourceName,
+			User:         NewUserSummaryDTO(item.Actor),
+			Details:      item.Details,
+		}
+	}
+	return result
+}
+
+// NewFileEventDTOs maps a page of file events
+func NewFileEventDTOs(events []models.FileEvent) []FileEventDTO {
+	result := make([]FileEventDTO, len(events))
+	for i := range events {
+		event := &events[i]
+		result[i] = FileEventDTO{
+			ID:        event.ID,
+			Sequence:  event.Sequence,
+			FileID:    event.FileID,
+			Type:      event.Type,
+			Payload:   event.Payload,
+			ActorID:   event.ActorID,
+			User:      NewUserSummaryDTO(event.Actor),
+			CreatedAt: event.CreatedAt,
+		}
+	}
+	return result
+}
+
+// NewUploadRejectionDTOs maps a page of upload rejections
+func NewUploadRejectionDTOs(rejections []models.UploadRejection) []UploadRejectionDTO {
+	result := make([]UploadRejectionDTO, len(rejections))
+	for i := range rejections {
+		r := &rejections[i]
+		result[i] = UploadRejectionDTO{
+			ID:               r.ID,
+			UserID:           r.UserID,
+			Reason:           r.Reason,
+			Code:             r.Code,
+			Message:          r.Message,
+			Filename:         r.Filename,
+			DeclaredMimeType: r.DeclaredMimeType,
+			DetectedMimeType: r.DetectedMimeType,
+			Size:             r.Size,
+			IPAddress:        r.IPAddress,
+			UserAgent:        r.UserAgent,
+			CreatedAt:        r.CreatedAt,
+			User:             NewUserSummaryDTO(r.User),
+		}
+	}
+	return result
+}
+
+// NewDeviceDTOs maps a user's devices, marking the one making the request
+func NewDeviceDTOs(devices []models.Device, current uuid.UUID) []DeviceDTO {
+	result := make([]DeviceDTO, len(devices))
+	for i := range devices {
+		d := &devices[i]
+		result[i] = DeviceDTO{
+			ID:         d.ID,
+			Name:       d.Name,
+			Platform:   d.Platform,
+			Client:     d.Client,
+			UserAgent:  d.UserAgent,
+			LastIP:     d.LastIP,
+			LastSeenAt: d.LastSeenAt,
+			CreatedAt:  d.CreatedAt,
+			Current:    d.ID == current,
+		}
+	}
+	return result
+}
+
+// ContentIndexDTO is the text extraction and OCR status of a file
+type ContentIndexDTO struct {
+	FileID    uuid.UUID                 `json:"file_id"`
+	Status    models.ContentIndexStatus `json:"status"`
+	Method    models.ContentIndexMethod `json:"method,omitempty"`
+	Engine    string                    `json:"engine,omitempty"`
+	CharCount int                       `json:"char_count"`
+	Attempts  int                       `json:"attempts"`
+	Error     string                    `json:"error,omitempty"`
+	IndexedAt *time.Time                `json:"indexed_at,omitempty"`
+	UpdatedAt *time.Time                `json:"updated_at,omitempty"`
+}
+
+// NewContentIndexDTO maps a file's index entry. Files whose content was never
+// queued are reported as not_indexed
+func NewContentIndexDTO(fileID uuid.UUID, entry *models.ContentIndex) ContentIndexDTO {
+	if entry == nil {
+		return ContentIndexDTO{FileID: fileID, Status: "not_indexed"}
+	}
+	return ContentIndexDTO{
+		FileID:    fileID,
+		Status:    entry.Status,
+		Method:    entry.Method,
+		Engine:    entry.Engine,
+		CharCount: entry.CharCount,
+		Attempts:  entry.Attempts,
+		Error:     entry.Error,
+		IndexedAt: entry.IndexedAt,
+		UpdatedAt: &entry.UpdatedAt,
+	}
+}
+
+// DownloadManifestDTO lists the byte ranges a large file can be downloaded in
+type DownloadManifestDTO struct {
+	FileID      uuid.UUID          `json:"file_id"`
+	Filename    string             `json:"filename"`
+	MimeType    string             `json:"mime_type"`
+	Size        int64              `json:"size"`
+	SHA256      string             `json:"sha256"`
+	DownloadURL string             `json:"download_url"`
+	ChunkSize   int64              `json:"chunk_size"`
+	ChunkCount  int                `json:"chunk_count"`
+	Chunks      []DownloadChunkDTO `json:"chunks"`
+}
+
+// DownloadChunkDTO is one segment of a download manifest. Range is the
+// header value that fetches it from the download URL
+type DownloadChunkDTO struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Range  string `json:"range"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewDownloadManifestDTO maps a file's download manifest, with the download
+// URL built by links
+func NewDownloadManifestDTO(manifest *services.DownloadManifest, links *services.LinkService) DownloadManifestDTO {
+	chunks := make([]DownloadChunkDTO, len(manifest.Chunks))
+	for i := range manifest.Chunks {
+		chunk := &manifest.Chunks[i]
+		chunks[i] = DownloadChunkDTO{
+			Index:  chunk.ChunkIndex,
+			Offset: chunk.Offset,
+			Length: chunk.Length,
+			Range:  fmt.Sprintf("bytes=%d-%d", chunk.Offset, chunk.Offset+chunk.Length-1),
+			SHA256: chunk.SHA256,
+		}
+	}
+	return DownloadManifestDTO{
+		FileID:      manifest.File.ID,
+		Filename:    manifest.File.OriginalFilename,
+		MimeType:    manifest.File.MimeType,
+		Size:        manifest.Hash.Size,
+		SHA256:      manifest.Hash.Hash,
+		DownloadURL: links.FileDownloadURL(manifest.File.ID),
+		ChunkSize:   manifest.ChunkSize,
+		ChunkCount:  len(chunks),
+		Chunks:      chunks,
+	}
+}
+
+// UploadResultDTO is the outcome of one file of an upload. Its identifying
+// fields match FileDTO, whichever way the file was stored
+type UploadResultDTO struct {
+	ID               uuid.UUID               `json:"id"`
+	Filename         string                  `json:"filename"`
+	OriginalFilename string                  `json:"original_filename"`
+	Size             int64                   `json:"size"`
+	MimeType         string                  `json:"mime_type"`
+	FolderID         *uuid.UUID              `json:"folder_id,omitempty"` // where the file was stored, which upload rules may have chosen
+	ContentHash      string                  `json:"content_hash"`
+	IsDuplicate      bool                    `json:"is_duplicate"`    // the content was already stored
+	SavedBytes       int64                   `json:"saved_bytes"`     // bytes not stored again thanks to deduplication
+	StorageCharged   int64                   `json:"storage_charged"` // bytes added to the user's storage usage
+	IsPublic         bool                    `json:"is_public"`
+	Revision         string                  `json:"revision"`
+	Replaced         bool                    `json:"replaced,omitempty"`
+	Conflict         bool                    `json:"conflict,omitempty"`
+	ConflictOf       *uuid.UUID              `json:"conflict_of,omitempty"`
+	IsQuarantined    bool                    `json:"is_quarantined,omitempty"`
+	ProcessingStatus models.ProcessingStatus `json:"processing_status"`
+	IsEncrypted      bool                    `json:"is_encrypted,omitempty"`
+	RetainUntil      *time.Time              `json:"retain_until,omitempty"`
+	Warning          string                  `json:"warning,omitempty"`
+	SensitiveContent []dlp.Finding           `json:"sensitive_content,omitempty"`
+}
+
+// newUploadResultDTO maps the file an upload was stored as. The content
+// fields come from the upload, since a replaced file's record may predate it.
+// charged is what the file added to the owner's storage usage.
+func newUploadResultDTO(file *models.File, upload FileUploadInfo, isNewContent bool, charged int64) *UploadResultDTO {
+	result := &UploadResultDTO{
+		ID:               file.ID,
+		Filename:         file.Filename,
+		OriginalFilename: file.OriginalFilename,
+		Size:             upload.Size,
+		MimeType:         upload.MimeType,
+		FolderID:         file.FolderID,
+		ContentHash:      upload.Hash,
+		IsDuplicate:      !isNewContent,
+		IsPublic:         file.IsPublic,
+		ProcessingStatus: file.ProcessingStatus,
+		IsEncrypted:      upload.EncryptionHeader != "",
+		Revision:         metadataETag(file.UpdatedAt),
+		StorageCharged:   charged,
+		Warning:          upload.Warning,
+		SensitiveContent: upload.DLPFindings,
+	}
+	if !isNewContent {
+		result.SavedBytes = upload.Size
+	}
+	return result
+}
+
+// Outcomes of the entries of an extracted archive
+const (
+	ArchiveEntryUploaded = "uploaded" // stored as a file
+	ArchiveEntryFolder   = "folder"   // a directory, created or already there
+	ArchiveEntrySkipped  = "skipped"  // refused by the upload checks or a quota
+	ArchiveEntryInvalid  = "invalid"  // cannot be extracted safely
+)
+
+// ArchiveEntryDTO is the outcome of one entry of an archive uploaded with
+// extract=true
+type ArchiveEntryDTO struct {
+	Path     string           `json:"path"` // as named in the archive
+	Status   string           `json:"status"`
+	Code     string           `json:"code,omitempty"` // why the entry was skipped or is invalid
+	Message  string           `json:"message,omitempty"`
+	FolderID *uuid.UUID       `json:"folder_id,omitempty"` // of a directory entry
+	File     *UploadResultDTO `json:"file,omitempty"`
+}
+
+// UploadSessionDTO is a resumable upload and the chunks it still needs
+type UploadSessionDTO struct {
+	ID             uuid.UUID  `json:"id"`
+	Filename       string     `json:"filename"`
+	MimeType       string     `json:"mime_type"`
+	Size           int64      `json:"size"`
+	FolderID       *uuid.UUID `json:"folder_id,omitempty"`
+	IsPublic       bool       `json:"is_public"`
+	ChunkSize      int64      `json:"chunk_size"`
+	ChunkCount     int        `json:"chunk_count"`
+	ReceivedCount  int        `json:"received_count"`
+	ReceivedChunks []byte     `json:"received_chunks"` // base64 bitmap; chunk i is bit i%8 of byte i/8
+	MissingChunks  []int      `json:"missing_chunks"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// NewUploadSessionDTO maps an upload session
+func NewUploadSessionDTO(session *models.UploadSession) UploadSessionDTO {
+	return UploadSessionDTO{
+		ID:             session.ID,
+		Filename:       session.Filename,
+		MimeType:       session.MimeType,
+		Size:           session.Size,
+		FolderID:       session.FolderID,
+		IsPublic:       session.IsPublic,
+		ChunkSize:      session.ChunkSize,
+		ChunkCount:     session.ChunkCount,
+		ReceivedCount:  session.ReceivedCount,
+		ReceivedChunks: session.ReceivedChunks,
+		MissingChunks:  session.MissingChunks(),
+		ExpiresAt:      session.ExpiresAt,
+		CreatedAt:      session.CreatedAt,
+	}
+}
+
+// URLImportDTO is a file being downloaded from a URL and how far it has got
+type URLImportDTO struct {
+	ID            uuid.UUID              `json:"id"`
+	URL           string                 `json:"url"`
+	Filename      string                 `json:"filename,omitempty"`
+	FolderID      *uuid.UUID             `json:"folder_id,omitempty"`
+	IsPublic      bool                   `json:"is_public"`
+	Status        models.URLImportStatus `json:"status"`
+	BytesReceived int64                  `json:"bytes_received"`
+	TotalBytes    *int64                 `json:"total_bytes,omitempty"` // unknown until the server sends its size
+	FileID        *uuid.UUID             `json:"file_id,omitempty"`     // once completed
+	ErrorCode     string                 `json:"error_code,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	StartedAt     *time.Time             `json:"started_at,omitempty"`
+	CompletedAt   *time.Time             `json:"completed_at,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+// NewURLImportDTO maps a URL import
+func NewURLImportDTO(job *models.URLImport) URLImportDTO {
+	return URLImportDTO{
+		ID:            job.ID,
+		URL:           job.URL,
+		Filename:      job.Filename,
+		FolderID:      job.FolderID,
+		IsPublic:      job.IsPublic,
+		Status:        job.Status,
+		BytesReceived: job.BytesReceived,
+		TotalBytes:    job.TotalBytes,
+		FileID:        job.FileID,
+		ErrorCode:     job.ErrorCode,
+		Error:         job.Error,
+		StartedAt:     job.StartedAt,
+		CompletedAt:   job.CompletedAt,
+		CreatedAt:     job.CreatedAt,
+	}
+}
+
+// DirectUploadDTO is an upload to the object store and where the client
+// sends its content
+type DirectUploadDTO struct {
+	ID            uuid.UUID         `json:"id"`
+	Filename      string            `json:"filename"`
+	MimeType      string            `json:"mime_type"`
+	Size          int64             `json:"size"`
+	SHA256        string            `json:"sha256"`
+	FolderID      *uuid.UUID        `json:"folder_id,omitempty"`
+	IsPublic      bool              `json:"is_public"`
+	UploadURL     string            `json:"upload_url,omitempty"`     // presigned; PUT the content here
+	UploadHeaders map[string]string `json:"upload_headers,omitempty"` // the PUT must carry these exactly
+	ExpiresAt     time.Time         `json:"expires_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// NewDirectUploadDTO maps a direct upload with the presigned request that
+// sends its content
+func NewDirectUploadDTO(upload *models.DirectUpload, uploadURL string, uploadHeader http.Header) DirectUploadDTO {
+	dto := DirectUploadDTO{
+		ID:        upload.ID,
+		Filename:  upload.Filename,
+		MimeType:  upload.MimeType,
+		Size:      upload.Size,
+		SHA256:    upload.SHA256,
+		FolderID:  upload.FolderID,
+		IsPublic:  upload.IsPublic,
+		UploadURL: uploadURL,
+		ExpiresAt: upload.ExpiresAt,
+		CreatedAt: upload.CreatedAt,
+	}
+	if len(uploadHeader) > 0 {
+		dto.UploadHeaders = make(map[string]string, len(uploadHeader))
+		for name := range uploadHeader {
+			dto.UploadHeaders[name] = uploadHeader.Get(name)
+		}
+	}
+	return dto
+}
+
+// CleanupRuleDTO is a cleanup rule with the path of the folder it covers
+type CleanupRuleDTO struct {
+	ID                uuid.UUID  `json:"id"`
+	Name              string     `json:"name"`
+	FolderID          *uuid.UUID `json:"folder_id"` // null for the root folder
+	FolderPath        string     `json:"folder_path,omitempty"`
+	IncludeSubfolders bool       `json:"include_subfolders"`
+	NamePattern       string     `json:"name_pattern,omitempty"`
+	MimeType          string     `json:"mime_type,omitempty"`
+	Tag               string     `json:"tag,omitempty"`
+	OlderThanDays     int        `json:"older_than_days"`
+	IsActive          bool       `json:"is_active"`
+	LastRunAt         *time.Time `json:"last_run_at,omitempty"`
+	LastTrashed       int        `json:"last_trashed"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// NewCleanupRuleDTO maps a cleanup rule and the path of its folder
+func NewCleanupRuleDTO(rule *models.CleanupRule, folderPath string) CleanupRuleDTO {
+	return CleanupRuleDTO{
+		ID:                rule.ID,
+		Name:              rule.Name,
+		FolderID:          rule.FolderID,
+		FolderPath:        folderPath,
+		IncludeSubfolders: rule.IncludeSubfolders,
+		NamePattern:       rule.NamePattern,
+		MimeType:          rule.MimeType,
+		Tag:               rule.Tag,
+		OlderThanDays:     rule.OlderThanDays,
+		IsActive:          rule.IsActive,
+		LastRunAt:         rule.LastRunAt,
+		LastTrashed:       rule.LastTrashed,
+		CreatedAt:         rule.CreatedAt,
+		UpdatedAt:         rule.UpdatedAt,
+	}
+}
+
+// CleanupPreviewDTO is what a cleanup rule would move to the trash if it ran
+// now. Files lists the oldest matches; total_files counts them all.
+type CleanupPreviewDTO struct {
+	RuleID     uuid.UUID `json:"rule_id"`
+	Cutoff     time.Time `json:"cutoff"` // files uploaded before this match
+	TotalFiles int64     `json:"total_files"`
+	TotalBytes int64     `json:"total_bytes"`
+	Files      []FileDTO `json:"files"`
+}
+
+// GalleryItemDTO is the slim view of a file in a photo grid
+type GalleryItemDTO struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	MimeType     string     `json:"mime_type"`
+	Size         int64      `json:"size"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"` // signed; loads without an Authorization header
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"` // camera's wall-clock time from EXIF
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// NewGalleryItemDTO maps a file with its thumbnail URL and what its content
+// says about it, any of which may be empty
+func NewGalleryItemDTO(file *models.File, thumbnailURL string, media services.MediaInfo) GalleryItemDTO {
+	return GalleryItemDTO{
+		ID:           file.ID,
+		Name:         file.OriginalFilename,
+		MimeType:     file.MimeType,
+		Size:         file.Size,
+		ThumbnailURL: thumbnailURL,
+		Width:        media.Width,
+		Height:       media.Height,
+		CapturedAt:   media.CapturedAt,
+		CreatedAt:    file.CreatedAt,
+	}
+}
+
+// AlbumItemDTO is a photo or video of a shared album
+type AlbumItemDTO struct {
+	GalleryItemDTO
+	TakenAt time.Time `json:"taken_at"`           // what the album is sorted by
+	ViewURL string    `json:"view_url,omitempty"` // opens the item through the album's link
+}
+
+// NewAlbumItemDTO maps an album item with its signed thumbnail URL and the
+// URL that opens it, either of which may be empty
+func NewAlbumItemDTO(item *services.AlbumItem, thumbnailURL, viewURL string) AlbumItemDTO {
+	return AlbumItemDTO{
+		GalleryItemDTO: NewGalleryItemDTO(&item.File, thumbnailURL, item.Media),
+		TakenAt:        item.TakenAt,
+		ViewURL:        viewURL,
+	}
+}
+
+// TimelineBucketDTO is one period of a photo timeline
+type TimelineBucketDTO struct {
+	Period     string          `json:"period"`
+	Start      time.Time       `json:"start"`
+	End        time.Time       `json:"end"`
+	Count      int             `json:"count"`
+	ImageCount int             `json:"image_count"`
+	VideoCount int             `json:"video_count"`
+	Cover      *GalleryItemDTO `json:"cover,omitempty"`
+}
+
+// NewTimelineBucketDTO maps a timeline period with its cover's signed thumbnail URL
+func NewTimelineBucketDTO(bucket *services.TimelineBucket, coverURL string) TimelineBucketDTO {
+	dto := TimelineBucketDTO{
+		Period:     bucket.Period,
+		Start:      bucket.Start,
+		End:        bucket.End,
+		Count:      bucket.Images + bucket.Videos,
+		ImageCount: bucket.Images,
+		VideoCount: bucket.Videos,
+	}
+	if bucket.Cover != nil {
+		cover := NewGalleryItemDTO(bucket.Cover, coverURL, bucket.CoverMedia)
+		dto.Cover = &cover
+	}
+	return dto
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errPreconditionFailed aborts an update whose resource changed after the
+// client's If-Match check passed
+var errPreconditionFailed = errors.New("resource was modified")
+
+// metadataETag is the entity tag of a file or folder's metadata. It changes
+// whenever updated_at does.
+func metadataETag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d"`, updatedAt.UnixMicro())
+}
+
+// checkIfMatch compares the request's If-Match header with the resource's
+// current ETag before an update. It responds 428 when the header is required
+// but missing and 412 when it names another version, and reports whether the
+// update may go ahead.
+func checkIfMatch(c *gin.Context, required bool, updatedAt time.Time) bool {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if required {
+			c.JSON(http.StatusPreconditionRequired, gin.H{
+				"error": "If-Match header is required",
+				"etag":  metadataETag(updatedAt),
+			})
+			return false
+		}
+		return true
+	}
+
+	current := metadataETag(updatedAt)
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match, as If-Match uses strong comparison
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return true
+		}
+	}
+	respondPreconditionFailed(c, updatedAt)
+	return false
+}
+
+// respondPreconditionFailed reports that the resource changed since the
+// client read it, with the current ETag so it can reload and retry
+func respondPreconditionFailed(c *gin.Context, updatedAt time.Time) {
+	c.Header("ETag", metadataETag(updatedAt))
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   "Resource was modified by another request",
+		"code":    "PRECONDITION_FAILED",
+		"message": "Reload the resource and apply your change again",
+		"etag":    metadataETag(updatedAt),
+	})
+}
+
+// unchangedSince limits an update to the version the client matched, so a
+// concurrent update between the If-Match check and the write is caught. It
+// only applies when the request sent If-Match.
+func unchangedSince(c *gin.Context, updatedAt time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if c.GetHeader("If-Match") == "" {
+			return db
+		}
+		return db.Where("updated_at = ?", updatedAt)
+	}
+}
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"crypto/sha256"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -17,49 +18,141 @@ import (
 	"gorm.io/gorm"
 
 	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/middleware"
 	"file-vault-system/backend/internal/models"
 	"file-vault-system/backend/internal/services"
+	"file-vault-system/backend/pkg/database"
+	"file-vault-system/backend/pkg/dlp"
 	"file-vault-system/backend/pkg/utils"
 )
 
 // FileUploadInfo holds information about a file being uploaded
 type FileUploadInfo struct {
 	Header   *multipart.FileHeader
-	Content  []byte
+	TempPath string // staged copy of the content, moved into storage on commit
 	Size     int64
 	Hash     string
 	MimeType string
 	IsValid  bool
 	Warning  string
+
+	// Sensitive content reported by DLP scanning
+	DLPFindings []dlp.Finding
+	// MalwareScanned is set when the content was scanned and found clean
+	MalwareScanned bool
+	// EncryptionHeader is set for content the client encrypted for a vault
+	// folder; the server cannot inspect it
+	EncryptionHeader string
+	// FolderID is the folder the file goes to, nil for the root folder
+	FolderID *uuid.UUID
+	// DirectUpload is set for content the client uploaded straight to the
+	// object store, which is copied into place on commit instead of moved
+	DirectUpload *models.DirectUpload
 }
 
+const (
+	// maxEncryptionHeaderLength bounds the client's encryption header of a file
+	maxEncryptionHeaderLength = 8192
+
+	// encryptedMimeType is the type stored for content encrypted by the client
+	encryptedMimeType = "application/octet-stream"
+)
+
 type FileHandler struct {
-	db           *gorm.DB
-	cfg          *config.Config
-	auditService *services.AuditService
+	db                   *gorm.DB
+	cfg                  *config.Config
+	auditService         *services.AuditService
+	uploadPolicyService  *services.UploadPolicyService
+	dlpService           *services.DLPService
+	quarantineService    *services.QuarantineService
+	retentionService     *services.RetentionService
+	rejectionService     *services.UploadRejectionService
+	quotaGraceService    *services.QuotaGraceService
+	contentIndexService  *services.ContentIndexService
+	malwareScanService   *services.MalwareScanService
+	accessService        *services.AccessService
+	fileStreamService    *services.FileStreamService
+	manifestService      *services.DownloadManifestService
+	zipDownload          *zipDownload
+	prewarmService       *services.PrewarmService
+	heatmapService       *services.AccessHeatmapService
+	tenantService        *services.TenantService
+	linkService          *services.LinkService
+	accessCountService   *services.AccessCountService
+	uploadSessionService *services.UploadSessionService
+	tusUploadService     *services.TusUploadService
+	directUploadService  *services.DirectUploadService
+	uploadRuleService    *services.UploadRuleService
+	galleryService       *services.GalleryService
+	urlImportService     *services.URLImportService
 }
 
-func NewFileHandler(db *gorm.DB, cfg *config.Config, auditService *services.AuditService) *FileHandler {
+func NewFileHandler(db *gorm.DB, cfg *config.Config, auditService *services.AuditService, dlpService *services.DLPService, quarantineService *services.QuarantineService) *FileHandler {
 	return &FileHandler{
-		db:           db,
-		cfg:          cfg,
-		auditService: auditService,
+		db:                   db,
+		cfg:                  cfg,
+		auditService:         auditService,
+		uploadPolicyService:  services.NewUploadPolicyService(db),
+		dlpService:           dlpService,
+		quarantineService:    quarantineService,
+		retentionService:     services.NewRetentionService(db, auditService),
+		rejectionService:     services.NewUploadRejectionService(db),
+		quotaGraceService:    services.NewQuotaGraceService(db, cfg, services.NewNotificationService(db, cfg), services.NewMailService(cfg)),
+		contentIndexService:  services.NewContentIndexService(db, cfg),
+		malwareScanService:   services.NewMalwareScanService(db, cfg, quarantineService),
+		accessService:        services.NewAccessService(db),
+		fileStreamService:    services.NewFileStreamService(db, cfg),
+		manifestService:      services.NewDownloadManifestService(db, cfg),
+		zipDownload:          newZipDownload(db, cfg, auditService),
+		prewarmService:       services.NewPrewarmService(db, cfg),
+		heatmapService:       services.NewAccessHeatmapService(db),
+		tenantService:        services.NewTenantService(db, cfg),
+		linkService:          services.NewLinkService(db, cfg),
+		accessCountService:   services.NewAccessCountService(db, cfg),
+		uploadSessionService: services.NewUploadSessionService(db, cfg),
+		tusUploadService:     services.NewTusUploadService(db, cfg),
+		directUploadService:  services.NewDirectUploadService(db, cfg),
+		uploadRuleService:    services.NewUploadRuleService(db),
+		galleryService:       services.NewGalleryService(db, cfg),
+		urlImportService:     services.NewURLImportService(db, cfg),
 	}
 }
 
-// recordDownload records a download statistic for a file
+// recordRejection stores why an upload was refused, for admin diagnostics
+func (h *FileHandler) recordRejection(c *gin.Context, userID uuid.UUID, rejection models.UploadRejection) {
+	rejection.UserID = userID
+	rejection.IPAddress = middleware.StoredClientIP(c)
+	rejection.UserAgent = c.GetHeader("User-Agent")
+	h.rejectionService.Record(&rejection)
+}
+
+// recordDownload records a download statistic for a file. Downloads by
+// anonymous visitors are only counted when anonymous access tracking is set
+// to counts
 func (h *FileHandler) recordDownload(fileID uuid.UUID, userID *uuid.UUID, shareID *uuid.UUID, c *gin.Context) {
-	downloadStat := models.DownloadStat{
+	// Log the download (ignore errors as this is supplementary data)
+	if userID == nil && h.accessCountService.CountsOnly() {
+		h.accessCountService.Record(models.AccessCountFile, fileID, "download")
+		return
+	}
+	stat := newDownloadStat(fileID, userID, shareID, c)
+	query := h.db.WithContext(c.Request.Context())
+	if stat.IPAddress == "" {
+		query = query.Omit("IPAddress")
+	}
+	query.Create(stat)
+}
+
+// newDownloadStat builds a download statistic for the current request
+func newDownloadStat(fileID uuid.UUID, userID *uuid.UUID, shareID *uuid.UUID, c *gin.Context) *models.DownloadStat {
+	return &models.DownloadStat{
 		FileID:       fileID,
 		DownloadedBy: userID,
 		SharedLinkID: shareID,
-		IPAddress:    c.ClientIP(),
-		UserAgent:    c.GetHeader("User-Agent"),
+		IPAddress:    middleware.StoredClientIP(c),
+		UserAgent:    middleware.StoredUserAgent(c),
 		DownloadSize: 0, // Will be set if needed
 	}
-
-	// Log the download (ignore errors as this is supplementary data)
-	h.db.Create(&downloadStat)
 }
 
 // GetUserStats returns storage statistics for the authenticated user
@@ -73,24 +166,25 @@ func (h *FileHandler) GetUserStats(c *gin.Context) {
 
 	// Get user with storage stats
 	var user models.User
-	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Preload("Plan").First(&user, "id = ?", userID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
 		return
 	}
 
 	// Count user's files
 	var fileCount int64
-	h.db.Model(&models.File{}).Where("owner_id = ? AND is_deleted = false", userID).Count(&fileCount)
+	h.db.WithContext(c.Request.Context()).Model(&models.File{}).Where("owner_id = ?", userID).Count(&fileCount)
 
 	// Count folders created by this user
 	var foldersCreated int64
-	h.db.Model(&models.Folder{}).Where("owner_id = ?", userID).Count(&foldersCreated)
+	h.db.WithContext(c.Request.Context()).Model(&models.Folder{}).Where("owner_id = ?", userID).Count(&foldersCreated)
 
 	// Count files shared by this user (files shared with others)
 	var filesShared int64
-	h.db.Table("file_shares").
+	h.db.WithContext(c.Request.Context()).Table("file_shares").
 		Joins("JOIN files ON file_shares.file_id = files.id").
-		Where("file_shares.shared_by = ? AND files.is_deleted = false", userID).
+		Where("file_shares.shared_by = ?", userID).
+		Scopes(models.ActiveFiles).
 		Count(&filesShared)
 
 	// Calculate storage efficiency
@@ -106,16 +200,119 @@ func (h *FileHandler) GetUserStats(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"total_uploaded_bytes": user.TotalUploadedBytes,
-		"actual_storage_bytes": user.ActualStorageBytes,
-		"saved_bytes":          user.SavedBytes,
-		"storage_used":         user.StorageUsed,
-		"storage_quota":        user.StorageQuota,
-		"remaining_storage":    remainingStorage,
-		"file_count":           fileCount,
-		"foldersCreated":       foldersCreated,
-		"filesShared":          filesShared,
-		"storage_efficiency":   storageEfficiency,
+		"total_uploaded_bytes":   user.TotalUploadedBytes,
+		"actual_storage_bytes":   user.ActualStorageBytes,
+		"saved_bytes":            user.SavedBytes,
+		"storage_used":           user.StorageUsed,
+		"storage_quota":          user.StorageQuota,
+		"remaining_storage":      remainingStorage,
+		"file_count":             fileCount,
+		"foldersCreated":         foldersCreated,
+		"filesShared":            filesShared,
+		"storage_efficiency":     storageEfficiency,
+		"in_quota_grace":         user.InQuotaGrace(time.Now()),
+		"quota_grace_expires_at": user.QuotaGraceExpiresAt,
+		"quota_grace_revoked_at": user.QuotaGraceRevokedAt,
+		"plan":                   user.Plan,
+		"max_file_size":          user.MaxUploadSize(h.cfg.MaxFileSize),
+		"public_sharing_allowed": user.AllowsPublicSharing(),
+	})
+}
+
+// mimeCategorySQL buckets files into the categories reported by GetUsageBreakdown
+const mimeCategorySQL = `
+	CASE
+		WHEN f.mime_type LIKE 'image/%' THEN 'images'
+		WHEN f.mime_type LIKE 'video/%' THEN 'video'
+		WHEN f.mime_type LIKE 'text/%'
+			OR f.mime_type IN ('application/pdf', 'application/msword', 'application/rtf')
+			OR f.mime_type LIKE 'application/vnd.ms-%'
+			OR f.mime_type LIKE 'application/vnd.openxmlformats-officedocument.%'
+			OR f.mime_type LIKE 'application/vnd.oasis.opendocument.%' THEN 'documents'
+		ELSE 'other'
+	END`
+
+// UsageBreakdownEntry is the storage consumed by one group of files
+type UsageBreakdownEntry struct {
+	FolderID   *uuid.UUID `json:"folder_id,omitempty"`
+	Name       string     `json:"name"`
+	FileCount  int64      `json:"file_count"`
+	TotalBytes int64      `json:"total_bytes"`
+	Percent    float64    `json:"percent"`
+}
+
+// GetUsageBreakdown returns the authenticated user's storage grouped by
+// top-level folder and by MIME category
+func (h *FileHandler) GetUsageBreakdown(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Files are attributed to the top-level folder at the root of their path;
+	// files outside any folder are grouped under the root
+	var byFolder []UsageBreakdownEntry
+	if err := h.db.WithContext(c.Request.Context()).Raw(`
+		SELECT
+			top.id as folder_id,
+			COALESCE(top.name, 'Root') as name,
+			COUNT(f.id) as file_count,
+			COALESCE(SUM(f.size), 0) as total_bytes
+		FROM files f
+		LEFT JOIN folders fo ON fo.id = f.folder_id
+		LEFT JOIN folders top ON top.owner_id = f.owner_id
+			AND top.parent_id IS NULL
+			AND top.path = '/' || split_part(fo.path, '/', 2)
+		WHERE f.owner_id = ? AND f.deleted_at IS NULL
+		GROUP BY top.id, top.name
+		ORDER BY total_bytes DESC
+	`, userID).Scan(&byFolder).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate folder usage"})
+		return
+	}
+
+	var byCategory []UsageBreakdownEntry
+	if err := h.db.WithContext(c.Request.Context()).Raw(`
+		SELECT
+			`+mimeCategorySQL+` as name,
+			COUNT(f.id) as file_count,
+			COALESCE(SUM(f.size), 0) as total_bytes
+		FROM files f
+		WHERE f.owner_id = ? AND f.deleted_at IS NULL
+		GROUP BY 1
+		ORDER BY total_bytes DESC
+	`, userID).Scan(&byCategory).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate file type usage"})
+		return
+	}
+
+	var totalBytes, fileCount int64
+	for _, entry := range byCategory {
+		totalBytes += entry.TotalBytes
+		fileCount += entry.FileCount
+	}
+
+	for _, entries := range [][]UsageBreakdownEntry{byFolder, byCategory} {
+		for i := range entries {
+			if totalBytes > 0 {
+				entries[i].Percent = float64(entries[i].TotalBytes) / float64(totalBytes) * 100
+			}
+		}
+	}
+
+	if byFolder == nil {
+		byFolder = []UsageBreakdownEntry{}
+	}
+	if byCategory == nil {
+		byCategory = []UsageBreakdownEntry{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_bytes":  totalBytes,
+		"file_count":   fileCount,
+		"by_folder":    byFolder,
+		"by_mime_type": byCategory,
 	})
 }
 
@@ -148,12 +345,12 @@ func (h *FileHandler) GetFileDownloadStats(c *gin.Context) {
 			MAX(ds.downloaded_at) as last_download
 		FROM files f
 		LEFT JOIN download_stats ds ON f.id = ds.file_id
-		WHERE f.owner_id = ? AND f.is_deleted = false
+		WHERE f.owner_id = ? AND f.deleted_at IS NULL
 		GROUP BY f.id, f.original_filename, f.is_public
 		ORDER BY total_downloads DESC, f.original_filename ASC
 	`
 
-	if err := h.db.Raw(query, userID).Scan(&stats).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Raw(query, userID).Scan(&stats).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get download statistics"})
 		return
 	}
@@ -172,43 +369,45 @@ func (h *FileHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// Get folder ID from form data or query parameter
-	var folderID *uuid.UUID
-	folderIDStr := c.PostForm("folder_id")
-	if folderIDStr == "" {
-		folderIDStr = c.Query("folder_id")
-	}
-
-	if folderIDStr != "" && folderIDStr != "null" && folderIDStr != "root" {
-		parsedFolderID, err := uuid.Parse(folderIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID format"})
+	// Parse multipart form before touching any form values; parts beyond the
+	// configured memory limit spill to disk
+	if err := c.Request.ParseMultipartForm(h.cfg.MultipartMemoryLimit); err != nil {
+		var quotaErr *middleware.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			quota, _ := c.Get("user_quota")
+			used, _ := c.Get("used_quota")
+			c.JSON(http.StatusForbidden, middleware.QuotaExceededResponse(quota.(int64), used.(int64), quotaErr.Received))
 			return
 		}
-
-		// Verify folder exists and user owns it
-		var folder models.Folder
-		if err := h.db.Where("id = ? AND owner_id = ?", parsedFolderID, userID).First(&folder).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Target folder not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify folder"})
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, middleware.FileTooLargeResponse(h.cfg.MaxFileSize, 0))
 			return
 		}
-		folderID = &parsedFolderID
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+		return
+	}
+	if c.Request.MultipartForm != nil {
+		defer c.Request.MultipartForm.RemoveAll()
 	}
 
-	// Initialize MIME type validator
-	validator := utils.NewMimeTypeValidator()
+	// Get folder ID from form data or query parameter
+	folderIDStr := c.PostForm("folder_id")
+	if folderIDStr == "" {
+		folderIDStr = c.Query("folder_id")
+	}
 
-	// Parse multipart form with max memory (32MB)
-	err := c.Request.ParseMultipartForm(32 << 20)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+	replaceTarget, ok := h.loadReplaceTarget(c, userID.(uuid.UUID))
+	if !ok {
 		return
 	}
 
+	// extract=true unpacks an uploaded ZIP archive into the folder
+	extract := c.PostForm("extract")
+	if extract == "" {
+		extract = c.Query("extract")
+	}
+
 	// Check if files were uploaded
 	form := c.Request.MultipartForm
 	if form == nil || form.File == nil {
@@ -234,199 +433,926 @@ func (h *FileHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// Check user storage quota and limits
-	var user models.User
-	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+	parts := make([]uploadPart, len(allFiles))
+	for i, fileHeader := range allFiles {
+		fileHeader := fileHeader
+		parts[i] = uploadPart{
+			Header: fileHeader,
+			Open:   func() (io.ReadCloser, error) { return fileHeader.Open() },
+		}
+	}
+
+	h.storeUpload(c, userID.(uuid.UUID), uploadRequest{
+		Parts:             parts,
+		FolderID:          folderIDStr,
+		IsPublic:          c.PostForm("is_public") == "true",
+		EncryptionHeaders: c.Request.PostForm["encryption_header"],
+		ReplaceTarget:     replaceTarget,
+		BaseRevision:      c.PostForm("base_revision"),
+		Extract:           extract == "true",
+	})
+}
+
+// uploadPart is one file of an upload: its multipart header, which names it
+// and carries its declared type, and its content
+type uploadPart struct {
+	Header *multipart.FileHeader
+	Open   func() (io.ReadCloser, error)
+	// Direct is set for content already uploaded to the object store, which
+	// is inspected where it is rather than opened
+	Direct *models.DirectUpload
+}
+
+// uploadRequest is what an upload stores, whether it arrived as one
+// multipart request or in chunks
+type uploadRequest struct {
+	Parts             []uploadPart
+	FolderID          string // empty, "null" or "root" for the root folder
+	IsPublic          bool
+	EncryptionHeaders []string // one per part, for uploads to encrypted folders
+	ReplaceTarget     *models.File
+	BaseRevision      string
+	Extract           bool // the only part is a ZIP archive to unpack
+}
+
+// storeUpload validates, scans and stores the files of an upload and writes
+// the response
+func (h *FileHandler) storeUpload(c *gin.Context, userID uuid.UUID, req uploadRequest) {
+	upload, err := h.prepareUpload(c, userID, req)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	if req.Extract {
+		h.extractUpload(c, &upload.user, req.Parts[0], upload.folderID, upload.checks)
 		return
 	}
 
-	// Get is_public parameter from form data
-	isPublic := false
-	if isPublicStr := c.PostForm("is_public"); isPublicStr == "true" {
-		isPublic = true
+	uploadFiles, outcome, err := h.storeFiles(c, userID, req, upload)
+	if err != nil {
+		respondUploadError(c, err)
+		return
 	}
+	c.JSON(http.StatusOK, h.uploadResponse(c, uploadFiles, outcome))
+}
 
-	// Validate each file and calculate total size
-	var uploadFiles []FileUploadInfo
-	var totalSize int64
+// storeFile validates, scans and stores an upload of one file and returns
+// the file, for callers that do not answer with the upload's response. A
+// refused upload returns an *uploadError
+func (h *FileHandler) storeFile(c *gin.Context, userID uuid.UUID, req uploadRequest) (*models.File, error) {
+	if len(req.Parts) != 1 || req.Extract {
+		return nil, errors.New("storeFile stores exactly one file")
+	}
+	upload, err := h.prepareUpload(c, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	_, outcome, err := h.storeFiles(c, userID, req, upload)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, fileHeader := range allFiles {
-		// Open file
-		file, err := fileHeader.Open()
+	var file models.File
+	if err := h.db.WithContext(c.Request.Context()).First(&file, "id = ?", outcome.results[0].ID).Error; err != nil {
+		return nil, fmt.Errorf("error loading stored file: %w", err)
+	}
+	return &file, nil
+}
+
+// uploadError is why an upload was refused or failed: the response that
+// tells the client
+type uploadError struct {
+	status int
+	body   gin.H
+}
+
+func (e *uploadError) Error() string {
+	message, _ := e.body["error"].(string)
+	if detail, _ := e.body["message"].(string); detail != "" && detail != message {
+		message = fmt.Sprintf("%s: %s", message, detail)
+	}
+	return message
+}
+
+// code is the machine-readable reason of the error
+func (e *uploadError) code() string {
+	if code, _ := e.body["code"].(string); code != "" {
+		return code
+	}
+	if code, _ := e.body["type"].(string); code != "" {
+		return code
+	}
+	return "UPLOAD_REJECTED"
+}
+
+// respondUploadError answers a request whose upload was not stored
+func respondUploadError(c *gin.Context, err error) {
+	var uploadErr *uploadError
+	if errors.As(err, &uploadErr) {
+		c.JSON(uploadErr.status, uploadErr.body)
+		return
+	}
+	c.Error(err)
+}
+
+// preparedUpload is an upload that passed the checks of the upload as a
+// whole, with what each of its files is checked against
+type preparedUpload struct {
+	user     models.User
+	folderID *uuid.UUID
+	checks   uploadChecks
+}
+
+// prepareUpload checks an upload as a whole, its target folder, encryption
+// headers and visibility, and loads the user and upload policies its files
+// are checked against
+func (h *FileHandler) prepareUpload(c *gin.Context, userID uuid.UUID, req uploadRequest) (*preparedUpload, error) {
+	// Files in vault folders are encrypted by the client
+	var folderID *uuid.UUID
+	encrypted := false
+	folderIDStr := req.FolderID
+
+	if folderIDStr != "" && folderIDStr != "null" && folderIDStr != "root" {
+		parsedFolderID, err := uuid.Parse(folderIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("Failed to open file %s", fileHeader.Filename),
-			})
-			return
+			return nil, &uploadError{http.StatusBadRequest, gin.H{"error": "Invalid folder ID format"}}
 		}
 
-		// Read file content
-		content, err := io.ReadAll(file)
-		file.Close()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprintf("Failed to read file %s", fileHeader.Filename),
-			})
-			return
+		// Verify folder exists and user owns it
+		var folder models.Folder
+		if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", parsedFolderID, userID).First(&folder).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, &uploadError{http.StatusNotFound, gin.H{"error": "Target folder not found"}}
+			}
+			return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to verify folder"}}
 		}
+		folderID = &parsedFolderID
+		encrypted = folder.IsEncrypted()
+	}
 
-		fileSize := int64(len(content))
+	if req.ReplaceTarget != nil {
+		encrypted = req.ReplaceTarget.IsEncrypted
+	}
+	if req.Extract {
+		if err := checkExtractable(req, encrypted); err != nil {
+			return nil, err
+		}
+	}
 
-		// Validate file size
-		if fileSize > h.cfg.MaxFileSize {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":     fmt.Sprintf("File %s exceeds size limit", fileHeader.Filename),
-				"max_size":  h.cfg.MaxFileSize,
-				"file_size": fileSize,
-			})
-			return
+	// Encrypted uploads carry the client's encryption header of each file,
+	// in the order of the files
+	encryptionHeaders := req.EncryptionHeaders
+	if !encrypted && len(encryptionHeaders) > 0 {
+		return nil, &uploadError{http.StatusBadRequest, gin.H{
+			"error": "encryption_header is only accepted for uploads to encrypted folders",
+			"code":  "NOT_ENCRYPTED_FOLDER",
+		}}
+	}
+	if encrypted && len(encryptionHeaders) != len(req.Parts) {
+		return nil, &uploadError{http.StatusBadRequest, gin.H{
+			"error": "Files uploaded to an encrypted folder need one encryption_header each",
+			"code":  "ENCRYPTION_HEADER_REQUIRED",
+		}}
+	}
+	for _, header := range encryptionHeaders {
+		if header == "" || len(header) > maxEncryptionHeaderLength {
+			return nil, &uploadError{http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("encryption_header must be between 1 and %d characters", maxEncryptionHeaderLength),
+				"code":  "ENCRYPTION_HEADER_REQUIRED",
+			}}
 		}
+	}
+	if req.ReplaceTarget != nil && len(req.Parts) != 1 {
+		return nil, &uploadError{http.StatusBadRequest, gin.H{"error": "Exactly one file must be uploaded to replace a file"}}
+	}
 
-		// Validate MIME type
-		declaredMimeType := fileHeader.Header.Get("Content-Type")
-		if declaredMimeType == "" {
-			declaredMimeType = "application/octet-stream"
+	// Check user storage quota and limits
+	upload := &preparedUpload{folderID: folderID}
+	if err := h.db.WithContext(c.Request.Context()).Preload("Plan").First(&upload.user, "id = ?", userID).Error; err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to get user"}}
+	}
+
+	if req.IsPublic && encrypted {
+		return nil, services.ErrEncryptedNotPublic
+	}
+	if req.IsPublic && !upload.user.AllowsPublicSharing() {
+		return nil, &uploadError{http.StatusForbidden, gin.H{
+			"error":   "Public sharing is not allowed",
+			"type":    "PLAN_RESTRICTION",
+			"message": "Your plan does not allow public files",
+			"code":    "PUBLIC_SHARING_NOT_IN_PLAN",
+		}}
+	}
+
+	// Load the admin-defined upload policies that apply to this user's role
+	policies, err := h.uploadPolicyService.ListPolicies(string(upload.user.Role))
+	if err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to load upload policies"}}
+	}
+	upload.checks = uploadChecks{
+		validator:   utils.NewMimeTypeValidator(h.cfg.MimeSniffBytes, h.cfg.MimeDeepInspectTypes),
+		encrypted:   encrypted,
+		isPublic:    req.IsPublic,
+		maxFileSize: upload.user.MaxUploadSize(h.cfg.MaxFileSize),
+		policies:    policies,
+	}
+	return upload, nil
+}
+
+// storeFiles checks, scans and stores the files of a prepared upload. It
+// returns the files with what their checks found, and what was stored
+func (h *FileHandler) storeFiles(c *gin.Context, userID uuid.UUID, req uploadRequest, upload *preparedUpload) ([]FileUploadInfo, *uploadOutcome, error) {
+	user := &upload.user
+	checks := upload.checks
+	replaceTarget := req.ReplaceTarget
+
+	// Uploads that name no folder are sent where the user's upload rules say
+	var routing *services.UploadRouting
+	var err error
+	if (req.FolderID == "" || req.FolderID == "null") && replaceTarget == nil {
+		if routing, err = h.uploadRuleService.ForUpload(userID); err != nil {
+			return nil, nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to load upload rules"}}
 		}
+	}
 
-		isValid, actualMimeType, warning := validator.ValidateMimeType(content, declaredMimeType, fileHeader.Filename)
+	// Validate each file and calculate total size
+	var uploadFiles []FileUploadInfo
+	var totalSize int64
 
-		if !isValid {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":             fmt.Sprintf("Invalid file type for %s", fileHeader.Filename),
-				"filename":          fileHeader.Filename,
-				"declared_mimetype": declaredMimeType,
-				"actual_mimetype":   actualMimeType,
-				"warning":           warning,
-			})
-			return
+	// Remove any staged content that was not moved into storage, whether the
+	// request fails part way through or a duplicate made the copy unnecessary
+	defer func() {
+		for _, uploadFile := range uploadFiles {
+			os.Remove(uploadFile.TempPath)
 		}
+	}()
 
-		// Check if MIME type is allowed (if configured)
-		if len(h.cfg.AllowedMimeTypes) > 0 && !validator.IsAllowedMimeType(actualMimeType, h.cfg.AllowedMimeTypes) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":         fmt.Sprintf("File type not allowed for %s", fileHeader.Filename),
-				"filename":      fileHeader.Filename,
-				"mimetype":      actualMimeType,
-				"allowed_types": h.cfg.AllowedMimeTypes,
-			})
-			return
+	for i, part := range req.Parts {
+		fileHeader := part.Header
+		var staged *utils.StagedFile
+		if part.Direct != nil {
+			// Content in the object store is only copied to disk for the
+			// scanners, which read from there
+			spool := !checks.encrypted && (h.malwareScanService.Enabled() || (h.dlpService != nil && h.dlpService.Enabled()))
+			staged, err = h.directUploadService.Inspect(c.Request.Context(), part.Direct, checks.validator.SniffLength(), spool)
+			if err != nil {
+				return nil, nil, err
+			}
+		} else {
+			file, err := part.Open()
+			if err != nil {
+				return nil, nil, &uploadError{http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("Failed to open file %s", fileHeader.Filename),
+				}}
+			}
+
+			// Stream file content to a temp file, hashing it on the way
+			staged, err = utils.StageReader(h.cfg.UploadTempDir, file, checks.validator.SniffLength())
+			file.Close()
+			if err != nil {
+				publishStorageError(c, "Failed to stage upload "+fileHeader.Filename, err)
+				return nil, nil, &uploadError{http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("Failed to read file %s", fileHeader.Filename),
+				}}
+			}
 		}
 
 		uploadFiles = append(uploadFiles, FileUploadInfo{
-			Header:   fileHeader,
-			Content:  content,
-			Size:     fileSize,
-			Hash:     h.calculateContentHash(content),
-			MimeType: actualMimeType,
-			IsValid:  isValid,
-			Warning:  warning,
+			Header:       fileHeader,
+			TempPath:     staged.Path,
+			Size:         staged.Size,
+			Hash:         staged.Hash,
+			DirectUpload: part.Direct,
 		})
+		uploadFile := &uploadFiles[len(uploadFiles)-1]
+		if checks.encrypted {
+			uploadFile.EncryptionHeader = req.EncryptionHeaders[i]
+		}
 
-		totalSize += fileSize
+		// Check the file's size and type and scan its content
+		if denial := h.inspectUploadFile(c, userID, uploadFile, staged, checks); denial != nil {
+			return nil, nil, h.deny(c, userID, denial)
+		}
+
+		uploadFile.FolderID = upload.folderID
+		if routing != nil {
+			uploadFile.FolderID = routing.Route(fileHeader.Filename, uploadFile.MimeType)
+		}
+
+		totalSize += uploadFile.Size
 	}
 
-	// Check total storage quota
-	if user.StorageUsed+totalSize > user.StorageQuota {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":         "Total upload size exceeds storage quota",
-			"total_size":    totalSize,
-			"storage_used":  user.StorageUsed,
-			"storage_quota": user.StorageQuota,
-			"available":     user.StorageQuota - user.StorageUsed,
-		})
-		return
+	// Check total storage quota, allowing the grace overage when available
+	quotaLimit := h.quotaGraceService.Limit(user)
+	if user.StorageUsed+totalSize > quotaLimit {
+		for _, uploadFile := range uploadFiles {
+			h.recordRejection(c, userID, models.UploadRejection{
+				Reason:           models.RejectionQuotaExceeded,
+				Code:             "QUOTA_EXCEEDED",
+				Message:          fmt.Sprintf("Upload of %d bytes exceeds remaining quota of %d bytes", totalSize, quotaLimit-user.StorageUsed),
+				Filename:         uploadFile.Header.Filename,
+				DeclaredMimeType: uploadFile.Header.Header.Get("Content-Type"),
+				DetectedMimeType: uploadFile.MimeType,
+				Size:             uploadFile.Size,
+			})
+		}
+		return nil, nil, &uploadError{http.StatusForbidden, middleware.QuotaExceededResponse(user.StorageQuota, user.StorageUsed, totalSize)}
 	}
 
-	// Process each file upload
-	var results []map[string]interface{}
+	// Members of a tenant also share their organization's storage quota
+	if err := h.tenantService.CheckQuota(user.TenantID, totalSize); err != nil {
+		if rejected := h.rejectTenantQuota(c, userID, uploadFiles, err); rejected != nil {
+			return nil, nil, rejected
+		}
+		return nil, nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to check organization storage quota"}}
+	}
+
+	// Check the size limits of the target folder and the folders above it.
+	// They are checked again as the upload is stored, in case another upload
+	// lands in between
+	if replaceTarget == nil {
+		folderSizes := make(map[uuid.UUID]int64)
+		for _, uploadFile := range uploadFiles {
+			if uploadFile.FolderID != nil {
+				folderSizes[*uploadFile.FolderID] += uploadFile.Size
+			}
+		}
+		for id, size := range folderSizes {
+			target := id
+			if err := services.CheckFolderQuota(h.db, &target, size); err != nil {
+				if rejected := h.rejectFolderQuota(c, userID, uploadFiles, err); rejected != nil {
+					return nil, nil, rejected
+				}
+				return nil, nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to check folder size limit"}}
+			}
+		}
+	}
+
+	outcome, err := h.saveUploads(c, userID, uploadFiles, checks.isPublic, replaceTarget, req.BaseRevision)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uploadFiles, outcome, nil
+}
+
+// uploadOutcome is what an upload stored
+type uploadOutcome struct {
+	results             []*UploadResultDTO
+	user                *models.User // with its storage stats after the upload
+	totalUploadedBytes  int64
+	totalSavedBytes     int64
+	totalStorageCharged int64 // added to the user's storage usage
+}
+
+// saveUploads stores checked files of an upload in one transaction, moves
+// their content into storage and starts the work that follows an upload
+func (h *FileHandler) saveUploads(c *gin.Context, userID uuid.UUID, uploadFiles []FileUploadInfo, isPublic bool, replaceTarget *models.File, baseRevision string) (outcome *uploadOutcome, err error) {
+	var results []*UploadResultDTO
 	var totalSavedBytes int64
 	var totalActualStorage int64
+	var totalStorageCharged int64
 	var totalUploadedBytes int64
 
 	// Start transaction for atomic operation
-	tx := h.db.Begin()
+	tx := h.db.WithContext(c.Request.Context()).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
+			outcome, err = nil, fmt.Errorf("storing upload panicked: %v", r)
 		}
 	}()
 
+	var quarantined []*models.FileQuarantine
+	var quarantinedNames []string
+
 	for _, uploadFile := range uploadFiles {
-		result, savedBytes, actualStorageUsed, err := h.processFileUpload(tx, uploadFile, userID.(uuid.UUID), folderID, isPublic)
+		var result *UploadResultDTO
+		if replaceTarget != nil {
+			result, err = h.processSyncUpload(tx, uploadFile, replaceTarget, baseRevision, userID)
+		} else {
+			result, err = h.processFileUpload(tx, uploadFile, userID, uploadFile.FolderID, isPublic)
+		}
 		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{
+			if rejected := h.rejectFolderQuota(c, userID, uploadFiles, err); rejected != nil {
+				return nil, rejected
+			}
+			publishStorageError(c, "Failed to store upload "+uploadFile.Header.Filename, err)
+			return nil, &uploadError{http.StatusInternalServerError, gin.H{
 				"error":    "Failed to process file upload",
 				"filename": uploadFile.Header.Filename,
 				"details":  err.Error(),
+			}}
+		}
+
+		// Record the upload with the files it creates
+		if err := h.auditService.LogFileUpload(tx, c, userID, result.ID, uploadFile.Header.Filename, uploadFile.Size); err != nil {
+			tx.Rollback()
+			return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to record upload"}}
+		}
+
+		// Lock files with sensitive content until an admin reviews them
+		if len(uploadFile.DLPFindings) > 0 && h.dlpService.Action() == services.DLPActionQuarantine {
+			entry, err := h.quarantineService.Quarantine(tx, services.QuarantineParams{
+				FileID:   result.ID,
+				OwnerID:  userID,
+				Filename: uploadFile.Header.Filename,
+				Source:   models.QuarantineSourceDLP,
+				Reason:   services.DescribeFindings(uploadFile.DLPFindings),
+				Findings: services.QuarantineFindings(uploadFile.DLPFindings),
 			})
-			return
+			if err != nil {
+				tx.Rollback()
+				return nil, &uploadError{http.StatusInternalServerError, gin.H{
+					"error":    "Failed to quarantine file",
+					"filename": uploadFile.Header.Filename,
+				}}
+			}
+			result.IsQuarantined = true
+			result.ProcessingStatus = models.ProcessingStatusQuarantined
+			quarantined = append(quarantined, entry)
+			quarantinedNames = append(quarantinedNames, uploadFile.Header.Filename)
+		}
+
+		results = append(results, result)
+		if !result.IsDuplicate {
+			totalActualStorage += uploadFile.Size
+		}
+		totalSavedBytes += result.SavedBytes
+		totalStorageCharged += result.StorageCharged
+		totalUploadedBytes += uploadFile.Size
+	}
+
+	// Update user storage statistics
+	updatedUser, err := h.updateUserStorageStats(tx, userID, totalUploadedBytes, totalActualStorage, totalStorageCharged, totalSavedBytes)
+	if err != nil {
+		tx.Rollback()
+		return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to update user storage stats"}}
+	}
+
+	// Going over quota within the grace overage opens the grace window
+	graceStarted, err := h.quotaGraceService.Begin(tx, updatedUser)
+	if err != nil {
+		tx.Rollback()
+		return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to update user storage stats"}}
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, &uploadError{http.StatusInternalServerError, gin.H{"error": "Failed to commit upload transaction"}}
+	}
+
+	h.placeUploadContent(c, uploadFiles)
+
+	// Record sensitive content findings and alert reviewers
+	for i, uploadFile := range uploadFiles {
+		if len(uploadFile.DLPFindings) == 0 {
+			continue
+		}
+		fileID := results[i].ID
+		h.dlpService.LogFindings(c, userID, &fileID, uploadFile.Header.Filename, h.dlpService.Action(), uploadFile.DLPFindings)
+	}
+	for i, entry := range quarantined {
+		h.quarantineService.NotifyQuarantined(entry, quarantinedNames[i])
+	}
+	if graceStarted {
+		h.quotaGraceService.NotifyStarted(updatedUser)
+	}
+
+	for _, result := range results {
+		publishUpload(c, userID, result)
+		if result.IsPublic && !result.IsQuarantined {
+			h.prewarmService.WarmFile(result.ID)
+		}
+	}
+
+	// Queue the new content for text extraction and OCR, which encrypted
+	// content would only fill with noise
+	uploadedIDs := make([]uuid.UUID, 0, len(results))
+	for _, result := range results {
+		if !result.IsEncrypted {
+			uploadedIDs = append(uploadedIDs, result.ID)
+		}
+	}
+	if err := h.contentIndexService.EnqueueFiles(uploadedIDs); err != nil {
+		fmt.Printf("Failed to queue content index: %v\n", err)
+	}
+
+	// Blobs scanned on upload are skipped by the background malware scan
+	scannedIDs := make([]uuid.UUID, 0, len(results))
+	for i, uploadFile := range uploadFiles {
+		if uploadFile.MalwareScanned {
+			scannedIDs = append(scannedIDs, results[i].ID)
+		}
+	}
+	if err := h.malwareScanService.MarkFilesScanned(scannedIDs); err != nil {
+		fmt.Printf("Failed to record malware scan: %v\n", err)
+	}
+
+	return &uploadOutcome{
+		results:             results,
+		user:                updatedUser,
+		totalUploadedBytes:  totalUploadedBytes,
+		totalSavedBytes:     totalSavedBytes,
+		totalStorageCharged: totalStorageCharged,
+	}, nil
+}
+
+// uploadResponse reports deduplication of a stored upload in headers and
+// returns the response body listing its files
+func (h *FileHandler) uploadResponse(c *gin.Context, uploadFiles []FileUploadInfo, outcome *uploadOutcome) gin.H {
+	// Report deduplication for the whole request in headers, so clients can
+	// show savings without parsing the per-file results
+	dedupHit := false
+	for _, result := range outcome.results {
+		dedupHit = dedupHit || result.IsDuplicate
+	}
+	c.Header("X-Dedup-Hit", strconv.FormatBool(dedupHit))
+	c.Header("X-Saved-Bytes", strconv.FormatInt(outcome.totalSavedBytes, 10))
+	c.Header("X-Storage-Charged", strconv.FormatInt(outcome.totalStorageCharged, 10))
+
+	// Return results
+	response := gin.H{
+		"message":               "Files uploaded successfully",
+		"uploaded_files_count":  len(outcome.results),
+		"total_size":            outcome.totalUploadedBytes,
+		"total_saved_bytes":     outcome.totalSavedBytes,
+		"total_storage_charged": outcome.totalStorageCharged,
+		"files":                 outcome.results,
+	}
+
+	// Add warnings if any
+	warnings := []string{}
+	for _, uploadFile := range uploadFiles {
+		if uploadFile.Warning != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", uploadFile.Header.Filename, uploadFile.Warning))
+		}
+	}
+	if outcome.user.InQuotaGrace(time.Now()) {
+		warnings = append(warnings, h.quotaGraceService.Warning(outcome.user))
+		response["quota_grace_expires_at"] = outcome.user.QuotaGraceExpiresAt
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+
+	return response
+}
+
+// uploadChecks is what each file of an upload is checked against
+type uploadChecks struct {
+	validator   *utils.MimeTypeValidator
+	encrypted   bool
+	isPublic    bool
+	maxFileSize int64
+	policies    []models.UploadPolicy
+}
+
+// uploadDenial is why a file of an upload was refused: the response for the
+// client and the rejection recorded for admins
+type uploadDenial struct {
+	status    int
+	body      gin.H
+	rejection models.UploadRejection
+}
+
+// deny records a refused upload file and returns the error refusing it
+func (h *FileHandler) deny(c *gin.Context, userID uuid.UUID, denial *uploadDenial) error {
+	h.recordRejection(c, userID, denial.rejection)
+	return &uploadError{denial.status, denial.body}
+}
+
+// inspectUploadFile checks a staged file of an upload against the size
+// limit, its extension, the allowed types and the upload policies, and scans
+// it for sensitive content and malware. It fills in the file's type and what
+// the scans found, and returns why the file is refused, or nil
+func (h *FileHandler) inspectUploadFile(c *gin.Context, userID uuid.UUID, uploadFile *FileUploadInfo, staged *utils.StagedFile, checks uploadChecks) *uploadDenial {
+	fileHeader := uploadFile.Header
+	fileSize := staged.Size
+
+	// Validate file size
+	if fileSize > checks.maxFileSize {
+		return &uploadDenial{
+			status: http.StatusBadRequest,
+			body: gin.H{
+				"error":     fmt.Sprintf("File %s exceeds size limit", fileHeader.Filename),
+				"max_size":  checks.maxFileSize,
+				"file_size": fileSize,
+			},
+			rejection: models.UploadRejection{
+				Reason:           models.RejectionSizeExceeded,
+				Message:          fmt.Sprintf("File exceeds the maximum size of %d bytes", checks.maxFileSize),
+				Filename:         fileHeader.Filename,
+				DeclaredMimeType: fileHeader.Header.Get("Content-Type"),
+				Size:             fileSize,
+			},
+		}
+	}
+
+	// Validate MIME type
+	declaredMimeType := fileHeader.Header.Get("Content-Type")
+	if declaredMimeType == "" {
+		declaredMimeType = "application/octet-stream"
+	}
+
+	// Ciphertext has no type of its own, so it is neither sniffed nor
+	// checked against the declared type or the allowed types
+	isValid, actualMimeType, warning := true, encryptedMimeType, ""
+	if !checks.encrypted {
+		isValid, actualMimeType, warning = checks.validator.ValidateMimeType(staged.Head, declaredMimeType, fileHeader.Filename)
+	}
+
+	if !isValid {
+		return &uploadDenial{
+			status: http.StatusBadRequest,
+			body: gin.H{
+				"error":             fmt.Sprintf("Invalid file type for %s", fileHeader.Filename),
+				"filename":          fileHeader.Filename,
+				"declared_mimetype": declaredMimeType,
+				"actual_mimetype":   actualMimeType,
+				"warning":           warning,
+			},
+			rejection: models.UploadRejection{
+				Reason:           models.RejectionMimeMismatch,
+				Message:          warning,
+				Filename:         fileHeader.Filename,
+				DeclaredMimeType: declaredMimeType,
+				DetectedMimeType: actualMimeType,
+				Size:             fileSize,
+			},
+		}
+	}
+
+	// Check if MIME type is allowed (if configured)
+	if !checks.encrypted && len(h.cfg.AllowedMimeTypes) > 0 && !checks.validator.IsAllowedMimeType(actualMimeType, h.cfg.AllowedMimeTypes) {
+		return &uploadDenial{
+			status: http.StatusBadRequest,
+			body: gin.H{
+				"error":         fmt.Sprintf("File type not allowed for %s", fileHeader.Filename),
+				"filename":      fileHeader.Filename,
+				"mimetype":      actualMimeType,
+				"allowed_types": h.cfg.AllowedMimeTypes,
+			},
+			rejection: models.UploadRejection{
+				Reason:           models.RejectionMimeNotAllowed,
+				Message:          "File type is not in the allowed list",
+				Filename:         fileHeader.Filename,
+				DeclaredMimeType: declaredMimeType,
+				DetectedMimeType: actualMimeType,
+				Size:             fileSize,
+			},
+		}
+	}
+
+	// Enforce upload policies for this file type
+	decision := services.EvaluatePolicies(checks.policies, services.PolicyFile{
+		Filename: fileHeader.Filename,
+		MimeType: actualMimeType,
+		Size:     fileSize,
+	}, checks.isPublic)
+	if !decision.Allowed {
+		status := http.StatusForbidden
+		if decision.Code == services.PolicyCodeSizeExceeded {
+			status = http.StatusRequestEntityTooLarge
+		}
+		return &uploadDenial{
+			status: status,
+			body: gin.H{
+				"error":    fmt.Sprintf("Upload policy violation for %s", fileHeader.Filename),
+				"type":     "UPLOAD_POLICY_VIOLATION",
+				"message":  decision.Reason,
+				"filename": fileHeader.Filename,
+				"mimetype": actualMimeType,
+				"max_size": decision.MaxSize,
+				"code":     decision.Code,
+			},
+			rejection: models.UploadRejection{
+				Reason:           models.RejectionPolicyViolation,
+				Code:             decision.Code,
+				Message:          decision.Reason,
+				Filename:         fileHeader.Filename,
+				DeclaredMimeType: declaredMimeType,
+				DetectedMimeType: actualMimeType,
+				Size:             fileSize,
+			},
+		}
+	}
+
+	uploadFile.MimeType = actualMimeType
+	uploadFile.IsValid = isValid
+	uploadFile.Warning = warning
+
+	// Inspect text content for sensitive data. Encrypted content cannot be
+	// inspected, so it skips both DLP and malware scanning
+	if h.dlpService != nil && h.dlpService.Enabled() && !checks.encrypted {
+		findings, err := h.dlpService.ScanFile(c.Request.Context(), staged.Path, actualMimeType)
+		if err != nil {
+			fmt.Printf("DLP scan failed for %s: %v\n", fileHeader.Filename, err)
+			if h.cfg.DLPFailClosed {
+				return &uploadDenial{
+					status: http.StatusServiceUnavailable,
+					body: gin.H{
+						"error":    fmt.Sprintf("Unable to scan %s for sensitive content", fileHeader.Filename),
+						"type":     "CONTENT_SCAN_UNAVAILABLE",
+						"message":  "Content inspection is temporarily unavailable. Please try again later.",
+						"filename": fileHeader.Filename,
+						"code":     "DLP_SCAN_FAILED",
+					},
+					rejection: models.UploadRejection{
+						Reason:           models.RejectionScanUnavailable,
+						Code:             "DLP_SCAN_FAILED",
+						Message:          err.Error(),
+						Filename:         fileHeader.Filename,
+						DeclaredMimeType: declaredMimeType,
+						DetectedMimeType: actualMimeType,
+						Size:             fileSize,
+					},
+				}
+			}
+		}
+
+		if len(findings) > 0 {
+			if h.dlpService.Action() == services.DLPActionBlock {
+				h.dlpService.LogFindings(c, userID, nil, fileHeader.Filename, services.DLPActionBlock, findings)
+				return &uploadDenial{
+					status: http.StatusForbidden,
+					body: gin.H{
+						"error":    fmt.Sprintf("Sensitive content detected in %s", fileHeader.Filename),
+						"type":     "SENSITIVE_CONTENT_DETECTED",
+						"message":  services.DescribeFindings(findings),
+						"filename": fileHeader.Filename,
+						"findings": findings,
+						"code":     "DLP_BLOCKED",
+					},
+					rejection: models.UploadRejection{
+						Reason:           models.RejectionSensitiveContent,
+						Code:             "DLP_BLOCKED",
+						Message:          services.DescribeFindings(findings),
+						Filename:         fileHeader.Filename,
+						DeclaredMimeType: declaredMimeType,
+						DetectedMimeType: actualMimeType,
+						Size:             fileSize,
+					},
+				}
+			}
+
+			uploadFile.DLPFindings = findings
+			dlpWarning := services.DescribeFindings(findings)
+			if uploadFile.Warning != "" {
+				uploadFile.Warning += "; " + dlpWarning
+			} else {
+				uploadFile.Warning = dlpWarning
+			}
+		}
+	}
+
+	// Reject malware before it reaches storage. When the scanner is down the
+	// upload is accepted and the stored blob is scanned later.
+	if h.malwareScanService.Enabled() && !checks.encrypted {
+		scan, err := h.malwareScanService.ScanFile(c.Request.Context(), staged.Path)
+		switch {
+		case err != nil:
+			fmt.Printf("Malware scan failed for %s: %v\n", fileHeader.Filename, err)
+			if h.cfg.MalwareFailClosed {
+				return &uploadDenial{
+					status: http.StatusServiceUnavailable,
+					body: gin.H{
+						"error":    fmt.Sprintf("Unable to scan %s for malware", fileHeader.Filename),
+						"type":     "CONTENT_SCAN_UNAVAILABLE",
+						"message":  "Malware scanning is temporarily unavailable. Please try again later.",
+						"filename": fileHeader.Filename,
+						"code":     "MALWARE_SCAN_FAILED",
+					},
+					rejection: models.UploadRejection{
+						Reason:           models.RejectionScanUnavailable,
+						Code:             "MALWARE_SCAN_FAILED",
+						Message:          err.Error(),
+						Filename:         fileHeader.Filename,
+						DeclaredMimeType: declaredMimeType,
+						DetectedMimeType: actualMimeType,
+						Size:             fileSize,
+					},
+				}
+			}
+		case scan.Infected:
+			return &uploadDenial{
+				status: http.StatusForbidden,
+				body: gin.H{
+					"error":     fmt.Sprintf("Malware detected in %s", fileHeader.Filename),
+					"type":      "MALWARE_DETECTED",
+					"message":   services.DescribeMalware(scan),
+					"filename":  fileHeader.Filename,
+					"signature": scan.Signature,
+					"code":      "MALWARE_DETECTED",
+				},
+				rejection: models.UploadRejection{
+					Reason:           models.RejectionMalwareDetected,
+					Code:             "MALWARE_DETECTED",
+					Message:          services.DescribeMalware(scan),
+					Filename:         fileHeader.Filename,
+					DeclaredMimeType: declaredMimeType,
+					DetectedMimeType: actualMimeType,
+					Size:             fileSize,
+				},
+			}
+		default:
+			uploadFile.MalwareScanned = true
 		}
+	}
 
-		results = append(results, result)
-		totalSavedBytes += savedBytes
-		totalActualStorage += actualStorageUsed
-		totalUploadedBytes += uploadFile.Size
+	return nil
+}
+
+// uploadProcessingStatus is the processing status of a file stored from an
+// upload. Content that was scanned on upload, or whose blob was already
+// scanned clean, needs nothing more; otherwise it waits for the background
+// scan. Encrypted content is never scanned, since the scanner would only see
+// ciphertext.
+func (h *FileHandler) uploadProcessingStatus(uploadFile FileUploadInfo, fileHash *models.FileHash) models.ProcessingStatus {
+	if !h.malwareScanService.Enabled() || uploadFile.EncryptionHeader != "" || uploadFile.MalwareScanned {
+		return models.ProcessingStatusClean
+	}
+	if fileHash.MalwareScannedAt != nil && fileHash.MalwareSignature == "" {
+		return models.ProcessingStatusClean
 	}
+	return models.ProcessingStatusPendingScan
+}
 
-	// Update user storage statistics
-	if err := h.updateUserStorageStats(tx, userID.(uuid.UUID), totalUploadedBytes, totalActualStorage, totalSavedBytes); err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user storage stats"})
-		return
+// processFileUpload handles the upload of a single file within a transaction
+func (h *FileHandler) processFileUpload(tx *gorm.DB, uploadFile FileUploadInfo, userID uuid.UUID, folderID *uuid.UUID, isPublic bool) (*UploadResultDTO, error) {
+	existingHash, isNewContent, err := h.storeUploadContent(tx, uploadFile)
+	if err != nil {
+		return nil, err
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit upload transaction"})
-		return
+	// Create file record
+	fileRecord := models.File{
+		BaseModel: models.BaseModel{
+			ID: uuid.New(),
+		},
+		Filename:         generateUniqueFilename(uploadFile.Header.Filename),
+		OriginalFilename: uploadFile.Header.Filename,
+		MimeType:         uploadFile.MimeType,
+		Size:             uploadFile.Size,
+		FileHashID:       existingHash.ID,
+		OwnerID:          userID,
+		FolderID:         folderID,
+		IsPublic:         isPublic,
+		ProcessingStatus: h.uploadProcessingStatus(uploadFile, &existingHash),
+		IsEncrypted:      uploadFile.EncryptionHeader != "",
+		EncryptionHeader: uploadFile.EncryptionHeader,
 	}
 
-	// Log audit activities for successful uploads
-	if h.auditService != nil {
-		for _, result := range results {
-			if fileID, ok := result["id"].(uuid.UUID); ok {
-				if filename, ok := result["original_filename"].(string); ok {
-					if fileSize, ok := result["size"].(int64); ok {
-						// Log the upload activity (non-blocking)
-						go func(fid uuid.UUID, fname string, fsize int64) {
-							if err := h.auditService.LogFileUpload(c, userID.(uuid.UUID), fid, fname, fsize); err != nil {
-								// Log error but don't fail the upload
-								fmt.Printf("Failed to log upload audit: %v\n", err)
-							}
-						}(fileID, filename, fileSize)
-					}
-				}
-			}
+	if err := tx.Create(&fileRecord).Error; err != nil {
+		// If file record creation fails and this was new content, decrement reference count
+		if isNewContent {
+			tx.Model(&models.FileHash{}).Where("hash = ?", uploadFile.Hash).Update("reference_count", gorm.Expr("reference_count - 1"))
 		}
+		return nil, fmt.Errorf("failed to create file record: %v", err)
 	}
 
-	// Return results
-	response := gin.H{
-		"message":              "Files uploaded successfully",
-		"uploaded_files_count": len(results),
-		"total_size":           totalUploadedBytes,
-		"total_saved_bytes":    totalSavedBytes,
-		"files":                results,
+	// Count the file towards its folders, enforcing their size limits
+	if err := services.AdjustFolderStats(tx, folderID, 1, fileRecord.Size); err != nil {
+		return nil, err
 	}
 
-	// Add warnings if any
-	warnings := []string{}
-	for _, uploadFile := range uploadFiles {
-		if uploadFile.Warning != "" {
-			warnings = append(warnings, fmt.Sprintf("%s: %s", uploadFile.Header.Filename, uploadFile.Warning))
-		}
+	// Content the user already holds in another file adds nothing to their usage
+	charged, err := services.ContentCharge(tx, userID, existingHash.ID, fileRecord.ID, fileRecord.Size)
+	if err != nil {
+		return nil, err
 	}
-	if len(warnings) > 0 {
-		response["warnings"] = warnings
+
+	// Files uploaded into a WORM folder are locked for its retention period
+	if err := h.retentionService.LockFile(tx, &fileRecord); err != nil {
+		return nil, fmt.Errorf("failed to apply retention: %v", err)
 	}
 
-	c.JSON(http.StatusOK, response)
+	if err := services.RecordFileEvent(tx, services.FileEventParams{
+		FileID:  fileRecord.ID,
+		OwnerID: userID,
+		ActorID: &userID,
+		Type:    models.FileEventCreated,
+		Payload: models.FileEventPayload{
+			"filename":     fileRecord.OriginalFilename,
+			"size":         fileRecord.Size,
+			"mime_type":    fileRecord.MimeType,
+			"folder_id":    fileRecord.FolderID,
+			"is_duplicate": !isNewContent,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	result := newUploadResultDTO(&fileRecord, uploadFile, isNewContent, charged)
+	result.RetainUntil = fileRecord.RetainUntil
+	return result, nil
 }
 
-// processFileUpload handles the upload of a single file within a transaction
-func (h *FileHandler) processFileUpload(tx *gorm.DB, uploadFile FileUploadInfo, userID uuid.UUID, folderID *uuid.UUID, isPublic bool) (map[string]interface{}, int64, int64, error) {
+// storeUploadContent references the stored blob of an upload's content and
+// reports whether the content is new. The staged copy is moved into storage
+// by placeUploadContent only after the transaction commits, so storage never
+// holds a blob the database does not know about.
+func (h *FileHandler) storeUploadContent(tx *gorm.DB, uploadFile FileUploadInfo) (models.FileHash, bool, error) {
 	// Check if file hash already exists (deduplication)
 	var existingHash models.FileHash
 	isNewContent := false
@@ -436,20 +1362,8 @@ func (h *FileHandler) processFileUpload(tx *gorm.DB, uploadFile FileUploadInfo,
 		// Content doesn't exist, create new hash record
 		isNewContent = true
 
-		// Store file physically only if it's new content
-		storagePath := fmt.Sprintf("storage/%s", uploadFile.Hash)
-
-		// Create storage directory if it doesn't exist
-		fullStoragePath := filepath.Join(h.cfg.StoragePath, storagePath)
-		storageDir := filepath.Dir(fullStoragePath)
-		if err := os.MkdirAll(storageDir, 0755); err != nil {
-			return nil, 0, 0, fmt.Errorf("failed to create storage directory: %v", err)
-		}
-
-		// Write file content to disk
-		if err := os.WriteFile(fullStoragePath, uploadFile.Content, 0644); err != nil {
-			return nil, 0, 0, fmt.Errorf("failed to write file to storage: %v", err)
-		}
+		// The staged content is stored here once the transaction commits
+		storagePath := services.BlobStoragePath(uploadFile.Hash)
 
 		newHash := models.FileHash{
 			ID:             uuid.New(),
@@ -460,94 +1374,79 @@ func (h *FileHandler) processFileUpload(tx *gorm.DB, uploadFile FileUploadInfo,
 		}
 
 		if err := tx.Create(&newHash).Error; err != nil {
-			return nil, 0, 0, fmt.Errorf("failed to save file hash: %v", err)
+			return models.FileHash{}, false, fmt.Errorf("failed to save file hash: %v", err)
 		}
 		existingHash = newHash
 	} else if err != nil {
-		return nil, 0, 0, fmt.Errorf("database error: %v", err)
+		return models.FileHash{}, false, fmt.Errorf("database error: %v", err)
 	} else {
-		// Content already exists, increment reference count
-		if err := tx.Model(&existingHash).Update("reference_count", gorm.Expr("reference_count + 1")).Error; err != nil {
-			return nil, 0, 0, fmt.Errorf("failed to update reference count: %v", err)
+		// Content whose files were all deleted is stored afresh
+		isNewContent = existingHash.ReferenceCount <= 0
+
+		// Content already exists, increment reference count. Content the
+		// orphan blob sweep removed is stored again after the commit
+		if err := tx.Model(&existingHash).Updates(map[string]interface{}{
+			"reference_count":    gorm.Expr("reference_count + 1"),
+			"content_removed_at": nil,
+		}).Error; err != nil {
+			return models.FileHash{}, false, fmt.Errorf("failed to update reference count: %v", err)
+		}
+
+		// The uploaded bytes are the archived content, so bring the blob back
+		// to primary storage instead of leaving the new file archived. The
+		// staged copy fills primary storage after the commit
+		if existingHash.StorageTier != "" && existingHash.StorageTier != models.StorageTierHot {
+			if err := services.MarkBlobHot(tx, existingHash.ID); err != nil {
+				return models.FileHash{}, false, err
+			}
 		}
 	}
 
-	// Create file record
-	fileRecord := models.File{
-		BaseModel: models.BaseModel{
-			ID: uuid.New(),
-		},
-		Filename:         generateUniqueFilename(uploadFile.Header.Filename),
-		OriginalFilename: uploadFile.Header.Filename,
-		MimeType:         uploadFile.MimeType,
-		Size:             uploadFile.Size,
-		FileHashID:       existingHash.ID,
-		OwnerID:          userID,
-		FolderID:         folderID,
-		IsPublic:         isPublic,
-	}
+	return existingHash, isNewContent, nil
+}
 
-	if err := tx.Create(&fileRecord).Error; err != nil {
-		// If file record creation fails and this was new content, decrement reference count
-		if isNewContent {
-			tx.Model(&models.FileHash{}).Where("hash = ?", uploadFile.Hash).Update("reference_count", gorm.Expr("reference_count - 1"))
+// placeUploadContent moves committed uploads' staged content into storage.
+// A staged copy that cannot be moved is kept for the startup recovery to
+// retry rather than removed with the rest. Content uploaded straight to the
+// object store is copied to its blob and the direct upload discarded, or kept
+// until it expires when the copy fails.
+func (h *FileHandler) placeUploadContent(c *gin.Context, uploadFiles []FileUploadInfo) {
+	for i := range uploadFiles {
+		if direct := uploadFiles[i].DirectUpload; direct != nil {
+			if _, err := services.PlaceObjectBlob(h.db, h.cfg, uploadFiles[i].Hash, direct.ObjectKey); err != nil {
+				publishStorageError(c, "Failed to copy upload "+uploadFiles[i].Header.Filename+" into storage", err)
+			} else if err := h.directUploadService.Delete(direct); err != nil {
+				fmt.Printf("Failed to delete finalized direct upload %s: %v\n", direct.ID, err)
+			}
+			continue
+		}
+		if _, err := services.PlaceStagedBlob(h.db, h.cfg, uploadFiles[i].Hash, uploadFiles[i].TempPath); err != nil {
+			publishStorageError(c, "Failed to move upload "+uploadFiles[i].Header.Filename+" into storage", err)
+			uploadFiles[i].TempPath = ""
 		}
-		return nil, 0, 0, fmt.Errorf("failed to create file record: %v", err)
-	}
-
-	// Calculate savings and storage
-	savedBytes := int64(0)
-	actualStorageUsed := int64(0)
-
-	if !isNewContent {
-		savedBytes = uploadFile.Size // User saved the full file size due to deduplication
-	} else {
-		actualStorageUsed = uploadFile.Size // New storage used
-	}
-
-	result := map[string]interface{}{
-		"file_id":       fileRecord.ID,
-		"filename":      fileRecord.Filename,
-		"original_name": fileRecord.OriginalFilename,
-		"size":          fileRecord.Size,
-		"mime_type":     fileRecord.MimeType,
-		"content_hash":  uploadFile.Hash,
-		"is_duplicate":  !isNewContent,
-		"saved_bytes":   savedBytes,
-		"is_public":     fileRecord.IsPublic,
-	}
-
-	if uploadFile.Warning != "" {
-		result["warning"] = uploadFile.Warning
 	}
-
-	return result, savedBytes, actualStorageUsed, nil
 }
 
 // updateUserStorageStats updates user storage statistics within a transaction
-func (h *FileHandler) updateUserStorageStats(tx *gorm.DB, userID uuid.UUID, totalUploadedBytes, totalActualStorage, totalSavedBytes int64) error {
+// and returns the updated user. totalActualStorage is content new to storage
+// and totalStorageCharged content new to the user.
+func (h *FileHandler) updateUserStorageStats(tx *gorm.DB, userID uuid.UUID, totalUploadedBytes, totalActualStorage, totalStorageCharged, totalSavedBytes int64) (*models.User, error) {
 	var user models.User
 	if err := tx.First(&user, userID).Error; err != nil {
-		return fmt.Errorf("failed to find user: %v", err)
+		return nil, fmt.Errorf("failed to find user: %v", err)
 	}
 
 	// Update user storage statistics
 	user.TotalUploadedBytes += totalUploadedBytes
 	user.ActualStorageBytes += totalActualStorage
-	user.StorageUsed += totalActualStorage
+	user.StorageUsed += totalStorageCharged
 	user.SavedBytes += totalSavedBytes
 
 	if err := tx.Save(&user).Error; err != nil {
-		return fmt.Errorf("failed to update user storage stats: %v", err)
+		return nil, fmt.Errorf("failed to update user storage stats: %v", err)
 	}
 
-	return nil
-}
-
-// calculateContentHash calculates SHA-256 hash of file content
-func (h *FileHandler) calculateContentHash(data []byte) string {
-	hash := sha256.Sum256(data)
-	return fmt.Sprintf("%x", hash[:])
+	return &user, nil
 }
 
 // ListFiles handles listing user files with advanced search and filtering
@@ -567,13 +1466,14 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 	endDate := c.Query("end_date")      // End date for date range
 	tags := c.Query("tags")             // Filter by tags (comma-separated)
 	uploaderName := c.Query("uploader") // Filter by uploader's name
-	sortBy := c.Query("sort_by")        // Sort field (name, size, date, mime_type)
+	sortBy := c.Query("sort_by")        // Sort fields (name, size, date, modified, mime, owner, folder)
 	sortOrder := c.Query("sort_order")  // Sort order (asc, desc)
 	page := c.Query("page")             // Page number for pagination
 	limit := c.Query("limit")           // Items per page
 
 	// Get folder filter from query parameter
 	folderIDStr := c.Query("folder_id")
+	recursive := c.Query("recursive") == "true" // Include files in subfolders of folder_id
 
 	// Set default pagination values
 	pageNum := 1
@@ -591,8 +1491,14 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 		}
 	}
 
+	shape, message := parseResponseShape(c, FileDTO{}, fileRelations, fileRelations)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
 	// Build base query
-	query := h.db.Model(&models.File{}).Where("is_deleted = false")
+	query := h.db.WithContext(c.Request.Context()).Model(&models.File{})
 
 	// Handle folder filtering and permissions
 	if folderIDStr != "" && folderIDStr != "root" && folderIDStr != "null" {
@@ -602,67 +1508,59 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 			return
 		}
 
-		// Check folder access (owned or shared)
-		var folder models.Folder
-		err = h.db.Where("id = ? AND owner_id = ?", folderUUID, userID).First(&folder).Error
-
-		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// Check if folder is shared with user
-				var folderShare models.FolderShare
-				err = h.db.Where("folder_id = ? AND shared_with = ?", folderUUID, userID).First(&folderShare).Error
-				if err != nil {
-					if err == gorm.ErrRecordNotFound {
-						c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found or access denied"})
-						return
-					}
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder access"})
-					return
-				}
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder ownership"})
-				return
-			}
+		// Owners and users the folder is shared with see all its files
+		if _, err := h.accessService.CanViewFolder(c.Request.Context(), userID.(uuid.UUID), folderUUID); err != nil {
+			respondAccessError(c, err)
+			return
 		}
 
-		query = query.Where("folder_id = ?", folderUUID)
+		if recursive {
+			// A folder share covers only the files directly inside it, so
+			// files further down are limited to the ones the user can view
+			query = query.Scopes(services.VisibleFiles(userID.(uuid.UUID))).
+				Where("files.folder_id IN ("+folderSubtreeSQL+")", []uuid.UUID{folderUUID})
+		} else {
+			query = query.Where("files.folder_id = ?", folderUUID)
+		}
 	} else {
 		// Show files user owns or has access to
 		if folderIDStr == "root" || folderIDStr == "null" {
-			query = query.Where("owner_id = ? AND folder_id IS NULL", userID)
+			query = query.Scopes(services.OwnedFiles(userID.(uuid.UUID))).Where("files.folder_id IS NULL")
 		} else {
 			// Show all files user has access to (owned + shared)
-			query = query.Where("owner_id = ? OR id IN (SELECT file_id FROM file_shares WHERE shared_with = ?)", userID, userID)
+			query = query.Scopes(services.VisibleFiles(userID.(uuid.UUID)))
 		}
 	}
 
-	// Apply search filters
+	// Apply search filters. End-to-end encrypted files are left to the
+	// clients holding their keys
 	if searchQuery != "" {
 		searchPattern := "%" + strings.ToLower(searchQuery) + "%"
-		query = query.Where("LOWER(original_filename) LIKE ? OR LOWER(description) LIKE ?", searchPattern, searchPattern)
+		query = query.Where("(LOWER(files.original_filename) LIKE ? OR LOWER(files.description) LIKE ? OR files.file_hash_id IN ("+contentMatchSQL(h.db)+"))", searchPattern, searchPattern, searchQuery).
+			Where("files.is_encrypted = false")
 	}
 
 	if mimeType != "" {
-		query = query.Where("mime_type LIKE ?", mimeType+"%")
+		query = query.Where("files.mime_type LIKE ?", mimeType+"%")
 	}
 
 	// Size range filters
 	if minSize != "" {
 		if size, err := strconv.ParseInt(minSize, 10, 64); err == nil {
-			query = query.Where("size >= ?", size)
+			query = query.Where("files.size >= ?", size)
 		}
 	}
 
 	if maxSize != "" {
 		if size, err := strconv.ParseInt(maxSize, 10, 64); err == nil {
-			query = query.Where("size <= ?", size)
+			query = query.Where("files.size <= ?", size)
 		}
 	}
 
 	// Date range filters
 	if startDate != "" {
 		if date, err := time.Parse("2006-01-02", startDate); err == nil {
-			query = query.Where("created_at >= ?", date)
+			query = query.Where("files.created_at >= ?", date)
 		}
 	}
 
@@ -670,17 +1568,17 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 		if date, err := time.Parse("2006-01-02", endDate); err == nil {
 			// Add 24 hours to include the entire end date
 			endDateTime := date.Add(24 * time.Hour)
-			query = query.Where("created_at < ?", endDateTime)
+			query = query.Where("files.created_at < ?", endDateTime)
 		}
 	}
 
-	// Tags filter (if tags are stored as JSON or comma-separated)
+	// Tags filter: every tag must be set by the user or the classifier
 	if tags != "" {
 		tagList := strings.Split(tags, ",")
 		for _, tag := range tagList {
 			tag = strings.TrimSpace(tag)
 			if tag != "" {
-				query = query.Where("tags LIKE ?", "%"+tag+"%")
+				query = query.Where(services.TagMatchSQL(h.db), tag, tag)
 			}
 		}
 	}
@@ -694,23 +1592,13 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 	}
 
 	// Apply sorting
-	orderClause := "original_filename ASC" // default
-	if sortBy != "" {
-		validSortFields := map[string]string{
-			"name":      "original_filename",
-			"size":      "size",
-			"date":      "created_at",
-			"mime_type": "mime_type",
-			"modified":  "updated_at",
-		}
-
-		if field, valid := validSortFields[sortBy]; valid {
-			direction := "ASC"
-			if sortOrder == "desc" {
-				direction = "DESC"
-			}
-			orderClause = field + " " + direction
-		}
+	sort, err := fileSorts.parse(sortBy, sortOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": sortErrorMessage(err)})
+		return
+	}
+	if sort.has("owner") && uploaderName == "" {
+		query = query.Joins("JOIN users ON files.owner_id = users.id")
 	}
 
 	// Get total count for pagination
@@ -725,9 +1613,8 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 	offset := (pageNum - 1) * limitNum
 	var files []models.File
 
-	if err := query.Preload("Folder").
-		Preload("Owner").
-		Order(orderClause).
+	if err := query.Scopes(preloadFileRelations(shape)).
+		Order(sort.clause).
 		Offset(offset).
 		Limit(limitNum).
 		Find(&files).Error; err != nil {
@@ -735,13 +1622,28 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 		return
 	}
 
+	if shape.wants("sha256") {
+		if err := h.attachChecksums(c.Request.Context(), files); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file checksums"})
+			return
+		}
+	}
+
+	items, err := shape.apply(NewFileDTOs(files))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build response"})
+		return
+	}
+
 	// Calculate pagination info
 	totalPages := int((totalCount + int64(limitNum) - 1) / int64(limitNum))
 	hasNext := pageNum < totalPages
 	hasPrev := pageNum > 1
 
+	h.auditService.LogListAccess(c, models.AuditResourceFile, nil, len(files), nil)
+
 	c.JSON(http.StatusOK, gin.H{
-		"files":       files,
+		"files":       items,
 		"count":       len(files),
 		"total_count": totalCount,
 		"pagination": gin.H{
@@ -760,6 +1662,7 @@ func (h *FileHandler) ListFiles(c *gin.Context) {
 			"end_date":   endDate,
 			"tags":       tags,
 			"uploader":   uploaderName,
+			"recursive":  recursive,
 			"sort_by":    sortBy,
 			"sort_order": sortOrder,
 		},
@@ -774,164 +1677,347 @@ func (h *FileHandler) GetFile(c *gin.Context) {
 		return
 	}
 
-	fileID := c.Param("id")
+	access := h.fileAccess(c, userID.(uuid.UUID), h.accessService.CanView)
+	if access == nil {
+		return
+	}
+	file := *access.File
 
-	var file models.File
-	if err := h.db.Where("id = ? AND owner_id = ? AND is_deleted = false", fileID, userID).First(&file).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
+	files := []models.File{file}
+	if err := h.attachChecksums(c.Request.Context(), files); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file checksum"})
+		return
+	}
+
+	h.auditService.LogFileAccess(c, models.AuditActionView, &file, string(access.Via))
+
+	c.Header("ETag", metadataETag(file.UpdatedAt))
+	c.JSON(http.StatusOK, gin.H{
+		"file": NewFileDTO(&files[0]),
+	})
+}
+
+// preloadFileRelations loads the relations a file listing was asked to include
+func preloadFileRelations(shape responseShape) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if shape.include("owner") {
+			db = db.Preload("Owner")
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+		if shape.include("folder") {
+			db = db.Preload("Folder")
+		}
+		return db
+	}
+}
+
+// attachChecksums fills in the SHA-256 of each file's content
+func (h *FileHandler) attachChecksums(ctx context.Context, files []models.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	hashIDs := make([]uuid.UUID, 0, len(files))
+	for _, file := range files {
+		hashIDs = append(hashIDs, file.FileHashID)
+	}
+
+	var fileHashes []models.FileHash
+	if err := h.db.WithContext(ctx).Select("id", "hash").Where("id IN ?", hashIDs).Find(&fileHashes).Error; err != nil {
+		return err
+	}
+
+	checksums := make(map[uuid.UUID]string, len(fileHashes))
+	for _, fileHash := range fileHashes {
+		checksums[fileHash.ID] = fileHash.Hash
+	}
+	for i := range files {
+		files[i].SHA256 = checksums[files[i].FileHashID]
+	}
+
+	return nil
+}
+
+// GetFileChecksum returns the recorded SHA-256 of a file's content
+// GET /api/v1/files/:id/checksum
+func (h *FileHandler) GetFileChecksum(c *gin.Context) {
+	file, fileHash, ok := h.getOwnedFileWithHash(c)
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"file": file,
+		"file_id":     file.ID,
+		"filename":    file.OriginalFilename,
+		"size":        fileHash.Size,
+		"algorithm":   "sha256",
+		"checksum":    fileHash.Hash,
+		"recorded_at": fileHash.CreatedAt,
 	})
 }
 
-// ViewFile serves file content for preview/viewing
-func (h *FileHandler) ViewFile(c *gin.Context) {
-	fmt.Printf("DEBUG ViewFile: Starting ViewFile function\n")
+// VerifyFile re-hashes the stored blob and compares it with the recorded
+// checksum, optionally also against a checksum supplied by the client
+// POST /api/v1/files/:id/verify
+func (h *FileHandler) VerifyFile(c *gin.Context) {
+	file, fileHash, ok := h.getOwnedFileWithHash(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Checksum string `json:"checksum"`
+	}
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
 
+	if respondIfArchived(c, fileHash) {
+		return
+	}
+
+	file.FileHash = fileHash
+	stream, err := h.fileStreamService.Open(file)
+	if err != nil {
+		if errors.Is(err, services.ErrBlobMissing) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File content not found in storage"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file content"})
+		return
+	}
+	content, err := stream.Reader(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file content"})
+		return
+	}
+	actual, err := utils.CalculateReaderHash(content)
+	content.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file content"})
+		return
+	}
+
+	matches := actual == fileHash.Hash
+	response := gin.H{
+		"file_id":     file.ID,
+		"filename":    file.OriginalFilename,
+		"algorithm":   "sha256",
+		"expected":    fileHash.Hash,
+		"actual":      actual,
+		"match":       matches,
+		"verified_at": time.Now(),
+	}
+
+	if req.Checksum != "" {
+		clientMatches := strings.EqualFold(req.Checksum, fileHash.Hash)
+		response["client_checksum"] = req.Checksum
+		response["client_match"] = clientMatches
+		matches = matches && clientMatches
+	}
+
+	if h.auditService != nil {
+		status := models.AuditStatusSuccess
+		if actual != fileHash.Hash {
+			status = models.AuditStatusFailed
+		}
+		if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+			UserID:       file.OwnerID,
+			Action:       models.AuditActionVerify,
+			ResourceType: models.AuditResourceFile,
+			ResourceID:   &file.ID,
+			ResourceName: &file.OriginalFilename,
+			Details: models.AuditLogDetails{
+				"expected":  fileHash.Hash,
+				"actual":    actual,
+				"match":     actual == fileHash.Hash,
+				"timestamp": time.Now().Unix(),
+			},
+			Status: status,
+		}); err != nil {
+			fmt.Printf("Failed to log verify audit: %v\n", err)
+		}
+	}
+
+	response["verified"] = matches
+	c.JSON(http.StatusOK, response)
+}
+
+// getOwnedFileWithHash loads a file owned by the current user together with
+// its content hash, writing an error response if either is missing
+func (h *FileHandler) getOwnedFileWithHash(c *gin.Context) (*models.File, *models.FileHash, bool) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		fmt.Printf("DEBUG ViewFile: User not authenticated - user_id not found in context\n")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+		return nil, nil, false
 	}
 
-	fmt.Printf("DEBUG ViewFile: User ID from context: %v\n", userID)
-
-	fileID := c.Param("id")
-	fmt.Printf("DEBUG ViewFile: File ID from URL: %s\n", fileID)
+	access := h.fileAccess(c, userID.(uuid.UUID), h.accessService.CanEdit)
+	if access == nil {
+		return nil, nil, false
+	}
+	file := access.File
 
-	// Get file with its file hash information
-	var file models.File
 	var fileHash models.FileHash
+	if err := h.db.WithContext(c.Request.Context()).Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file hash"})
+		return nil, nil, false
+	}
+
+	return file, &fileHash, true
+}
 
-	// First try to find as owned file
-	err := h.db.Where("id = ? AND owner_id = ? AND is_deleted = false", fileID, userID).First(&file).Error
+// fileAccess checks the current user's access to the file in the :id route
+// parameter with one of the AccessService checks. It writes the error
+// response and returns nil when the check fails.
+func (h *FileHandler) fileAccess(c *gin.Context, userID uuid.UUID, check func(context.Context, uuid.UUID, uuid.UUID) (*services.FileAccess, error)) *services.FileAccess {
+	fileID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// If not owned, check if it's a shared file
-			fmt.Printf("DEBUG ViewFile: File not owned by user, checking shared files\n")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return nil
+	}
 
-			var fileShare models.FileShare
-			err = h.db.Where("file_id = ? AND shared_with = ? AND is_active = true", fileID, userID).
-				Preload("File").First(&fileShare).Error
+	access, err := check(c.Request.Context(), userID, fileID)
+	if err != nil {
+		respondAccessError(c, err)
+		return nil
+	}
+	return access
+}
 
-			if err != nil {
-				if err == gorm.ErrRecordNotFound {
-					// If not directly shared, check if file is in a shared folder
-					fmt.Printf("DEBUG ViewFile: File not directly shared, checking if file is in a shared folder\n")
-
-					// First get the file to check its folder
-					var tempFile models.File
-					err = h.db.Where("id = ? AND is_deleted = false", fileID).First(&tempFile).Error
-					if err != nil {
-						if err == gorm.ErrRecordNotFound {
-							fmt.Printf("DEBUG ViewFile: File not found at all: %s\n", fileID)
-							c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-							return
-						}
-						fmt.Printf("DEBUG ViewFile: Database error getting file for folder check: %v\n", err)
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
-						return
-					}
-
-					// Check if the file's folder is shared with the user
-					if tempFile.FolderID != nil {
-						var folderShare models.FolderShare
-						err = h.db.Where("folder_id = ? AND shared_with = ?", tempFile.FolderID, userID).First(&folderShare).Error
-						if err != nil {
-							if err == gorm.ErrRecordNotFound {
-								fmt.Printf("DEBUG ViewFile: File's folder not shared with user: folder_id=%v\n", tempFile.FolderID)
-								c.JSON(http.StatusNotFound, gin.H{"error": "File not found or access denied"})
-								return
-							}
-							fmt.Printf("DEBUG ViewFile: Database error checking folder sharing: %v\n", err)
-							c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder access"})
-							return
-						}
-
-						// User has access to the folder, so they can view the file
-						file = tempFile
-						fmt.Printf("DEBUG ViewFile: Found file in shared folder: %s, FolderShare Permission: %s\n", file.ID, folderShare.Permission)
-					} else {
-						fmt.Printf("DEBUG ViewFile: File has no folder and is not directly shared: %s\n", fileID)
-						c.JSON(http.StatusNotFound, gin.H{"error": "File not found or access denied"})
-						return
-					}
-				} else {
-					fmt.Printf("DEBUG ViewFile: Database error getting shared file: %v\n", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
-					return
-				}
-			} else {
-				// Use the shared file
-				file = fileShare.File
-				fmt.Printf("DEBUG ViewFile: Found shared file: %s, Permission: %s\n", file.ID, fileShare.Permission)
-			}
-		} else {
-			fmt.Printf("DEBUG ViewFile: Database error getting owned file: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
-			return
-		}
+// fileRelations are the relations file listings can return with ?include=
+var fileRelations = []string{"owner", "folder"}
+
+// fileSorts are the sorts of file listings and search. "owner" needs users
+// joined on files.owner_id; root files sort first by "folder".
+var fileSorts = sortSpec{
+	fields: map[string]string{
+		"name":      "files.original_filename",
+		"size":      "files.size",
+		"date":      "files.created_at",
+		"modified":  "files.updated_at",
+		"mime":      "files.mime_type",
+		"mime_type": "files.mime_type",
+		"owner":     "users.username",
+		"folder":    "COALESCE((SELECT folders.path FROM folders WHERE folders.id = files.folder_id), '')",
+	},
+	defaultSort:  "name",
+	defaultOrder: "asc",
+	tieBreak:     "files.id",
+}
+
+// publicFileSorts are the sorts of the public file listing, newest first by default
+var publicFileSorts = sortSpec{
+	fields: map[string]string{
+		"name":  "files.original_filename",
+		"size":  "files.size",
+		"date":  "files.created_at",
+		"owner": "users.username",
+	},
+	defaultSort:  "date",
+	defaultOrder: "desc",
+	tieBreak:     "files.id",
+}
+
+// folderSubtreeSQL selects the given folders and every folder below them.
+// UNION rather than UNION ALL stops the walk if a corrupt parent cycle exists.
+const folderSubtreeSQL = `WITH RECURSIVE subtree AS (
+	SELECT id FROM folders WHERE id IN ?
+	UNION
+	SELECT folders.id FROM folders JOIN subtree ON folders.parent_id = subtree.id
+) SELECT id FROM subtree`
+
+// contentMatchSQL selects blobs whose extracted text matches a search query.
+// SQLite has no full-text search here, so the query must appear as written.
+func contentMatchSQL(db *gorm.DB) string {
+	if database.IsSQLite(db) {
+		return `SELECT file_hash_id FROM content_index WHERE status = 'indexed' AND extracted_text LIKE '%' || ? || '%'`
+	}
+	return `SELECT file_hash_id FROM content_index WHERE status = 'indexed' AND to_tsvector('english', COALESCE(extracted_text, '')) @@ plainto_tsquery('english', ?)`
+}
+
+// GetOCRStatus reports whether text has been extracted from a file's content
+// GET /api/v1/files/:id/ocr
+func (h *FileHandler) GetOCRStatus(c *gin.Context) {
+	file, fileHash, ok := h.getOwnedFileWithHash(c)
+	if !ok {
+		return
+	}
+	if respondIfEncrypted(c, file) {
+		return
 	}
 
-	fmt.Printf("DEBUG ViewFile: Found file: %s, FileHashID: %s\n", file.ID, file.FileHashID)
+	entry, err := h.contentIndexService.GetEntry(fileHash.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get OCR status"})
+		return
+	}
 
-	// Get the file hash record to find the storage path
-	fmt.Printf("DEBUG ViewFile: Looking up file hash with ID: %s\n", file.FileHashID)
-	if err := h.db.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
-		fmt.Printf("DEBUG ViewFile: Failed to get file hash: %v\n", err)
-		if err == gorm.ErrRecordNotFound {
-			fmt.Printf("DEBUG ViewFile: File hash record not found for ID: %s\n", file.FileHashID)
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get file storage information",
-			"debug": fmt.Sprintf("FileHashID: %s, Error: %v", file.FileHashID, err),
-		})
+	c.JSON(http.StatusOK, NewContentIndexDTO(file.ID, entry))
+}
+
+// ReindexFile queues a file's content to be extracted again, for example
+// after a failed OCR attempt or once OCR has been configured
+// POST /api/v1/files/:id/ocr
+func (h *FileHandler) ReindexFile(c *gin.Context) {
+	file, fileHash, ok := h.getOwnedFileWithHash(c)
+	if !ok {
+		return
+	}
+	if respondIfEncrypted(c, file) {
 		return
 	}
 
-	fmt.Printf("DEBUG ViewFile: Found file hash: %s, StoragePath: %s\n", fileHash.ID, fileHash.StoragePath)
+	if !h.contentIndexService.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Content indexing is not enabled"})
+		return
+	}
 
-	// First try the new storage path structure (storage/{hash})
-	filePath := filepath.Join(h.cfg.StoragePath, fileHash.StoragePath)
+	entry, err := h.contentIndexService.Requeue(fileHash.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue file for OCR"})
+		return
+	}
 
-	// Debug logging
-	fmt.Printf("DEBUG ViewFile: StoragePath=%s, fileHash.StoragePath=%s, filePath=%s\n",
-		h.cfg.StoragePath, fileHash.StoragePath, filePath)
+	c.JSON(http.StatusAccepted, NewContentIndexDTO(file.ID, entry))
+}
 
-	// Check if file exists at new location
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		fmt.Printf("DEBUG ViewFile: File does not exist at new path: %s\n", filePath)
+// ViewFile serves file content for preview/viewing
+func (h *FileHandler) ViewFile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-		// Try legacy storage pattern (direct UUID filename)
-		legacyFilePath := filepath.Join(h.cfg.StoragePath, file.ID.String())
-		fmt.Printf("DEBUG ViewFile: Trying legacy path: %s\n", legacyFilePath)
+	fileUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
 
-		if _, err := os.Stat(legacyFilePath); os.IsNotExist(err) {
-			fmt.Printf("DEBUG ViewFile: File does not exist at legacy path either: %s\n", legacyFilePath)
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "File not found on disk",
-				"debug": fmt.Sprintf("StoragePath: %s, FileHashPath: %s, FullPath: %s, LegacyPath: %s", h.cfg.StoragePath, fileHash.StoragePath, filePath, legacyFilePath),
-			})
-			return
-		}
+	access, err := h.accessService.CanView(c.Request.Context(), userID.(uuid.UUID), fileUUID)
+	if err != nil {
+		respondAccessError(c, err)
+		return
+	}
+	file := *access.File
 
-		// Use legacy path
-		filePath = legacyFilePath
-		fmt.Printf("DEBUG ViewFile: Using legacy file path: %s\n", filePath)
+	if respondIfQuarantined(c, &file) {
+		return
+	}
+	if respondIfUnscanned(c, h.fileStreamService, &file) {
+		return
+	}
+	if respondIfEncrypted(c, &file) {
+		return
 	}
 
-	// Set appropriate headers for inline viewing
-	c.Header("Content-Type", file.MimeType)
-	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", file.OriginalFilename))
-	c.Header("Cache-Control", "max-age=3600") // Cache for 1 hour
+	// Locate the blob, falling back to the legacy layout and the replica
+	stream, err := h.fileStreamService.Open(&file)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
 
 	// Record download/view statistics
 	var userIDPtr *uuid.UUID
@@ -941,275 +2027,347 @@ func (h *FileHandler) ViewFile(c *gin.Context) {
 		}
 	}
 	h.recordDownload(file.ID, userIDPtr, nil, c)
+	h.auditService.LogFileAccess(c, models.AuditActionView, &file, string(access.Via))
 
-	// Serve the file
-	c.File(filePath)
+	h.fileStreamService.Serve(c, stream, services.StreamInline)
 }
 
 // ViewPublicFile serves public file content for preview/viewing without authentication
 func (h *FileHandler) ViewPublicFile(c *gin.Context) {
-	fileID := c.Param("id")
-
-	// Get public file information
-	var file models.File
-	var fileHash models.FileHash
+	fileUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Public file not found"})
+		return
+	}
 
 	// Check if file exists and is public
-	err := h.db.Where("id = ? AND is_public = true AND is_deleted = false", fileID).First(&file).Error
+	publicFile, err := h.accessService.PublicFile(c.Request.Context(), middleware.TenantID(c), fileUUID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, services.ErrAccessFileNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Public file not found"})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
 		return
 	}
+	file := *publicFile
 
-	// Get the file hash record to find the storage path
-	if err := h.db.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file storage information"})
+	if respondIfQuarantined(c, &file) {
 		return
 	}
-
-	// First try the new storage path structure (storage/{hash})
-	filePath := filepath.Join(h.cfg.StoragePath, fileHash.StoragePath)
-
-	// Check if file exists at new location
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// Try legacy storage pattern (direct UUID filename)
-		legacyFilePath := filepath.Join(h.cfg.StoragePath, file.ID.String())
-		if _, err := os.Stat(legacyFilePath); os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found on disk"})
-			return
-		}
-		filePath = legacyFilePath
+	if respondIfUnscanned(c, h.fileStreamService, &file) {
+		return
 	}
 
-	// Set appropriate headers for inline viewing
-	c.Header("Content-Type", file.MimeType)
-	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", file.OriginalFilename))
-	c.Header("Cache-Control", "max-age=3600") // Cache for 1 hour
+	// Locate the blob, falling back to the legacy layout and the replica
+	stream, err := h.fileStreamService.Open(&file)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
 
 	// Record download/view statistics (no user ID for public access)
 	h.recordDownload(file.ID, nil, nil, c)
+	h.auditService.LogFileAccess(c, models.AuditActionView, &file, "public")
 
-	// Serve the file
-	c.File(filePath)
+	h.fileStreamService.Serve(c, stream, services.StreamInline)
 }
 
 // DownloadFile serves file content for download (attachment)
 func (h *FileHandler) DownloadFile(c *gin.Context) {
-	fmt.Printf("DEBUG DownloadFile: Starting DownloadFile function\n")
-
 	userID, exists := c.Get("user_id")
 	if !exists {
-		fmt.Printf("DEBUG DownloadFile: User not authenticated - user_id not found in context\n")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	fmt.Printf("DEBUG DownloadFile: User ID from context: %v\n", userID)
+	fileUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
 
-	fileID := c.Param("id")
-	fmt.Printf("DEBUG DownloadFile: File ID from URL: %s\n", fileID)
+	access, err := h.accessService.CanDownload(c.Request.Context(), userID.(uuid.UUID), fileUUID)
+	if err != nil {
+		respondAccessError(c, err)
+		return
+	}
+	file := *access.File
 
-	// Get file with its file hash information (reuse ViewFile logic)
-	var file models.File
-	var fileHash models.FileHash
+	if respondIfQuarantined(c, &file) {
+		return
+	}
+	if respondIfUnscanned(c, h.fileStreamService, &file) {
+		return
+	}
 
-	// First try to find as owned file
-	err := h.db.Where("id = ? AND owner_id = ? AND is_deleted = false", fileID, userID).First(&file).Error
+	// Locate the blob, falling back to the legacy layout and the replica
+	stream, err := h.fileStreamService.Open(&file)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// If not owned, check if it's a shared file
-			fmt.Printf("DEBUG DownloadFile: File not owned by user, checking shared files\n")
-
-			var fileShare models.FileShare
-			err = h.db.Where("file_id = ? AND shared_with = ? AND is_active = true", fileID, userID).
-				Preload("File").First(&fileShare).Error
+		respondStreamError(c, err)
+		return
+	}
 
-			if err != nil {
-				if err == gorm.ErrRecordNotFound {
-					// If not directly shared, check if file is in a shared folder
-					fmt.Printf("DEBUG DownloadFile: File not directly shared, checking if file is in a shared folder\n")
-
-					// First get the file to check its folder
-					var tempFile models.File
-					err = h.db.Where("id = ? AND is_deleted = false", fileID).First(&tempFile).Error
-					if err != nil {
-						if err == gorm.ErrRecordNotFound {
-							fmt.Printf("DEBUG DownloadFile: File not found at all: %s\n", fileID)
-							c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-							return
-						}
-						fmt.Printf("DEBUG DownloadFile: Database error getting file for folder check: %v\n", err)
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
-						return
-					}
-
-					// Check if the file's folder is shared with the user
-					if tempFile.FolderID != nil {
-						var folderShare models.FolderShare
-						err = h.db.Where("folder_id = ? AND shared_with = ?", tempFile.FolderID, userID).First(&folderShare).Error
-						if err != nil {
-							if err == gorm.ErrRecordNotFound {
-								fmt.Printf("DEBUG DownloadFile: File's folder not shared with user: folder_id=%v\n", tempFile.FolderID)
-								c.JSON(http.StatusNotFound, gin.H{"error": "File not found or access denied"})
-								return
-							}
-							fmt.Printf("DEBUG DownloadFile: Database error checking folder sharing: %v\n", err)
-							c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder access"})
-							return
-						}
-
-						// User has access to the folder, so they can download the file
-						file = tempFile
-						fmt.Printf("DEBUG DownloadFile: Found file in shared folder: %s, FolderShare Permission: %s\n", file.ID, folderShare.Permission)
-					} else {
-						fmt.Printf("DEBUG DownloadFile: File has no folder and is not directly shared: %s\n", fileID)
-						c.JSON(http.StatusNotFound, gin.H{"error": "File not found or access denied"})
-						return
-					}
-				} else {
-					fmt.Printf("DEBUG DownloadFile: Database error getting shared file: %v\n", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
-					return
-				}
-			} else {
-				// Use the shared file
-				file = fileShare.File
-				fmt.Printf("DEBUG DownloadFile: Found shared file: %s, Permission: %s\n", file.ID, fileShare.Permission)
+	// Record the download statistic together with its audit entry. Segmented
+	// downloads are counted once, on the segment at the start of the file
+	if !isContinuationRange(c) {
+		uid := userID.(uuid.UUID)
+		if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(newDownloadStat(file.ID, &uid, nil, c)).Error; err != nil {
+				return err
 			}
-		} else {
-			fmt.Printf("DEBUG DownloadFile: Database error getting owned file: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
-			return
+			return h.auditService.LogFileDownload(tx, c, uid, file.ID, file.OriginalFilename, file.Size)
+		}); err != nil {
+			fmt.Printf("Failed to record download of %s: %v\n", file.ID, err)
 		}
+		publishDownload(c, &file, nil)
 	}
 
-	fmt.Printf("DEBUG DownloadFile: Found file: %s, FileHashID: %s\n", file.ID, file.FileHashID)
+	h.fileStreamService.Serve(c, stream, services.StreamAttachment)
+}
 
-	// Get the file hash record to find the storage path
-	fmt.Printf("DEBUG DownloadFile: Looking up file hash with ID: %s\n", file.FileHashID)
-	if err := h.db.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
-		fmt.Printf("DEBUG DownloadFile: Failed to get file hash: %v\n", err)
-		if err == gorm.ErrRecordNotFound {
-			fmt.Printf("DEBUG DownloadFile: File hash record not found for ID: %s\n", file.FileHashID)
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get file storage information",
-			"debug": fmt.Sprintf("FileHashID: %s, Error: %v", file.FileHashID, err),
-		})
+// DownloadFilesZip streams several files as one ZIP archive. Every file must
+// be downloadable by the user; quarantined files are rejected
+// POST /api/v1/files/download-zip
+func (h *FileHandler) DownloadFilesZip(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
+	uid := userID.(uuid.UUID)
 
-	fmt.Printf("DEBUG DownloadFile: Found file hash: %s, StoragePath: %s\n", fileHash.ID, fileHash.StoragePath)
+	var req struct {
+		FileIDs []uuid.UUID `json:"file_ids" binding:"required,min=1"`
+		Name    string      `json:"name"` // archive name without .zip, "files" by default
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	if h.zipDownload.tooMany(c, len(req.FileIDs)) {
+		return
+	}
 
-	// First try the new storage path structure (storage/{hash})
-	filePath := filepath.Join(h.cfg.StoragePath, fileHash.StoragePath)
+	seen := make(map[uuid.UUID]bool, len(req.FileIDs))
+	used := make(map[string]bool, len(req.FileIDs))
+	files := make([]zipFile, 0, len(req.FileIDs))
+	for _, fileID := range req.FileIDs {
+		if seen[fileID] {
+			continue
+		}
+		seen[fileID] = true
 
-	// Debug logging
-	fmt.Printf("DEBUG DownloadFile: StoragePath=%s, fileHash.StoragePath=%s, filePath=%s\n",
-		h.cfg.StoragePath, fileHash.StoragePath, filePath)
+		access, err := h.accessService.CanDownload(c.Request.Context(), uid, fileID)
+		if err != nil {
+			respondAccessError(c, err)
+			return
+		}
+		if respondIfQuarantined(c, access.File) {
+			return
+		}
+		files = append(files, zipFile{
+			name: uniqueZipName(used, zipEntryName(access.File.OriginalFilename)),
+			file: access.File,
+		})
+	}
 
-	// Check if file exists at new location
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		fmt.Printf("DEBUG DownloadFile: File does not exist at new path: %s\n", filePath)
+	archiveName := "files"
+	if name := zipEntryName(req.Name); req.Name != "" && name != "_" {
+		archiveName = name
+	}
+	h.zipDownload.serve(c, &uid, archiveName, nil, files)
+}
 
-		// Try legacy storage pattern (direct UUID filename)
-		legacyFilePath := filepath.Join(h.cfg.StoragePath, file.ID.String())
-		fmt.Printf("DEBUG DownloadFile: Trying legacy path: %s\n", legacyFilePath)
+// GetDownloadManifest lists the byte ranges and checksums of a large file so
+// clients can download it in parallel segments, verify each one and
+// reassemble it
+// GET /api/v1/files/:id/download-manifest
+func (h *FileHandler) GetDownloadManifest(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-		if _, err := os.Stat(legacyFilePath); os.IsNotExist(err) {
-			fmt.Printf("DEBUG DownloadFile: File does not exist at legacy path either: %s\n", legacyFilePath)
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "File not found on disk",
-				"debug": fmt.Sprintf("StoragePath: %s, FileHashPath: %s, FullPath: %s, LegacyPath: %s", h.cfg.StoragePath, fileHash.StoragePath, filePath, legacyFilePath),
-			})
-			return
-		}
+	fileUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
 
-		// Use legacy path
-		filePath = legacyFilePath
-		fmt.Printf("DEBUG DownloadFile: Using legacy file path: %s\n", filePath)
+	access, err := h.accessService.CanDownload(c.Request.Context(), userID.(uuid.UUID), fileUUID)
+	if err != nil {
+		respondAccessError(c, err)
+		return
 	}
+	file := access.File
 
-	// Set appropriate headers for download (attachment)
-	c.Header("Content-Type", file.MimeType)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.OriginalFilename))
-	c.Header("Cache-Control", "no-cache")
+	if respondIfQuarantined(c, file) {
+		return
+	}
+	if respondIfUnscanned(c, h.fileStreamService, file) {
+		return
+	}
 
-	// Record download statistics
-	var userIDPtr *uuid.UUID
-	if userID != nil {
-		if uid, ok := userID.(uuid.UUID); ok {
-			userIDPtr = &uid
-		}
+	if !h.manifestService.Applies(file) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "File is too small for a segmented download",
+			"type":      "MANIFEST_NOT_AVAILABLE",
+			"message":   fmt.Sprintf("Download manifests are available for files of at least %d bytes; download this file directly", h.manifestService.Threshold()),
+			"code":      "MANIFEST_NOT_AVAILABLE",
+			"threshold": h.manifestService.Threshold(),
+		})
+		return
 	}
-	h.recordDownload(file.ID, userIDPtr, nil, c)
 
-	// Log audit activity for download
-	if h.auditService != nil && userIDPtr != nil {
-		go func() {
-			if err := h.auditService.LogFileDownload(c, *userIDPtr, file.ID, file.OriginalFilename, file.Size); err != nil {
-				fmt.Printf("Failed to log download audit: %v\n", err)
-			}
-		}()
+	stream, err := h.fileStreamService.Open(file)
+	if err != nil {
+		respondStreamError(c, err)
+		return
 	}
 
-	// Serve the file
-	c.File(filePath)
+	manifest, err := h.manifestService.Build(stream)
+	if err != nil {
+		fmt.Printf("Failed to build download manifest for %s: %v\n", file.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build download manifest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, NewDownloadManifestDTO(manifest, h.linkService))
 }
 
 // DownloadPublicFile serves public file content for download without authentication
 func (h *FileHandler) DownloadPublicFile(c *gin.Context) {
-	fileID := c.Param("id")
-
-	// Get public file information
-	var file models.File
-	var fileHash models.FileHash
+	fileUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Public file not found"})
+		return
+	}
 
 	// Check if file exists and is public
-	err := h.db.Where("id = ? AND is_public = true AND is_deleted = false", fileID).First(&file).Error
+	publicFile, err := h.accessService.PublicFile(c.Request.Context(), middleware.TenantID(c), fileUUID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, services.ErrAccessFileNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Public file not found"})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
 		return
 	}
+	file := *publicFile
 
-	// Get the file hash record to find the storage path
-	if err := h.db.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file storage information"})
+	if respondIfQuarantined(c, &file) {
 		return
 	}
-
-	// First try the new storage path structure (storage/{hash})
-	filePath := filepath.Join(h.cfg.StoragePath, fileHash.StoragePath)
-
-	// Check if file exists at new location
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// Try legacy storage pattern (direct UUID filename)
-		legacyFilePath := filepath.Join(h.cfg.StoragePath, file.ID.String())
-		if _, err := os.Stat(legacyFilePath); os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found on disk"})
-			return
-		}
-		filePath = legacyFilePath
+	if respondIfUnscanned(c, h.fileStreamService, &file) {
+		return
 	}
 
-	// Set appropriate headers for download (attachment)
-	c.Header("Content-Type", file.MimeType)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.OriginalFilename))
-	c.Header("Cache-Control", "no-cache")
+	// Locate the blob, falling back to the legacy layout and the replica
+	stream, err := h.fileStreamService.Open(&file)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
 
 	// Record download statistics (no user ID for public access)
 	h.recordDownload(file.ID, nil, nil, c)
+	h.auditService.LogFileAccess(c, models.AuditActionDownload, &file, "public")
+	publishDownload(c, &file, nil)
+
+	h.fileStreamService.Serve(c, stream, services.StreamAttachment)
+}
+
+// retentionLockedResponse describes a change rejected because of WORM retention
+func retentionLockedResponse(message string, retainUntil *time.Time) gin.H {
+	response := gin.H{
+		"error":   message,
+		"type":    "RETENTION_LOCKED",
+		"message": "This item is in a write-once (WORM) folder and cannot be modified or deleted until its retention period ends",
+		"code":    "RETENTION_LOCKED",
+	}
+	if retainUntil != nil {
+		response["retain_until"] = retainUntil
+	}
+	return response
+}
+
+// folderQuotaExceededResponse builds the error body for a change that would
+// take a folder over its size limit
+func folderQuotaExceededResponse(message string, quotaErr *services.FolderQuotaError) gin.H {
+	available := quotaErr.SizeLimit - quotaErr.TotalSize
+	if available < 0 {
+		available = 0
+	}
+	return gin.H{
+		"error":          message,
+		"type":           "FOLDER_QUOTA_EXCEEDED",
+		"message":        fmt.Sprintf("Folder %q is limited to %.2f MB and has %.2f MB available", quotaErr.FolderName, float64(quotaErr.SizeLimit)/(1024*1024), float64(available)/(1024*1024)),
+		"code":           "FOLDER_QUOTA_EXCEEDED",
+		"folder_id":      quotaErr.FolderID,
+		"folder_name":    quotaErr.FolderName,
+		"size_limit":     quotaErr.SizeLimit,
+		"used_size":      quotaErr.TotalSize,
+		"available_size": available,
+		"attempted_size": quotaErr.Additional,
+	}
+}
+
+// rejectFolderQuota records an upload stopped by a folder size limit and
+// returns the error refusing it. It returns nil for any other error
+func (h *FileHandler) rejectFolderQuota(c *gin.Context, userID uuid.UUID, uploadFiles []FileUploadInfo, err error) error {
+	var quotaErr *services.FolderQuotaError
+	if !errors.As(err, &quotaErr) {
+		return nil
+	}
+	for _, uploadFile := range uploadFiles {
+		h.recordRejection(c, userID, models.UploadRejection{
+			Reason:           models.RejectionQuotaExceeded,
+			Code:             "FOLDER_QUOTA_EXCEEDED",
+			Message:          quotaErr.Error(),
+			Filename:         uploadFile.Header.Filename,
+			DeclaredMimeType: uploadFile.Header.Header.Get("Content-Type"),
+			DetectedMimeType: uploadFile.MimeType,
+			Size:             uploadFile.Size,
+		})
+	}
+	return &uploadError{http.StatusForbidden, folderQuotaExceededResponse("Upload exceeds folder size limit", quotaErr)}
+}
+
+// rejectTenantQuota records an upload stopped by the storage quota of the
+// user's tenant and returns the error refusing it. It returns nil for any
+// other error
+func (h *FileHandler) rejectTenantQuota(c *gin.Context, userID uuid.UUID, uploadFiles []FileUploadInfo, err error) error {
+	var quotaErr *services.TenantQuotaError
+	if !errors.As(err, &quotaErr) {
+		return nil
+	}
+	for _, uploadFile := range uploadFiles {
+		h.recordRejection(c, userID, models.UploadRejection{
+			Reason:           models.RejectionQuotaExceeded,
+			Code:             "TENANT_QUOTA_EXCEEDED",
+			Message:          quotaErr.Error(),
+			Filename:         uploadFile.Header.Filename,
+			DeclaredMimeType: uploadFile.Header.Header.Get("Content-Type"),
+			DetectedMimeType: uploadFile.MimeType,
+			Size:             uploadFile.Size,
+		})
+	}
 
-	// Serve the file
-	c.File(filePath)
+	available := quotaErr.StorageQuota - quotaErr.StorageUsed
+	if available < 0 {
+		available = 0
+	}
+	return &uploadError{http.StatusForbidden, gin.H{
+		"error":          "Upload exceeds organization storage quota",
+		"type":           "TENANT_QUOTA_EXCEEDED",
+		"message":        fmt.Sprintf("Your organization is limited to %.2f MB and has %.2f MB available", float64(quotaErr.StorageQuota)/(1024*1024), float64(available)/(1024*1024)),
+		"code":           "TENANT_QUOTA_EXCEEDED",
+		"storage_quota":  quotaErr.StorageQuota,
+		"storage_used":   quotaErr.StorageUsed,
+		"available_size": available,
+		"attempted_size": quotaErr.Additional,
+	}}
 }
 
 // DeleteFile handles file deletion with deduplication cleanup
@@ -1220,80 +2378,41 @@ func (h *FileHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	fileID := c.Param("id")
+	access := h.fileAccess(c, userID.(uuid.UUID), h.accessService.CanEdit)
+	if access == nil {
+		return
+	}
+	file := *access.File
 
-	var file models.File
-	if err := h.db.Where("id = ? AND owner_id = ? AND is_deleted = false", fileID, userID).First(&file).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file"})
+	if file.RetentionLocked() {
+		h.retentionService.LogBlocked(c, userID.(uuid.UUID), models.AuditActionDelete, models.AuditResourceFile, file.ID, file.OriginalFilename, file.RetainUntil)
+		c.JSON(http.StatusForbidden, retentionLockedResponse("File cannot be deleted", file.RetainUntil))
 		return
 	}
 
 	// Start transaction for consistent deduplication cleanup
-	tx := h.db.Begin()
+	tx := h.db.WithContext(c.Request.Context()).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	// Mark file as deleted
-	if err := tx.Model(&file).Updates(map[string]interface{}{
-		"is_deleted": true,
-		"deleted_at": time.Now(),
-		"updated_at": time.Now(),
-	}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
-		return
-	}
-
-	// Decrease reference count for the file hash
-	var fileHash models.FileHash
-	if err := tx.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find file hash"})
-		return
-	}
-
-	// Decrement reference count
-	newRefCount := fileHash.ReferenceCount - 1
-	if err := tx.Model(&fileHash).Update("reference_count", newRefCount).Error; err != nil {
+	actorID := userID.(uuid.UUID)
+	actualStorageFreed, err := services.TrashFile(tx, &file, &actorID, nil)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reference count"})
-		return
-	}
-
-	// If no more references, delete the hash record
-	actualStorageFreed := int64(0)
-	if newRefCount <= 0 {
-		if err := tx.Delete(&fileHash).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file hash"})
+		if errors.Is(err, services.ErrFileAlreadyTrashed) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 			return
 		}
-		actualStorageFreed = file.Size
-	}
-
-	// Update user storage statistics
-	var user models.User
-	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
 		return
 	}
 
-	updates := map[string]interface{}{
-		"storage_used":         gorm.Expr("storage_used - ?", file.Size),
-		"actual_storage_bytes": gorm.Expr("actual_storage_bytes - ?", actualStorageFreed),
-	}
-
-	if err := tx.Model(&user).Updates(updates).Error; err != nil {
+	if err := h.auditService.LogFileDelete(tx, c, userID.(uuid.UUID), file.ID, file.OriginalFilename); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user storage stats"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record deletion"})
 		return
 	}
 
@@ -1302,15 +2421,6 @@ func (h *FileHandler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	// Log audit activity for file deletion
-	if h.auditService != nil {
-		go func() {
-			if err := h.auditService.LogFileDelete(c, userID.(uuid.UUID), file.ID, file.OriginalFilename); err != nil {
-				fmt.Printf("Failed to log delete audit: %v\n", err)
-			}
-		}()
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":               "File deleted successfully",
 		"actual_storage_freed":  actualStorageFreed,
@@ -1326,37 +2436,35 @@ func (h *FileHandler) MoveFile(c *gin.Context) {
 		return
 	}
 
-	fileID := c.Param("id")
-	fileUUID, err := uuid.Parse(fileID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+	var req struct {
+		FolderID *uuid.UUID `json:"folder_id"`
+	}
+
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	var req struct {
-		FolderID *uuid.UUID `json:"folder_id"`
+	access := h.fileAccess(c, userID.(uuid.UUID), h.accessService.CanEdit)
+	if access == nil {
+		return
 	}
+	file := *access.File
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+	if file.RetentionLocked() {
+		h.retentionService.LogBlocked(c, userID.(uuid.UUID), models.AuditActionMove, models.AuditResourceFile, file.ID, file.OriginalFilename, file.RetainUntil)
+		c.JSON(http.StatusForbidden, retentionLockedResponse("File cannot be moved", file.RetainUntil))
 		return
 	}
 
-	// Get the file
-	var file models.File
-	if err := h.db.Where("id = ? AND owner_id = ? AND is_deleted = false", fileUUID, userID).First(&file).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve file"})
+	if !checkIfMatch(c, h.cfg.RequireIfMatch, file.UpdatedAt) {
 		return
 	}
 
 	// Validate target folder if provided
+	var targetVaultID *uuid.UUID
 	if req.FolderID != nil {
 		var targetFolder models.Folder
-		if err := h.db.Where("id = ? AND owner_id = ?", req.FolderID, userID).First(&targetFolder).Error; err != nil {
+		if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", req.FolderID, userID).First(&targetFolder).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Target folder not found"})
 				return
@@ -1364,20 +2472,113 @@ func (h *FileHandler) MoveFile(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify target folder"})
 			return
 		}
+		targetVaultID = targetFolder.VaultID
 	}
 
-	// Update file folder
-	if err := h.db.Model(&file).Update("folder_id", req.FolderID).Error; err != nil {
+	// Files stay in the vault whose key encrypted them
+	vaultID, err := services.VaultOf(h.db.WithContext(c.Request.Context()), file.FolderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify folder"})
+		return
+	}
+	if !services.SameVault(vaultID, targetVaultID) {
+		c.Error(services.ErrVaultBoundary)
+		return
+	}
+
+	// Update file folder, locking it if the target is a WORM folder
+	fromFolderID := file.FolderID
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&file).Scopes(unchangedSince(c, file.UpdatedAt)).Update("folder_id", req.FolderID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPreconditionFailed
+		}
+		file.FolderID = req.FolderID
+		if err := services.TransferFolderStats(tx, fromFolderID, req.FolderID, 1, file.Size); err != nil {
+			return err
+		}
+		if err := h.retentionService.LockFile(tx, &file); err != nil {
+			return err
+		}
+
+		actorID := userID.(uuid.UUID)
+		return services.RecordFileEvent(tx, services.FileEventParams{
+			FileID:  file.ID,
+			OwnerID: file.OwnerID,
+			ActorID: &actorID,
+			Type:    models.FileEventMoved,
+			Payload: models.FileEventPayload{
+				"from_folder_id": fromFolderID,
+				"to_folder_id":   req.FolderID,
+			},
+		})
+	}); err != nil {
+		if errors.Is(err, errPreconditionFailed) {
+			h.db.WithContext(c.Request.Context()).First(&file, "id = ?", file.ID)
+			respondPreconditionFailed(c, file.UpdatedAt)
+			return
+		}
+		var quotaErr *services.FolderQuotaError
+		if errors.As(err, &quotaErr) {
+			c.JSON(http.StatusForbidden, folderQuotaExceededResponse("Move exceeds folder size limit", quotaErr))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move file"})
 		return
 	}
 
 	// Reload file with folder information
-	h.db.Preload("Folder").First(&file, fileUUID)
+	h.db.WithContext(c.Request.Context()).Preload("Folder").First(&file, "id = ?", file.ID)
 
+	c.Header("ETag", metadataETag(file.UpdatedAt))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "File moved successfully",
-		"file":    file,
+		"file":    NewFileDTO(&file),
+	})
+}
+
+// SetDownloadNotifications turns notifications about downloads of one of
+// the user's files on or off
+// PUT /api/v1/files/:id/download-notifications
+func (h *FileHandler) SetDownloadNotifications(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result := h.db.WithContext(c.Request.Context()).Model(&models.File{}).
+		Where("id = ? AND owner_id = ?", fileID, userID).
+		Update("notify_on_download", *req.Enabled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update download notifications"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Download notifications updated",
+		"file_id":            fileID,
+		"notify_on_download": *req.Enabled,
 	})
 }
 
@@ -1390,7 +2591,7 @@ func (h *FileHandler) GetStorageSavings(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).First(&user, "id = ?", userID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
 		return
 	}
@@ -1431,18 +2632,30 @@ func (h *FileHandler) GetPublicFiles(c *gin.Context) {
 		search = strings.TrimSpace(s)
 	}
 
+	sort, err := publicFileSorts.parse(c.Query("sort_by"), c.Query("sort_order"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": sortErrorMessage(err)})
+		return
+	}
+	shape, message := parseResponseShape(c, FileDTO{}, fileRelations, []string{"owner"})
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
 	offset := (page - 1) * limit
 
-	// Build query for public files
-	query := h.db.Model(&models.File{}).
-		Where("is_public = true AND is_deleted = false").
-		Preload("Owner").
-		Preload("FileHash")
+	// Build query for the public files of the request's tenant
+	query := h.db.WithContext(c.Request.Context()).Model(&models.File{}).
+		Joins("JOIN users ON files.owner_id = users.id").
+		Scopes(models.InTenant(middleware.TenantID(c))).
+		Where("files.is_public = true AND files.is_quarantined = false").
+		Scopes(preloadFileRelations(shape))
 
 	// Add search filter if provided
 	if search != "" {
 		searchPattern := "%" + strings.ToLower(search) + "%"
-		query = query.Where("LOWER(original_filename) LIKE ? OR LOWER(description) LIKE ?", searchPattern, searchPattern)
+		query = query.Where("LOWER(files.original_filename) LIKE ? OR LOWER(files.description) LIKE ?", searchPattern, searchPattern)
 	}
 
 	// Get total count
@@ -1451,7 +2664,7 @@ func (h *FileHandler) GetPublicFiles(c *gin.Context) {
 
 	// Get files with pagination
 	var files []models.File
-	if err := query.Order("created_at DESC").
+	if err := query.Order(sort.clause).
 		Offset(offset).
 		Limit(limit).
 		Find(&files).Error; err != nil {
@@ -1461,9 +2674,12 @@ func (h *FileHandler) GetPublicFiles(c *gin.Context) {
 
 	// Calculate download counts for each file and mark admin files
 	for i := range files {
-		var downloadCount int64
-		h.db.Model(&models.DownloadStat{}).Where("file_id = ?", files[i].ID).Count(&downloadCount)
-		files[i].ShareCount = int(downloadCount) // Using ShareCount field to store download count for public files
+		if shape.wants("share_count") {
+			var downloadCount int64
+			h.db.WithContext(c.Request.Context()).Model(&models.DownloadStat{}).Where("file_id = ?", files[i].ID).Count(&downloadCount)
+			downloadCount += countedFileDownloads(h.db.WithContext(c.Request.Context()), files[i].ID)
+			files[i].ShareCount = int(downloadCount) // Using ShareCount field to store download count for public files
+		}
 
 		// Add admin indicator to the Owner information if it's loaded
 		if files[i].Owner.Role == models.RoleAdmin {
@@ -1471,13 +2687,19 @@ func (h *FileHandler) GetPublicFiles(c *gin.Context) {
 		}
 	}
 
+	items, err := shape.apply(NewFileDTOs(files))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build response"})
+		return
+	}
+
 	// Calculate pagination info
 	totalPages := int((totalCount + int64(limit) - 1) / int64(limit))
 	hasNext := page < totalPages
 	hasPrev := page > 1
 
 	c.JSON(http.StatusOK, gin.H{
-		"files": files,
+		"files": items,
 		"pagination": gin.H{
 			"current_page": page,
 			"total_pages":  totalPages,
@@ -1508,6 +2730,7 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		Tags          []string `json:"tags"`           // Array of tags
 		Uploaders     []string `json:"uploaders"`      // Array of uploader usernames
 		FolderIDs     []string `json:"folder_ids"`     // Array of folder IDs to search in
+		Recursive     bool     `json:"recursive"`      // Also search the subfolders of folder_ids
 		SortBy        string   `json:"sort_by"`        // Sort field
 		SortOrder     string   `json:"sort_order"`     // Sort direction
 		Page          int      `json:"page"`           // Page number
@@ -1547,6 +2770,7 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		if folderIDs := c.Query("folder_ids"); folderIDs != "" {
 			searchReq.FolderIDs = strings.Split(folderIDs, ",")
 		}
+		searchReq.Recursive = c.Query("recursive") == "true"
 		searchReq.SortBy = c.Query("sort_by")
 		searchReq.SortOrder = c.Query("sort_order")
 		if page := c.Query("page"); page != "" {
@@ -1571,21 +2795,26 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 	}
 
 	// Build optimized query with indexes
-	query := h.db.Model(&models.File{}).Where("is_deleted = false")
+	query := h.db.WithContext(c.Request.Context()).Model(&models.File{})
 
 	// User access control
 	if searchReq.IncludeShared {
 		// Include owned files and files shared with user
-		query = query.Where("owner_id = ? OR id IN (SELECT file_id FROM file_shares WHERE shared_with = ?)", userID, userID)
+		query = query.Scopes(services.VisibleFiles(userID.(uuid.UUID)))
 	} else {
 		// Only owned files
-		query = query.Where("owner_id = ?", userID)
+		query = query.Scopes(services.OwnedFiles(userID.(uuid.UUID)))
 	}
 
-	// Text search with full-text search capabilities
+	// End-to-end encrypted files are only searched by the clients holding
+	// their keys
+	query = query.Where("files.is_encrypted = false")
+
+	// Text search with full-text search capabilities, including text
+	// extracted from file content and OCR
 	if searchReq.Query != "" {
 		searchPattern := "%" + strings.ToLower(searchReq.Query) + "%"
-		query = query.Where("(LOWER(original_filename) LIKE ? OR LOWER(description) LIKE ?)", searchPattern, searchPattern)
+		query = query.Where("(LOWER(files.original_filename) LIKE ? OR LOWER(files.description) LIKE ? OR files.file_hash_id IN ("+contentMatchSQL(h.db)+"))", searchPattern, searchPattern, searchReq.Query)
 	}
 
 	// MIME type filter (optimized with IN clause)
@@ -1593,7 +2822,7 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		mimeConditions := make([]string, len(searchReq.MimeTypes))
 		mimeArgs := make([]interface{}, len(searchReq.MimeTypes))
 		for i, mimeType := range searchReq.MimeTypes {
-			mimeConditions[i] = "mime_type LIKE ?"
+			mimeConditions[i] = "files.mime_type LIKE ?"
 			mimeArgs[i] = strings.TrimSpace(mimeType) + "%"
 		}
 		query = query.Where("("+strings.Join(mimeConditions, " OR ")+")", mimeArgs...)
@@ -1601,32 +2830,33 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 
 	// Size range filters (indexed on size column)
 	if searchReq.MinSize != nil {
-		query = query.Where("size >= ?", *searchReq.MinSize)
+		query = query.Where("files.size >= ?", *searchReq.MinSize)
 	}
 	if searchReq.MaxSize != nil {
-		query = query.Where("size <= ?", *searchReq.MaxSize)
+		query = query.Where("files.size <= ?", *searchReq.MaxSize)
 	}
 
 	// Date range filters (indexed on created_at)
 	if searchReq.StartDate != nil {
 		if date, err := time.Parse("2006-01-02", *searchReq.StartDate); err == nil {
-			query = query.Where("created_at >= ?", date)
+			query = query.Where("files.created_at >= ?", date)
 		}
 	}
 	if searchReq.EndDate != nil {
 		if date, err := time.Parse("2006-01-02", *searchReq.EndDate); err == nil {
 			endDateTime := date.Add(24 * time.Hour)
-			query = query.Where("created_at < ?", endDateTime)
+			query = query.Where("files.created_at < ?", endDateTime)
 		}
 	}
 
-	// Tags filter (if using JSON column or comma-separated)
+	// Tags filter: any of the tags, set by the user or the classifier
 	if len(searchReq.Tags) > 0 {
 		tagConditions := make([]string, len(searchReq.Tags))
-		tagArgs := make([]interface{}, len(searchReq.Tags))
+		tagArgs := make([]interface{}, 0, len(searchReq.Tags)*2)
 		for i, tag := range searchReq.Tags {
-			tagConditions[i] = "tags LIKE ?"
-			tagArgs[i] = "%" + strings.TrimSpace(tag) + "%"
+			tag = strings.TrimSpace(tag)
+			tagConditions[i] = services.TagMatchSQL(h.db)
+			tagArgs = append(tagArgs, tag, tag)
 		}
 		query = query.Where("("+strings.Join(tagConditions, " OR ")+")", tagArgs...)
 	}
@@ -1640,11 +2870,17 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 			}
 		}
 		if len(folderUUIDs) > 0 {
-			query = query.Where("folder_id IN ?", folderUUIDs)
+			if searchReq.Recursive {
+				query = query.Where("files.folder_id IN ("+folderSubtreeSQL+")", folderUUIDs)
+			} else {
+				query = query.Where("files.folder_id IN ?", folderUUIDs)
+			}
 		}
 	}
 
-	// Uploader filter (join with users table)
+	// Uploader filter (join with users table). Shared results have other
+	// owners, so the owner sort needs the join too.
+	joinedUsers := false
 	if len(searchReq.Uploaders) > 0 {
 		uploaderConditions := make([]string, 0)
 		uploaderArgs := make([]interface{}, 0)
@@ -1662,28 +2898,23 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		if len(uploaderConditions) > 0 {
 			query = query.Joins("JOIN users ON files.owner_id = users.id").
 				Where("("+strings.Join(uploaderConditions, " OR ")+")", uploaderArgs...)
+			joinedUsers = true
 		}
 	}
 
-	// Sorting with performance optimization
-	orderClause := "files.original_filename ASC"
-	if searchReq.SortBy != "" {
-		validSortFields := map[string]string{
-			"name":     "files.original_filename",
-			"size":     "files.size",
-			"date":     "files.created_at",
-			"modified": "files.updated_at",
-			"mime":     "files.mime_type",
-			"owner":    "users.username",
-		}
-
-		if field, valid := validSortFields[searchReq.SortBy]; valid {
-			direction := "ASC"
-			if strings.ToLower(searchReq.SortOrder) == "desc" {
-				direction = "DESC"
-			}
-			orderClause = field + " " + direction
-		}
+	// Sorting
+	sort, err := fileSorts.parse(searchReq.SortBy, searchReq.SortOrder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": sortErrorMessage(err)})
+		return
+	}
+	shape, message := parseResponseShape(c, SearchResultDTO{}, fileRelations, fileRelations)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+	if sort.has("owner") && !joinedUsers {
+		query = query.Joins("JOIN users ON files.owner_id = users.id")
 	}
 
 	// Get total count for pagination (optimized count query)
@@ -1698,9 +2929,8 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 	offset := (searchReq.Page - 1) * searchReq.Limit
 	var files []models.File
 
-	finalQuery := query.Preload("Folder").
-		Preload("Owner").
-		Order(orderClause).
+	finalQuery := query.Scopes(preloadFileRelations(shape)).
+		Order(sort.clause).
 		Offset(offset).
 		Limit(searchReq.Limit)
 
@@ -1709,6 +2939,34 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		return
 	}
 
+	// Explain why each file matched, with content snippets for this page only
+	var contentSnippets map[uuid.UUID]string
+	if searchReq.Query != "" && len(files) > 0 && shape.wants("matches") {
+		hashIDs := make([]uuid.UUID, len(files))
+		for i := range files {
+			hashIDs[i] = files[i].FileHashID
+		}
+		snippets, err := h.contentIndexService.MatchSnippets(hashIDs, searchReq.Query)
+		if err != nil {
+			// Results are still returned, just without content snippets
+			fmt.Printf("Failed to build content snippets: %v\n", err)
+		}
+		contentSnippets = snippets
+	}
+	results := make([]SearchResultDTO, len(files))
+	for i := range files {
+		results[i] = SearchResultDTO{
+			FileDTO: NewFileDTO(&files[i]),
+			Matches: searchMatches(&files[i], searchReq.Query, searchReq.Tags, contentSnippets[files[i].FileHashID]),
+		}
+	}
+
+	items, err := shape.apply(results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build response"})
+		return
+	}
+
 	// Calculate pagination metadata
 	totalPages := int((totalCount + int64(searchReq.Limit) - 1) / int64(searchReq.Limit))
 	hasNext := searchReq.Page < totalPages
@@ -1716,7 +2974,7 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 
 	// Prepare response with search metadata
 	response := gin.H{
-		"files":       files,
+		"files":       items,
 		"count":       len(files),
 		"total_count": totalCount,
 		"pagination": gin.H{
@@ -1736,6 +2994,7 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 				"tags":           searchReq.Tags,
 				"uploaders":      searchReq.Uploaders,
 				"folders":        searchReq.FolderIDs,
+				"recursive":      searchReq.Recursive,
 				"include_shared": searchReq.IncludeShared,
 			},
 			"sort": map[string]string{
@@ -1745,6 +3004,11 @@ func (h *FileHandler) SearchFiles(c *gin.Context) {
 		},
 	}
 
+	h.auditService.LogListAccess(c, models.AuditResourceFile, nil, len(files), models.AuditLogDetails{
+		"search": searchReq.Query,
+		"tags":   searchReq.Tags,
+	})
+
 	c.JSON(http.StatusOK, response)
 }
 
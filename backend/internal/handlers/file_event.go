@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type FileEventHandler struct {
+	db               *gorm.DB
+	fileEventService *services.FileEventService
+}
+
+func NewFileEventHandler(db *gorm.DB, fileEventService *services.FileEventService) *FileEventHandler {
+	return &FileEventHandler{
+		db:               db,
+		fileEventService: fileEventService,
+	}
+}
+
+// GetFileHistory returns what happened to one of the user's files, newest
+// first. History stays available after the file is deleted. Page back with
+// ?before=<sequence of the oldest event received>.
+// GET /api/v1/files/:id/history
+func (h *FileEventHandler) GetFileHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	var file models.File
+	if err := h.db.WithContext(c.Request.Context()).Unscoped().Select("id").Where("id = ? AND owner_id = ?", fileUUID, userID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve file"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	before, err := strconv.ParseInt(c.DefaultQuery("before", "0"), 10, 64)
+	if err != nil || before < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before sequence"})
+		return
+	}
+
+	events, err := h.fileEventService.History(c.Request.Context(), file.ID, before, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file history"})
+		return
+	}
+
+	response := gin.H{
+		"file_id":  file.ID,
+		"events":   NewFileEventDTOs(events),
+		"has_more": len(events) == limit,
+	}
+	if len(events) == limit {
+		response["next_before"] = events[len(events)-1].Sequence
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetFileChanges returns the change feed of the user's files: every event
+// after ?cursor=, oldest first. Clients store the returned cursor and pass it
+// back to receive only newer changes; omit it to start from the beginning.
+// GET /api/v1/files/changes
+func (h *FileEventHandler) GetFileChanges(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	changes, err := h.fileEventService.Changes(c.Request.Context(), userID.(uuid.UUID), c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidChangeCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":   NewFileEventDTOs(changes.Events),
+		"cursor":   changes.Cursor,
+		"has_more": changes.HasMore,
+	})
+}
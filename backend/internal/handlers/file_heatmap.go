@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// maxHeatmapDays bounds how far back an access heatmap reaches
+const maxHeatmapDays = 365
+
+// heatmapPeak is the busiest hour of an access heatmap
+type heatmapPeak struct {
+	DayOfWeek int   `json:"day_of_week"`
+	Hour      int   `json:"hour"`
+	Count     int64 `json:"count"`
+}
+
+// GetAccessHeatmap shows the owner when a file is consumed: downloads and
+// share link views bucketed by day of week (0 is Sunday) and hour of day, in
+// the time zone given by tz. Anonymous visits that were only counted per day
+// are reported as untimed
+// GET /api/v1/files/:id/access-heatmap?days=90&tz=Europe/Berlin
+func (h *FileHandler) GetAccessHeatmap(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 1 || days > maxHeatmapDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+		return
+	}
+
+	var file models.File
+	if err := h.db.WithContext(c.Request.Context()).Select("id").Where("id = ? AND owner_id = ?", fileID, userID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve file"})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	heatmap, err := h.heatmapService.Build(c.Request.Context(), file.ID, since, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build access heatmap"})
+		return
+	}
+
+	var peak *heatmapPeak
+	for dow := 0; dow < 7; dow++ {
+		for hour := 0; hour < 24; hour++ {
+			count := heatmap.Downloads[dow][hour] + heatmap.Views[dow][hour]
+			if count > 0 && (peak == nil || count > peak.Count) {
+				peak = &heatmapPeak{DayOfWeek: dow, Hour: hour, Count: count}
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":           file.ID,
+		"timezone":          loc.String(),
+		"since":             since,
+		"downloads":         heatmap.Downloads,
+		"views":             heatmap.Views,
+		"untimed_downloads": heatmap.UntimedDownloads,
+		"untimed_views":     heatmap.UntimedViews,
+		"total_downloads":   heatmap.TotalDownloads,
+		"total_views":       heatmap.TotalViews,
+		"peak":              peak,
+	})
+}
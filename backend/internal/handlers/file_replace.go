@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// loadReplaceTarget reads the replace_file_id and base_revision form fields
+// a sync client sends to upload over one of its files. It returns nil when
+// the upload does not replace a file, and reports false when a response was
+// written.
+func (h *FileHandler) loadReplaceTarget(c *gin.Context, userID uuid.UUID) (*models.File, bool) {
+	replaceID := c.PostForm("replace_file_id")
+	if replaceID == "" {
+		return nil, true
+	}
+
+	// Replacing files is the sync client's job and needs its registered device
+	if c.GetString("device_client") != string(models.DeviceClientSync) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Replacing files requires a registered sync device",
+			"code":  "SYNC_DEVICE_REQUIRED",
+		})
+		return nil, false
+	}
+
+	fileID, err := uuid.Parse(replaceID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid replace_file_id"})
+		return nil, false
+	}
+	if strings.TrimSpace(c.PostForm("base_revision")) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base_revision is required when replacing a file"})
+		return nil, false
+	}
+
+	var file models.File
+	if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", fileID, userID).First(&file).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File to replace not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve file"})
+		return nil, false
+	}
+
+	if file.RetentionLocked() {
+		h.retentionService.LogBlocked(c, userID, models.AuditActionUpdate, models.AuditResourceFile, file.ID, file.OriginalFilename, file.RetainUntil)
+		c.JSON(http.StatusForbidden, retentionLockedResponse("File cannot be replaced", file.RetainUntil))
+		return nil, false
+	}
+	if respondIfQuarantined(c, &file) {
+		return nil, false
+	}
+	return &file, true
+}
+
+// processSyncUpload replaces the content of target with an upload made from
+// baseRevision, the file's ETag when the client last synced it. The target is
+// locked until the transaction ends. When it changed or was deleted after
+// baseRevision, the upload is kept as a conflicted copy beside it instead, so
+// neither change is lost.
+func (h *FileHandler) processSyncUpload(tx *gorm.DB, uploadFile FileUploadInfo, target *models.File, baseRevision string, userID uuid.UUID) (*UploadResultDTO, error) {
+	var current models.File
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", target.ID).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to lock file: %v", err)
+	}
+	found := err == nil
+	if found && sameRevision(baseRevision, current.UpdatedAt) && !current.RetentionLocked() && !current.IsQuarantined {
+		return h.replaceFileContent(tx, uploadFile, &current, userID)
+	}
+
+	header := *uploadFile.Header
+	header.Filename = conflictedCopyName(target.OriginalFilename, time.Now())
+	uploadFile.Header = &header
+	result, err := h.processFileUpload(tx, uploadFile, userID, target.FolderID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := models.FileEventPayload{
+		"conflict_of":   target.ID,
+		"filename":      target.OriginalFilename,
+		"base_revision": baseRevision,
+	}
+	if found {
+		payload["server_revision"] = metadataETag(current.UpdatedAt)
+	} else {
+		payload["original_deleted"] = true
+	}
+	if err := services.RecordFileEvent(tx, services.FileEventParams{
+		FileID:  result.ID,
+		OwnerID: userID,
+		ActorID: &userID,
+		Type:    models.FileEventConflicted,
+		Payload: payload,
+	}); err != nil {
+		return nil, err
+	}
+
+	result.Conflict = true
+	result.ConflictOf = &target.ID
+	return result, nil
+}
+
+// replaceFileContent points a file at an upload's content in place and
+// releases its previous content the way deleting the file would
+func (h *FileHandler) replaceFileContent(tx *gorm.DB, uploadFile FileUploadInfo, file *models.File, userID uuid.UUID) (*UploadResultDTO, error) {
+	fileHash, isNewContent, err := h.storeUploadContent(tx, uploadFile)
+	if err != nil {
+		return nil, err
+	}
+
+	previousHashID := file.FileHashID
+	previousSize := file.Size
+	previousRevision := metadataETag(file.UpdatedAt)
+
+	// Tags the classifier derived from the old content no longer apply, and
+	// encrypted content comes with its own encryption header
+	updates := map[string]interface{}{
+		"file_hash_id":      fileHash.ID,
+		"size":              uploadFile.Size,
+		"mime_type":         uploadFile.MimeType,
+		"storage_tier":      models.StorageTierHot,
+		"processing_status": h.uploadProcessingStatus(uploadFile, &fileHash),
+		"auto_tags":         nil,
+		"classified_at":     nil,
+	}
+	if file.IsEncrypted {
+		updates["encryption_header"] = uploadFile.EncryptionHeader
+	}
+	if err := tx.Model(file).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update file record: %v", err)
+	}
+	if err := services.AdjustFolderStats(tx, file.FolderID, 0, uploadFile.Size-previousSize); err != nil {
+		return nil, err
+	}
+
+	var previousHash models.FileHash
+	if err := tx.Where("id = ?", previousHashID).First(&previousHash).Error; err != nil {
+		return nil, fmt.Errorf("failed to find file hash: %v", err)
+	}
+	newRefCount := previousHash.ReferenceCount - 1
+	if err := tx.Model(&previousHash).Update("reference_count", newRefCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to update reference count: %v", err)
+	}
+	storageFreed := int64(0)
+	if newRefCount <= 0 {
+		storageFreed = previousSize
+	}
+
+	// The user pays for the new content unless another of their files has
+	// it, and stops paying for the previous content unless one still does
+	charged, err := services.ContentCharge(tx, userID, fileHash.ID, file.ID, uploadFile.Size)
+	if err != nil {
+		return nil, err
+	}
+	released, err := services.ContentCharge(tx, userID, previousHashID, file.ID, previousSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"storage_used":         gorm.Expr("storage_used - ?", released),
+		"actual_storage_bytes": gorm.Expr("actual_storage_bytes - ?", storageFreed),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update user storage stats: %v", err)
+	}
+
+	if err := services.RecordFileEvent(tx, services.FileEventParams{
+		FileID:  file.ID,
+		OwnerID: file.OwnerID,
+		ActorID: &userID,
+		Type:    models.FileEventModified,
+		Payload: models.FileEventPayload{
+			"filename":          file.OriginalFilename,
+			"size":              uploadFile.Size,
+			"previous_size":     previousSize,
+			"mime_type":         uploadFile.MimeType,
+			"previous_revision": previousRevision,
+			"is_duplicate":      !isNewContent,
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	result := newUploadResultDTO(file, uploadFile, isNewContent, charged)
+	result.Replaced = true
+	return result, nil
+}
+
+// sameRevision reports whether a client's base revision is the file's
+// current ETag, which clients may send with or without its quotes
+func sameRevision(baseRevision string, updatedAt time.Time) bool {
+	return strings.Trim(strings.TrimSpace(baseRevision), `"`) == strings.Trim(metadataETag(updatedAt), `"`)
+}
+
+// conflictedCopyName names the copy kept when an upload conflicts with
+// another change, such as "report (conflicted copy 2026-10-15 142501).docx"
+func conflictedCopyName(filename string, at time.Time) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s (conflicted copy %s)%s", strings.TrimSuffix(filename, ext), at.Format("2006-01-02 150405"), ext)
+}
@@ -1,30 +1,47 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"path"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"file-vault-system/backend/internal/apperrors"
 	"file-vault-system/backend/internal/config"
 	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
 )
 
 type FolderHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db               *gorm.DB
+	cfg              *config.Config
+	auditService     *services.AuditService
+	retentionService *services.RetentionService
+	accessService    *services.AccessService
+	zipDownload      *zipDownload
+	vaultService     *services.VaultService
 }
 
-func NewFolderHandler(db *gorm.DB, cfg *config.Config) *FolderHandler {
+func NewFolderHandler(db *gorm.DB, cfg *config.Config, auditService *services.AuditService) *FolderHandler {
 	return &FolderHandler{
-		db:  db,
-		cfg: cfg,
+		db:               db,
+		cfg:              cfg,
+		auditService:     auditService,
+		retentionService: services.NewRetentionService(db, auditService),
+		accessService:    services.NewAccessService(db),
+		zipDownload:      newZipDownload(db, cfg, auditService),
+		vaultService:     services.NewVaultService(db, cfg),
 	}
 }
 
-// CreateFolder creates a new folder
+// CreateFolder creates a new folder. An encrypted folder starts a vault whose
+// files the client encrypts; it is created with the owner's wrapped key of
+// the vault, and the escrow copy when the server keeps one. Folders created
+// inside a vault belong to it.
 func (h *FolderHandler) CreateFolder(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -33,12 +50,14 @@ func (h *FolderHandler) CreateFolder(c *gin.Context) {
 	}
 
 	var req struct {
-		Name     string     `json:"name" binding:"required"`
-		ParentID *uuid.UUID `json:"parent_id,omitempty"`
+		Name             string     `json:"name" binding:"required"`
+		ParentID         *uuid.UUID `json:"parent_id,omitempty"`
+		Encrypted        bool       `json:"encrypted"`
+		WrappedKey       string     `json:"wrapped_key"`
+		EscrowWrappedKey string     `json:"escrow_wrapped_key"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -60,7 +79,7 @@ func (h *FolderHandler) CreateFolder(c *gin.Context) {
 
 	// If parent ID is provided, validate it exists and user owns it
 	if req.ParentID != nil {
-		if err := h.db.Where("id = ? AND owner_id = ?", req.ParentID, userID).First(&parentFolder).Error; err != nil {
+		if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", req.ParentID, userID).First(&parentFolder).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Parent folder not found"})
 				return
@@ -73,6 +92,20 @@ func (h *FolderHandler) CreateFolder(c *gin.Context) {
 		parentPath = "/"
 	}
 
+	// A folder inside a vault belongs to it; otherwise an encrypted folder
+	// starts a new vault
+	var vaultID *uuid.UUID
+	newVault := false
+	if parentFolder != nil && parentFolder.IsEncrypted() {
+		vaultID = parentFolder.VaultID
+	} else if req.Encrypted {
+		if err := h.vaultService.CheckNewVault(req.WrappedKey, req.EscrowWrappedKey); err != nil {
+			c.Error(err)
+			return
+		}
+		newVault = true
+	}
+
 	// Build the full path
 	var fullPath string
 	if parentPath == "/" {
@@ -83,9 +116,9 @@ func (h *FolderHandler) CreateFolder(c *gin.Context) {
 
 	// Check if folder with same name already exists in the same parent
 	var existingFolder models.Folder
-	err := h.db.Where("name = ? AND parent_id = ? AND owner_id = ?", sanitizedName, req.ParentID, userID).First(&existingFolder).Error
+	err := h.db.WithContext(c.Request.Context()).Where("name = ? AND parent_id = ? AND owner_id = ?", sanitizedName, req.ParentID, userID).First(&existingFolder).Error
 	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Folder with this name already exists in the same location"})
+		c.Error(apperrors.ErrDuplicateName.With("Folder with this name already exists in the same location"))
 		return
 	} else if err != gorm.ErrRecordNotFound {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing folders"})
@@ -101,19 +134,33 @@ func (h *FolderHandler) CreateFolder(c *gin.Context) {
 		ParentID: req.ParentID,
 		OwnerID:  userID.(uuid.UUID),
 		Path:     fullPath,
+		VaultID:  vaultID,
+	}
+	if newVault {
+		folder.VaultID = &folder.ID
 	}
 
-	if err := h.db.Create(&folder).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&folder).Error; err != nil {
+			return err
+		}
+		if newVault {
+			if err := h.vaultService.CreateVaultKeys(tx, &folder, req.WrappedKey, req.EscrowWrappedKey); err != nil {
+				return err
+			}
+		}
+		return services.CreateFolderStats(tx, &folder)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
 		return
 	}
 
 	// Load the created folder with relationships
-	h.db.Preload("Parent").Preload("Owner").First(&folder, folder.ID)
+	h.db.WithContext(c.Request.Context()).Preload("Parent").Preload("Owner").Preload("Stats").First(&folder, folder.ID)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Folder created successfully",
-		"folder":  folder,
+		"folder":  NewFolderDTO(&folder),
 	})
 }
 
@@ -138,38 +185,23 @@ func (h *FolderHandler) ListFolders(c *gin.Context) {
 			return
 		}
 
-		// First check if user owns the parent folder
-		var parentFolder models.Folder
-		err = h.db.Where("id = ? AND owner_id = ?", parentUUID, userID).First(&parentFolder).Error
-
-		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// User doesn't own the parent folder, check if it's shared with them
-				var folderShare models.FolderShare
-				err = h.db.Where("folder_id = ? AND shared_with = ?", parentUUID, userID).First(&folderShare).Error
-
-				if err != nil {
-					if err == gorm.ErrRecordNotFound {
-						c.JSON(http.StatusNotFound, gin.H{"error": "Parent folder not found or access denied"})
-						return
-					}
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder access"})
-					return
-				}
-				// User has shared access to the parent folder
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check parent folder ownership"})
+		// The parent must be owned by or shared with the user
+		if _, err := h.accessService.CanViewFolder(c.Request.Context(), userID.(uuid.UUID), parentUUID); err != nil {
+			if errors.Is(err, services.ErrAccessFolderNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Parent folder not found or access denied"})
 				return
 			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder access"})
+			return
 		}
 
 		// Get subfolders of the specific parent - include all subfolders regardless of ownership
-		query := h.db.Where("parent_id = ?", parentUUID)
+		query := h.db.WithContext(c.Request.Context()).Where("parent_id = ?", parentUUID)
 
 		// Load relationships
-		query = query.Preload("Parent").Preload("Owner")
+		query = query.Preload("Parent").Preload("Owner").Preload("Stats")
 		if includeFiles {
-			query = query.Preload("Files", "is_deleted = false")
+			query = query.Preload("Files")
 		}
 
 		if err := query.Order("name ASC").Find(&folders).Error; err != nil {
@@ -178,16 +210,16 @@ func (h *FolderHandler) ListFolders(c *gin.Context) {
 		}
 	} else {
 		// Show root level folders or all folders for the user
-		query := h.db.Where("owner_id = ?", userID)
+		query := h.db.WithContext(c.Request.Context()).Where("owner_id = ?", userID)
 
 		if parentID == "root" || parentID == "null" {
 			query = query.Where("parent_id IS NULL")
 		}
 
 		// Load relationships
-		query = query.Preload("Parent").Preload("Owner")
+		query = query.Preload("Parent").Preload("Owner").Preload("Stats")
 		if includeFiles {
-			query = query.Preload("Files", "is_deleted = false")
+			query = query.Preload("Files")
 		}
 
 		if err := query.Order("name ASC").Find(&folders).Error; err != nil {
@@ -196,8 +228,10 @@ func (h *FolderHandler) ListFolders(c *gin.Context) {
 		}
 	}
 
+	h.auditService.LogListAccess(c, models.AuditResourceFolder, nil, len(folders), nil)
+
 	c.JSON(http.StatusOK, gin.H{
-		"folders": folders,
+		"folders": NewFolderDTOs(folders),
 		"count":   len(folders),
 	})
 }
@@ -221,12 +255,12 @@ func (h *FolderHandler) GetFolder(c *gin.Context) {
 	includeChildren := c.Query("include_children") == "true"
 
 	var folder models.Folder
-	query := h.db.Where("id = ? AND owner_id = ?", folderUUID, userID)
+	query := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", folderUUID, userID)
 
 	// Load relationships
-	query = query.Preload("Parent").Preload("Owner")
+	query = query.Preload("Parent").Preload("Owner").Preload("Stats")
 	if includeFiles {
-		query = query.Preload("Files", "is_deleted = false")
+		query = query.Preload("Files")
 	}
 	if includeChildren {
 		query = query.Preload("Children")
@@ -241,7 +275,10 @@ func (h *FolderHandler) GetFolder(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"folder": folder})
+	h.auditService.LogFolderAccess(c, models.AuditActionView, &folder, "owner")
+
+	c.Header("ETag", metadataETag(folder.UpdatedAt))
+	c.JSON(http.StatusOK, gin.H{"folder": NewFolderDTO(&folder)})
 }
 
 // UpdateFolder updates a folder's name
@@ -263,8 +300,7 @@ func (h *FolderHandler) UpdateFolder(c *gin.Context) {
 		Name string `json:"name" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -277,7 +313,7 @@ func (h *FolderHandler) UpdateFolder(c *gin.Context) {
 
 	// Get the folder
 	var folder models.Folder
-	if err := h.db.Where("id = ? AND owner_id = ?", folderUUID, userID).First(&folder).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", folderUUID, userID).First(&folder).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 			return
@@ -286,11 +322,19 @@ func (h *FolderHandler) UpdateFolder(c *gin.Context) {
 		return
 	}
 
+	if !h.checkFolderMutable(c, userID.(uuid.UUID), &folder, models.AuditActionRename, "Folder cannot be renamed") {
+		return
+	}
+
+	if !checkIfMatch(c, h.cfg.RequireIfMatch, folder.UpdatedAt) {
+		return
+	}
+
 	// Check if folder with same name already exists in the same parent
 	var existingFolder models.Folder
-	err = h.db.Where("name = ? AND parent_id = ? AND owner_id = ? AND id != ?", sanitizedName, folder.ParentID, userID, folderUUID).First(&existingFolder).Error
+	err = h.db.WithContext(c.Request.Context()).Where("name = ? AND parent_id = ? AND owner_id = ? AND id != ?", sanitizedName, folder.ParentID, userID, folderUUID).First(&existingFolder).Error
 	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Folder with this name already exists in the same location"})
+		c.Error(apperrors.ErrDuplicateName.With("Folder with this name already exists in the same location"))
 		return
 	} else if err != gorm.ErrRecordNotFound {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing folders"})
@@ -298,7 +342,7 @@ func (h *FolderHandler) UpdateFolder(c *gin.Context) {
 	}
 
 	// Start transaction to update folder and all children paths
-	tx := h.db.Begin()
+	tx := h.db.WithContext(c.Request.Context()).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -316,14 +360,21 @@ func (h *FolderHandler) UpdateFolder(c *gin.Context) {
 	}
 
 	// Update the folder
-	if err := tx.Model(&folder).Updates(map[string]interface{}{
+	result := tx.Model(&folder).Scopes(unchangedSince(c, folder.UpdatedAt)).Updates(map[string]interface{}{
 		"name": sanitizedName,
 		"path": newPath,
-	}).Error; err != nil {
+	})
+	if result.Error != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
 		return
 	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		h.db.WithContext(c.Request.Context()).First(&folder, folderUUID)
+		respondPreconditionFailed(c, folder.UpdatedAt)
+		return
+	}
 
 	// Update all children paths recursively
 	if err := h.updateChildrenPaths(tx, folderUUID, oldPath, newPath); err != nil {
@@ -339,11 +390,12 @@ func (h *FolderHandler) UpdateFolder(c *gin.Context) {
 	}
 
 	// Reload the updated folder
-	h.db.Preload("Parent").Preload("Owner").First(&folder, folderUUID)
+	h.db.WithContext(c.Request.Context()).Preload("Parent").Preload("Owner").First(&folder, folderUUID)
 
+	c.Header("ETag", metadataETag(folder.UpdatedAt))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Folder updated successfully",
-		"folder":  folder,
+		"folder":  NewFolderDTO(&folder),
 	})
 }
 
@@ -366,14 +418,13 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 		ParentID *uuid.UUID `json:"parent_id"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Get the folder to move
 	var folder models.Folder
-	if err := h.db.Where("id = ? AND owner_id = ?", folderUUID, userID).First(&folder).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", folderUUID, userID).First(&folder).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 			return
@@ -382,8 +433,17 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 		return
 	}
 
+	if !h.checkFolderMutable(c, userID.(uuid.UUID), &folder, models.AuditActionMove, "Folder cannot be moved") {
+		return
+	}
+
+	if !checkIfMatch(c, h.cfg.RequireIfMatch, folder.UpdatedAt) {
+		return
+	}
+
 	// Validate new parent if provided
 	var newParentPath string
+	var parentVaultID *uuid.UUID
 	if req.ParentID != nil {
 		// Check if trying to move to itself or its own child (circular reference)
 		if *req.ParentID == folderUUID {
@@ -393,7 +453,7 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 
 		// Check if new parent exists and is owned by user
 		var parentFolder models.Folder
-		if err := h.db.Where("id = ? AND owner_id = ?", req.ParentID, userID).First(&parentFolder).Error; err != nil {
+		if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", req.ParentID, userID).First(&parentFolder).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Target parent folder not found"})
 				return
@@ -409,15 +469,23 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 		}
 
 		newParentPath = parentFolder.Path
+		parentVaultID = parentFolder.VaultID
 	} else {
 		newParentPath = "/"
 	}
 
+	// Folders stay in their vault, and a vault may only move outside vaults
+	isVaultRoot := folder.VaultID != nil && *folder.VaultID == folder.ID
+	if isVaultRoot && parentVaultID != nil || !isVaultRoot && !services.SameVault(folder.VaultID, parentVaultID) {
+		c.Error(services.ErrVaultBoundary)
+		return
+	}
+
 	// Check if folder with same name already exists in target location
 	var existingFolder models.Folder
-	err = h.db.Where("name = ? AND parent_id = ? AND owner_id = ? AND id != ?", folder.Name, req.ParentID, userID, folderUUID).First(&existingFolder).Error
+	err = h.db.WithContext(c.Request.Context()).Where("name = ? AND parent_id = ? AND owner_id = ? AND id != ?", folder.Name, req.ParentID, userID, folderUUID).First(&existingFolder).Error
 	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Folder with this name already exists in the target location"})
+		c.Error(apperrors.ErrDuplicateName.With("Folder with this name already exists in the target location"))
 		return
 	} else if err != gorm.ErrRecordNotFound {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing folders"})
@@ -425,7 +493,7 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := h.db.WithContext(c.Request.Context()).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -434,6 +502,7 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 
 	// Calculate new path
 	oldPath := folder.Path
+	oldParentID := folder.ParentID
 	var newPath string
 	if newParentPath == "/" {
 		newPath = "/" + folder.Name
@@ -442,14 +511,21 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 	}
 
 	// Update the folder
-	if err := tx.Model(&folder).Updates(map[string]interface{}{
+	result := tx.Model(&folder).Scopes(unchangedSince(c, folder.UpdatedAt)).Updates(map[string]interface{}{
 		"parent_id": req.ParentID,
 		"path":      newPath,
-	}).Error; err != nil {
+	})
+	if result.Error != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move folder"})
 		return
 	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		h.db.WithContext(c.Request.Context()).First(&folder, folderUUID)
+		respondPreconditionFailed(c, folder.UpdatedAt)
+		return
+	}
 
 	// Update all children paths recursively
 	if err := h.updateChildrenPaths(tx, folderUUID, oldPath, newPath); err != nil {
@@ -458,6 +534,18 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 		return
 	}
 
+	// Carry the folder's contents over to its new ancestors' size limits
+	if err := services.MoveFolderStats(tx, folderUUID, oldParentID, req.ParentID); err != nil {
+		tx.Rollback()
+		var quotaErr *services.FolderQuotaError
+		if errors.As(err, &quotaErr) {
+			c.JSON(http.StatusForbidden, folderQuotaExceededResponse("Move exceeds folder size limit", quotaErr))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder stats"})
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
@@ -465,11 +553,12 @@ func (h *FolderHandler) MoveFolder(c *gin.Context) {
 	}
 
 	// Reload the moved folder
-	h.db.Preload("Parent").Preload("Owner").First(&folder, folderUUID)
+	h.db.WithContext(c.Request.Context()).Preload("Parent").Preload("Owner").Preload("Stats").First(&folder, folderUUID)
 
+	c.Header("ETag", metadataETag(folder.UpdatedAt))
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Folder moved successfully",
-		"folder":  folder,
+		"folder":  NewFolderDTO(&folder),
 	})
 }
 
@@ -493,7 +582,7 @@ func (h *FolderHandler) DeleteFolder(c *gin.Context) {
 
 	// Get the folder
 	var folder models.Folder
-	if err := h.db.Where("id = ? AND owner_id = ?", folderUUID, userID).First(&folder).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", folderUUID, userID).First(&folder).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 			return
@@ -502,33 +591,45 @@ func (h *FolderHandler) DeleteFolder(c *gin.Context) {
 		return
 	}
 
+	if !h.checkFolderMutable(c, userID.(uuid.UUID), &folder, models.AuditActionDelete, "Folder cannot be deleted") {
+		return
+	}
+
 	// Check if folder has children or files
-	var childCount int64
-	var fileCount int64
-	h.db.Model(&models.Folder{}).Where("parent_id = ?", folderUUID).Count(&childCount)
-	h.db.Model(&models.File{}).Where("folder_id = ? AND is_deleted = false", folderUUID).Count(&fileCount)
+	stats, err := services.GetFolderStats(h.db, folderUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve folder stats"})
+		return
+	}
 
-	if (childCount > 0 || fileCount > 0) && !forceDelete {
+	if (stats.ChildCount > 0 || stats.FileCount > 0) && !forceDelete {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":       "Folder is not empty",
-			"child_count": childCount,
-			"file_count":  fileCount,
+			"child_count": stats.ChildCount,
+			"file_count":  stats.FileCount,
+			"total_size":  stats.TotalSize,
 			"suggestion":  "Use force=true to delete folder and all its contents",
 		})
 		return
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := h.db.WithContext(c.Request.Context()).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
+	if err := services.RemoveFolderStats(tx, &folder); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder stats"})
+		return
+	}
+
 	if forceDelete {
 		// Delete all files in folder and subfolders recursively
-		if err := h.deleteAllFolderContents(tx, folderUUID); err != nil {
+		if err := h.deleteAllFolderContents(tx, folderUUID, userID.(uuid.UUID)); err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder contents"})
 			return
@@ -553,6 +654,193 @@ func (h *FolderHandler) DeleteFolder(c *gin.Context) {
 	})
 }
 
+// EnableWORM makes a folder write-once or extends its retention period
+// POST /api/v1/folders/:id/worm
+func (h *FolderHandler) EnableWORM(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	folderUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	var req struct {
+		RetentionDays int `json:"retention_days"`
+	}
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	if req.RetentionDays == 0 {
+		req.RetentionDays = h.cfg.WORMDefaultRetentionDays
+	}
+
+	folder, err := h.retentionService.EnableWORM(folderUUID, userID.(uuid.UUID), req.RetentionDays)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFolderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		case errors.Is(err, services.ErrInvalidRetention):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable WORM retention"})
+		}
+		return
+	}
+
+	h.retentionService.LogEnabled(c, userID.(uuid.UUID), folder)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "WORM retention enabled",
+		"folder":  NewFolderDTO(folder),
+	})
+}
+
+// SetSizeLimit caps the total size of a folder and its subfolders, or with
+// a null size_limit removes the cap
+// PUT /api/v1/folders/:id/size-limit
+func (h *FolderHandler) SetSizeLimit(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	ownerID := userID.(uuid.UUID)
+	h.setSizeLimit(c, &ownerID)
+}
+
+// SetSizeLimitAsAdmin caps the size of any user's folder
+// PUT /api/v1/admin/folders/:id/size-limit
+func (h *FolderHandler) SetSizeLimitAsAdmin(c *gin.Context) {
+	h.setSizeLimit(c, nil)
+}
+
+func (h *FolderHandler) setSizeLimit(c *gin.Context, ownerID *uuid.UUID) {
+	folderUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	var req struct {
+		SizeLimit *int64 `json:"size_limit"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	folder, err := services.SetFolderSizeLimit(h.db, folderUUID, ownerID, req.SizeLimit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFolderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		case errors.Is(err, services.ErrInvalidSizeLimit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder size limit"})
+		}
+		return
+	}
+
+	stats, err := services.GetFolderStats(h.db, folder.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve folder stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Folder size limit updated",
+		"folder":     NewFolderDTO(folder),
+		"total_size": stats.TotalSize,
+		"file_count": stats.FileCount,
+	})
+}
+
+// DownloadFolderZip streams a folder as a ZIP archive. Owners get the whole
+// subtree with its folder structure; share recipients with download
+// permission get the files of the shared folder itself. Quarantined files are
+// left out
+// GET /api/v1/folders/:id/download
+func (h *FolderHandler) DownloadFolderZip(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	uid := userID.(uuid.UUID)
+
+	folderUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	access, err := h.accessService.CanDownloadFolder(c.Request.Context(), uid, folderUUID)
+	if err != nil {
+		respondAccessError(c, err)
+		return
+	}
+	root := access.Folder
+
+	// Shares do not extend to subfolders, so recipients get one level
+	var folders []models.Folder
+	if access.Via == services.AccessViaOwner {
+		if err := h.db.WithContext(c.Request.Context()).Where("id IN ("+folderSubtreeSQL+")", []uuid.UUID{root.ID}).
+			Find(&folders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list folder contents"})
+			return
+		}
+	}
+
+	// Lay folders out by their path below the downloaded folder
+	paths := map[uuid.UUID]string{root.ID: ""}
+	byParent := make(map[uuid.UUID][]*models.Folder)
+	for i := range folders {
+		if folders[i].ParentID != nil && folders[i].ID != root.ID {
+			byParent[*folders[i].ParentID] = append(byParent[*folders[i].ParentID], &folders[i])
+		}
+	}
+	var dirs []string
+	used := make(map[string]bool)
+	queue := []uuid.UUID{root.ID}
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		for _, child := range byParent[parentID] {
+			childPath := uniqueZipName(used, path.Join(paths[parentID], zipEntryName(child.Name)))
+			paths[child.ID] = childPath
+			dirs = append(dirs, childPath)
+			queue = append(queue, child.ID)
+		}
+	}
+
+	folderIDs := make([]uuid.UUID, 0, len(paths))
+	for id := range paths {
+		folderIDs = append(folderIDs, id)
+	}
+	var files []models.File
+	if err := h.db.WithContext(c.Request.Context()).Where("folder_id IN ? AND deleted_at IS NULL AND is_quarantined = ?", folderIDs, false).
+		Order("original_filename").Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list folder contents"})
+		return
+	}
+	if h.zipDownload.tooMany(c, len(files)) {
+		return
+	}
+
+	entries := make([]zipFile, len(files))
+	for i := range files {
+		name := path.Join(paths[*files[i].FolderID], zipEntryName(files[i].OriginalFilename))
+		entries[i] = zipFile{name: uniqueZipName(used, name), file: &files[i]}
+	}
+
+	h.zipDownload.serve(c, &uid, zipEntryName(root.Name), dirs, entries)
+}
+
 // GetFolderTree gets the complete folder tree for the user
 func (h *FolderHandler) GetFolderTree(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -562,7 +850,7 @@ func (h *FolderHandler) GetFolderTree(c *gin.Context) {
 	}
 
 	var folders []models.Folder
-	if err := h.db.Where("owner_id = ?", userID).Order("path ASC").Find(&folders).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Preload("Stats").Where("owner_id = ?", userID).Order("path ASC").Find(&folders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve folder tree"})
 		return
 	}
@@ -577,6 +865,23 @@ func (h *FolderHandler) GetFolderTree(c *gin.Context) {
 
 // Helper functions
 
+// checkFolderMutable rejects changes to folders holding WORM-retained content
+// and reports whether the change may go ahead
+func (h *FolderHandler) checkFolderMutable(c *gin.Context, userID uuid.UUID, folder *models.Folder, action models.AuditLogAction, message string) bool {
+	locked, err := h.retentionService.FolderTreeLocked(folder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder retention"})
+		return false
+	}
+	if !locked {
+		return true
+	}
+
+	h.retentionService.LogBlocked(c, userID, action, models.AuditResourceFolder, folder.ID, folder.Name, nil)
+	c.JSON(http.StatusForbidden, retentionLockedResponse(message, nil))
+	return false
+}
+
 func sanitizeFolderName(name string) string {
 	// Remove leading/trailing whitespace
 	name = strings.TrimSpace(name)
@@ -616,7 +921,7 @@ func (h *FolderHandler) updateChildrenPaths(tx *gorm.DB, parentID uuid.UUID, old
 	return nil
 }
 
-func (h *FolderHandler) deleteAllFolderContents(tx *gorm.DB, folderID uuid.UUID) error {
+func (h *FolderHandler) deleteAllFolderContents(tx *gorm.DB, folderID uuid.UUID, actorID uuid.UUID) error {
 	// Get all subfolders
 	var subfolders []models.Folder
 	if err := tx.Where("parent_id = ?", folderID).Find(&subfolders).Error; err != nil {
@@ -625,13 +930,34 @@ func (h *FolderHandler) deleteAllFolderContents(tx *gorm.DB, folderID uuid.UUID)
 
 	// Recursively delete subfolder contents
 	for _, subfolder := range subfolders {
-		if err := h.deleteAllFolderContents(tx, subfolder.ID); err != nil {
+		if err := h.deleteAllFolderContents(tx, subfolder.ID, actorID); err != nil {
+			return err
+		}
+	}
+
+	// Record the deletion of files that are still live, then mark all files
+	// in this folder as deleted
+	var files []models.File
+	if err := tx.Select("id", "owner_id", "original_filename").Where("folder_id = ?", folderID).Find(&files).Error; err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := services.RecordFileEvent(tx, services.FileEventParams{
+			FileID:  file.ID,
+			OwnerID: file.OwnerID,
+			ActorID: &actorID,
+			Type:    models.FileEventDeleted,
+			Payload: models.FileEventPayload{
+				"filename":       file.OriginalFilename,
+				"folder_id":      folderID,
+				"folder_deleted": true,
+			},
+		}); err != nil {
 			return err
 		}
 	}
 
-	// Mark all files in this folder as deleted
-	if err := tx.Model(&models.File{}).Where("folder_id = ?", folderID).Update("is_deleted", true).Error; err != nil {
+	if err := tx.Where("folder_id = ?", folderID).Delete(&models.File{}).Error; err != nil {
 		return err
 	}
 
@@ -644,7 +970,7 @@ func (h *FolderHandler) deleteAllFolderContents(tx *gorm.DB, folderID uuid.UUID)
 }
 
 type FolderTreeNode struct {
-	models.Folder
+	FolderDTO
 	Children []FolderTreeNode `json:"children"`
 }
 
@@ -655,8 +981,8 @@ func buildFolderTree(folders []models.Folder) []FolderTreeNode {
 	// First pass: create all nodes
 	for _, folder := range folders {
 		node := FolderTreeNode{
-			Folder:   folder,
-			Children: []FolderTreeNode{},
+			FolderDTO: NewFolderDTO(&folder),
+			Children:  []FolderTreeNode{},
 		}
 		folderMap[folder.ID] = &node
 	}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -8,19 +9,38 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/middleware"
 	"file-vault-system/backend/internal/models"
 	"file-vault-system/backend/internal/services"
+	"file-vault-system/backend/pkg/preview"
 )
 
 type FolderSharingHandler struct {
 	db                   *gorm.DB
 	folderSharingService *services.FolderSharingService
+	guestService         *services.GuestService
+	auditService         *services.AuditService
+	linkService          *services.LinkService
+	shareNotifier        *services.ShareNotifier
+	integrations         *services.IntegrationService
+	galleryService       *services.GalleryService
+	fileStreamService    *services.FileStreamService
+	zipDownload          *zipDownload
 }
 
-func NewFolderSharingHandler(db *gorm.DB, folderSharingService *services.FolderSharingService) *FolderSharingHandler {
+func NewFolderSharingHandler(db *gorm.DB, cfg *config.Config, folderSharingService *services.FolderSharingService, guestService *services.GuestService, auditService *services.AuditService, linkService *services.LinkService, shareNotifier *services.ShareNotifier, integrations *services.IntegrationService) *FolderSharingHandler {
 	return &FolderSharingHandler{
 		db:                   db,
 		folderSharingService: folderSharingService,
+		guestService:         guestService,
+		auditService:         auditService,
+		linkService:          linkService,
+		shareNotifier:        shareNotifier,
+		integrations:         integrations,
+		galleryService:       services.NewGalleryService(db, cfg),
+		fileStreamService:    services.NewFileStreamService(db, cfg),
+		zipDownload:          newZipDownload(db, cfg, auditService),
 	}
 }
 
@@ -30,6 +50,8 @@ type ShareFolderRequest struct {
 	SharedWithEmail string `json:"sharedWithEmail" binding:"required,email"`
 	Permission      string `json:"permission" binding:"required"`
 	Message         string `json:"message"`
+	AsGuest         bool   `json:"createGuest"` // create a guest account when the email is unknown
+	GuestDays       int    `json:"guestDays"`   // guest account lifetime, defaults to GUEST_ACCOUNT_DAYS
 }
 
 type CreateFolderShareLinkRequest struct {
@@ -37,6 +59,7 @@ type CreateFolderShareLinkRequest struct {
 	Permission string `json:"permission" binding:"required"`
 	ExpiresAt  string `json:"expiresAt"` // Optional expiration date
 	Password   string `json:"password"`  // Optional password protection
+	Mode       string `json:"mode"`      // "folder" (default) or "album"
 }
 
 // ShareFolderWithUser creates an internal share between users
@@ -48,8 +71,7 @@ func (h *FolderSharingHandler) ShareFolderWithUser(c *gin.Context) {
 	}
 
 	var req ShareFolderRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -67,9 +89,23 @@ func (h *FolderSharingHandler) ShareFolderWithUser(c *gin.Context) {
 		return
 	}
 
-	// Find target user by email
+	var guest *services.GuestCredentials
+	if req.AsGuest {
+		// Only the owner of the folder may invite a guest to it
+		if err := h.folderSharingService.CheckSharable(folderID, userID.(uuid.UUID)); err != nil {
+			c.Error(err)
+			return
+		}
+		guest, err = inviteGuest(c, h.guestService, req.SharedWithEmail, userID.(uuid.UUID), req.GuestDays)
+		if err != nil {
+			return
+		}
+	}
+
+	// Find target user by email within the sharer's tenant
 	var targetUser models.User
-	if err := h.db.Where("email = ?", req.SharedWithEmail).First(&targetUser).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Scopes(models.InSameTenantAs(userID.(uuid.UUID))).
+		Where("email = ?", req.SharedWithEmail).First(&targetUser).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User with this email not found"})
 		} else {
@@ -88,14 +124,19 @@ func (h *FolderSharingHandler) ShareFolderWithUser(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
+	h.shareNotifier.FolderShared(share)
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message": "Folder shared successfully",
-		"share":   share,
-	})
+		"share":   NewFolderShareDTO(share),
+	}
+	if guest != nil {
+		response["guest"] = guest
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 // CreateFolderShareLink creates a shareable link for external sharing
@@ -107,8 +148,7 @@ func (h *FolderSharingHandler) CreateFolderShareLink(c *gin.Context) {
 	}
 
 	var req CreateFolderShareLinkRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -126,6 +166,16 @@ func (h *FolderSharingHandler) CreateFolderShareLink(c *gin.Context) {
 		return
 	}
 
+	// Validate mode
+	mode := models.FolderShareLinkMode(req.Mode)
+	if mode == "" {
+		mode = models.FolderShareLinkModeFolder
+	}
+	if mode != models.FolderShareLinkModeFolder && mode != models.FolderShareLinkModeAlbum {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Must be 'folder' or 'album'"})
+		return
+	}
+
 	// Parse expiration date if provided
 	var expiresAt *time.Time
 	if req.ExpiresAt != "" {
@@ -142,19 +192,21 @@ func (h *FolderSharingHandler) CreateFolderShareLink(c *gin.Context) {
 		folderID,
 		userID.(uuid.UUID),
 		permission,
+		mode,
 		expiresAt,
 		req.Password,
 		nil, // maxDownloads - not implemented in the request, could be added later
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message":   "Share link created successfully",
-		"shareLink": shareLink,
+		"shareLink": NewFolderShareLinkDTO(shareLink),
+		"url":       h.linkService.FolderShareLinkURL(shareLink.CreatedBy, shareLink.Token),
 	})
 }
 
@@ -166,17 +218,30 @@ func (h *FolderSharingHandler) GetSharedFolders(c *gin.Context) {
 		return
 	}
 
-	sharedFolders, err := h.folderSharingService.GetSharedFolders(userID.(uuid.UUID))
+	filter, message := shareListFilter(c)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	sharedFolders, err := h.folderSharingService.GetSharedFolders(userID.(uuid.UUID), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"sharedFolders": sharedFolders,
+		"sharedFolders": NewFolderShareDTOs(sharedFolders),
 	})
 }
 
+// RespondToSharedFolder accepts, declines, hides or unhides a folder share
+// made to the current user
+// POST /api/v1/shared-folders/:id/accept|decline|hide|unhide
+func (h *FolderSharingHandler) RespondToSharedFolder(c *gin.Context) {
+	applyShareAction(c, h.folderSharingService.RespondToFolderShare, h.folderSharingService.SetFolderShareHidden)
+}
+
 // GetFolderShares returns all shares for folders owned by the current user
 func (h *FolderSharingHandler) GetFolderShares(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -187,19 +252,19 @@ func (h *FolderSharingHandler) GetFolderShares(c *gin.Context) {
 
 	// Get all folders owned by the user and their shares
 	var folderShares []models.FolderShare
-	err := h.db.Joins("JOIN folders ON folders.id = folder_shares.folder_id").
+	err := h.db.WithContext(c.Request.Context()).Joins("JOIN folders ON folders.id = folder_shares.folder_id").
 		Where("folders.owner_id = ?", userID).
 		Preload("Folder").
 		Preload("SharedWithUser").
 		Find(&folderShares).Error
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"folderShares": folderShares,
+		"folderShares": NewFolderShareDTOs(folderShares),
 	})
 }
 
@@ -213,12 +278,12 @@ func (h *FolderSharingHandler) GetFolderShareLinks(c *gin.Context) {
 
 	shareLinks, err := h.folderSharingService.GetFolderShareLinks(userID.(uuid.UUID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"shareLinks": shareLinks,
+		"shareLinks": NewFolderShareLinkDTOs(shareLinks),
 	})
 }
 
@@ -239,7 +304,7 @@ func (h *FolderSharingHandler) RemoveFolderShare(c *gin.Context) {
 
 	err = h.folderSharingService.RevokeFolderShare(shareID, userID.(uuid.UUID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -265,7 +330,7 @@ func (h *FolderSharingHandler) RemoveFolderShareLink(c *gin.Context) {
 
 	err = h.folderSharingService.RevokeFolderShareLink(linkID, userID.(uuid.UUID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -274,29 +339,249 @@ func (h *FolderSharingHandler) RemoveFolderShareLink(c *gin.Context) {
 	})
 }
 
-// AccessSharedFolderByLink provides public access to shared folders via link
+// UnlockSharedFolderByLink checks the password of a folder share link, sent
+// in the body rather than the URL, and issues a short-lived access token
+// that opens only this link
+// POST /folder-share/:token/unlock
+func (h *FolderSharingHandler) UnlockSharedFolderByLink(c *gin.Context) {
+	var req shareUnlockRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	access, err := h.folderSharingService.UnlockFolderShareLink(c.Param("token"), req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondShareUnlocked(c, "/folder-share/"+c.Param("token"), access)
+}
+
+// AccessSharedFolderByLink provides public access to shared folders via
+// link. Album links answer with the folder's photos and videos instead
+// GET /folder-share/:token
 func (h *FolderSharingHandler) AccessSharedFolderByLink(c *gin.Context) {
 	token := c.Param("token")
-	password := c.Query("password") // Optional password
 
-	shareLink, err := h.folderSharingService.AccessFolderByToken(token, password)
+	shareLink, err := h.folderSharingService.AccessFolderByToken(token, shareLinkCredentials(c, "/folder-share/"+token))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	// Log access
-	h.folderSharingService.LogFolderShareLinkAccess(shareLink.ID, c.ClientIP(), c.GetHeader("User-Agent"), "view")
+	h.folderSharingService.LogFolderShareLinkAccess(shareLink, middleware.StoredClientIP(c), middleware.StoredUserAgent(c), "view")
+
+	if shareLink.Mode == models.FolderShareLinkModeAlbum {
+		h.respondAlbum(c, shareLink)
+		return
+	}
 
 	// Get the folder
 	var folder models.Folder
-	if err := h.db.Preload("Files").Where("id = ?", shareLink.FolderID).First(&folder).Error; err != nil {
+	if err := h.db.WithContext(c.Request.Context()).Preload("Files").Where("id = ?", shareLink.FolderID).First(&folder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	h.auditService.LogFolderAccess(c, models.AuditActionView, &folder, "share_link")
+	h.integrations.LinkAccessed(shareLink.CreatedBy, "folder", folder.Name, "view", middleware.StoredClientIP(c), c.GetString("client_country"),
+		h.linkService.FolderShareLinkURL(shareLink.CreatedBy, shareLink.Token))
+
+	c.JSON(http.StatusOK, gin.H{
+		"folder":    NewFolderDTO(&folder),
+		"shareLink": NewFolderShareLinkDTO(shareLink),
+	})
+}
+
+// respondAlbum answers an album link with the folder's photos and videos in
+// the order they were taken, each with a signed thumbnail URL and the URL
+// that opens it, and the order a slideshow steps through them
+func (h *FolderSharingHandler) respondAlbum(c *gin.Context, shareLink *models.FolderShareLink) {
+	var folder models.Folder
+	if err := h.db.WithContext(c.Request.Context()).Where("id = ?", shareLink.FolderID).First(&folder).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
 		return
 	}
 
+	album, err := h.galleryService.Album(folder.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	expiresAt := h.galleryService.ThumbnailExpiry()
+	items := make([]AlbumItemDTO, len(album))
+	slideshow := make([]uuid.UUID, 0, len(album))
+	for i := range album {
+		file := &album[i].File
+		var thumbnailURL, viewURL string
+		if h.galleryService.HasThumbnail(file) {
+			thumbnailURL = h.galleryService.ThumbnailURL(file, expiresAt)
+		}
+		// Encrypted and quarantined items are listed but cannot be opened,
+		// nor can items of view-only albums that cannot be rendered
+		if !file.IsEncrypted && !file.IsQuarantined && albumItemViewable(shareLink, file) {
+			viewURL = "/folder-share/" + shareLink.Token + "/items/" + file.ID.String()
+			slideshow = append(slideshow, file.ID)
+		}
+		items[i] = NewAlbumItemDTO(&album[i], thumbnailURL, viewURL)
+	}
+
+	h.auditService.LogFolderAccess(c, models.AuditActionView, &folder, "share_link")
+	h.integrations.LinkAccessed(shareLink.CreatedBy, "folder", folder.Name, "view", middleware.StoredClientIP(c), c.GetString("client_country"),
+		h.linkService.FolderShareLinkURL(shareLink.CreatedBy, shareLink.Token))
+
 	c.JSON(http.StatusOK, gin.H{
-		"folder":    folder,
-		"shareLink": shareLink,
+		"album": gin.H{
+			"id":               folder.ID,
+			"name":             folder.Name,
+			"item_count":       len(items),
+			"download_allowed": shareLink.Permission == models.PermissionDownload,
+		},
+		"items":                items,
+		"slideshow":            slideshow,
+		"thumbnails_expire_at": expiresAt,
+		"shareLink":            NewFolderShareLinkDTO(shareLink),
 	})
 }
+
+// albumLink validates the album link of the request, answering the request
+// when it is invalid or the link shares a plain folder
+func (h *FolderSharingHandler) albumLink(c *gin.Context) (*models.FolderShareLink, bool) {
+	token := c.Param("token")
+
+	shareLink, err := h.folderSharingService.AccessFolderByToken(token, shareLinkCredentials(c, "/folder-share/"+token))
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	if shareLink.Mode != models.FolderShareLinkModeAlbum {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link is not an album"})
+		return nil, false
+	}
+	return shareLink, true
+}
+
+// albumItemViewable reports whether an album link can show a file. View-only
+// albums only show files that can be rendered as images
+func albumItemViewable(shareLink *models.FolderShareLink, file *models.File) bool {
+	return shareLink.Permission == models.PermissionDownload || preview.Supports(file.MimeType)
+}
+
+// ViewAlbumItem opens a photo or video of an album link inline, for the
+// slideshow. Only items of the album can be opened. View-only albums show
+// items rendered as an image, their first page for PDFs, so the originals
+// cannot be saved from them
+// GET /folder-share/:token/items/:fileId
+func (h *FolderSharingHandler) ViewAlbumItem(c *gin.Context) {
+	shareLink, ok := h.albumLink(c)
+	if !ok {
+		return
+	}
+
+	fileID, err := uuid.Parse(c.Param("fileId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+	files, err := h.galleryService.AlbumFiles(shareLink.FolderID, []uuid.UUID{fileID})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found in album"})
+		return
+	}
+	file := &files[0]
+
+	if respondIfQuarantined(c, file) || respondIfEncrypted(c, file) {
+		return
+	}
+	if respondIfUnscanned(c, h.fileStreamService, file) {
+		return
+	}
+	if !albumItemViewable(shareLink, file) {
+		c.Error(services.ErrPreviewUnsupported)
+		return
+	}
+	stream, err := h.fileStreamService.Open(file)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
+
+	h.auditService.LogFileAccess(c, models.AuditActionView, file, "share_link")
+	if shareLink.Permission != models.PermissionDownload {
+		h.fileStreamService.ServeRendered(c, stream, 1, nil)
+		return
+	}
+	h.fileStreamService.Serve(c, stream, services.StreamInline)
+}
+
+// DownloadAlbumZip streams the chosen photos and videos of an album link as
+// one ZIP archive, named after the folder. The link must allow downloads, and
+// the archive counts as one download against its limit
+// POST /folder-share/:token/download-zip
+func (h *FolderSharingHandler) DownloadAlbumZip(c *gin.Context) {
+	shareLink, ok := h.albumLink(c)
+	if !ok {
+		return
+	}
+	if shareLink.Permission != models.PermissionDownload {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Download not allowed for this share"})
+		return
+	}
+
+	var req struct {
+		FileIDs []uuid.UUID `json:"file_ids" binding:"required,min=1"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	if h.zipDownload.tooMany(c, len(req.FileIDs)) {
+		return
+	}
+
+	found, err := h.galleryService.AlbumFiles(shareLink.FolderID, req.FileIDs)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	byID := make(map[uuid.UUID]*models.File, len(found))
+	for i := range found {
+		byID[found[i].ID] = &found[i]
+	}
+
+	// Entries follow the order the files were chosen in
+	seen := make(map[uuid.UUID]bool, len(req.FileIDs))
+	used := make(map[string]bool, len(req.FileIDs))
+	files := make([]zipFile, 0, len(found))
+	for _, fileID := range req.FileIDs {
+		if seen[fileID] {
+			continue
+		}
+		seen[fileID] = true
+
+		file, ok := byID[fileID]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found in album", "file_id": fileID})
+			return
+		}
+		if respondIfQuarantined(c, file) {
+			return
+		}
+		files = append(files, zipFile{name: uniqueZipName(used, zipEntryName(file.OriginalFilename)), file: file})
+	}
+
+	ipAddress := middleware.StoredClientIP(c)
+	if err := h.folderSharingService.RecordFolderShareLinkDownload(shareLink, ipAddress, middleware.StoredUserAgent(c)); err != nil {
+		fmt.Printf("Failed to record album download through link %s: %v\n", shareLink.ID, err)
+	}
+	h.integrations.LinkAccessed(shareLink.CreatedBy, "folder", shareLink.Folder.Name, "download", ipAddress, c.GetString("client_country"),
+		h.linkService.FolderShareLinkURL(shareLink.CreatedBy, shareLink.Token))
+
+	h.zipDownload.serve(c, nil, zipEntryName(shareLink.Folder.Name), nil, files)
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// ListGallery lists files for photo-grid clients: only what a grid shows,
+// newest first, with each thumbnail's signed URL and each image's dimensions.
+// folder_id selects the folder as on ListFiles, and mime_type filters by
+// prefix, such as image/
+// GET /api/v1/files/gallery
+func (h *FileHandler) ListGallery(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	pageNum, limitNum := 1, 100
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		pageNum = p
+	}
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limitNum = l
+	}
+
+	query := h.db.WithContext(c.Request.Context()).Model(&models.File{})
+	switch folderIDStr := c.Query("folder_id"); folderIDStr {
+	case "":
+		query = query.Scopes(services.VisibleFiles(userID))
+	case "root", "null":
+		query = query.Scopes(services.OwnedFiles(userID)).Where("files.folder_id IS NULL")
+	default:
+		folderUUID, err := uuid.Parse(folderIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID format"})
+			return
+		}
+		// Owners and users the folder is shared with see all its files
+		if _, err := h.accessService.CanViewFolder(c.Request.Context(), userID, folderUUID); err != nil {
+			respondAccessError(c, err)
+			return
+		}
+		query = query.Where("files.folder_id = ?", folderUUID)
+	}
+	if mimeType := c.Query("mime_type"); mimeType != "" {
+		query = query.Where("files.mime_type LIKE ?", mimeType+"%")
+	}
+
+	var totalCount int64
+	if err := query.Count(&totalCount).Error; err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	var files []models.File
+	if err := query.Select(services.GalleryFileColumns).
+		Order("files.created_at DESC, files.id").
+		Offset((pageNum - 1) * limitNum).
+		Limit(limitNum).
+		Find(&files).Error; err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	media, err := h.galleryService.Inspect(files)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	// Every URL in the page is signed until the same time, in one pass
+	expiresAt := h.galleryService.ThumbnailExpiry()
+	items := make([]GalleryItemDTO, len(files))
+	for i := range files {
+		var thumbnailURL string
+		if h.galleryService.HasThumbnail(&files[i]) {
+			thumbnailURL = h.galleryService.ThumbnailURL(&files[i], expiresAt)
+		}
+		items[i] = NewGalleryItemDTO(&files[i], thumbnailURL, media[files[i].FileHashID])
+	}
+
+	h.auditService.LogListAccess(c, models.AuditResourceFile, nil, len(files), nil)
+
+	totalPages := int((totalCount + int64(limitNum) - 1) / int64(limitNum))
+	c.JSON(http.StatusOK, gin.H{
+		"files":                items,
+		"count":                len(files),
+		"total_count":          totalCount,
+		"thumbnails_expire_at": expiresAt,
+		"pagination": gin.H{
+			"current_page": pageNum,
+			"total_pages":  totalPages,
+			"limit":        limitNum,
+			"has_next":     pageNum < totalPages,
+			"has_previous": pageNum > 1,
+		},
+	})
+}
+
+// GetTimeline groups the user's photos and videos by when they were taken,
+// for a scrolling timeline: each period has its counts and the signed
+// thumbnail of its latest photo as a cover. granularity is day, month or
+// year, and tz the time zone upload times are placed in
+// GET /api/v1/files/timeline?granularity=month&tz=Europe/Berlin
+func (h *FileHandler) GetTimeline(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	granularity := c.DefaultQuery("granularity", services.TimelineMonth)
+	switch granularity {
+	case services.TimelineDay, services.TimelineMonth, services.TimelineYear:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be day, month or year"})
+		return
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+		return
+	}
+
+	timeline, err := h.galleryService.Timeline(userID, granularity, loc)
+	if err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	expiresAt := h.galleryService.ThumbnailExpiry()
+	buckets := make([]TimelineBucketDTO, len(timeline))
+	total := 0
+	for i := range timeline {
+		var coverURL string
+		if timeline[i].Cover != nil {
+			coverURL = h.galleryService.ThumbnailURL(timeline[i].Cover, expiresAt)
+		}
+		buckets[i] = NewTimelineBucketDTO(&timeline[i], coverURL)
+		total += buckets[i].Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"granularity":          granularity,
+		"time_zone":            loc.String(),
+		"buckets":              buckets,
+		"total_count":          total,
+		"thumbnails_expire_at": expiresAt,
+	})
+}
+
+// signThumbnailsRequest names the files whose thumbnail URLs are wanted
+type signThumbnailsRequest struct {
+	FileIDs []uuid.UUID `json:"file_ids" binding:"required,min=1,max=200"`
+}
+
+// SignThumbnails signs the thumbnail URLs of many files in one request, so a
+// client whose URLs expired refreshes a whole grid at once. Files the user
+// cannot view or that have no thumbnail are left out
+// POST /api/v1/files/thumbnails
+func (h *FileHandler) SignThumbnails(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req signThumbnailsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	var files []models.File
+	if err := h.db.WithContext(c.Request.Context()).Model(&models.File{}).
+		Scopes(services.VisibleFiles(userID)).
+		Where("files.id IN ?", req.FileIDs).
+		Select(services.GalleryFileColumns).
+		Find(&files).Error; err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	expiresAt := h.galleryService.ThumbnailExpiry()
+	thumbnails := make(map[uuid.UUID]string, len(files))
+	for i := range files {
+		if h.galleryService.HasThumbnail(&files[i]) {
+			thumbnails[files[i].ID] = h.galleryService.ThumbnailURL(&files[i], expiresAt)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"thumbnails": thumbnails,
+		"expires_at": expiresAt,
+	})
+}
+
+// GetThumbnail serves a thumbnail from a signed URL. The signature stands in
+// for authentication, so grids load thumbnails as plain image URLs; it stops
+// working when the URL expires or the file's content is replaced
+// GET /thumbnails/:id
+func (h *FileHandler) GetThumbnail(c *gin.Context) {
+	fileID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(services.ErrThumbnailURLInvalid)
+		return
+	}
+
+	file, remaining, err := h.galleryService.ThumbnailFile(fileID, c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if respondIfQuarantined(c, file) {
+		return
+	}
+	if respondIfUnscanned(c, h.fileStreamService, file) {
+		return
+	}
+	if respondIfEncrypted(c, file) {
+		return
+	}
+
+	stream, err := h.fileStreamService.Open(file)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
+
+	h.fileStreamService.ServeThumbnail(c, stream, remaining)
+}
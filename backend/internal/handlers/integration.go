@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// IntegrationHandler manages Slack and Microsoft Teams integrations. The same
+// endpoints serve personal integrations under /me, team integrations under
+// /tenant and platform integrations under /admin
+type IntegrationHandler struct {
+	integrationService *services.IntegrationService
+}
+
+func NewIntegrationHandler(integrationService *services.IntegrationService) *IntegrationHandler {
+	return &IntegrationHandler{integrationService: integrationService}
+}
+
+// IntegrationDTO is an integration as shown to its owner. The webhook URL is
+// a secret, so only its host is shown
+type IntegrationDTO struct {
+	ID              uuid.UUID              `json:"id"`
+	Kind            models.IntegrationKind `json:"kind"`
+	Name            string                 `json:"name"`
+	WebhookHost     string                 `json:"webhook_host"`
+	Events          []string               `json:"events"`
+	IsActive        bool                   `json:"is_active"`
+	LastDeliveredAt *time.Time             `json:"last_delivered_at,omitempty"`
+	LastError       string                 `json:"last_error,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// NewIntegrationDTO creates an IntegrationDTO from an integration
+func NewIntegrationDTO(integration *models.Integration) IntegrationDTO {
+	var host string
+	if u, err := url.Parse(integration.WebhookURL); err == nil {
+		host = u.Host
+	}
+	events := []string(integration.Events)
+	if events == nil {
+		events = []string{}
+	}
+	return IntegrationDTO{
+		ID:              integration.ID,
+		Kind:            integration.Kind,
+		Name:            integration.Name,
+		WebhookHost:     host,
+		Events:          events,
+		IsActive:        integration.IsActive,
+		LastDeliveredAt: integration.LastDeliveredAt,
+		LastError:       integration.LastError,
+		CreatedAt:       integration.CreatedAt,
+		UpdatedAt:       integration.UpdatedAt,
+	}
+}
+
+// integrationOwner returns whose integrations the request manages, going by
+// the route it came in on
+func integrationOwner(c *gin.Context) services.IntegrationOwner {
+	switch {
+	case strings.HasPrefix(c.FullPath(), "/api/v1/admin/"):
+		return services.IntegrationOwner{}
+	case strings.HasPrefix(c.FullPath(), "/api/v1/tenant/"):
+		return services.IntegrationOwner{TenantID: middleware.TenantID(c)}
+	default:
+		userID := c.MustGet("user_id").(uuid.UUID)
+		return services.IntegrationOwner{UserID: &userID}
+	}
+}
+
+// ListIntegrations returns the integrations
+// GET /api/v1/me/integrations, /api/v1/tenant/integrations, /api/v1/admin/integrations
+func (h *IntegrationHandler) ListIntegrations(c *gin.Context) {
+	integrations, err := h.integrationService.ListIntegrations(integrationOwner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch integrations"})
+		return
+	}
+
+	dtos := make([]IntegrationDTO, len(integrations))
+	for i := range integrations {
+		dtos[i] = NewIntegrationDTO(&integrations[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"integrations": dtos,
+		"events":       models.IntegrationEvents,
+	})
+}
+
+// CreateIntegration connects a Slack or Teams incoming webhook
+// POST /api/v1/me/integrations, /api/v1/tenant/integrations, /api/v1/admin/integrations
+func (h *IntegrationHandler) CreateIntegration(c *gin.Context) {
+	var req services.IntegrationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	integration, err := h.integrationService.CreateIntegration(integrationOwner(c), c.MustGet("user_id").(uuid.UUID), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidIntegration):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrIntegrationLimit):
+			c.JSON(http.StatusConflict, gin.H{"error": "No more integrations can be added"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create integration"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, NewIntegrationDTO(integration))
+}
+
+// UpdateIntegration replaces an integration's name, events and status, and
+// its webhook URL when one is given
+// PUT /api/v1/me/integrations/:id, /api/v1/tenant/integrations/:id, /api/v1/admin/integrations/:id
+func (h *IntegrationHandler) UpdateIntegration(c *gin.Context) {
+	integrationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid integration ID"})
+		return
+	}
+
+	var req services.IntegrationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	integration, err := h.integrationService.UpdateIntegration(integrationOwner(c), integrationID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrIntegrationNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Integration not found"})
+		case errors.Is(err, services.ErrInvalidIntegration):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update integration"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, NewIntegrationDTO(integration))
+}
+
+// DeleteIntegration disconnects an integration
+// DELETE /api/v1/me/integrations/:id, /api/v1/tenant/integrations/:id, /api/v1/admin/integrations/:id
+func (h *IntegrationHandler) DeleteIntegration(c *gin.Context) {
+	integrationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid integration ID"})
+		return
+	}
+
+	if err := h.integrationService.DeleteIntegration(integrationOwner(c), integrationID); err != nil {
+		if errors.Is(err, services.ErrIntegrationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Integration not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete integration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Integration deleted"})
+}
+
+// TestIntegration posts a test message and returns the integration with the
+// outcome in last_delivered_at and last_error
+// POST /api/v1/me/integrations/:id/test, /api/v1/tenant/integrations/:id/test, /api/v1/admin/integrations/:id/test
+func (h *IntegrationHandler) TestIntegration(c *gin.Context) {
+	integrationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid integration ID"})
+		return
+	}
+
+	integration, err := h.integrationService.TestIntegration(integrationOwner(c), integrationID)
+	if err != nil {
+		if errors.Is(err, services.ErrIntegrationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Integration not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to test integration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, NewIntegrationDTO(integration))
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/services"
+)
+
+type LoginHistoryHandler struct {
+	loginHistory *services.LoginHistoryService
+}
+
+func NewLoginHistoryHandler(loginHistory *services.LoginHistoryService) *LoginHistoryHandler {
+	return &LoginHistoryHandler{
+		loginHistory: loginHistory,
+	}
+}
+
+// GetMyLoginHistory lists the current user's successful and failed sign-in
+// attempts, newest first
+// GET /api/v1/me/login-history
+func (h *LoginHistoryHandler) GetMyLoginHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	logins, total, err := h.loginHistory.History(userID.(uuid.UUID), limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logins": logins,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/events"
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+)
+
+const (
+	// opsStreamBuffer is how many events a slow console can fall behind before events are dropped
+	opsStreamBuffer = 256
+	// opsStreamHeartbeat keeps idle connections open through proxies
+	opsStreamHeartbeat = 15 * time.Second
+)
+
+type OpsHandler struct {
+	bus *events.Bus
+}
+
+func NewOpsHandler(bus *events.Bus) *OpsHandler {
+	return &OpsHandler{
+		bus: bus,
+	}
+}
+
+// StreamEvents streams system events to the operations console as
+// server-sent events, starting with up to ?replay= recent matching events.
+// Filter with ?types=, ?severity= (minimum) and ?user_id= (admin only)
+// GET /api/v1/admin/events/stream
+func (h *OpsHandler) StreamEvents(c *gin.Context) {
+	filter, ok := opsEventFilter(c)
+	if !ok {
+		return
+	}
+	replay, _ := strconv.Atoi(c.DefaultQuery("replay", "0"))
+
+	sub := h.bus.Subscribe(filter, opsStreamBuffer)
+	defer h.bus.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if replay > 0 {
+		for _, event := range h.bus.Recent(filter, replay) {
+			if err := writeSSEEvent(c.Writer, event); err != nil {
+				return
+			}
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(opsStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	var reportedDropped int64
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, open := <-sub.C:
+			if !open {
+				return false
+			}
+			return writeSSEEvent(w, event) == nil
+		case <-heartbeat.C:
+			// Tell the console when it has missed events so operators know the view is incomplete
+			if dropped := sub.Dropped(); dropped > reportedDropped {
+				reportedDropped = dropped
+				_, err := fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped)
+				return err == nil
+			}
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		}
+	})
+}
+
+// GetRecentEvents returns the most recent matching system events, oldest first (admin only)
+// GET /api/v1/admin/events/recent
+func (h *OpsHandler) GetRecentEvents(c *gin.Context) {
+	filter, ok := opsEventFilter(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 200 {
+		limit = 100
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":      h.bus.Recent(filter, limit),
+		"subscribers": h.bus.SubscriberCount(),
+	})
+}
+
+// opsEventFilter builds an event filter from the query string, writing a 400
+// response and returning false when a parameter is invalid
+func opsEventFilter(c *gin.Context) (events.Filter, bool) {
+	var filter events.Filter
+
+	if typesParam := c.Query("types"); typesParam != "" {
+		for _, name := range strings.Split(typesParam, ",") {
+			eventType := events.Type(strings.TrimSpace(name))
+			if !isEventType(eventType) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event type: " + string(eventType)})
+				return filter, false
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	switch severity := events.Severity(c.Query("severity")); severity {
+	case "", events.SeverityInfo, events.SeverityWarning, events.SeverityError:
+		filter.MinSeverity = severity
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid severity, expected info, warning or error"})
+		return filter, false
+	}
+
+	if userParam := c.Query("user_id"); userParam != "" {
+		userID, err := uuid.Parse(userParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return filter, false
+		}
+		filter.UserID = &userID
+	}
+
+	return filter, true
+}
+
+// isEventType reports whether t is a known event type
+func isEventType(t events.Type) bool {
+	for _, known := range events.Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSSEEvent writes one event in server-sent events format
+func writeSSEEvent(w io.Writer, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// publishUpload reports a completed upload to the operations feed
+func publishUpload(c *gin.Context, userID uuid.UUID, result *UploadResultDTO) {
+	events.Publish(events.Event{
+		Type:      events.TypeUpload,
+		Severity:  events.SeverityInfo,
+		UserID:    &userID,
+		IPAddress: middleware.StoredClientIP(c),
+		Message:   fmt.Sprintf("Uploaded %s", result.OriginalFilename),
+		Details: map[string]interface{}{
+			"file_id":      result.ID,
+			"filename":     result.OriginalFilename,
+			"size":         result.Size,
+			"mime_type":    result.MimeType,
+			"is_duplicate": result.IsDuplicate,
+		},
+	})
+}
+
+// publishDownload reports a file download to the operations feed. Downloads
+// of a file or share link the owner watches carry notify_owner, which the
+// download notifier acts on; owners are not told about their own downloads.
+func publishDownload(c *gin.Context, file *models.File, shareLink *models.ShareLink) {
+	event := events.Event{
+		Type:      events.TypeDownload,
+		Severity:  events.SeverityInfo,
+		IPAddress: middleware.StoredClientIP(c),
+		Message:   fmt.Sprintf("Downloaded %s", file.OriginalFilename),
+		Details: map[string]interface{}{
+			"file_id":  file.ID,
+			"filename": file.OriginalFilename,
+			"owner_id": file.OwnerID,
+		},
+	}
+	if uid, exists := c.Get("user_id"); exists {
+		if id, ok := uid.(uuid.UUID); ok {
+			event.UserID = &id
+		}
+	}
+	if country := c.GetString("client_country"); country != "" {
+		event.Details["country"] = country
+	}
+
+	notify := file.NotifyOnDownload
+	if shareLink != nil {
+		event.Details["share_link_id"] = shareLink.ID
+		notify = notify || shareLink.NotifyOnDownload
+	}
+	if event.UserID != nil && *event.UserID == file.OwnerID {
+		notify = false
+	}
+	event.Details["notify_owner"] = notify
+
+	events.Publish(event)
+}
+
+// publishStorageError reports a failed storage operation to the operations feed
+func publishStorageError(c *gin.Context, message string, err error) {
+	event := events.Event{
+		Type:      events.TypeStorageError,
+		Severity:  events.SeverityError,
+		IPAddress: middleware.StoredClientIP(c),
+		Message:   message,
+		Details: map[string]interface{}{
+			"error":    err.Error(),
+			"endpoint": c.Request.URL.Path,
+		},
+	}
+	if uid, exists := c.Get("user_id"); exists {
+		if id, ok := uid.(uuid.UUID); ok {
+			event.UserID = &id
+		}
+	}
+	events.Publish(event)
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type PlanHandler struct {
+	planService  *services.PlanService
+	auditService *services.AuditService
+}
+
+func NewPlanHandler(planService *services.PlanService, auditService *services.AuditService) *PlanHandler {
+	return &PlanHandler{
+		planService:  planService,
+		auditService: auditService,
+	}
+}
+
+// ListPlans returns every plan with the number of users on it (admin only)
+// GET /api/v1/admin/plans
+func (h *PlanHandler) ListPlans(c *gin.Context) {
+	plans, err := h.planService.ListPlans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// CreatePlan adds a plan (admin only)
+// POST /api/v1/admin/plans
+func (h *PlanHandler) CreatePlan(c *gin.Context) {
+	var req services.PlanRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	plan, err := h.planService.CreatePlan(req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPlan) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create plan"})
+		return
+	}
+
+	h.logPlanChange(c, models.AuditActionCreate, plan, nil)
+	c.JSON(http.StatusCreated, plan)
+}
+
+// UpdatePlan replaces a plan's limits and applies its quota to the users on it (admin only)
+// PUT /api/v1/admin/plans/:id
+func (h *PlanHandler) UpdatePlan(c *gin.Context) {
+	planID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	var req services.PlanRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	plan, err := h.planService.UpdatePlan(planID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+		case errors.Is(err, services.ErrInvalidPlan):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update plan"})
+		}
+		return
+	}
+
+	h.logPlanChange(c, models.AuditActionUpdate, plan, nil)
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeletePlan removes a plan with no users assigned (admin only)
+// DELETE /api/v1/admin/plans/:id
+func (h *PlanHandler) DeletePlan(c *gin.Context) {
+	planID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	if err := h.planService.DeletePlan(planID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+		case errors.Is(err, services.ErrPlanInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Plan is still assigned to users; migrate them to another plan first"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete plan"})
+		}
+		return
+	}
+
+	h.logPlanChange(c, models.AuditActionDelete, &models.Plan{ID: planID}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Plan deleted"})
+}
+
+// AssignUserPlan moves a user onto a plan (admin only)
+// PUT /api/v1/admin/users/:id/plan
+func (h *PlanHandler) AssignUserPlan(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		PlanID uuid.UUID `json:"plan_id" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	plan, err := h.planService.AssignPlan(userID, req.PlanID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+		case errors.Is(err, services.ErrPlanUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign plan"})
+		}
+		return
+	}
+
+	h.logPlanChange(c, models.AuditActionUpdate, plan, models.AuditLogDetails{"user_id": userID})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Plan assigned",
+		"user_id": userID,
+		"plan":    plan,
+	})
+}
+
+// MigrateUsers moves every user on another plan, or a list of users, onto a plan (admin only)
+// POST /api/v1/admin/plans/:id/migrate
+func (h *PlanHandler) MigrateUsers(c *gin.Context) {
+	planID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	var req services.MigratePlanRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	plan, migrated, err := h.planService.MigrateUsers(planID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+		case errors.Is(err, services.ErrInvalidPlan):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to migrate users"})
+		}
+		return
+	}
+
+	details := models.AuditLogDetails{"migrated_users": migrated}
+	if req.FromPlanID != nil {
+		details["from_plan_id"] = *req.FromPlanID
+	}
+	if len(req.UserIDs) > 0 {
+		details["user_ids"] = req.UserIDs
+	}
+	h.logPlanChange(c, models.AuditActionMove, plan, details)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        fmt.Sprintf("Moved %d user(s) to plan %s", migrated, plan.Name),
+		"plan":           plan,
+		"migrated_users": migrated,
+	})
+}
+
+// logPlanChange records an admin change to a plan or its assignments
+func (h *PlanHandler) logPlanChange(c *gin.Context, action models.AuditLogAction, plan *models.Plan, details models.AuditLogDetails) {
+	adminID, exists := c.Get("user_id")
+	if !exists {
+		return
+	}
+
+	if details == nil {
+		details = models.AuditLogDetails{}
+	}
+	details["timestamp"] = time.Now().Unix()
+
+	var resourceName *string
+	if plan.Name != "" {
+		resourceName = &plan.Name
+	}
+
+	if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+		UserID:       adminID.(uuid.UUID),
+		Action:       action,
+		ResourceType: models.AuditResourcePlan,
+		ResourceID:   &plan.ID,
+		ResourceName: resourceName,
+		Details:      details,
+		Status:       models.AuditStatusSuccess,
+	}); err != nil {
+		fmt.Printf("Failed to log plan change: %v\n", err)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type PolicyHandler struct {
+	policyService *services.PolicyService
+	auditService  *services.AuditService
+}
+
+func NewPolicyHandler(policyService *services.PolicyService, auditService *services.AuditService) *PolicyHandler {
+	return &PolicyHandler{
+		policyService: policyService,
+		auditService:  auditService,
+	}
+}
+
+// AcceptPoliciesRequest lists the policy documents the user accepts
+type AcceptPoliciesRequest struct {
+	DocumentIDs []uuid.UUID `json:"document_ids" binding:"required,min=1"`
+}
+
+// GetCurrentPolicies returns the current policy versions and whether the user has accepted each
+// GET /api/v1/policies/current
+func (h *PolicyHandler) GetCurrentPolicies(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	statuses, err := h.policyService.PolicyStatusForUser(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch policies"})
+		return
+	}
+
+	allAccepted := true
+	for _, status := range statuses {
+		if !status.Accepted {
+			allAccepted = false
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policies":     statuses,
+		"all_accepted": allAccepted,
+	})
+}
+
+// AcceptPolicies records the user's acceptance of the given policy versions
+// POST /api/v1/policies/accept
+func (h *PolicyHandler) AcceptPolicies(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req AcceptPoliciesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	acceptances, err := h.policyService.AcceptPolicies(userID.(uuid.UUID), req.DocumentIDs, middleware.StoredClientIP(c), c.GetHeader("User-Agent"))
+	if err != nil {
+		if errors.Is(err, services.ErrPolicyNotCurrent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record policy acceptance"})
+		return
+	}
+
+	for _, acceptance := range acceptances {
+		documentID := acceptance.DocumentID
+		resourceName := fmt.Sprintf("%s %s", acceptance.Type, acceptance.Version)
+		if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+			UserID:       userID.(uuid.UUID),
+			Action:       models.AuditActionAccept,
+			ResourceType: models.AuditResourcePolicy,
+			ResourceID:   &documentID,
+			ResourceName: &resourceName,
+			Status:       models.AuditStatusSuccess,
+		}); err != nil {
+			fmt.Printf("Failed to log policy acceptance: %v\n", err)
+		}
+	}
+
+	pending, err := h.policyService.PendingPolicies(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Policies accepted",
+		"accepted":         acceptances,
+		"pending_policies": pending,
+		"all_accepted":     len(pending) == 0,
+	})
+}
+
+// GetMyAcceptances returns every policy version the user has accepted
+// GET /api/v1/policies/acceptances
+func (h *PolicyHandler) GetMyAcceptances(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	acceptances, err := h.policyService.UserAcceptances(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch policy acceptances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"acceptances": acceptances})
+}
+
+// ListPolicies returns every published policy version with acceptance counts (admin only)
+// GET /api/v1/admin/policies
+func (h *PolicyHandler) ListPolicies(c *gin.Context) {
+	policies, err := h.policyService.ListPolicies(c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// PublishPolicy publishes a new policy version that users must accept (admin only)
+// POST /api/v1/admin/policies
+func (h *PolicyHandler) PublishPolicy(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req services.PolicyDocumentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	doc, err := h.policyService.PublishPolicy(req, userID.(uuid.UUID))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPolicyDocument):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPolicyVersionExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish policy"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, doc)
+}
+
+// GetUserAcceptances returns the policy versions a user has accepted (admin only)
+// GET /api/v1/admin/users/:id/policy-acceptances
+func (h *PolicyHandler) GetUserAcceptances(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	acceptances, err := h.policyService.UserAcceptances(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch policy acceptances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"acceptances": acceptances})
+}
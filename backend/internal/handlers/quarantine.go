@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type QuarantineHandler struct {
+	quarantineService *services.QuarantineService
+}
+
+func NewQuarantineHandler(quarantineService *services.QuarantineService) *QuarantineHandler {
+	return &QuarantineHandler{
+		quarantineService: quarantineService,
+	}
+}
+
+// ListQuarantine returns the quarantine review queue (admin only)
+// GET /api/v1/admin/quarantine
+func (h *QuarantineHandler) ListQuarantine(c *gin.Context) {
+	pageNum := 1
+	limitNum := 20
+
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil && p > 0 {
+			pageNum = p
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil && l > 0 && l <= 100 {
+			limitNum = l
+		}
+	}
+
+	// Default to the entries still awaiting review; status=all shows everything
+	status := models.QuarantineStatusPending
+	switch s := c.Query("status"); s {
+	case "":
+	case "all":
+		status = ""
+	case string(models.QuarantineStatusPending), string(models.QuarantineStatusReleased),
+		string(models.QuarantineStatusRejected), string(models.QuarantineStatusDeleted):
+		status = models.QuarantineStatus(s)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+		return
+	}
+
+	entries, total, err := h.quarantineService.ListQuarantine(status, limitNum, (pageNum-1)*limitNum)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quarantine queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": NewQuarantineDTOs(entries),
+		"total":   total,
+		"page":    pageNum,
+		"limit":   limitNum,
+	})
+}
+
+// ReleaseQuarantine clears a quarantined file so its owner can use it again (admin only)
+// POST /api/v1/admin/quarantine/:id/release
+func (h *QuarantineHandler) ReleaseQuarantine(c *gin.Context) {
+	h.review(c, h.quarantineService.Release)
+}
+
+// RejectQuarantine keeps a quarantined file locked (admin only)
+// POST /api/v1/admin/quarantine/:id/reject
+func (h *QuarantineHandler) RejectQuarantine(c *gin.Context) {
+	h.review(c, h.quarantineService.Reject)
+}
+
+// DeleteQuarantine deletes a quarantined file on behalf of its owner (admin only)
+// POST /api/v1/admin/quarantine/:id/delete
+func (h *QuarantineHandler) DeleteQuarantine(c *gin.Context) {
+	h.review(c, h.quarantineService.Delete)
+}
+
+func (h *QuarantineHandler) review(c *gin.Context, decide func(id uuid.UUID, review services.QuarantineReview) (*models.FileQuarantine, error)) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quarantine ID"})
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	entry, err := decide(entryID, services.QuarantineReview{
+		ReviewerID: userID.(uuid.UUID),
+		Note:       req.Note,
+		IPAddress:  middleware.StoredClientIP(c),
+		UserAgent:  c.GetHeader("User-Agent"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrQuarantineNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quarantine entry not found"})
+		case errors.Is(err, services.ErrQuarantineReviewed):
+			c.JSON(http.StatusConflict, gin.H{"error": "Quarantine entry has already been reviewed"})
+		case errors.Is(err, services.ErrQuarantineRetained):
+			c.JSON(http.StatusForbidden, gin.H{"error": "File is under WORM retention and cannot be deleted"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review quarantine entry"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, NewQuarantineDTO(entry))
+}
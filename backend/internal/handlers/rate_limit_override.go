@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type RateLimitOverrideHandler struct {
+	overrideService *services.RateLimitOverrideService
+	auditService    *services.AuditService
+}
+
+func NewRateLimitOverrideHandler(overrideService *services.RateLimitOverrideService, auditService *services.AuditService) *RateLimitOverrideHandler {
+	return &RateLimitOverrideHandler{
+		overrideService: overrideService,
+		auditService:    auditService,
+	}
+}
+
+// ListOverrides returns every per-user rate limit override (admin only)
+// GET /api/v1/admin/rate-limit-overrides
+func (h *RateLimitOverrideHandler) ListOverrides(c *gin.Context) {
+	overrides, err := h.overrideService.ListOverrides()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rate limit overrides"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"overrides": overrides})
+}
+
+// SetOverride exempts a user from rate limiting, scales their limits or
+// replaces them (admin only)
+// PUT /api/v1/admin/users/:id/rate-limit-override
+func (h *RateLimitOverrideHandler) SetOverride(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req services.RateLimitOverrideRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	override, err := h.overrideService.SetOverride(userID, c.MustGet("user_id").(uuid.UUID), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRateLimitOverride):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrRateLimitUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rate limit override"})
+		}
+		return
+	}
+
+	h.logOverrideChange(c, models.AuditActionUpdate, userID, models.AuditLogDetails{
+		"exempt":           override.Exempt,
+		"multiplier":       override.Multiplier,
+		"rate_limit":       override.RateLimit,
+		"rate_limit_burst": override.RateLimitBurst,
+		"reason":           override.Reason,
+		"expires_at":       override.ExpiresAt,
+	})
+	c.JSON(http.StatusOK, override)
+}
+
+// DeleteOverride returns a user to the normal rate limits (admin only)
+// DELETE /api/v1/admin/users/:id/rate-limit-override
+func (h *RateLimitOverrideHandler) DeleteOverride(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.overrideService.DeleteOverride(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rate limit override not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rate limit override"})
+		return
+	}
+
+	h.logOverrideChange(c, models.AuditActionDelete, userID, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Rate limit override removed"})
+}
+
+// logOverrideChange records an admin change to a user's rate limit override
+func (h *RateLimitOverrideHandler) logOverrideChange(c *gin.Context, action models.AuditLogAction, userID uuid.UUID, details models.AuditLogDetails) {
+	adminID, exists := c.Get("user_id")
+	if !exists {
+		return
+	}
+
+	if details == nil {
+		details = models.AuditLogDetails{}
+	}
+	details["timestamp"] = time.Now().Unix()
+
+	if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+		UserID:       adminID.(uuid.UUID),
+		Action:       action,
+		ResourceType: models.AuditResourceRateLimit,
+		ResourceID:   &userID,
+		Details:      details,
+		Status:       models.AuditStatusSuccess,
+	}); err != nil {
+		fmt.Printf("Failed to log rate limit override change: %v\n", err)
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"file-vault-system/backend/internal/services"
+)
+
+type ReplicationHandler struct {
+	replicationService *services.ReplicationService
+}
+
+func NewReplicationHandler(replicationService *services.ReplicationService) *ReplicationHandler {
+	return &ReplicationHandler{
+		replicationService: replicationService,
+	}
+}
+
+// GetReplicationStatus reports blob replication progress and lag (admin only)
+// GET /api/v1/admin/storage/replication
+func (h *ReplicationHandler) GetReplicationStatus(c *gin.Context) {
+	report, err := h.replicationService.GetStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch replication status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// SAMLMetadata serves the service provider metadata to register with the
+// identity provider
+// GET /api/v1/auth/saml/metadata
+func (h *AuthHandler) SAMLMetadata(c *gin.Context) {
+	metadata, err := h.samlService.Metadata()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build SAML metadata"})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SAMLLogin sends the user to the identity provider to sign in
+// GET /api/v1/auth/saml/login
+func (h *AuthHandler) SAMLLogin(c *gin.Context) {
+	redirect, err := h.samlService.LoginURL(c.Query("relay_state"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// SAMLACS receives the identity provider's response, signs the user in and
+// hands the token to the frontend, or returns it when no redirect is set
+// POST /api/v1/auth/saml/acs
+func (h *AuthHandler) SAMLACS(c *gin.Context) {
+	user, err := h.samlService.Authenticate(c.Request)
+	if err != nil {
+		h.loginFailed(c, "", nil, "sso_rejected", models.LoginMethodSAML)
+		switch {
+		case errors.Is(err, services.ErrSSOUnknownUser), errors.Is(err, services.ErrSSOAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed"})
+		}
+		return
+	}
+
+	services.MarkSignedIn(h.db, user, time.Now())
+
+	token, err := h.issueToken(c, user, services.DeviceInfo{}, models.LoginMethodSAML)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	if h.cfg.SAMLLoginRedirectURL != "" {
+		// The token travels in the fragment so it stays out of server logs
+		fragment := url.Values{"token": {token}}
+		if relayState := c.PostForm("RelayState"); relayState != "" {
+			fragment.Set("relay_state", relayState)
+		}
+		c.Redirect(http.StatusSeeOther, h.cfg.SAMLLoginRedirectURL+"#"+fragment.Encode())
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Token: token,
+		User:  NewUserDTO(user),
+	})
+}
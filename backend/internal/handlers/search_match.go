@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// Fields a search result can match on
+const (
+	searchMatchName        = "name"
+	searchMatchDescription = "description"
+	searchMatchContent     = "content"
+	searchMatchTag         = "tag"
+)
+
+// snippetContext is how many characters of text are kept on either side of
+// the first match in a name or description snippet
+const snippetContext = 60
+
+// searchMatches explains why a file matched a search: each field the query
+// or tag filter matched, with an HTML-escaped snippet in which the matched
+// text is wrapped in <mark>. contentSnippet is the file's excerpt from the
+// content index, or empty when its content did not match.
+func searchMatches(file *models.File, query string, tags []string, contentSnippet string) []SearchMatchDTO {
+	matches := []SearchMatchDTO{}
+	query = strings.TrimSpace(query)
+
+	if query != "" {
+		pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+		if snippet, ok := highlightSnippet(file.OriginalFilename, pattern); ok {
+			matches = append(matches, SearchMatchDTO{Field: searchMatchName, Snippet: snippet})
+		}
+		if snippet, ok := highlightSnippet(file.Description, pattern); ok {
+			matches = append(matches, SearchMatchDTO{Field: searchMatchDescription, Snippet: snippet})
+		}
+	}
+
+	if contentSnippet != "" {
+		snippet := html.EscapeString(contentSnippet)
+		snippet = strings.ReplaceAll(snippet, services.SnippetMatchStart, "<mark>")
+		snippet = strings.ReplaceAll(snippet, services.SnippetMatchEnd, "</mark>")
+		matches = append(matches, SearchMatchDTO{Field: searchMatchContent, Snippet: snippet})
+	}
+
+	// Tags match the tag filter exactly, as in the search query
+	for _, tag := range append(append([]string{}, file.Tags...), file.AutoTags...) {
+		for _, wanted := range tags {
+			if tag == strings.TrimSpace(wanted) {
+				matches = append(matches, SearchMatchDTO{Field: searchMatchTag, Snippet: "<mark>" + html.EscapeString(tag) + "</mark>"})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// highlightSnippet cuts the text around the first match of pattern and marks
+// every match in the cut. It reports false when the text does not match.
+func highlightSnippet(text string, pattern *regexp.Regexp) (string, bool) {
+	first := pattern.FindStringIndex(text)
+	if first == nil {
+		return "", false
+	}
+
+	start, end := first[0]-snippetContext, first[1]+snippetContext
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	// Move the cut off partial UTF-8 characters
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	excerpt := text[start:end]
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	last := 0
+	for _, m := range pattern.FindAllStringIndex(excerpt, -1) {
+		b.WriteString(html.EscapeString(excerpt[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(excerpt[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(excerpt[last:]))
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return b.String(), true
+}
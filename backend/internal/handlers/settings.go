@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type SettingsHandler struct {
+	db                    *gorm.DB
+	classificationService *services.ClassificationService
+	uploadRuleService     *services.UploadRuleService
+}
+
+func NewSettingsHandler(db *gorm.DB, classificationService *services.ClassificationService) *SettingsHandler {
+	return &SettingsHandler{
+		db:                    db,
+		classificationService: classificationService,
+		uploadRuleService:     services.NewUploadRuleService(db),
+	}
+}
+
+// SettingsDTO holds the preferences a user can change for their account
+type SettingsDTO struct {
+	AutoTaggingEnabled bool `json:"auto_tagging_enabled"`
+}
+
+// GetSettings returns the current user's preferences
+// GET /api/v1/me/settings
+func (h *SettingsHandler) GetSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).Select("id", "auto_tagging_enabled").First(&user, "id = ?", userID.(uuid.UUID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SettingsDTO{AutoTaggingEnabled: user.AutoTaggingEnabled})
+}
+
+// UpdateSettings changes the current user's preferences. Turning auto-tagging
+// off removes the tags the classifier already applied to their files
+// PUT /api/v1/me/settings
+func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		AutoTaggingEnabled *bool `json:"auto_tagging_enabled"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if req.AutoTaggingEnabled != nil {
+		if err := h.classificationService.SetAutoTagging(userID.(uuid.UUID), *req.AutoTaggingEnabled); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+			return
+		}
+	}
+
+	h.GetSettings(c)
+}
+
+// UploadRuleDTO is one upload rule and the folder it sends matching uploads to
+type UploadRuleDTO struct {
+	MimeType   string    `json:"mime_type,omitempty"`
+	Extension  string    `json:"extension,omitempty"`
+	FolderID   uuid.UUID `json:"folder_id"`
+	FolderPath string    `json:"folder_path,omitempty"`
+}
+
+// UploadRulesDTO is where a user's uploads that name no folder go
+type UploadRulesDTO struct {
+	DefaultFolderID   *uuid.UUID      `json:"default_folder_id"` // null for the root folder
+	DefaultFolderPath string          `json:"default_folder_path,omitempty"`
+	Rules             []UploadRuleDTO `json:"rules"`
+}
+
+// uploadRulesRequest replaces a user's default upload folder and rules
+type uploadRulesRequest struct {
+	DefaultFolderID *uuid.UUID `json:"default_folder_id"`
+	Rules           []struct {
+		MimeType  string    `json:"mime_type" binding:"max=100"`
+		Extension string    `json:"extension" binding:"max=20"`
+		FolderID  uuid.UUID `json:"folder_id" binding:"required"`
+	} `json:"rules" binding:"dive"`
+}
+
+// GetUploadRules returns the current user's default upload folder and
+// upload rules, in the order they are tried
+// GET /api/v1/me/upload-rules
+func (h *SettingsHandler) GetUploadRules(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	routing, err := h.uploadRuleService.Get(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondUploadRules(c, userID, routing)
+}
+
+// UpdateUploadRules replaces the current user's default upload folder and
+// upload rules. Uploads that name no folder go to the folder of the first
+// rule matching their detected type (mime_type, such as image/* or
+// application/pdf) and extension, else to the default folder, else to the
+// root folder. Rules cannot send uploads to encrypted folders
+// PUT /api/v1/me/upload-rules
+func (h *SettingsHandler) UpdateUploadRules(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req uploadRulesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rules := make([]models.UploadRule, len(req.Rules))
+	for i, rule := range req.Rules {
+		rules[i] = models.UploadRule{MimeType: rule.MimeType, Extension: rule.Extension, FolderID: rule.FolderID}
+	}
+	routing, err := h.uploadRuleService.Replace(userID, req.DefaultFolderID, rules)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondUploadRules(c, userID, routing)
+}
+
+// respondUploadRules writes a user's upload routing with the paths of its
+// folders
+func (h *SettingsHandler) respondUploadRules(c *gin.Context, userID uuid.UUID, routing *services.UploadRouting) {
+	ids := make([]uuid.UUID, 0, len(routing.Rules)+1)
+	if routing.DefaultFolderID != nil {
+		ids = append(ids, *routing.DefaultFolderID)
+	}
+	for _, rule := range routing.Rules {
+		ids = append(ids, rule.FolderID)
+	}
+	paths := make(map[uuid.UUID]string, len(ids))
+	if len(ids) > 0 {
+		var folders []models.Folder
+		if err := h.db.WithContext(c.Request.Context()).Select("id", "path").
+			Where("id IN ? AND owner_id = ?", ids, userID).Find(&folders).Error; err != nil {
+			c.Error(err)
+			return
+		}
+		for _, folder := range folders {
+			paths[folder.ID] = folder.Path
+		}
+	}
+
+	dto := UploadRulesDTO{DefaultFolderID: routing.DefaultFolderID, Rules: make([]UploadRuleDTO, len(routing.Rules))}
+	if routing.DefaultFolderID != nil {
+		dto.DefaultFolderPath = paths[*routing.DefaultFolderID]
+	}
+	for i, rule := range routing.Rules {
+		dto.Rules[i] = UploadRuleDTO{
+			MimeType:   rule.MimeType,
+			Extension:  rule.Extension,
+			FolderID:   rule.FolderID,
+			FolderPath: paths[rule.FolderID],
+		}
+	}
+	c.JSON(http.StatusOK, dto)
+}
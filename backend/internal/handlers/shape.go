@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseShape is what a listing request asked to receive: the item fields
+// from ?fields= and the relations from ?include=. Relations are only loaded
+// when included, so clients that need a few columns avoid the preloads.
+type responseShape struct {
+	fields   map[string]bool // nil when every field is wanted
+	includes map[string]bool
+}
+
+// parseResponseShape reads ?fields= and ?include= for a listing of items of
+// type item. Without ?include= the relations in defaultIncludes are loaded,
+// which keeps existing clients working; ?include= with no value loads none.
+// It returns an error message for bad parameters.
+func parseResponseShape(c *gin.Context, item interface{}, relations, defaultIncludes []string) (responseShape, string) {
+	known := jsonFieldNames(reflect.TypeOf(item))
+	shape := responseShape{includes: map[string]bool{}}
+
+	includeParam, hasInclude := c.GetQuery("include")
+	includes := defaultIncludes
+	if hasInclude {
+		includes = splitList(includeParam)
+	}
+	for _, relation := range includes {
+		if !containsString(relations, relation) {
+			return responseShape{}, fmt.Sprintf("Unknown include: %s", relation)
+		}
+		shape.includes[relation] = true
+	}
+
+	if fieldsParam := c.Query("fields"); fieldsParam != "" {
+		shape.fields = map[string]bool{"id": true}
+		for _, field := range splitList(fieldsParam) {
+			if containsString(relations, field) {
+				return responseShape{}, fmt.Sprintf("%s is a relation; request it with include=%s", field, field)
+			}
+			if !known[field] {
+				return responseShape{}, fmt.Sprintf("Unknown field: %s", field)
+			}
+			shape.fields[field] = true
+		}
+	}
+	return shape, ""
+}
+
+// include reports whether a relation should be loaded and returned
+func (s responseShape) include(relation string) bool {
+	return s.includes[relation]
+}
+
+// wants reports whether a field is returned, so work that only fills that
+// field can be skipped
+func (s responseShape) wants(field string) bool {
+	return s.fields == nil || s.fields[field]
+}
+
+// apply trims each item of a slice to the requested fields and relations.
+// Items are returned unchanged when no fields were selected.
+func (s responseShape) apply(items interface{}) (interface{}, error) {
+	if s.fields == nil {
+		return items, nil
+	}
+
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for key := range row {
+			if !s.fields[key] && !s.includes[key] {
+				delete(row, key)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// jsonFieldNames lists the JSON names of a struct's fields, including those
+// of embedded structs
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name := range jsonFieldNames(field.Type) {
+				names[name] = true
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// splitList splits a comma-separated query value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// containsString reports whether value is one of values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
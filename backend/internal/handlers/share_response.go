@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// shareAccessCookie holds the access token of an unlocked share link. Its
+// path limits it to that link's URLs
+const shareAccessCookie = "share_access"
+
+// shareAccessHeader carries the access token for clients that keep no cookies
+const shareAccessHeader = "X-Share-Access"
+
+// shareUnlockRequest carries a share link password in the request body,
+// which proxies and access logs do not record
+type shareUnlockRequest struct {
+	Password string `json:"password"`
+}
+
+// shareLinkCredentials reads what a request to the share link at linkPath
+// opens it with: the access token from unlocking it, or the deprecated
+// ?password=, which is answered with headers pointing at the unlock endpoint
+func shareLinkCredentials(c *gin.Context, linkPath string) services.ShareLinkCredentials {
+	creds := services.ShareLinkCredentials{AccessToken: c.GetHeader(shareAccessHeader)}
+	if creds.AccessToken == "" {
+		creds.AccessToken, _ = c.Cookie(shareAccessCookie)
+	}
+	if password := c.Query("password"); password != "" {
+		creds.Password = password
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+linkPath+"/unlock>; rel=\"successor-version\"")
+	}
+	return creds
+}
+
+// respondShareUnlocked hands out the access token of the share link at
+// linkPath, as a cookie for browsers and in the body for other clients
+func respondShareUnlocked(c *gin.Context, linkPath string, access *services.ShareAccess) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     shareAccessCookie,
+		Value:    access.Token,
+		Path:     linkPath,
+		Expires:  access.ExpiresAt,
+		MaxAge:   int(time.Until(access.ExpiresAt).Seconds()),
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	c.Header("Cache-Control", "no-store")
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": access.Token,
+		"expires_at":   access.ExpiresAt,
+	})
+}
+
+// shareListFilter reads the status and include_hidden parameters of a
+// shared-with-me listing. It returns an error message for bad parameters.
+func shareListFilter(c *gin.Context) (services.ShareListFilter, string) {
+	filter := services.ShareListFilter{IncludeHidden: c.Query("include_hidden") == "true"}
+	if status := c.Query("status"); status != "" {
+		filter.Status = models.ShareStatus(status)
+		valid := false
+		for _, known := range models.ShareStatuses {
+			valid = valid || known == filter.Status
+		}
+		if !valid {
+			return services.ShareListFilter{}, "Invalid status parameter"
+		}
+	}
+	return filter, ""
+}
+
+// applyShareAction performs the accept, decline, hide or unhide action named
+// in the path on one of the recipient's shares
+func applyShareAction(c *gin.Context, respond func(shareID, recipientID uuid.UUID, status models.ShareStatus) error, setHidden func(shareID, recipientID uuid.UUID, hidden bool) error) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	shareID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share ID"})
+		return
+	}
+
+	recipientID := userID.(uuid.UUID)
+	action := c.Param("action")
+	switch action {
+	case "accept":
+		err = respond(shareID, recipientID, models.ShareStatusAccepted)
+	case "decline":
+		err = respond(shareID, recipientID, models.ShareStatusDeclined)
+	case "hide":
+		err = setHidden(shareID, recipientID, true)
+	case "unhide":
+		err = setHidden(shareID, recipientID, false)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown share action"})
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, services.ErrShareNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update share"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Share updated",
+		"share_id": shareID,
+		"action":   action,
+	})
+}
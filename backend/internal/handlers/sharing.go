@@ -1,23 +1,42 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"file-vault-system/backend/internal/middleware"
 	"file-vault-system/backend/internal/models"
 	"file-vault-system/backend/internal/services"
+	"file-vault-system/backend/pkg/preview"
 )
 
 type SharingHandler struct {
-	sharingService *services.SharingService
+	sharingService    *services.SharingService
+	guestService      *services.GuestService
+	auditService      *services.AuditService
+	fileStreamService *services.FileStreamService
+	prewarmService    *services.PrewarmService
+	linkService       *services.LinkService
+	shareNotifier     *services.ShareNotifier
+	integrations      *services.IntegrationService
 }
 
-func NewSharingHandler(sharingService *services.SharingService) *SharingHandler {
+func NewSharingHandler(sharingService *services.SharingService, guestService *services.GuestService, auditService *services.AuditService, fileStreamService *services.FileStreamService, prewarmService *services.PrewarmService, linkService *services.LinkService, shareNotifier *services.ShareNotifier, integrations *services.IntegrationService) *SharingHandler {
 	return &SharingHandler{
-		sharingService: sharingService,
+		sharingService:    sharingService,
+		guestService:      guestService,
+		auditService:      auditService,
+		fileStreamService: fileStreamService,
+		prewarmService:    prewarmService,
+		linkService:       linkService,
+		shareNotifier:     shareNotifier,
+		integrations:      integrations,
 	}
 }
 
@@ -48,10 +67,11 @@ func (h *SharingHandler) ShareFileWithUser(c *gin.Context) {
 		Message    string  `json:"message"`
 		ExpiresAt  *string `json:"expires_at"`
 		Permission string  `json:"permission"`
+		AsGuest    bool    `json:"create_guest"` // create a guest account when the email is unknown
+		GuestDays  int     `json:"guest_days"`   // guest account lifetime, defaults to GUEST_ACCOUNT_DAYS
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -72,6 +92,19 @@ func (h *SharingHandler) ShareFileWithUser(c *gin.Context) {
 		permission = models.PermissionDownload
 	}
 
+	var guest *services.GuestCredentials
+	if req.AsGuest {
+		// Only the owner of a file that can be shared may invite a guest to it
+		if err := h.sharingService.CheckSharable(fileID, sharedBy); err != nil {
+			c.Error(err)
+			return
+		}
+		guest, err = inviteGuest(c, h.guestService, req.Email, sharedBy, req.GuestDays)
+		if err != nil {
+			return
+		}
+	}
+
 	shareReq := services.ShareFileRequest{
 		FileID:     fileID,
 		SharedBy:   sharedBy,
@@ -83,14 +116,34 @@ func (h *SharingHandler) ShareFileWithUser(c *gin.Context) {
 
 	fileShare, err := h.sharingService.ShareFileWithUser(shareReq)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
+	h.shareNotifier.FileShared(fileShare)
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message": "File shared successfully",
-		"share":   fileShare,
-	})
+		"share":   NewShareDTO(fileShare),
+	}
+	if guest != nil {
+		response["guest"] = guest
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// inviteGuest creates or extends a guest account for a share recipient,
+// writing the error response itself when it fails
+func inviteGuest(c *gin.Context, guestService *services.GuestService, email string, invitedBy uuid.UUID, days int) (*services.GuestCredentials, error) {
+	guest, err := guestService.Invite(email, invitedBy, days)
+	if err != nil {
+		if errors.Is(err, services.ErrGuestLifetime) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create guest account"})
+		}
+		return nil, err
+	}
+	return guest, nil
 }
 
 // CreateShareLink creates a shareable link for a file
@@ -116,14 +169,16 @@ func (h *SharingHandler) CreateShareLink(c *gin.Context) {
 	}
 
 	var req struct {
-		Password     string  `json:"password"`
-		MaxDownloads *int    `json:"max_downloads"`
-		ExpiresAt    *string `json:"expires_at"`
-		Permission   string  `json:"permission"`
+		Password         string  `json:"password"`
+		MaxDownloads     *int    `json:"max_downloads"`
+		ExpiresAt        *string `json:"expires_at"`
+		Permission       string  `json:"permission"`
+		NotifyOnDownload bool    `json:"notify_on_download"`
+		Watermark        bool    `json:"watermark"`
+		WatermarkText    string  `json:"watermark_text" binding:"max=100"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -145,24 +200,30 @@ func (h *SharingHandler) CreateShareLink(c *gin.Context) {
 	}
 
 	shareReq := services.CreateShareLinkRequest{
-		FileID:       fileID,
-		CreatedBy:    createdBy,
-		Password:     req.Password,
-		MaxDownloads: req.MaxDownloads,
-		ExpiresAt:    expiresAt,
-		Permission:   permission,
+		FileID:           fileID,
+		CreatedBy:        createdBy,
+		Password:         req.Password,
+		MaxDownloads:     req.MaxDownloads,
+		ExpiresAt:        expiresAt,
+		Permission:       permission,
+		NotifyOnDownload: req.NotifyOnDownload,
+		Watermark:        req.Watermark,
+		WatermarkText:    strings.TrimSpace(req.WatermarkText),
 	}
 
 	shareLink, err := h.sharingService.CreateShareLink(shareReq)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	// Get the file ready for its first visitor
+	h.prewarmService.WarmShareLink(shareLink)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message":    "Share link created successfully",
-		"share_link": shareLink,
-		"url":        "/share/" + shareLink.ShareToken,
+		"share_link": NewShareLinkDTO(shareLink),
+		"url":        h.linkService.ShareLinkURL(shareLink.CreatedBy, shareLink.ShareToken),
 	})
 }
 
@@ -181,17 +242,30 @@ func (h *SharingHandler) GetSharedFiles(c *gin.Context) {
 		return
 	}
 
-	fileShares, err := h.sharingService.GetSharedFiles(userUUID)
+	filter, message := shareListFilter(c)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	fileShares, err := h.sharingService.GetSharedFiles(userUUID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"shared_files": fileShares,
+		"shared_files": NewShareDTOs(fileShares),
 	})
 }
 
+// RespondToSharedFile accepts, declines, hides or unhides a file share
+// made to the current user
+// POST /api/shared-files/:id/accept|decline|hide|unhide
+func (h *SharingHandler) RespondToSharedFile(c *gin.Context) {
+	applyShareAction(c, h.sharingService.RespondToFileShare, h.sharingService.SetFileShareHidden)
+}
+
 // GetFileShares returns all shares for a specific file
 // GET /api/files/:id/shares
 func (h *SharingHandler) GetFileShares(c *gin.Context) {
@@ -216,12 +290,12 @@ func (h *SharingHandler) GetFileShares(c *gin.Context) {
 
 	fileShares, err := h.sharingService.GetFileShares(fileID, ownerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"shares": fileShares,
+		"shares": NewShareDTOs(fileShares),
 	})
 }
 
@@ -242,53 +316,178 @@ func (h *SharingHandler) GetShareLinks(c *gin.Context) {
 
 	shareLinks, err := h.sharingService.GetShareLinks(userUUID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"share_links": shareLinks,
+		"share_links": NewShareLinkDTOs(shareLinks),
 	})
 }
 
+// UnlockSharedFile checks the password of a share link, sent in the body
+// rather than the URL, and issues a short-lived access token that opens
+// only this link
+// POST /share/:token/unlock
+func (h *SharingHandler) UnlockSharedFile(c *gin.Context) {
+	var req shareUnlockRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	access, err := h.sharingService.UnlockShareLink(c.Param("token"), req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondShareUnlocked(c, "/share/"+c.Param("token"), access)
+}
+
 // AccessSharedFile handles access to files via share links
 // GET /share/:token
 func (h *SharingHandler) AccessSharedFile(c *gin.Context) {
 	token := c.Param("token")
-	password := c.Query("password")
 
-	shareLink, err := h.sharingService.ValidateShareLink(token, password)
+	shareLink, err := h.sharingService.ValidateShareLink(token, shareLinkCredentials(c, "/share/"+token))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	// Record access
-	ipAddress := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
+	ipAddress := middleware.StoredClientIP(c)
+	userAgent := middleware.StoredUserAgent(c)
 	h.sharingService.RecordShareLinkAccess(shareLink, ipAddress, userAgent, "view")
+	h.auditService.LogFileAccess(c, models.AuditActionView, &shareLink.File, "share_link")
+	h.integrations.LinkAccessed(shareLink.CreatedBy, "file", shareLink.File.OriginalFilename, "view", ipAddress, c.GetString("client_country"),
+		h.linkService.ShareLinkURL(shareLink.CreatedBy, shareLink.ShareToken))
 
 	c.JSON(http.StatusOK, gin.H{
-		"file":       shareLink.File,
+		"file":       NewFileDTO(&shareLink.File),
 		"permission": shareLink.Permission,
+		"preview":    sharePreviewMode(shareLink),
 		"share_info": gin.H{
 			"created_at":     shareLink.CreatedAt,
 			"expires_at":     shareLink.ExpiresAt,
 			"download_count": shareLink.DownloadCount,
 			"max_downloads":  shareLink.MaxDownloads,
+			"watermark":      shareLink.Watermark,
 		},
 	})
 }
 
+// sharePreviewMode tells clients how a link's file can be shown: "inline"
+// from /preview for download links, "pages" rendered from /pages/:n for
+// view-only links, or "none" for view-only links to files that cannot be
+// rendered
+func sharePreviewMode(shareLink *models.ShareLink) string {
+	switch {
+	case shareLink.Permission == models.PermissionDownload:
+		return "inline"
+	case preview.Supports(shareLink.File.MimeType):
+		return "pages"
+	}
+	return "none"
+}
+
+// PreviewSharedFile shows the file of a share link in the browser. View-only
+// links show it rendered as an image, its first page for PDFs, so the
+// original cannot be saved from them. Links with watermarking on serve a
+// copy stamped with the viewer.
+// GET /share/:token/preview
+func (h *SharingHandler) PreviewSharedFile(c *gin.Context) {
+	shareLink, stream, ok := h.openSharedFile(c)
+	if !ok {
+		return
+	}
+
+	viewOnly := shareLink.Permission != models.PermissionDownload
+	if viewOnly && !preview.Supports(shareLink.File.MimeType) {
+		c.Error(services.ErrPreviewUnsupported)
+		return
+	}
+
+	h.recordSharedView(c, shareLink)
+	switch {
+	case viewOnly:
+		h.fileStreamService.ServeRendered(c, stream, 1, watermarkLines(c, shareLink))
+	case shareLink.Watermark:
+		h.fileStreamService.ServeWatermarked(c, stream, services.StreamInline, watermarkLines(c, shareLink))
+	default:
+		h.fileStreamService.Serve(c, stream, services.StreamInline)
+	}
+}
+
+// ViewSharedPage shows one page of the file of a share link rendered as an
+// image, counted from 1; images have a single page. Pages past the last are
+// 404. Only opening the first page is recorded as a view of the link.
+// GET /share/:token/pages/:n
+func (h *SharingHandler) ViewSharedPage(c *gin.Context) {
+	page, err := strconv.Atoi(c.Param("n"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
+		return
+	}
+
+	shareLink, stream, ok := h.openSharedFile(c)
+	if !ok {
+		return
+	}
+	if !preview.Supports(shareLink.File.MimeType) {
+		c.Error(services.ErrPreviewUnsupported)
+		return
+	}
+
+	if page == 1 {
+		h.recordSharedView(c, shareLink)
+	}
+	h.fileStreamService.ServeRendered(c, stream, page, watermarkLines(c, shareLink))
+}
+
+// openSharedFile validates the share link of the request and locates its
+// file's content, answering the request when either fails
+func (h *SharingHandler) openSharedFile(c *gin.Context) (*models.ShareLink, *services.FileStream, bool) {
+	token := c.Param("token")
+
+	shareLink, err := h.sharingService.ValidateShareLink(token, shareLinkCredentials(c, "/share/"+token))
+	if err != nil {
+		c.Error(err)
+		return nil, nil, false
+	}
+
+	if respondIfQuarantined(c, &shareLink.File) {
+		return nil, nil, false
+	}
+	if respondIfUnscanned(c, h.fileStreamService, &shareLink.File) {
+		return nil, nil, false
+	}
+
+	stream, err := h.fileStreamService.Open(&shareLink.File)
+	if err != nil {
+		respondStreamError(c, err)
+		return nil, nil, false
+	}
+	return shareLink, stream, true
+}
+
+// recordSharedView logs a view of the file through its share link
+func (h *SharingHandler) recordSharedView(c *gin.Context, shareLink *models.ShareLink) {
+	ipAddress := middleware.StoredClientIP(c)
+	h.sharingService.RecordShareLinkAccess(shareLink, ipAddress, middleware.StoredUserAgent(c), "view")
+	h.auditService.LogFileAccess(c, models.AuditActionView, &shareLink.File, "share_link")
+	h.integrations.LinkAccessed(shareLink.CreatedBy, "file", shareLink.File.OriginalFilename, "view", ipAddress, c.GetString("client_country"),
+		h.linkService.ShareLinkURL(shareLink.CreatedBy, shareLink.ShareToken))
+}
+
 // DownloadSharedFile handles downloading files via share links
 // GET /share/:token/download
 func (h *SharingHandler) DownloadSharedFile(c *gin.Context) {
 	token := c.Param("token")
-	password := c.Query("password")
 
-	shareLink, err := h.sharingService.ValidateShareLink(token, password)
+	shareLink, err := h.sharingService.ValidateShareLink(token, shareLinkCredentials(c, "/share/"+token))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -299,20 +498,57 @@ func (h *SharingHandler) DownloadSharedFile(c *gin.Context) {
 	}
 
 	// Record download
-	ipAddress := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
+	ipAddress := middleware.StoredClientIP(c)
+	userAgent := middleware.StoredUserAgent(c)
 	h.sharingService.RecordShareLinkAccess(shareLink, ipAddress, userAgent, "download")
 
-	// Get file path from FileHash
-	if shareLink.File.FileHash == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "File not found"})
+	if respondIfQuarantined(c, &shareLink.File) {
+		return
+	}
+	if respondIfUnscanned(c, h.fileStreamService, &shareLink.File) {
 		return
 	}
 
-	filePath := shareLink.File.FileHash.StoragePath
-	c.Header("Content-Disposition", "attachment; filename=\""+shareLink.File.OriginalFilename+"\"")
-	c.Header("Content-Type", shareLink.File.MimeType)
-	c.File(filePath)
+	stream, err := h.fileStreamService.Open(&shareLink.File)
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
+
+	h.auditService.LogFileAccess(c, models.AuditActionDownload, &shareLink.File, "share_link")
+	publishDownload(c, &shareLink.File, shareLink)
+	h.integrations.LinkAccessed(shareLink.CreatedBy, "file", shareLink.File.OriginalFilename, "download", ipAddress, c.GetString("client_country"),
+		h.linkService.ShareLinkURL(shareLink.CreatedBy, shareLink.ShareToken))
+
+	// Downloads are stamped too, or the original would leak through them
+	if shareLink.Watermark {
+		h.fileStreamService.ServeWatermarked(c, stream, services.StreamAttachment, watermarkLines(c, shareLink))
+		return
+	}
+	h.fileStreamService.Serve(c, stream, services.StreamAttachment)
+}
+
+// watermarkLines returns what content opened through a watermarked link is
+// stamped with: the email of a signed-in viewer, their IP address and the
+// time, then the link's own text. The IP address is anonymized when IP
+// anonymization is on. Links without watermarking get no lines.
+func watermarkLines(c *gin.Context, link *models.ShareLink) []string {
+	if !link.Watermark {
+		return nil
+	}
+
+	viewer := "anonymous"
+	if token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); token != "" {
+		if claims, err := middleware.ValidateJWTToken(token); err == nil && models.SameTenant(claims.TenantID, middleware.TenantID(c)) {
+			viewer = claims.Email
+		}
+	}
+
+	lines := []string{viewer, middleware.AnonymizeIP(c.ClientIP()), time.Now().UTC().Format("2006-01-02 15:04 UTC")}
+	if link.WatermarkText != "" {
+		lines = append(lines, link.WatermarkText)
+	}
+	return lines
 }
 
 // RevokeFileShare revokes a file share
@@ -339,7 +575,7 @@ func (h *SharingHandler) RevokeFileShare(c *gin.Context) {
 
 	err = h.sharingService.RevokeFileShare(shareID, ownerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -372,7 +608,7 @@ func (h *SharingHandler) RevokeShareLink(c *gin.Context) {
 
 	err = h.sharingService.RevokeShareLink(linkID, ownerID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -380,3 +616,44 @@ func (h *SharingHandler) RevokeShareLink(c *gin.Context) {
 		"message": "Share link revoked successfully",
 	})
 }
+
+// SetShareLinkNotifications turns notifications about downloads through a
+// share link on or off
+// PUT /api/share-links/:id/download-notifications
+func (h *SharingHandler) SetShareLinkNotifications(c *gin.Context) {
+	linkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	ownerID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.sharingService.SetShareLinkNotifications(linkID, ownerID, *req.Enabled); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Download notifications updated",
+		"share_link_id":      linkID,
+		"notify_on_download": *req.Enabled,
+	})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type SigningKeyHandler struct {
+	signingKeyService *services.SigningKeyService
+	auditService      *services.AuditService
+}
+
+func NewSigningKeyHandler(signingKeyService *services.SigningKeyService, auditService *services.AuditService) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		signingKeyService: signingKeyService,
+		auditService:      auditService,
+	}
+}
+
+// signingKeyView is a signing key as listed to admins
+type signingKeyView struct {
+	models.SigningKey
+	Current bool `json:"current"`
+	Valid   bool `json:"valid"` // tokens signed with it are accepted
+}
+
+// ListSigningKeys returns the login token signing keys, newest first, without
+// the keys themselves (admin only)
+// GET /api/v1/admin/jwt-keys
+func (h *SigningKeyHandler) ListSigningKeys(c *gin.Context) {
+	keys, err := h.signingKeyService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch signing keys"})
+		return
+	}
+
+	now := time.Now()
+	views := make([]signingKeyView, len(keys))
+	for i := range keys {
+		views[i] = signingKeyView{SigningKey: keys[i], Current: keys[i].Current(), Valid: keys[i].Valid(now)}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"keys":        views,
+		"grace_hours": h.signingKeyService.Grace().Hours(),
+	})
+}
+
+// RotateSigningKeyRequest optionally overrides how long tokens signed with
+// the replaced keys keep working; 0 rejects them at once
+type RotateSigningKeyRequest struct {
+	GraceHours *int `json:"grace_hours" binding:"omitempty,min=0"`
+}
+
+// RotateSigningKey makes a new signing key current (admin only)
+// POST /api/v1/admin/jwt-keys/rotate
+func (h *SigningKeyHandler) RotateSigningKey(c *gin.Context) {
+	var req RotateSigningKeyRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+	grace := h.signingKeyService.Grace()
+	if req.GraceHours != nil {
+		grace = time.Duration(*req.GraceHours) * time.Hour
+	}
+
+	key, err := h.signingKeyService.Rotate(grace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate signing key"})
+		return
+	}
+
+	if adminID, exists := c.Get("user_id"); exists {
+		if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+			UserID:       adminID.(uuid.UUID),
+			Action:       models.AuditActionRotate,
+			ResourceType: models.AuditResourceSigningKey,
+			ResourceName: &key.ID,
+			Details:      models.AuditLogDetails{"grace_hours": grace.Hours(), "timestamp": time.Now().Unix()},
+			Status:       models.AuditStatusSuccess,
+		}); err != nil {
+			fmt.Printf("Failed to log signing key rotation: %v\n", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"key":                  signingKeyView{SigningKey: *key, Current: true, Valid: true},
+		"previous_valid_until": time.Now().Add(grace),
+	})
+}
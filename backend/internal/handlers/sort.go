@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	errInvalidSortBy    = errors.New("invalid sort_by parameter")
+	errInvalidSortOrder = errors.New("invalid sort_order parameter")
+)
+
+// maxSortTerms limits how many fields one sort_by may combine
+const maxSortTerms = 4
+
+// sortSpec describes how a listing may be sorted. Only the columns listed in
+// fields ever reach the ORDER BY clause; request values are used solely to
+// look them up.
+type sortSpec struct {
+	fields       map[string]string // sort_by name -> SQL column or expression
+	defaultSort  string            // sort_by used when the request has none
+	defaultOrder string            // "asc" or "desc" for fields without their own direction
+	tieBreak     string            // unique column appended last so pages never shuffle
+}
+
+// sortTerm is one validated field of a sort
+type sortTerm struct {
+	field string
+	desc  bool
+}
+
+// listSort is a validated sort, ready to pass to Order
+type listSort struct {
+	terms  []sortTerm
+	clause string
+}
+
+// parse validates sort_by and sort_order. sort_by is a comma-separated list
+// of field names, each optionally suffixed with ":asc" or ":desc", such as
+// "folder,name:desc"; sort_order applies to fields without a suffix.
+func (spec sortSpec) parse(sortBy, sortOrder string) (listSort, error) {
+	if strings.TrimSpace(sortBy) == "" {
+		sortBy = spec.defaultSort
+	}
+
+	order := strings.ToLower(strings.TrimSpace(sortOrder))
+	switch order {
+	case "":
+		order = spec.defaultOrder
+	case "asc", "desc":
+	default:
+		return listSort{}, errInvalidSortOrder
+	}
+
+	parts := strings.Split(sortBy, ",")
+	if len(parts) > maxSortTerms {
+		return listSort{}, errInvalidSortBy
+	}
+
+	sort := listSort{}
+	columns := make([]string, 0, len(parts)+1)
+	seen := make(map[string]bool, len(parts))
+	for _, part := range parts {
+		name, direction, hasDirection := strings.Cut(strings.TrimSpace(part), ":")
+		if !hasDirection {
+			direction = order
+		}
+		column, ok := spec.fields[name]
+		if !ok || seen[name] {
+			return listSort{}, errInvalidSortBy
+		}
+		seen[name] = true
+
+		term := sortTerm{field: name}
+		switch strings.ToLower(direction) {
+		case "asc":
+		case "desc":
+			term.desc = true
+		default:
+			return listSort{}, errInvalidSortOrder
+		}
+		sort.terms = append(sort.terms, term)
+
+		if term.desc {
+			columns = append(columns, column+" DESC NULLS LAST")
+		} else {
+			columns = append(columns, column+" ASC NULLS LAST")
+		}
+	}
+
+	if spec.tieBreak != "" {
+		columns = append(columns, spec.tieBreak+" ASC")
+	}
+	sort.clause = strings.Join(columns, ", ")
+	return sort, nil
+}
+
+// has reports whether the sort uses a field, for sorts that need a join
+func (s listSort) has(field string) bool {
+	for _, term := range s.terms {
+		if term.field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// sortErrorMessage is the response for a sort that failed to parse
+func sortErrorMessage(err error) string {
+	if errors.Is(err, errInvalidSortOrder) {
+		return "Invalid sort_order parameter"
+	}
+	return "Invalid sort_by parameter"
+}
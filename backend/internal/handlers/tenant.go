@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type TenantHandler struct {
+	tenantService *services.TenantService
+	auditService  *services.AuditService
+}
+
+func NewTenantHandler(tenantService *services.TenantService, auditService *services.AuditService) *TenantHandler {
+	return &TenantHandler{
+		tenantService: tenantService,
+		auditService:  auditService,
+	}
+}
+
+// TenantBrandingDTO is what clients need to present a tenant before sign-in
+type TenantBrandingDTO struct {
+	Slug              string `json:"slug"`
+	Name              string `json:"name"`
+	AllowRegistration bool   `json:"allow_registration"`
+	LogoURL           string `json:"logo_url,omitempty"`
+	PrimaryColor      string `json:"primary_color,omitempty"`
+	SupportEmail      string `json:"support_email,omitempty"`
+}
+
+// tenantSettingsRequest is what tenant admins may change about their tenant
+type tenantSettingsRequest struct {
+	Name              string `json:"name" binding:"required,max=255"`
+	AllowRegistration *bool  `json:"allow_registration"`
+	services.TenantSettingsRequest
+}
+
+// GetBranding returns the name and branding of the tenant the request is
+// for. Platform requests get no tenant
+// GET /api/v1/tenant
+func (h *TenantHandler) GetBranding(c *gin.Context) {
+	tenant := middleware.CurrentTenant(c)
+	if tenant == nil {
+		c.JSON(http.StatusOK, gin.H{"tenant": nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tenant": TenantBrandingDTO{
+		Slug:              tenant.Slug,
+		Name:              tenant.Name,
+		AllowRegistration: tenant.AllowRegistration,
+		LogoURL:           tenant.LogoURL,
+		PrimaryColor:      tenant.PrimaryColor,
+		SupportEmail:      tenant.SupportEmail,
+	}})
+}
+
+// ListTenants returns every tenant with its member count and usage (admin only)
+// GET /api/v1/admin/tenants
+func (h *TenantHandler) ListTenants(c *gin.Context) {
+	tenants, err := h.tenantService.ListTenants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tenants"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tenants": tenants})
+}
+
+// GetTenant returns a tenant with its member count and usage (admin only)
+// GET /api/v1/admin/tenants/:id
+func (h *TenantHandler) GetTenant(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+
+	tenant, err := h.tenantService.GetTenant(tenantID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tenant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// CreateTenant adds a tenant (admin only)
+// POST /api/v1/admin/tenants
+func (h *TenantHandler) CreateTenant(c *gin.Context) {
+	var req services.TenantRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tenant, err := h.tenantService.CreateTenant(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTenant):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTenantSlugTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Another tenant already uses this slug"})
+		case errors.Is(err, services.ErrTenantShareDomainTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Another tenant already uses this share domain"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tenant"})
+		}
+		return
+	}
+
+	h.logTenantChange(c, models.AuditActionCreate, tenant, nil)
+	c.JSON(http.StatusCreated, tenant)
+}
+
+// UpdateTenant replaces a tenant's slug, quotas, status and branding (admin only)
+// PUT /api/v1/admin/tenants/:id
+func (h *TenantHandler) UpdateTenant(c *gin.Context) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+
+	var req services.TenantRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tenant, err := h.tenantService.UpdateTenant(tenantID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		case errors.Is(err, services.ErrInvalidTenant):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTenantSlugTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Another tenant already uses this slug"})
+		case errors.Is(err, services.ErrTenantShareDomainTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Another tenant already uses this share domain"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tenant"})
+		}
+		return
+	}
+
+	h.logTenantChange(c, models.AuditActionUpdate, tenant, nil)
+	c.JSON(http.StatusOK, tenant)
+}
+
+// GetSettings returns the tenant with its member count and usage (tenant admin only)
+// GET /api/v1/tenant/settings
+func (h *TenantHandler) GetSettings(c *gin.Context) {
+	tenant, err := h.tenantService.GetTenant(*middleware.TenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tenant settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// UpdateSettings changes the tenant's name, registration and branding (tenant admin only)
+// PUT /api/v1/tenant/settings
+func (h *TenantHandler) UpdateSettings(c *gin.Context) {
+	var req tenantSettingsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tenant, err := h.tenantService.UpdateSettings(*middleware.TenantID(c), req.Name, req.AllowRegistration, req.TenantSettingsRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTenant) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tenant settings"})
+		return
+	}
+
+	h.logTenantChange(c, models.AuditActionUpdate, tenant, nil)
+	c.JSON(http.StatusOK, tenant)
+}
+
+// ListUsers returns a page of the tenant's members (tenant admin only)
+// GET /api/v1/tenant/users
+func (h *TenantHandler) ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	users, total, err := h.tenantService.ListUsers(*middleware.TenantID(c), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":      NewUserDTOs(users),
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + int64(limit) - 1) / int64(limit),
+	})
+}
+
+// UpdateUser changes a member's role or active status (tenant admin only)
+// PUT /api/v1/tenant/users/:id
+func (h *TenantHandler) UpdateUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req services.TenantUserUpdate
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	tenantID := *middleware.TenantID(c)
+	user, err := h.tenantService.UpdateUser(tenantID, c.MustGet("user_id").(uuid.UUID), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTenantUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, services.ErrInvalidTenantUser):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		}
+		return
+	}
+
+	details := models.AuditLogDetails{"user_id": user.ID}
+	if req.Role != nil {
+		details["role"] = *req.Role
+	}
+	if req.IsActive != nil {
+		details["is_active"] = *req.IsActive
+	}
+	h.logTenantChange(c, models.AuditActionUpdate, middleware.CurrentTenant(c), details)
+
+	c.JSON(http.StatusOK, NewUserDTO(user))
+}
+
+// logTenantChange records a change to a tenant or its members
+func (h *TenantHandler) logTenantChange(c *gin.Context, action models.AuditLogAction, tenant *models.Tenant, details models.AuditLogDetails) {
+	actorID, exists := c.Get("user_id")
+	if !exists {
+		return
+	}
+
+	if details == nil {
+		details = models.AuditLogDetails{}
+	}
+	details["timestamp"] = time.Now().Unix()
+
+	var resourceName *string
+	if tenant.Name != "" {
+		resourceName = &tenant.Name
+	}
+
+	if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+		UserID:       actorID.(uuid.UUID),
+		Action:       action,
+		ResourceType: models.AuditResourceTenant,
+		ResourceID:   &tenant.ID,
+		ResourceName: resourceName,
+		Details:      details,
+		Status:       models.AuditStatusSuccess,
+	}); err != nil {
+		fmt.Printf("Failed to log tenant change: %v\n", err)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/services"
+)
+
+// TriggerHandler serves the polling triggers of automation services such as
+// Zapier and IFTTT. Triggers answer with a bare JSON array, newest first,
+// whose items each carry a unique id, as those services expect
+type TriggerHandler struct {
+	triggerService *services.TriggerService
+}
+
+func NewTriggerHandler(triggerService *services.TriggerService) *TriggerHandler {
+	return &TriggerHandler{triggerService: triggerService}
+}
+
+// GetMe identifies the API key's user, for services to test the key and
+// label the connection
+// GET /api/v1/triggers/me
+func (h *TriggerHandler) GetMe(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"id":       c.MustGet("user_id").(uuid.UUID),
+		"username": c.GetString("username"),
+		"email":    c.GetString("email"),
+	})
+}
+
+// GetNewFiles returns the files the user uploaded, newest first. Pass the
+// cursor of the newest file seen as ?since= to get only newer ones
+// GET /api/v1/triggers/new-files
+func (h *TriggerHandler) GetNewFiles(c *gin.Context) {
+	files, err := h.triggerService.NewFiles(c.Request.Context(), c.MustGet("user_id").(uuid.UUID), c.Query("since"), triggerLimit(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidChangeCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch new files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, files)
+}
+
+// GetNewShares returns the files shared with the user, newest first. Pass
+// the cursor of the newest share seen as ?since= to get only newer ones
+// GET /api/v1/triggers/new-shares
+func (h *TriggerHandler) GetNewShares(c *gin.Context) {
+	shares, err := h.triggerService.NewShares(c.Request.Context(), c.MustGet("user_id").(uuid.UUID), c.Query("since"), triggerLimit(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidChangeCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch new shares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// triggerLimit reads ?limit=, from 1 to 100 and 50 by default
+func triggerLimit(c *gin.Context) int {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	return limit
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// tusVersion is the version of the tus protocol served
+const tusVersion = "1.0.0"
+
+// tusExtensions are the optional parts of the tus protocol served
+const tusExtensions = "creation,creation-with-upload,termination,expiration,checksum"
+
+// tusContentType is the content type of the data sent with a PATCH
+const tusContentType = "application/offset+octet-stream"
+
+// TusOptions describes the tus protocol as served, for clients discovering
+// it before they sign in
+// OPTIONS /api/v1/uploads
+func (h *FileHandler) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.cfg.MaxFileSize, 10))
+	c.Header("Tus-Checksum-Algorithm", services.TusChecksumAlgorithms)
+	c.Status(http.StatusNoContent)
+}
+
+// CreateTusUpload starts a tus upload of Upload-Length bytes. Upload-Metadata
+// carries the filename and filetype, and optionally folder_id, is_public
+// ("true") and encryption_header, and the same checks are made up front as
+// for POST /api/v1/files/uploads. Data sent with the request is the start
+// of the upload; when it is all of it, the file is stored at once and its ID
+// is sent in Upload-File-Id
+// POST /api/v1/uploads
+func (h *FileHandler) CreateTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Defer-Length is not supported; send Upload-Length", "code": "UPLOAD_LENGTH_REQUIRED"})
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length must be the size of the file in bytes", "code": "UPLOAD_LENGTH_REQUIRED"})
+		return
+	}
+	if size > h.cfg.MaxFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, middleware.FileTooLargeResponse(h.cfg.MaxFileSize, size))
+		return
+	}
+
+	metadataHeader := c.GetHeader("Upload-Metadata")
+	metadata, ok := parseTusMetadata(metadataHeader)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Metadata must be keys with base64 values", "code": "INVALID_UPLOAD_METADATA"})
+		return
+	}
+	// tus-js-client and Uppy name these differently
+	for key, alias := range map[string]string{"filename": "name", "filetype": "type"} {
+		if metadata[key] == "" {
+			metadata[key] = metadata[alias]
+		}
+	}
+	req := createUploadSessionRequest{
+		Filename:         metadata["filename"],
+		MimeType:         metadata["filetype"],
+		Size:             size,
+		IsPublic:         metadata["is_public"] == "true",
+		EncryptionHeader: metadata["encryption_header"],
+	}
+	if folderID := metadata["folder_id"]; folderID != "" && folderID != "root" {
+		parsed, err := uuid.Parse(folderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID format"})
+			return
+		}
+		req.FolderID = &parsed
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	if !h.checkResumableUpload(c, userID, &req) {
+		return
+	}
+
+	upload, err := h.tusUploadService.Create(services.CreateTusUploadParams{
+		OwnerID:          userID,
+		FolderID:         req.FolderID,
+		Filename:         req.Filename,
+		MimeType:         req.MimeType,
+		Size:             req.Size,
+		Metadata:         metadataHeader,
+		IsPublic:         req.IsPublic,
+		EncryptionHeader: req.EncryptionHeader,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Location", "/api/v1/uploads/"+upload.ID.String())
+	tusUploadHeaders(c, upload)
+
+	if c.ContentType() == tusContentType && c.Request.ContentLength != 0 {
+		upload, err = h.tusUploadService.Append(upload, 0, c.Request.Body, nil)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		tusUploadHeaders(c, upload)
+	}
+	if upload.Complete() && !h.storeTusUpload(c, upload) {
+		return
+	}
+	c.Status(http.StatusCreated)
+}
+
+// HeadTusUpload reports how much of a tus upload has been received, so an
+// interrupted client can resume from there
+// HEAD /api/v1/uploads/:id
+func (h *FileHandler) HeadTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	upload, ok := h.tusUpload(c)
+	if !ok {
+		return
+	}
+
+	tusUploadHeaders(c, upload)
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// PatchTusUpload appends data to a tus upload at Upload-Offset, which must
+// be the upload's offset. With Upload-Checksum the data is stored only if it
+// matches, and 460 answers data that does not. The request that completes
+// the upload stores the file and sends its ID in Upload-File-Id. The upload
+// is kept when the file is refused, so a PATCH of no data at its end stores
+// it again once the cause is resolved
+// PATCH /api/v1/uploads/:id
+func (h *FileHandler) PatchTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":    "Invalid content type",
+			"expected": tusContentType,
+			"received": c.ContentType(),
+		})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset must be the offset the data starts at", "code": "UPLOAD_OFFSET_REQUIRED"})
+		return
+	}
+	var checksum *services.TusChecksum
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		if checksum, err = services.ParseTusChecksum(header); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	upload, ok := h.tusUpload(c)
+	if !ok {
+		return
+	}
+	upload, err = h.tusUploadService.Append(upload, offset, c.Request.Body, checksum)
+	if err == services.ErrTusChecksumMismatch {
+		// tus has its own status for data that fails its checksum
+		c.JSON(460, services.ErrTusChecksumMismatch.Body())
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	tusUploadHeaders(c, upload)
+	if upload.Complete() && !h.storeTusUpload(c, upload) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteTusUpload discards a tus upload and the data received
+// DELETE /api/v1/uploads/:id
+func (h *FileHandler) DeleteTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	upload, ok := h.tusUpload(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tusUploadService.Delete(upload); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// storeTusUpload stores a complete tus upload as a regular upload of the
+// file would be, sets Upload-File-Id and discards the upload once the file
+// is stored. It answers a refused file and reports whether it was stored
+func (h *FileHandler) storeTusUpload(c *gin.Context, upload *models.TusUpload) bool {
+	file, err := h.storeFile(c, upload.OwnerID, assembledUpload{
+		filename:         upload.Filename,
+		mimeType:         upload.MimeType,
+		size:             upload.Size,
+		folderID:         upload.FolderID,
+		isPublic:         upload.IsPublic,
+		encryptionHeader: upload.EncryptionHeader,
+		open:             func() (io.ReadCloser, error) { return h.tusUploadService.Open(upload) },
+	}.request())
+	if err != nil {
+		respondUploadError(c, err)
+		return false
+	}
+
+	c.Header("Upload-File-Id", file.ID.String())
+	if err := h.tusUploadService.Delete(upload); err != nil {
+		fmt.Printf("Failed to delete completed tus upload %s: %v\n", upload.ID, err)
+	}
+	return true
+}
+
+// tusUpload loads the current user's upload named by the :id parameter
+func (h *FileHandler) tusUpload(c *gin.Context) (*models.TusUpload, bool) {
+	uploadID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(services.ErrTusUploadNotFound)
+		return nil, false
+	}
+
+	upload, err := h.tusUploadService.Get(c.MustGet("user_id").(uuid.UUID), uploadID)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return upload, true
+}
+
+// tusResumable marks the response as tus and checks the request speaks the
+// version served, answering it when not
+func tusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported tus version", "code": "TUS_VERSION_UNSUPPORTED", "supported": tusVersion})
+		return false
+	}
+	return true
+}
+
+// tusUploadHeaders sets the offset and expiry of an upload on the response
+func tusUploadHeaders(c *gin.Context, upload *models.TusUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseTusMetadata reads an Upload-Metadata header: comma-separated keys,
+// each followed by a space and its base64 value unless it has none
+func parseTusMetadata(header string) (map[string]string, bool) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, false
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, true
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type UploadPolicyHandler struct {
+	db                  *gorm.DB
+	uploadPolicyService *services.UploadPolicyService
+}
+
+func NewUploadPolicyHandler(db *gorm.DB, uploadPolicyService *services.UploadPolicyService) *UploadPolicyHandler {
+	return &UploadPolicyHandler{
+		db:                  db,
+		uploadPolicyService: uploadPolicyService,
+	}
+}
+
+// ListPolicies returns all upload policies (admin only)
+// GET /api/v1/admin/upload-policies
+func (h *UploadPolicyHandler) ListPolicies(c *gin.Context) {
+	policies, err := h.uploadPolicyService.ListPolicies(c.Query("role"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upload policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// CreatePolicy adds an upload policy (admin only)
+// POST /api/v1/admin/upload-policies
+func (h *UploadPolicyHandler) CreatePolicy(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req services.UploadPolicyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	policy, err := h.uploadPolicyService.CreatePolicy(req, userID.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload policy"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// UpdatePolicy replaces an upload policy (admin only)
+// PUT /api/v1/admin/upload-policies/:id
+func (h *UploadPolicyHandler) UpdatePolicy(c *gin.Context) {
+	policyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy ID"})
+		return
+	}
+
+	var req services.UploadPolicyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	policy, err := h.uploadPolicyService.UpdatePolicy(policyID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload policy not found"})
+		case errors.Is(err, services.ErrInvalidPolicy):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update upload policy"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeletePolicy removes an upload policy (admin only)
+// DELETE /api/v1/admin/upload-policies/:id
+func (h *UploadPolicyHandler) DeletePolicy(c *gin.Context) {
+	policyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy ID"})
+		return
+	}
+
+	if err := h.uploadPolicyService.DeletePolicy(policyID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload policy not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete upload policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload policy deleted"})
+}
+
+// GetMyPolicies returns the upload policies that apply to the current user
+// GET /api/v1/files/upload-policies
+func (h *UploadPolicyHandler) GetMyPolicies(c *gin.Context) {
+	role, err := h.currentRole(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	policies, err := h.uploadPolicyService.ListPolicies(role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upload policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":     role,
+		"policies": policies,
+	})
+}
+
+// PreviewPolicies evaluates prospective uploads against the current user's
+// policies so clients can reject files before sending them
+// POST /api/v1/files/upload-policies/preview
+func (h *UploadPolicyHandler) PreviewPolicies(c *gin.Context) {
+	role, err := h.currentRole(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Files    []services.PolicyFile `json:"files" binding:"required,min=1,dive"`
+		IsPublic bool                  `json:"is_public"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	decisions, err := h.uploadPolicyService.Evaluate(role, req.Files, req.IsPublic)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate upload policies"})
+		return
+	}
+
+	allowed := true
+	for _, decision := range decisions {
+		if !decision.Allowed {
+			allowed = false
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"allowed":   allowed,
+		"decisions": decisions,
+	})
+}
+
+// currentRole looks up the authenticated user's role from the database so
+// previews match what the upload path enforces
+func (h *UploadPolicyHandler) currentRole(c *gin.Context) (string, error) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return "", errors.New("user not authenticated")
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).Select("id", "role").First(&user, "id = ?", userID).Error; err != nil {
+		return "", err
+	}
+	return string(user.Role), nil
+}
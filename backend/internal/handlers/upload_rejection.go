@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type UploadRejectionHandler struct {
+	rejectionService *services.UploadRejectionService
+}
+
+func NewUploadRejectionHandler(rejectionService *services.UploadRejectionService) *UploadRejectionHandler {
+	return &UploadRejectionHandler{
+		rejectionService: rejectionService,
+	}
+}
+
+// uploadRejectionReasons lists the reasons accepted by the ?reason= filter
+var uploadRejectionReasons = []models.UploadRejectionReason{
+	models.RejectionSizeExceeded,
+	models.RejectionMimeMismatch,
+	models.RejectionMimeNotAllowed,
+	models.RejectionPolicyViolation,
+	models.RejectionSensitiveContent,
+	models.RejectionScanUnavailable,
+	models.RejectionMalwareDetected,
+	models.RejectionQuotaExceeded,
+}
+
+// ListRejections returns rejected uploads, newest first, with counts by
+// reason, by declared and detected MIME type and by user (admin only).
+// Filter with ?reason=, ?user_id= and ?since= / ?until= (RFC 3339).
+// GET /api/v1/admin/upload-rejections
+func (h *UploadRejectionHandler) ListRejections(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	filter := services.UploadRejectionFilter{
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	if reason := c.Query("reason"); reason != "" {
+		if !isUploadRejectionReason(models.UploadRejectionReason(reason)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rejection reason: " + reason})
+			return
+		}
+		filter.Reason = reason
+	}
+
+	if userParam := c.Query("user_id"); userParam != "" {
+		userID, err := uuid.Parse(userParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	var ok bool
+	if filter.Since, ok = timeQuery(c, "since"); !ok {
+		return
+	}
+	if filter.Until, ok = timeQuery(c, "until"); !ok {
+		return
+	}
+
+	report, err := h.rejectionService.Report(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upload rejections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rejections":   NewUploadRejectionDTOs(report.Rejections),
+		"total":        report.Total,
+		"page":         page,
+		"limit":        limit,
+		"has_more":     int64(page*limit) < report.Total,
+		"by_reason":    report.ByReason,
+		"by_mime_type": report.ByMimeType,
+		"top_users":    report.TopUsers,
+	})
+}
+
+// isUploadRejectionReason reports whether r is a known rejection reason
+func isUploadRejectionReason(r models.UploadRejectionReason) bool {
+	for _, known := range uploadRejectionReasons {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// createUploadSessionRequest describes a file to be uploaded in chunks
+type createUploadSessionRequest struct {
+	Filename         string     `json:"filename" binding:"required,max=255"`
+	MimeType         string     `json:"mime_type" binding:"max=100"`
+	Size             int64      `json:"size" binding:"min=0"`
+	ChunkSize        int64      `json:"chunk_size" binding:"min=0"` // the configured default when omitted
+	FolderID         *uuid.UUID `json:"folder_id"`
+	IsPublic         bool       `json:"is_public"`
+	EncryptionHeader string     `json:"encryption_header" binding:"max=8192"`
+}
+
+// assembledUpload is a file received in pieces by a resumable upload, or
+// uploaded straight to the object store
+type assembledUpload struct {
+	filename         string
+	mimeType         string
+	size             int64
+	folderID         *uuid.UUID
+	isPublic         bool
+	encryptionHeader string
+	open             func() (io.ReadCloser, error)
+	direct           *models.DirectUpload // in place of open, for content in the object store
+}
+
+// request returns the upload request that stores the file like a regular
+// upload of it
+func (u assembledUpload) request() uploadRequest {
+	req := uploadRequest{
+		Parts: []uploadPart{{
+			Header: &multipart.FileHeader{
+				Filename: u.filename,
+				Header:   textproto.MIMEHeader{"Content-Type": {u.mimeType}},
+				Size:     u.size,
+			},
+			Open:   u.open,
+			Direct: u.direct,
+		}},
+		IsPublic: u.isPublic,
+	}
+	if u.folderID != nil {
+		req.FolderID = u.folderID.String()
+	}
+	if u.encryptionHeader != "" {
+		req.EncryptionHeaders = []string{u.encryptionHeader}
+	}
+	return req
+}
+
+// CreateUploadSession starts a resumable upload. The file's size, the
+// folder and the quota are checked up front so clients learn before sending
+// anything whether the upload can succeed; everything else is checked when
+// the upload completes, as for a regular upload
+// POST /api/v1/files/uploads
+func (h *FileHandler) CreateUploadSession(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req createUploadSessionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !h.checkResumableUpload(c, userID, &req) {
+		return
+	}
+
+	session, err := h.uploadSessionService.Create(services.CreateUploadSessionParams{
+		OwnerID:          userID,
+		FolderID:         req.FolderID,
+		Filename:         req.Filename,
+		MimeType:         req.MimeType,
+		Size:             req.Size,
+		ChunkSize:        req.ChunkSize,
+		IsPublic:         req.IsPublic,
+		EncryptionHeader: req.EncryptionHeader,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"upload": NewUploadSessionDTO(session)})
+}
+
+// checkResumableUpload fills in the defaults of a resumable upload and
+// checks its folder, encryption header, size and the owner's quota. It
+// answers the request and returns false when the upload cannot succeed
+func (h *FileHandler) checkResumableUpload(c *gin.Context, userID uuid.UUID, req *createUploadSessionRequest) bool {
+	req.Filename = strings.TrimSpace(req.Filename)
+	if req.Filename == "" {
+		c.Error(apperrors.ErrInvalidInput.WithCode("VALIDATION_FAILED", "filename is required"))
+		return false
+	}
+	if req.MimeType == "" {
+		req.MimeType = "application/octet-stream"
+	}
+
+	// Files in vault folders are encrypted by the client, which sends the
+	// file's encryption header up front
+	encrypted := false
+	if req.FolderID != nil {
+		var folder models.Folder
+		if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", *req.FolderID, userID).First(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Target folder not found"})
+				return false
+			}
+			c.Error(apperrors.Internal(err))
+			return false
+		}
+		encrypted = folder.IsEncrypted()
+	}
+	if !encrypted && req.EncryptionHeader != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "encryption_header is only accepted for uploads to encrypted folders",
+			"code":  "NOT_ENCRYPTED_FOLDER",
+		})
+		return false
+	}
+	if encrypted && req.EncryptionHeader == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Files uploaded to an encrypted folder need an encryption_header",
+			"code":  "ENCRYPTION_HEADER_REQUIRED",
+		})
+		return false
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).Preload("Plan").First(&user, "id = ?", userID).Error; err != nil {
+		c.Error(apperrors.Internal(err))
+		return false
+	}
+	if maxFileSize := user.MaxUploadSize(h.cfg.MaxFileSize); req.Size > maxFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     fmt.Sprintf("File %s exceeds size limit", req.Filename),
+			"max_size":  maxFileSize,
+			"file_size": req.Size,
+		})
+		return false
+	}
+	if user.StorageUsed+req.Size > h.quotaGraceService.Limit(&user) {
+		c.JSON(http.StatusForbidden, middleware.QuotaExceededResponse(user.StorageQuota, user.StorageUsed, req.Size))
+		return false
+	}
+	return true
+}
+
+// GetUploadSession reports which chunks of a resumable upload have been
+// received, so an interrupted client can send only the missing ones
+// GET /api/v1/files/uploads/:id
+func (h *FileHandler) GetUploadSession(c *gin.Context) {
+	session, ok := h.uploadSession(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upload": NewUploadSessionDTO(session)})
+}
+
+// UploadChunk stores one chunk of a resumable upload. The body is the
+// chunk's bytes and X-Chunk-SHA256 or X-Chunk-CRC32C its hex checksum; a
+// chunk that does not match is refused and can be sent again on its own.
+// Chunks may be sent in any order, and concurrently
+// PUT /api/v1/files/uploads/:id/chunks/:index
+func (h *FileHandler) UploadChunk(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.Error(services.ErrChunkOutOfRange)
+		return
+	}
+
+	var checksum services.ChunkChecksum
+	if value := c.GetHeader("X-Chunk-SHA256"); value != "" {
+		checksum = services.ChunkChecksum{Algorithm: services.ChunkChecksumSHA256, Value: value}
+	} else if value := c.GetHeader("X-Chunk-CRC32C"); value != "" {
+		checksum = services.ChunkChecksum{Algorithm: services.ChunkChecksumCRC32C, Value: value}
+	}
+
+	session, ok := h.uploadSession(c)
+	if !ok {
+		return
+	}
+
+	session, err = h.uploadSessionService.PutChunk(session, index, c.Request.Body, checksum)
+	if err != nil {
+		var quotaErr *middleware.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			quota, _ := c.Get("user_quota")
+			used, _ := c.Get("used_quota")
+			c.JSON(http.StatusForbidden, middleware.QuotaExceededResponse(quota.(int64), used.(int64), quotaErr.Received))
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chunk":          index,
+		"received_count": session.ReceivedCount,
+		"chunk_count":    session.ChunkCount,
+		"complete":       session.Complete(),
+	})
+}
+
+// CompleteUploadSession stores a resumable upload once every chunk has been
+// received. The assembled file goes through the same checks as a regular
+// upload and the response is the same. The session is kept when the upload
+// is refused, so it can be completed again once the cause is resolved
+// POST /api/v1/files/uploads/:id/complete
+func (h *FileHandler) CompleteUploadSession(c *gin.Context) {
+	session, ok := h.uploadSession(c)
+	if !ok {
+		return
+	}
+	if !session.Complete() {
+		c.Error(services.ErrUploadIncomplete.WithDetail("missing_chunks", session.MissingChunks()))
+		return
+	}
+
+	h.storeUpload(c, session.OwnerID, assembledUpload{
+		filename:         session.Filename,
+		mimeType:         session.MimeType,
+		size:             session.Size,
+		folderID:         session.FolderID,
+		isPublic:         session.IsPublic,
+		encryptionHeader: session.EncryptionHeader,
+		open:             func() (io.ReadCloser, error) { return h.uploadSessionService.Open(session) },
+	}.request())
+
+	if c.Writer.Status() == http.StatusOK && len(c.Errors) == 0 {
+		if err := h.uploadSessionService.Delete(session); err != nil {
+			fmt.Printf("Failed to delete completed upload session %s: %v\n", session.ID, err)
+		}
+	}
+}
+
+// AbortUploadSession discards a resumable upload and the chunks received
+// DELETE /api/v1/files/uploads/:id
+func (h *FileHandler) AbortUploadSession(c *gin.Context) {
+	session, ok := h.uploadSession(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploadSessionService.Delete(session); err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload discarded"})
+}
+
+// uploadSession loads the current user's session named by the :id parameter
+func (h *FileHandler) uploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(services.ErrUploadSessionNotFound)
+		return nil, false
+	}
+
+	session, err := h.uploadSessionService.Get(c.MustGet("user_id").(uuid.UUID), sessionID)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return session, true
+}
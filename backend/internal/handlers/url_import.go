@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// importURLRequest starts a URL import
+type importURLRequest struct {
+	URL      string     `json:"url" binding:"required"`
+	Filename string     `json:"filename" binding:"max=255"`
+	FolderID *uuid.UUID `json:"folder_id"`
+	IsPublic bool       `json:"is_public"`
+}
+
+// ImportURL downloads a file from an http or https URL in the background
+// and stores it in folder_id as an upload of it would be, with the same type
+// and size checks, scans, quotas and deduplication. The file is named
+// filename, or as the server or the URL name it; its extension must match
+// its content. The response is the import, whose id is polled for progress
+// POST /api/v1/files/import-url
+func (h *FileHandler) ImportURL(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req importURLRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	req.Filename = strings.TrimSpace(req.Filename)
+
+	// The server cannot encrypt content for a vault folder, which only its
+	// members' clients can do
+	if req.FolderID != nil {
+		var folder models.Folder
+		if err := h.db.WithContext(c.Request.Context()).Where("id = ? AND owner_id = ?", *req.FolderID, userID).First(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Target folder not found"})
+				return
+			}
+			c.Error(apperrors.Internal(err))
+			return
+		}
+		if folder.IsEncrypted() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Files cannot be imported into an encrypted folder",
+				"code":  "ENCRYPTED_FOLDER",
+			})
+			return
+		}
+	}
+
+	var user models.User
+	if err := h.db.WithContext(c.Request.Context()).Preload("Plan").First(&user, "id = ?", userID).Error; err != nil {
+		c.Error(apperrors.Internal(err))
+		return
+	}
+
+	job, err := h.urlImportService.Create(userID, services.URLImportParams{
+		URL:      req.URL,
+		Filename: req.Filename,
+		FolderID: req.FolderID,
+		IsPublic: req.IsPublic,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	dto := NewURLImportDTO(job)
+	go h.runURLImport(detachedContext(c), job, user.MaxUploadSize(h.cfg.MaxFileSize))
+
+	c.Header("Location", "/api/v1/files/import-url/"+job.ID.String())
+	c.JSON(http.StatusAccepted, gin.H{"import": dto})
+}
+
+// GetURLImport returns a URL import: its status, the bytes received so far
+// and, once known, the total. A completed import names the file it was
+// stored as; a failed one has an error_code and error
+// GET /api/v1/files/import-url/:id
+func (h *FileHandler) GetURLImport(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	importID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(services.ErrURLImportNotFound)
+		return
+	}
+
+	job, err := h.urlImportService.Get(userID, importID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"import": NewURLImportDTO(job)})
+}
+
+// runURLImport downloads a URL import and stores the file as an upload of
+// it would be, recording the outcome on the import
+func (h *FileHandler) runURLImport(c *gin.Context, job *models.URLImport, maxSize int64) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("URL import %s panicked: %v", job.ID, r)
+			h.urlImportService.Fail(job, "IMPORT_FAILED", "The file could not be imported")
+		}
+	}()
+
+	fetched, err := h.urlImportService.Fetch(job, maxSize)
+	if err != nil {
+		var importErr *services.URLImportError
+		if errors.As(err, &importErr) {
+			h.urlImportService.Fail(job, importErr.Code, importErr.Message)
+			return
+		}
+		log.Printf("URL import %s failed: %v", job.ID, err)
+		h.urlImportService.Fail(job, "IMPORT_FAILED", "The file could not be downloaded")
+		return
+	}
+	defer os.Remove(fetched.Staged.Path)
+
+	h.urlImportService.MarkProcessing(job)
+	file, err := h.storeFile(c, job.UserID, assembledUpload{
+		filename: fetched.Filename,
+		mimeType: fetched.MimeType,
+		size:     fetched.Staged.Size,
+		folderID: job.FolderID,
+		isPublic: job.IsPublic,
+		open:     func() (io.ReadCloser, error) { return os.Open(fetched.Staged.Path) },
+	}.request())
+	if err != nil {
+		code, message := importFailure(job, err)
+		h.urlImportService.Fail(job, code, message)
+		return
+	}
+	h.urlImportService.Complete(job, file.ID)
+}
+
+// importFailure returns the code and message recorded on an import whose
+// file was not stored. Server errors are logged rather than recorded
+func importFailure(job *models.URLImport, err error) (string, string) {
+	var uploadErr *uploadError
+	if errors.As(err, &uploadErr) && uploadErr.status != http.StatusInternalServerError {
+		return uploadErr.code(), uploadErr.Error()
+	}
+	if appErr := apperrors.From(err); appErr.Status < http.StatusInternalServerError {
+		return appErr.Code, appErr.Message
+	}
+	log.Printf("URL import %s failed: %v", job.ID, err)
+	return "IMPORT_FAILED", "The file could not be stored"
+}
+
+// detachedContext returns a copy of the request's context for work that
+// carries on after the request has been answered, whose own context must not
+// be used for it. The copy keeps the request's client address, headers and
+// keys, so audit records and events name the same client, but cannot write
+// a response
+func detachedContext(c *gin.Context) *gin.Context {
+	detached := c.Copy()
+	detached.Request = c.Request.Clone(context.Background())
+	detached.Request.Body = http.NoBody
+	return detached
+}
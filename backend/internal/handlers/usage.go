@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/middleware"
+)
+
+type UsageHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewUsageHandler(db *gorm.DB, cfg *config.Config) *UsageHandler {
+	return &UsageHandler{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// GetMyUsage returns the caller's consumption of each rate limit that applies
+// to them, what remains and how many requests were refused recently
+// GET /api/v1/me/usage
+func (h *UsageHandler) GetMyUsage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	usage, err := middleware.GetRateLimitUsage(h.db, h.cfg, userID.(uuid.UUID), c.GetString("role"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rate limit usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
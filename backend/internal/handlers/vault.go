@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+type VaultHandler struct {
+	vaultService *services.VaultService
+	auditService *services.AuditService
+}
+
+func NewVaultHandler(vaultService *services.VaultService, auditService *services.AuditService) *VaultHandler {
+	return &VaultHandler{
+		vaultService: vaultService,
+		auditService: auditService,
+	}
+}
+
+// GetVaultConfig tells clients whether encrypted folders may be created and
+// which escrow public key their keys must also be wrapped for
+// GET /api/v1/vault/config
+func (h *VaultHandler) GetVaultConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":           h.vaultService.Enabled(),
+		"escrow_public_key": h.vaultService.EscrowPublicKey(),
+	})
+}
+
+// SetPublicKeyRequest is the public key other users wrap vault keys with;
+// the matching private key never leaves the user's devices
+type SetPublicKeyRequest struct {
+	Algorithm string `json:"algorithm" binding:"required,max=50"`
+	PublicKey string `json:"public_key" binding:"required,max=16384"`
+}
+
+// SetPublicKey registers or replaces the current user's public key
+// PUT /api/v1/vault/public-key
+func (h *VaultHandler) SetPublicKey(c *gin.Context) {
+	var req SetPublicKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	key, err := h.vaultService.SetPublicKey(c.MustGet("user_id").(uuid.UUID), req.Algorithm, req.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save public key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"public_key": key})
+}
+
+// GetMyPublicKey returns the current user's public key
+// GET /api/v1/vault/public-key
+func (h *VaultHandler) GetMyPublicKey(c *gin.Context) {
+	h.respondPublicKey(c, c.MustGet("user_id").(uuid.UUID))
+}
+
+// GetUserPublicKey returns another user's public key, to wrap a vault key
+// for them
+// GET /api/v1/vault/public-keys/:userId
+func (h *VaultHandler) GetUserPublicKey(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	h.respondPublicKey(c, userID)
+}
+
+func (h *VaultHandler) respondPublicKey(c *gin.Context, userID uuid.UUID) {
+	key, err := h.vaultService.GetPublicKey(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"public_key": key})
+}
+
+// GetMyVaultKey returns the current user's wrapped key of the encrypted
+// folder, whether they own it or it was shared with them
+// GET /api/v1/folders/:id/vault-key
+func (h *VaultHandler) GetMyVaultKey(c *gin.Context) {
+	folderID, ok := parseFolderParam(c)
+	if !ok {
+		return
+	}
+
+	grant, err := h.vaultService.MyKey(c.Request.Context(), c.MustGet("user_id").(uuid.UUID), folderID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"key": grant})
+}
+
+// ListVaultKeys lists who holds the key of an owned encrypted folder
+// GET /api/v1/folders/:id/vault-keys
+func (h *VaultHandler) ListVaultKeys(c *gin.Context) {
+	folderID, ok := parseFolderParam(c)
+	if !ok {
+		return
+	}
+
+	grants, err := h.vaultService.ListKeys(c.MustGet("user_id").(uuid.UUID), folderID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": grants})
+}
+
+// GrantVaultKeyRequest is the folder's key wrapped with the recipient's
+// public key
+type GrantVaultKeyRequest struct {
+	WrappedKey string `json:"wrapped_key" binding:"required,max=8192"`
+}
+
+// GrantVaultKey gives a user the folder is shared with its key
+// PUT /api/v1/folders/:id/vault-keys/:userId
+func (h *VaultHandler) GrantVaultKey(c *gin.Context) {
+	folderID, ok := parseFolderParam(c)
+	if !ok {
+		return
+	}
+	recipientID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req GrantVaultKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ownerID := c.MustGet("user_id").(uuid.UUID)
+	grant, err := h.vaultService.GrantKey(ownerID, folderID, recipientID, req.WrappedKey)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.logKeyChange(c, ownerID, models.AuditActionShare, folderID, recipientID)
+	c.JSON(http.StatusOK, gin.H{"key": grant})
+}
+
+// RevokeVaultKey takes the folder's key back from a user. Files they could
+// read before stay readable with a key they kept.
+// DELETE /api/v1/folders/:id/vault-keys/:userId
+func (h *VaultHandler) RevokeVaultKey(c *gin.Context) {
+	folderID, ok := parseFolderParam(c)
+	if !ok {
+		return
+	}
+	recipientID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	ownerID := c.MustGet("user_id").(uuid.UUID)
+	if err := h.vaultService.RevokeKey(ownerID, folderID, recipientID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.logKeyChange(c, ownerID, models.AuditActionDelete, folderID, recipientID)
+	c.JSON(http.StatusOK, gin.H{"message": "Vault key revoked successfully"})
+}
+
+// GetEscrowVaultKey returns the escrow copy of an encrypted folder's key so
+// its content can be recovered, for example after the owner lost their
+// devices. A reason is required and audited (admin only).
+// GET /api/v1/admin/folders/:id/vault-key/escrow?reason=...
+func (h *VaultHandler) GetEscrowVaultKey(c *gin.Context) {
+	folderID, ok := parseFolderParam(c)
+	if !ok {
+		return
+	}
+	reason := strings.TrimSpace(c.Query("reason"))
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "A reason is required to recover an encrypted folder's key",
+			"code":  "ACCESS_REASON_REQUIRED",
+		})
+		return
+	}
+	if utf8.RuneCountInString(reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason must be at most 500 characters"})
+		return
+	}
+
+	folder, grant, err := h.vaultService.EscrowKey(folderID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Recovery is only served once it is on record
+	if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+		UserID:       c.MustGet("user_id").(uuid.UUID),
+		Action:       models.AuditActionRecover,
+		ResourceType: models.AuditResourceVaultKey,
+		ResourceID:   &folder.ID,
+		ResourceName: &folder.Name,
+		Details: models.AuditLogDetails{
+			"vault_id":  grant.VaultID,
+			"owner_id":  folder.OwnerID,
+			"reason":    reason,
+			"timestamp": time.Now().Unix(),
+		},
+		Status: models.AuditStatusSuccess,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record key recovery"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"key": grant})
+}
+
+// logKeyChange audits giving or taking back the key of an encrypted folder
+func (h *VaultHandler) logKeyChange(c *gin.Context, ownerID uuid.UUID, action models.AuditLogAction, folderID, recipientID uuid.UUID) {
+	if err := h.auditService.LogActivityFromGin(c, services.LogActivityParams{
+		UserID:       ownerID,
+		Action:       action,
+		ResourceType: models.AuditResourceVaultKey,
+		ResourceID:   &folderID,
+		Details:      models.AuditLogDetails{"recipient_id": recipientID, "timestamp": time.Now().Unix()},
+		Status:       models.AuditStatusSuccess,
+	}); err != nil {
+		fmt.Printf("Failed to log vault key change: %v\n", err)
+	}
+}
+
+// parseFolderParam parses the :id folder parameter, answering invalid IDs
+func parseFolderParam(c *gin.Context) (uuid.UUID, bool) {
+	folderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return uuid.Nil, false
+	}
+	return folderID, true
+}
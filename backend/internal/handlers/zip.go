@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+)
+
+// zipFile is a file placed in a ZIP download under name, a slash-separated
+// path inside the archive
+type zipFile struct {
+	name string
+	file *models.File
+}
+
+// zipDownload streams files the user may download as one ZIP archive. File
+// and folder handlers share it so both kinds of archive are built, limited
+// and recorded the same way.
+type zipDownload struct {
+	db           *gorm.DB
+	cfg          *config.Config
+	auditService *services.AuditService
+	streams      *services.FileStreamService
+	zips         *services.ZipService
+}
+
+func newZipDownload(db *gorm.DB, cfg *config.Config, auditService *services.AuditService) *zipDownload {
+	return &zipDownload{
+		db:           db,
+		cfg:          cfg,
+		auditService: auditService,
+		streams:      services.NewFileStreamService(db, cfg),
+		zips:         services.NewZipService(cfg),
+	}
+}
+
+// tooMany answers when an archive would hold more files than allowed and
+// reports whether a response was written
+func (z *zipDownload) tooMany(c *gin.Context, count int) bool {
+	if count <= z.cfg.ZipMaxEntries {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":       "Too many files for one archive",
+		"type":        "ZIP_TOO_LARGE",
+		"message":     fmt.Sprintf("A ZIP download may contain at most %d files", z.cfg.ZipMaxEntries),
+		"code":        "ZIP_TOO_LARGE",
+		"max_entries": z.cfg.ZipMaxEntries,
+	})
+	return true
+}
+
+// serve locates the content of every file before the response starts, so a
+// missing or archived file is still reported with a proper status, then
+// records each file as downloaded and streams the archive. dirs are added as
+// directory entries so empty folders survive the download. userID is nil for
+// anonymous downloads through a share link.
+func (z *zipDownload) serve(c *gin.Context, userID *uuid.UUID, archiveName string, dirs []string, files []zipFile) {
+	entries := make([]services.ZipEntry, 0, len(dirs)+len(files))
+	now := time.Now()
+	for _, dir := range dirs {
+		entries = append(entries, services.ZipEntry{Name: dir + "/", Modified: now})
+	}
+	for _, f := range files {
+		if respondIfUnscanned(c, z.streams, f.file) {
+			return
+		}
+		stream, err := z.streams.Open(f.file)
+		if err != nil {
+			respondStreamError(c, err)
+			return
+		}
+		entries = append(entries, services.ZipEntry{Name: f.name, Stream: stream, Modified: f.file.UpdatedAt})
+	}
+
+	if err := z.db.Transaction(func(tx *gorm.DB) error {
+		for _, f := range files {
+			if err := tx.Create(newDownloadStat(f.file.ID, userID, nil, c)).Error; err != nil {
+				return err
+			}
+			if userID == nil {
+				z.auditService.LogFileAccess(c, models.AuditActionDownload, f.file, "share_link")
+				continue
+			}
+			if err := z.auditService.LogFileDownload(tx, c, *userID, f.file.ID, f.file.OriginalFilename, f.file.Size); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		fmt.Printf("Failed to record ZIP download of %d files: %v\n", len(files), err)
+	}
+	for _, f := range files {
+		publishDownload(c, f.file, nil)
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", archiveName))
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	if err := z.zips.Write(c.Request.Context(), c.Writer, entries); err != nil {
+		fmt.Printf("ZIP download of %s stopped: %v\n", archiveName, err)
+	}
+}
+
+// zipEntryName makes a file or folder name safe to use as one segment of a
+// path inside an archive
+func zipEntryName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// uniqueZipName returns name, or name with a counter before its extension
+// when the archive already holds an entry of that name
+func uniqueZipName(used map[string]bool, name string) string {
+	if !used[name] {
+		used[name] = true
+		return name
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !used[candidate] {
+			used[candidate] = true
+			return candidate
+		}
+	}
+}
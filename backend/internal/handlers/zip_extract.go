@@ -0,0 +1,412 @@
+package handlers
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/internal/services"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// zipFlagEncrypted marks a password-protected archive entry
+const zipFlagEncrypted = 0x1
+
+// errArchiveFolderEncrypted is returned for an archive directory that matches
+// an encrypted folder, whose files only the client can encrypt
+var errArchiveFolderEncrypted = errors.New("folder is encrypted")
+
+// archiveFolder is a folder that archive entries are extracted to
+type archiveFolder struct {
+	id   *uuid.UUID // nil for the root folder
+	path string
+}
+
+// archiveExtraction is an archive being unpacked into a folder
+type archiveExtraction struct {
+	userID uuid.UUID
+	checks uploadChecks
+	// folders maps each directory of the archive, with its folder names
+	// sanitized and joined by "/", to its folder; "" is the target folder
+	folders map[string]archiveFolder
+	outcome *uploadOutcome
+	stored  []FileUploadInfo
+}
+
+// checkExtractable refuses extract=true for uploads that are not a single
+// new archive
+func checkExtractable(req uploadRequest, encrypted bool) error {
+	var message string
+	switch {
+	case req.ReplaceTarget != nil:
+		message = "An archive cannot be extracted over an existing file"
+	case encrypted:
+		message = "An archive cannot be extracted into an encrypted folder"
+	case len(req.Parts) != 1:
+		message = "Exactly one archive must be uploaded to extract it"
+	default:
+		return nil
+	}
+	return &uploadError{http.StatusBadRequest, gin.H{"error": message, "code": "EXTRACT_NOT_SUPPORTED"}}
+}
+
+// extractUpload unpacks an uploaded ZIP archive into a folder, recreating
+// its directories as folders. Every file entry goes through the checks and
+// quotas of an upload and is stored on its own, so entries are deduplicated
+// against each other and a refused entry does not stop the rest. It answers
+// with the outcome of every entry; an entry that fails to store fails the
+// request, leaving the entries before it stored
+func (h *FileHandler) extractUpload(c *gin.Context, user *models.User, part uploadPart, folderID *uuid.UUID, checks uploadChecks) {
+	archiveName := part.Header.Filename
+
+	file, err := part.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to open file %s", archiveName),
+		})
+		return
+	}
+	staged, err := utils.StageReader(h.cfg.UploadTempDir, file, 0)
+	file.Close()
+	if err != nil {
+		publishStorageError(c, "Failed to stage upload "+archiveName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read file %s", archiveName),
+		})
+		return
+	}
+	defer os.Remove(staged.Path)
+
+	archiveFile, err := os.Open(staged.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read file %s", archiveName),
+		})
+		return
+	}
+	defer archiveFile.Close()
+
+	reader, err := zip.NewReader(archiveFile, staged.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    fmt.Sprintf("%s is not a valid ZIP archive", archiveName),
+			"code":     "INVALID_ARCHIVE",
+			"filename": archiveName,
+		})
+		return
+	}
+	if len(reader.File) > h.cfg.ZipMaxEntries {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       fmt.Sprintf("An archive may contain at most %d entries", h.cfg.ZipMaxEntries),
+			"code":        "ARCHIVE_TOO_MANY_ENTRIES",
+			"entry_count": len(reader.File),
+			"max_entries": h.cfg.ZipMaxEntries,
+		})
+		return
+	}
+
+	target := archiveFolder{id: folderID, path: "/"}
+	if folderID != nil {
+		var folder models.Folder
+		if err := h.db.WithContext(c.Request.Context()).Select("id", "path").First(&folder, "id = ?", *folderID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify folder"})
+			return
+		}
+		target.path = folder.Path
+	}
+
+	extraction := &archiveExtraction{
+		userID:  user.ID,
+		checks:  checks,
+		folders: map[string]archiveFolder{"": target},
+		outcome: &uploadOutcome{user: user, results: []*UploadResultDTO{}},
+	}
+	entries := make([]ArchiveEntryDTO, 0, len(reader.File))
+	for _, entry := range reader.File {
+		result, ok := h.extractEntry(c, extraction, entry)
+		if !ok {
+			return
+		}
+		entries = append(entries, result)
+	}
+
+	response := h.uploadResponse(c, extraction.stored, extraction.outcome)
+	response["message"] = "Archive extracted"
+	response["archive"] = archiveName
+	response["entries"] = entries
+	c.JSON(http.StatusOK, response)
+}
+
+// extractEntry extracts one entry of an archive. It returns false when the
+// request failed and has been answered
+func (h *FileHandler) extractEntry(c *gin.Context, x *archiveExtraction, entry *zip.File) (ArchiveEntryDTO, bool) {
+	result := ArchiveEntryDTO{Path: entry.Name}
+	invalid := func(code, message string) (ArchiveEntryDTO, bool) {
+		result.Status, result.Code, result.Message = ArchiveEntryInvalid, code, message
+		return result, true
+	}
+
+	segments, ok := archiveEntryPath(entry.Name)
+	if !ok {
+		return invalid("UNSAFE_PATH", "Entry path leads outside the target folder")
+	}
+	if len(segments) == 0 {
+		return invalid("INVALID_NAME", "Entry has no name")
+	}
+	isDir := entry.FileInfo().IsDir()
+	if !isDir && entry.Mode()&os.ModeType != 0 {
+		return invalid("UNSUPPORTED_ENTRY", "Only files and directories are extracted")
+	}
+	if entry.Flags&zipFlagEncrypted != 0 {
+		return invalid("ENCRYPTED_ENTRY", "Password-protected entries cannot be extracted")
+	}
+
+	dirs, name := segments, ""
+	if !isDir {
+		dirs, name = segments[:len(segments)-1], segments[len(segments)-1]
+	}
+	for i := range dirs {
+		if dirs[i] = sanitizeFolderName(dirs[i]); dirs[i] == "" {
+			return invalid("INVALID_NAME", "Entry path contains an invalid folder name")
+		}
+	}
+
+	folder, err := h.archiveFolder(c, x, dirs)
+	if errors.Is(err, errArchiveFolderEncrypted) {
+		result.Status, result.Code = ArchiveEntrySkipped, "ENCRYPTED_FOLDER"
+		result.Message = "Files cannot be extracted into an encrypted folder"
+		return result, true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder", "path": entry.Name})
+		return result, false
+	}
+	if isDir {
+		result.Status, result.FolderID = ArchiveEntryFolder, folder.id
+		return result, true
+	}
+
+	header := &multipart.FileHeader{Filename: name, Header: textproto.MIMEHeader{}}
+	header.Header.Set("Content-Type", x.checks.validator.GetMimeTypeFromExtension(name))
+
+	// Skip an oversized entry before inflating it. The limit is applied
+	// while it is read too, since the declared size may be wrong
+	if entry.UncompressedSize64 > uint64(x.checks.maxFileSize) {
+		h.recordRejection(c, x.userID, models.UploadRejection{
+			Reason:           models.RejectionSizeExceeded,
+			Message:          fmt.Sprintf("File exceeds the maximum size of %d bytes", x.checks.maxFileSize),
+			Filename:         name,
+			DeclaredMimeType: header.Header.Get("Content-Type"),
+			Size:             int64(entry.UncompressedSize64),
+		})
+		result.Status, result.Code = ArchiveEntrySkipped, "FILE_TOO_LARGE"
+		result.Message = fmt.Sprintf("File exceeds the maximum size of %d bytes", x.checks.maxFileSize)
+		return result, true
+	}
+
+	content, err := entry.Open()
+	if errors.Is(err, zip.ErrAlgorithm) {
+		return invalid("UNSUPPORTED_COMPRESSION", "Entry uses an unsupported compression method")
+	}
+	if err != nil {
+		return invalid("CORRUPT_ENTRY", "Entry cannot be read")
+	}
+	staged, err := utils.StageReader(h.cfg.UploadTempDir, io.LimitReader(content, x.checks.maxFileSize+1), x.checks.validator.SniffLength())
+	content.Close()
+	if err != nil {
+		if corruptArchiveEntry(err) {
+			return invalid("CORRUPT_ENTRY", "Entry content is corrupt")
+		}
+		publishStorageError(c, "Failed to stage archive entry "+entry.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extract archive entry", "path": entry.Name})
+		return result, false
+	}
+	header.Size = staged.Size
+
+	files := []FileUploadInfo{{
+		Header:   header,
+		TempPath: staged.Path,
+		Size:     staged.Size,
+		Hash:     staged.Hash,
+	}}
+	// Storage moves the staged content away unless it was a duplicate, and
+	// clears the path of content it failed to move, which recovery retries
+	defer func() {
+		if files[0].TempPath != "" {
+			os.Remove(files[0].TempPath)
+		}
+	}()
+	uploadFile := &files[0]
+
+	skip := func(rejection models.UploadRejection) (ArchiveEntryDTO, bool) {
+		if rejection.Filename == "" {
+			rejection.Filename = name
+			rejection.DeclaredMimeType = header.Header.Get("Content-Type")
+			rejection.DetectedMimeType = uploadFile.MimeType
+			rejection.Size = uploadFile.Size
+		}
+		h.recordRejection(c, x.userID, rejection)
+
+		result.Status, result.Code, result.Message = ArchiveEntrySkipped, rejection.Code, rejection.Message
+		if result.Code == "" {
+			result.Code = strings.ToUpper(string(rejection.Reason))
+		}
+		return result, true
+	}
+
+	if denial := h.inspectUploadFile(c, x.userID, uploadFile, staged, x.checks); denial != nil {
+		return skip(denial.rejection)
+	}
+	uploadFile.FolderID = folder.id
+
+	// The quotas are checked against the usage of the entries stored so far
+	user := x.outcome.user
+	if quotaLimit := h.quotaGraceService.Limit(user); user.StorageUsed+uploadFile.Size > quotaLimit {
+		return skip(models.UploadRejection{
+			Reason:  models.RejectionQuotaExceeded,
+			Code:    "QUOTA_EXCEEDED",
+			Message: fmt.Sprintf("Upload of %d bytes exceeds remaining quota of %d bytes", uploadFile.Size, quotaLimit-user.StorageUsed),
+		})
+	}
+	if err := h.tenantService.CheckQuota(user.TenantID, uploadFile.Size); err != nil {
+		var quotaErr *services.TenantQuotaError
+		if !errors.As(err, &quotaErr) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization storage quota"})
+			return result, false
+		}
+		return skip(models.UploadRejection{
+			Reason:  models.RejectionQuotaExceeded,
+			Code:    "TENANT_QUOTA_EXCEEDED",
+			Message: quotaErr.Error(),
+		})
+	}
+	if err := services.CheckFolderQuota(h.db, uploadFile.FolderID, uploadFile.Size); err != nil {
+		var quotaErr *services.FolderQuotaError
+		if !errors.As(err, &quotaErr) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check folder size limit"})
+			return result, false
+		}
+		return skip(models.UploadRejection{
+			Reason:  models.RejectionQuotaExceeded,
+			Code:    "FOLDER_QUOTA_EXCEEDED",
+			Message: quotaErr.Error(),
+		})
+	}
+
+	saved, err := h.saveUploads(c, x.userID, files, x.checks.isPublic, nil, "")
+	if err != nil {
+		respondUploadError(c, err)
+		return result, false
+	}
+	x.outcome.results = append(x.outcome.results, saved.results...)
+	x.outcome.user = saved.user
+	x.outcome.totalUploadedBytes += saved.totalUploadedBytes
+	x.outcome.totalSavedBytes += saved.totalSavedBytes
+	x.outcome.totalStorageCharged += saved.totalStorageCharged
+	x.stored = append(x.stored, *uploadFile)
+
+	result.Status, result.File = ArchiveEntryUploaded, saved.results[0]
+	return result, true
+}
+
+// archiveFolder returns the folder an archive directory is extracted to,
+// given by its sanitized folder names. A folder of the same name already in
+// place is reused; missing folders are created, along with those above them
+func (h *FileHandler) archiveFolder(c *gin.Context, x *archiveExtraction, dirs []string) (archiveFolder, error) {
+	key := strings.Join(dirs, "/")
+	if folder, ok := x.folders[key]; ok {
+		return folder, nil
+	}
+	parent, err := h.archiveFolder(c, x, dirs[:len(dirs)-1])
+	if err != nil {
+		return archiveFolder{}, err
+	}
+	name := dirs[len(dirs)-1]
+
+	db := h.db.WithContext(c.Request.Context())
+	query := db.Where("owner_id = ? AND name = ?", x.userID, name)
+	if parent.id == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parent.id)
+	}
+
+	var existing models.Folder
+	err = query.First(&existing).Error
+	switch {
+	case err == nil:
+		if existing.IsEncrypted() {
+			return archiveFolder{}, errArchiveFolderEncrypted
+		}
+		x.folders[key] = archiveFolder{id: &existing.ID, path: existing.Path}
+		return x.folders[key], nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return archiveFolder{}, err
+	}
+
+	folder := models.Folder{
+		BaseModel: models.BaseModel{
+			ID: uuid.New(),
+		},
+		Name:     name,
+		ParentID: parent.id,
+		OwnerID:  x.userID,
+		Path:     "/" + name,
+	}
+	if parent.path != "/" {
+		folder.Path = parent.path + "/" + name
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&folder).Error; err != nil {
+			return err
+		}
+		return services.CreateFolderStats(tx, &folder)
+	}); err != nil {
+		return archiveFolder{}, err
+	}
+
+	x.folders[key] = archiveFolder{id: &folder.ID, path: folder.Path}
+	return x.folders[key], nil
+}
+
+// archiveEntryPath splits an archive entry's path into its names. It
+// returns false for a path that is absolute or climbs out with "..", which
+// would place the entry outside the target folder
+func archiveEntryPath(name string) ([]string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return nil, false
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(name, "/") {
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			return nil, false
+		}
+		segments = append(segments, segment)
+	}
+	return segments, true
+}
+
+// corruptArchiveEntry reports whether extracting an entry failed on its
+// content rather than on the server
+func corruptArchiveEntry(err error) bool {
+	var flateErr flate.CorruptInputError
+	return errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &flateErr)
+}
@@ -18,11 +18,13 @@ import (
 
 // JWTClaims represents the claims in a JWT token
 type JWTClaims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
-	Role     string    `json:"role"`  // Simple role field
-	Roles    []string  `json:"roles"` // Complex roles array (keeping for backward compatibility)
+	UserID   uuid.UUID  `json:"user_id"`
+	Username string     `json:"username"`
+	Email    string     `json:"email"`
+	Role     string     `json:"role"`                // Simple role field
+	Roles    []string   `json:"roles"`               // Complex roles array (keeping for backward compatibility)
+	DeviceID uuid.UUID  `json:"device_id"`           // device the token was issued to; zero for older tokens
+	TenantID *uuid.UUID `json:"tenant_id,omitempty"` // tenant the user belongs to; nil for platform users
 	jwt.RegisteredClaims
 }
 
@@ -65,6 +67,23 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// A token only works for its own tenant, so one organization's
+		// sessions cannot be replayed against another
+		if !models.SameTenant(claims.TenantID, TenantID(c)) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Token does not belong to this tenant",
+				"code":  "TENANT_MISMATCH",
+			})
+			c.Abort()
+			return
+		}
+
+		// Tokens bound to a revoked device stop working immediately
+		if !checkDevice(c, claims) {
+			c.Abort()
+			return
+		}
+
 		// Set user context
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
@@ -116,6 +135,24 @@ func RequireAdmin() gin.HandlerFunc {
 	return RequireRole("admin")
 }
 
+// RestrictGuests lets guest accounts read what is shared with them but
+// rejects every other request method, so guests cannot upload, share or
+// change anything
+func RestrictGuests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") == string(models.RoleGuest) &&
+			c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Guest accounts can only view items shared with them",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // AdminMiddleware validates admin access
 func AdminMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -150,7 +187,7 @@ func AdminMiddleware(db *gorm.DB) gin.HandlerFunc {
 
 		// Verify admin status in database
 		var user models.User
-		if err := db.First(&user, userID).Error; err != nil {
+		if err := db.WithContext(c.Request.Context()).First(&user, userID).Error; err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "User not found",
 			})
@@ -172,14 +209,7 @@ func AdminMiddleware(db *gorm.DB) gin.HandlerFunc {
 
 // ValidateJWTToken validates a JWT token and returns claims
 func ValidateJWTToken(tokenString string) (*JWTClaims, error) {
-	jwtSecret := utils.GetEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production")
-
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, signingKeyFor)
 
 	if err != nil {
 		return nil, err
@@ -211,6 +241,8 @@ func RefreshJWTToken(tokenString string) (string, error) {
 		Email:    claims.Email,
 		Role:     claims.Role,
 		Roles:    claims.Roles,
+		DeviceID: claims.DeviceID,
+		TenantID: claims.TenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -220,14 +252,11 @@ func RefreshJWTToken(tokenString string) (string, error) {
 		},
 	}
 
-	jwtSecret := utils.GetEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
-	return token.SignedString([]byte(jwtSecret))
+	return SignJWT(newClaims)
 }
 
 // GenerateJWTToken creates a new JWT token for a user
 func GenerateJWTToken(user *models.User, roles []string) (string, error) {
-	jwtSecret := utils.GetEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production")
 	expirationHours := 24 // Default 24 hours
 
 	if expStr := utils.GetEnv("JWT_EXPIRATION", "24"); expStr != "" {
@@ -242,6 +271,7 @@ func GenerateJWTToken(user *models.User, roles []string) (string, error) {
 		Email:    user.Email,
 		Role:     string(user.Role), // Add simple role
 		Roles:    roles,             // Keep complex roles for backward compatibility
+		TenantID: user.TenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expirationHours) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -251,8 +281,7 @@ func GenerateJWTToken(user *models.User, roles []string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+	return SignJWT(claims)
 }
 
 // GetUserFromContext extracts user information from Gin context
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"file-vault-system/backend/internal/config"
+)
+
+// ClientCountry stores the two-letter country code that a CDN or proxy in
+// front of the server reports in cfg.CountryHeader as "client_country".
+// Values that are not a country code are ignored.
+func ClientCountry(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.CountryHeader != "" {
+			country := strings.ToUpper(strings.TrimSpace(c.GetHeader(cfg.CountryHeader)))
+			if isCountryCode(country) {
+				c.Set("client_country", country)
+			}
+		}
+		c.Next()
+	}
+}
+
+// isCountryCode reports whether value looks like an ISO 3166-1 alpha-2 code
+func isCountryCode(value string) bool {
+	if len(value) != 2 {
+		return false
+	}
+	for _, r := range value {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
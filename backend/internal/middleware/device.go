@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"file-vault-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// deviceSeenInterval limits how often a device's last-seen time is written
+const deviceSeenInterval = time.Minute
+
+// deviceDB is set by InitializeDeviceTracking; device checks are skipped
+// while it is nil
+var deviceDB *gorm.DB
+
+// InitializeDeviceTracking makes AuthMiddleware reject tokens of revoked
+// devices and record when and from where each device was last seen
+func InitializeDeviceTracking(db *gorm.DB) {
+	deviceDB = db
+}
+
+// checkDevice validates the device a token is bound to, writing the error
+// response when it has been revoked. Tokens issued before devices were
+// tracked carry no device and pass
+func checkDevice(c *gin.Context, claims *JWTClaims) bool {
+	if deviceDB == nil || claims.DeviceID == uuid.Nil {
+		return true
+	}
+
+	var device models.Device
+	if err := deviceDB.Select("id", "client", "revoked_at", "last_seen_at", "last_ip").
+		Where("id = ? AND user_id = ?", claims.DeviceID, claims.UserID).
+		First(&device).Error; err != nil || device.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "This device has been signed out",
+			"code":  "DEVICE_REVOKED",
+		})
+		return false
+	}
+
+	now := time.Now()
+	ip := StoredClientIP(c)
+	if now.Sub(device.LastSeenAt) >= deviceSeenInterval || device.LastIP != ip {
+		deviceDB.Model(&models.Device{}).Where("id = ?", device.ID).
+			Updates(map[string]interface{}{"last_seen_at": now, "last_ip": ip})
+	}
+
+	c.Set("device_id", device.ID)
+	c.Set("device_client", string(device.Client))
+	return true
+}
+
+// RequireSyncDevice only admits tokens issued to a registered sync client
+func RequireSyncDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("device_client") != string(models.DeviceClientSync) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Sync clients must log in with client \"sync\" and register a device name and platform",
+				"code":  "SYNC_DEVICE_REQUIRED",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrorHandler writes the response for an error a handler attached with
+// c.Error and did not answer itself. Catalog errors map to their status and
+// body; anything else is reported as a generic internal error, so database
+// messages and file paths never reach clients.
+//
+// The cause is logged with an error ID that is also returned in the body and
+// the X-Error-ID header, so a user's report can be matched to the log entry.
+// Server errors are always logged; other failures only in debug mode.
+func ErrorHandler(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		respondError(c, cfg, c.Errors.Last().Err)
+	}
+}
+
+// QueryTimeouts cancels a request's queries when the client disconnects and
+// answers requests whose queries timed out with 503 and a Retry-After hint.
+// Handlers that report such a failure as a generic 500 have their response
+// replaced, so clients can tell a busy server from a broken request.
+func QueryTimeouts(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, tracker := database.TrackTimeouts(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		writer := &timeoutResponseWriter{ResponseWriter: c.Writer, tracker: tracker}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.suppressed {
+			respondError(c, cfg, apperrors.ErrTimeout.Wrap(errors.New("database query timed out")))
+		}
+	}
+}
+
+// timeoutResponseWriter holds back a server error response once one of the
+// request's queries has timed out
+type timeoutResponseWriter struct {
+	gin.ResponseWriter
+	tracker    *database.TimeoutTracker
+	suppressed bool
+}
+
+func (w *timeoutResponseWriter) WriteHeader(code int) {
+	if code >= 500 && !w.Written() && w.tracker.TimedOut() {
+		w.suppressed = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutResponseWriter) WriteHeaderNow() {
+	if !w.suppressed {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutResponseWriter) Write(data []byte) (int, error) {
+	if w.suppressed {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutResponseWriter) WriteString(s string) (int, error) {
+	if w.suppressed {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// respondError writes the catalog response for err, logging its cause
+func respondError(c *gin.Context, cfg *config.Config, err error) {
+	appErr := apperrors.From(err)
+	body := appErr.Body()
+
+	if errors.Is(appErr, apperrors.ErrTimeout) && cfg.DBTimeoutRetryAfter > 0 {
+		body["retry_after"] = cfg.DBTimeoutRetryAfter
+		c.Header("Retry-After", strconv.Itoa(cfg.DBTimeoutRetryAfter))
+	}
+
+	if appErr.Status >= 500 || cfg.DebugMode {
+		errorID := uuid.New().String()
+		body["error_id"] = errorID
+		c.Header("X-Error-ID", errorID)
+
+		userID := "-"
+		if uid, exists := c.Get("user_id"); exists {
+			if id, ok := uid.(uuid.UUID); ok {
+				userID = id.String()
+			}
+		}
+		log.Printf("request failed error_id=%s status=%d code=%s method=%s path=%s user_id=%s error=%q",
+			errorID, appErr.Status, appErr.Code, c.Request.Method, c.Request.URL.Path, userID, err.Error())
+	}
+
+	c.JSON(appErr.Status, body)
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/utils"
+)
+
+const (
+	// signingKeysTTL bounds how long a rotation made on another server takes
+	// to apply; rotations made through this server apply at once
+	signingKeysTTL = time.Minute
+
+	// signingKeysMissTTL limits reloads for tokens naming an unknown key, so
+	// forged kids cannot make every request query the database
+	signingKeysMissTTL = 5 * time.Second
+)
+
+// keyRing holds the signing keys so tokens are signed and checked without
+// querying the database on every request
+type keyRing struct {
+	mu       sync.RWMutex
+	db       *gorm.DB
+	secret   string
+	keys     map[string]models.SigningKey
+	current  *models.SigningKey
+	loadedAt time.Time
+}
+
+var signingKeys = &keyRing{}
+
+// InitializeSigningKeys makes login tokens be signed with the current key
+// in jwt_signing_keys, named by their kid header, and checked against every
+// key still valid. Without it tokens are signed with JWT_SECRET and carry
+// no kid.
+func InitializeSigningKeys(db *gorm.DB, cfg *config.Config) {
+	signingKeys.mu.Lock()
+	defer signingKeys.mu.Unlock()
+	signingKeys.db = db
+	signingKeys.secret = cfg.JWTSecret
+	signingKeys.loadedAt = time.Time{}
+}
+
+// ReloadSigningKeys reads the signing keys again after they were rotated
+func ReloadSigningKeys() error {
+	signingKeys.mu.Lock()
+	defer signingKeys.mu.Unlock()
+	return signingKeys.load()
+}
+
+// load reads the keys; the caller holds the write lock
+func (r *keyRing) load() error {
+	if r.db == nil {
+		return nil
+	}
+	r.loadedAt = time.Now()
+
+	var keys []models.SigningKey
+	if err := r.db.Order("created_at").Find(&keys).Error; err != nil {
+		return fmt.Errorf("error loading signing keys: %w", err)
+	}
+	r.keys = make(map[string]models.SigningKey, len(keys))
+	r.current = nil
+	for i := range keys {
+		r.keys[keys[i].ID] = keys[i]
+		if keys[i].Current() {
+			r.current = &keys[i]
+		}
+	}
+	return nil
+}
+
+// refresh reloads the keys once they are older than ttl, keeping the last
+// known keys when the database cannot be read
+func (r *keyRing) refresh(ttl time.Duration) {
+	r.mu.RLock()
+	stale := r.db != nil && time.Since(r.loadedAt) > ttl
+	r.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.loadedAt) > ttl {
+		if err := r.load(); err != nil {
+			log.Printf("Failed to reload signing keys: %v", err)
+		}
+	}
+}
+
+// lookup returns the key a token names and the secret it was signed with
+func (r *keyRing) lookup(kid string) (models.SigningKey, []byte, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[kid]
+	if !ok {
+		return key, nil, false
+	}
+	return key, deriveSigningKey(r.secret, &key), true
+}
+
+// deriveSigningKey returns the HMAC key of a signing key. The legacy key,
+// which has no salt, is JWT_SECRET itself
+func deriveSigningKey(secret string, key *models.SigningKey) []byte {
+	if key.Salt == "" {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("jwt-signing-key\x00" + key.ID + "\x00" + key.Salt))
+	return mac.Sum(nil)
+}
+
+// legacyJWTSecret is the secret tokens are signed with before
+// InitializeSigningKeys is called
+func legacyJWTSecret() []byte {
+	return []byte(utils.GetEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"))
+}
+
+// SignJWT signs claims with the current signing key and names it in the
+// token's kid header
+func SignJWT(claims jwt.Claims) (string, error) {
+	r := signingKeys
+	r.refresh(signingKeysTTL)
+
+	r.mu.RLock()
+	db, current := r.db, r.current
+	var secret []byte
+	if current != nil {
+		secret = deriveSigningKey(r.secret, current)
+	}
+	r.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if db == nil {
+		return token.SignedString(legacyJWTSecret())
+	}
+	if current == nil {
+		return "", fmt.Errorf("no current signing key")
+	}
+	if current.ID != models.LegacySigningKeyID {
+		token.Header["kid"] = current.ID
+	}
+	return token.SignedString(secret)
+}
+
+// signingKeyFor is the jwt.Keyfunc of login tokens. It accepts tokens
+// signed with the current key or with a replaced key that has not expired;
+// tokens without a kid were signed with the legacy key
+func signingKeyFor(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = models.LegacySigningKeyID
+	}
+
+	r := signingKeys
+	r.mu.RLock()
+	initialized := r.db != nil
+	r.mu.RUnlock()
+	if !initialized {
+		if kid != models.LegacySigningKeyID {
+			return nil, fmt.Errorf("unknown signing key")
+		}
+		return legacyJWTSecret(), nil
+	}
+
+	r.refresh(signingKeysTTL)
+	key, secret, ok := r.lookup(kid)
+	if !ok {
+		// The key may have been created on another server since the last load
+		r.refresh(signingKeysMissTTL)
+		if key, secret, ok = r.lookup(kid); !ok {
+			return nil, fmt.Errorf("unknown signing key")
+		}
+	}
+	if !key.Valid(time.Now()) {
+		return nil, fmt.Errorf("signing key has expired")
+	}
+	return secret, nil
+}
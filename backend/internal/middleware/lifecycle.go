@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"file-vault-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// readOnlyPosts are POST routes that only read, so read-only accounts may
+// still search and download their files
+var readOnlyPosts = []string{"/search", "/download-zip"}
+
+// RestrictReadOnly rejects requests that would change anything for accounts
+// the inactivity policy has made read-only. Signing in again lifts the state.
+func RestrictReadOnly(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if c.Request.Method == http.MethodPost {
+			for _, suffix := range readOnlyPosts {
+				if strings.HasSuffix(c.FullPath(), suffix) {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		uid, _ := c.Get("user_id")
+		userID, ok := uid.(uuid.UUID)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var user models.User
+		if err := db.WithContext(c.Request.Context()).Select("lifecycle_state").Where("id = ?", userID).Take(&user).Error; err == nil &&
+			user.LifecycleState == models.LifecycleReadOnly {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Account is read-only",
+				"type":    "account_read_only",
+				"message": "Your account was made read-only after a long period of inactivity. Sign in again to restore full access.",
+				"code":    "ACCOUNT_READ_ONLY",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"file-vault-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PolicyChecker reports which current policies a user has not accepted
+type PolicyChecker interface {
+	PendingPolicies(userID uuid.UUID) ([]models.PolicyDocument, error)
+}
+
+// RequirePolicyAcceptance blocks the request until the user has accepted the
+// current terms-of-service and privacy policy versions
+func RequirePolicyAcceptance(checker PolicyChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDInterface, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Authentication required",
+				"type":    "AUTHENTICATION_REQUIRED",
+				"message": "You must be logged in to perform this action",
+				"code":    "AUTH_REQUIRED",
+			})
+			c.Abort()
+			return
+		}
+
+		pending, err := checker.PendingPolicies(userIDInterface.(uuid.UUID))
+		if err != nil {
+			fmt.Printf("Failed to check policy acceptance: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to check policy acceptance",
+				"type":    "SERVER_ERROR",
+				"message": "Could not verify that you have accepted the current policies. Please try again",
+				"code":    "POLICY_CHECK_FAILED",
+			})
+			c.Abort()
+			return
+		}
+
+		if len(pending) > 0 {
+			summaries := make([]gin.H, len(pending))
+			for i, doc := range pending {
+				summaries[i] = gin.H{
+					"id":      doc.ID,
+					"type":    doc.Type,
+					"version": doc.Version,
+					"title":   doc.Title,
+				}
+			}
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":            "Policy acceptance required",
+				"type":             "POLICY_ACCEPTANCE_REQUIRED",
+				"message":          "You must accept the current terms of service and privacy policy before uploading",
+				"code":             "POLICY_ACCEPTANCE_REQUIRED",
+				"pending_policies": summaries,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+
+	"file-vault-system/backend/internal/config"
+)
+
+// ipPrefixes holds the bits of IPv4 and IPv6 addresses kept by AnonymizeIP.
+// It is set by InitializePrivacy; addresses are kept whole while it is nil
+var ipPrefixes *[2]int
+
+// anonymousTracking is cfg.AnonymousAccessTracking, set by InitializePrivacy
+var anonymousTracking = "full"
+
+// InitializePrivacy makes StoredClientIP and AnonymizeIP truncate addresses
+// to the configured prefixes when cfg.AnonymizeIPs is set, and
+// StoredClientIP and StoredUserAgent withhold the client details of
+// anonymous visitors unless cfg.AnonymousAccessTracking is "full"
+func InitializePrivacy(cfg *config.Config) {
+	if cfg.AnonymizeIPs {
+		ipPrefixes = &[2]int{cfg.AnonymizeIPv4Prefix, cfg.AnonymizeIPv6Prefix}
+	}
+	anonymousTracking = cfg.AnonymousAccessTracking
+}
+
+// AnonymousAccessTracking returns what is kept of visits to share links and
+// public files: "full", "minimal" or "counts"
+func AnonymousAccessTracking() string {
+	return anonymousTracking
+}
+
+// AnonymousAccess marks the routes anonymous visitors reach, share links and
+// public files, so the client details of their requests are only kept as
+// AnonymousAccessTracking allows
+func AnonymousAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("anonymous_access", true)
+		c.Next()
+	}
+}
+
+// withholdsClient reports whether the client details of the request must
+// not be kept
+func withholdsClient(c *gin.Context) bool {
+	return anonymousTracking != "full" && c.GetBool("anonymous_access")
+}
+
+// AnonymizeIP truncates an address to its network when IP anonymization is
+// on, so 203.0.113.57 is kept as 203.0.113.0 with a /24 prefix. Values that
+// are not an address are returned unchanged
+func AnonymizeIP(ip string) string {
+	if ipPrefixes == nil {
+		return ip
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+	bits := ipPrefixes[1]
+	if addr.Is4() {
+		bits = ipPrefixes[0]
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.Addr().String()
+}
+
+// StoredClientIP returns the client address to record in logs, history and
+// notifications: the client IP, anonymized when privacy mode is on, or empty
+// for anonymous visitors whose details are withheld. Rate limiting and other
+// checks that need the exact address use c.ClientIP()
+func StoredClientIP(c *gin.Context) string {
+	if withholdsClient(c) {
+		return ""
+	}
+	return AnonymizeIP(c.ClientIP())
+}
+
+// StoredUserAgent returns the user agent to record alongside StoredClientIP,
+// empty for anonymous visitors whose details are withheld
+func StoredUserAgent(c *gin.Context) string {
+	if withholdsClient(c) {
+		return ""
+	}
+	return c.GetHeader("User-Agent")
+}
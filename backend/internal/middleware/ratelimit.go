@@ -2,12 +2,17 @@ package middleware
 
 import (
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/events"
 	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -46,14 +51,32 @@ func (rl *RateLimiter) GetLimiter(key string) *rate.Limiter {
 	return limiter
 }
 
+// GetLimiterWithRate returns the limiter for a key sized to the given rate and
+// burst, resizing an existing limiter when the limits have changed
+func (rl *RateLimiter) GetLimiterWithRate(key string, r rate.Limit, b int) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	limiter, exists := rl.limiters[key]
+	if !exists {
+		limiter = rate.NewLimiter(r, b)
+		rl.limiters[key] = limiter
+	} else if limiter.Limit() != r || limiter.Burst() != b {
+		limiter.SetLimit(r)
+		limiter.SetBurst(b)
+	}
+
+	return limiter
+}
+
 // CleanupOldLimiters removes unused limiters to prevent memory leaks
 func (rl *RateLimiter) CleanupOldLimiters() {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	for key, limiter := range rl.limiters {
-		// Remove limiters that haven't been used in the last hour
-		if limiter.Tokens() == float64(rl.burst) {
+		// Remove limiters that have refilled completely, so are not in use
+		if limiter.Tokens() >= float64(limiter.Burst()) {
 			delete(rl.limiters, key)
 		}
 	}
@@ -72,24 +95,27 @@ func InitializeRateLimiter(cfg *config.Config) {
 		defer ticker.Stop()
 		for range ticker.C {
 			globalRateLimiter.CleanupOldLimiters()
+			rateLimitRejections.cleanup(time.Now())
 		}
 	}()
 }
 
+// skipRateLimit reports whether a request bypasses the server-wide limiters:
+// health checks, public file access, file and folder listings and auth
+// endpoints. Admins are exempted by role in serverLimitFor, not by path.
+func skipRateLimit(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	return path == "/health" ||
+		strings.HasPrefix(path, "/public-files") ||
+		(c.Request.Method == "GET" && (path == "/api/v1/files" || path == "/api/v1/files/")) ||
+		(c.Request.Method == "GET" && (path == "/api/v1/folders" || path == "/api/v1/folders/")) ||
+		strings.HasPrefix(path, "/api/v1/auth/")
+}
+
 // RateLimit middleware implements rate limiting per user with configurable limits
 func RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip rate limiting for health check, public file access, file listing operations, auth endpoints, and admin endpoints
-		if c.Request.URL.Path == "/health" ||
-			(len(c.Request.URL.Path) > 12 && c.Request.URL.Path[:13] == "/public-files") ||
-			// Skip rate limiting for GET requests to file listing endpoints
-			(c.Request.Method == "GET" && (c.Request.URL.Path == "/api/v1/files" || c.Request.URL.Path == "/api/v1/files/")) ||
-			// Skip rate limiting for folder listing endpoints
-			(c.Request.Method == "GET" && (c.Request.URL.Path == "/api/v1/folders" || c.Request.URL.Path == "/api/v1/folders/")) ||
-			// Skip rate limiting for auth endpoints (login, register, logout)
-			(len(c.Request.URL.Path) > 12 && c.Request.URL.Path[:13] == "/api/v1/auth/") ||
-			// Skip rate limiting for admin endpoints
-			(len(c.Request.URL.Path) > 13 && c.Request.URL.Path[:14] == "/api/v1/admin/") {
+		if skipRateLimit(c) {
 			c.Next()
 			return
 		}
@@ -100,24 +126,21 @@ func RateLimit() gin.HandlerFunc {
 			return
 		}
 
-		// Get user ID from context (if authenticated)
-		var userKey string
-		if uid, exists := c.Get("user_id"); exists {
-			if id, ok := uid.(uuid.UUID); ok {
-				userKey = fmt.Sprintf("user:%s", id.String())
+		// Signed-in users get their own bucket and any override; everyone
+		// else is limited by IP address
+		key := fmt.Sprintf("ip:%s", c.ClientIP())
+		limit, burst := float64(globalRateLimiter.rate), globalRateLimiter.burst
+		if userID, role, ok := rateLimitCaller(c); ok {
+			var exempt bool
+			if limit, burst, exempt = serverLimitFor(userID, role, limit, burst); exempt {
+				c.Next()
+				return
 			}
+			key = fmt.Sprintf("user:%s", userID)
 		}
 
-		// Use IP address if no user ID available
-		if userKey == "" {
-			userKey = fmt.Sprintf("ip:%s", c.ClientIP())
-		}
-
-		// Create a unique key for this user
-		key := userKey
-
 		// Get rate limiter for this key
-		limiter := globalRateLimiter.GetLimiter(key)
+		limiter := globalRateLimiter.GetLimiterWithRate(key, rate.Limit(limit), burst)
 
 		// Check if request is allowed
 		if !limiter.Allow() {
@@ -126,7 +149,9 @@ func RateLimit() gin.HandlerFunc {
 			retryAfter := int(reservation.Delay().Seconds()) + 1
 			reservation.Cancel() // Cancel the reservation since we're rejecting
 
-			c.Header("X-RateLimit-Limit", fmt.Sprintf("%v", globalRateLimiter.rate))
+			publishRateLimited(c, RateLimitClassGlobal, key, limit)
+
+			c.Header("X-RateLimit-Limit", fmt.Sprintf("%v", limit))
 			c.Header("X-RateLimit-Remaining", "0")
 			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Duration(retryAfter)*time.Second).Unix()))
 			c.Header("Retry-After", fmt.Sprintf("%d", retryAfter))
@@ -134,9 +159,9 @@ func RateLimit() gin.HandlerFunc {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded",
 				"type":        "RATE_LIMIT_EXCEEDED",
-				"message":     fmt.Sprintf("Too many requests. You have exceeded the limit of %.0f calls per %d second(s). Please try again later.", float64(globalRateLimiter.rate), 1),
+				"message":     fmt.Sprintf("Too many requests. You have exceeded the limit of %.0f calls per %d second(s). Please try again later.", limit, 1),
 				"retry_after": retryAfter,
-				"limit":       float64(globalRateLimiter.rate),
+				"limit":       limit,
 				"window":      1,
 				"code":        "RATE_LIMIT_ERROR",
 			})
@@ -145,72 +170,68 @@ func RateLimit() gin.HandlerFunc {
 		}
 
 		// Add rate limit headers for successful requests
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%v", globalRateLimiter.rate))
+		c.Header("X-RateLimit-Limit", fmt.Sprintf("%v", limit))
 		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%.0f", limiter.Tokens()))
 
 		c.Next()
 	}
 }
 
+// countRequestSQL counts a request against its (user, endpoint) window,
+// starting a new window once the stored one has ended. The limit always
+// follows the current configuration and overrides. It returns the window.
+func countRequestSQL(db *gorm.DB) string {
+	// The stored window ended before this request; window_duration is in
+	// nanoseconds
+	windowEnded := database.TimePlusNanos(db, "api_rate_limits.window_start", "api_rate_limits.window_duration") +
+		" <= " + database.Time(db, "EXCLUDED.window_start")
+
+	return `
+INSERT INTO api_rate_limits (user_id, endpoint, request_count, window_start, window_duration, max_requests)
+VALUES (?, ?, 1, ?, ?, ?)
+ON CONFLICT (user_id, endpoint) DO UPDATE SET
+    request_count = CASE WHEN ` + windowEnded + ` THEN 1 ELSE api_rate_limits.request_count + 1 END,
+    window_start = CASE WHEN ` + windowEnded + ` THEN EXCLUDED.window_start ELSE api_rate_limits.window_start END,
+    window_duration = CASE WHEN ` + windowEnded + ` THEN EXCLUDED.window_duration ELSE api_rate_limits.window_duration END,
+    max_requests = EXCLUDED.max_requests
+RETURNING request_count, window_start, window_duration, max_requests`
+}
+
 // DatabaseRateLimit middleware uses database to track rate limits with configurable settings
 func DatabaseRateLimit(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip rate limiting for health check, public file access, file listing operations, auth endpoints, and admin endpoints
-		if c.Request.URL.Path == "/health" ||
-			(len(c.Request.URL.Path) > 12 && c.Request.URL.Path[:13] == "/public-files") ||
-			// Skip rate limiting for GET requests to file listing endpoints
-			(c.Request.Method == "GET" && (c.Request.URL.Path == "/api/v1/files" || c.Request.URL.Path == "/api/v1/files/")) ||
-			// Skip rate limiting for folder listing endpoints
-			(c.Request.Method == "GET" && (c.Request.URL.Path == "/api/v1/folders" || c.Request.URL.Path == "/api/v1/folders/")) ||
-			// Skip rate limiting for auth endpoints (login, register, logout)
-			(len(c.Request.URL.Path) > 12 && c.Request.URL.Path[:13] == "/api/v1/auth/") ||
-			// Skip rate limiting for admin endpoints
-			(len(c.Request.URL.Path) > 13 && c.Request.URL.Path[:14] == "/api/v1/admin/") {
+		if skipRateLimit(c) {
 			c.Next()
 			return
 		}
 
-		// Get user ID from context
-		userIDInterface, exists := c.Get("user_id")
-		if !exists {
-			// For unauthenticated requests, use the in-memory rate limiter
+		// Only signed-in users are counted here
+		userID, role, ok := rateLimitCaller(c)
+		if !ok {
 			c.Next()
 			return
 		}
 
-		userID, ok := userIDInterface.(uuid.UUID)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Invalid user ID",
-				"type":  "server_error",
-			})
-			c.Abort()
+		limit, _, exempt := serverLimitFor(userID, role, float64(cfg.RateLimit), cfg.RateLimitBurst)
+		if exempt {
+			c.Next()
 			return
 		}
 
-		endpoint := c.Request.URL.Path
+		// Count per route rather than per path, so IDs in the URL do not
+		// create a window for every file
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			endpoint = "unmatched"
+		}
 		now := time.Now()
 		windowDuration := time.Duration(cfg.RateLimitWindow) * time.Second
-		maxRequests := cfg.RateLimit
-
-		// Check current rate limit status
-		var rateLimit models.APIRateLimit
-		result := db.Where("user_id = ? AND endpoint = ?", userID, endpoint).First(&rateLimit)
-
-		if result.Error == gorm.ErrRecordNotFound {
-			// Create new rate limit record
-			rateLimit = models.APIRateLimit{
-				UserID:         userID,
-				Endpoint:       endpoint,
-				RequestCount:   1,
-				WindowStart:    now,
-				WindowDuration: windowDuration,
-				MaxRequests:    maxRequests,
-			}
-			db.Create(&rateLimit)
-			c.Next()
-			return
-		} else if result.Error != nil {
+		maxRequests := int(math.Ceil(limit))
+
+		// Count the request and read back its window in one statement, so
+		// concurrent requests can neither lose counts nor create duplicates
+		var window models.APIRateLimit
+		if err := db.WithContext(c.Request.Context()).Raw(countRequestSQL(db), userID, endpoint, now, windowDuration, maxRequests).Scan(&window).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Database error during rate limit check",
 				"type":    "SERVER_ERROR",
@@ -220,25 +241,15 @@ func DatabaseRateLimit(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-
-		// Check if window has expired
-		windowEnd := rateLimit.WindowStart.Add(rateLimit.WindowDuration)
-		if now.After(windowEnd) {
-			// Reset the window
-			rateLimit.RequestCount = 1
-			rateLimit.WindowStart = now
-			rateLimit.MaxRequests = maxRequests // Update in case config changed
-			rateLimit.WindowDuration = windowDuration
-			db.Save(&rateLimit)
-			c.Next()
-			return
-		}
+		windowEnd := window.WindowStart.Add(window.WindowDuration)
 
 		// Check if limit exceeded
-		if rateLimit.RequestCount >= rateLimit.MaxRequests {
+		if window.RequestCount > window.MaxRequests {
 			retryAfter := int(windowEnd.Sub(now).Seconds()) + 1
 
-			c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", rateLimit.MaxRequests))
+			publishRateLimited(c, RateLimitClassEndpoint, fmt.Sprintf("user:%s", userID), float64(window.MaxRequests))
+
+			c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", window.MaxRequests))
 			c.Header("X-RateLimit-Remaining", "0")
 			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", windowEnd.Unix()))
 			c.Header("Retry-After", fmt.Sprintf("%d", retryAfter))
@@ -246,10 +257,10 @@ func DatabaseRateLimit(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded",
 				"type":        "RATE_LIMIT_EXCEEDED",
-				"message":     fmt.Sprintf("You have exceeded the limit of %d requests per %v for this endpoint. Please wait before trying again.", rateLimit.MaxRequests, rateLimit.WindowDuration),
+				"message":     fmt.Sprintf("You have exceeded the limit of %d requests per %v for this endpoint. Please wait before trying again.", window.MaxRequests, window.WindowDuration),
 				"retry_after": retryAfter,
-				"limit":       rateLimit.MaxRequests,
-				"window":      rateLimit.WindowDuration.String(),
+				"limit":       window.MaxRequests,
+				"window":      window.WindowDuration.String(),
 				"endpoint":    endpoint,
 				"code":        "RATE_LIMIT_ERROR",
 			})
@@ -257,13 +268,9 @@ func DatabaseRateLimit(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		// Increment request count
-		rateLimit.RequestCount++
-		db.Save(&rateLimit)
-
 		// Add rate limit headers
-		remaining := rateLimit.MaxRequests - rateLimit.RequestCount
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", rateLimit.MaxRequests))
+		remaining := window.MaxRequests - window.RequestCount
+		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", window.MaxRequests))
 		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
 		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", windowEnd.Unix()))
 
@@ -271,6 +278,104 @@ func DatabaseRateLimit(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// planRateLimiter holds per-user token buckets sized by each user's plan
+var planRateLimiter = NewRateLimiter(0, 0)
+
+// PlanRateLimit applies the rate limit of the authenticated user's plan. The
+// global limiters run before authentication and only see IP addresses, so this
+// is registered after AuthMiddleware; users without a plan rate are not limited here
+func PlanRateLimit(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := c.Get("user_id")
+		userID, ok := uid.(uuid.UUID)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var plan models.Plan
+		err := db.WithContext(c.Request.Context()).Model(&models.Plan{}).
+			Select("plans.rate_limit", "plans.rate_limit_burst").
+			Joins("JOIN users ON users.plan_id = plans.id").
+			Where("users.id = ?", userID).
+			Take(&plan).Error
+		if err != nil || plan.RateLimit <= 0 {
+			c.Next()
+			return
+		}
+
+		burst := plan.RateLimitBurst
+		if burst <= 0 {
+			burst = plan.RateLimit
+		}
+		limit, burst, exempt := planLimitFor(userID, float64(plan.RateLimit), burst)
+		if exempt {
+			c.Next()
+			return
+		}
+
+		// Plans and overrides can change at any time, so the bucket follows them
+		key := fmt.Sprintf("user:%s", userID)
+		limiter := planRateLimiter.GetLimiterWithRate(key, rate.Limit(limit), burst)
+
+		if !limiter.Allow() {
+			reservation := limiter.Reserve()
+			retryAfter := int(reservation.Delay().Seconds()) + 1
+			reservation.Cancel()
+
+			publishRateLimited(c, RateLimitClassPlan, key, limit)
+
+			c.Header("X-RateLimit-Limit", fmt.Sprintf("%v", limit))
+			c.Header("X-RateLimit-Remaining", "0")
+			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Duration(retryAfter)*time.Second).Unix()))
+			c.Header("Retry-After", fmt.Sprintf("%d", retryAfter))
+
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Rate limit exceeded",
+				"type":        "RATE_LIMIT_EXCEEDED",
+				"message":     fmt.Sprintf("Too many requests. Your plan allows %v calls per second. Please try again later.", limit),
+				"retry_after": retryAfter,
+				"limit":       limit,
+				"window":      1,
+				"code":        "RATE_LIMIT_ERROR",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", fmt.Sprintf("%v", limit))
+		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%.0f", limiter.Tokens()))
+
+		c.Next()
+	}
+}
+
+// publishRateLimited records a rejected request for the caller's usage
+// report and reports it to the operations feed
+func publishRateLimited(c *gin.Context, class, key string, limit float64) {
+	rateLimitRejections.record(class, key, time.Now())
+
+	event := events.Event{
+		Type:      events.TypeRateLimited,
+		Severity:  events.SeverityWarning,
+		IPAddress: StoredClientIP(c),
+		Message:   fmt.Sprintf("Rate limit exceeded on %s %s", c.Request.Method, c.Request.URL.Path),
+		Details: map[string]interface{}{
+			"key":      key,
+			"class":    class,
+			"method":   c.Request.Method,
+			"endpoint": c.Request.URL.Path,
+			"limit":    limit,
+		},
+	}
+	if uid, exists := c.Get("user_id"); exists {
+		if id, ok := uid.(uuid.UUID); ok {
+			event.UserID = &id
+		}
+	}
+	events.Publish(event)
+}
+
 // StorageQuotaMiddleware checks if user has exceeded storage quota with detailed validation
 func StorageQuotaMiddleware(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -306,7 +411,7 @@ func StorageQuotaMiddleware(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 
 		// Get user's current storage usage and quota
 		var user models.User
-		if err := db.First(&user, userID).Error; err != nil {
+		if err := db.WithContext(c.Request.Context()).First(&user, userID).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "User account not found",
 				"type":    "SERVER_ERROR",
@@ -317,70 +422,27 @@ func StorageQuotaMiddleware(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		// Calculate remaining quota
-		remainingQuota := user.StorageQuota - user.StorageUsed
+		// Calculate remaining quota, including any grace overage still available
+		remainingQuota := user.QuotaLimit(cfg.QuotaGracePercent, time.Now()) - user.StorageUsed
 		if remainingQuota <= 0 {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":   "Storage quota exceeded",
-				"type":    "STORAGE_QUOTA_EXCEEDED",
-				"message": fmt.Sprintf("Your storage quota of %.2f MB is fully used. Please delete some files to free up space or contact support to increase your quota.", float64(user.StorageQuota)/(1024*1024)),
-				"quota_info": gin.H{
-					"total_quota":       user.StorageQuota,
-					"used_storage":      user.StorageUsed,
-					"available_storage": 0,
-					"quota_mb":          float64(user.StorageQuota) / (1024 * 1024),
-					"used_mb":           float64(user.StorageUsed) / (1024 * 1024),
-					"available_mb":      0.0,
-				},
-				"code": "QUOTA_EXCEEDED",
-			})
+			c.JSON(http.StatusForbidden, QuotaExceededResponse(user.StorageQuota, user.StorageUsed, 0))
 			c.Abort()
 			return
 		}
 
-		// Check if the upload size would exceed quota
-		contentLength := c.Request.ContentLength
-		if contentLength > 0 && contentLength > remainingQuota {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Storage quota exceeded",
-				"type":  "STORAGE_QUOTA_EXCEEDED",
-				"message": fmt.Sprintf("Upload would exceed your storage quota of %.2f MB. Used: %.2f MB, Available: %.2f MB, File size: %.2f MB",
-					float64(user.StorageQuota)/(1024*1024),
-					float64(user.StorageUsed)/(1024*1024),
-					float64(remainingQuota)/(1024*1024),
-					float64(contentLength)/(1024*1024)),
-				"quota_info": gin.H{
-					"total_quota":       user.StorageQuota,
-					"used_storage":      user.StorageUsed,
-					"available_storage": remainingQuota,
-					"file_size":         contentLength,
-					"quota_mb":          float64(user.StorageQuota) / (1024 * 1024),
-					"used_mb":           float64(user.StorageUsed) / (1024 * 1024),
-					"available_mb":      float64(remainingQuota) / (1024 * 1024),
-					"file_mb":           float64(contentLength) / (1024 * 1024),
-				},
-				"code": "UPLOAD_EXCEEDS_QUOTA",
-			})
+		// Check against max file size limit when the client declares a size
+		if contentLength := c.Request.ContentLength; contentLength > cfg.MaxFileSize {
+			c.JSON(http.StatusRequestEntityTooLarge, FileTooLargeResponse(cfg.MaxFileSize, contentLength))
 			c.Abort()
 			return
 		}
 
-		// Check against max file size limit
-		if contentLength > cfg.MaxFileSize {
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-				"error": "File too large",
-				"type":  "FILE_SIZE_EXCEEDED",
-				"message": fmt.Sprintf("File size %.2f MB exceeds the maximum allowed size of %.2f MB",
-					float64(contentLength)/(1024*1024),
-					float64(cfg.MaxFileSize)/(1024*1024)),
-				"max_size":     cfg.MaxFileSize,
-				"file_size":    contentLength,
-				"max_size_mb":  float64(cfg.MaxFileSize) / (1024 * 1024),
-				"file_size_mb": float64(contentLength) / (1024 * 1024),
-				"code":         "FILE_TOO_LARGE",
-			})
-			c.Abort()
-			return
+		// Enforce the quota while the body streams in. Content-Length is absent
+		// for chunked uploads and includes multipart framing, so the running byte
+		// count is checked instead, with a small allowance for the framing itself
+		c.Request.Body = &quotaLimitedBody{
+			ReadCloser: c.Request.Body,
+			limit:      remainingQuota + multipartOverheadAllowance,
 		}
 
 		// Set quota information in context for upload handlers
@@ -392,37 +454,120 @@ func StorageQuotaMiddleware(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// multipartOverheadAllowance covers boundaries and part headers so uploads that
+// fit the quota exactly are not rejected because of multipart framing
+const multipartOverheadAllowance = 64 << 10 // 64KB
+
+// QuotaExceededError is returned by request bodies wrapped in StorageQuotaMiddleware
+// once more bytes have been received than the user's remaining quota allows
+type QuotaExceededError struct {
+	Received int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("upload exceeds remaining storage quota after %d bytes", e.Received)
+}
+
+// quotaLimitedBody counts request body bytes and fails the read that crosses the limit
+type quotaLimitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *quotaLimitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n, &QuotaExceededError{Received: b.read}
+	}
+	return n, err
+}
+
+// QuotaExceededResponse builds the QUOTA_EXCEEDED error body. attempted is the
+// size of the rejected upload, or 0 when the quota is already fully used.
+func QuotaExceededResponse(quota, used, attempted int64) gin.H {
+	available := quota - used
+	if available < 0 {
+		available = 0
+	}
+
+	message := fmt.Sprintf("Your storage quota of %.2f MB is fully used. Please delete some files to free up space or contact support to increase your quota.", float64(quota)/(1024*1024))
+	if attempted > 0 {
+		message = fmt.Sprintf("Upload would exceed your storage quota of %.2f MB. Used: %.2f MB, Available: %.2f MB, File size: %.2f MB",
+			float64(quota)/(1024*1024),
+			float64(used)/(1024*1024),
+			float64(available)/(1024*1024),
+			float64(attempted)/(1024*1024))
+	}
+
+	quotaInfo := gin.H{
+		"total_quota":       quota,
+		"used_storage":      used,
+		"available_storage": available,
+		"quota_mb":          float64(quota) / (1024 * 1024),
+		"used_mb":           float64(used) / (1024 * 1024),
+		"available_mb":      float64(available) / (1024 * 1024),
+	}
+	if attempted > 0 {
+		quotaInfo["file_size"] = attempted
+		quotaInfo["file_mb"] = float64(attempted) / (1024 * 1024)
+	}
+
+	return gin.H{
+		"error":      "Storage quota exceeded",
+		"type":       "STORAGE_QUOTA_EXCEEDED",
+		"message":    message,
+		"quota_info": quotaInfo,
+		"code":       "QUOTA_EXCEEDED",
+	}
+}
+
 // AdminOnlyMiddleware restricts access to admin users only
 func AdminOnlyMiddleware() gin.HandlerFunc {
 	return RequireAdmin()
 }
 
-// FileUploadSizeLimit middleware checks file size before processing with configurable limits
+// FileUploadSizeLimit rejects upload requests larger than MAX_FILE_SIZE. A
+// declared Content-Length is checked up front, but clients can omit it or
+// lie, so the body is also capped with http.MaxBytesReader; handlers turn
+// the resulting *http.MaxBytesError into FileTooLargeResponse.
 func FileUploadSizeLimit(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method == "POST" && c.Request.Header.Get("Content-Type") != "" {
 			contentLength := c.Request.ContentLength
 			if contentLength > cfg.MaxFileSize {
-				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-					"error": "File too large",
-					"type":  "FILE_SIZE_EXCEEDED",
-					"message": fmt.Sprintf("File size %.2f MB exceeds the maximum allowed size of %.2f MB",
-						float64(contentLength)/(1024*1024),
-						float64(cfg.MaxFileSize)/(1024*1024)),
-					"max_size":     cfg.MaxFileSize,
-					"file_size":    contentLength,
-					"max_size_mb":  float64(cfg.MaxFileSize) / (1024 * 1024),
-					"file_size_mb": float64(contentLength) / (1024 * 1024),
-					"code":         "FILE_TOO_LARGE",
-				})
+				c.JSON(http.StatusRequestEntityTooLarge, FileTooLargeResponse(cfg.MaxFileSize, contentLength))
 				c.Abort()
 				return
 			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxFileSize+multipartOverheadAllowance)
 		}
 		c.Next()
 	}
 }
 
+// FileTooLargeResponse builds the FILE_TOO_LARGE error body. size is the
+// declared request size, or 0 when the limit was hit while streaming.
+func FileTooLargeResponse(maxSize, size int64) gin.H {
+	body := gin.H{
+		"error":       "File too large",
+		"type":        "FILE_SIZE_EXCEEDED",
+		"message":     fmt.Sprintf("Upload exceeds the maximum allowed size of %.2f MB", float64(maxSize)/(1024*1024)),
+		"max_size":    maxSize,
+		"max_size_mb": float64(maxSize) / (1024 * 1024),
+		"code":        "FILE_TOO_LARGE",
+	}
+	if size > 0 {
+		body["message"] = fmt.Sprintf("File size %.2f MB exceeds the maximum allowed size of %.2f MB",
+			float64(size)/(1024*1024),
+			float64(maxSize)/(1024*1024))
+		body["file_size"] = size
+		body["file_size_mb"] = float64(size) / (1024 * 1024)
+	}
+	return body
+}
+
 // QuotaInfoMiddleware adds quota information to responses for authenticated users
 func QuotaInfoMiddleware(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -433,12 +578,15 @@ func QuotaInfoMiddleware(db *gorm.DB) gin.HandlerFunc {
 		if userIDInterface, exists := c.Get("user_id"); exists {
 			if userID, ok := userIDInterface.(uuid.UUID); ok {
 				var user models.User
-				if err := db.First(&user, userID).Error; err == nil {
+				if err := db.WithContext(c.Request.Context()).First(&user, userID).Error; err == nil {
 					remaining := user.StorageQuota - user.StorageUsed
 					c.Header("X-Storage-Quota", fmt.Sprintf("%d", user.StorageQuota))
 					c.Header("X-Storage-Used", fmt.Sprintf("%d", user.StorageUsed))
 					c.Header("X-Storage-Remaining", fmt.Sprintf("%d", remaining))
 					c.Header("X-Storage-Usage-Percent", fmt.Sprintf("%.1f", float64(user.StorageUsed)/float64(user.StorageQuota)*100))
+					if user.InQuotaGrace(time.Now()) {
+						c.Header("X-Storage-Grace-Expires", user.QuotaGraceExpiresAt.UTC().Format(time.RFC3339))
+					}
 				}
 			}
 		}
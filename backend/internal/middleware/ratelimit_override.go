@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"file-vault-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// overrideCacheTTL bounds how long a change made on another server takes to
+// apply; changes made through this server apply at once
+const overrideCacheTTL = 30 * time.Second
+
+// overrideCache holds each user's override, or its absence, so the
+// limiters do not query the database on every request
+type overrideCache struct {
+	mu      sync.Mutex
+	db      *gorm.DB
+	entries map[uuid.UUID]cachedOverride
+}
+
+type cachedOverride struct {
+	override *models.RateLimitOverride
+	loadedAt time.Time
+}
+
+var rateLimitOverrides = &overrideCache{entries: make(map[uuid.UUID]cachedOverride)}
+
+// InitializeRateLimitOverrides makes the rate limiters consult per-user
+// overrides. Without it every user gets the normal limits.
+func InitializeRateLimitOverrides(db *gorm.DB) {
+	rateLimitOverrides.mu.Lock()
+	defer rateLimitOverrides.mu.Unlock()
+	rateLimitOverrides.db = db
+}
+
+// InvalidateRateLimitOverride drops a user's cached override after it changed
+func InvalidateRateLimitOverride(userID uuid.UUID) {
+	rateLimitOverrides.mu.Lock()
+	defer rateLimitOverrides.mu.Unlock()
+	delete(rateLimitOverrides.entries, userID)
+}
+
+// RateLimitOverrideFor returns the override currently in force for a user,
+// or nil when there is none
+func RateLimitOverrideFor(userID uuid.UUID) *models.RateLimitOverride {
+	now := time.Now()
+	c := rateLimitOverrides
+
+	c.mu.Lock()
+	db := c.db
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	if !ok || now.Sub(entry.loadedAt) > overrideCacheTTL {
+		var override models.RateLimitOverride
+		err := db.Where("user_id = ?", userID).Take(&override).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			// Keep enforcing the last known override rather than dropping it
+			return activeOverride(entry.override, now)
+		}
+		entry = cachedOverride{loadedAt: now}
+		if err == nil {
+			entry.override = &override
+		}
+		c.mu.Lock()
+		c.entries[userID] = entry
+		c.mu.Unlock()
+	}
+
+	return activeOverride(entry.override, now)
+}
+
+func activeOverride(override *models.RateLimitOverride, now time.Time) *models.RateLimitOverride {
+	if override == nil || override.Expired(now) {
+		return nil
+	}
+	return override
+}
+
+// rateLimitCaller identifies the user behind a request. The server-wide
+// limiters run before AuthMiddleware, so a valid bearer token is read here
+// without being enforced; requests without one are limited by IP address.
+func rateLimitCaller(c *gin.Context) (uuid.UUID, string, bool) {
+	if uid, exists := c.Get("user_id"); exists {
+		if id, ok := uid.(uuid.UUID); ok {
+			return id, c.GetString("role"), true
+		}
+	}
+
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" || tokenString == c.GetHeader("Authorization") {
+		return uuid.Nil, "", false
+	}
+	claims, err := ValidateJWTToken(tokenString)
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+	return claims.UserID, claims.Role, true
+}
+
+// serverLimitFor returns the server-wide limit and burst for a caller and
+// whether they are exempt. An override wins; otherwise admins are exempt, so
+// the admin panel keeps working while anonymous requests to admin routes are
+// still limited.
+func serverLimitFor(userID uuid.UUID, role string, limit float64, burst int) (float64, int, bool) {
+	if override := RateLimitOverrideFor(userID); override != nil {
+		return override.Apply(limit, burst)
+	}
+	return limit, burst, role == string(models.RoleAdmin)
+}
+
+// planLimitFor returns the plan limit and burst for a user after any override
+// and whether they are exempt
+func planLimitFor(userID uuid.UUID, limit float64, burst int) (float64, int, bool) {
+	if override := RateLimitOverrideFor(userID); override != nil {
+		return override.Apply(limit, burst)
+	}
+	return limit, burst, false
+}
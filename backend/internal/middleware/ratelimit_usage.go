@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Rate limit classes, one per limiter a request can run into
+const (
+	RateLimitClassGlobal   = "global"   // in-memory limiter in front of every route
+	RateLimitClassEndpoint = "endpoint" // database limiter counting each endpoint separately
+	RateLimitClassPlan     = "plan"     // the user's plan limit on file and folder routes
+)
+
+// rejectionWindow is how long rejected requests are remembered
+const rejectionWindow = 24 * time.Hour
+
+// maxRejectionsPerKey caps the memory one client hammering a limit can use
+const maxRejectionsPerKey = 10000
+
+// rejectionLog remembers when each limiter key was last refused, so users can
+// see how often they have been throttled recently
+type rejectionLog struct {
+	mu      sync.Mutex
+	entries map[string][]time.Time
+}
+
+var rateLimitRejections = &rejectionLog{entries: make(map[string][]time.Time)}
+
+// record notes a rejected request for a class and key
+func (l *rejectionLog) record(class, key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := class + "|" + key
+	times := pruneRejections(l.entries[id], now)
+	if len(times) >= maxRejectionsPerKey {
+		times = times[1:]
+	}
+	l.entries[id] = append(times, now)
+}
+
+// count returns how many requests for a class and key were refused since the given time
+func (l *rejectionLog) count(class, key string, since time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, t := range l.entries[class+"|"+key] {
+		if !t.Before(since) {
+			n++
+		}
+	}
+	return n
+}
+
+// cleanup forgets rejections older than the window
+func (l *rejectionLog) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, times := range l.entries {
+		if times = pruneRejections(times, now); len(times) == 0 {
+			delete(l.entries, id)
+		} else {
+			l.entries[id] = times
+		}
+	}
+}
+
+func pruneRejections(times []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-rejectionWindow)
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// RateLimitConsumption is the state of one limiter for the caller
+type RateLimitConsumption struct {
+	Class         string     `json:"class"`
+	Scope         string     `json:"scope"` // "user" or "ip", what the limiter is keyed by
+	Endpoint      string     `json:"endpoint,omitempty"`
+	Limit         float64    `json:"limit"` // requests allowed per window
+	WindowSeconds float64    `json:"window_seconds"`
+	Burst         int        `json:"burst,omitempty"`
+	Exempt        bool       `json:"exempt,omitempty"`
+	Used          int        `json:"used"`
+	Remaining     int        `json:"remaining"`
+	ResetAt       *time.Time `json:"reset_at,omitempty"`
+}
+
+// RateLimitRejections counts requests refused with 429 for a class
+type RateLimitRejections struct {
+	LastHour int `json:"last_hour"`
+	LastDay  int `json:"last_day"`
+}
+
+// RateLimitUsage is what the rate limiters currently know about a caller
+type RateLimitUsage struct {
+	Enabled    bool                           `json:"enabled"`
+	Mode       string                         `json:"mode,omitempty"`
+	Override   *models.RateLimitOverride      `json:"override,omitempty"`
+	Limits     []RateLimitConsumption         `json:"limits"`
+	Rejections map[string]RateLimitRejections `json:"rejections"`
+	AsOf       time.Time                      `json:"as_of"`
+}
+
+// GetRateLimitUsage reports the caller's consumption of every limiter that
+// applies to them, after any override. Reading the state never consumes a
+// request.
+func GetRateLimitUsage(db *gorm.DB, cfg *config.Config, userID uuid.UUID, role string) (*RateLimitUsage, error) {
+	now := time.Now()
+	usage := &RateLimitUsage{
+		Enabled:    cfg.EnableRateLimit,
+		Limits:     []RateLimitConsumption{},
+		Rejections: map[string]RateLimitRejections{},
+		AsOf:       now,
+	}
+	if !cfg.EnableRateLimit {
+		return usage, nil
+	}
+	usage.Mode = cfg.RateLimitMode
+	usage.Override = RateLimitOverrideFor(userID)
+
+	userKey := fmt.Sprintf("user:%s", userID)
+	serverLimit, serverBurst, serverExempt := serverLimitFor(userID, role, float64(cfg.RateLimit), cfg.RateLimitBurst)
+
+	if cfg.RateLimitMode == "database" {
+		var rows []models.APIRateLimit
+		if err := db.Where("user_id = ?", userID).Order("endpoint").Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("error loading rate limit windows: %w", err)
+		}
+		for _, row := range rows {
+			consumption := RateLimitConsumption{
+				Class:         RateLimitClassEndpoint,
+				Scope:         "user",
+				Endpoint:      row.Endpoint,
+				Limit:         float64(row.MaxRequests),
+				WindowSeconds: row.WindowDuration.Seconds(),
+				Remaining:     row.MaxRequests,
+			}
+			if resetAt := row.WindowStart.Add(row.WindowDuration); now.Before(resetAt) {
+				consumption.Used = row.RequestCount
+				consumption.Remaining = max(row.MaxRequests-row.RequestCount, 0)
+				consumption.ResetAt = &resetAt
+			}
+			usage.Limits = append(usage.Limits, consumption)
+		}
+		if serverExempt {
+			usage.Limits = append(usage.Limits, RateLimitConsumption{Class: RateLimitClassEndpoint, Scope: "user", Exempt: true})
+		}
+		usage.Rejections[RateLimitClassEndpoint] = rejectionCounts(RateLimitClassEndpoint, userKey, now)
+	} else if globalRateLimiter != nil {
+		consumption := RateLimitConsumption{Class: RateLimitClassGlobal, Scope: "user", Exempt: true}
+		if !serverExempt {
+			consumption = bucketConsumption(RateLimitClassGlobal, "user", globalRateLimiter, userKey, serverLimit, serverBurst, now)
+		}
+		usage.Limits = append(usage.Limits, consumption)
+		usage.Rejections[RateLimitClassGlobal] = rejectionCounts(RateLimitClassGlobal, userKey, now)
+	}
+
+	var plan models.Plan
+	err := db.Model(&models.Plan{}).
+		Select("plans.rate_limit", "plans.rate_limit_burst").
+		Joins("JOIN users ON users.plan_id = plans.id").
+		Where("users.id = ?", userID).
+		Take(&plan).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("error loading plan rate limit: %w", err)
+	}
+	if err == nil && plan.RateLimit > 0 {
+		burst := plan.RateLimitBurst
+		if burst <= 0 {
+			burst = plan.RateLimit
+		}
+		consumption := RateLimitConsumption{Class: RateLimitClassPlan, Scope: "user", Exempt: true}
+		if limit, burst, exempt := planLimitFor(userID, float64(plan.RateLimit), burst); !exempt {
+			consumption = bucketConsumption(RateLimitClassPlan, "user", planRateLimiter, userKey, limit, burst, now)
+		}
+		usage.Limits = append(usage.Limits, consumption)
+		usage.Rejections[RateLimitClassPlan] = rejectionCounts(RateLimitClassPlan, userKey, now)
+	}
+
+	return usage, nil
+}
+
+// bucketConsumption reads a token bucket without taking a token. A key with
+// no bucket yet has its full burst available.
+func bucketConsumption(class, scope string, rl *RateLimiter, key string, limit float64, burst int, now time.Time) RateLimitConsumption {
+	consumption := RateLimitConsumption{
+		Class:         class,
+		Scope:         scope,
+		Limit:         limit,
+		WindowSeconds: 1,
+		Burst:         burst,
+		Remaining:     burst,
+	}
+
+	rl.mu.RLock()
+	limiter, exists := rl.limiters[key]
+	rl.mu.RUnlock()
+	if !exists {
+		return consumption
+	}
+
+	tokens := math.Max(limiter.TokensAt(now), 0)
+	consumption.Remaining = int(math.Floor(tokens))
+	consumption.Used = burst - consumption.Remaining
+	if missing := float64(burst) - tokens; missing > 0 && limit > 0 {
+		resetAt := now.Add(time.Duration(missing / limit * float64(time.Second)))
+		consumption.ResetAt = &resetAt
+	}
+	return consumption
+}
+
+func rejectionCounts(class, key string, now time.Time) RateLimitRejections {
+	return RateLimitRejections{
+		LastHour: rateLimitRejections.count(class, key, now.Add(-time.Hour)),
+		LastDay:  rateLimitRejections.count(class, key, now.Add(-rejectionWindow)),
+	}
+}
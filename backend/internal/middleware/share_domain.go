@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// shareDomainRoutes are the routes served on share domains, each with the
+// table and column that resolve its token to the link's creator
+var shareDomainRoutes = map[string]struct{ table, column string }{
+	"/share/:token":               {"share_links", "share_token"},
+	"/share/:token/download":      {"share_links", "share_token"},
+	"/share/:token/preview":       {"share_links", "share_token"},
+	"/share/:token/pages/:n":      {"share_links", "share_token"},
+	"/share/:token/unlock":        {"share_links", "share_token"},
+	"/folder-share/:token":        {"folder_share_links", "token"},
+	"/folder-share/:token/unlock": {"folder_share_links", "token"},
+}
+
+// ServeShareDomains limits requests to the hosts in cfg.ShareDomains to the
+// public share link routes and health checks. A share domain assigned to a
+// tenant only serves that tenant's links; the other share domains serve the
+// links of everyone whose tenant has no domain of its own. Anything else is
+// answered as not found, so a custom domain never exposes the API or another
+// organization's links
+func ServeShareDomains(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(cfg.ShareDomains) == 0 || !cfg.IsShareDomain(c.Request.Host) {
+			c.Next()
+			return
+		}
+
+		path := c.FullPath()
+		if path == "/health" || path == "/healthz" || path == "/readyz" {
+			c.Next()
+			return
+		}
+
+		route, ok := shareDomainRoutes[path]
+		if !ok || !shareDomainServes(c, db, route.table, route.column) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// shareDomainServes reports whether the request's host may serve the link
+// named by the token. Unknown tokens pass so the handler answers for them
+func shareDomainServes(c *gin.Context, db *gorm.DB, table, column string) bool {
+	host := strings.ToLower(c.Request.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	ctx := c.Request.Context()
+
+	// The share domain of the tenant the link's creator belongs to
+	var linkDomains []string
+	if err := db.WithContext(ctx).Table(table).
+		Joins("JOIN users ON users.id = "+table+".created_by").
+		Joins("LEFT JOIN tenants ON tenants.id = users.tenant_id").
+		Where(table+"."+column+" = ?", c.Param("token")).
+		Pluck("COALESCE(tenants.share_domain, '')", &linkDomains).Error; err != nil {
+		return false
+	}
+	if len(linkDomains) == 0 {
+		return true
+	}
+	if linkDomains[0] != "" {
+		return linkDomains[0] == host
+	}
+
+	// Links of users without a tenant domain stay off tenants' domains
+	var assigned int64
+	if err := db.WithContext(ctx).Model(&models.Tenant{}).
+		Where("share_domain = ?", host).Count(&assigned).Error; err != nil {
+		return false
+	}
+	return assigned == 0
+}
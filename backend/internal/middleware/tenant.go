@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ResolveTenant works out which tenant a request is for, from the tenant
+// header or else the subdomain of cfg.TenantBaseDomain, and stores it as
+// "tenant" and its ID as "tenant_id". Requests naming no tenant are for the
+// platform itself; requests naming an unknown or inactive tenant are
+// rejected.
+func ResolveTenant(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug := requestTenantSlug(c, cfg)
+		if slug == "" {
+			c.Next()
+			return
+		}
+
+		var tenant models.Tenant
+		if err := db.WithContext(c.Request.Context()).
+			Where("slug = ? AND is_active = ?", slug, true).
+			Take(&tenant).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Tenant not found",
+				"code":  "TENANT_NOT_FOUND",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("tenant", &tenant)
+		c.Set("tenant_id", tenant.ID)
+		c.Next()
+	}
+}
+
+// requestTenantSlug returns the tenant slug a request names, or "" for the
+// platform
+func requestTenantSlug(c *gin.Context, cfg *config.Config) string {
+	if cfg.TenantHeader != "" {
+		if slug := strings.ToLower(strings.TrimSpace(c.GetHeader(cfg.TenantHeader))); slug != "" {
+			return slug
+		}
+	}
+	if cfg.TenantBaseDomain == "" {
+		return ""
+	}
+
+	host := strings.ToLower(c.Request.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label := strings.TrimSuffix(host, "."+cfg.TenantBaseDomain)
+	// Only a single label directly under the base domain names a tenant
+	if label == host || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// TenantID returns the ID of the tenant the request is for, or nil for the
+// platform
+func TenantID(c *gin.Context) *uuid.UUID {
+	if id, ok := c.Get("tenant_id"); ok {
+		tenantID := id.(uuid.UUID)
+		return &tenantID
+	}
+	return nil
+}
+
+// CurrentTenant returns the tenant the request is for, or nil for the
+// platform
+func CurrentTenant(c *gin.Context) *models.Tenant {
+	if tenant, ok := c.Get("tenant"); ok {
+		return tenant.(*models.Tenant)
+	}
+	return nil
+}
+
+// RequireTenantAdmin admits tenant admins of the tenant the request is for
+func RequireTenantAdmin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := TenantID(c)
+		if tenantID == nil || c.GetString("role") != string(models.RoleTenantAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Tenant admin access required",
+			})
+			c.Abort()
+			return
+		}
+
+		// Verify the role in the database, since it may have changed since
+		// the token was issued
+		var user models.User
+		if err := db.WithContext(c.Request.Context()).Select("role", "tenant_id").
+			Where("id = ?", c.MustGet("user_id")).Take(&user).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User not found",
+			})
+			c.Abort()
+			return
+		}
+		if user.Role != models.RoleTenantAdmin || !models.SameTenant(user.TenantID, tenantID) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Tenant admin privileges required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
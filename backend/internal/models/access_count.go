@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessCountResource is what an access count counts visits to
+type AccessCountResource string
+
+const (
+	AccessCountFile   AccessCountResource = "file"
+	AccessCountFolder AccessCountResource = "folder"
+)
+
+// AccessCount is a day's anonymous views and downloads of one file or
+// folder, kept instead of access logs when anonymous access tracking is set
+// to counts. It holds nothing about the visitors.
+type AccessCount struct {
+	ResourceType AccessCountResource `json:"resource_type" gorm:"type:varchar(20);primary_key"`
+	ResourceID   uuid.UUID           `json:"resource_id" gorm:"type:uuid;primary_key"`
+	Day          time.Time           `json:"day" gorm:"type:date;primary_key"`
+	Views        int64               `json:"views" gorm:"not null;default:0"`
+	Downloads    int64               `json:"downloads" gorm:"not null;default:0"`
+}
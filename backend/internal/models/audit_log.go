@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"time"
 
@@ -22,15 +24,44 @@ const (
 	AuditActionRename   AuditLogAction = "rename"
 	AuditActionCreate   AuditLogAction = "create"
 	AuditActionUpdate   AuditLogAction = "update"
+	AuditActionDLPScan  AuditLogAction = "dlp_scan"
+	AuditActionVerify   AuditLogAction = "verify"
+	AuditActionAccept   AuditLogAction = "accept"
+	AuditActionList     AuditLogAction = "list" // recorded in verbose access audit mode
+
+	// Quarantine review queue
+	AuditActionQuarantine AuditLogAction = "quarantine"
+	AuditActionRelease    AuditLogAction = "release"
+	AuditActionReject     AuditLogAction = "reject"
+
+	// Audit log retention
+	AuditActionPrune AuditLogAction = "prune"
+
+	// Login token signing keys
+	AuditActionRotate AuditLogAction = "rotate"
+
+	// An admin recovered the escrow copy of an encrypted folder's key
+	AuditActionRecover AuditLogAction = "recover"
+
+	// An admin opened or downloaded a user's file, with a stated reason
+	AuditActionAdminFileAccess AuditLogAction = "admin_file_access"
 )
 
 // AuditLogResourceType represents the type of resource
 type AuditLogResourceType string
 
 const (
-	AuditResourceFile   AuditLogResourceType = "file"
-	AuditResourceFolder AuditLogResourceType = "folder"
-	AuditResourceShare  AuditLogResourceType = "share"
+	AuditResourceFile       AuditLogResourceType = "file"
+	AuditResourceFolder     AuditLogResourceType = "folder"
+	AuditResourceShare      AuditLogResourceType = "share"
+	AuditResourceUser       AuditLogResourceType = "user"
+	AuditResourcePolicy     AuditLogResourceType = "policy"
+	AuditResourcePlan       AuditLogResourceType = "plan"
+	AuditResourceRateLimit  AuditLogResourceType = "rate_limit_override"
+	AuditResourceAudit      AuditLogResourceType = "audit_log"
+	AuditResourceTenant     AuditLogResourceType = "tenant"
+	AuditResourceSigningKey AuditLogResourceType = "signing_key"
+	AuditResourceVaultKey   AuditLogResourceType = "vault_key"
 )
 
 // AuditLogStatus represents the status of the action
@@ -83,6 +114,12 @@ type AuditLog struct {
 	CreatedAt    time.Time            `json:"created_at" gorm:"index"`
 	UpdatedAt    time.Time            `json:"updated_at"`
 
+	// Hash chain, filled in when the entry is sealed. Each entry's hash covers
+	// its contents and the hash of the entry before it.
+	Sequence *int64 `json:"sequence,omitempty" gorm:"uniqueIndex"`
+	PrevHash string `json:"prev_hash,omitempty" gorm:"type:varchar(64)"`
+	Hash     string `json:"hash,omitempty" gorm:"type:varchar(64)"`
+
 	// Associations
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
@@ -100,6 +137,50 @@ func (AuditLog) TableName() string {
 	return "audit_logs"
 }
 
+// ComputeHash returns the SHA-256 of the entry's sequence, previous hash and
+// contents. Details are hashed as re-encoded JSON, which sorts object keys, so
+// the result does not depend on how the database stored them.
+func (al *AuditLog) ComputeHash() string {
+	var sequence int64
+	if al.Sequence != nil {
+		sequence = *al.Sequence
+	}
+
+	details, _ := json.Marshal(al.Details)
+	payload, _ := json.Marshal(struct {
+		Sequence     int64                `json:"sequence"`
+		PrevHash     string               `json:"prev_hash"`
+		ID           uuid.UUID            `json:"id"`
+		UserID       uuid.UUID            `json:"user_id"`
+		Action       AuditLogAction       `json:"action"`
+		ResourceType AuditLogResourceType `json:"resource_type"`
+		ResourceID   *uuid.UUID           `json:"resource_id"`
+		ResourceName *string              `json:"resource_name"`
+		Details      json.RawMessage      `json:"details"`
+		IPAddress    *string              `json:"ip_address"`
+		UserAgent    *string              `json:"user_agent"`
+		Status       AuditLogStatus       `json:"status"`
+		CreatedAt    string               `json:"created_at"`
+	}{
+		Sequence:     sequence,
+		PrevHash:     al.PrevHash,
+		ID:           al.ID,
+		UserID:       al.UserID,
+		Action:       al.Action,
+		ResourceType: al.ResourceType,
+		ResourceID:   al.ResourceID,
+		ResourceName: al.ResourceName,
+		Details:      details,
+		IPAddress:    al.IPAddress,
+		UserAgent:    al.UserAgent,
+		Status:       al.Status,
+		CreatedAt:    al.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
 // GetUserDisplayName returns the user's display name for the audit log
 func (al *AuditLog) GetUserDisplayName() string {
 	if al.User.Username != "" {
@@ -111,6 +192,38 @@ func (al *AuditLog) GetUserDisplayName() string {
 	return "Unknown User"
 }
 
+// AuditOutbox is an audit entry written in the same transaction as the action
+// it records. A worker moves entries into audit_logs, so an action that
+// commits always gets its audit record and one that rolls back never does.
+type AuditOutbox struct {
+	ID           uuid.UUID            `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID       uuid.UUID            `json:"user_id" gorm:"type:uuid;not null"`
+	Action       AuditLogAction       `json:"action" gorm:"type:varchar(50);not null"`
+	ResourceType AuditLogResourceType `json:"resource_type" gorm:"type:varchar(20);not null"`
+	ResourceID   *uuid.UUID           `json:"resource_id,omitempty" gorm:"type:uuid"`
+	ResourceName *string              `json:"resource_name,omitempty" gorm:"type:varchar(255)"`
+	Details      AuditLogDetails      `json:"details,omitempty" gorm:"type:jsonb"`
+	IPAddress    *string              `json:"ip_address,omitempty" gorm:"type:inet"`
+	UserAgent    *string              `json:"user_agent,omitempty" gorm:"type:text"`
+	Status       AuditLogStatus       `json:"status" gorm:"type:varchar(20);default:'success'"`
+	Attempts     int                  `json:"attempts" gorm:"default:0"`
+	LastError    string               `json:"last_error,omitempty" gorm:"type:text"`
+	CreatedAt    time.Time            `json:"created_at"` // time of the action, kept on the audit log entry
+}
+
+// BeforeCreate hook
+func (o *AuditOutbox) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for GORM
+func (AuditOutbox) TableName() string {
+	return "audit_outbox"
+}
+
 // AuditLogFilter represents filters for querying audit logs
 type AuditLogFilter struct {
 	UserID       *uuid.UUID            `json:"user_id,omitempty"`
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BackupMode selects how much blob content a backup copies
+type BackupMode string
+
+const (
+	BackupModeFull        BackupMode = "full"        // copy every blob
+	BackupModeIncremental BackupMode = "incremental" // copy only blobs missing from the secondary location
+)
+
+// BackupStatus represents the lifecycle of a backup job
+type BackupStatus string
+
+const (
+	BackupStatusPending   BackupStatus = "pending"
+	BackupStatusRunning   BackupStatus = "running"
+	BackupStatusCompleted BackupStatus = "completed"
+	BackupStatusFailed    BackupStatus = "failed"
+)
+
+// BackupJob records a database snapshot and blob sync run
+type BackupJob struct {
+	ID           uuid.UUID    `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Mode         BackupMode   `json:"mode" gorm:"type:varchar(20);default:'full'"`
+	Status       BackupStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	StartedBy    *uuid.UUID   `json:"started_by,omitempty" gorm:"type:uuid"`
+	BackupDir    string       `json:"backup_dir" gorm:"type:text"`
+	BlobCount    int          `json:"blob_count" gorm:"default:0"`
+	BlobsCopied  int          `json:"blobs_copied" gorm:"default:0"`
+	BytesCopied  int64        `json:"bytes_copied" gorm:"default:0"`
+	MissingBlobs int          `json:"missing_blobs" gorm:"default:0"`
+	Error        string       `json:"error,omitempty" gorm:"type:text"`
+	StartedAt    *time.Time   `json:"started_at,omitempty"`
+	CompletedAt  *time.Time   `json:"completed_at,omitempty"`
+	CreatedAt    time.Time    `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for GORM
+func (BackupJob) TableName() string {
+	return "backup_jobs"
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// BlobChunk is the checksum of one segment of a stored blob. Blobs never
+// change once written, so the checksums are computed the first time a
+// download manifest is requested and reused for every file sharing the blob
+type BlobChunk struct {
+	FileHashID uuid.UUID `json:"file_hash_id" gorm:"type:uuid;primary_key"`
+	ChunkSize  int64     `json:"chunk_size" gorm:"primary_key"` // segment size the blob was split with
+	ChunkIndex int       `json:"chunk_index" gorm:"primary_key"`
+	Offset     int64     `json:"offset" gorm:"not null"`
+	Length     int64     `json:"length" gorm:"not null"`
+	SHA256     string    `json:"sha256" gorm:"column:sha256;size:64;not null"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CleanupRule moves a user's files to the trash once they are older than
+// OlderThanDays. A rule covers one folder, or the root folder when FolderID
+// is nil, and with IncludeSubfolders everything below it too; NamePattern,
+// MimeType and Tag narrow it down further. Rules are evaluated by the
+// scheduler on every cleanup pass.
+type CleanupRule struct {
+	ID                uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID            uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name              string     `json:"name" gorm:"not null;size:100"`
+	FolderID          *uuid.UUID `json:"folder_id" gorm:"type:uuid"` // nil for the root folder
+	IncludeSubfolders bool       `json:"include_subfolders" gorm:"not null"`
+	NamePattern       string     `json:"name_pattern,omitempty" gorm:"size:255"` // case-insensitive, with * and ? wildcards
+	MimeType          string     `json:"mime_type,omitempty" gorm:"size:100"`    // a type such as application/pdf, or a family such as image/*
+	Tag               string     `json:"tag,omitempty" gorm:"size:50"`           // set by the user or the classifier, such as screenshot
+	OlderThanDays     int        `json:"older_than_days" gorm:"not null"`        // by upload time
+	IsActive          bool       `json:"is_active" gorm:"not null"`
+
+	// Outcome of the latest pass
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	LastTrashed int        `json:"last_trashed" gorm:"not null;default:0"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ContentIndexStatus tracks text extraction for a blob
+type ContentIndexStatus string
+
+const (
+	ContentIndexPending     ContentIndexStatus = "pending"
+	ContentIndexIndexed     ContentIndexStatus = "indexed"
+	ContentIndexFailed      ContentIndexStatus = "failed"
+	ContentIndexUnsupported ContentIndexStatus = "unsupported" // no text can be extracted from this type
+)
+
+// ContentIndexMethod records how text was extracted
+type ContentIndexMethod string
+
+const (
+	ContentIndexMethodText ContentIndexMethod = "text" // read directly from a text file
+	ContentIndexMethodOCR  ContentIndexMethod = "ocr"  // recognised in an image or scanned PDF
+)
+
+// ContentIndex holds the searchable text extracted from a blob. Content is
+// deduplicated, so every file sharing the blob shares its index entry
+type ContentIndex struct {
+	FileHashID    uuid.UUID          `json:"file_hash_id" gorm:"type:uuid;primary_key"`
+	Status        ContentIndexStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	Method        ContentIndexMethod `json:"method,omitempty" gorm:"type:varchar(10)"`
+	Engine        string             `json:"engine,omitempty" gorm:"size:50"`
+	MimeType      string             `json:"mime_type" gorm:"size:100"`
+	ExtractedText string             `json:"-" gorm:"type:text"`
+	CharCount     int                `json:"char_count" gorm:"default:0"`
+	Attempts      int                `json:"attempts" gorm:"default:0"`
+	Error         string             `json:"error,omitempty" gorm:"type:text"`
+	IndexedAt     *time.Time         `json:"indexed_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (ContentIndex) TableName() string {
+	return "content_index"
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceClient is the kind of client a device signed in with
+type DeviceClient string
+
+const (
+	DeviceClientWeb  DeviceClient = "web"
+	DeviceClientSync DeviceClient = "sync" // desktop sync client; must register a name and platform
+)
+
+// Device is a client a user signed in from. Every token issued at login is
+// bound to a device, so revoking the device signs that client out
+type Device struct {
+	ID         uuid.UUID    `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID     uuid.UUID    `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name       string       `json:"name" gorm:"size:100"`
+	Platform   string       `json:"platform" gorm:"size:50"`
+	Client     DeviceClient `json:"client" gorm:"type:varchar(20);default:'web'"`
+	UserAgent  string       `json:"user_agent" gorm:"type:text"`
+	LastIP     string       `json:"last_ip" gorm:"size:45"`
+	LastSeenAt time.Time    `json:"last_seen_at"`
+	RevokedAt  *time.Time   `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at" gorm:"autoCreateTime"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DirectUpload is an upload the client sends straight to the object store
+// with a presigned URL. The content waits under ObjectKey until the client
+// finalizes the upload, when it is checked against SHA256 and becomes a file.
+type DirectUpload struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OwnerID          uuid.UUID  `json:"owner_id" gorm:"type:uuid;not null;index"`
+	FolderID         *uuid.UUID `json:"folder_id,omitempty" gorm:"type:uuid"`
+	Filename         string     `json:"filename" gorm:"not null;size:255"`
+	MimeType         string     `json:"mime_type" gorm:"not null;size:100"` // declared by the client
+	Size             int64      `json:"size" gorm:"not null"`
+	SHA256           string     `json:"sha256" gorm:"column:sha256;not null;size:64"` // declared by the client, and enforced by the store
+	ObjectKey        string     `json:"-" gorm:"not null;size:255"`
+	IsPublic         bool       `json:"is_public" gorm:"default:false"`
+	EncryptionHeader string     `json:"encryption_header,omitempty" gorm:"type:text"` // for uploads to encrypted folders
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
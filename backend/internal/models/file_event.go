@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FileEventType is a step in a file's lifecycle
+type FileEventType string
+
+const (
+	FileEventCreated  FileEventType = "created"
+	FileEventRenamed  FileEventType = "renamed"
+	FileEventMoved    FileEventType = "moved"
+	FileEventShared   FileEventType = "shared"
+	FileEventDeleted  FileEventType = "deleted"
+	FileEventRestored FileEventType = "restored"
+
+	// FileEventModified is recorded when an upload replaces a file's content
+	FileEventModified FileEventType = "modified"
+	// FileEventConflicted is recorded on the conflicted copy kept when an
+	// upload replaced a file that changed after the uploader's base revision
+	FileEventConflicted FileEventType = "conflicted"
+)
+
+// FileEventPayload holds what changed as JSON, such as the old and new folder
+// of a move or the recipient of a share
+type FileEventPayload map[string]interface{}
+
+// Value implements the driver.Valuer interface for JSON storage
+func (p FileEventPayload) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+// Scan implements the sql.Scanner interface for JSON scanning
+func (p *FileEventPayload) Scan(value interface{}) error {
+	if value == nil {
+		*p = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// FileEvent is an entry in the append-only history of a file. Events are
+// written in the same transaction as the change they describe and are never
+// updated or deleted, so a file's history survives the file itself.
+type FileEvent struct {
+	ID        uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Sequence  int64            `json:"sequence" gorm:"->"`       // assigned by the database
+	TxID      int64            `json:"-" gorm:"column:tx_id;->"` // writing transaction; orders the change feed
+	FileID    uuid.UUID        `json:"file_id" gorm:"type:uuid;not null;index"`
+	OwnerID   uuid.UUID        `json:"owner_id" gorm:"type:uuid;not null;index"`
+	ActorID   *uuid.UUID       `json:"actor_id,omitempty" gorm:"type:uuid"` // nil for changes made by the system
+	Type      FileEventType    `json:"type" gorm:"type:varchar(20);not null"`
+	Payload   FileEventPayload `json:"payload,omitempty" gorm:"type:jsonb"`
+	CreatedAt time.Time        `json:"created_at" gorm:"autoCreateTime"`
+
+	// Relationships
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+// BeforeCreate hook
+func (e *FileEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName returns the table name for GORM
+func (FileEvent) TableName() string {
+	return "file_events"
+}
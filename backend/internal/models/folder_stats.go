@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FolderStats holds running totals for a folder and everything below it. It
+// is updated in the same transaction as every upload, move and delete, so
+// folder sizes and counts never have to be computed from the file table
+type FolderStats struct {
+	FolderID     uuid.UUID `json:"folder_id" gorm:"type:uuid;primary_key"`
+	FileCount    int64     `json:"file_count" gorm:"not null;default:0"`  // files in the whole subtree
+	TotalSize    int64     `json:"total_size" gorm:"not null;default:0"`  // bytes in the whole subtree
+	ChildCount   int64     `json:"child_count" gorm:"not null;default:0"` // direct subfolders only
+	LastModified time.Time `json:"last_modified"`                         // last change anywhere in the subtree
+}
+
+// TableName keeps the plural table name for the already plural model name
+func (FolderStats) TableName() string {
+	return "folder_stats"
+}
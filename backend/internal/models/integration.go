@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IntegrationKind is the chat service an integration posts to
+type IntegrationKind string
+
+const (
+	IntegrationSlack IntegrationKind = "slack"
+	IntegrationTeams IntegrationKind = "teams"
+)
+
+// IntegrationEvent is a kind of event integrations can subscribe to
+type IntegrationEvent string
+
+const (
+	IntegrationEventShareReceived IntegrationEvent = "share_received" // a file or folder was shared with a user
+	IntegrationEventLinkAccessed  IntegrationEvent = "link_accessed"  // someone opened or downloaded through a share link
+	IntegrationEventQuotaAlert    IntegrationEvent = "quota_alert"    // a user went over quota or lost their grace period
+	IntegrationEventModeration    IntegrationEvent = "moderation"     // a file was quarantined or reviewed by an admin
+)
+
+// IntegrationEvents lists every integration event, used to validate subscriptions
+var IntegrationEvents = []IntegrationEvent{
+	IntegrationEventShareReceived,
+	IntegrationEventLinkAccessed,
+	IntegrationEventQuotaAlert,
+	IntegrationEventModeration,
+}
+
+// Integration posts messages about the events it subscribes to to a Slack or
+// Microsoft Teams incoming webhook. A personal integration has a UserID and
+// hears about that user; a team integration has a TenantID and hears about
+// the tenant's members; a platform integration has neither and hears about
+// everyone.
+type Integration struct {
+	ID         uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID     *uuid.UUID      `json:"user_id,omitempty" gorm:"type:uuid;index"`
+	TenantID   *uuid.UUID      `json:"tenant_id,omitempty" gorm:"type:uuid;index"`
+	Kind       IntegrationKind `json:"kind" gorm:"type:varchar(20);not null"`
+	Name       string          `json:"name" gorm:"not null;size:100"`
+	WebhookURL string          `json:"-" gorm:"type:text;not null"` // a secret; responses only show its host
+	Events     StringArray     `json:"events" gorm:"type:text[]"`
+	IsActive   bool            `json:"is_active" gorm:"default:true"`
+	CreatedBy  uuid.UUID       `json:"created_by" gorm:"type:uuid;not null"`
+
+	// Outcome of the latest delivery
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (Integration) TableName() string {
+	return "integrations"
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoginMethod is how a user tried to sign in
+type LoginMethod string
+
+const (
+	LoginMethodPassword LoginMethod = "password"
+	LoginMethodSAML     LoginMethod = "saml"
+)
+
+// LoginEvent records one successful or failed sign-in attempt
+type LoginEvent struct {
+	ID            uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID        *uuid.UUID  `json:"user_id,omitempty" gorm:"type:uuid;index"` // nil when the email matched no account
+	Email         string      `json:"email" gorm:"size:255"`
+	Success       bool        `json:"success"`
+	FailureReason string      `json:"failure_reason,omitempty" gorm:"size:50"`
+	Method        LoginMethod `json:"method" gorm:"type:varchar(20)"`
+	IPAddress     string      `json:"ip_address" gorm:"size:45"`
+	UserAgent     string      `json:"user_agent" gorm:"type:text"`
+	Country       string      `json:"country,omitempty" gorm:"size:2"`
+	DeviceID      *uuid.UUID  `json:"device_id,omitempty" gorm:"type:uuid"`
+	NewDevice     bool        `json:"new_device"` // first successful login from this browser or client and location
+	CreatedAt     time.Time   `json:"created_at" gorm:"autoCreateTime"`
+}
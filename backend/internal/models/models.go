@@ -28,6 +28,21 @@ type UserRoleType string
 const (
 	RoleUser  UserRoleType = "user"
 	RoleAdmin UserRoleType = "admin"
+	RoleGuest UserRoleType = "guest" // external collaborator who only sees what is shared with them
+
+	// RoleTenantAdmin manages the users and settings of their own tenant
+	RoleTenantAdmin UserRoleType = "tenant_admin"
+)
+
+// LifecycleState tracks how far an inactive account has moved through the
+// inactivity policy
+type LifecycleState string
+
+const (
+	LifecycleActive   LifecycleState = "active"
+	LifecycleWarned   LifecycleState = "warned"    // told the account will be restricted
+	LifecycleReadOnly LifecycleState = "read_only" // may sign in and download but not change anything
+	LifecycleArchived LifecycleState = "archived"  // deactivated until an admin restores it
 )
 
 // User represents a user in the system
@@ -41,16 +56,44 @@ type User struct {
 	Role         UserRoleType `json:"role" gorm:"type:varchar(20);default:'user'"`
 	StorageQuota int64        `json:"storageQuota" gorm:"default:10485760"` // 10MB default
 	StorageUsed  int64        `json:"storageUsed" gorm:"default:0"`
+	PlanID       *uuid.UUID   `json:"planId,omitempty" gorm:"type:uuid"`
+	Plan         *Plan        `json:"plan,omitempty" gorm:"foreignKey:PlanID"`
+
+	// TenantID is the organization the user belongs to; nil for platform users
+	TenantID *uuid.UUID `json:"tenantId,omitempty" gorm:"type:uuid;index"`
+
+	// Grace overage: set when an upload first takes the user over quota
+	QuotaGraceStartedAt *time.Time `json:"quotaGraceStartedAt,omitempty"`
+	QuotaGraceExpiresAt *time.Time `json:"quotaGraceExpiresAt,omitempty"`
+	QuotaGraceRevokedAt *time.Time `json:"quotaGraceRevokedAt,omitempty"` // grace ran out while still over quota
 
 	// Storage savings tracking for deduplication
 	TotalUploadedBytes int64 `json:"totalUploadedBytes" gorm:"default:0"` // Total bytes uploaded by user
 	ActualStorageBytes int64 `json:"actualStorageBytes" gorm:"default:0"` // Actual storage used (after deduplication)
 	SavedBytes         int64 `json:"savedBytes" gorm:"default:0"`         // Bytes saved through deduplication
 
+	// AutoTaggingEnabled lets the classification worker tag the user's files
+	AutoTaggingEnabled bool `json:"autoTaggingEnabled" gorm:"default:true"`
+
+	// DefaultUploadFolderID receives uploads that name no folder and match
+	// none of the user's upload rules; nil for the root folder
+	DefaultUploadFolderID *uuid.UUID `json:"defaultUploadFolderId,omitempty" gorm:"type:uuid"`
+
 	IsActive      bool       `json:"isActive" gorm:"default:true"`
 	EmailVerified bool       `json:"emailVerified" gorm:"default:false"`
 	LastLogin     *time.Time `json:"lastLogin,omitempty"`
 
+	// Inactivity policy: the stage reached and when the account entered it
+	LifecycleState     LifecycleState `json:"lifecycleState" gorm:"type:varchar(20);default:'active'"`
+	LifecycleChangedAt *time.Time     `json:"lifecycleChangedAt,omitempty"`
+
+	// Guest accounts are created by an owner when sharing and stop working at GuestExpiresAt
+	GuestExpiresAt *time.Time `json:"guestExpiresAt,omitempty"`
+	InvitedBy      *uuid.UUID `json:"invitedBy,omitempty" gorm:"type:uuid"`
+
+	// SSOSubject is the SAML NameID the identity provider asserts for this user
+	SSOSubject *string `json:"ssoSubject,omitempty" gorm:"size:255"`
+
 	// Relationships
 	Roles         []Role         `json:"roles" gorm:"many2many:user_roles;"`
 	Files         []File         `json:"files" gorm:"foreignKey:OwnerID"`
@@ -59,6 +102,45 @@ type User struct {
 	DownloadStats []DownloadStat `json:"download_stats" gorm:"foreignKey:DownloadedBy"`
 }
 
+// GuestExpired reports whether the user is a guest whose account has expired
+func (u *User) GuestExpired(now time.Time) bool {
+	return u.Role == RoleGuest && u.GuestExpiresAt != nil && !now.Before(*u.GuestExpiresAt)
+}
+
+// QuotaLimit returns the most storage the user may occupy. With grace enabled
+// the quota may be exceeded by gracePercent until the grace window expires or
+// is revoked; afterwards the plain quota applies again
+func (u *User) QuotaLimit(gracePercent int, now time.Time) int64 {
+	if gracePercent <= 0 || u.QuotaGraceRevokedAt != nil {
+		return u.StorageQuota
+	}
+	if u.QuotaGraceExpiresAt != nil && now.After(*u.QuotaGraceExpiresAt) {
+		return u.StorageQuota
+	}
+	return u.StorageQuota + u.StorageQuota*int64(gracePercent)/100
+}
+
+// MaxUploadSize returns the largest file the user may upload. A plan can lower
+// the server-wide limit but never raise it
+func (u *User) MaxUploadSize(serverMax int64) int64 {
+	if u.Plan != nil && u.Plan.MaxFileSize > 0 && u.Plan.MaxFileSize < serverMax {
+		return u.Plan.MaxFileSize
+	}
+	return serverMax
+}
+
+// AllowsPublicSharing reports whether the user's plan permits public files and links
+func (u *User) AllowsPublicSharing() bool {
+	return u.Plan == nil || u.Plan.AllowPublicSharing
+}
+
+// InQuotaGrace reports whether the user is over quota on an active grace window
+func (u *User) InQuotaGrace(now time.Time) bool {
+	return u.StorageUsed > u.StorageQuota &&
+		u.QuotaGraceRevokedAt == nil &&
+		u.QuotaGraceExpiresAt != nil && !now.After(*u.QuotaGraceExpiresAt)
+}
+
 // UserRole represents the many-to-many relationship between users and roles
 type UserRole struct {
 	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
@@ -80,8 +162,53 @@ type FileHash struct {
 	StoragePath    string    `json:"storage_path" gorm:"not null;type:text"`
 	ReferenceCount int       `json:"reference_count" gorm:"default:0"`
 	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	// When the orphan blob sweep deleted the content of this unreferenced blob
+	ContentRemovedAt *time.Time `json:"content_removed_at,omitempty"`
+
+	// Replication to the secondary storage backend
+	ReplicationStatus   ReplicationStatus `json:"replication_status" gorm:"type:varchar(20);default:'pending'"`
+	ReplicationAttempts int               `json:"replication_attempts" gorm:"default:0"`
+	ReplicationError    string            `json:"replication_error,omitempty" gorm:"type:text"`
+	ReplicatedAt        *time.Time        `json:"replicated_at,omitempty"`
+
+	// Cold storage tiering
+	StorageTier        StorageTier `json:"storage_tier" gorm:"type:varchar(20);default:'hot'"`
+	ArchivedAt         *time.Time  `json:"archived_at,omitempty"`
+	RestoredAt         *time.Time  `json:"restored_at,omitempty"`
+	RestoreRequestedAt *time.Time  `json:"restore_requested_at,omitempty"`
+	RestoreRequestedBy *uuid.UUID  `json:"restore_requested_by,omitempty" gorm:"type:uuid"`
+
+	// Malware scanning
+	MalwareScannedAt *time.Time `json:"malware_scanned_at,omitempty"`
+	MalwareSignature string     `json:"malware_signature,omitempty" gorm:"size:255"` // threat found by the last scan
+
+	// Image metadata, read when the content is first listed in a gallery or
+	// timeline. Dimensions are 0 when the image could not be read, and
+	// CapturedAt is the camera's wall-clock time from EXIF, in UTC
+	Width      *int       `json:"width,omitempty"`
+	Height     *int       `json:"height,omitempty"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
 }
 
+// StorageTier represents where a blob's content currently lives
+type StorageTier string
+
+const (
+	StorageTierHot       StorageTier = "hot"       // in primary storage
+	StorageTierArchived  StorageTier = "archived"  // moved to cold storage
+	StorageTierRestoring StorageTier = "restoring" // restore from cold storage requested
+)
+
+// ReplicationStatus represents the state of a blob's copy on the replica
+type ReplicationStatus string
+
+const (
+	ReplicationPending    ReplicationStatus = "pending"
+	ReplicationReplicated ReplicationStatus = "replicated"
+	ReplicationFailed     ReplicationStatus = "failed"
+)
+
 // Folder represents a folder for organizing files
 type Folder struct {
 	BaseModel
@@ -90,32 +217,66 @@ type Folder struct {
 	OwnerID  uuid.UUID  `json:"owner_id" gorm:"type:uuid;not null"`
 	Path     string     `json:"path" gorm:"not null"` // Full path for quick lookups
 
+	// Write-once retention: files placed here are locked for RetentionDays
+	IsWORM        bool       `json:"is_worm" gorm:"column:is_worm;default:false"`
+	RetentionDays int        `json:"retention_days" gorm:"default:0"`
+	WORMEnabledAt *time.Time `json:"worm_enabled_at,omitempty" gorm:"column:worm_enabled_at"`
+
+	// Optional cap on the total size of files in the folder and its subfolders
+	SizeLimit *int64 `json:"size_limit,omitempty"`
+
+	// End-to-end encrypted folder this folder belongs to: its own ID for the
+	// top folder of the vault, which holds the wrapped keys, nil otherwise
+	VaultID *uuid.UUID `json:"vault_id,omitempty" gorm:"type:uuid;index"`
+
 	// Relationships
-	Parent   *Folder  `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
-	Children []Folder `json:"children" gorm:"foreignKey:ParentID"`
-	Owner    User     `json:"owner" gorm:"foreignKey:OwnerID"`
-	Files    []File   `json:"files" gorm:"foreignKey:FolderID"`
+	Parent   *Folder      `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
+	Children []Folder     `json:"children" gorm:"foreignKey:ParentID"`
+	Owner    User         `json:"owner" gorm:"foreignKey:OwnerID"`
+	Files    []File       `json:"files" gorm:"foreignKey:FolderID"`
+	Stats    *FolderStats `json:"stats,omitempty" gorm:"foreignKey:FolderID"`
 
 	// Folder sharing relationships
 	FolderShares     []FolderShare     `json:"folder_shares" gorm:"foreignKey:FolderID"`
 	FolderShareLinks []FolderShareLink `json:"folder_share_links" gorm:"foreignKey:FolderID"`
 }
 
+// ProcessingStatus is how far the scanning pipeline has got with a file's
+// content. Files are clean when nothing is left to check: their content was
+// scanned, an admin released them from quarantine, or scanning is off.
+type ProcessingStatus string
+
+const (
+	ProcessingStatusPendingScan ProcessingStatus = "pending_scan" // waiting for the background malware scan
+	ProcessingStatusClean       ProcessingStatus = "clean"
+	ProcessingStatusQuarantined ProcessingStatus = "quarantined" // locked pending quarantine review
+	ProcessingStatusFailed      ProcessingStatus = "failed"      // the last scan could not complete; retried every pass
+)
+
 // File represents a file in the system
 type File struct {
 	BaseModel
-	Filename         string     `json:"filename" gorm:"not null;size:255"`
-	OriginalFilename string     `json:"original_filename" gorm:"not null;size:255"`
-	MimeType         string     `json:"mime_type" gorm:"not null;size:100"`
-	Size             int64      `json:"size" gorm:"not null"`
-	FileHashID       uuid.UUID  `json:"file_hash_id" gorm:"type:uuid;not null;index"` // Reference to FileHash
-	OwnerID          uuid.UUID  `json:"owner_id" gorm:"type:uuid;not null"`
-	FolderID         *uuid.UUID `json:"folder_id,omitempty" gorm:"type:uuid"`
-	Tags             []string   `json:"tags" gorm:"type:text[]"`
-	Description      string     `json:"description" gorm:"type:text"`
-	IsDeleted        bool       `json:"is_deleted" gorm:"default:false"`
-	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
-	IsPublic         bool       `json:"is_public" gorm:"default:false"`
+	Filename         string           `json:"filename" gorm:"not null;size:255"`
+	OriginalFilename string           `json:"original_filename" gorm:"not null;size:255"`
+	MimeType         string           `json:"mime_type" gorm:"not null;size:100"`
+	Size             int64            `json:"size" gorm:"not null"`
+	FileHashID       uuid.UUID        `json:"file_hash_id" gorm:"type:uuid;not null;index"` // Reference to FileHash
+	OwnerID          uuid.UUID        `json:"owner_id" gorm:"type:uuid;not null"`
+	FolderID         *uuid.UUID       `json:"folder_id,omitempty" gorm:"type:uuid"`
+	Tags             StringArray      `json:"tags" gorm:"type:text[]"`
+	AutoTags         StringArray      `json:"auto_tags" gorm:"type:text[]"` // applied by the classification worker
+	ClassifiedAt     *time.Time       `json:"classified_at,omitempty"`
+	Description      string           `json:"description" gorm:"type:text"`
+	IsPublic         bool             `json:"is_public" gorm:"default:false"`
+	StorageTier      StorageTier      `json:"storage_tier" gorm:"type:varchar(20);default:'hot'"`        // mirrors FileHash.StorageTier
+	IsQuarantined    bool             `json:"is_quarantined" gorm:"default:false"`                       // locked pending quarantine review
+	ProcessingStatus ProcessingStatus `json:"processing_status" gorm:"type:varchar(20);default:'clean'"` // how far malware scanning of the content has got
+	NotifyOnDownload bool             `json:"notify_on_download" gorm:"default:false"`                   // notify the owner whenever someone else downloads it
+	SHA256           string           `json:"sha256,omitempty" gorm:"-"`                                 // content checksum filled from FileHash for responses
+	WORMLockedAt     *time.Time       `json:"worm_locked_at,omitempty" gorm:"column:worm_locked_at"`
+	RetainUntil      *time.Time       `json:"retain_until,omitempty"`                       // cannot be deleted, moved or renamed before this time
+	IsEncrypted      bool             `json:"is_encrypted" gorm:"default:false"`            // content encrypted by the client in a vault folder
+	EncryptionHeader string           `json:"encryption_header,omitempty" gorm:"type:text"` // client's per-file key and nonce, opaque to the server
 
 	// Relationships
 	FileHash      *FileHash      `json:"file_hash,omitempty" gorm:"foreignKey:FileHashID"`
@@ -130,6 +291,35 @@ type File struct {
 	IsShared   bool `json:"is_shared" gorm:"default:false"`
 }
 
+// IsEncrypted reports whether the folder is in an end-to-end encrypted vault
+func (f *Folder) IsEncrypted() bool {
+	return f.VaultID != nil
+}
+
+// IsDeleted reports whether the file was soft-deleted. BaseModel.DeletedAt is
+// the only deletion marker: GORM leaves deleted files out of every query on
+// File unless it is Unscoped.
+func (f *File) IsDeleted() bool {
+	return f.DeletedAt.Valid
+}
+
+// ActiveFiles scopes a query that reaches files through a join or raw
+// condition, where GORM does not apply its soft-delete filter, to files that
+// are not deleted
+func ActiveFiles(db *gorm.DB) *gorm.DB {
+	return db.Where("files.deleted_at IS NULL")
+}
+
+// TrashedFiles scopes a files query to deleted files only
+func TrashedFiles(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("files.deleted_at IS NOT NULL")
+}
+
+// RetentionLocked reports whether the file is still under WORM retention
+func (f *File) RetentionLocked() bool {
+	return f.RetainUntil != nil && time.Now().Before(*f.RetainUntil)
+}
+
 // SharePermission represents access permissions for sharing
 type SharePermission string
 
@@ -138,6 +328,18 @@ const (
 	PermissionDownload SharePermission = "download"
 )
 
+// ShareStatus is a recipient's answer to a file or folder share
+type ShareStatus string
+
+const (
+	ShareStatusPending  ShareStatus = "pending"
+	ShareStatusAccepted ShareStatus = "accepted"
+	ShareStatusDeclined ShareStatus = "declined"
+)
+
+// ShareStatuses lists every share status, used to validate filters
+var ShareStatuses = []ShareStatus{ShareStatusPending, ShareStatusAccepted, ShareStatusDeclined}
+
 // FileShare represents internal sharing between users
 type FileShare struct {
 	BaseModel
@@ -149,12 +351,27 @@ type FileShare struct {
 	ExpiresAt  *time.Time      `json:"expires_at,omitempty"`
 	IsActive   bool            `json:"is_active" gorm:"default:true"`
 
+	// Recipient's handling of the share
+	Status      ShareStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	RespondedAt *time.Time  `json:"responded_at,omitempty"`
+	HiddenAt    *time.Time  `json:"hidden_at,omitempty"` // hidden from the recipient's shared listing
+
 	// Relationships
 	File           File `json:"file" gorm:"foreignKey:FileID"`
 	SharedByUser   User `json:"shared_by_user" gorm:"foreignKey:SharedBy"`
 	SharedWithUser User `json:"shared_with_user" gorm:"foreignKey:SharedWith"`
 }
 
+// WarmStatus is how far pre-warming of a share link's file has got. Links
+// created while pre-warming is off have no status.
+type WarmStatus string
+
+const (
+	WarmStatusPending WarmStatus = "pending"
+	WarmStatusWarm    WarmStatus = "warm"
+	WarmStatusFailed  WarmStatus = "failed"
+)
+
 // ShareLink represents external shareable links
 type ShareLink struct {
 	BaseModel
@@ -169,6 +386,18 @@ type ShareLink struct {
 	IsActive       bool            `json:"is_active" gorm:"default:true"`
 	LastAccessedAt *time.Time      `json:"last_accessed_at,omitempty"`
 
+	NotifyOnDownload bool `json:"notify_on_download" gorm:"default:false"` // notify the creator of every download through this link
+
+	// Content opened through the link is stamped with the viewer, their IP
+	// address and the time, followed by WatermarkText when set
+	Watermark     bool   `json:"watermark" gorm:"default:false"`
+	WatermarkText string `json:"watermark_text" gorm:"size:100;default:''"`
+
+	// Pre-warming of caches and the CDN origin for the linked file
+	WarmStatus WarmStatus `json:"warm_status" gorm:"type:varchar(20);default:''"`
+	WarmedAt   *time.Time `json:"warmed_at,omitempty"`
+	WarmError  string     `json:"warm_error,omitempty" gorm:"type:text;default:''"`
+
 	// Relationships
 	File          File                 `json:"file" gorm:"foreignKey:FileID"`
 	CreatedByUser User                 `json:"created_by_user" gorm:"foreignKey:CreatedBy"`
@@ -214,24 +443,40 @@ type FolderShare struct {
 	Permission SharePermission `json:"permission" gorm:"type:varchar(20);default:'view'"`
 	Message    string          `json:"message" gorm:"type:text"`
 
+	// Recipient's handling of the share
+	Status      ShareStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	RespondedAt *time.Time  `json:"responded_at,omitempty"`
+	HiddenAt    *time.Time  `json:"hidden_at,omitempty"` // hidden from the recipient's shared listing
+
 	// Relationships
 	Folder         Folder `json:"folder" gorm:"foreignKey:FolderID"`
 	SharedByUser   User   `json:"shared_by_user" gorm:"foreignKey:SharedBy"`
 	SharedWithUser User   `json:"shared_with_user" gorm:"foreignKey:SharedWith"`
 }
 
+// FolderShareLinkMode is how a folder share link presents the folder
+type FolderShareLinkMode string
+
+const (
+	// FolderShareLinkModeFolder lists the folder's files
+	FolderShareLinkModeFolder FolderShareLinkMode = "folder"
+	// FolderShareLinkModeAlbum shows the folder's photos and videos as an album
+	FolderShareLinkModeAlbum FolderShareLinkMode = "album"
+)
+
 // FolderShareLink represents a shareable link for a folder
 type FolderShareLink struct {
 	BaseModel
-	FolderID      uuid.UUID       `json:"folder_id" gorm:"type:uuid;not null"`
-	CreatedBy     uuid.UUID       `json:"created_by" gorm:"type:uuid;not null"`
-	Token         string          `json:"token" gorm:"unique;not null;size:255"`
-	PasswordHash  string          `json:"password_hash,omitempty" gorm:"size:255"`
-	Permission    SharePermission `json:"permission" gorm:"type:varchar(20);default:'view'"`
-	ExpiresAt     *time.Time      `json:"expires_at,omitempty"`
-	IsActive      bool            `json:"is_active" gorm:"default:true"`
-	MaxDownloads  *int            `json:"max_downloads,omitempty"`
-	DownloadCount int             `json:"download_count" gorm:"default:0"`
+	FolderID      uuid.UUID           `json:"folder_id" gorm:"type:uuid;not null"`
+	CreatedBy     uuid.UUID           `json:"created_by" gorm:"type:uuid;not null"`
+	Token         string              `json:"token" gorm:"unique;not null;size:255"`
+	PasswordHash  string              `json:"password_hash,omitempty" gorm:"size:255"`
+	Permission    SharePermission     `json:"permission" gorm:"type:varchar(20);default:'view'"`
+	Mode          FolderShareLinkMode `json:"mode" gorm:"type:varchar(20);default:'folder'"`
+	ExpiresAt     *time.Time          `json:"expires_at,omitempty"`
+	IsActive      bool                `json:"is_active" gorm:"default:true"`
+	MaxDownloads  *int                `json:"max_downloads,omitempty"`
+	DownloadCount int                 `json:"download_count" gorm:"default:0"`
 
 	// Relationships
 	Folder        Folder                     `json:"folder" gorm:"foreignKey:FolderID"`
@@ -255,8 +500,8 @@ type FolderShareLinkAccessLog struct {
 // APIRateLimit tracks API rate limiting per user
 type APIRateLimit struct {
 	ID             uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	UserID         uuid.UUID     `json:"user_id" gorm:"type:uuid;not null"`
-	Endpoint       string        `json:"endpoint" gorm:"not null;size:255"`
+	UserID         uuid.UUID     `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_api_rate_limits_user_endpoint"`
+	Endpoint       string        `json:"endpoint" gorm:"not null;size:255;uniqueIndex:idx_api_rate_limits_user_endpoint"` // route pattern, e.g. /api/v1/files/:id
 	RequestCount   int           `json:"request_count" gorm:"default:0"`
 	WindowStart    time.Time     `json:"window_start" gorm:"autoCreateTime"`
 	WindowDuration time.Duration `json:"window_duration" gorm:"default:1000000000"` // 1 second in nanoseconds
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationType identifies what triggered a notification
+type NotificationType string
+
+const (
+	NotificationStorageCapacity  NotificationType = "storage_capacity"
+	NotificationBackup           NotificationType = "backup"
+	NotificationArchiveRestore   NotificationType = "archive_restore"
+	NotificationQuarantine       NotificationType = "quarantine"
+	NotificationQuotaGrace       NotificationType = "quota_grace"
+	NotificationDownload         NotificationType = "download"
+	NotificationNewLogin         NotificationType = "new_login"
+	NotificationAccountLifecycle NotificationType = "account_lifecycle"
+	NotificationShareReceived    NotificationType = "share_received"
+)
+
+// NotificationSeverity represents how urgent a notification is
+type NotificationSeverity string
+
+const (
+	NotificationSeverityInfo     NotificationSeverity = "info"
+	NotificationSeverityWarning  NotificationSeverity = "warning"
+	NotificationSeverityCritical NotificationSeverity = "critical"
+)
+
+// NotificationDetails holds additional metadata as JSON
+type NotificationDetails map[string]interface{}
+
+// Value implements the driver.Valuer interface for JSON storage
+func (d NotificationDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+// Scan implements the sql.Scanner interface for JSON scanning
+func (d *NotificationDetails) Scan(value interface{}) error {
+	if value == nil {
+		*d = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, d)
+}
+
+// Notification is an in-app message delivered to a single user
+type Notification struct {
+	ID        uuid.UUID            `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID            `json:"user_id" gorm:"type:uuid;not null;index"`
+	Type      NotificationType     `json:"type" gorm:"type:varchar(50);not null"`
+	Severity  NotificationSeverity `json:"severity" gorm:"type:varchar(20);default:'info'"`
+	Title     string               `json:"title" gorm:"not null;size:255"`
+	Message   string               `json:"message" gorm:"type:text;not null"`
+	Details   NotificationDetails  `json:"details,omitempty" gorm:"type:jsonb"`
+	ReadAt    *time.Time           `json:"read_at,omitempty"`
+	CreatedAt time.Time            `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for GORM
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// NotificationPreference records whether a user also gets notifications of a
+// type by email. Types without a row are emailed
+type NotificationPreference struct {
+	UserID    uuid.UUID        `json:"-" gorm:"type:uuid;primaryKey"`
+	Type      NotificationType `json:"type" gorm:"type:varchar(50);primaryKey"`
+	Email     bool             `json:"email"`
+	UpdatedAt time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
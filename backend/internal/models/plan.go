@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Plan bundles the storage quota and feature limits assigned to users
+type Plan struct {
+	ID                 uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Name               string    `json:"name" gorm:"unique;not null;size:100"`
+	Description        string    `json:"description" gorm:"type:text"`
+	StorageQuota       int64     `json:"storage_quota" gorm:"not null"`
+	MaxFileSize        int64     `json:"max_file_size"`    // 0 uses the server limit
+	RateLimit          int       `json:"rate_limit"`       // requests per second; 0 uses the server limit
+	RateLimitBurst     int       `json:"rate_limit_burst"` // 0 uses the server burst
+	AllowPublicSharing bool      `json:"allow_public_sharing"`
+	IsDefault          bool      `json:"is_default"` // assigned to newly registered users
+	CreatedAt          time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (Plan) TableName() string {
+	return "plans"
+}
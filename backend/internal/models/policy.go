@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PolicyDocumentType identifies which policy a document is a version of
+type PolicyDocumentType string
+
+const (
+	PolicyDocumentTerms   PolicyDocumentType = "terms"
+	PolicyDocumentPrivacy PolicyDocumentType = "privacy"
+)
+
+// PolicyDocument is one published version of a terms-of-service or privacy policy.
+// The current version of each type is the newest one whose effective date has passed.
+type PolicyDocument struct {
+	ID          uuid.UUID          `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Type        PolicyDocumentType `json:"type" gorm:"type:varchar(20);not null"`
+	Version     string             `json:"version" gorm:"type:varchar(50);not null"`
+	Title       string             `json:"title" gorm:"not null;size:255"`
+	Content     string             `json:"content" gorm:"type:text;not null"`
+	EffectiveAt time.Time          `json:"effective_at" gorm:"not null"`
+	CreatedBy   *uuid.UUID         `json:"created_by,omitempty" gorm:"type:uuid"`
+	CreatedAt   time.Time          `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for GORM
+func (PolicyDocument) TableName() string {
+	return "policy_documents"
+}
+
+// PolicyAcceptance records that a user accepted a policy version, kept for compliance
+type PolicyAcceptance struct {
+	ID         uuid.UUID          `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID     uuid.UUID          `json:"user_id" gorm:"type:uuid;not null"`
+	DocumentID uuid.UUID          `json:"document_id" gorm:"type:uuid;not null"`
+	Type       PolicyDocumentType `json:"type" gorm:"type:varchar(20);not null"`
+	Version    string             `json:"version" gorm:"type:varchar(50);not null"`
+	IPAddress  string             `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent  string             `json:"user_agent" gorm:"type:text"`
+	AcceptedAt time.Time          `json:"accepted_at" gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for GORM
+func (PolicyAcceptance) TableName() string {
+	return "policy_acceptances"
+}
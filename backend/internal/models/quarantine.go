@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuarantineSource identifies which inspection flagged a file
+type QuarantineSource string
+
+const (
+	QuarantineSourceDLP     QuarantineSource = "dlp"
+	QuarantineSourceMalware QuarantineSource = "malware"
+)
+
+// QuarantineStatus tracks an item through the admin review queue
+type QuarantineStatus string
+
+const (
+	QuarantineStatusPending  QuarantineStatus = "pending"  // awaiting admin review
+	QuarantineStatusReleased QuarantineStatus = "released" // admin cleared the file
+	QuarantineStatusRejected QuarantineStatus = "rejected" // admin confirmed the file stays locked
+	QuarantineStatusDeleted  QuarantineStatus = "deleted"  // admin deleted the file
+)
+
+// QuarantineFinding is one category of problem detected in a file
+type QuarantineFinding struct {
+	Rule    string   `json:"rule"`
+	Label   string   `json:"label"`
+	Count   int      `json:"count"`
+	Samples []string `json:"samples,omitempty"`
+	Source  string   `json:"source"`
+}
+
+// QuarantineFindings holds the findings as JSON
+type QuarantineFindings []QuarantineFinding
+
+// Value implements the driver.Valuer interface for JSON storage
+func (f QuarantineFindings) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+	return json.Marshal(f)
+}
+
+// Scan implements the sql.Scanner interface for JSON scanning
+func (f *QuarantineFindings) Scan(value interface{}) error {
+	if value == nil {
+		*f = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, f)
+}
+
+// FileQuarantine is an entry in the admin quarantine review queue
+type FileQuarantine struct {
+	ID         uuid.UUID          `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	FileID     uuid.UUID          `json:"file_id" gorm:"type:uuid;not null;index"`
+	OwnerID    uuid.UUID          `json:"owner_id" gorm:"type:uuid;not null"`
+	Source     QuarantineSource   `json:"source" gorm:"type:varchar(20);not null"`
+	Reason     string             `json:"reason" gorm:"type:text;not null"`
+	Findings   QuarantineFindings `json:"findings" gorm:"type:jsonb"`
+	Status     QuarantineStatus   `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	ReviewedBy *uuid.UUID         `json:"reviewed_by,omitempty" gorm:"type:uuid"`
+	ReviewedAt *time.Time         `json:"reviewed_at,omitempty"`
+	ReviewNote string             `json:"review_note,omitempty" gorm:"type:text"`
+	CreatedAt  time.Time          `json:"created_at" gorm:"autoCreateTime"`
+
+	// Relationships
+	File  *File `json:"file,omitempty" gorm:"foreignKey:FileID"`
+	Owner *User `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+}
+
+// TableName returns the table name for GORM
+func (FileQuarantine) TableName() string {
+	return "file_quarantines"
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RateLimitOverride changes the rate limits one user runs into. It either
+// exempts them, scales the limits that would otherwise apply by a multiplier,
+// or replaces them with an absolute limit.
+type RateLimitOverride struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Exempt         bool       `json:"exempt" gorm:"not null;default:false"`
+	Multiplier     float64    `json:"multiplier" gorm:"not null;default:0"`       // 0 leaves the limits unscaled
+	RateLimit      int        `json:"rate_limit" gorm:"not null;default:0"`       // requests per window replacing every other limit; 0 unused
+	RateLimitBurst int        `json:"rate_limit_burst" gorm:"not null;default:0"` // 0 uses rate_limit
+	Reason         string     `json:"reason" gorm:"type:text"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty" gorm:"type:uuid"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (RateLimitOverride) TableName() string {
+	return "rate_limit_overrides"
+}
+
+// Expired reports whether the override no longer applies
+func (o *RateLimitOverride) Expired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
+}
+
+// Apply returns the limit and burst to enforce in place of the given ones,
+// and whether the user is exempt altogether
+func (o *RateLimitOverride) Apply(limit float64, burst int) (float64, int, bool) {
+	switch {
+	case o.Exempt:
+		return limit, burst, true
+	case o.RateLimit > 0:
+		if o.RateLimitBurst > 0 {
+			return float64(o.RateLimit), o.RateLimitBurst, false
+		}
+		return float64(o.RateLimit), o.RateLimit, false
+	case o.Multiplier > 0:
+		return limit * o.Multiplier, int(math.Ceil(float64(burst) * o.Multiplier)), false
+	}
+	return limit, burst, false
+}
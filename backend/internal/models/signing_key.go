@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// LegacySigningKeyID is the key of tokens issued before signing keys were
+// rotated, which carry no kid and are signed with JWT_SECRET itself
+const LegacySigningKeyID = "legacy"
+
+// SigningKey is a key login tokens are signed with, named by the kid in
+// their header. The key itself is derived from JWT_SECRET and the salt, so
+// the database alone cannot sign tokens. One key is current; the keys it
+// replaced keep validating tokens until they expire.
+type SigningKey struct {
+	ID        string     `json:"kid" gorm:"primary_key;size:32"`
+	Salt      string     `json:"-" gorm:"size:64;not null"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"` // when a newer key replaced it
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // tokens signed with it stop working; nil while current
+}
+
+// TableName returns the table name for GORM
+func (SigningKey) TableName() string {
+	return "jwt_signing_keys"
+}
+
+// Current reports whether new tokens are signed with the key
+func (k *SigningKey) Current() bool {
+	return k.RetiredAt == nil
+}
+
+// Valid reports whether tokens signed with the key are accepted at t
+func (k *SigningKey) Valid(t time.Time) bool {
+	return k.ExpiresAt == nil || t.Before(*k.ExpiresAt)
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// StorageRollup is a daily snapshot of global storage. Logical bytes count
+// every live file at its full size; actual bytes count each stored blob
+// once, so the difference is what deduplication saves.
+type StorageRollup struct {
+	Day          time.Time `json:"day" gorm:"type:date;primary_key"`
+	LogicalBytes int64     `json:"logical_bytes" gorm:"not null;default:0"`
+	ActualBytes  int64     `json:"actual_bytes" gorm:"not null;default:0"`
+	SavedBytes   int64     `json:"saved_bytes" gorm:"not null;default:0"`
+	FileCount    int64     `json:"file_count" gorm:"not null;default:0"`
+	BlobCount    int64     `json:"blob_count" gorm:"not null;default:0"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
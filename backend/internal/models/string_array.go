@@ -0,0 +1,60 @@
+package models
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// StringArray maps a Go string slice to a PostgreSQL text[] column. The pgx
+// database/sql driver returns arrays as their text form, which a plain
+// []string cannot be scanned from
+type StringArray []string
+
+// Value implements the driver.Valuer interface, encoding an array literal
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(a))
+	for i, s := range a {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, `"`, `\"`)
+		quoted[i] = `"` + s + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}", nil
+}
+
+// Scan implements the sql.Scanner interface for text[] scanning
+func (a *StringArray) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+
+	// NULL elements are dropped
+	var values []*string
+	if err := pgtype.NewMap().SQLScanner(&values).Scan(value); err != nil {
+		return err
+	}
+	result := make(StringArray, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			result = append(result, *v)
+		}
+	}
+	*a = result
+	return nil
+}
+
+// Contains reports whether the array holds s
+func (a StringArray) Contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tenant is an organization hosted on a shared deployment. Its members are
+// the users whose TenantID points at it; users without one belong to the
+// platform itself.
+type Tenant struct {
+	ID                uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Slug              string    `json:"slug" gorm:"unique;not null;size:63"` // subdomain and header value that select the tenant
+	Name              string    `json:"name" gorm:"not null;size:255"`
+	IsActive          bool      `json:"is_active" gorm:"default:true"`
+	DefaultUserQuota  int64     `json:"default_user_quota"` // 0 uses the server default
+	StorageQuota      int64     `json:"storage_quota"`      // shared by all members; 0 is unlimited
+	AllowRegistration bool      `json:"allow_registration" gorm:"default:true"`
+
+	// Branding shown by clients on the tenant's hosts
+	LogoURL      string `json:"logo_url" gorm:"type:text"`
+	PrimaryColor string `json:"primary_color" gorm:"size:7"`
+	SupportEmail string `json:"support_email" gorm:"size:255"`
+
+	// ShareDomain is the custom host the tenant's share links are served on
+	ShareDomain *string `json:"share_domain,omitempty" gorm:"size:255"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// SameTenant reports whether two tenant IDs name the same tenant, counting
+// two platform users (both nil) as the same
+func SameTenant(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// InTenant scopes a users query to the members of a tenant, or to platform
+// users when tenantID is nil
+func InTenant(tenantID *uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantID == nil {
+			return db.Where("users.tenant_id IS NULL")
+		}
+		return db.Where("users.tenant_id = ?", *tenantID)
+	}
+}
+
+// InSameTenantAs scopes a users query to the users in the same tenant as the
+// given user, so lookups by email never reach into another organization
+func InSameTenantAs(userID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`COALESCE(CAST(users.tenant_id AS TEXT), '') =
+			COALESCE((SELECT CAST(t.tenant_id AS TEXT) FROM users t WHERE t.id = ?), '')`, userID)
+	}
+}
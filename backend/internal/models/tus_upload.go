@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TusUpload is a resumable upload sent with the tus protocol
+// (https://tus.io/protocols/resumable-upload). Unlike an UploadSession its
+// content arrives in order: each PATCH appends to what was received, and
+// Offset is how far the upload has got. An interrupted client asks for the
+// offset and carries on from there.
+type TusUpload struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OwnerID          uuid.UUID  `json:"owner_id" gorm:"type:uuid;not null;index"`
+	FolderID         *uuid.UUID `json:"folder_id,omitempty" gorm:"type:uuid"`
+	Filename         string     `json:"filename" gorm:"not null;size:255"`
+	MimeType         string     `json:"mime_type" gorm:"not null;size:100"` // declared by the client
+	Size             int64      `json:"size" gorm:"not null"`
+	Offset           int64      `json:"offset" gorm:"column:upload_offset;not null;default:0"`
+	Metadata         string     `json:"metadata" gorm:"type:text"` // the Upload-Metadata header, echoed back on HEAD
+	IsPublic         bool       `json:"is_public" gorm:"default:false"`
+	EncryptionHeader string     `json:"encryption_header,omitempty" gorm:"type:text"` // for uploads to encrypted folders
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Complete reports whether every byte of the upload has been received
+func (u *TusUpload) Complete() bool {
+	return u.Offset >= u.Size
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PolicyMatchType selects what an upload policy pattern is compared against
+type PolicyMatchType string
+
+const (
+	PolicyMatchMime      PolicyMatchType = "mime"      // pattern like "video/*" or "image/png"
+	PolicyMatchExtension PolicyMatchType = "extension" // pattern like ".exe"
+)
+
+// UploadPolicy restricts uploads of a file type for a role
+type UploadPolicy struct {
+	ID          uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Role        string          `json:"role" gorm:"type:varchar(20);default:''"` // empty applies to every role
+	MatchType   PolicyMatchType `json:"match_type" gorm:"type:varchar(20);not null"`
+	Pattern     string          `json:"pattern" gorm:"not null;size:100"`
+	Blocked     bool            `json:"blocked" gorm:"default:false"`
+	MaxSize     *int64          `json:"max_size,omitempty"`
+	AllowPublic bool            `json:"allow_public"`
+	Description string          `json:"description" gorm:"type:text"`
+	CreatedBy   *uuid.UUID      `json:"created_by,omitempty" gorm:"type:uuid"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for GORM
+func (UploadPolicy) TableName() string {
+	return "upload_policies"
+}
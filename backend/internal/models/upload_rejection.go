@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadRejectionReason says why an upload was refused
+type UploadRejectionReason string
+
+const (
+	RejectionSizeExceeded     UploadRejectionReason = "size_exceeded"     // larger than MAX_FILE_SIZE
+	RejectionMimeMismatch     UploadRejectionReason = "mime_mismatch"     // content does not match the declared type
+	RejectionMimeNotAllowed   UploadRejectionReason = "mime_not_allowed"  // detected type is not in ALLOWED_MIME_TYPES
+	RejectionPolicyViolation  UploadRejectionReason = "policy_violation"  // blocked by an upload policy
+	RejectionSensitiveContent UploadRejectionReason = "sensitive_content" // blocked by DLP
+	RejectionScanUnavailable  UploadRejectionReason = "scan_unavailable"  // DLP or malware scanning failed closed
+	RejectionMalwareDetected  UploadRejectionReason = "malware_detected"  // flagged by the malware scanner
+	RejectionQuotaExceeded    UploadRejectionReason = "quota_exceeded"    // over the user's storage quota
+)
+
+// UploadRejection records a refused upload so admins can diagnose
+// misconfigured allowlists and spot clients that keep retrying
+type UploadRejection struct {
+	ID               uuid.UUID             `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID           uuid.UUID             `json:"user_id" gorm:"type:uuid;not null"`
+	Reason           UploadRejectionReason `json:"reason" gorm:"type:varchar(30);not null"`
+	Code             string                `json:"code,omitempty" gorm:"type:varchar(50)"`
+	Message          string                `json:"message" gorm:"type:text"`
+	Filename         string                `json:"filename" gorm:"size:255"`
+	DeclaredMimeType string                `json:"declared_mime_type,omitempty" gorm:"size:100"`
+	DetectedMimeType string                `json:"detected_mime_type,omitempty" gorm:"size:100"`
+	Size             int64                 `json:"size"`
+	IPAddress        string                `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent        string                `json:"user_agent" gorm:"type:text"`
+	CreatedAt        time.Time             `json:"created_at" gorm:"autoCreateTime"`
+
+	// Relationships
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// TableName returns the table name for GORM
+func (UploadRejection) TableName() string {
+	return "upload_rejections"
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadRule sends a user's uploads that name no folder to a folder by their
+// type, such as images to Photos. A rule matches on MimeType, Extension or
+// both; the user's rules are tried in Position order and the first match
+// wins.
+type UploadRule struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Position  int       `json:"position" gorm:"not null"`
+	MimeType  string    `json:"mime_type,omitempty" gorm:"size:100"` // a type such as application/pdf, or a family such as image/*
+	Extension string    `json:"extension,omitempty" gorm:"size:20"`  // lower-case, without the dot
+	FolderID  uuid.UUID `json:"folder_id" gorm:"type:uuid;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Matches reports whether a file of the given name and detected type falls
+// under the rule
+func (r *UploadRule) Matches(filename, mimeType string) bool {
+	if r.MimeType != "" {
+		family, subtype, _ := strings.Cut(r.MimeType, "/")
+		if subtype == "*" {
+			if !strings.HasPrefix(mimeType, family+"/") {
+				return false
+			}
+		} else if !strings.EqualFold(mimeType, r.MimeType) {
+			return false
+		}
+	}
+	if r.Extension != "" {
+		if strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), ".")) != r.Extension {
+			return false
+		}
+	}
+	return r.MimeType != "" || r.Extension != ""
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadSession is a resumable upload sent in fixed-size chunks, so a
+// dropped connection only costs the chunk in flight. Chunks may arrive in any
+// order and be sent again; each is verified against the checksum the client
+// sends with it before it counts as received.
+type UploadSession struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OwnerID          uuid.UUID  `json:"owner_id" gorm:"type:uuid;not null;index"`
+	FolderID         *uuid.UUID `json:"folder_id,omitempty" gorm:"type:uuid"`
+	Filename         string     `json:"filename" gorm:"not null;size:255"`
+	MimeType         string     `json:"mime_type" gorm:"not null;size:100"` // declared by the client
+	Size             int64      `json:"size" gorm:"not null"`
+	ChunkSize        int64      `json:"chunk_size" gorm:"not null"`
+	ChunkCount       int        `json:"chunk_count" gorm:"not null"`
+	ReceivedChunks   []byte     `json:"received_chunks"` // bitmap; chunk i is bit i%8 of byte i/8
+	ReceivedCount    int        `json:"received_count" gorm:"not null;default:0"`
+	IsPublic         bool       `json:"is_public" gorm:"default:false"`
+	EncryptionHeader string     `json:"encryption_header,omitempty" gorm:"type:text"` // for uploads to encrypted folders
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// HasChunk reports whether chunk i has been received
+func (s *UploadSession) HasChunk(i int) bool {
+	return i >= 0 && i/8 < len(s.ReceivedChunks) && s.ReceivedChunks[i/8]&(1<<(i%8)) != 0
+}
+
+// MarkChunk records chunk i as received and reports whether it was new
+func (s *UploadSession) MarkChunk(i int) bool {
+	if s.HasChunk(i) {
+		return false
+	}
+	s.ReceivedChunks[i/8] |= 1 << (i % 8)
+	s.ReceivedCount++
+	return true
+}
+
+// MissingChunks lists the chunks not received yet
+func (s *UploadSession) MissingChunks() []int {
+	missing := make([]int, 0, s.ChunkCount-s.ReceivedCount)
+	for i := 0; i < s.ChunkCount; i++ {
+		if !s.HasChunk(i) {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// ChunkLength returns the size of chunk i; the last chunk may be short
+func (s *UploadSession) ChunkLength(i int) int64 {
+	if remaining := s.Size - int64(i)*s.ChunkSize; remaining < s.ChunkSize {
+		return remaining
+	}
+	return s.ChunkSize
+}
+
+// Complete reports whether every chunk has been received
+func (s *UploadSession) Complete() bool {
+	return s.ReceivedCount >= s.ChunkCount
+}
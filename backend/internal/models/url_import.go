@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// URLImportStatus is how far a URL import has got
+type URLImportStatus string
+
+const (
+	URLImportPending     URLImportStatus = "pending"     // waiting to start
+	URLImportDownloading URLImportStatus = "downloading" // fetching the URL
+	URLImportProcessing  URLImportStatus = "processing"  // checking and storing the downloaded file
+	URLImportCompleted   URLImportStatus = "completed"   // stored as FileID
+	URLImportFailed      URLImportStatus = "failed"      // see ErrorCode and Error
+)
+
+// URLImport is a file the server downloads from a URL on a user's behalf
+// and stores like an upload. Clients poll it for progress.
+type URLImport struct {
+	ID            uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID        uuid.UUID       `json:"user_id" gorm:"type:uuid;not null;index"`
+	URL           string          `json:"url" gorm:"type:text;not null"`
+	Filename      string          `json:"filename,omitempty" gorm:"size:255"` // chosen by the user, or taken from the response
+	FolderID      *uuid.UUID      `json:"folder_id,omitempty" gorm:"type:uuid"`
+	IsPublic      bool            `json:"is_public" gorm:"not null"`
+	Status        URLImportStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	BytesReceived int64           `json:"bytes_received" gorm:"not null;default:0"`
+	TotalBytes    *int64          `json:"total_bytes,omitempty"` // from Content-Length, when the server sends it
+	FileID        *uuid.UUID      `json:"file_id,omitempty" gorm:"type:uuid"`
+	ErrorCode     string          `json:"error_code,omitempty" gorm:"size:50"`
+	Error         string          `json:"error,omitempty" gorm:"type:text"`
+	StartedAt     *time.Time      `json:"started_at,omitempty"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Active reports whether the import is still running
+func (i *URLImport) Active() bool {
+	return i.Status != URLImportCompleted && i.Status != URLImportFailed
+}
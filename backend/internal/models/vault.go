@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserPublicKey is the public key of a user's key pair for encrypted
+// folders. Clients wrap a vault's key with it to give the user the vault;
+// the private key never reaches the server.
+type UserPublicKey struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
+	Algorithm string    `json:"algorithm" gorm:"size:50;not null"`
+	PublicKey string    `json:"public_key" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VaultKeyGrant is the key of an encrypted folder wrapped for one recipient,
+// or for the escrow key when RecipientID is nil. The server cannot unwrap it.
+type VaultKeyGrant struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	VaultID     uuid.UUID  `json:"vault_id" gorm:"type:uuid;not null"`
+	RecipientID *uuid.UUID `json:"recipient_id,omitempty" gorm:"type:uuid"`
+	WrappedKey  string     `json:"wrapped_key" gorm:"type:text;not null"`
+	GrantedBy   uuid.UUID  `json:"granted_by" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// IsEscrow reports whether the grant is the escrow copy of the key
+func (g *VaultKeyGrant) IsEscrow() bool {
+	return g.RecipientID == nil
+}
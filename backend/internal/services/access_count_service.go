@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// AccessCountService counts anonymous visits to share links and public files
+// per file or folder and day, for deployments that keep no access logs of
+// visitors who have not consented to tracking
+type AccessCountService struct {
+	db         *gorm.DB
+	countsOnly bool
+}
+
+// NewAccessCountService creates a new access count service
+func NewAccessCountService(db *gorm.DB, cfg *config.Config) *AccessCountService {
+	return &AccessCountService{db: db, countsOnly: cfg.AnonymousAccessTracking == "counts"}
+}
+
+// CountsOnly reports whether anonymous visits are counted instead of logged
+func (s *AccessCountService) CountsOnly() bool {
+	return s.countsOnly
+}
+
+// Record counts one anonymous view or download of a file or folder
+func (s *AccessCountService) Record(resourceType models.AccessCountResource, resourceID uuid.UUID, action string) error {
+	now := time.Now().UTC()
+	count := models.AccessCount{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Day:          time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+	}
+	column := "views"
+	if action == "download" {
+		column = "downloads"
+		count.Downloads = 1
+	} else {
+		count.Views = 1
+	}
+
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "resource_type"}, {Name: "resource_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{column: gorm.Expr("access_counts." + column + " + 1")}),
+	}).Create(&count).Error; err != nil {
+		return fmt.Errorf("error counting access: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// AccessLogRetentionService strips the IP address and user agent from
+// download statistics and share link access logs once they are older than
+// the retention period. The rows themselves stay, so download counts,
+// analytics and access heatmaps are unaffected.
+type AccessLogRetentionService struct {
+	db   *gorm.DB
+	days int
+}
+
+// NewAccessLogRetentionService creates a new access log retention service
+// keeping client details for the given number of days
+func NewAccessLogRetentionService(db *gorm.DB, days int) *AccessLogRetentionService {
+	return &AccessLogRetentionService{db: db, days: days}
+}
+
+// Enabled reports whether a retention period is configured
+func (s *AccessLogRetentionService) Enabled() bool {
+	return s.days > 0
+}
+
+// Start runs retention passes in the background
+func (s *AccessLogRetentionService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			scrubbed, err := s.Scrub(time.Now().AddDate(0, 0, -s.days))
+			if err != nil {
+				log.Printf("Access log retention failed: %v", err)
+			} else if scrubbed > 0 {
+				log.Printf("Access log retention: removed client details from %d entries", scrubbed)
+			}
+		}
+	}()
+}
+
+// Scrub clears the client details of access log entries recorded before the
+// cutoff and returns how many entries it changed
+func (s *AccessLogRetentionService) Scrub(cutoff time.Time) (int64, error) {
+	logs := []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.DownloadStat{}, "downloaded_at"},
+		{&models.ShareLinkAccessLog{}, "accessed_at"},
+		{&models.FolderShareLinkAccessLog{}, "accessed_at"},
+	}
+
+	var scrubbed int64
+	for _, l := range logs {
+		result := s.db.Unscoped().Model(l.model).
+			Where(l.column+" < ? AND (ip_address IS NOT NULL OR user_agent IS NOT NULL)", cutoff).
+			UpdateColumns(map[string]interface{}{"ip_address": nil, "user_agent": nil})
+		if result.Error != nil {
+			return scrubbed, fmt.Errorf("error scrubbing access logs: %w", result.Error)
+		}
+		scrubbed += result.RowsAffected
+	}
+	return scrubbed, nil
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrAccessUserNotFound is returned when the simulated user does not exist
+	ErrAccessUserNotFound = errors.New("user not found")
+	// ErrAccessFileNotFound is returned when a file does not exist, is deleted
+	// or is not visible to the user, so its existence is not revealed
+	ErrAccessFileNotFound = errors.New("file not found")
+	// ErrAccessFolderNotFound is the folder counterpart of ErrAccessFileNotFound
+	ErrAccessFolderNotFound = errors.New("folder not found")
+	// ErrAccessDenied is returned when the user can see a resource but not
+	// perform the requested action on it
+	ErrAccessDenied = errors.New("access denied")
+)
+
+// AccessVia names the rule that gave a user access to a file or folder
+type AccessVia string
+
+const (
+	AccessViaOwner       AccessVia = "owner"
+	AccessViaShare       AccessVia = "share"
+	AccessViaFolderShare AccessVia = "folder_share"
+	// AccessViaAdmin is an admin's access to another user's file, which only
+	// the admin routes resolve, through ResolveFileAsAdmin
+	AccessViaAdmin AccessVia = "admin"
+)
+
+// FileAccess is what a user may do with a file. Via is empty when no rule
+// gives the user access.
+//
+// The permission matrix:
+//
+//	via           view  download                edit
+//	owner         yes   yes                     yes
+//	share         yes   permission "download"   no
+//	folder_share  yes   permission "download"   no
+//	admin         yes   yes                     yes
+//	(none)        no    no                      no
+type FileAccess struct {
+	File       *models.File
+	Via        AccessVia
+	Permission models.SharePermission // of the share that grants access
+}
+
+// CanView reports whether the user may see the file and open it inline
+func (a *FileAccess) CanView() bool {
+	return a.Via != ""
+}
+
+// CanDownload reports whether the user may download the file
+func (a *FileAccess) CanDownload() bool {
+	switch a.Via {
+	case AccessViaOwner, AccessViaAdmin:
+		return true
+	case AccessViaShare, AccessViaFolderShare:
+		return a.Permission == models.PermissionDownload
+	default:
+		return false
+	}
+}
+
+// CanEdit reports whether the user may change the file: move, delete, share
+// or reprocess it
+func (a *FileAccess) CanEdit() bool {
+	return a.Via == AccessViaOwner || a.Via == AccessViaAdmin
+}
+
+// FolderAccess is what a user may do with a folder, following the same
+// matrix as FileAccess
+type FolderAccess struct {
+	Folder     *models.Folder
+	Via        AccessVia
+	Permission models.SharePermission
+}
+
+// CanView reports whether the user may open the folder and list its files
+func (a *FolderAccess) CanView() bool {
+	return a.Via != ""
+}
+
+// CanDownload reports whether the user may download the folder's files
+func (a *FolderAccess) CanDownload() bool {
+	switch a.Via {
+	case AccessViaOwner:
+		return true
+	case AccessViaFolderShare:
+		return a.Permission == models.PermissionDownload
+	default:
+		return false
+	}
+}
+
+// CanEdit reports whether the user may change the folder or its contents
+func (a *FolderAccess) CanEdit() bool {
+	return a.Via == AccessViaOwner
+}
+
+// AccessService decides what users may do with files and folders. Handlers
+// and services check access through it rather than querying ownership and
+// shares themselves, so every route applies the same rules.
+type AccessService struct {
+	db *gorm.DB
+}
+
+// NewAccessService creates a new access service
+func NewAccessService(db *gorm.DB) *AccessService {
+	return &AccessService{db: db}
+}
+
+// ResolveFile loads a live file and works out the user's access to it. It
+// returns ErrAccessFileNotFound when the file does not exist or is deleted;
+// a file the user has no access to is returned with an empty Via.
+func (s *AccessService) ResolveFile(ctx context.Context, userID, fileID uuid.UUID) (*FileAccess, error) {
+	db := s.db.WithContext(ctx)
+
+	var file models.File
+	if err := db.Where("id = ?", fileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessFileNotFound
+		}
+		return nil, fmt.Errorf("error fetching file: %w", err)
+	}
+
+	return resolveFileAccess(db, userID, &file, time.Now())
+}
+
+// ResolveFileAsAdmin loads a live file for an admin, who may do anything
+// with any file: their own as its owner, anyone else's as an admin. Admin
+// routes audit access to other users' files themselves. It returns
+// ErrAccessFileNotFound when the file does not exist or is deleted.
+func (s *AccessService) ResolveFileAsAdmin(ctx context.Context, adminID, fileID uuid.UUID) (*FileAccess, error) {
+	var file models.File
+	if err := s.db.WithContext(ctx).Where("id = ?", fileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessFileNotFound
+		}
+		return nil, fmt.Errorf("error fetching file: %w", err)
+	}
+
+	access := &FileAccess{File: &file, Via: AccessViaAdmin}
+	if file.OwnerID == adminID {
+		access.Via = AccessViaOwner
+	}
+	return access, nil
+}
+
+// resolveFileAccess applies the access rules to a loaded file: ownership,
+// then an active unexpired direct share, then a share of the folder the file
+// is in. Deleted files are never accessible.
+func resolveFileAccess(db *gorm.DB, userID uuid.UUID, file *models.File, now time.Time) (*FileAccess, error) {
+	access := &FileAccess{File: file}
+	if file.IsDeleted() {
+		return access, nil
+	}
+
+	if file.OwnerID == userID {
+		access.Via = AccessViaOwner
+		return access, nil
+	}
+
+	share, err := activeFileShare(db, file.ID, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if share != nil {
+		access.Via = AccessViaShare
+		access.Permission = share.Permission
+		return access, nil
+	}
+
+	if file.FolderID != nil {
+		folderShare, err := folderShareFor(db, *file.FolderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if folderShare != nil {
+			access.Via = AccessViaFolderShare
+			access.Permission = folderShare.Permission
+		}
+	}
+	return access, nil
+}
+
+// activeFileShare returns the user's active, unexpired share of a file,
+// preferring one that allows downloading, or nil if there is none
+func activeFileShare(db *gorm.DB, fileID, userID uuid.UUID, now time.Time) (*models.FileShare, error) {
+	var share models.FileShare
+	err := db.Where("file_id = ? AND shared_with = ? AND is_active = true", fileID, userID).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order(fmt.Sprintf("permission = '%s' DESC, created_at ASC", models.PermissionDownload)).
+		First(&share).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching file share: %w", err)
+	}
+	return &share, nil
+}
+
+// folderShareFor returns the user's share of a folder, or nil if there is none
+func folderShareFor(db *gorm.DB, folderID, userID uuid.UUID) (*models.FolderShare, error) {
+	var share models.FolderShare
+	err := db.Where("folder_id = ? AND shared_with = ?", folderID, userID).
+		Order(fmt.Sprintf("permission = '%s' DESC, created_at ASC", models.PermissionDownload)).
+		First(&share).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching folder share: %w", err)
+	}
+	return &share, nil
+}
+
+// CanView returns the user's access to a file they may view, or
+// ErrAccessFileNotFound
+func (s *AccessService) CanView(ctx context.Context, userID, fileID uuid.UUID) (*FileAccess, error) {
+	return s.requireFile(ctx, userID, fileID, (*FileAccess).CanView)
+}
+
+// CanDownload returns the user's access to a file they may download. It
+// returns ErrAccessDenied when they may only view it.
+func (s *AccessService) CanDownload(ctx context.Context, userID, fileID uuid.UUID) (*FileAccess, error) {
+	return s.requireFile(ctx, userID, fileID, (*FileAccess).CanDownload)
+}
+
+// CanEdit returns the user's access to a file they may change. It returns
+// ErrAccessDenied when they may only view it.
+func (s *AccessService) CanEdit(ctx context.Context, userID, fileID uuid.UUID) (*FileAccess, error) {
+	return s.requireFile(ctx, userID, fileID, (*FileAccess).CanEdit)
+}
+
+// requireFile resolves access and checks it allows an action. Files the user
+// cannot see at all are reported as not found.
+func (s *AccessService) requireFile(ctx context.Context, userID, fileID uuid.UUID, allowed func(*FileAccess) bool) (*FileAccess, error) {
+	access, err := s.ResolveFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.CanView() {
+		return nil, ErrAccessFileNotFound
+	}
+	if !allowed(access) {
+		return nil, ErrAccessDenied
+	}
+	return access, nil
+}
+
+// PublicFile returns a live public file of a tenant, which anyone may view
+// and download, or ErrAccessFileNotFound. A nil tenantID is the platform
+func (s *AccessService) PublicFile(ctx context.Context, tenantID *uuid.UUID, fileID uuid.UUID) (*models.File, error) {
+	var file models.File
+	if err := s.db.WithContext(ctx).Joins("JOIN users ON files.owner_id = users.id").
+		Scopes(models.InTenant(tenantID)).
+		Where("files.id = ? AND files.is_public = true", fileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessFileNotFound
+		}
+		return nil, fmt.Errorf("error fetching file: %w", err)
+	}
+	return &file, nil
+}
+
+// ResolveFolder loads a folder and works out the user's access to it: as its
+// owner or through a share of that folder. It returns ErrAccessFolderNotFound
+// when the folder does not exist; a folder the user has no access to is
+// returned with an empty Via.
+func (s *AccessService) ResolveFolder(ctx context.Context, userID, folderID uuid.UUID) (*FolderAccess, error) {
+	db := s.db.WithContext(ctx)
+
+	var folder models.Folder
+	if err := db.First(&folder, "id = ?", folderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessFolderNotFound
+		}
+		return nil, fmt.Errorf("error fetching folder: %w", err)
+	}
+
+	access := &FolderAccess{Folder: &folder}
+	if folder.OwnerID == userID {
+		access.Via = AccessViaOwner
+		return access, nil
+	}
+
+	share, err := folderShareFor(db, folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if share != nil {
+		access.Via = AccessViaFolderShare
+		access.Permission = share.Permission
+	}
+	return access, nil
+}
+
+// CanViewFolder returns the user's access to a folder they may open, or
+// ErrAccessFolderNotFound
+func (s *AccessService) CanViewFolder(ctx context.Context, userID, folderID uuid.UUID) (*FolderAccess, error) {
+	access, err := s.ResolveFolder(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.CanView() {
+		return nil, ErrAccessFolderNotFound
+	}
+	return access, nil
+}
+
+// CanDownloadFolder returns the user's access to a folder whose files they
+// may download. It returns ErrAccessDenied when they may only open it.
+func (s *AccessService) CanDownloadFolder(ctx context.Context, userID, folderID uuid.UUID) (*FolderAccess, error) {
+	access, err := s.CanViewFolder(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.CanDownload() {
+		return nil, ErrAccessDenied
+	}
+	return access, nil
+}
+
+// OwnedFiles scopes a files query to the user's live files
+func OwnedFiles(userID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("files.deleted_at IS NULL AND files.owner_id = ?", userID)
+	}
+}
+
+// VisibleFiles scopes a files query to the live files the user may view: the
+// ones they own, the ones shared with them and the ones in folders shared
+// with them. It matches ResolveFile.
+func VisibleFiles(userID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`files.deleted_at IS NULL AND (
+			files.owner_id = @user
+			OR files.id IN (
+				SELECT fs.file_id FROM file_shares fs
+				WHERE fs.shared_with = @user AND fs.is_active = true AND fs.deleted_at IS NULL
+					AND (fs.expires_at IS NULL OR fs.expires_at > @now))
+			OR files.folder_id IN (
+				SELECT fo.folder_id FROM folder_shares fo
+				WHERE fo.shared_with = @user AND fo.deleted_at IS NULL))`,
+			map[string]interface{}{"user": userID, "now": time.Now()})
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+)
+
+// openTestDB opens an in-memory SQLite database with every migration applied
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	// Migrations are read relative to the backend directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// One connection, since each connection to :memory: is its own database
+	cfg := &config.Config{
+		DatabaseDriver: "sqlite",
+		SQLitePath:     ":memory:",
+		DBMaxOpenConns: 1,
+		DBMaxIdleConns: 1,
+	}
+	db, err := database.Initialize(cfg)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	if err := database.RunMigrations(db, cfg); err != nil {
+		t.Fatalf("running migrations: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// accessFixture creates the rows an access test needs and fails the test if
+// one cannot be created
+type accessFixture struct {
+	t  *testing.T
+	db *gorm.DB
+	n  int
+}
+
+func (f *accessFixture) create(value interface{}) {
+	f.t.Helper()
+	if err := f.db.Create(value).Error; err != nil {
+		f.t.Fatalf("creating %T: %v", value, err)
+	}
+}
+
+// user creates a user. Names get a suffix, since migrations seed some users
+func (f *accessFixture) user(name string) uuid.UUID {
+	username := name + "-test"
+	user := models.User{
+		BaseModel:    models.BaseModel{ID: uuid.New()},
+		Username:     username,
+		Email:        username + "@example.com",
+		PasswordHash: "x",
+		Role:         models.RoleUser,
+	}
+	f.create(&user)
+	return user.ID
+}
+
+func (f *accessFixture) folder(ownerID uuid.UUID, name string) uuid.UUID {
+	folder := models.Folder{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Name:      name,
+		OwnerID:   ownerID,
+		Path:      "/" + name,
+	}
+	f.create(&folder)
+	return folder.ID
+}
+
+func (f *accessFixture) file(ownerID uuid.UUID, folderID *uuid.UUID, tier models.StorageTier) *models.File {
+	f.n++
+	hash := models.FileHash{
+		ID:             uuid.New(),
+		Hash:           fmt.Sprintf("%064x", f.n),
+		Size:           1,
+		StoragePath:    fmt.Sprintf("blobs/%d", f.n),
+		ReferenceCount: 1,
+		StorageTier:    tier,
+	}
+	f.create(&hash)
+
+	file := models.File{
+		BaseModel:        models.BaseModel{ID: uuid.New()},
+		Filename:         fmt.Sprintf("file-%d.txt", f.n),
+		OriginalFilename: fmt.Sprintf("file-%d.txt", f.n),
+		MimeType:         "text/plain",
+		Size:             1,
+		FileHashID:       hash.ID,
+		OwnerID:          ownerID,
+		FolderID:         folderID,
+		StorageTier:      tier,
+	}
+	f.create(&file)
+	return &file
+}
+
+func (f *accessFixture) share(file *models.File, with uuid.UUID, permission models.SharePermission, expiresAt *time.Time) uuid.UUID {
+	share := models.FileShare{
+		BaseModel:  models.BaseModel{ID: uuid.New()},
+		FileID:     file.ID,
+		SharedBy:   file.OwnerID,
+		SharedWith: with,
+		Permission: permission,
+		ExpiresAt:  expiresAt,
+		IsActive:   true,
+	}
+	f.create(&share)
+	return share.ID
+}
+
+func (f *accessFixture) folderShare(folderID, ownerID, with uuid.UUID, permission models.SharePermission) {
+	f.create(&models.FolderShare{
+		BaseModel:  models.BaseModel{ID: uuid.New()},
+		FolderID:   folderID,
+		SharedBy:   ownerID,
+		SharedWith: with,
+		Permission: permission,
+	})
+}
+
+func TestFileAccess(t *testing.T) {
+	db := openTestDB(t)
+	fx := &accessFixture{t: t, db: db}
+
+	owner := fx.user("owner")
+	viewer := fx.user("viewer")
+	downloader := fx.user("downloader")
+	folderViewer := fx.user("folder-viewer")
+	folderDownloader := fx.user("folder-downloader")
+	expired := fx.user("expired")
+	revoked := fx.user("revoked")
+	stranger := fx.user("stranger")
+
+	folderID := fx.folder(owner, "shared")
+	fx.folderShare(folderID, owner, folderViewer, models.PermissionView)
+	fx.folderShare(folderID, owner, folderDownloader, models.PermissionDownload)
+
+	plain := fx.file(owner, &folderID, models.StorageTierHot)
+	fx.share(plain, viewer, models.PermissionView, nil)
+	fx.share(plain, downloader, models.PermissionDownload, nil)
+	past := time.Now().Add(-time.Hour)
+	fx.share(plain, expired, models.PermissionDownload, &past)
+	// GORM skips a false IsActive on create, since the column has a default
+	revokedShare := fx.share(plain, revoked, models.PermissionDownload, nil)
+	if err := db.Model(&models.FileShare{}).Where("id = ?", revokedShare).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Quarantine and cold storage do not change who has access: the content
+	// routes refuse to serve such files after access is granted
+	quarantined := fx.file(owner, nil, models.StorageTierHot)
+	if err := db.Model(quarantined).Update("is_quarantined", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	fx.share(quarantined, downloader, models.PermissionDownload, nil)
+	archived := fx.file(owner, nil, models.StorageTierArchived)
+	fx.share(archived, downloader, models.PermissionDownload, nil)
+
+	deleted := fx.file(owner, nil, models.StorageTierHot)
+	fx.share(deleted, downloader, models.PermissionDownload, nil)
+	if err := db.Delete(deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		user     uuid.UUID
+		file     *models.File
+		via      AccessVia
+		view     error
+		download error
+		edit     error
+	}{
+		{"owner", owner, plain, AccessViaOwner, nil, nil, nil},
+		{"view share", viewer, plain, AccessViaShare, nil, ErrAccessDenied, ErrAccessDenied},
+		{"download share", downloader, plain, AccessViaShare, nil, nil, ErrAccessDenied},
+		{"folder view share", folderViewer, plain, AccessViaFolderShare, nil, ErrAccessDenied, ErrAccessDenied},
+		{"folder download share", folderDownloader, plain, AccessViaFolderShare, nil, nil, ErrAccessDenied},
+		{"expired share", expired, plain, "", ErrAccessFileNotFound, ErrAccessFileNotFound, ErrAccessFileNotFound},
+		{"revoked share", revoked, plain, "", ErrAccessFileNotFound, ErrAccessFileNotFound, ErrAccessFileNotFound},
+		{"stranger", stranger, plain, "", ErrAccessFileNotFound, ErrAccessFileNotFound, ErrAccessFileNotFound},
+		{"quarantined owner", owner, quarantined, AccessViaOwner, nil, nil, nil},
+		{"quarantined share", downloader, quarantined, AccessViaShare, nil, nil, ErrAccessDenied},
+		{"archived owner", owner, archived, AccessViaOwner, nil, nil, nil},
+		{"archived share", downloader, archived, AccessViaShare, nil, nil, ErrAccessDenied},
+		{"deleted owner", owner, deleted, "", ErrAccessFileNotFound, ErrAccessFileNotFound, ErrAccessFileNotFound},
+		{"deleted share", downloader, deleted, "", ErrAccessFileNotFound, ErrAccessFileNotFound, ErrAccessFileNotFound},
+	}
+
+	svc := NewAccessService(db)
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := []struct {
+				action string
+				check  func(context.Context, uuid.UUID, uuid.UUID) (*FileAccess, error)
+				want   error
+			}{
+				{"CanView", svc.CanView, tt.view},
+				{"CanDownload", svc.CanDownload, tt.download},
+				{"CanEdit", svc.CanEdit, tt.edit},
+			}
+			for _, c := range checks {
+				access, err := c.check(ctx, tt.user, tt.file.ID)
+				if !errors.Is(err, c.want) {
+					t.Errorf("%s: got error %v, want %v", c.action, err, c.want)
+					continue
+				}
+				if err == nil && access.Via != tt.via {
+					t.Errorf("%s: got access via %q, want %q", c.action, access.Via, tt.via)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveFileAsAdmin(t *testing.T) {
+	db := openTestDB(t)
+	fx := &accessFixture{t: t, db: db}
+
+	admin := fx.user("admin")
+	owner := fx.user("owner")
+	own := fx.file(admin, nil, models.StorageTierHot)
+	other := fx.file(owner, nil, models.StorageTierHot)
+	deleted := fx.file(owner, nil, models.StorageTierHot)
+	if err := db.Delete(deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewAccessService(db)
+	tests := []struct {
+		name   string
+		fileID uuid.UUID
+		via    AccessVia
+		err    error
+	}{
+		{"own file", own.ID, AccessViaOwner, nil},
+		{"other user's file", other.ID, AccessViaAdmin, nil},
+		{"deleted file", deleted.ID, "", ErrAccessFileNotFound},
+		{"missing file", uuid.New(), "", ErrAccessFileNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, err := svc.ResolveFileAsAdmin(context.Background(), admin, tt.fileID)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if access.Via != tt.via {
+				t.Errorf("got access via %q, want %q", access.Via, tt.via)
+			}
+			if !access.CanView() || !access.CanDownload() || !access.CanEdit() {
+				t.Errorf("admin access via %q does not allow every action", access.Via)
+			}
+		})
+	}
+}
+
+func TestEffectiveFileAccessBlocksContent(t *testing.T) {
+	db := openTestDB(t)
+	fx := &accessFixture{t: t, db: db}
+
+	owner := fx.user("owner")
+	quarantined := fx.file(owner, nil, models.StorageTierHot)
+	if err := db.Model(quarantined).Update("is_quarantined", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	archived := fx.file(owner, nil, models.StorageTierArchived)
+	plain := fx.file(owner, nil, models.StorageTierHot)
+
+	svc := NewAccessService(db)
+	tests := []struct {
+		name string
+		file *models.File
+		open bool
+	}{
+		{"plain", plain, true},
+		{"quarantined", quarantined, false},
+		{"archived", archived, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.EffectiveFileAccess(context.Background(), owner, tt.file.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.CanView != tt.open || result.CanDownload != tt.open {
+				t.Errorf("got can_view %v, can_download %v, want %v", result.CanView, result.CanDownload, tt.open)
+			}
+			if !result.CanEdit {
+				t.Errorf("owner cannot edit the file")
+			}
+		})
+	}
+}
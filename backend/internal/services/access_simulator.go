@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// AccessOutcome is the result of one rule in an access decision trace
+type AccessOutcome string
+
+const (
+	AccessOutcomeGrant AccessOutcome = "grant" // the rule gives the user access
+	AccessOutcomeDeny  AccessOutcome = "deny"  // the rule blocks access
+	AccessOutcomeSkip  AccessOutcome = "skip"  // the rule does not apply
+	AccessOutcomeWarn  AccessOutcome = "warn"  // applies, but behaves in a way users often do not expect
+	AccessOutcomeInfo  AccessOutcome = "info"  // context that does not change the decision
+)
+
+// AccessCheck is one step of an access decision trace
+type AccessCheck struct {
+	Rule    string        `json:"rule"`
+	Outcome AccessOutcome `json:"outcome"`
+	Detail  string        `json:"detail"`
+}
+
+// EffectiveAccess is what a user can do with a file, with the trace of every
+// rule that was evaluated to reach the decision
+type EffectiveAccess struct {
+	UserID               uuid.UUID              `json:"user_id"`
+	FileID               uuid.UUID              `json:"file_id"`
+	CanView              bool                   `json:"can_view"`
+	CanDownload          bool                   `json:"can_download"`
+	CanEdit              bool                   `json:"can_edit"`
+	Via                  AccessVia              `json:"via,omitempty"`
+	Permission           models.SharePermission `json:"permission,omitempty"`
+	ListedInSharedWithMe bool                   `json:"listed_in_shared_with_me"`
+	PublicAccess         bool                   `json:"public_access"`      // anyone can open it through the public file routes
+	ActiveShareLinks     int64                  `json:"active_share_links"` // anyone holding one of these links can open it
+	Trace                []AccessCheck          `json:"trace"`
+	EvaluatedAt          time.Time              `json:"evaluated_at"`
+}
+
+// EffectiveFileAccess explains the decision ResolveFile makes for a user and
+// file: every ownership, share and folder share rule, then the quarantine and
+// archive checks that block the content. It also reports the public and share
+// link routes and whether the file appears in the user's shared-with-me list.
+// Nothing is changed or logged as an access.
+func (s *AccessService) EffectiveFileAccess(ctx context.Context, userID, fileID uuid.UUID) (*EffectiveAccess, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	result := &EffectiveAccess{
+		UserID:      userID,
+		FileID:      fileID,
+		Trace:       []AccessCheck{},
+		EvaluatedAt: now,
+	}
+	trace := func(rule string, outcome AccessOutcome, format string, args ...interface{}) {
+		result.Trace = append(result.Trace, AccessCheck{Rule: rule, Outcome: outcome, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	var user models.User
+	if err := db.Select("id", "username", "role").First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessUserNotFound
+		}
+		return nil, fmt.Errorf("error fetching user: %w", err)
+	}
+
+	// Deleted files are loaded too, so the trace can say why they are hidden
+	var file models.File
+	if err := db.Unscoped().First(&file, "id = ?", fileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccessFileNotFound
+		}
+		return nil, fmt.Errorf("error fetching file: %w", err)
+	}
+
+	// The decision itself comes from the same resolver the routes use
+	access, err := resolveFileAccess(db, userID, &file, now)
+	if err != nil {
+		return nil, err
+	}
+	result.Via = access.Via
+	result.Permission = access.Permission
+	result.CanEdit = access.CanEdit()
+
+	if file.IsDeleted() {
+		trace("file", AccessOutcomeDeny, "File was deleted; no one can open it")
+	} else {
+		trace("file", AccessOutcomeInfo, "File %q exists and is not deleted", file.OriginalFilename)
+	}
+
+	switch {
+	case file.OwnerID != userID:
+		trace("owner", AccessOutcomeSkip, "User does not own the file (owner is %s)", file.OwnerID)
+	case access.Via == AccessViaOwner:
+		trace("owner", AccessOutcomeGrant, "User owns the file")
+	default:
+		trace("owner", AccessOutcomeDeny, "User owns the file, but it is deleted")
+	}
+
+	if err := s.traceFileShares(db, &file, userID, access, now, result, trace); err != nil {
+		return nil, err
+	}
+	if err := s.traceFolderShares(db, &file, userID, access, trace); err != nil {
+		return nil, err
+	}
+
+	if !access.CanView() {
+		trace("decision", AccessOutcomeDeny, "No rule gives the user access; the file routes respond 404")
+	} else if !access.CanDownload() {
+		trace("decision", AccessOutcomeWarn, "Access comes from a %s with view permission; the user can open the file but downloading responds 403", access.Via)
+	} else {
+		trace("decision", AccessOutcomeGrant, "User can view and download the file through %s", access.Via)
+	}
+
+	// Content checks applied after access is granted
+	var fileHash models.FileHash
+	if err := db.Select("id", "storage_tier").First(&fileHash, "id = ?", file.FileHashID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error fetching file hash: %w", err)
+	}
+	blocked := false
+	if file.IsQuarantined {
+		trace("quarantine", AccessOutcomeDeny, "File is quarantined pending admin review; viewing and downloading respond 403")
+		blocked = true
+	}
+	if fileHash.StorageTier != "" && fileHash.StorageTier != models.StorageTierHot {
+		trace("archive", AccessOutcomeDeny, "File content is in %s storage and must be restored before it can be opened (409)", fileHash.StorageTier)
+		blocked = true
+	}
+
+	result.CanView = access.CanView() && !blocked
+	result.CanDownload = access.CanDownload() && !blocked
+
+	// Routes anyone can use, regardless of who the user is
+	if file.IsPublic && !file.IsDeleted() {
+		result.PublicAccess = true
+		trace("public", AccessOutcomeInfo, "File is public; anyone can open it through the public file routes")
+	} else {
+		trace("public", AccessOutcomeSkip, "File is not public")
+	}
+
+	if err := db.Model(&models.ShareLink{}).
+		Where("file_id = ? AND is_active = true", fileID).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Where("max_downloads IS NULL OR download_count < max_downloads").
+		Count(&result.ActiveShareLinks).Error; err != nil {
+		return nil, fmt.Errorf("error counting share links: %w", err)
+	}
+	if result.ActiveShareLinks > 0 {
+		trace("share_link", AccessOutcomeInfo, "%d active share link(s); anyone holding one can open the file", result.ActiveShareLinks)
+	}
+
+	if user.Role == models.RoleAdmin {
+		trace("admin", AccessOutcomeInfo, "User is an admin and can also reach the file through the admin routes")
+	}
+
+	return result, nil
+}
+
+// traceFileShares reports every direct share of the file with the user and
+// which one, if any, grants access
+func (s *AccessService) traceFileShares(db *gorm.DB, file *models.File, userID uuid.UUID, access *FileAccess, now time.Time, result *EffectiveAccess, trace func(string, AccessOutcome, string, ...interface{})) error {
+	var shares []models.FileShare
+	if err := db.Where("file_id = ? AND shared_with = ?", file.ID, userID).Order("created_at ASC").Find(&shares).Error; err != nil {
+		return fmt.Errorf("error fetching file shares: %w", err)
+	}
+	if len(shares) == 0 {
+		trace("share", AccessOutcomeSkip, "File is not shared directly with the user")
+		return nil
+	}
+
+	granted := false
+	for _, share := range shares {
+		switch {
+		case !share.IsActive:
+			trace("share", AccessOutcomeSkip, "Share %s was revoked", share.ID)
+		case share.ExpiresAt != nil && !share.ExpiresAt.After(now):
+			trace("share", AccessOutcomeDeny, "Share %s expired at %s", share.ID, share.ExpiresAt.Format(time.RFC3339))
+		case file.IsDeleted():
+			trace("share", AccessOutcomeDeny, "Share %s is active, but the file is deleted", share.ID)
+		case access.Via == AccessViaShare && !granted && share.Permission == access.Permission:
+			granted = true
+			result.ListedInSharedWithMe = true
+			trace("share", AccessOutcomeGrant, "Active share %s from %s with %s permission", share.ID, share.SharedBy, share.Permission)
+		default:
+			result.ListedInSharedWithMe = true
+			trace("share", AccessOutcomeInfo, "Active share %s (%s) is not needed; access comes from %s", share.ID, share.Permission, access.Via)
+		}
+	}
+	return nil
+}
+
+// traceFolderShares evaluates the share of the folder the file is in. Shares
+// of folders further up the tree are reported because they do not cover
+// files in subfolders, a common cause of missing files.
+func (s *AccessService) traceFolderShares(db *gorm.DB, file *models.File, userID uuid.UUID, access *FileAccess, trace func(string, AccessOutcome, string, ...interface{})) error {
+	switch {
+	case file.FolderID == nil:
+		trace("folder_share", AccessOutcomeSkip, "File is in the owner's root folder, which cannot be shared")
+		return nil
+	case access.Via == AccessViaFolderShare:
+		trace("folder_share", AccessOutcomeGrant, "File's folder %s is shared with the user with %s permission", *file.FolderID, access.Permission)
+		return nil
+	case access.Via != "":
+		trace("folder_share", AccessOutcomeSkip, "Not checked; access already comes from %s", access.Via)
+		return nil
+	case file.IsDeleted():
+		trace("folder_share", AccessOutcomeDeny, "Folder shares do not reach deleted files")
+		return nil
+	}
+	trace("folder_share", AccessOutcomeSkip, "File's folder %s is not shared with the user", *file.FolderID)
+
+	// Walk up the tree; the visited set guards against a corrupt parent cycle
+	visited := map[uuid.UUID]bool{*file.FolderID: true}
+	var folder models.Folder
+	if err := db.Select("id", "parent_id").First(&folder, "id = ?", *file.FolderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			trace("folder_share", AccessOutcomeWarn, "File's folder %s no longer exists", *file.FolderID)
+			return nil
+		}
+		return fmt.Errorf("error fetching folder: %w", err)
+	}
+	for parentID := folder.ParentID; parentID != nil && !visited[*parentID]; parentID = folder.ParentID {
+		visited[*parentID] = true
+
+		share, err := folderShareFor(db, *parentID, userID)
+		if err != nil {
+			return err
+		}
+		if share != nil {
+			trace("folder_share", AccessOutcomeWarn, "Parent folder %s is shared with the user, but folder shares only cover files directly inside the shared folder", *parentID)
+			return nil
+		}
+
+		folder = models.Folder{}
+		if err := db.Select("id", "parent_id").First(&folder, "id = ?", *parentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("error fetching folder: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// lifecycleStage is one step of the inactivity policy: accounts in the
+// previous state that have not signed in for days move to state
+type lifecycleStage struct {
+	from     models.LifecycleState
+	state    models.LifecycleState
+	days     int
+	waitDays int // days the account must have spent in the previous state
+}
+
+// AccountLifecycleService warns users who have not signed in for a while,
+// then makes their accounts read-only and finally archives them. Signing in
+// at any point before archival returns the account to active.
+type AccountLifecycleService struct {
+	db                  *gorm.DB
+	cfg                 *config.Config
+	notificationService *NotificationService
+	mailService         *MailService
+}
+
+// NewAccountLifecycleService creates a new account lifecycle service
+func NewAccountLifecycleService(db *gorm.DB, cfg *config.Config, notificationService *NotificationService, mailService *MailService) *AccountLifecycleService {
+	return &AccountLifecycleService{
+		db:                  db,
+		cfg:                 cfg,
+		notificationService: notificationService,
+		mailService:         mailService,
+	}
+}
+
+// Enabled reports whether the inactivity policy is configured
+func (s *AccountLifecycleService) Enabled() bool {
+	return s.cfg.InactiveWarnDays > 0
+}
+
+// stages returns the configured stages in order. A stage whose threshold is
+// not later than the one before it is skipped, so users are always warned
+// before anything is restricted.
+func (s *AccountLifecycleService) stages() []lifecycleStage {
+	stages := []lifecycleStage{{
+		from:  models.LifecycleActive,
+		state: models.LifecycleWarned,
+		days:  s.cfg.InactiveWarnDays,
+	}}
+	for _, next := range []lifecycleStage{
+		{state: models.LifecycleReadOnly, days: s.cfg.InactiveReadOnlyDays},
+		{state: models.LifecycleArchived, days: s.cfg.InactiveArchiveDays},
+	} {
+		prev := stages[len(stages)-1]
+		if next.days <= prev.days {
+			continue
+		}
+		next.from = prev.state
+		next.waitDays = next.days - prev.days
+		stages = append(stages, next)
+	}
+	return stages
+}
+
+// Start runs inactivity passes in the background
+func (s *AccountLifecycleService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			moved, err := s.Enforce()
+			if err != nil {
+				log.Printf("Account lifecycle enforcement failed: %v", err)
+			} else if moved > 0 {
+				log.Printf("Account lifecycle: moved %d inactive accounts to the next stage", moved)
+			}
+		}
+	}()
+}
+
+// Enforce moves each inactive account at most one stage forward and returns
+// the number moved. Only regular users are affected; admins and guests have
+// their own controls.
+func (s *AccountLifecycleService) Enforce() (int64, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+
+	now := time.Now()
+	var moved int64
+	for _, stage := range s.stages() {
+		cutoff := now.AddDate(0, 0, -stage.days)
+		query := s.db.Where("role = ? AND lifecycle_state = ? AND COALESCE(last_login, created_at) < ?",
+			models.RoleUser, stage.from, cutoff)
+		if stage.waitDays > 0 {
+			query = query.Where("lifecycle_changed_at <= ?", now.AddDate(0, 0, -stage.waitDays))
+		} else {
+			// An account an admin restored counts as active from that moment
+			query = query.Where("(lifecycle_changed_at IS NULL OR lifecycle_changed_at < ?)", cutoff)
+		}
+
+		var users []models.User
+		if err := query.Find(&users).Error; err != nil {
+			return moved, fmt.Errorf("error finding inactive users: %w", err)
+		}
+
+		for i := range users {
+			ok, err := s.advance(&users[i], stage, now)
+			if err != nil {
+				return moved, err
+			}
+			if ok {
+				moved++
+				s.notify(&users[i], stage)
+			}
+		}
+	}
+	return moved, nil
+}
+
+// advance moves a user into the stage's state unless they signed in or were
+// changed by an admin since they were selected
+func (s *AccountLifecycleService) advance(user *models.User, stage lifecycleStage, now time.Time) (bool, error) {
+	updates := map[string]interface{}{
+		"lifecycle_state":      stage.state,
+		"lifecycle_changed_at": now,
+	}
+	if stage.state == models.LifecycleArchived {
+		updates["is_active"] = false
+	}
+
+	result := s.db.Model(&models.User{}).
+		Where("id = ? AND lifecycle_state = ?", user.ID, stage.from).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("error updating lifecycle state: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// notify tells the user what happened to their account and what happens next
+func (s *AccountLifecycleService) notify(user *models.User, stage lifecycleStage) {
+	title, message, severity := s.describe(stage)
+
+	if err := s.notificationService.Notify(user.ID, NotifyParams{
+		Type:     models.NotificationAccountLifecycle,
+		Severity: severity,
+		Title:    title,
+		Message:  message,
+		Details: models.NotificationDetails{
+			"lifecycle_state": stage.state,
+			"inactive_days":   stage.days,
+		},
+	}); err != nil {
+		log.Printf("Failed to notify user %s about account lifecycle: %v", user.ID, err)
+	}
+
+	if s.mailService.Enabled() && user.Email != "" && s.notificationService.WantsEmail(user.ID, models.NotificationAccountLifecycle) {
+		if err := s.mailService.Send(user.Email, EmailAccountLifecycle, AccountLifecycleEmail{
+			Username: user.Username,
+			Title:    title,
+			Message:  message,
+		}); err != nil {
+			log.Printf("Failed to email user %s about account lifecycle: %v", user.ID, err)
+		}
+	}
+}
+
+// describe returns the notice for entering a stage, naming the next stage
+// when there is one
+func (s *AccountLifecycleService) describe(stage lifecycleStage) (string, string, models.NotificationSeverity) {
+	var next *lifecycleStage
+	stages := s.stages()
+	for i := range stages {
+		if stages[i].state == stage.state && i+1 < len(stages) {
+			next = &stages[i+1]
+		}
+	}
+
+	switch stage.state {
+	case models.LifecycleWarned:
+		message := fmt.Sprintf("You have not signed in for %d days.", stage.days)
+		if next != nil {
+			message += fmt.Sprintf(" Sign in within %d days to keep your account; otherwise it will be %s.", next.waitDays, lifecycleOutcome(next.state))
+		}
+		return "Your account is inactive", message, models.NotificationSeverityWarning
+	case models.LifecycleReadOnly:
+		message := fmt.Sprintf("Your account is now read-only because you have not signed in for %d days. You can still download your files; sign in to restore full access.", stage.days)
+		if next != nil {
+			message += fmt.Sprintf(" If you do not sign in within %d days it will be %s.", next.waitDays, lifecycleOutcome(next.state))
+		}
+		return "Your account is now read-only", message, models.NotificationSeverityCritical
+	default:
+		message := fmt.Sprintf("Your account has been archived because you have not signed in for %d days. Contact an administrator to restore it.", stage.days)
+		return "Your account has been archived", message, models.NotificationSeverityCritical
+	}
+}
+
+func lifecycleOutcome(state models.LifecycleState) string {
+	if state == models.LifecycleArchived {
+		return "archived"
+	}
+	return "made read-only"
+}
+
+// MarkSignedIn records a login, returning a warned or read-only account to
+// active. Archived accounts cannot sign in, so they are never touched here.
+func MarkSignedIn(db *gorm.DB, user *models.User, now time.Time) {
+	updates := map[string]interface{}{"last_login": now}
+	if user.LifecycleState != "" && user.LifecycleState != models.LifecycleActive {
+		updates["lifecycle_state"] = models.LifecycleActive
+		updates["lifecycle_changed_at"] = now
+		user.LifecycleState = models.LifecycleActive
+		user.LifecycleChangedAt = &now
+	}
+	db.Model(user).Updates(updates)
+	user.LastLogin = &now
+}
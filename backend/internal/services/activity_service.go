@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+)
+
+// ActivityType discriminates the entries of a user's activity timeline
+type ActivityType string
+
+const (
+	ActivityTypeAudit         ActivityType = "audit"          // something the user did
+	ActivityTypeDownload      ActivityType = "download"       // someone else downloaded one of the user's files
+	ActivityTypeShareSent     ActivityType = "share_sent"     // the user shared a file or folder
+	ActivityTypeShareReceived ActivityType = "share_received" // a file or folder was shared with the user
+	ActivityTypeFileEvent     ActivityType = "file_event"     // someone else changed one of the user's files
+)
+
+// ActivityTypes lists every activity type, used to validate filters
+var ActivityTypes = []ActivityType{
+	ActivityTypeAudit,
+	ActivityTypeDownload,
+	ActivityTypeShareSent,
+	ActivityTypeShareReceived,
+	ActivityTypeFileEvent,
+}
+
+// ActivityFilter selects the part of a user's timeline to return
+type ActivityFilter struct {
+	Types  []ActivityType
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+	Offset int
+}
+
+// ActivityItem is one entry of the timeline. ActorID is the other user
+// involved, if any: the downloader, or the sharer or recipient of a share.
+type ActivityItem struct {
+	ID           uuid.UUID
+	Type         ActivityType
+	OccurredAt   time.Time
+	Action       string
+	ResourceType string
+	ResourceID   *uuid.UUID
+	ResourceName string
+	ActorID      *uuid.UUID
+	Actor        *models.User
+	Details      map[string]interface{}
+}
+
+// ActivityPage is a page of the timeline
+type ActivityPage struct {
+	Items   []ActivityItem
+	Total   int64
+	HasMore bool
+	Page    int
+	Limit   int
+}
+
+// ActivityService builds a single chronological feed from audit logs,
+// downloads of the user's files, share events and changes others made to the
+// user's files
+type ActivityService struct {
+	db *gorm.DB
+}
+
+// NewActivityService creates a new activity service
+func NewActivityService(db *gorm.DB) *ActivityService {
+	return &ActivityService{db: db}
+}
+
+// activityRow is the shape every source query is projected onto
+type activityRow struct {
+	ID           uuid.UUID
+	Type         string
+	OccurredAt   time.Time
+	Action       string
+	ResourceType string
+	ResourceID   *uuid.UUID
+	ResourceName string
+	ActorID      *uuid.UUID
+	Details      []byte
+}
+
+// activitySources returns one SELECT per activity type, each projected onto activityRow
+func activitySources() map[ActivityType]string {
+	return map[ActivityType]string{
+		ActivityTypeAudit: `
+			SELECT a.id, 'audit' AS type, a.created_at AS occurred_at, a.action,
+				a.resource_type, a.resource_id, COALESCE(a.resource_name, '') AS resource_name,
+				NULL::uuid AS actor_id, COALESCE(a.details, '{}'::jsonb) AS details
+			FROM audit_logs a
+			WHERE a.user_id = @user`,
+		ActivityTypeDownload: `
+			SELECT d.id, 'download' AS type, d.downloaded_at AS occurred_at, 'download' AS action,
+				'file' AS resource_type, f.id AS resource_id, f.original_filename AS resource_name,
+				d.downloaded_by AS actor_id,
+				jsonb_build_object('via_share_link', d.shared_link_id IS NOT NULL, 'download_size', d.download_size) AS details
+			FROM download_stats d
+			JOIN files f ON f.id = d.file_id
+			WHERE f.owner_id = @user AND d.downloaded_by IS DISTINCT FROM @user`,
+		ActivityTypeShareSent: `
+			SELECT s.id, 'share_sent' AS type, s.created_at AS occurred_at, 'share' AS action,
+				'file' AS resource_type, f.id AS resource_id, f.original_filename AS resource_name,
+				s.shared_with AS actor_id,
+				jsonb_build_object('permission', s.permission, 'message', COALESCE(s.message, '')) AS details
+			FROM file_shares s
+			JOIN files f ON f.id = s.file_id
+			WHERE s.shared_by = @user AND s.deleted_at IS NULL
+			UNION ALL
+			SELECT s.id, 'share_sent' AS type, s.created_at AS occurred_at, 'share' AS action,
+				'folder' AS resource_type, fo.id AS resource_id, fo.name AS resource_name,
+				s.shared_with AS actor_id,
+				jsonb_build_object('permission', s.permission, 'message', COALESCE(s.message, '')) AS details
+			FROM folder_shares s
+			JOIN folders fo ON fo.id = s.folder_id
+			WHERE s.shared_by = @user AND s.deleted_at IS NULL`,
+		ActivityTypeShareReceived: `
+			SELECT s.id, 'share_received' AS type, s.created_at AS occurred_at, 'share' AS action,
+				'file' AS resource_type, f.id AS resource_id, f.original_filename AS resource_name,
+				s.shared_by AS actor_id,
+				jsonb_build_object('permission', s.permission, 'message', COALESCE(s.message, '')) AS details
+			FROM file_shares s
+			JOIN files f ON f.id = s.file_id
+			WHERE s.shared_with = @user AND s.deleted_at IS NULL AND f.deleted_at IS NULL
+			UNION ALL
+			SELECT s.id, 'share_received' AS type, s.created_at AS occurred_at, 'share' AS action,
+				'folder' AS resource_type, fo.id AS resource_id, fo.name AS resource_name,
+				s.shared_by AS actor_id,
+				jsonb_build_object('permission', s.permission, 'message', COALESCE(s.message, '')) AS details
+			FROM folder_shares s
+			JOIN folders fo ON fo.id = s.folder_id
+			WHERE s.shared_with = @user AND s.deleted_at IS NULL`,
+		ActivityTypeFileEvent: `
+			SELECT e.id, 'file_event' AS type, e.created_at AS occurred_at, e.type AS action,
+				'file' AS resource_type, e.file_id AS resource_id,
+				COALESCE(f.original_filename, e.payload->>'filename', '') AS resource_name,
+				e.actor_id, COALESCE(e.payload, '{}'::jsonb) AS details
+			FROM file_events e
+			LEFT JOIN files f ON f.id = e.file_id
+			WHERE e.owner_id = @user AND e.actor_id IS DISTINCT FROM @user`,
+	}
+}
+
+// sqliteActivitySources rewrites the PostgreSQL casts and JSON builders of the
+// source queries for SQLite, which also has no boolean JSON values
+var sqliteActivitySources = strings.NewReplacer(
+	"NULL::uuid", "NULL",
+	"'{}'::jsonb", "'{}'",
+	"jsonb_build_object", "json_object",
+	"d.shared_link_id IS NOT NULL", "json(iif(d.shared_link_id IS NULL, 'false', 'true'))",
+)
+
+// GetTimeline returns a page of the user's activity, newest first
+func (s *ActivityService) GetTimeline(ctx context.Context, userID uuid.UUID, filter ActivityFilter) (*ActivityPage, error) {
+	types := filter.Types
+	if len(types) == 0 {
+		types = ActivityTypes
+	}
+
+	sources := activitySources()
+	parts := make([]string, 0, len(types))
+	for _, t := range types {
+		source, ok := sources[t]
+		if !ok {
+			return nil, fmt.Errorf("unknown activity type %q", t)
+		}
+		if database.IsSQLite(s.db) {
+			source = sqliteActivitySources.Replace(source)
+		}
+		parts = append(parts, source)
+	}
+
+	conditions := []string{"TRUE"}
+	args := map[string]interface{}{"user": userID}
+	if filter.Since != nil {
+		conditions = append(conditions, "occurred_at >= @since")
+		args["since"] = *filter.Since
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "occurred_at <= @until")
+		args["until"] = *filter.Until
+	}
+
+	feed := fmt.Sprintf("(%s) AS feed WHERE %s", strings.Join(parts, "\nUNION ALL\n"), strings.Join(conditions, " AND "))
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	db := s.db.WithContext(ctx)
+
+	var total int64
+	if err := db.Raw("SELECT COUNT(*) FROM "+feed, args).Scan(&total).Error; err != nil {
+		return nil, fmt.Errorf("error counting activity: %w", err)
+	}
+
+	args["limit"] = limit
+	args["offset"] = offset
+	var rows []activityRow
+	if err := db.Raw("SELECT * FROM "+feed+" ORDER BY occurred_at DESC, id LIMIT @limit OFFSET @offset", args).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching activity: %w", err)
+	}
+
+	items := make([]ActivityItem, len(rows))
+	actorIDs := make([]uuid.UUID, 0, len(rows))
+	for i, row := range rows {
+		items[i] = ActivityItem{
+			ID:           row.ID,
+			Type:         ActivityType(row.Type),
+			OccurredAt:   row.OccurredAt,
+			Action:       row.Action,
+			ResourceType: row.ResourceType,
+			ResourceID:   row.ResourceID,
+			ResourceName: row.ResourceName,
+			ActorID:      row.ActorID,
+		}
+		if len(row.Details) > 0 {
+			if err := json.Unmarshal(row.Details, &items[i].Details); err != nil {
+				return nil, fmt.Errorf("error decoding activity details: %w", err)
+			}
+		}
+		if row.ActorID != nil {
+			actorIDs = append(actorIDs, *row.ActorID)
+		}
+	}
+
+	if len(actorIDs) > 0 {
+		var actors []models.User
+		if err := db.Where("id IN ?", actorIDs).Find(&actors).Error; err != nil {
+			return nil, fmt.Errorf("error fetching activity users: %w", err)
+		}
+		actorByID := make(map[uuid.UUID]*models.User, len(actors))
+		for i := range actors {
+			actorByID[actors[i].ID] = &actors[i]
+		}
+		for i := range items {
+			if items[i].ActorID != nil {
+				items[i].Actor = actorByID[*items[i].ActorID]
+			}
+		}
+	}
+
+	return &ActivityPage{
+		Items:   items,
+		Total:   total,
+		HasMore: int64(offset+limit) < total,
+		Page:    offset/limit + 1,
+		Limit:   limit,
+	}, nil
+}
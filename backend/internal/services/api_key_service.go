@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// maxAPIKeysPerUser limits how many API keys a user may hold
+const maxAPIKeysPerUser = 10
+
+var (
+	// ErrAPIKeyNotFound is returned when an API key does not exist for the user
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyLimit is returned when the user already has the most API keys allowed
+	ErrAPIKeyLimit = errors.New("API key limit reached")
+)
+
+// APIKeyService manages the API keys users connect automation services with
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// ListAPIKeys returns the user's API keys, newest first
+func (s *APIKeyService) ListAPIKeys(userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("error fetching API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// CreateAPIKey issues a new API key for the user and returns it with the
+// key itself, which cannot be recovered later
+func (s *APIKeyService) CreateAPIKey(userID uuid.UUID, name string) (*models.APIKey, string, error) {
+	var count int64
+	if err := s.db.Model(&models.APIKey{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, "", fmt.Errorf("error counting API keys: %w", err)
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", ErrAPIKeyLimit
+	}
+
+	token, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating API key: %w", err)
+	}
+	key := middleware.APIKeyPrefix + token
+
+	apiKey := &models.APIKey{
+		UserID:  userID,
+		Name:    strings.TrimSpace(name),
+		Prefix:  key[:len(middleware.APIKeyPrefix)+8],
+		KeyHash: middleware.HashAPIKey(key),
+	}
+	if err := s.db.Create(apiKey).Error; err != nil {
+		return nil, "", fmt.Errorf("error creating API key: %w", err)
+	}
+	return apiKey, key, nil
+}
+
+// DeleteAPIKey revokes one of the user's API keys
+func (s *APIKeyService) DeleteAPIKey(userID, id uuid.UUID) error {
+	result := s.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIKey{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting API key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// ErrArchivingDisabled is returned when a restore is requested but no cold storage is configured
+var ErrArchivingDisabled = errors.New("cold storage is not configured")
+
+// archiveBatchSize is the number of blobs moved per query during a lifecycle pass
+const archiveBatchSize = 100
+
+// ArchiveService moves blobs that have not been accessed recently to cold
+// storage and restores them on request
+type ArchiveService struct {
+	db                  *gorm.DB
+	cfg                 *config.Config
+	notificationService *NotificationService
+	restoreRequests     chan struct{}
+}
+
+// NewArchiveService creates a new archive service
+func NewArchiveService(db *gorm.DB, cfg *config.Config, notificationService *NotificationService) *ArchiveService {
+	return &ArchiveService{
+		db:                  db,
+		cfg:                 cfg,
+		notificationService: notificationService,
+		restoreRequests:     make(chan struct{}, 1),
+	}
+}
+
+// Start runs lifecycle passes and restore processing in the background
+func (s *ArchiveService) Start() {
+	interval := time.Duration(s.cfg.ArchiveInterval) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			archived, err := s.ArchiveStaleBlobs()
+			if err != nil {
+				log.Printf("Archive lifecycle pass failed: %v", err)
+			} else if archived > 0 {
+				log.Printf("Archived %d blob(s) to cold storage", archived)
+			}
+		}
+	}()
+
+	// Restores are picked up as soon as they are requested, with a periodic
+	// sweep for requests left over from a previous run
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			s.ProcessRestores()
+			select {
+			case <-s.restoreRequests:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ArchiveStaleBlobs moves hot blobs with no downloads or views within the
+// configured window to cold storage. It returns the number of blobs archived.
+func (s *ArchiveService) ArchiveStaleBlobs() (int, error) {
+	cutoff := time.Now().AddDate(0, 0, -s.cfg.ArchiveAfterDays)
+	archived := 0
+
+	for {
+		var fileHashes []models.FileHash
+		if err := s.db.Raw(`
+			SELECT fh.* FROM file_hashes fh
+			WHERE fh.storage_tier = ?
+				AND fh.created_at < ?
+				AND (fh.restored_at IS NULL OR fh.restored_at < ?)
+				AND NOT EXISTS (
+					SELECT 1 FROM download_stats ds
+					JOIN files f ON f.id = ds.file_id
+					WHERE f.file_hash_id = fh.id AND ds.downloaded_at >= ?
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM audit_logs al
+					JOIN files f ON f.id = al.resource_id
+					WHERE al.resource_type = ? AND al.action IN ? AND f.file_hash_id = fh.id AND al.created_at >= ?
+				)
+			ORDER BY fh.created_at ASC
+			LIMIT ?`,
+			models.StorageTierHot, cutoff, cutoff, cutoff,
+			models.AuditResourceFile, []models.AuditLogAction{models.AuditActionDownload, models.AuditActionView}, cutoff,
+			archiveBatchSize,
+		).Scan(&fileHashes).Error; err != nil {
+			return archived, fmt.Errorf("error finding stale blobs: %w", err)
+		}
+
+		moved := 0
+		for i := range fileHashes {
+			if err := s.archiveBlob(&fileHashes[i]); err != nil {
+				log.Printf("Failed to archive blob %s: %v", fileHashes[i].Hash, err)
+				continue
+			}
+			moved++
+		}
+		archived += moved
+
+		if len(fileHashes) < archiveBatchSize || moved == 0 {
+			return archived, nil
+		}
+	}
+}
+
+// archiveBlob copies a blob to cold storage, marks it archived and removes
+// the primary copy
+func (s *ArchiveService) archiveBlob(fileHash *models.FileHash) error {
+	primaryPath := filepath.Join(s.cfg.StoragePath, fileHash.StoragePath)
+	archivePath := filepath.Join(s.cfg.ArchiveStoragePath, fileHash.StoragePath)
+
+	if _, err := utils.CopyFileVerified(primaryPath, archivePath, fileHash.Hash); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.setTier(fileHash.ID, map[string]interface{}{
+		"storage_tier": models.StorageTierArchived,
+		"archived_at":  now,
+	}, models.StorageTierArchived); err != nil {
+		os.Remove(archivePath)
+		return err
+	}
+
+	if err := os.Remove(primaryPath); err != nil {
+		log.Printf("Archived blob %s but failed to remove primary copy: %v", fileHash.Hash, err)
+	}
+	return nil
+}
+
+// RequestRestore queues the blob behind a file for restore from cold storage.
+// It returns the file's storage tier after the request.
+func (s *ArchiveService) RequestRestore(fileID, userID uuid.UUID) (models.StorageTier, error) {
+	var file models.File
+	if err := s.db.Where("id = ? AND owner_id = ?", fileID, userID).First(&file).Error; err != nil {
+		return "", err
+	}
+
+	var fileHash models.FileHash
+	if err := s.db.First(&fileHash, "id = ?", file.FileHashID).Error; err != nil {
+		return "", err
+	}
+
+	if fileHash.StorageTier != models.StorageTierArchived {
+		return fileHash.StorageTier, nil
+	}
+
+	if s.cfg.ArchiveStoragePath == "" {
+		return "", ErrArchivingDisabled
+	}
+
+	now := time.Now()
+	if err := s.setTier(fileHash.ID, map[string]interface{}{
+		"storage_tier":         models.StorageTierRestoring,
+		"restore_requested_at": now,
+		"restore_requested_by": userID,
+	}, models.StorageTierRestoring); err != nil {
+		return "", err
+	}
+
+	// Wake the restore worker without blocking if it is already busy
+	select {
+	case s.restoreRequests <- struct{}{}:
+	default:
+	}
+
+	return models.StorageTierRestoring, nil
+}
+
+// ProcessRestores copies every blob awaiting restore back to primary storage
+func (s *ArchiveService) ProcessRestores() {
+	var fileHashes []models.FileHash
+	if err := s.db.Where("storage_tier = ?", models.StorageTierRestoring).
+		Order("restore_requested_at ASC").
+		Find(&fileHashes).Error; err != nil {
+		log.Printf("Failed to fetch pending restores: %v", err)
+		return
+	}
+
+	for i := range fileHashes {
+		if err := s.restoreBlob(&fileHashes[i]); err != nil {
+			log.Printf("Failed to restore blob %s from cold storage: %v", fileHashes[i].Hash, err)
+		}
+	}
+}
+
+// restoreBlob copies a blob back to primary storage and notifies the requester
+func (s *ArchiveService) restoreBlob(fileHash *models.FileHash) error {
+	primaryPath := filepath.Join(s.cfg.StoragePath, fileHash.StoragePath)
+	archivePath := filepath.Join(s.cfg.ArchiveStoragePath, fileHash.StoragePath)
+
+	if _, err := utils.CopyFileVerified(archivePath, primaryPath, fileHash.Hash); err != nil {
+		return err
+	}
+
+	if err := s.markRestored(fileHash.ID); err != nil {
+		return err
+	}
+
+	if err := os.Remove(archivePath); err != nil {
+		log.Printf("Restored blob %s but failed to remove archived copy: %v", fileHash.Hash, err)
+	}
+
+	if fileHash.RestoreRequestedBy != nil {
+		if err := s.notificationService.Notify(*fileHash.RestoreRequestedBy, NotifyParams{
+			Type:     models.NotificationArchiveRestore,
+			Severity: models.NotificationSeverityInfo,
+			Title:    "File restored from archive",
+			Message:  "A file you requested has been restored from cold storage and is available for download again.",
+			Details:  models.NotificationDetails{"file_hash_id": fileHash.ID},
+		}); err != nil {
+			log.Printf("Failed to notify restore requester for blob %s: %v", fileHash.Hash, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *ArchiveService) markRestored(fileHashID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return MarkBlobHot(tx, fileHashID)
+	})
+}
+
+func (s *ArchiveService) setTier(fileHashID uuid.UUID, updates map[string]interface{}, tier models.StorageTier) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return setTier(tx, fileHashID, updates, tier)
+	})
+}
+
+// MarkBlobHot sets a blob and its files back to the hot tier once its content
+// is in primary storage again
+func MarkBlobHot(tx *gorm.DB, fileHashID uuid.UUID) error {
+	return setTier(tx, fileHashID, map[string]interface{}{
+		"storage_tier":         models.StorageTierHot,
+		"restored_at":          time.Now(),
+		"restore_requested_at": nil,
+		"restore_requested_by": nil,
+	}, models.StorageTierHot)
+}
+
+// setTier updates a blob and mirrors its tier onto every file referencing it
+func setTier(tx *gorm.DB, fileHashID uuid.UUID, updates map[string]interface{}, tier models.StorageTier) error {
+	if err := tx.Model(&models.FileHash{}).Where("id = ?", fileHashID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("error updating blob tier: %w", err)
+	}
+	if err := tx.Model(&models.File{}).Where("file_hash_id = ?", fileHashID).Update("storage_tier", tier).Error; err != nil {
+		return fmt.Errorf("error updating file tier: %w", err)
+	}
+	return nil
+}
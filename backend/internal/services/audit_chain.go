@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+)
+
+const (
+	// auditChainLockKey is the advisory lock held while the chain is extended
+	// or pruned, so instances never hand out the same sequence number
+	auditChainLockKey = 0x61756469
+	// auditChainBatchSize is the number of entries sealed or verified per query
+	auditChainBatchSize = 1000
+	// auditChainMaxProblems caps the problems listed in a verification report
+	auditChainMaxProblems = 100
+)
+
+// ChainAnchor is a sequence number and hash an auditor recorded earlier, such
+// as the head reported by a previous verification
+type ChainAnchor struct {
+	Sequence int64
+	Hash     string
+}
+
+// ChainProblem describes an entry that breaks the chain
+type ChainProblem struct {
+	Sequence int64     `json:"sequence"`
+	ID       uuid.UUID `json:"id,omitempty"`
+	Problem  string    `json:"problem"`
+}
+
+// ChainVerification is the result of checking the audit log's hash chain
+type ChainVerification struct {
+	Valid         bool           `json:"valid"`
+	CheckedCount  int64          `json:"checked_count"`
+	FirstSequence int64          `json:"first_sequence,omitempty"`
+	LastSequence  int64          `json:"last_sequence,omitempty"`
+	HeadHash      string         `json:"head_hash,omitempty"`
+	PrunedThrough int64          `json:"pruned_through,omitempty"` // last sequence removed by retention pruning
+	UnsealedCount int64          `json:"unsealed_count"`           // recent entries not yet in the chain
+	AnchorChecked bool           `json:"anchor_checked"`
+	Problems      []ChainProblem `json:"problems"`
+	VerifiedAt    time.Time      `json:"verified_at"`
+}
+
+// SealChain links unsealed audit entries into the hash chain in the order
+// they were written, in batches until none are left. It returns the number
+// of entries sealed.
+func (s *AuditService) SealChain() int {
+	sealed := 0
+	for {
+		var count int
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := lockAuditChain(tx); err != nil {
+				return err
+			}
+
+			sequence, prevHash, err := chainHead(tx)
+			if err != nil {
+				return err
+			}
+
+			var entries []models.AuditLog
+			if err := tx.Where("sequence IS NULL").
+				Order("created_at ASC, id ASC").
+				Limit(auditChainBatchSize).
+				Find(&entries).Error; err != nil {
+				return fmt.Errorf("error fetching unsealed audit entries: %w", err)
+			}
+
+			for i := range entries {
+				entry := &entries[i]
+				sequence++
+				seq := sequence
+				entry.Sequence = &seq
+				entry.PrevHash = prevHash
+				entry.Hash = entry.ComputeHash()
+
+				if err := tx.Model(&models.AuditLog{}).Where("id = ?", entry.ID).UpdateColumns(map[string]interface{}{
+					"sequence":  seq,
+					"prev_hash": entry.PrevHash,
+					"hash":      entry.Hash,
+				}).Error; err != nil {
+					return fmt.Errorf("error sealing audit entry %s: %w", entry.ID, err)
+				}
+				prevHash = entry.Hash
+			}
+			count = len(entries)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Audit chain: %v", err)
+			return sealed
+		}
+
+		sealed += count
+		if count < auditChainBatchSize {
+			return sealed
+		}
+	}
+}
+
+// chainHead returns the sequence number and hash of the last sealed entry, or
+// zero and an empty hash when nothing has been sealed
+func chainHead(tx *gorm.DB) (int64, string, error) {
+	var head models.AuditLog
+	err := tx.Select("sequence", "hash").
+		Where("sequence IS NOT NULL").
+		Order("sequence DESC").
+		Take(&head).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("error fetching audit chain head: %w", err)
+	}
+	return *head.Sequence, head.Hash, nil
+}
+
+// VerifyChain recomputes every sealed entry's hash and checks that each links
+// to the one before it with no gaps. Entries may only be missing from the
+// start of the chain, and only where a prune entry records their removal. An
+// anchor, if given, must still be in the chain with the same hash, which
+// detects entries removed from the end.
+func (s *AuditService) VerifyChain(ctx context.Context, anchor *ChainAnchor) (*ChainVerification, error) {
+	result := &ChainVerification{
+		Problems:   []ChainProblem{},
+		VerifiedAt: time.Now(),
+	}
+	addProblem := func(sequence int64, id uuid.UUID, problem string) {
+		if len(result.Problems) < auditChainMaxProblems {
+			result.Problems = append(result.Problems, ChainProblem{Sequence: sequence, ID: id, Problem: problem})
+		}
+	}
+
+	// Read one snapshot so entries sealed or pruned meanwhile do not show as breaks
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AuditLog{}).Where("sequence IS NULL").Count(&result.UnsealedCount).Error; err != nil {
+			return fmt.Errorf("error counting unsealed audit entries: %w", err)
+		}
+
+		var prev *models.AuditLog
+		for {
+			query := tx.Where("sequence IS NOT NULL").Order("sequence ASC").Limit(auditChainBatchSize)
+			if prev != nil {
+				query = query.Where("sequence > ?", *prev.Sequence)
+			}
+
+			var entries []models.AuditLog
+			if err := query.Find(&entries).Error; err != nil {
+				return fmt.Errorf("error fetching audit entries: %w", err)
+			}
+
+			for i := range entries {
+				entry := &entries[i]
+				sequence := *entry.Sequence
+
+				if prev == nil {
+					result.FirstSequence = sequence
+					if err := s.checkChainStart(tx, entry, result, addProblem); err != nil {
+						return err
+					}
+				} else {
+					if sequence != *prev.Sequence+1 {
+						addProblem(sequence, entry.ID, fmt.Sprintf("entries %d to %d are missing", *prev.Sequence+1, sequence-1))
+					}
+					if entry.PrevHash != prev.Hash {
+						addProblem(sequence, entry.ID, "previous hash does not match the entry before it")
+					}
+				}
+
+				if entry.ComputeHash() != entry.Hash {
+					addProblem(sequence, entry.ID, "contents do not match the stored hash")
+				}
+				if anchor != nil && sequence == anchor.Sequence {
+					result.AnchorChecked = true
+					if entry.Hash != anchor.Hash {
+						addProblem(sequence, entry.ID, "hash differs from the anchor")
+					}
+				}
+
+				result.CheckedCount++
+				prev = entry
+			}
+
+			if len(entries) < auditChainBatchSize {
+				break
+			}
+		}
+
+		if prev != nil {
+			result.LastSequence = *prev.Sequence
+			result.HeadHash = prev.Hash
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if anchor != nil && !result.AnchorChecked {
+		switch {
+		case anchor.Sequence > result.LastSequence:
+			addProblem(anchor.Sequence, uuid.Nil, fmt.Sprintf("anchor is beyond the last entry %d; the log has been truncated", result.LastSequence))
+		case anchor.Sequence <= result.PrunedThrough:
+			addProblem(anchor.Sequence, uuid.Nil, "anchor was removed by retention pruning")
+		}
+	}
+
+	result.Valid = len(result.Problems) == 0
+	return result, nil
+}
+
+// checkChainStart verifies the first remaining entry: it is either the first
+// entry ever sealed, or follows entries whose removal a prune entry recorded
+func (s *AuditService) checkChainStart(tx *gorm.DB, first *models.AuditLog, result *ChainVerification, addProblem func(int64, uuid.UUID, string)) error {
+	sequence := *first.Sequence
+	if sequence == 1 {
+		if first.PrevHash != "" {
+			addProblem(sequence, first.ID, "first entry has a previous hash")
+		}
+		return nil
+	}
+
+	var pruned int64
+	if err := tx.Model(&models.AuditLog{}).
+		Where("action = ? AND resource_type = ?", models.AuditActionPrune, models.AuditResourceAudit).
+		// SQLite's ->> returns JSON numbers as numbers, so compare as text
+		Where("CAST(details->>'through_sequence' AS TEXT) = ? AND details->>'through_hash' = ?", strconv.FormatInt(sequence-1, 10), first.PrevHash).
+		Count(&pruned).Error; err != nil {
+		return fmt.Errorf("error checking audit prune records: %w", err)
+	}
+	if pruned == 0 {
+		addProblem(sequence, first.ID, fmt.Sprintf("entries 1 to %d are missing and no prune entry records their removal", sequence-1))
+		return nil
+	}
+
+	result.PrunedThrough = sequence - 1
+	return nil
+}
+
+// lockAuditChain serializes the transactions that extend or prune the chain.
+// SQLite transactions already take the database's write lock when they begin.
+func lockAuditChain(tx *gorm.DB) error {
+	if database.IsSQLite(tx) {
+		return nil
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockKey).Error; err != nil {
+		return fmt.Errorf("error locking audit chain: %w", err)
+	}
+	return nil
+}
+
+// pruneAuditLogs deletes the entries up to and including a sequence number,
+// lifting the protection against deleting audit entries for this transaction
+// only
+func pruneAuditLogs(tx *gorm.DB, through int64) (int64, error) {
+	grant := "SET LOCAL audit.allow_prune = 'on'"
+	if database.IsSQLite(tx) {
+		grant = "INSERT INTO audit_prune_grants DEFAULT VALUES"
+	}
+	if err := tx.Exec(grant).Error; err != nil {
+		return 0, fmt.Errorf("error enabling audit pruning: %w", err)
+	}
+
+	result := tx.Where("sequence <= ?", through).Delete(&models.AuditLog{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error pruning audit entries: %w", result.Error)
+	}
+
+	if database.IsSQLite(tx) {
+		if err := tx.Exec("DELETE FROM audit_prune_grants").Error; err != nil {
+			return 0, fmt.Errorf("error disabling audit pruning: %w", err)
+		}
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteOldAuditLogs prunes sealed entries older than the given number of
+// days. Only the start of the chain is removed, the head is always kept, and
+// a prune entry records the last removed sequence and hash so the chain can
+// still be verified. It returns the number of entries removed.
+func (s *AuditService) DeleteOldAuditLogs(ctx context.Context, olderThanDays int, actorID uuid.UUID) (int64, error) {
+	if olderThanDays <= 0 {
+		return 0, nil // Safety check
+	}
+
+	cutoffDate := time.Now().AddDate(0, 0, -olderThanDays)
+	var deleted int64
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockAuditChain(tx); err != nil {
+			return err
+		}
+
+		head, _, err := chainHead(tx)
+		if err != nil || head == 0 {
+			return err
+		}
+
+		// Stop before the first entry inside the retention window
+		var firstKept sql.NullInt64
+		if err := tx.Model(&models.AuditLog{}).
+			Select("MIN(sequence)").
+			Where("sequence IS NOT NULL AND created_at >= ?", cutoffDate).
+			Scan(&firstKept).Error; err != nil {
+			return fmt.Errorf("error finding audit entries to keep: %w", err)
+		}
+		through := head - 1
+		if firstKept.Valid && firstKept.Int64-1 < through {
+			through = firstKept.Int64 - 1
+		}
+
+		var last models.AuditLog
+		if err := tx.Select("sequence", "hash").Where("sequence = ?", through).Take(&last).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // already pruned
+			}
+			return fmt.Errorf("error fetching last audit entry to prune: %w", err)
+		}
+
+		if deleted, err = pruneAuditLogs(tx, through); err != nil {
+			return err
+		}
+
+		return tx.Create(&models.AuditLog{
+			UserID:       actorID,
+			Action:       models.AuditActionPrune,
+			ResourceType: models.AuditResourceAudit,
+			Details: models.AuditLogDetails{
+				"through_sequence": through,
+				"through_hash":     last.Hash,
+				"deleted_count":    deleted,
+				"older_than_days":  olderThanDays,
+			},
+			Status: models.AuditStatusSuccess,
+		}).Error
+	})
+
+	return deleted, err
+}
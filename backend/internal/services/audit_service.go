@@ -2,23 +2,44 @@ package services
 
 import (
 	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/middleware"
 	"file-vault-system/backend/internal/models"
 )
 
+// auditWritesInFlight counts audit entries being written. Most are logged
+// from background goroutines, so a value that keeps climbing means writes
+// are backing up. Exposed through /debug/vars.
+var auditWritesInFlight = expvar.NewInt("audit_writes_in_flight")
+
+const (
+	// auditOutboxBatchSize is the number of outbox entries moved per transaction
+	auditOutboxBatchSize = 100
+	// auditOutboxMaxAttempts is how often an entry is retried before it is
+	// left in the outbox for an operator to inspect
+	auditOutboxMaxAttempts = 10
+)
+
 // AuditService handles audit logging operations
 type AuditService struct {
-	db *gorm.DB
+	db  *gorm.DB
+	cfg *config.Config
 }
 
 // NewAuditService creates a new audit service
-func NewAuditService(db *gorm.DB) *AuditService {
-	return &AuditService{db: db}
+func NewAuditService(db *gorm.DB, cfg *config.Config) *AuditService {
+	return &AuditService{db: db, cfg: cfg}
 }
 
 // LogActivity logs an audit activity
@@ -39,27 +60,170 @@ func (s *AuditService) LogActivity(ctx context.Context, params LogActivityParams
 		auditLog.Status = models.AuditStatusSuccess
 	}
 
+	auditWritesInFlight.Add(1)
+	defer auditWritesInFlight.Add(-1)
+
 	return s.db.WithContext(ctx).Create(auditLog).Error
 }
 
 // LogActivityFromGin logs an audit activity from a Gin context
 func (s *AuditService) LogActivityFromGin(c *gin.Context, params LogActivityParams) error {
-	// Extract IP address and user agent from Gin context
+	return s.LogActivity(c.Request.Context(), withClient(c, params))
+}
+
+// withClient fills in the IP address and user agent of the request
+func withClient(c *gin.Context, params LogActivityParams) LogActivityParams {
 	if params.IPAddress == nil {
-		ip := c.ClientIP()
-		if ip != "" {
+		if ip := middleware.StoredClientIP(c); ip != "" {
 			params.IPAddress = &ip
 		}
 	}
-
 	if params.UserAgent == nil {
-		userAgent := c.GetHeader("User-Agent")
-		if userAgent != "" {
+		if userAgent := middleware.StoredUserAgent(c); userAgent != "" {
 			params.UserAgent = &userAgent
 		}
 	}
+	return params
+}
+
+// logActivityAsync captures the client details from a Gin context and writes
+// the entry in the background. The request context is not used for the write
+// because it is cancelled as soon as the handler returns. Use it only for
+// reads; actions that change data are recorded with Enqueue.
+func (s *AuditService) logActivityAsync(c *gin.Context, params LogActivityParams) {
+	params = withClient(c, params)
+
+	go func() {
+		if err := s.LogActivity(context.Background(), params); err != nil {
+			log.Printf("Failed to log %s audit: %v", params.Action, err)
+		}
+	}()
+}
+
+// Enqueue records an audit entry in the caller's transaction. The entry
+// reaches the audit log once the transaction commits and the outbox worker
+// runs; if the transaction rolls back, so does the entry.
+func (s *AuditService) Enqueue(tx *gorm.DB, params LogActivityParams) error {
+	entry := &models.AuditOutbox{
+		UserID:       params.UserID,
+		Action:       params.Action,
+		ResourceType: params.ResourceType,
+		ResourceID:   params.ResourceID,
+		ResourceName: params.ResourceName,
+		Details:      params.Details,
+		IPAddress:    params.IPAddress,
+		UserAgent:    params.UserAgent,
+		Status:       params.Status,
+	}
+
+	if entry.Status == "" {
+		entry.Status = models.AuditStatusSuccess
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("error queueing audit entry: %w", err)
+	}
+	return nil
+}
+
+// EnqueueFromGin records an audit entry in the caller's transaction with the
+// client details of the request
+func (s *AuditService) EnqueueFromGin(tx *gorm.DB, c *gin.Context, params LogActivityParams) error {
+	return s.Enqueue(tx, withClient(c, params))
+}
+
+// Start moves committed outbox entries into the audit log and seals new
+// entries into the hash chain in the background
+func (s *AuditService) Start() {
+	interval := time.Duration(s.cfg.AuditOutboxInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			s.DrainOutbox()
+			s.SealChain()
+		}
+	}()
+}
+
+// DrainOutbox moves outbox entries into the audit log in batches until none
+// are left, keeping each entry's id and time. Entries that fail are retried
+// on later passes. It returns the number of entries moved.
+func (s *AuditService) DrainOutbox() int {
+	moved := 0
+	for {
+		var fetched, failed int
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// Skip rows another instance is draining
+			var entries []models.AuditOutbox
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("attempts < ?", auditOutboxMaxAttempts).
+				Order("created_at ASC").
+				Limit(auditOutboxBatchSize).
+				Find(&entries).Error; err != nil {
+				return fmt.Errorf("error fetching audit outbox: %w", err)
+			}
+			fetched = len(entries)
+
+			for i := range entries {
+				entry := &entries[i]
+				err := tx.Transaction(func(tx *gorm.DB) error {
+					return deliverAuditEntry(tx, entry)
+				})
+				if err == nil {
+					continue
+				}
+
+				failed++
+				if entry.Attempts+1 >= auditOutboxMaxAttempts {
+					log.Printf("Audit outbox: giving up on entry %s (%s %s): %v", entry.ID, entry.Action, entry.ResourceType, err)
+				}
+				if err := tx.Model(entry).Updates(map[string]interface{}{
+					"attempts":   gorm.Expr("attempts + 1"),
+					"last_error": err.Error(),
+				}).Error; err != nil {
+					return fmt.Errorf("error recording audit outbox failure: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Audit outbox: %v", err)
+			return moved
+		}
+
+		moved += fetched - failed
+		if fetched < auditOutboxBatchSize || failed == fetched {
+			return moved
+		}
+	}
+}
+
+// deliverAuditEntry copies an outbox entry into the audit log and removes it.
+// An entry already copied by an earlier, interrupted pass is not duplicated.
+func deliverAuditEntry(tx *gorm.DB, entry *models.AuditOutbox) error {
+	auditLog := &models.AuditLog{
+		ID:           entry.ID,
+		UserID:       entry.UserID,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		ResourceName: entry.ResourceName,
+		Details:      entry.Details,
+		IPAddress:    entry.IPAddress,
+		UserAgent:    entry.UserAgent,
+		Status:       entry.Status,
+		CreatedAt:    entry.CreatedAt,
+	}
 
-	return s.LogActivity(c.Request.Context(), params)
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(auditLog).Error; err != nil {
+		return err
+	}
+	return tx.Delete(entry).Error
 }
 
 // GetAuditLogs retrieves audit logs with filtering and pagination
@@ -188,21 +352,6 @@ func (s *AuditService) GetUserActivitySummary(ctx context.Context, userID uuid.U
 	return &summary, nil
 }
 
-// DeleteOldAuditLogs removes audit logs older than specified days
-func (s *AuditService) DeleteOldAuditLogs(ctx context.Context, olderThanDays int) (int64, error) {
-	if olderThanDays <= 0 {
-		return 0, nil // Safety check
-	}
-
-	cutoffDate := time.Now().AddDate(0, 0, -olderThanDays)
-
-	result := s.db.WithContext(ctx).
-		Where("created_at < ?", cutoffDate).
-		Delete(&models.AuditLog{})
-
-	return result.RowsAffected, result.Error
-}
-
 // LogActivityParams represents parameters for logging an activity
 type LogActivityParams struct {
 	UserID       uuid.UUID                   `json:"user_id"`
@@ -226,14 +375,14 @@ type UserActivitySummary struct {
 
 // Helper functions for common audit actions
 
-// LogFileUpload logs a file upload activity
-func (s *AuditService) LogFileUpload(c *gin.Context, userID, fileID uuid.UUID, filename string, fileSize int64) error {
+// LogFileUpload records a file upload in the upload's transaction
+func (s *AuditService) LogFileUpload(tx *gorm.DB, c *gin.Context, userID, fileID uuid.UUID, filename string, fileSize int64) error {
 	details := models.AuditLogDetails{
 		"file_size": fileSize,
 		"timestamp": time.Now().Unix(),
 	}
 
-	return s.LogActivityFromGin(c, LogActivityParams{
+	return s.EnqueueFromGin(tx, c, LogActivityParams{
 		UserID:       userID,
 		Action:       models.AuditActionUpload,
 		ResourceType: models.AuditResourceFile,
@@ -244,14 +393,15 @@ func (s *AuditService) LogFileUpload(c *gin.Context, userID, fileID uuid.UUID, f
 	})
 }
 
-// LogFileDownload logs a file download activity
-func (s *AuditService) LogFileDownload(c *gin.Context, userID, fileID uuid.UUID, filename string, fileSize int64) error {
+// LogFileDownload records a file download in the transaction that stores its
+// download statistic
+func (s *AuditService) LogFileDownload(tx *gorm.DB, c *gin.Context, userID, fileID uuid.UUID, filename string, fileSize int64) error {
 	details := models.AuditLogDetails{
 		"file_size": fileSize,
 		"timestamp": time.Now().Unix(),
 	}
 
-	return s.LogActivityFromGin(c, LogActivityParams{
+	return s.EnqueueFromGin(tx, c, LogActivityParams{
 		UserID:       userID,
 		Action:       models.AuditActionDownload,
 		ResourceType: models.AuditResourceFile,
@@ -262,13 +412,13 @@ func (s *AuditService) LogFileDownload(c *gin.Context, userID, fileID uuid.UUID,
 	})
 }
 
-// LogFileDelete logs a file deletion activity
-func (s *AuditService) LogFileDelete(c *gin.Context, userID, fileID uuid.UUID, filename string) error {
+// LogFileDelete records a file deletion in the deletion's transaction
+func (s *AuditService) LogFileDelete(tx *gorm.DB, c *gin.Context, userID, fileID uuid.UUID, filename string) error {
 	details := models.AuditLogDetails{
 		"timestamp": time.Now().Unix(),
 	}
 
-	return s.LogActivityFromGin(c, LogActivityParams{
+	return s.EnqueueFromGin(tx, c, LogActivityParams{
 		UserID:       userID,
 		Action:       models.AuditActionDelete,
 		ResourceType: models.AuditResourceFile,
@@ -297,3 +447,143 @@ func (s *AuditService) LogFileShare(c *gin.Context, userID, fileID uuid.UUID, fi
 		Status:       models.AuditStatusSuccess,
 	})
 }
+
+// AccessAuditEnabled reports whether views, metadata reads and listings are
+// recorded in addition to uploads, downloads and deletes
+func (s *AuditService) AccessAuditEnabled() bool {
+	return s.cfg != nil && s.cfg.AuditVerboseAccess
+}
+
+// sampleAccess decides whether a read is recorded under the configured sample rate
+func (s *AuditService) sampleAccess() bool {
+	percent := s.cfg.AuditAccessSamplePercent
+	return percent >= 100 || (percent > 0 && rand.Intn(100) < percent)
+}
+
+// LogFileAccess records a read of a file in verbose access mode. via names
+// how the file was reached: "owner", "share", "share_link" or "public".
+func (s *AuditService) LogFileAccess(c *gin.Context, action models.AuditLogAction, file *models.File, via string) {
+	s.logAccess(c, action, models.AuditResourceFile, file.ID, file.OriginalFilename, file.OwnerID, via)
+}
+
+// LogFolderAccess records a read of a folder's contents in verbose access
+// mode, such as opening a folder share link
+func (s *AuditService) LogFolderAccess(c *gin.Context, action models.AuditLogAction, folder *models.Folder, via string) {
+	s.logAccess(c, action, models.AuditResourceFolder, folder.ID, folder.Name, folder.OwnerID, via)
+}
+
+// logAccess records a sampled read. Anonymous reads, through public files or
+// share links, are attributed to the resource's owner and marked as anonymous.
+func (s *AuditService) logAccess(c *gin.Context, action models.AuditLogAction, resourceType models.AuditLogResourceType, resourceID uuid.UUID, resourceName string, ownerID uuid.UUID, via string) {
+	if !s.AccessAuditEnabled() || !s.sampleAccess() {
+		return
+	}
+
+	details := models.AuditLogDetails{
+		"via":       via,
+		"route":     c.FullPath(),
+		"timestamp": time.Now().Unix(),
+	}
+	if percent := s.cfg.AuditAccessSamplePercent; percent < 100 {
+		details["sample_percent"] = percent
+	}
+
+	actorID := ownerID
+	if userID, ok := c.Get("user_id"); ok {
+		actorID = userID.(uuid.UUID)
+	} else {
+		details["anonymous"] = true
+	}
+
+	s.logActivityAsync(c, LogActivityParams{
+		UserID:       actorID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   &resourceID,
+		ResourceName: &resourceName,
+		Details:      details,
+		Status:       models.AuditStatusSuccess,
+	})
+}
+
+// LogListAccess records a listing or search by an authenticated user in
+// verbose access mode, with the route, query string and number of results.
+// resourceID is the folder being listed, if any; extra carries search terms
+// sent in a request body.
+func (s *AuditService) LogListAccess(c *gin.Context, resourceType models.AuditLogResourceType, resourceID *uuid.UUID, resultCount int, extra models.AuditLogDetails) {
+	if !s.AccessAuditEnabled() || !s.sampleAccess() {
+		return
+	}
+
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return
+	}
+
+	details := models.AuditLogDetails{}
+	for key, value := range extra {
+		details[key] = value
+	}
+	details["route"] = c.FullPath()
+	details["query"] = c.Request.URL.RawQuery
+	details["result_count"] = resultCount
+	details["timestamp"] = time.Now().Unix()
+	if percent := s.cfg.AuditAccessSamplePercent; percent < 100 {
+		details["sample_percent"] = percent
+	}
+
+	s.logActivityAsync(c, LogActivityParams{
+		UserID:       userID.(uuid.UUID),
+		Action:       models.AuditActionList,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Details:      details,
+		Status:       models.AuditStatusSuccess,
+	})
+}
+
+// LogAdminFileAccess records an admin opening or downloading a file they do
+// not own, with the reason they gave. It is written before the content is
+// served, so access fails when it cannot be recorded. mode is "view" or
+// "download".
+func (s *AuditService) LogAdminFileAccess(c *gin.Context, adminID uuid.UUID, file *models.File, mode, reason string) error {
+	return s.LogActivityFromGin(c, LogActivityParams{
+		UserID:       adminID,
+		Action:       models.AuditActionAdminFileAccess,
+		ResourceType: models.AuditResourceFile,
+		ResourceID:   &file.ID,
+		ResourceName: &file.OriginalFilename,
+		Details: models.AuditLogDetails{
+			"owner_id":  file.OwnerID.String(),
+			"mode":      mode,
+			"reason":    reason,
+			"route":     c.FullPath(),
+			"timestamp": time.Now().Unix(),
+		},
+		Status: models.AuditStatusSuccess,
+	})
+}
+
+// AdminAccessVisibleToOwner reports whether users may list admin access to
+// their files
+func (s *AuditService) AdminAccessVisibleToOwner() bool {
+	return s.cfg != nil && s.cfg.AdminAccessVisibleToOwner
+}
+
+// ListAdminAccessForOwner returns a page of admin accesses to the owner's
+// files, newest first
+func (s *AuditService) ListAdminAccessForOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int) ([]models.AuditLog, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.AuditLog{}).
+		Where("action = ? AND details->>'owner_id' = ?", models.AuditActionAdminFileAccess, ownerID.String())
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting admin access: %w", err)
+	}
+
+	var logs []models.AuditLog
+	if err := query.Preload("User").Order("created_at DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("error listing admin access: %w", err)
+	}
+	return logs, total, nil
+}
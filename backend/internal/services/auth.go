@@ -178,30 +178,15 @@ func (s *AuthService) Login(req *LoginRequest) (*AuthResponse, error) {
 
 	// Check if user is active
 	if !user.IsActive {
+		if user.LifecycleState == models.LifecycleArchived {
+			return nil, fmt.Errorf("account was archived due to inactivity")
+		}
 		return nil, fmt.Errorf("account is deactivated")
 	}
 
-	// Special handling for admin user
-	if user.Username == "admin" || user.Email == "admin@gmail.com" {
-		// For admin user, check if password is "admin" (plain text)
-		if req.Password != "admin" {
-			return nil, fmt.Errorf("invalid credentials")
-		}
-		// Ensure admin user has admin role
-		if user.Role != models.RoleAdmin {
-			user.Role = models.RoleAdmin
-			s.db.Save(&user)
-		}
-	} else {
-		// For all other users, use normal password verification
-		if !utils.CheckPassword(req.Password, user.PasswordHash) {
-			return nil, fmt.Errorf("invalid credentials")
-		}
-		// Ensure non-admin usernames cannot have admin role
-		if user.Role == models.RoleAdmin {
-			user.Role = models.RoleUser
-			s.db.Save(&user)
-		}
+	// Verify password; admin access comes from the stored role, not the username
+	if !utils.CheckPassword(req.Password, user.PasswordHash) {
+		return nil, fmt.Errorf("invalid credentials")
 	}
 
 	// Get user roles
@@ -222,9 +207,7 @@ func (s *AuthService) Login(req *LoginRequest) (*AuthResponse, error) {
 	}
 
 	// Update last login time
-	now := time.Now()
-	user.LastLogin = &now
-	s.db.Save(&user)
+	MarkSignedIn(s.db, &user, time.Now())
 
 	// Convert to response format
 	userResponse := &UserResponse{
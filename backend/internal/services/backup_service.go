@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/backup"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// ErrBackupInProgress is returned when a backup is requested while another is running
+var ErrBackupInProgress = errors.New("a backup is already in progress")
+
+// BackupService runs database snapshots and blob syncs to the backup location
+type BackupService struct {
+	db                  *gorm.DB
+	cfg                 *config.Config
+	notificationService *NotificationService
+
+	mu      sync.Mutex
+	running bool
+}
+
+// NewBackupService creates a new backup service
+func NewBackupService(db *gorm.DB, cfg *config.Config, notificationService *NotificationService) *BackupService {
+	return &BackupService{
+		db:                  db,
+		cfg:                 cfg,
+		notificationService: notificationService,
+	}
+}
+
+// StartBackup records a new backup job and runs it in the background
+func (s *BackupService) StartBackup(mode models.BackupMode, startedBy uuid.UUID) (*models.BackupJob, error) {
+	if mode != models.BackupModeIncremental {
+		mode = models.BackupModeFull
+	}
+
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, ErrBackupInProgress
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	job := &models.BackupJob{
+		Mode:      mode,
+		Status:    models.BackupStatusPending,
+		StartedBy: &startedBy,
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		s.finish()
+		return nil, fmt.Errorf("error creating backup job: %w", err)
+	}
+
+	go func() {
+		defer s.finish()
+		s.run(job)
+	}()
+
+	return job, nil
+}
+
+// ListBackups returns the most recent backup jobs
+func (s *BackupService) ListBackups(limit int) ([]models.BackupJob, error) {
+	var jobs []models.BackupJob
+	if err := s.db.Order("created_at DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("error fetching backup jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetBackup returns a single backup job
+func (s *BackupService) GetBackup(id uuid.UUID) (*models.BackupJob, error) {
+	var job models.BackupJob
+	if err := s.db.First(&job, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *BackupService) finish() {
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+}
+
+// run performs the backup and records the outcome on the job
+func (s *BackupService) run(job *models.BackupJob) {
+	startedAt := time.Now()
+	name := startedAt.Format("20060102-150405") + "-" + job.ID.String()[:8]
+	backupDir := filepath.Join(s.cfg.BackupPath, name)
+
+	job.Status = models.BackupStatusRunning
+	job.StartedAt = &startedAt
+	job.BackupDir = name
+	s.db.Save(job)
+
+	manifest, err := s.createBackup(job, backupDir)
+
+	completedAt := time.Now()
+	job.CompletedAt = &completedAt
+	if manifest != nil {
+		job.BlobCount = len(manifest.Blobs)
+		job.BlobsCopied = manifest.BlobsCopied
+		job.BytesCopied = manifest.BytesCopied
+		job.MissingBlobs = len(manifest.MissingBlobs)
+	}
+
+	notification := NotifyParams{
+		Type: models.NotificationBackup,
+		Details: models.NotificationDetails{
+			"backup_id":    job.ID,
+			"backup_dir":   name,
+			"mode":         job.Mode,
+			"blobs_copied": job.BlobsCopied,
+			"bytes_copied": job.BytesCopied,
+		},
+	}
+
+	if err != nil {
+		log.Printf("Backup %s failed: %v", job.ID, err)
+		job.Status = models.BackupStatusFailed
+		job.Error = err.Error()
+		notification.Severity = models.NotificationSeverityCritical
+		notification.Title = "Backup failed"
+		notification.Message = fmt.Sprintf("The %s backup started at %s failed: %v", job.Mode, startedAt.Format(time.RFC3339), err)
+	} else {
+		job.Status = models.BackupStatusCompleted
+		notification.Severity = models.NotificationSeverityInfo
+		notification.Title = "Backup completed"
+		notification.Message = fmt.Sprintf("The %s backup %s completed: %d blob(s) copied, %d referenced", job.Mode, name, job.BlobsCopied, job.BlobCount)
+		if job.MissingBlobs > 0 {
+			notification.Severity = models.NotificationSeverityWarning
+			notification.Message += fmt.Sprintf(", %d missing from storage", job.MissingBlobs)
+		}
+	}
+
+	if err := s.db.Save(job).Error; err != nil {
+		log.Printf("Failed to update backup job %s: %v", job.ID, err)
+	}
+
+	if err := s.notificationService.NotifyAdmins(notification); err != nil {
+		log.Printf("Failed to notify admins about backup %s: %v", job.ID, err)
+	}
+}
+
+// createBackup dumps the database, syncs referenced blobs and writes the manifest
+func (s *BackupService) createBackup(job *models.BackupJob, backupDir string) (*backup.Manifest, error) {
+	if err := utils.EnsureDir(backupDir); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	manifest := &backup.Manifest{
+		ID:           job.ID.String(),
+		Mode:         string(job.Mode),
+		CreatedAt:    *job.StartedAt,
+		DatabaseDump: backup.DatabaseDumpFile,
+	}
+
+	// Snapshot the database first so every blob it references is synced below
+	dumpPath := filepath.Join(backupDir, backup.DatabaseDumpFile)
+	if err := backup.DumpDatabase(s.cfg, dumpPath); err != nil {
+		return nil, err
+	}
+
+	dumpHash, err := backup.FileSHA256(dumpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum database dump: %w", err)
+	}
+	manifest.DatabaseSHA256 = dumpHash
+
+	// Blobs in a bucket are left to the bucket's own versioning and
+	// replication, so the backup holds the database only
+	if s.cfg.IsObjectStorage() {
+		manifest.CompletedAt = time.Now()
+		return manifest, backup.WriteManifest(backupDir, manifest)
+	}
+
+	var fileHashes []models.FileHash
+	if err := s.db.Select("hash", "size", "storage_path", "storage_tier").Find(&fileHashes).Error; err != nil {
+		return nil, fmt.Errorf("error fetching blobs: %w", err)
+	}
+
+	overwrite := job.Mode == models.BackupModeFull
+	for _, fileHash := range fileHashes {
+		manifest.Blobs = append(manifest.Blobs, backup.ManifestBlob{
+			Hash:        fileHash.Hash,
+			Size:        fileHash.Size,
+			StoragePath: fileHash.StoragePath,
+		})
+
+		srcPath := filepath.Join(s.cfg.StoragePath, fileHash.StoragePath)
+		if fileHash.StorageTier == models.StorageTierArchived && s.cfg.ArchiveStoragePath != "" {
+			srcPath = filepath.Join(s.cfg.ArchiveStoragePath, fileHash.StoragePath)
+		}
+		copied, size, err := backup.SyncBlob(srcPath, s.cfg.BackupPath, fileHash.Hash, overwrite)
+		if err != nil {
+			if os.IsNotExist(err) {
+				manifest.MissingBlobs = append(manifest.MissingBlobs, fileHash.Hash)
+				continue
+			}
+			return manifest, fmt.Errorf("failed to sync blob %s: %w", fileHash.Hash, err)
+		}
+
+		if copied {
+			manifest.BlobsCopied++
+			manifest.BytesCopied += size
+		}
+	}
+
+	manifest.CompletedAt = time.Now()
+	if err := backup.WriteManifest(backupDir, manifest); err != nil {
+		return manifest, err
+	}
+
+	return manifest, nil
+}
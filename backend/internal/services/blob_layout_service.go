@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// shardedStoragePattern matches storage paths already in the sharded layout
+const shardedStoragePattern = "storage/__/__/%"
+
+// BlobStoragePath returns where a blob with the given content hash is stored,
+// relative to each storage root. Blobs are sharded two levels deep by the
+// first bytes of the hash, storage/ab/cd/abcd..., so no single directory
+// grows to millions of entries.
+func BlobStoragePath(hash string) string {
+	if len(hash) < 4 {
+		return path.Join("storage", hash)
+	}
+	return path.Join("storage", hash[0:2], hash[2:4], hash)
+}
+
+// blobPathCandidates returns a stored path followed by the same blob's path in
+// the other layout, so reads keep working while the migration is moving it
+func blobPathCandidates(storagePath string) []string {
+	candidates := []string{storagePath}
+	hash := path.Base(filepath.ToSlash(storagePath))
+	if alternate := BlobStoragePath(hash); alternate != storagePath {
+		candidates = append(candidates, alternate)
+	} else if legacy := path.Join("storage", hash); legacy != storagePath {
+		candidates = append(candidates, legacy)
+	}
+	return candidates
+}
+
+// BlobLayoutService moves blobs written flat under storage/{hash} into the
+// sharded layout, on every storage root that holds a copy
+type BlobLayoutService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewBlobLayoutService creates a new blob layout service
+func NewBlobLayoutService(db *gorm.DB, cfg *config.Config) *BlobLayoutService {
+	return &BlobLayoutService{db: db, cfg: cfg}
+}
+
+// Start migrates the blob layout in the background
+func (s *BlobLayoutService) Start() {
+	go func() {
+		migrated := s.MigrateAll()
+		if migrated > 0 {
+			log.Printf("Blob layout: moved %d blobs into sharded directories", migrated)
+		}
+	}()
+}
+
+// MigrateAll moves every blob still in the flat layout in batches and returns
+// the number moved. Blobs that fail are logged and left where they are.
+func (s *BlobLayoutService) MigrateAll() int {
+	batchSize := s.cfg.BlobLayoutBatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	migrated := 0
+	failed := []uuid.UUID{}
+	for {
+		query := s.db.Where("storage_path NOT LIKE ?", shardedStoragePattern)
+		if len(failed) > 0 {
+			query = query.Where("id NOT IN ?", failed)
+		}
+		var fileHashes []models.FileHash
+		if err := query.Order("created_at ASC").Limit(batchSize).Find(&fileHashes).Error; err != nil {
+			log.Printf("Blob layout: failed to fetch blobs: %v", err)
+			return migrated
+		}
+
+		for i := range fileHashes {
+			if err := s.migrate(&fileHashes[i]); err != nil {
+				log.Printf("Blob layout: failed to move blob %s: %v", fileHashes[i].Hash, err)
+				failed = append(failed, fileHashes[i].ID)
+				continue
+			}
+			migrated++
+		}
+
+		if len(fileHashes) < batchSize {
+			return migrated
+		}
+	}
+}
+
+// migrate moves one blob on each storage root, then points its row at the
+// new path. The row is only updated if nothing else changed it meanwhile.
+func (s *BlobLayoutService) migrate(fileHash *models.FileHash) error {
+	oldPath := fileHash.StoragePath
+	newPath := BlobStoragePath(fileHash.Hash)
+	if oldPath == newPath {
+		return nil
+	}
+
+	for _, root := range []string{s.cfg.StoragePath, s.cfg.ReplicaStoragePath, s.cfg.ArchiveStoragePath} {
+		if root == "" {
+			continue
+		}
+		if err := moveBlob(filepath.Join(root, oldPath), filepath.Join(root, newPath)); err != nil {
+			return err
+		}
+	}
+
+	return s.db.Model(&models.FileHash{}).
+		Where("id = ? AND storage_path = ?", fileHash.ID, oldPath).
+		Update("storage_path", newPath).Error
+}
+
+// moveBlob renames a blob within its storage root. A root without the blob is
+// skipped, and a blob already at the destination is left in place.
+func moveBlob(src, dest string) error {
+	if _, err := os.Stat(src); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error checking %s: %w", src, err)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		// Same content hash, same bytes: drop the flat copy
+		return os.Remove(src)
+	}
+	if err := utils.EnsureDir(filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("error creating shard directory: %w", err)
+	}
+	if err := os.Rename(src, dest); err != nil {
+		return fmt.Errorf("error moving blob: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+
+	"file-vault-system/backend/internal/config"
+)
+
+// ErrBlobBusy is returned when every read slot of a blob stays taken for
+// longer than the configured wait
+var ErrBlobBusy = errors.New("too many concurrent reads of this file")
+
+// blobReadStats is published on /debug/vars so admins can watch throttling
+// and cache effectiveness
+var blobReadStats = expvar.NewMap("blob_reads")
+
+// blobReadGate bounds concurrent disk reads of each blob and keeps small,
+// hot blobs in memory. A blob is immutable under its hash, so cached bytes
+// never need invalidating.
+type blobReadGate struct {
+	limit int
+	wait  time.Duration
+
+	mu    sync.Mutex
+	slots map[string]*blobSlots
+
+	cache *hotBlobCache
+}
+
+// blobSlots is the read semaphore of one blob and the number of readers
+// holding or waiting for it, so idle blobs can be forgotten
+type blobSlots struct {
+	sem   chan struct{}
+	users int
+}
+
+var (
+	sharedReadGate     *blobReadGate
+	sharedReadGateOnce sync.Once
+)
+
+// readGate returns the process-wide gate, so every stream service counts
+// against the same limits
+func readGate(cfg *config.Config) *blobReadGate {
+	sharedReadGateOnce.Do(func() {
+		sharedReadGate = &blobReadGate{
+			limit: cfg.BlobReadConcurrency,
+			wait:  time.Duration(cfg.BlobReadWaitTimeout) * time.Second,
+			slots: make(map[string]*blobSlots),
+			cache: newHotBlobCache(cfg.HotBlobCacheSize, cfg.HotBlobMaxSize),
+		}
+	})
+	return sharedReadGate
+}
+
+// acquire takes a read slot for the blob, waiting up to the configured time.
+// The returned func gives the slot back.
+func (g *blobReadGate) acquire(ctx context.Context, hash string) (func(), error) {
+	if g.limit <= 0 {
+		blobReadStats.Add("in_flight", 1)
+		return func() { blobReadStats.Add("in_flight", -1) }, nil
+	}
+
+	g.mu.Lock()
+	slots, ok := g.slots[hash]
+	if !ok {
+		slots = &blobSlots{sem: make(chan struct{}, g.limit)}
+		g.slots[hash] = slots
+	}
+	slots.users++
+	g.mu.Unlock()
+
+	timer := time.NewTimer(g.wait)
+	defer timer.Stop()
+
+	select {
+	case slots.sem <- struct{}{}:
+		blobReadStats.Add("in_flight", 1)
+		return func() {
+			<-slots.sem
+			blobReadStats.Add("in_flight", -1)
+			g.leave(hash, slots)
+		}, nil
+	case <-timer.C:
+		blobReadStats.Add("throttled", 1)
+		g.leave(hash, slots)
+		return nil, ErrBlobBusy
+	case <-ctx.Done():
+		g.leave(hash, slots)
+		return nil, ctx.Err()
+	}
+}
+
+// leave drops a reader from a blob's slots, forgetting the blob once no one
+// is reading it
+func (g *blobReadGate) leave(hash string, slots *blobSlots) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	slots.users--
+	if slots.users == 0 {
+		delete(g.slots, hash)
+	}
+}
+
+// hotBlobCache is a size-bounded LRU of small blob contents keyed by hash
+type hotBlobCache struct {
+	capacity int64
+	maxEntry int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// hotBlob is one cached blob
+type hotBlob struct {
+	hash string
+	data []byte
+}
+
+func newHotBlobCache(capacity, maxEntry int64) *hotBlobCache {
+	return &hotBlobCache{
+		capacity: capacity,
+		maxEntry: maxEntry,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// accepts reports whether a blob of the given size may be cached
+func (c *hotBlobCache) accepts(size int64) bool {
+	return c.capacity > 0 && size <= c.maxEntry && size <= c.capacity
+}
+
+// get returns a cached blob and marks it recently used
+func (c *hotBlobCache) get(hash string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[hash]
+	if !ok {
+		blobReadStats.Add("cache_misses", 1)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	blobReadStats.Add("cache_hits", 1)
+	return elem.Value.(*hotBlob).data, true
+}
+
+// put caches a blob, evicting the least recently used ones to make room
+func (c *hotBlobCache) put(hash string, data []byte) {
+	if !c.accepts(int64(len(data))) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[hash]; ok {
+		return
+	}
+
+	for c.size+int64(len(data)) > c.capacity {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		evicted := c.order.Remove(oldest).(*hotBlob)
+		delete(c.entries, evicted.hash)
+		c.size -= int64(len(evicted.data))
+		blobReadStats.Add("cache_evictions", 1)
+		blobReadStats.Add("cache_entries", -1)
+		blobReadStats.Add("cache_bytes", -int64(len(evicted.data)))
+	}
+
+	c.entries[hash] = c.order.PushFront(&hotBlob{hash: hash, data: data})
+	c.size += int64(len(data))
+	blobReadStats.Add("cache_entries", 1)
+	blobReadStats.Add("cache_bytes", int64(len(data)))
+}
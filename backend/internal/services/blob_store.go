@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/storage"
+	"file-vault-system/backend/pkg/utils"
+)
+
+var (
+	sharedBlobStore     storage.Storage
+	sharedBlobStoreErr  error
+	sharedBlobStoreOnce sync.Once
+)
+
+// OpenBlobStore returns the process-wide store holding primary blob content:
+// the storage directory, or the S3-compatible bucket when STORAGE_BACKEND is
+// s3. Blobs are keyed by their FileHash.StoragePath in either.
+func OpenBlobStore(cfg *config.Config) (storage.Storage, error) {
+	sharedBlobStoreOnce.Do(func() {
+		if !cfg.IsObjectStorage() {
+			sharedBlobStore = storage.NewLocal(cfg.StoragePath)
+			return
+		}
+		sharedBlobStore, sharedBlobStoreErr = storage.NewS3(storage.S3Options{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			Prefix:          cfg.S3Prefix,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3ForcePathStyle,
+			PublicEndpoint:  cfg.S3PublicEndpoint,
+		})
+	})
+	return sharedBlobStore, sharedBlobStoreErr
+}
+
+// spoolBlob copies object-stored content into a temp file for tools that
+// only read from disk, and returns its path. The caller removes the file.
+func spoolBlob(ctx context.Context, cfg *config.Config, r io.Reader) (string, error) {
+	if err := utils.EnsureDir(cfg.UploadTempDir); err != nil {
+		return "", fmt.Errorf("error creating temp directory: %w", err)
+	}
+	// The staging prefix lets the startup sweep clear copies left by a crash
+	tmp, err := os.CreateTemp(cfg.UploadTempDir, "upload-blob-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating temp file: %w", err)
+	}
+	_, err = io.Copy(tmp, &contextReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("error copying blob from object storage: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// locateBlob finds a blob's content on disk for background jobs, on the
+// primary store and then the replica. Object-stored content is copied to a
+// temp file first. The returned func removes any such copy.
+func locateBlob(ctx context.Context, cfg *config.Config, fileHash *models.FileHash) (string, func(), bool) {
+	if !cfg.IsObjectStorage() {
+		path, ok := locateLocalBlob(cfg, fileHash)
+		return path, func() {}, ok
+	}
+
+	store, err := OpenBlobStore(cfg)
+	if err != nil {
+		return "", nil, false
+	}
+	body, err := store.Get(ctx, fileHash.StoragePath, 0)
+	if err != nil {
+		return "", nil, false
+	}
+	defer body.Close()
+	path, err := spoolBlob(ctx, cfg, body)
+	if err != nil {
+		return "", nil, false
+	}
+	return path, func() { os.Remove(path) }, true
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/classify"
+	"file-vault-system/backend/pkg/database"
+)
+
+const (
+	// classificationBatchSize is the number of files classified per query
+	classificationBatchSize = 100
+	// classificationTextChars is how much extracted text is searched for keywords
+	classificationTextChars = 20000
+)
+
+// TagMatchSQL matches a file carrying a tag, whether set by the user or the
+// classifier. It takes the tag twice
+func TagMatchSQL(db *gorm.DB) string {
+	if database.IsSQLite(db) {
+		return `(array_contains(files.tags, ?) OR array_contains(files.auto_tags, ?))`
+	}
+	return `(? = ANY(files.tags) OR ? = ANY(files.auto_tags))`
+}
+
+// ClassificationService tags files in the background from their type, name
+// and extracted text. Tags it applies are stored in files.auto_tags, apart
+// from the tags users set themselves
+type ClassificationService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewClassificationService creates a new classification service
+func NewClassificationService(db *gorm.DB, cfg *config.Config) *ClassificationService {
+	return &ClassificationService{db: db, cfg: cfg}
+}
+
+// Enabled reports whether the classification worker runs
+func (s *ClassificationService) Enabled() bool {
+	return s.cfg.EnableAutoTagging
+}
+
+// Start runs classification passes in the background
+func (s *ClassificationService) Start() {
+	interval := time.Duration(s.cfg.AutoTagInterval) * time.Second
+	if interval <= 0 {
+		interval = 60 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if classified := s.ClassifyPending(); classified > 0 {
+				log.Printf("Classification: tagged %d file(s)", classified)
+			}
+		}
+	}()
+}
+
+// ClassifyPending classifies unclassified files of users with auto-tagging
+// on, in batches until none are left. Files whose content is still queued for
+// indexing wait so their text can be used. It returns the number classified.
+func (s *ClassificationService) ClassifyPending() int {
+	classified := 0
+	for {
+		var files []models.File
+		if err := s.db.Select("files.id, files.original_filename, files.mime_type, files.file_hash_id").
+			Joins("JOIN users ON users.id = files.owner_id").
+			Where("files.classified_at IS NULL AND files.is_encrypted = false AND users.auto_tagging_enabled = true").
+			Where(`NOT EXISTS (
+				SELECT 1 FROM content_index ci
+				WHERE ci.file_hash_id = files.file_hash_id AND ci.status = ? AND files.storage_tier = ?)`,
+				models.ContentIndexPending, models.StorageTierHot).
+			Order("files.created_at ASC").
+			Limit(classificationBatchSize).
+			Find(&files).Error; err != nil {
+			log.Printf("Classification: failed to fetch unclassified files: %v", err)
+			return classified
+		}
+		if len(files) == 0 {
+			return classified
+		}
+
+		texts, err := s.extractedText(files)
+		if err != nil {
+			log.Printf("Classification: failed to load extracted text: %v", err)
+			return classified
+		}
+
+		now := time.Now()
+		for _, file := range files {
+			tags := models.StringArray(classify.Classify(file.OriginalFilename, file.MimeType, texts[file.FileHashID]))
+			if err := s.db.Model(&models.File{}).Where("id = ?", file.ID).UpdateColumns(map[string]interface{}{
+				"auto_tags":     tags,
+				"classified_at": now,
+			}).Error; err != nil {
+				log.Printf("Classification: failed to tag file %s: %v", file.ID, err)
+				return classified
+			}
+			classified++
+		}
+
+		if len(files) < classificationBatchSize {
+			return classified
+		}
+	}
+}
+
+// extractedText loads the start of the indexed text of each file's blob
+func (s *ClassificationService) extractedText(files []models.File) (map[uuid.UUID]string, error) {
+	hashIDs := make([]uuid.UUID, len(files))
+	for i, file := range files {
+		hashIDs[i] = file.FileHashID
+	}
+
+	var rows []struct {
+		FileHashID uuid.UUID
+		Text       string
+	}
+	if err := s.db.Model(&models.ContentIndex{}).
+		Select("file_hash_id, LEFT(extracted_text, ?) AS text", classificationTextChars).
+		Where("file_hash_id IN ? AND status = ?", hashIDs, models.ContentIndexIndexed).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	texts := make(map[uuid.UUID]string, len(rows))
+	for _, row := range rows {
+		texts[row.FileHashID] = row.Text
+	}
+	return texts, nil
+}
+
+// SetAutoTagging turns classification on or off for a user. Turning it off
+// removes the tags already applied; turning it back on classifies the user's
+// files again on the next pass
+func (s *ClassificationService) SetAutoTagging(userID uuid.UUID, enabled bool) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).Update("auto_tagging_enabled", enabled)
+		if result.Error != nil {
+			return fmt.Errorf("error updating auto-tagging setting: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if enabled {
+			return nil
+		}
+
+		if err := tx.Model(&models.File{}).Where("owner_id = ?", userID).UpdateColumns(map[string]interface{}{
+			"auto_tags":     nil,
+			"classified_at": nil,
+		}).Error; err != nil {
+			return fmt.Errorf("error removing auto tags: %w", err)
+		}
+		return nil
+	})
+}
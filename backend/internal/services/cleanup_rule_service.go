@@ -0,0 +1,394 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/models"
+)
+
+// MaxCleanupRules is how many cleanup rules a user may keep
+const MaxCleanupRules = 20
+
+const (
+	// maxCleanupAgeDays is the most days a cleanup rule may wait
+	maxCleanupAgeDays = 3650
+	// cleanupBatchSize is the number of files fetched per query of a cleanup pass
+	cleanupBatchSize = 200
+	// cleanupPreviewFiles is how many of the matching files a preview lists
+	cleanupPreviewFiles = 100
+)
+
+var (
+	// ErrCleanupRuleNotFound is returned when a cleanup rule does not exist for the user
+	ErrCleanupRuleNotFound = apperrors.ErrNotFound.WithCode("CLEANUP_RULE_NOT_FOUND", "cleanup rule not found")
+	// ErrCleanupRuleFolderNotFound is returned for rules naming a folder the user does not own
+	ErrCleanupRuleFolderNotFound = apperrors.ErrNotFound.WithCode("FOLDER_NOT_FOUND", "folder not found")
+	// ErrCleanupRuleLimit is returned when the user already has the most cleanup rules allowed
+	ErrCleanupRuleLimit = apperrors.ErrConflict.WithCode("CLEANUP_RULE_LIMIT", "no more cleanup rules can be added").
+				WithDetail("max_rules", MaxCleanupRules)
+	// ErrInvalidCleanupRule is returned for rules with a malformed pattern or age
+	ErrInvalidCleanupRule = apperrors.ErrInvalidInput.WithCode("INVALID_CLEANUP_RULE", "invalid cleanup rule")
+)
+
+// CleanupPreview is what a cleanup rule would move to the trash if it ran now
+type CleanupPreview struct {
+	Cutoff     time.Time     // files uploaded before this match
+	TotalFiles int64         // matching files
+	TotalBytes int64         // their combined size
+	Files      []models.File // the oldest matching files, up to cleanupPreviewFiles
+}
+
+// CleanupRuleService keeps users' cleanup rules and applies them in the
+// background. Every file a rule moves to the trash is recorded in the owner's
+// audit trail.
+type CleanupRuleService struct {
+	db           *gorm.DB
+	auditService *AuditService
+}
+
+// NewCleanupRuleService creates a new cleanup rule service
+func NewCleanupRuleService(db *gorm.DB, auditService *AuditService) *CleanupRuleService {
+	return &CleanupRuleService{db: db, auditService: auditService}
+}
+
+// List returns a user's cleanup rules, oldest first
+func (s *CleanupRuleService) List(userID uuid.UUID) ([]models.CleanupRule, error) {
+	var rules []models.CleanupRule
+	if err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("error fetching cleanup rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Get returns one of a user's cleanup rules
+func (s *CleanupRuleService) Get(userID, ruleID uuid.UUID) (*models.CleanupRule, error) {
+	var rule models.CleanupRule
+	if err := s.db.Where("user_id = ?", userID).First(&rule, "id = ?", ruleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCleanupRuleNotFound
+		}
+		return nil, fmt.Errorf("error fetching cleanup rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// Create adds a cleanup rule for a user. Its patterns are normalized and its
+// folder must be the user's own.
+func (s *CleanupRuleService) Create(userID uuid.UUID, rule models.CleanupRule) (*models.CleanupRule, error) {
+	var count int64
+	if err := s.db.Model(&models.CleanupRule{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("error counting cleanup rules: %w", err)
+	}
+	if count >= MaxCleanupRules {
+		return nil, ErrCleanupRuleLimit
+	}
+
+	normalized, err := s.prepare(userID, rule)
+	if err != nil {
+		return nil, err
+	}
+	normalized.ID = uuid.New()
+	normalized.UserID = userID
+	if err := s.db.Create(&normalized).Error; err != nil {
+		return nil, fmt.Errorf("error creating cleanup rule: %w", err)
+	}
+	return &normalized, nil
+}
+
+// Update replaces what a user's cleanup rule matches and whether it is active
+func (s *CleanupRuleService) Update(userID, ruleID uuid.UUID, rule models.CleanupRule) (*models.CleanupRule, error) {
+	normalized, err := s.prepare(userID, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.db.Model(&models.CleanupRule{}).Where("id = ? AND user_id = ?", ruleID, userID).Select(
+		"name", "folder_id", "include_subfolders", "name_pattern", "mime_type", "tag", "older_than_days", "is_active", "updated_at",
+	).Updates(&models.CleanupRule{
+		Name:              normalized.Name,
+		FolderID:          normalized.FolderID,
+		IncludeSubfolders: normalized.IncludeSubfolders,
+		NamePattern:       normalized.NamePattern,
+		MimeType:          normalized.MimeType,
+		Tag:               normalized.Tag,
+		OlderThanDays:     normalized.OlderThanDays,
+		IsActive:          normalized.IsActive,
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("error updating cleanup rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCleanupRuleNotFound
+	}
+	return s.Get(userID, ruleID)
+}
+
+// Delete removes one of a user's cleanup rules
+func (s *CleanupRuleService) Delete(userID, ruleID uuid.UUID) error {
+	result := s.db.Where("id = ? AND user_id = ?", ruleID, userID).Delete(&models.CleanupRule{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting cleanup rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrCleanupRuleNotFound
+	}
+	return nil
+}
+
+// prepare normalizes a rule and makes sure the user owns its folder
+func (s *CleanupRuleService) prepare(userID uuid.UUID, rule models.CleanupRule) (models.CleanupRule, error) {
+	normalized, err := normalizeCleanupRule(rule)
+	if err != nil {
+		return normalized, err
+	}
+	if normalized.FolderID != nil {
+		var folder models.Folder
+		err := s.db.Select("id").Where("id = ? AND owner_id = ?", *normalized.FolderID, userID).First(&folder).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return normalized, ErrCleanupRuleFolderNotFound.WithDetail("folder_id", *normalized.FolderID)
+		}
+		if err != nil {
+			return normalized, fmt.Errorf("error fetching folder: %w", err)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeCleanupRule trims a rule's name and patterns and lower-cases its
+// name and type patterns, and refuses patterns and ages that are malformed
+func normalizeCleanupRule(rule models.CleanupRule) (models.CleanupRule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.NamePattern = strings.ToLower(strings.TrimSpace(rule.NamePattern))
+	rule.MimeType = strings.ToLower(strings.TrimSpace(rule.MimeType))
+	rule.Tag = strings.TrimSpace(rule.Tag)
+
+	if rule.Name == "" || len(rule.Name) > 100 {
+		return rule, ErrInvalidCleanupRule.WithDetail("name", rule.Name)
+	}
+	if rule.OlderThanDays < 1 || rule.OlderThanDays > maxCleanupAgeDays {
+		return rule, ErrInvalidCleanupRule.With("older_than_days must be between 1 and 3650").WithDetail("older_than_days", rule.OlderThanDays)
+	}
+	if strings.ContainsAny(rule.NamePattern, "/\\") || len(rule.NamePattern) > 255 {
+		return rule, ErrInvalidCleanupRule.WithDetail("name_pattern", rule.NamePattern)
+	}
+	if rule.MimeType != "" && !validMimePattern(rule.MimeType) {
+		return rule, ErrInvalidCleanupRule.With("mime_type must be a type such as application/pdf or a family such as image/*").
+			WithDetail("mime_type", rule.MimeType)
+	}
+	if len(rule.Tag) > 50 {
+		return rule, ErrInvalidCleanupRule.WithDetail("tag", rule.Tag)
+	}
+	return rule, nil
+}
+
+// likePattern turns a name pattern with * and ? wildcards into a LIKE
+// pattern escaped with a backslash
+func likePattern(pattern string) string {
+	var b strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteByte('%')
+		case '?':
+			b.WriteByte('_')
+		case '%', '_', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ruleMatches scopes a files query to the files a cleanup rule would move to
+// the trash at the given time. Files under WORM retention or in quarantine
+// are never matched.
+func ruleMatches(rule *models.CleanupRule, now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("files.owner_id = ? AND files.created_at < ?", rule.UserID, now.AddDate(0, 0, -rule.OlderThanDays)).
+			Where("files.is_quarantined = ? AND (files.retain_until IS NULL OR files.retain_until <= ?)", false, now)
+
+		switch {
+		case rule.FolderID == nil && rule.IncludeSubfolders:
+			// every folder
+		case rule.FolderID == nil:
+			db = db.Where("files.folder_id IS NULL")
+		case rule.IncludeSubfolders:
+			db = db.Where("files.folder_id IN ("+folderSubtree+" SELECT id FROM subtree)", *rule.FolderID)
+		default:
+			db = db.Where("files.folder_id = ?", *rule.FolderID)
+		}
+
+		if rule.NamePattern != "" {
+			db = db.Where(`LOWER(files.original_filename) LIKE ? ESCAPE '\'`, likePattern(rule.NamePattern))
+		}
+		if family, subtype, _ := strings.Cut(rule.MimeType, "/"); subtype == "*" {
+			db = db.Where("files.mime_type LIKE ?", family+"/%")
+		} else if rule.MimeType != "" {
+			db = db.Where("LOWER(files.mime_type) = ?", rule.MimeType)
+		}
+		if rule.Tag != "" {
+			db = db.Where(TagMatchSQL(db), rule.Tag, rule.Tag)
+		}
+		return db
+	}
+}
+
+// Preview returns what a cleanup rule would move to the trash if it ran now,
+// whether or not it is active, without changing anything
+func (s *CleanupRuleService) Preview(rule *models.CleanupRule) (*CleanupPreview, error) {
+	now := time.Now()
+	preview := &CleanupPreview{Cutoff: now.AddDate(0, 0, -rule.OlderThanDays)}
+
+	var totals struct {
+		Files int64
+		Bytes int64
+	}
+	if err := s.db.Model(&models.File{}).Scopes(ruleMatches(rule, now)).
+		Select("COUNT(*) AS files, COALESCE(SUM(files.size), 0) AS bytes").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("error counting matching files: %w", err)
+	}
+	preview.TotalFiles = totals.Files
+	preview.TotalBytes = totals.Bytes
+
+	if err := s.db.Scopes(ruleMatches(rule, now)).Preload("Folder").
+		Order("files.created_at ASC, files.id ASC").
+		Limit(cleanupPreviewFiles).
+		Find(&preview.Files).Error; err != nil {
+		return nil, fmt.Errorf("error fetching matching files: %w", err)
+	}
+	return preview, nil
+}
+
+// Start applies the active cleanup rules in the background
+func (s *CleanupRuleService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			trashed, err := s.ApplyAll()
+			if err != nil {
+				log.Printf("Cleanup rules failed: %v", err)
+			} else if trashed > 0 {
+				log.Printf("Cleanup rules: moved %d file(s) to the trash", trashed)
+			}
+		}
+	}()
+}
+
+// ApplyAll applies every active cleanup rule and returns the number of files
+// moved to the trash
+func (s *CleanupRuleService) ApplyAll() (int, error) {
+	var rules []models.CleanupRule
+	if err := s.db.Where("is_active = ?", true).Order("created_at").Find(&rules).Error; err != nil {
+		return 0, fmt.Errorf("error fetching cleanup rules: %w", err)
+	}
+
+	total := 0
+	for i := range rules {
+		trashed, err := s.Apply(&rules[i])
+		total += trashed
+		if err != nil {
+			log.Printf("Cleanup rule %s failed: %v", rules[i].ID, err)
+		}
+	}
+	return total, nil
+}
+
+// Apply moves the files a cleanup rule matches to the trash, each in its own
+// transaction with an audit entry, and records the outcome on the rule. It
+// returns the number of files moved.
+func (s *CleanupRuleService) Apply(rule *models.CleanupRule) (int, error) {
+	now := time.Now()
+	trashed := 0
+	var after *uuid.UUID
+	for {
+		query := s.db.Scopes(ruleMatches(rule, now)).Order("files.id").Limit(cleanupBatchSize)
+		if after != nil {
+			query = query.Where("files.id > ?", *after)
+		}
+		var files []models.File
+		if err := query.Find(&files).Error; err != nil {
+			return trashed, fmt.Errorf("error fetching matching files: %w", err)
+		}
+
+		for i := range files {
+			moved, err := s.trash(rule, files[i].ID, now)
+			if err != nil {
+				log.Printf("Cleanup rule %s: failed to move file %s to the trash: %v", rule.ID, files[i].ID, err)
+				continue
+			}
+			if moved {
+				trashed++
+			}
+		}
+		if len(files) < cleanupBatchSize {
+			break
+		}
+		after = &files[len(files)-1].ID
+	}
+
+	if err := s.db.Model(rule).UpdateColumns(map[string]interface{}{
+		"last_run_at":  now,
+		"last_trashed": trashed,
+	}).Error; err != nil {
+		return trashed, fmt.Errorf("error recording cleanup rule run: %w", err)
+	}
+	return trashed, nil
+}
+
+// trash moves one file to the trash for a cleanup rule. The file is matched
+// against the rule again in the transaction, so a file changed since it was
+// listed, for example moved or put under retention, is left alone.
+func (s *CleanupRuleService) trash(rule *models.CleanupRule, fileID uuid.UUID, now time.Time) (bool, error) {
+	moved := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var file models.File
+		if err := tx.Scopes(ruleMatches(rule, now)).First(&file, "files.id = ?", fileID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("error fetching file: %w", err)
+		}
+
+		freed, err := TrashFile(tx, &file, nil, models.FileEventPayload{"cleanup_rule_id": rule.ID})
+		if errors.Is(err, ErrFileAlreadyTrashed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.auditService.Enqueue(tx, LogActivityParams{
+			UserID:       file.OwnerID,
+			Action:       models.AuditActionDelete,
+			ResourceType: models.AuditResourceFile,
+			ResourceID:   &file.ID,
+			ResourceName: &file.OriginalFilename,
+			Details: models.AuditLogDetails{
+				"automatic":            true,
+				"cleanup_rule_id":      rule.ID,
+				"cleanup_rule_name":    rule.Name,
+				"older_than_days":      rule.OlderThanDays,
+				"actual_storage_freed": freed,
+				"timestamp":            now.Unix(),
+			},
+			Status: models.AuditStatusSuccess,
+		}); err != nil {
+			return err
+		}
+		moved = true
+		return nil
+	})
+	return moved, err
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+	"file-vault-system/backend/pkg/dlp"
+	"file-vault-system/backend/pkg/ocr"
+)
+
+const (
+	// contentIndexBatchSize is the number of blobs indexed per query
+	contentIndexBatchSize = 20
+	// contentIndexMaxAttempts is how often extraction is retried before a blob is marked failed
+	contentIndexMaxAttempts = 3
+)
+
+// ContentIndexService extracts searchable text from uploaded blobs in the
+// background, reading text files directly and running OCR on images and PDFs
+type ContentIndexService struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	engine ocr.Engine
+}
+
+// NewContentIndexService creates a new content index service
+func NewContentIndexService(db *gorm.DB, cfg *config.Config) *ContentIndexService {
+	return &ContentIndexService{db: db, cfg: cfg}
+}
+
+// Enabled reports whether uploads are queued for indexing
+func (s *ContentIndexService) Enabled() bool {
+	return s.cfg.EnableContentIndex
+}
+
+// EnqueueFiles queues the blobs behind newly uploaded files
+func (s *ContentIndexService) EnqueueFiles(fileIDs []uuid.UUID) error {
+	if !s.Enabled() || len(fileIDs) == 0 {
+		return nil
+	}
+
+	if err := s.db.Exec(`
+		INSERT INTO content_index (file_hash_id, status)
+		SELECT DISTINCT file_hash_id, ? FROM files WHERE id IN ? AND is_encrypted = false
+		ON CONFLICT DO NOTHING`, models.ContentIndexPending, fileIDs).Error; err != nil {
+		return fmt.Errorf("error queueing content index: %w", err)
+	}
+	return nil
+}
+
+// Requeue resets a blob's entry so it is extracted again on the next pass
+func (s *ContentIndexService) Requeue(fileHashID uuid.UUID) (*models.ContentIndex, error) {
+	entry := models.ContentIndex{FileHashID: fileHashID, Status: models.ContentIndexPending}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "file_hash_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status":     models.ContentIndexPending,
+			"attempts":   0,
+			"error":      "",
+			"updated_at": time.Now(),
+		}),
+	}).Create(&entry).Error; err != nil {
+		return nil, fmt.Errorf("error requeueing content index: %w", err)
+	}
+	return s.GetEntry(fileHashID)
+}
+
+// Backfill queues every blob that has never been indexed and returns how
+// many were queued. Blobs of end-to-end encrypted files are skipped.
+func (s *ContentIndexService) Backfill() (int64, error) {
+	result := s.db.Exec(`
+		INSERT INTO content_index (file_hash_id, status)
+		SELECT fh.id, ? FROM file_hashes fh
+		WHERE NOT EXISTS (SELECT 1 FROM content_index ci WHERE ci.file_hash_id = fh.id)
+		AND EXISTS (SELECT 1 FROM files f WHERE f.file_hash_id = fh.id AND f.is_encrypted = false)
+		ON CONFLICT DO NOTHING`, models.ContentIndexPending)
+	if result.Error != nil {
+		return 0, fmt.Errorf("error backfilling content index: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ContentIndexSummary counts index entries by status
+type ContentIndexSummary struct {
+	Enabled   bool                                `json:"enabled"`
+	OCREngine string                              `json:"ocr_engine"`
+	Counts    map[models.ContentIndexStatus]int64 `json:"counts"`
+}
+
+// Summary reports how many blobs are in each indexing state
+func (s *ContentIndexService) Summary() (*ContentIndexSummary, error) {
+	var rows []struct {
+		Status models.ContentIndexStatus
+		Count  int64
+	}
+	if err := s.db.Model(&models.ContentIndex{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error counting content index: %w", err)
+	}
+
+	summary := &ContentIndexSummary{
+		Enabled:   s.Enabled(),
+		OCREngine: s.cfg.OCREngine,
+		Counts:    map[models.ContentIndexStatus]int64{},
+	}
+	for _, row := range rows {
+		summary.Counts[row.Status] = row.Count
+	}
+	return summary, nil
+}
+
+// GetEntry returns the index entry for a blob, or nil when it was never queued
+func (s *ContentIndexService) GetEntry(fileHashID uuid.UUID) (*models.ContentIndex, error) {
+	var entries []models.ContentIndex
+	if err := s.db.Omit("extracted_text").Where("file_hash_id = ?", fileHashID).Limit(1).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("error fetching content index: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+// Markers placed around matched words in content snippets. They are
+// private-use characters, so they never occur in extracted text.
+const (
+	SnippetMatchStart = "\uE000"
+	SnippetMatchEnd   = "\uE001"
+)
+
+// MatchSnippets returns an excerpt of the extracted text for each blob whose
+// content matches a search query, keyed by blob, with the matched words
+// between SnippetMatchStart and SnippetMatchEnd
+func (s *ContentIndexService) MatchSnippets(fileHashIDs []uuid.UUID, query string) (map[uuid.UUID]string, error) {
+	snippets := make(map[uuid.UUID]string)
+	if len(fileHashIDs) == 0 || strings.TrimSpace(query) == "" {
+		return snippets, nil
+	}
+
+	if database.IsSQLite(s.db) {
+		return s.matchSnippetsLike(fileHashIDs, query)
+	}
+
+	options := fmt.Sprintf(`StartSel=%s, StopSel=%s, MinWords=8, MaxWords=25, MaxFragments=2, FragmentDelimiter=" ... "`,
+		SnippetMatchStart, SnippetMatchEnd)
+
+	var rows []struct {
+		FileHashID uuid.UUID
+		Snippet    string
+	}
+	if err := s.db.Raw(`
+		SELECT file_hash_id, ts_headline('english', extracted_text, plainto_tsquery('english', ?), ?) AS snippet
+		FROM content_index
+		WHERE status = ? AND file_hash_id IN ?
+			AND to_tsvector('english', COALESCE(extracted_text, '')) @@ plainto_tsquery('english', ?)`,
+		query, options, models.ContentIndexIndexed, fileHashIDs, query).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error building content snippets: %w", err)
+	}
+
+	for _, row := range rows {
+		snippets[row.FileHashID] = row.Snippet
+	}
+	return snippets, nil
+}
+
+// snippetContext is the number of characters kept on each side of a match
+// in snippets built without full-text search
+const snippetContext = 60
+
+// matchSnippetsLike is MatchSnippets for databases without full-text search:
+// the query must appear in the text as written, ignoring case, and the
+// snippet is the text around its first occurrence
+func (s *ContentIndexService) matchSnippetsLike(fileHashIDs []uuid.UUID, query string) (map[uuid.UUID]string, error) {
+	snippets := make(map[uuid.UUID]string)
+
+	var rows []struct {
+		FileHashID    uuid.UUID
+		ExtractedText string
+	}
+	if err := s.db.Model(&models.ContentIndex{}).
+		Select("file_hash_id, extracted_text").
+		Where("status = ? AND file_hash_id IN ? AND extracted_text LIKE '%' || ? || '%'", models.ContentIndexIndexed, fileHashIDs, query).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error building content snippets: %w", err)
+	}
+
+	for _, row := range rows {
+		// Lowercase rune by rune so positions in the text stay the same
+		text := []rune(row.ExtractedText)
+		lower := string(foldRunes(text))
+		at := strings.Index(lower, string(foldRunes([]rune(query))))
+		if at < 0 {
+			continue
+		}
+		start := len([]rune(lower[:at]))
+		end := start + len([]rune(query))
+
+		from, to := max(start-snippetContext, 0), min(end+snippetContext, len(text))
+		snippet := string(text[from:start]) + SnippetMatchStart + string(text[start:end]) + SnippetMatchEnd + string(text[end:to])
+		if from > 0 {
+			snippet = "..." + snippet
+		}
+		if to < len(text) {
+			snippet += "..."
+		}
+		snippets[row.FileHashID] = snippet
+	}
+	return snippets, nil
+}
+
+// foldRunes lowercases each rune of a text
+func foldRunes(text []rune) []rune {
+	folded := make([]rune, len(text))
+	for i, r := range text {
+		folded[i] = unicode.ToLower(r)
+	}
+	return folded
+}
+
+// Start runs indexing passes in the background
+func (s *ContentIndexService) Start() {
+	s.engine = s.newEngine()
+
+	interval := time.Duration(s.cfg.ContentIndexInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if indexed := s.IndexPending(); indexed > 0 {
+				log.Printf("Content index: extracted text from %d blob(s)", indexed)
+			}
+		}
+	}()
+}
+
+// newEngine builds the configured OCR engine, returning nil when OCR is off or unavailable
+func (s *ContentIndexService) newEngine() ocr.Engine {
+	switch s.cfg.OCREngine {
+	case "tesseract":
+		engine, err := ocr.NewTesseractEngine(s.cfg.TesseractPath, s.cfg.PdftoppmPath, s.cfg.OCRLanguages, s.cfg.OCRMaxPDFPages)
+		if err != nil {
+			log.Printf("OCR disabled: %v", err)
+			return nil
+		}
+		return engine
+	case "external":
+		if s.cfg.OCRExternalURL == "" {
+			log.Printf("OCR disabled: OCR_EXTERNAL_URL is not set")
+			return nil
+		}
+		return ocr.NewHTTPEngine(s.cfg.OCRExternalURL, s.cfg.OCRExternalToken, time.Duration(s.cfg.OCRTimeout)*time.Second)
+	default:
+		return nil
+	}
+}
+
+// IndexPending extracts text from queued blobs in batches until none are
+// left. Archived blobs wait until they are restored. It returns the number
+// of blobs indexed.
+func (s *ContentIndexService) IndexPending() int {
+	indexed := 0
+	for {
+		var entries []models.ContentIndex
+		if err := s.db.Omit("extracted_text").
+			Joins("JOIN file_hashes ON file_hashes.id = content_index.file_hash_id").
+			Where("content_index.status = ? AND file_hashes.storage_tier = ?", models.ContentIndexPending, models.StorageTierHot).
+			Order("content_index.created_at ASC").
+			Limit(contentIndexBatchSize).
+			Find(&entries).Error; err != nil {
+			log.Printf("Content index: failed to fetch pending blobs: %v", err)
+			return indexed
+		}
+
+		progressed := false
+		for i := range entries {
+			if s.index(&entries[i]) {
+				indexed++
+				progressed = true
+			}
+		}
+
+		if len(entries) < contentIndexBatchSize || !progressed {
+			return indexed
+		}
+	}
+}
+
+// index extracts and stores the text of one blob, reporting whether it succeeded
+func (s *ContentIndexService) index(entry *models.ContentIndex) bool {
+	var fileHash models.FileHash
+	if err := s.db.First(&fileHash, "id = ?", entry.FileHashID).Error; err != nil {
+		s.recordFailure(entry, fmt.Errorf("blob not found: %w", err))
+		return false
+	}
+
+	// Every file sharing the blob has the same detected type
+	var mimeType string
+	s.db.Model(&models.File{}).Where("file_hash_id = ?", fileHash.ID).Limit(1).Pluck("mime_type", &mimeType)
+
+	var method models.ContentIndexMethod
+	switch {
+	case dlp.IsScannable(mimeType):
+		method = models.ContentIndexMethodText
+	case ocr.Supports(mimeType) && s.engine != nil:
+		method = models.ContentIndexMethodOCR
+	default:
+		reason := ""
+		if ocr.Supports(mimeType) {
+			reason = "no OCR engine is configured"
+		}
+		s.db.Model(entry).Updates(map[string]interface{}{
+			"status":    models.ContentIndexUnsupported,
+			"mime_type": mimeType,
+			"error":     reason,
+		})
+		return false
+	}
+
+	path, release, ok := locateBlob(context.Background(), s.cfg, &fileHash)
+	if !ok {
+		s.recordFailure(entry, fmt.Errorf("blob content not found in storage"))
+		return false
+	}
+	defer release()
+
+	var text, engine string
+	var err error
+	if method == models.ContentIndexMethodText {
+		text, err = s.readText(path)
+	} else {
+		engine = s.engine.Name()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.OCRTimeout)*time.Second)
+		text, err = s.engine.ExtractText(ctx, path, mimeType)
+		cancel()
+	}
+	if err != nil {
+		s.recordFailure(entry, err)
+		return false
+	}
+
+	text = s.normalizeText(text)
+	now := time.Now()
+	if err := s.db.Model(entry).Updates(map[string]interface{}{
+		"status":         models.ContentIndexIndexed,
+		"method":         method,
+		"engine":         engine,
+		"mime_type":      mimeType,
+		"extracted_text": text,
+		"char_count":     utf8.RuneCountInString(text),
+		"attempts":       gorm.Expr("attempts + 1"),
+		"error":          "",
+		"indexed_at":     now,
+	}).Error; err != nil {
+		log.Printf("Content index: failed to store text for blob %s: %v", fileHash.Hash, err)
+		return false
+	}
+	return true
+}
+
+// recordFailure counts a failed attempt, giving up once the retries are used
+func (s *ContentIndexService) recordFailure(entry *models.ContentIndex, cause error) {
+	status := models.ContentIndexPending
+	if entry.Attempts+1 >= contentIndexMaxAttempts {
+		status = models.ContentIndexFailed
+	}
+	s.db.Model(entry).Updates(map[string]interface{}{
+		"status":   status,
+		"attempts": gorm.Expr("attempts + 1"),
+		"error":    cause.Error(),
+	})
+	log.Printf("Content index: failed to index blob %s: %v", entry.FileHashID, cause)
+}
+
+// locateLocalBlob finds a blob on the primary store, falling back to the replica
+func locateLocalBlob(cfg *config.Config, fileHash *models.FileHash) (string, bool) {
+	var candidates []string
+	for _, p := range blobPathCandidates(fileHash.StoragePath) {
+		candidates = append(candidates, filepath.Join(cfg.StoragePath, p))
+	}
+	if cfg.ReplicaStoragePath != "" {
+		for _, p := range blobPathCandidates(fileHash.StoragePath) {
+			candidates = append(candidates, filepath.Join(cfg.ReplicaStoragePath, p))
+		}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// readText reads the start of a text file, up to four bytes per kept character
+func (s *ContentIndexService) readText(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open blob: %w", err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, int64(s.cfg.ContentIndexMaxChars)*4))
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob: %w", err)
+	}
+	return string(content), nil
+}
+
+// normalizeText makes extracted text safe to store: invalid UTF-8 and NUL
+// bytes are dropped and the text is capped at the configured length
+func (s *ContentIndexService) normalizeText(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\x00", ""))
+	if max := s.cfg.ContentIndexMaxChars; max > 0 && utf8.RuneCountInString(text) > max {
+		text = string([]rune(text)[:max])
+	}
+	return text
+}
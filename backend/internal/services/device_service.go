@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrDeviceNotFound is returned when a device does not exist or belongs to someone else
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrSyncDeviceInfo is returned when a sync client logs in without naming its device
+	ErrSyncDeviceInfo = errors.New("sync clients must provide device_name and device_platform")
+)
+
+// DeviceService registers the clients users sign in from and lets users
+// revoke them
+type DeviceService struct {
+	db *gorm.DB
+}
+
+// NewDeviceService creates a new device service
+func NewDeviceService(db *gorm.DB) *DeviceService {
+	return &DeviceService{db: db}
+}
+
+// DeviceInfo describes the client registering at login
+type DeviceInfo struct {
+	Name      string
+	Platform  string
+	Client    models.DeviceClient
+	UserAgent string
+	IPAddress string
+}
+
+// Register records a new device for a login. Sync clients must name their
+// device and platform; browsers fall back to their user agent
+func (s *DeviceService) Register(userID uuid.UUID, info DeviceInfo) (*models.Device, error) {
+	info.Name = strings.TrimSpace(info.Name)
+	info.Platform = strings.TrimSpace(info.Platform)
+	if info.Client != models.DeviceClientSync {
+		info.Client = models.DeviceClientWeb
+	} else if info.Name == "" || info.Platform == "" {
+		return nil, ErrSyncDeviceInfo
+	}
+	if info.Name == "" {
+		info.Name = "Web browser"
+	}
+	if len(info.Name) > 100 {
+		info.Name = info.Name[:100]
+	}
+	if len(info.Platform) > 50 {
+		info.Platform = info.Platform[:50]
+	}
+
+	device := models.Device{
+		UserID:     userID,
+		Name:       info.Name,
+		Platform:   info.Platform,
+		Client:     info.Client,
+		UserAgent:  info.UserAgent,
+		LastIP:     info.IPAddress,
+		LastSeenAt: time.Now(),
+	}
+	if err := s.db.Create(&device).Error; err != nil {
+		return nil, fmt.Errorf("error registering device: %w", err)
+	}
+	return &device, nil
+}
+
+// List returns the user's signed-in devices, most recently seen first
+func (s *DeviceService) List(userID uuid.UUID) ([]models.Device, error) {
+	var devices []models.Device
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_seen_at DESC").
+		Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("error listing devices: %w", err)
+	}
+	return devices, nil
+}
+
+// Revoke signs a device out; tokens issued to it stop working at once
+func (s *DeviceService) Revoke(userID, deviceID uuid.UUID) error {
+	result := s.db.Model(&models.Device{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", deviceID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("error revoking device: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/storage"
+	"file-vault-system/backend/pkg/utils"
+)
+
+var (
+	// ErrDirectUploadsUnavailable is returned when blobs are not kept in an object store
+	ErrDirectUploadsUnavailable = apperrors.ErrConflict.WithCode("DIRECT_UPLOADS_UNAVAILABLE", "direct uploads need object storage").
+					Explain("Upload through POST /api/v1/files/upload or the resumable upload endpoints instead")
+	// ErrDirectUploadNotFound is returned for uploads that do not exist, belong to someone else or expired
+	ErrDirectUploadNotFound = apperrors.ErrNotFound.WithCode("UPLOAD_NOT_FOUND", "upload not found or expired")
+	// ErrDirectUploadIncomplete is returned when finalizing an upload whose content has not arrived
+	ErrDirectUploadIncomplete = apperrors.ErrConflict.WithCode("UPLOAD_INCOMPLETE", "the content has not been uploaded").
+					Explain("PUT the file to the upload URL with the headers given, then finalize the upload")
+	// ErrDirectUploadMismatch is returned when the uploaded content is not what was declared
+	ErrDirectUploadMismatch = apperrors.ErrInvalidInput.WithCode("CHECKSUM_MISMATCH", "the uploaded content does not match the declared size and sha256").
+				Explain("Upload the file to the upload URL again")
+)
+
+// CreateDirectUploadParams describes an upload to be sent to the object store
+type CreateDirectUploadParams struct {
+	OwnerID          uuid.UUID
+	FolderID         *uuid.UUID
+	Filename         string
+	MimeType         string
+	Size             int64
+	SHA256           string
+	IsPublic         bool
+	EncryptionHeader string
+}
+
+// DirectUploadService lets clients upload content straight to the object
+// store with presigned URLs, so large files never pass through the server.
+// Each upload is written under its own key and copied to its blob key when
+// the upload is finalized.
+type DirectUploadService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewDirectUploadService creates a new direct upload service
+func NewDirectUploadService(db *gorm.DB, cfg *config.Config) *DirectUploadService {
+	return &DirectUploadService{db: db, cfg: cfg}
+}
+
+// presigner returns the blob store when it can presign uploads
+func (s *DirectUploadService) presigner() (storage.Presigner, error) {
+	if !s.cfg.IsObjectStorage() {
+		return nil, ErrDirectUploadsUnavailable
+	}
+	store, err := OpenBlobStore(s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	presigner, ok := store.(storage.Presigner)
+	if !ok {
+		return nil, ErrDirectUploadsUnavailable
+	}
+	return presigner, nil
+}
+
+// Create records an upload of params.Size bytes and returns it with the URL
+// the content is PUT to and the headers the PUT must carry
+func (s *DirectUploadService) Create(params CreateDirectUploadParams) (*models.DirectUpload, string, http.Header, error) {
+	presigner, err := s.presigner()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	id := uuid.New()
+	upload := &models.DirectUpload{
+		ID:               id,
+		OwnerID:          params.OwnerID,
+		FolderID:         params.FolderID,
+		Filename:         params.Filename,
+		MimeType:         params.MimeType,
+		Size:             params.Size,
+		SHA256:           params.SHA256,
+		ObjectKey:        "direct-uploads/" + id.String(),
+		IsPublic:         params.IsPublic,
+		EncryptionHeader: params.EncryptionHeader,
+		ExpiresAt:        time.Now().Add(time.Duration(s.cfg.UploadSessionTTL) * time.Hour),
+	}
+	url, header, err := presigner.PresignPut(upload.ObjectKey, upload.Size, upload.SHA256, upload.ExpiresAt)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error presigning upload: %w", err)
+	}
+	if err := s.db.Create(upload).Error; err != nil {
+		return nil, "", nil, fmt.Errorf("error creating direct upload: %w", err)
+	}
+	return upload, url, header, nil
+}
+
+// Get returns an owner's unexpired upload
+func (s *DirectUploadService) Get(ownerID, uploadID uuid.UUID) (*models.DirectUpload, error) {
+	var upload models.DirectUpload
+	err := s.db.Where("id = ? AND owner_id = ? AND expires_at > ?", uploadID, ownerID, time.Now()).
+		First(&upload).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDirectUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching direct upload: %w", err)
+	}
+	return &upload, nil
+}
+
+// Inspect checks the uploaded content against the declared size and hash and
+// returns it described like a staged upload, with its first headLength bytes
+// for MIME sniffing. Without spool nothing is written locally and Path is
+// empty; the hash the store keeps is trusted when it has one, and otherwise
+// the content is read through once to hash it. With spool the content is
+// copied to a staged file for the scanners that read from disk.
+func (s *DirectUploadService) Inspect(ctx context.Context, upload *models.DirectUpload, headLength int, spool bool) (*utils.StagedFile, error) {
+	store, err := OpenBlobStore(s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	info, err := store.Stat(ctx, upload.ObjectKey)
+	if errors.Is(err, storage.ErrNotExist) {
+		return nil, ErrDirectUploadIncomplete
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error checking uploaded content: %w", err)
+	}
+	if info.Size != upload.Size || (info.SHA256 != "" && info.SHA256 != upload.SHA256) {
+		return nil, ErrDirectUploadMismatch
+	}
+
+	staged := &utils.StagedFile{Size: info.Size, Hash: info.SHA256}
+	if info.SHA256 == "" || spool {
+		body, err := store.Get(ctx, upload.ObjectKey, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error reading uploaded content: %w", err)
+		}
+		defer body.Close()
+		if spool {
+			staged, err = utils.StageReader(s.cfg.UploadTempDir, body, headLength)
+		} else {
+			staged.Head, staged.Hash, err = hashObject(body, headLength)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading uploaded content: %w", err)
+		}
+		if staged.Hash != upload.SHA256 {
+			os.Remove(staged.Path)
+			return nil, ErrDirectUploadMismatch
+		}
+		return staged, nil
+	}
+
+	if upload.Size > 0 && headLength > 0 {
+		body, err := store.Get(ctx, upload.ObjectKey, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error reading uploaded content: %w", err)
+		}
+		defer body.Close()
+		staged.Head = make([]byte, min(int64(headLength), upload.Size))
+		if _, err := io.ReadFull(body, staged.Head); err != nil {
+			return nil, fmt.Errorf("error reading uploaded content: %w", err)
+		}
+	}
+	return staged, nil
+}
+
+// hashObject reads r to its end and returns its first headLength bytes and
+// its SHA-256
+func hashObject(r io.Reader, headLength int) ([]byte, string, error) {
+	hasher := sha256.New()
+	head := make([]byte, headLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, "", err
+	}
+	head = head[:n]
+	hasher.Write(head)
+	if _, err := io.Copy(hasher, r); err != nil {
+		return nil, "", err
+	}
+	return head, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Delete discards an upload and its content
+func (s *DirectUploadService) Delete(upload *models.DirectUpload) error {
+	if err := s.db.Delete(&models.DirectUpload{}, "id = ?", upload.ID).Error; err != nil {
+		return fmt.Errorf("error deleting direct upload: %w", err)
+	}
+	if !s.cfg.IsObjectStorage() {
+		return nil
+	}
+	store, err := OpenBlobStore(s.cfg)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(context.Background(), upload.ObjectKey); err != nil {
+		return fmt.Errorf("error removing uploaded content: %w", err)
+	}
+	return nil
+}
+
+// Start discards expired uploads in the background
+func (s *DirectUploadService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if removed, err := s.DeleteExpired(); err != nil {
+				log.Printf("direct upload cleanup failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("direct upload cleanup: discarded %d expired upload(s)", removed)
+			}
+		}
+	}()
+}
+
+// DeleteExpired discards uploads past their expiry and returns how many
+func (s *DirectUploadService) DeleteExpired() (int, error) {
+	var uploads []models.DirectUpload
+	if err := s.db.Select("id", "object_key").Where("expires_at <= ?", time.Now()).Find(&uploads).Error; err != nil {
+		return 0, fmt.Errorf("error fetching expired direct uploads: %w", err)
+	}
+	for i := range uploads {
+		if err := s.Delete(&uploads[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(uploads), nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/dlp"
+)
+
+// DLPAction is what happens to an upload when sensitive content is found
+type DLPAction string
+
+const (
+	DLPActionWarn       DLPAction = "warn"       // accept the upload and return a warning
+	DLPActionQuarantine DLPAction = "quarantine" // accept the upload but lock it pending review
+	DLPActionBlock      DLPAction = "block"      // reject the upload
+)
+
+// DLPService inspects uploads for sensitive content
+type DLPService struct {
+	cfg          *config.Config
+	auditService *AuditService
+	scanner      dlp.Scanner
+}
+
+// NewDLPService creates a DLP service with the pattern rules and optional
+// external scanner from the configuration
+func NewDLPService(cfg *config.Config, auditService *AuditService) *DLPService {
+	var scanners dlp.MultiScanner
+
+	patternScanner, err := dlp.NewPatternScanner(cfg.DLPRules)
+	if err != nil {
+		log.Printf("Pattern DLP scanning disabled: %v", err)
+	} else {
+		scanners = append(scanners, patternScanner)
+	}
+
+	if cfg.DLPExternalURL != "" {
+		timeout := time.Duration(cfg.DLPExternalTimeout) * time.Second
+		scanners = append(scanners, dlp.NewHTTPScanner(cfg.DLPExternalURL, cfg.DLPExternalToken, timeout))
+	}
+
+	return &DLPService{
+		cfg:          cfg,
+		auditService: auditService,
+		scanner:      scanners,
+	}
+}
+
+// Enabled reports whether uploads should be scanned
+func (s *DLPService) Enabled() bool {
+	return s.cfg.EnableDLP
+}
+
+// Action returns the configured response to findings
+func (s *DLPService) Action() DLPAction {
+	return DLPAction(s.cfg.DLPAction)
+}
+
+// ScanFile inspects the start of a file if its type is text-extractable. It
+// returns no findings for other types.
+func (s *DLPService) ScanFile(ctx context.Context, path, mimeType string) ([]dlp.Finding, error) {
+	if !dlp.IsScannable(mimeType) {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for scanning: %w", err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, s.cfg.DLPMaxScanBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file for scanning: %w", err)
+	}
+
+	return s.scanner.Scan(ctx, content, mimeType)
+}
+
+// LogFindings records sensitive content findings in the audit log. fileID is
+// nil when the upload was blocked and no file was created.
+func (s *DLPService) LogFindings(c *gin.Context, userID uuid.UUID, fileID *uuid.UUID, filename string, action DLPAction, findings []dlp.Finding) {
+	if s.auditService == nil {
+		return
+	}
+
+	status := models.AuditStatusSuccess
+	if action == DLPActionBlock {
+		status = models.AuditStatusFailed
+	}
+
+	if err := s.auditService.LogActivityFromGin(c, LogActivityParams{
+		UserID:       userID,
+		Action:       models.AuditActionDLPScan,
+		ResourceType: models.AuditResourceFile,
+		ResourceID:   fileID,
+		ResourceName: &filename,
+		Details: models.AuditLogDetails{
+			"action":    action,
+			"findings":  findings,
+			"timestamp": time.Now().Unix(),
+		},
+		Status: status,
+	}); err != nil {
+		log.Printf("Failed to log DLP findings for %s: %v", filename, err)
+	}
+}
+
+// QuarantineFindings converts scanner findings for storage with a quarantine entry
+func QuarantineFindings(findings []dlp.Finding) models.QuarantineFindings {
+	result := make(models.QuarantineFindings, 0, len(findings))
+	for _, finding := range findings {
+		result = append(result, models.QuarantineFinding{
+			Rule:    finding.Rule,
+			Label:   finding.Label,
+			Count:   finding.Count,
+			Samples: finding.Samples,
+			Source:  finding.Source,
+		})
+	}
+	return result
+}
+
+// DescribeFindings summarises findings for warnings and quarantine reasons
+func DescribeFindings(findings []dlp.Finding) string {
+	description := ""
+	for i, finding := range findings {
+		if i > 0 {
+			description += ", "
+		}
+		label := finding.Label
+		if label == "" {
+			label = finding.Rule
+		}
+		description += fmt.Sprintf("%s (%d)", label, finding.Count)
+	}
+	return "Sensitive content detected: " + description
+}
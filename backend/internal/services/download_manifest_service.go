@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// DownloadManifest describes how a large file splits into byte ranges that
+// can be downloaded in parallel and verified one by one
+type DownloadManifest struct {
+	File      *models.File
+	Hash      *models.FileHash
+	ChunkSize int64
+	Chunks    []models.BlobChunk
+}
+
+// DownloadManifestService builds segmented download manifests for files
+// above the configured size threshold
+type DownloadManifestService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewDownloadManifestService creates a new download manifest service
+func NewDownloadManifestService(db *gorm.DB, cfg *config.Config) *DownloadManifestService {
+	return &DownloadManifestService{db: db, cfg: cfg}
+}
+
+// Threshold returns the size from which files get a manifest
+func (s *DownloadManifestService) Threshold() int64 {
+	return s.cfg.DownloadManifestThreshold
+}
+
+// Applies reports whether a file is large enough to get a manifest
+func (s *DownloadManifestService) Applies(file *models.File) bool {
+	return file.Size >= s.cfg.DownloadManifestThreshold
+}
+
+// Build returns the manifest of an opened file. Chunk checksums are read
+// from the cache, or computed from the blob and cached when the blob has not
+// been split with the current chunk size before.
+func (s *DownloadManifestService) Build(stream *FileStream) (*DownloadManifest, error) {
+	chunkSize := s.cfg.DownloadManifestChunkSize
+	expected := int((stream.Hash.Size + chunkSize - 1) / chunkSize)
+
+	var chunks []models.BlobChunk
+	if err := s.db.Where("file_hash_id = ? AND chunk_size = ?", stream.Hash.ID, chunkSize).
+		Order("chunk_index").Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("error fetching blob chunks: %w", err)
+	}
+
+	if len(chunks) != expected {
+		computed, err := hashChunks(stream, chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		// Concurrent requests may compute the same checksums; either copy will do
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(computed, 500).Error; err != nil {
+			return nil, fmt.Errorf("error caching blob chunks: %w", err)
+		}
+		chunks = computed
+	}
+
+	return &DownloadManifest{
+		File:      stream.File,
+		Hash:      stream.Hash,
+		ChunkSize: chunkSize,
+		Chunks:    chunks,
+	}, nil
+}
+
+// hashChunks reads a blob once and checksums each chunkSize segment
+func hashChunks(stream *FileStream, chunkSize int64) ([]models.BlobChunk, error) {
+	f, err := stream.Reader(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error opening blob: %w", err)
+	}
+	defer f.Close()
+
+	var chunks []models.BlobChunk
+	for offset := int64(0); offset < stream.Hash.Size; offset += chunkSize {
+		h := sha256.New()
+		n, err := io.CopyN(h, f, chunkSize)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading blob: %w", err)
+		}
+		if n < chunkSize && offset+n != stream.Hash.Size {
+			return nil, fmt.Errorf("error reading blob: %d bytes stored, %d expected", offset+n, stream.Hash.Size)
+		}
+		chunks = append(chunks, models.BlobChunk{
+			FileHashID: stream.Hash.ID,
+			ChunkSize:  chunkSize,
+			ChunkIndex: len(chunks),
+			Offset:     offset,
+			Length:     n,
+			SHA256:     hex.EncodeToString(h.Sum(nil)),
+		})
+	}
+	return chunks, nil
+}
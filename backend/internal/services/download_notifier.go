@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/events"
+	"file-vault-system/backend/internal/models"
+)
+
+// downloadNotifierBuffer is how many download events may wait for the
+// notifier before further ones are dropped
+const downloadNotifierBuffer = 256
+
+// DownloadNotifier tells owners about downloads of the files and share links
+// they asked to watch. It follows download events on the event bus and sends
+// an in-app notification, and an email when SMTP is configured.
+type DownloadNotifier struct {
+	db                  *gorm.DB
+	notificationService *NotificationService
+	mailService         *MailService
+	linkService         *LinkService
+}
+
+// NewDownloadNotifier creates a new download notifier
+func NewDownloadNotifier(db *gorm.DB, notificationService *NotificationService, mailService *MailService, linkService *LinkService) *DownloadNotifier {
+	return &DownloadNotifier{
+		db:                  db,
+		notificationService: notificationService,
+		mailService:         mailService,
+		linkService:         linkService,
+	}
+}
+
+// Start follows download events on the bus in the background
+func (n *DownloadNotifier) Start(bus *events.Bus) {
+	sub := bus.Subscribe(events.Filter{Types: []events.Type{events.TypeDownload}}, downloadNotifierBuffer)
+	go func() {
+		for event := range sub.C {
+			if notify, _ := event.Details["notify_owner"].(bool); !notify {
+				continue
+			}
+			if err := n.notify(event); err != nil {
+				log.Printf("Failed to send download notification: %v", err)
+			}
+		}
+	}()
+}
+
+// notify tells the owner of the downloaded file who downloaded it
+func (n *DownloadNotifier) notify(event events.Event) error {
+	ownerID, _ := event.Details["owner_id"].(uuid.UUID)
+	filename, _ := event.Details["filename"].(string)
+	country, _ := event.Details["country"].(string)
+
+	var owner models.User
+	if err := n.db.Select("id", "email").First(&owner, "id = ?", ownerID).Error; err != nil {
+		return fmt.Errorf("error finding file owner: %w", err)
+	}
+
+	details := models.NotificationDetails{
+		"file_id":       event.Details["file_id"],
+		"filename":      filename,
+		"downloaded_at": event.Timestamp,
+	}
+	if shareLinkID, ok := event.Details["share_link_id"]; ok {
+		details["share_link_id"] = shareLinkID
+	}
+
+	// Signed-in downloaders are named; anonymous ones are described by where
+	// the request came from
+	var downloader string
+	if event.UserID != nil {
+		var user models.User
+		if err := n.db.Select("id", "username", "email").First(&user, "id = ?", *event.UserID).Error; err != nil {
+			return fmt.Errorf("error finding downloader: %w", err)
+		}
+		downloader = fmt.Sprintf("%s (%s)", user.Username, user.Email)
+		details["downloaded_by"] = user.ID
+		details["username"] = user.Username
+		details["email"] = user.Email
+	} else {
+		downloader = "An anonymous user"
+		if event.IPAddress != "" {
+			downloader += " at " + event.IPAddress
+			details["ip_address"] = event.IPAddress
+		}
+		if country != "" {
+			downloader += " (" + country + ")"
+			details["country"] = country
+		}
+	}
+
+	via := ""
+	if _, ok := details["share_link_id"]; ok {
+		via = " through a share link"
+	}
+	message := fmt.Sprintf("%s downloaded %s%s.", downloader, filename, via)
+
+	if err := n.notificationService.Notify(owner.ID, NotifyParams{
+		Type:     models.NotificationDownload,
+		Severity: models.NotificationSeverityInfo,
+		Title:    "File downloaded",
+		Message:  message,
+		Details:  details,
+	}); err != nil {
+		return fmt.Errorf("error creating notification: %w", err)
+	}
+
+	if n.mailService.Enabled() && owner.Email != "" && n.notificationService.WantsEmail(owner.ID, models.NotificationDownload) {
+		email := DownloadAlertEmail{
+			Filename: filename,
+			Message:  message,
+			Time:     event.Timestamp.UTC().Format(time.RFC1123),
+		}
+		if shareLinkID, ok := details["share_link_id"]; ok {
+			var link models.ShareLink
+			if err := n.db.Select("created_by", "share_token").First(&link, "id = ?", shareLinkID).Error; err == nil {
+				email.ShareLinkURL = n.linkService.ShareLinkURL(link.CreatedBy, link.ShareToken)
+			}
+		}
+		if err := n.mailService.Send(owner.Email, EmailDownloadAlert, email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/email
+var emailTemplateFiles embed.FS
+
+// Outgoing email templates. Each has a <name>.txt file with the plain-text
+// body and a "subject" block, and a <name>.html file defining the "content"
+// placed in layout.html. EMAIL_TEMPLATES_DIR may hold replacements for any
+// of these files.
+const (
+	EmailShareReceived    = "share_received"
+	EmailQuotaWarning     = "quota_warning"
+	EmailDownloadAlert    = "download_alert"
+	EmailNewLogin         = "new_login"
+	EmailAccountLifecycle = "account_lifecycle"
+)
+
+var emailTemplateNames = []string{
+	EmailShareReceived,
+	EmailQuotaWarning,
+	EmailDownloadAlert,
+	EmailNewLogin,
+	EmailAccountLifecycle,
+}
+
+// ShareReceivedEmail is the data of the share_received template
+type ShareReceivedEmail struct {
+	Username   string // recipient
+	SharedBy   string // username of the sharer
+	ItemKind   string // "file" or "folder"
+	ItemName   string
+	Permission string
+	Note       string // message left by the sharer
+	ExpiresAt  string // empty when the share does not expire
+	SignInURL  string // PUBLIC_BASE_URL, empty when not configured
+}
+
+// QuotaWarningEmail is the data of the quota_warning template
+type QuotaWarningEmail struct {
+	Username string
+	Title    string
+	Message  string
+}
+
+// DownloadAlertEmail is the data of the download_alert template
+type DownloadAlertEmail struct {
+	Filename     string
+	Message      string
+	Time         string
+	ShareLinkURL string // set for downloads through a share link
+}
+
+// NewLoginEmail is the data of the new_login template
+type NewLoginEmail struct {
+	Message   string
+	Time      string
+	UserAgent string
+}
+
+// AccountLifecycleEmail is the data of the account_lifecycle template
+type AccountLifecycleEmail struct {
+	Username string
+	Title    string
+	Message  string
+}
+
+// emailTemplate renders one kind of email
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// loadEmailTemplates parses the built-in templates, using the copies of their
+// files found in dir instead. A template whose replacement does not parse
+// keeps the built-in version
+func loadEmailTemplates(dir string) map[string]*emailTemplate {
+	templates := make(map[string]*emailTemplate, len(emailTemplateNames))
+	for _, name := range emailTemplateNames {
+		tmpl, err := parseEmailTemplate(name, dir)
+		if err != nil && dir != "" {
+			log.Printf("Failed to load email template %s from %s, using the built-in one: %v", name, dir, err)
+			tmpl, err = parseEmailTemplate(name, "")
+		}
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in email template %s: %v", name, err))
+		}
+		templates[name] = tmpl
+	}
+	return templates
+}
+
+func parseEmailTemplate(name, dir string) (*emailTemplate, error) {
+	text, err := readEmailTemplate(dir, name+".txt")
+	if err != nil {
+		return nil, err
+	}
+	layout, err := readEmailTemplate(dir, "layout.html")
+	if err != nil {
+		return nil, err
+	}
+	content, err := readEmailTemplate(dir, name+".html")
+	if err != nil {
+		return nil, err
+	}
+
+	textTemplate, err := texttemplate.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s.txt: %w", name, err)
+	}
+	if textTemplate.Lookup("subject") == nil {
+		return nil, fmt.Errorf("%s.txt has no subject block", name)
+	}
+
+	htmlTemplate, err := htmltemplate.New("layout").Parse(layout)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing layout.html: %w", err)
+	}
+	if _, err := htmlTemplate.Parse(content); err != nil {
+		return nil, fmt.Errorf("error parsing %s.html: %w", name, err)
+	}
+	if htmlTemplate.Lookup("content") == nil {
+		return nil, fmt.Errorf("%s.html has no content block", name)
+	}
+
+	return &emailTemplate{text: textTemplate, html: htmlTemplate}, nil
+}
+
+// readEmailTemplate returns a template file from dir, or the built-in one
+// when dir is empty or lacks the file
+func readEmailTemplate(dir, file string) (string, error) {
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("error reading %s: %w", file, err)
+		}
+	}
+
+	data, err := emailTemplateFiles.ReadFile("templates/email/" + file)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", file, err)
+	}
+	return string(data), nil
+}
+
+// render returns the email's subject on one line, its plain-text body and its
+// HTML body
+func (t *emailTemplate) render(data interface{}) (subject, text, html string, err error) {
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", fmt.Errorf("error rendering subject: %w", err)
+	}
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	if err := t.text.Execute(&buf, data); err != nil {
+		return "", "", "", fmt.Errorf("error rendering text body: %w", err)
+	}
+	text = strings.TrimSpace(buf.String()) + "\n"
+
+	buf.Reset()
+	if err := t.html.Execute(&buf, data); err != nil {
+		return "", "", "", fmt.Errorf("error rendering HTML body: %w", err)
+	}
+	return subject, text, buf.String(), nil
+}
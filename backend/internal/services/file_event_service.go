@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+)
+
+// ErrInvalidChangeCursor is returned when a change feed cursor cannot be parsed
+var ErrInvalidChangeCursor = errors.New("invalid change cursor")
+
+// FileEventParams describes a change to a file
+type FileEventParams struct {
+	FileID  uuid.UUID
+	OwnerID uuid.UUID
+	ActorID *uuid.UUID // nil for changes made by the system
+	Type    models.FileEventType
+	Payload models.FileEventPayload
+}
+
+// RecordFileEvent appends an event to a file's history. It must be called in
+// the transaction that makes the change, so the event is stored if and only
+// if the change is.
+func RecordFileEvent(tx *gorm.DB, params FileEventParams) error {
+	event := models.FileEvent{
+		FileID:  params.FileID,
+		OwnerID: params.OwnerID,
+		ActorID: params.ActorID,
+		Type:    params.Type,
+		Payload: params.Payload,
+	}
+	if err := tx.Omit("Actor").Create(&event).Error; err != nil {
+		return fmt.Errorf("error recording file event: %w", err)
+	}
+	return nil
+}
+
+// FileChanges is a page of the change feed
+type FileChanges struct {
+	Events  []models.FileEvent
+	Cursor  string // pass back to continue after the last event
+	HasMore bool
+}
+
+// FileEventService reads file histories and the change feed
+type FileEventService struct {
+	db *gorm.DB
+}
+
+// NewFileEventService creates a new file event service
+func NewFileEventService(db *gorm.DB) *FileEventService {
+	return &FileEventService{db: db}
+}
+
+// History returns a file's events, newest first. When before is positive only
+// events with a lower sequence are returned, for paging back through history.
+func (s *FileEventService) History(ctx context.Context, fileID uuid.UUID, before int64, limit int) ([]models.FileEvent, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	query := s.db.WithContext(ctx).
+		Preload("Actor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "username", "email", "first_name", "last_name")
+		}).
+		Where("file_id = ?", fileID)
+	if before > 0 {
+		query = query.Where("sequence < ?", before)
+	}
+
+	var events []models.FileEvent
+	if err := query.Order("sequence DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("error fetching file history: %w", err)
+	}
+	return events, nil
+}
+
+// Changes returns events on the owner's files after the cursor, oldest first.
+// An empty cursor starts from the beginning. Only events of transactions that
+// finished before every transaction still running are returned, so events
+// that commit later always sort after the returned cursor.
+func (s *FileEventService) Changes(ctx context.Context, ownerID uuid.UUID, cursor string, limit int) (*FileChanges, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	txID, sequence, err := parseChangeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Where("owner_id = ?", ownerID)
+	if database.IsSQLite(s.db) {
+		// SQLite runs one write transaction at a time, so events commit in
+		// sequence order and tx_id is always zero
+		query = query.Where("sequence > ?", sequence).Order("sequence ASC")
+	} else {
+		query = query.
+			Where("(tx_id, sequence) > (?::text::xid8, ?)", txID, sequence).
+			Where("tx_id < pg_snapshot_xmin(pg_current_snapshot())").
+			Order("tx_id ASC, sequence ASC")
+	}
+
+	var events []models.FileEvent
+	if err := query.Limit(limit + 1).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("error fetching file changes: %w", err)
+	}
+
+	changes := &FileChanges{Cursor: cursor}
+	if len(events) > limit {
+		events = events[:limit]
+		changes.HasMore = true
+	}
+	if len(events) > 0 {
+		last := events[len(events)-1]
+		changes.Cursor = fmt.Sprintf("%d-%d", last.TxID, last.Sequence)
+	}
+	changes.Events = events
+	return changes, nil
+}
+
+// parseChangeCursor splits a cursor of the form "<tx_id>-<sequence>"
+func parseChangeCursor(cursor string) (int64, int64, error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+
+	txPart, seqPart, ok := strings.Cut(cursor, "-")
+	if !ok {
+		return 0, 0, ErrInvalidChangeCursor
+	}
+	txID, err := strconv.ParseInt(txPart, 10, 64)
+	if err != nil || txID < 0 {
+		return 0, 0, ErrInvalidChangeCursor
+	}
+	sequence, err := strconv.ParseInt(seqPart, 10, 64)
+	if err != nil || sequence < 0 {
+		return 0, 0, ErrInvalidChangeCursor
+	}
+	return txID, sequence, nil
+}
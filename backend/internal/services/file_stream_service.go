@@ -0,0 +1,427 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/preview"
+	"file-vault-system/backend/pkg/storage"
+	"file-vault-system/backend/pkg/watermark"
+)
+
+var (
+	// ErrBlobMissing is returned when a file's content is in none of the stores
+	ErrBlobMissing = errors.New("file content not found in storage")
+	// ErrWatermarkTooLarge is returned when content is too large to be watermarked
+	ErrWatermarkTooLarge = apperrors.ErrConflict.WithCode("WATERMARK_TOO_LARGE", "file is too large to be watermarked")
+	// ErrWatermarkFailed is returned when content cannot be watermarked, such as a damaged or password-protected PDF
+	ErrWatermarkFailed = apperrors.ErrConflict.WithCode("WATERMARK_FAILED", "file could not be watermarked")
+	// ErrPreviewUnsupported is returned when content cannot be rendered as pages
+	ErrPreviewUnsupported = apperrors.ErrConflict.WithCode("PREVIEW_UNSUPPORTED", "only PDFs and PNG, JPEG and GIF images can be rendered")
+	// ErrPreviewFailed is returned when rendering fails, such as for a damaged PDF
+	ErrPreviewFailed = apperrors.ErrConflict.WithCode("PREVIEW_FAILED", "file could not be rendered")
+	// ErrPageNotFound is returned for pages past the end of the content
+	ErrPageNotFound = apperrors.ErrNotFound.WithCode("PAGE_NOT_FOUND", "page not found")
+	// ErrFileNotScanned is returned in strict mode for files that have not been scanned clean yet
+	ErrFileNotScanned = apperrors.ErrConflict.WithCode("FILE_NOT_SCANNED", "file has not been scanned for malware yet")
+)
+
+// BlobArchivedError is returned when a file's content lives in cold storage
+// and must be restored before it can be read
+type BlobArchivedError struct {
+	Tier models.StorageTier
+}
+
+func (e *BlobArchivedError) Error() string {
+	return fmt.Sprintf("file content is archived in %s storage", e.Tier)
+}
+
+// StreamDisposition says whether a browser should show streamed content or
+// save it
+type StreamDisposition string
+
+const (
+	StreamInline     StreamDisposition = "inline"
+	StreamAttachment StreamDisposition = "attachment"
+)
+
+// FileStream is a file whose content has been located, on disk at Path or,
+// with object storage, under Key in the bucket
+type FileStream struct {
+	File  *models.File
+	Hash  *models.FileHash
+	Path  string
+	Key   string
+	store storage.Storage
+}
+
+// Reader opens the content wherever it is stored. Object-stored content is
+// fetched as it is read, from wherever the reader seeks to.
+func (f *FileStream) Reader(ctx context.Context) (io.ReadSeekCloser, error) {
+	if f.Path != "" {
+		return os.Open(f.Path)
+	}
+	return storage.NewReadSeeker(ctx, f.store, f.Key, f.Hash.Size), nil
+}
+
+// FileStreamService locates the stored content of files and streams it.
+// Every endpoint that serves content goes through it, so owners, share
+// recipients, public links and admins all get the same storage fallbacks and
+// headers; callers only decide who may read the file.
+type FileStreamService struct {
+	db         *gorm.DB
+	cfg        *config.Config
+	reads      *blobReadGate
+	renderer   *preview.Renderer
+	thumbnails *preview.Renderer
+}
+
+// NewFileStreamService creates a new file stream service
+func NewFileStreamService(db *gorm.DB, cfg *config.Config) *FileStreamService {
+	return &FileStreamService{
+		db:         db,
+		cfg:        cfg,
+		reads:      readGate(cfg),
+		renderer:   preview.NewRenderer(cfg.PdftoppmPath, cfg.SharePreviewSize),
+		thumbnails: preview.NewRenderer(cfg.PdftoppmPath, cfg.ThumbnailSize),
+	}
+}
+
+// Open locates a file's content, on disk or in the bucket. It fails with a
+// *BlobArchivedError while the content is in cold storage and with
+// ErrBlobMissing when it is found nowhere. The file's FileHash is used when
+// preloaded.
+func (s *FileStreamService) Open(file *models.File) (*FileStream, error) {
+	fileHash := file.FileHash
+	if fileHash == nil {
+		fileHash = &models.FileHash{}
+		if err := s.db.First(fileHash, "id = ?", file.FileHashID).Error; err != nil {
+			return nil, fmt.Errorf("error fetching file hash: %w", err)
+		}
+	}
+
+	if fileHash.StorageTier != "" && fileHash.StorageTier != models.StorageTierHot {
+		return nil, &BlobArchivedError{Tier: fileHash.StorageTier}
+	}
+
+	if s.cfg.IsObjectStorage() {
+		store, err := OpenBlobStore(s.cfg)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := store.Stat(context.Background(), fileHash.StoragePath); err != nil {
+			if errors.Is(err, storage.ErrNotExist) {
+				return nil, fmt.Errorf("%w: file %s, hash %s at %s", ErrBlobMissing, file.ID, fileHash.ID, fileHash.StoragePath)
+			}
+			return nil, fmt.Errorf("error locating blob: %w", err)
+		}
+		return &FileStream{File: file, Hash: fileHash, Key: fileHash.StoragePath, store: store}, nil
+	}
+
+	path, found := s.ResolvePath(fileHash.StoragePath, file.ID)
+	if !found {
+		return nil, fmt.Errorf("%w: file %s, hash %s at %s", ErrBlobMissing, file.ID, fileHash.ID, fileHash.StoragePath)
+	}
+	return &FileStream{File: file, Hash: fileHash, Path: path}, nil
+}
+
+// CheckScanned fails with ErrFileNotScanned when MALWARE_STRICT_DOWNLOADS is
+// set and the file is still waiting for a scan, or its last scan failed.
+// Quarantined files are left to the quarantine check.
+func (s *FileStreamService) CheckScanned(file *models.File) error {
+	if !s.cfg.MalwareStrictDownloads {
+		return nil
+	}
+	switch file.ProcessingStatus {
+	case models.ProcessingStatusPendingScan, models.ProcessingStatusFailed:
+		return ErrFileNotScanned
+	}
+	return nil
+}
+
+// ResolvePath locates a blob on disk. The primary store is tried first, at
+// the stored path and then in the other storage/{hash} layout in case the
+// blob was moved mid-migration, then the legacy layout named after the file
+// ID, and finally the replica so reads keep working while the primary is
+// unavailable.
+func (s *FileStreamService) ResolvePath(storagePath string, fileID uuid.UUID) (string, bool) {
+	var candidates []string
+	for _, p := range blobPathCandidates(storagePath) {
+		candidates = append(candidates, filepath.Join(s.cfg.StoragePath, p))
+	}
+	candidates = append(candidates, filepath.Join(s.cfg.StoragePath, fileID.String()))
+	if s.cfg.ReplicaStoragePath != "" {
+		for _, p := range blobPathCandidates(storagePath) {
+			candidates = append(candidates, filepath.Join(s.cfg.ReplicaStoragePath, p))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+// Serve writes the content with the file's own MIME type and name. Inline
+// content may be cached for an hour; attachments are not cached. Small blobs
+// are served from memory once read, and disk reads of one blob are limited
+// so a popular file answers 503 instead of saturating the disk.
+func (s *FileStreamService) Serve(c *gin.Context, stream *FileStream, disposition StreamDisposition) {
+	cacheable := s.reads.cache.accepts(stream.Hash.Size)
+	if cacheable {
+		if data, ok := s.reads.cache.get(stream.Hash.Hash); ok {
+			s.writeHeaders(c, stream, disposition)
+			http.ServeContent(c.Writer, c.Request, stream.File.OriginalFilename, stream.Hash.CreatedAt, bytes.NewReader(data))
+			return
+		}
+	}
+
+	release, err := s.reads.acquire(c.Request.Context(), stream.Hash.Hash)
+	if err != nil {
+		respondBlobBusy(c, err)
+		return
+	}
+	defer release()
+
+	s.writeHeaders(c, stream, disposition)
+	if cacheable {
+		if data, err := s.readAll(c.Request.Context(), stream); err == nil {
+			s.reads.cache.put(stream.Hash.Hash, data)
+			http.ServeContent(c.Writer, c.Request, stream.File.OriginalFilename, stream.Hash.CreatedAt, bytes.NewReader(data))
+			return
+		}
+	}
+	if stream.Path != "" {
+		c.File(stream.Path)
+		return
+	}
+	content, err := stream.Reader(c.Request.Context())
+	if err != nil {
+		c.Error(apperrors.Internal(fmt.Errorf("error reading blob: %w", err)))
+		return
+	}
+	defer content.Close()
+	http.ServeContent(c.Writer, c.Request, stream.File.OriginalFilename, stream.Hash.CreatedAt, content)
+}
+
+// readAll reads the whole content into memory
+func (s *FileStreamService) readAll(ctx context.Context, stream *FileStream) ([]byte, error) {
+	content, err := stream.Reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(content)
+}
+
+// LocalPath returns a path the content can be read from on disk, for tools
+// that only read files. Object-stored content is copied to a temp file,
+// which the returned func removes.
+func (s *FileStreamService) LocalPath(ctx context.Context, stream *FileStream) (string, func(), error) {
+	if stream.Path != "" {
+		return stream.Path, func() {}, nil
+	}
+	content, err := stream.Reader(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	defer content.Close()
+	path, err := spoolBlob(ctx, s.cfg, content)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, func() { os.Remove(path) }, nil
+}
+
+// ServeWatermarked writes the content with the lines stamped onto it. The
+// stamped copy is made for every request and never cached, by the server or
+// the browser. Content that cannot be stamped is refused rather than served
+// without its watermark.
+func (s *FileStreamService) ServeWatermarked(c *gin.Context, stream *FileStream, disposition StreamDisposition, lines []string) {
+	if stream.Hash.Size > s.cfg.WatermarkMaxFileSize {
+		c.Error(ErrWatermarkTooLarge)
+		return
+	}
+
+	var data []byte
+	var cached bool
+	if s.reads.cache.accepts(stream.Hash.Size) {
+		data, cached = s.reads.cache.get(stream.Hash.Hash)
+	}
+	if !cached {
+		release, err := s.reads.acquire(c.Request.Context(), stream.Hash.Hash)
+		if err != nil {
+			respondBlobBusy(c, err)
+			return
+		}
+		data, err = s.readAll(c.Request.Context(), stream)
+		release()
+		if err != nil {
+			c.Error(apperrors.Internal(fmt.Errorf("error reading blob: %w", err)))
+			return
+		}
+	}
+
+	var stamped bytes.Buffer
+	mimeType, err := watermark.Apply(&stamped, data, stream.File.MimeType, lines)
+	if err != nil {
+		if errors.Is(err, watermark.ErrTooLarge) {
+			c.Error(ErrWatermarkTooLarge.Wrap(err))
+		} else {
+			c.Error(ErrWatermarkFailed.Wrap(err))
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, stream.File.OriginalFilename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, mimeType, stamped.Bytes())
+}
+
+// ServeRendered writes a page of the content rendered as an image, counted
+// from 1, so it can be looked at without handing out the original file. The
+// page is stamped with the lines when there are any, and is never cached.
+// Rendering takes a read slot of the blob like any other read.
+func (s *FileStreamService) ServeRendered(c *gin.Context, stream *FileStream, page int, lines []string) {
+	if !preview.Supports(stream.File.MimeType) {
+		c.Error(ErrPreviewUnsupported)
+		return
+	}
+
+	release, err := s.reads.acquire(c.Request.Context(), stream.Hash.Hash)
+	if err != nil {
+		respondBlobBusy(c, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(s.cfg.SharePreviewTimeout)*time.Second)
+	data, mimeType, err := s.render(ctx, s.renderer, stream, page)
+	cancel()
+	release()
+	if err != nil {
+		switch {
+		case errors.Is(err, preview.ErrPageNotFound):
+			c.Error(ErrPageNotFound)
+		case errors.Is(err, context.DeadlineExceeded):
+			c.Error(apperrors.ErrTimeout.Wrap(err))
+		default:
+			c.Error(ErrPreviewFailed.Wrap(err))
+		}
+		return
+	}
+
+	if len(lines) > 0 {
+		var stamped bytes.Buffer
+		if mimeType, err = watermark.Apply(&stamped, data, mimeType, lines); err != nil {
+			c.Error(ErrWatermarkFailed.Wrap(err))
+			return
+		}
+		data = stamped.Bytes()
+	}
+
+	c.Header("Content-Disposition", "inline")
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, mimeType, data)
+}
+
+// ServeThumbnail writes the first page of the content rendered at thumbnail
+// size. Browsers may cache it privately for maxAge.
+func (s *FileStreamService) ServeThumbnail(c *gin.Context, stream *FileStream, maxAge time.Duration) {
+	if !preview.Supports(stream.File.MimeType) {
+		c.Error(ErrPreviewUnsupported)
+		return
+	}
+
+	release, err := s.reads.acquire(c.Request.Context(), stream.Hash.Hash)
+	if err != nil {
+		respondBlobBusy(c, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(s.cfg.SharePreviewTimeout)*time.Second)
+	data, mimeType, err := s.render(ctx, s.thumbnails, stream, 1)
+	cancel()
+	release()
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.Error(apperrors.ErrTimeout.Wrap(err))
+		} else {
+			c.Error(ErrPreviewFailed.Wrap(err))
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", "inline")
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	c.Data(http.StatusOK, mimeType, data)
+}
+
+// render renders a page of the content, which the renderer reads from disk
+func (s *FileStreamService) render(ctx context.Context, renderer *preview.Renderer, stream *FileStream, page int) ([]byte, string, error) {
+	path, done, err := s.LocalPath(ctx, stream)
+	if err != nil {
+		return nil, "", err
+	}
+	defer done()
+	return renderer.Render(ctx, path, stream.File.MimeType, page)
+}
+
+// respondBlobBusy answers a request turned away by the read gate. Requests
+// whose client went away get no response.
+func respondBlobBusy(c *gin.Context, err error) {
+	if !errors.Is(err, ErrBlobBusy) {
+		return
+	}
+	c.Header("Retry-After", "1")
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":   "File is busy",
+		"type":    "BLOB_BUSY",
+		"message": "Too many downloads of this file are in progress; retry shortly",
+		"code":    "BLOB_BUSY",
+	})
+}
+
+// Prefetch loads a small blob into the in-memory cache ahead of its first
+// request. Blobs too large for the cache are left alone.
+func (s *FileStreamService) Prefetch(stream *FileStream) error {
+	if !s.reads.cache.accepts(stream.Hash.Size) {
+		return nil
+	}
+	data, err := s.readAll(context.Background(), stream)
+	if err != nil {
+		return fmt.Errorf("error reading blob: %w", err)
+	}
+	s.reads.cache.put(stream.Hash.Hash, data)
+	return nil
+}
+
+// writeHeaders sets the content type, disposition and caching headers
+func (s *FileStreamService) writeHeaders(c *gin.Context, stream *FileStream, disposition StreamDisposition) {
+	mimeType := stream.File.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	c.Header("Content-Type", mimeType)
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, stream.File.OriginalFilename))
+	if disposition == StreamInline {
+		c.Header("Cache-Control", "max-age=3600")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+}
@@ -3,33 +3,68 @@ package services
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
 	"file-vault-system/backend/internal/models"
 )
 
+var (
+	// ErrSharedFolderNotFound is returned when a folder does not exist or is not the user's
+	ErrSharedFolderNotFound = apperrors.ErrNotFound.With("folder not found or access denied")
+	// ErrShareTargetNotFound is returned when the user a folder is shared with does not exist
+	ErrShareTargetNotFound = apperrors.ErrNotFound.With("target user not found")
+	// ErrFolderAlreadyShared is returned when sharing a folder with a user twice
+	ErrFolderAlreadyShared = apperrors.ErrConflict.WithCode("ALREADY_SHARED", "folder already shared with this user")
+	// ErrFolderShareNotFound is returned when a folder share is missing or not the user's
+	ErrFolderShareNotFound = apperrors.ErrNotFound.With("folder share not found or access denied")
+	// ErrFolderShareLinkNotFound is returned when a folder share link is missing or not the user's
+	ErrFolderShareLinkNotFound = apperrors.ErrNotFound.With("folder share link not found or access denied")
+	// ErrFolderShareLinkInvalid is returned for unknown or revoked folder share link tokens
+	ErrFolderShareLinkInvalid = apperrors.ErrNotFound.With("invalid or expired share link")
+	// ErrFolderShareLinkEncrypted is returned when creating a share link to an encrypted folder
+	ErrFolderShareLinkEncrypted = apperrors.ErrConflict.WithCode("FOLDER_ENCRYPTED", "end-to-end encrypted folders cannot be shared by link; share them with users instead")
+)
+
 type FolderSharingService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	tokens shareAccessTokens
+	counts *AccessCountService
+	vaults *VaultService
 }
 
-func NewFolderSharingService(db *gorm.DB) *FolderSharingService {
+func NewFolderSharingService(db *gorm.DB, cfg *config.Config) *FolderSharingService {
 	return &FolderSharingService{
-		db: db,
+		db:     db,
+		tokens: newShareAccessTokens(cfg),
+		counts: NewAccessCountService(db, cfg),
+		vaults: NewVaultService(db, cfg),
 	}
 }
 
-// ShareFolderWithUser shares a folder with another user
-func (s *FolderSharingService) ShareFolderWithUser(folderID, sharedBy, sharedWith uuid.UUID, permission models.SharePermission, message string) (*models.FolderShare, error) {
-	// Check if folder exists and belongs to the user
+// CheckSharable checks that a folder exists and belongs to the user, who
+// may then share it
+func (s *FolderSharingService) CheckSharable(folderID, userID uuid.UUID) error {
 	var folder models.Folder
-	if err := s.db.Where("id = ? AND owner_id = ?", folderID, sharedBy).First(&folder).Error; err != nil {
+	if err := s.db.Select("id").Where("id = ? AND owner_id = ?", folderID, userID).First(&folder).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, errors.New("folder not found or access denied")
+			return ErrSharedFolderNotFound
 		}
+		return err
+	}
+	return nil
+}
+
+// ShareFolderWithUser shares a folder with another user
+func (s *FolderSharingService) ShareFolderWithUser(folderID, sharedBy, sharedWith uuid.UUID, permission models.SharePermission, message string) (*models.FolderShare, error) {
+	// Check if folder exists and belongs to the user
+	if err := s.CheckSharable(folderID, sharedBy); err != nil {
 		return nil, err
 	}
 
@@ -37,7 +72,7 @@ func (s *FolderSharingService) ShareFolderWithUser(folderID, sharedBy, sharedWit
 	var targetUser models.User
 	if err := s.db.Where("id = ?", sharedWith).First(&targetUser).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, errors.New("target user not found")
+			return nil, ErrShareTargetNotFound
 		}
 		return nil, err
 	}
@@ -46,7 +81,7 @@ func (s *FolderSharingService) ShareFolderWithUser(folderID, sharedBy, sharedWit
 	var existingShare models.FolderShare
 	if err := s.db.Where("folder_id = ? AND shared_by = ? AND shared_with = ? AND deleted_at IS NULL",
 		folderID, sharedBy, sharedWith).First(&existingShare).Error; err == nil {
-		return nil, errors.New("folder already shared with this user")
+		return nil, ErrFolderAlreadyShared
 	}
 
 	// Create folder share
@@ -72,15 +107,22 @@ func (s *FolderSharingService) ShareFolderWithUser(folderID, sharedBy, sharedWit
 }
 
 // CreateFolderShareLink creates a shareable link for a folder
-func (s *FolderSharingService) CreateFolderShareLink(folderID, createdBy uuid.UUID, permission models.SharePermission, expiresAt *time.Time, password string, maxDownloads *int) (*models.FolderShareLink, error) {
+func (s *FolderSharingService) CreateFolderShareLink(folderID, createdBy uuid.UUID, permission models.SharePermission, mode models.FolderShareLinkMode, expiresAt *time.Time, password string, maxDownloads *int) (*models.FolderShareLink, error) {
 	// Check if folder exists and belongs to the user
 	var folder models.Folder
 	if err := s.db.Where("id = ? AND owner_id = ?", folderID, createdBy).First(&folder).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, errors.New("folder not found or access denied")
+			return nil, ErrSharedFolderNotFound
 		}
 		return nil, err
 	}
+	if folder.IsEncrypted() {
+		return nil, ErrFolderShareLinkEncrypted
+	}
+
+	if err := CheckPublicSharing(s.db, createdBy); err != nil {
+		return nil, err
+	}
 
 	// Generate unique token
 	token, err := generateSecureToken(32)
@@ -105,6 +147,7 @@ func (s *FolderSharingService) CreateFolderShareLink(folderID, createdBy uuid.UU
 		Token:         token,
 		PasswordHash:  passwordHash,
 		Permission:    permission,
+		Mode:          mode,
 		ExpiresAt:     expiresAt,
 		IsActive:      true,
 		MaxDownloads:  maxDownloads,
@@ -124,11 +167,12 @@ func (s *FolderSharingService) CreateFolderShareLink(folderID, createdBy uuid.UU
 	return &shareLink, nil
 }
 
-// GetSharedFolders returns folders shared with a user
-func (s *FolderSharingService) GetSharedFolders(userID uuid.UUID) ([]models.FolderShare, error) {
+// GetSharedFolders returns folders shared with a user that pass the filter
+func (s *FolderSharingService) GetSharedFolders(userID uuid.UUID, filter ShareListFilter) ([]models.FolderShare, error) {
 	var folderShares []models.FolderShare
 
 	if err := s.db.Where("shared_with = ? AND deleted_at IS NULL", userID).
+		Scopes(filter.scope).
 		Preload("Folder").
 		Preload("SharedByUser").
 		Find(&folderShares).Error; err != nil {
@@ -144,7 +188,7 @@ func (s *FolderSharingService) GetFolderShares(folderID, ownerID uuid.UUID) ([]m
 	var folder models.Folder
 	if err := s.db.Where("id = ? AND owner_id = ?", folderID, ownerID).First(&folder).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, errors.New("folder not found or access denied")
+			return nil, ErrSharedFolderNotFound
 		}
 		return nil, err
 	}
@@ -178,13 +222,17 @@ func (s *FolderSharingService) RevokeFolderShare(shareID, userID uuid.UUID) erro
 	var folderShare models.FolderShare
 	if err := s.db.Where("id = ? AND shared_by = ?", shareID, userID).First(&folderShare).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return errors.New("folder share not found or access denied")
+			return ErrFolderShareNotFound
 		}
 		return err
 	}
 
-	// Soft delete the share
-	return s.db.Model(&folderShare).Update("deleted_at", time.Now()).Error
+	// Soft delete the share, and take back the key of an encrypted folder
+	// once nothing in it stays shared with the user
+	if err := s.db.Model(&folderShare).Update("deleted_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return s.vaults.RevokeUnshared(folderShare.FolderID, folderShare.SharedWith)
 }
 
 // RevokeFolderShareLink deactivates a folder share link
@@ -193,7 +241,7 @@ func (s *FolderSharingService) RevokeFolderShareLink(linkID, userID uuid.UUID) e
 	var shareLink models.FolderShareLink
 	if err := s.db.Where("id = ? AND created_by = ?", linkID, userID).First(&shareLink).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return errors.New("folder share link not found or access denied")
+			return ErrFolderShareLinkNotFound
 		}
 		return err
 	}
@@ -203,7 +251,45 @@ func (s *FolderSharingService) RevokeFolderShareLink(linkID, userID uuid.UUID) e
 }
 
 // AccessFolderByToken validates and returns folder access info from a share token
-func (s *FolderSharingService) AccessFolderByToken(token string, password string) (*models.FolderShareLink, error) {
+func (s *FolderSharingService) AccessFolderByToken(token string, creds ShareLinkCredentials) (*models.FolderShareLink, error) {
+	shareLink, err := s.findFolderShareLink(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check password if required
+	if err := s.tokens.check("folder", shareLink.ID, shareLink.PasswordHash, creds, func(password string) bool {
+		return checkPasswordHash(password, shareLink.PasswordHash)
+	}); err != nil {
+		return nil, err
+	}
+
+	return shareLink, nil
+}
+
+// UnlockFolderShareLink checks the password of a folder share link and
+// issues an access token for the link's later requests
+func (s *FolderSharingService) UnlockFolderShareLink(token string, password string) (*ShareAccess, error) {
+	shareLink, err := s.findFolderShareLink(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if shareLink.PasswordHash != "" {
+		if password == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		if !checkPasswordHash(password, shareLink.PasswordHash) {
+			return nil, ErrSharePasswordInvalid
+		}
+	}
+
+	return s.tokens.issue("folder", shareLink.ID, shareLink.PasswordHash), nil
+}
+
+// findFolderShareLink returns an active folder share link that has neither
+// expired nor run out of downloads
+func (s *FolderSharingService) findFolderShareLink(token string) (*models.FolderShareLink, error) {
 	var shareLink models.FolderShareLink
 
 	if err := s.db.Where("token = ? AND is_active = true AND deleted_at IS NULL", token).
@@ -211,45 +297,58 @@ func (s *FolderSharingService) AccessFolderByToken(token string, password string
 		Preload("CreatedByUser").
 		First(&shareLink).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, errors.New("invalid or expired share link")
+			return nil, ErrFolderShareLinkInvalid
 		}
 		return nil, err
 	}
 
 	// Check if link has expired
 	if shareLink.ExpiresAt != nil && time.Now().After(*shareLink.ExpiresAt) {
-		return nil, errors.New("share link has expired")
-	}
-
-	// Check password if required
-	if shareLink.PasswordHash != "" {
-		if password == "" {
-			return nil, errors.New("password required")
-		}
-		if !checkPasswordHash(password, shareLink.PasswordHash) {
-			return nil, errors.New("invalid password")
-		}
+		return nil, ErrShareLinkExpired
 	}
 
 	// Check download limit
 	if shareLink.MaxDownloads != nil && shareLink.DownloadCount >= *shareLink.MaxDownloads {
-		return nil, errors.New("download limit exceeded")
+		return nil, ErrShareLinkExhausted
 	}
 
 	return &shareLink, nil
 }
 
-// LogFolderShareLinkAccess logs access to a folder share link
-func (s *FolderSharingService) LogFolderShareLinkAccess(linkID uuid.UUID, ipAddress, userAgent, action string) error {
+// LogFolderShareLinkAccess logs access to a folder share link, or only counts
+// it when anonymous access tracking is set to counts. An empty ipAddress is
+// stored as none
+func (s *FolderSharingService) LogFolderShareLinkAccess(shareLink *models.FolderShareLink, ipAddress, userAgent, action string) error {
+	if s.counts.CountsOnly() {
+		return s.counts.Record(models.AccessCountFolder, shareLink.FolderID, action)
+	}
+
 	accessLog := models.FolderShareLinkAccessLog{
-		FolderShareLinkID: linkID,
+		FolderShareLinkID: shareLink.ID,
 		IPAddress:         ipAddress,
 		UserAgent:         userAgent,
 		Action:            action,
 		AccessedAt:        time.Now(),
 	}
 
-	return s.db.Create(&accessLog).Error
+	query := s.db
+	if ipAddress == "" {
+		query = query.Omit("IPAddress")
+	}
+	return query.Create(&accessLog).Error
+}
+
+// RecordFolderShareLinkDownload logs a download through a folder share link
+// and counts it against the link's download limit
+func (s *FolderSharingService) RecordFolderShareLinkDownload(shareLink *models.FolderShareLink, ipAddress, userAgent string) error {
+	// The limit is counted first, so a failed log cannot leave it unenforced
+	if err := s.db.Model(shareLink).Update("download_count", gorm.Expr("download_count + 1")).Error; err != nil {
+		return fmt.Errorf("error updating download count: %w", err)
+	}
+	if err := s.LogFolderShareLinkAccess(shareLink, ipAddress, userAgent, "download"); err != nil {
+		return fmt.Errorf("error recording access log: %w", err)
+	}
+	return nil
 }
 
 // Helper function to generate secure token (copied from file sharing service)
@@ -261,13 +360,43 @@ func generateSecureToken(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-// Helper functions for password hashing (should be shared utility functions)
+// hashPassword hashes a folder share link password with bcrypt, like the
+// passwords of file share links
 func hashPassword(password string) (string, error) {
-	// This is a simple example - in production, use bcrypt
-	return password, nil // TODO: Implement proper password hashing
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return string(hash), nil
 }
 
 func checkPasswordHash(password, hash string) bool {
-	// This is a simple example - in production, use bcrypt
-	return password == hash // TODO: Implement proper password checking
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// HashPlaintextSharePasswords hashes folder share link passwords that earlier
+// versions stored in plaintext, so they keep opening their links, and returns
+// how many it hashed. It runs at startup.
+func HashPlaintextSharePasswords(db *gorm.DB) (int, error) {
+	var links []models.FolderShareLink
+	if err := db.Select("id", "password_hash").Where("password_hash <> ''").Find(&links).Error; err != nil {
+		return 0, fmt.Errorf("error fetching folder share link passwords: %w", err)
+	}
+
+	hashed := 0
+	for _, link := range links {
+		if _, err := bcrypt.Cost([]byte(link.PasswordHash)); err == nil {
+			continue
+		}
+		hash, err := hashPassword(link.PasswordHash)
+		if err != nil {
+			return hashed, err
+		}
+		if err := db.Model(&models.FolderShareLink{}).Where("id = ? AND password_hash = ?", link.ID, link.PasswordHash).
+			UpdateColumn("password_hash", hash).Error; err != nil {
+			return hashed, fmt.Errorf("error hashing folder share link password: %w", err)
+		}
+		hashed++
+	}
+	return hashed, nil
 }
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// ErrInvalidSizeLimit is returned when a folder size limit is negative
+var ErrInvalidSizeLimit = errors.New("size limit must not be negative")
+
+// FolderQuotaError is returned when a change would take a folder, or one of
+// the folders above it, over its size limit
+type FolderQuotaError struct {
+	FolderID   uuid.UUID
+	FolderName string
+	SizeLimit  int64
+	TotalSize  int64 // size of the folder's subtree before the change
+	Additional int64
+}
+
+func (e *FolderQuotaError) Error() string {
+	return fmt.Sprintf("folder %q is limited to %d bytes and already holds %d bytes", e.FolderName, e.SizeLimit, e.TotalSize)
+}
+
+// folderChain selects a folder and all of its ancestors, and the same for a
+// second folder whose chain is left out of limit checks
+const folderChain = `WITH RECURSIVE chain AS (
+	SELECT id, parent_id FROM folders WHERE id = ?
+	UNION ALL
+	SELECT f.id, f.parent_id FROM folders f JOIN chain c ON f.id = c.parent_id
+), unaffected AS (
+	SELECT id, parent_id FROM folders WHERE id = ?
+	UNION ALL
+	SELECT f.id, f.parent_id FROM folders f JOIN unaffected u ON f.id = u.parent_id
+)`
+
+// folderSubtree selects a folder and all of its descendants
+const folderSubtree = `WITH RECURSIVE subtree AS (
+	SELECT id FROM folders WHERE id = ?
+	UNION ALL
+	SELECT f.id FROM folders f JOIN subtree s ON f.parent_id = s.id
+)`
+
+// CheckFolderQuota reports a *FolderQuotaError when adding bytes to the
+// folder would take it or a folder above it over its size limit. Files at
+// the root (nil folder) are only subject to the user's storage quota
+func CheckFolderQuota(db *gorm.DB, folderID *uuid.UUID, bytes int64) error {
+	if folderID == nil || bytes <= 0 {
+		return nil
+	}
+	return checkFolderLimits(db, *folderID, nil, bytes)
+}
+
+// AdjustFolderStats adds files and bytes (negative to remove them) to the
+// totals of a folder and every folder above it and marks them modified. It
+// must be called in the transaction that makes the change. Growth is checked
+// against the size limits after the update, while the updated rows are
+// locked, so concurrent uploads cannot both squeeze under a limit
+func AdjustFolderStats(tx *gorm.DB, folderID *uuid.UUID, files, bytes int64) error {
+	return TransferFolderStats(tx, nil, folderID, files, bytes)
+}
+
+// TransferFolderStats moves files and bytes from the totals of one folder
+// chain to another, as moving content between folders does, and marks both
+// chains modified. Only folders that gain content are checked against their
+// size limits, so content can still be reorganised inside a folder that is
+// already over its limit
+func TransferFolderStats(tx *gorm.DB, fromFolderID, toFolderID *uuid.UUID, files, bytes int64) error {
+	if err := addFolderStats(tx, fromFolderID, -files, -bytes); err != nil {
+		return err
+	}
+	if err := addFolderStats(tx, toFolderID, files, bytes); err != nil {
+		return err
+	}
+
+	if toFolderID == nil || bytes <= 0 {
+		return nil
+	}
+	err := checkFolderLimits(tx, *toFolderID, fromFolderID, 0)
+	var quotaErr *FolderQuotaError
+	if errors.As(err, &quotaErr) {
+		// Report the totals as they were before this change
+		quotaErr.TotalSize -= bytes
+		quotaErr.Additional = bytes
+	}
+	return err
+}
+
+// addFolderStats upserts the totals of a folder and its ancestors
+func addFolderStats(tx *gorm.DB, folderID *uuid.UUID, files, bytes int64) error {
+	if folderID == nil {
+		return nil
+	}
+	// SQLite needs a WHERE clause to tell ON CONFLICT from a join constraint
+	if err := tx.Exec(folderChain+`
+		INSERT INTO folder_stats (folder_id, file_count, total_size, last_modified)
+		SELECT id, ?, ?, ? FROM chain WHERE TRUE
+		ON CONFLICT (folder_id) DO UPDATE SET
+			file_count = folder_stats.file_count + EXCLUDED.file_count,
+			total_size = folder_stats.total_size + EXCLUDED.total_size,
+			last_modified = EXCLUDED.last_modified`,
+		*folderID, uuid.Nil, files, bytes, time.Now()).Error; err != nil {
+		return fmt.Errorf("error updating folder stats: %w", err)
+	}
+	return nil
+}
+
+// addChildFolders changes the subfolder count of a folder
+func addChildFolders(tx *gorm.DB, folderID *uuid.UUID, delta int64) error {
+	if folderID == nil {
+		return nil
+	}
+	if err := tx.Exec(`
+		INSERT INTO folder_stats (folder_id, child_count, last_modified)
+		VALUES (?, ?, ?)
+		ON CONFLICT (folder_id) DO UPDATE SET
+			child_count = folder_stats.child_count + EXCLUDED.child_count,
+			last_modified = EXCLUDED.last_modified`,
+		*folderID, delta, time.Now()).Error; err != nil {
+		return fmt.Errorf("error updating folder stats: %w", err)
+	}
+	return nil
+}
+
+// checkFolderLimits looks for a folder above folderID, and not above
+// exceptID, whose total plus additional bytes is over its size limit
+func checkFolderLimits(db *gorm.DB, folderID uuid.UUID, exceptID *uuid.UUID, additional int64) error {
+	except := uuid.Nil
+	if exceptID != nil {
+		except = *exceptID
+	}
+
+	var exceeded struct {
+		ID        uuid.UUID
+		Name      string
+		SizeLimit int64
+		TotalSize int64
+	}
+	result := db.Raw(folderChain+`
+		SELECT f.id, f.name, f.size_limit, COALESCE(s.total_size, 0) AS total_size
+		FROM chain
+		JOIN folders f ON f.id = chain.id
+		LEFT JOIN folder_stats s ON s.folder_id = f.id
+		WHERE f.size_limit IS NOT NULL AND COALESCE(s.total_size, 0) + ? > f.size_limit
+			AND f.id NOT IN (SELECT id FROM unaffected)
+		LIMIT 1`, folderID, except, additional).Scan(&exceeded)
+	if result.Error != nil {
+		return fmt.Errorf("error checking folder size limits: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	return &FolderQuotaError{
+		FolderID:   exceeded.ID,
+		FolderName: exceeded.Name,
+		SizeLimit:  exceeded.SizeLimit,
+		TotalSize:  exceeded.TotalSize,
+		Additional: additional,
+	}
+}
+
+// GetFolderStats returns the totals of a folder's subtree, zero when nothing
+// has been stored in it yet
+func GetFolderStats(db *gorm.DB, folderID uuid.UUID) (models.FolderStats, error) {
+	stats := models.FolderStats{FolderID: folderID}
+	err := db.Where("folder_id = ?", folderID).First(&stats).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return stats, fmt.Errorf("error fetching folder stats: %w", err)
+	}
+	return stats, nil
+}
+
+// CreateFolderStats starts the stats of a new folder and counts it as a
+// subfolder of its parent
+func CreateFolderStats(tx *gorm.DB, folder *models.Folder) error {
+	stats := models.FolderStats{FolderID: folder.ID, LastModified: time.Now()}
+	if err := tx.Create(&stats).Error; err != nil {
+		return fmt.Errorf("error creating folder stats: %w", err)
+	}
+	return addChildFolders(tx, folder.ParentID, 1)
+}
+
+// MoveFolderStats moves the totals of a folder's subtree from the folders
+// above its old parent to those above its new one
+func MoveFolderStats(tx *gorm.DB, folderID uuid.UUID, fromParentID, toParentID *uuid.UUID) error {
+	stats, err := GetFolderStats(tx, folderID)
+	if err != nil {
+		return err
+	}
+	if err := addChildFolders(tx, fromParentID, -1); err != nil {
+		return err
+	}
+	if err := addChildFolders(tx, toParentID, 1); err != nil {
+		return err
+	}
+	return TransferFolderStats(tx, fromParentID, toParentID, stats.FileCount, stats.TotalSize)
+}
+
+// RemoveFolderStats takes a deleted folder's subtree out of the totals of
+// the folders above it and drops the subtree's own stats
+func RemoveFolderStats(tx *gorm.DB, folder *models.Folder) error {
+	stats, err := GetFolderStats(tx, folder.ID)
+	if err != nil {
+		return err
+	}
+	if err := addFolderStats(tx, folder.ParentID, -stats.FileCount, -stats.TotalSize); err != nil {
+		return err
+	}
+	if err := addChildFolders(tx, folder.ParentID, -1); err != nil {
+		return err
+	}
+	if err := tx.Exec(`DELETE FROM folder_stats WHERE folder_id IN (`+folderSubtree+` SELECT id FROM subtree)`, folder.ID).Error; err != nil {
+		return fmt.Errorf("error removing folder stats: %w", err)
+	}
+	return nil
+}
+
+// SetFolderSizeLimit sets or, with a nil limit, removes a folder's size
+// limit. ownerID restricts the change to the owner's folders; admins pass
+// nil. A limit below the folder's current size is allowed and only stops it
+// from growing further
+func SetFolderSizeLimit(db *gorm.DB, folderID uuid.UUID, ownerID *uuid.UUID, limit *int64) (*models.Folder, error) {
+	if limit != nil && *limit < 0 {
+		return nil, ErrInvalidSizeLimit
+	}
+
+	query := db.Where("id = ?", folderID)
+	if ownerID != nil {
+		query = query.Where("owner_id = ?", *ownerID)
+	}
+	var folder models.Folder
+	if err := query.First(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFolderNotFound
+		}
+		return nil, fmt.Errorf("error fetching folder: %w", err)
+	}
+
+	if err := db.Model(&folder).Update("size_limit", limit).Error; err != nil {
+		return nil, fmt.Errorf("error updating folder size limit: %w", err)
+	}
+	folder.SizeLimit = limit
+	return &folder, nil
+}
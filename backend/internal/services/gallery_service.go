@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/exif"
+	"file-vault-system/backend/pkg/preview"
+)
+
+// ErrThumbnailURLInvalid is returned for thumbnail URLs that were tampered with, expired or outlived the content they were signed for
+var ErrThumbnailURLInvalid = apperrors.ErrForbidden.WithCode("THUMBNAIL_URL_INVALID", "thumbnail URL is invalid or has expired").
+	Explain("Request fresh thumbnail URLs from POST /api/v1/files/thumbnails")
+
+// GalleryFileColumns are the only columns galleries and timelines read, so
+// listing thousands of photos stays cheap
+var GalleryFileColumns = []string{
+	"files.id", "files.original_filename", "files.mime_type", "files.size", "files.file_hash_id",
+	"files.storage_tier", "files.is_quarantined", "files.processing_status", "files.is_encrypted", "files.created_at",
+}
+
+// Timeline granularities
+const (
+	TimelineDay   = "day"
+	TimelineMonth = "month"
+	TimelineYear  = "year"
+)
+
+// TimelineBucket is one period of a photo timeline
+type TimelineBucket struct {
+	Period     string // 2024, 2024-06 or 2024-06-30, by granularity
+	Start      time.Time
+	End        time.Time
+	Images     int
+	Videos     int
+	Cover      *models.File // the latest file of the period with a thumbnail, if any
+	CoverMedia MediaInfo
+	coverTaken time.Time
+}
+
+// inspectBatchSize bounds the file hashes looked up per query
+const inspectBatchSize = 500
+
+// MediaInfo is what an image's content says about it: its size in pixels and
+// when it was taken, where known
+type MediaInfo struct {
+	Width      int
+	Height     int
+	CapturedAt *time.Time
+}
+
+// GalleryService serves photo-grid clients. It signs thumbnail URLs that load
+// without an Authorization header, so a grid of hundreds of images needs no
+// token handling per image, and measures images so clients can lay out the
+// grid before any thumbnail arrives.
+type GalleryService struct {
+	db      *gorm.DB
+	secret  []byte
+	ttl     time.Duration
+	streams *FileStreamService
+}
+
+// NewGalleryService creates a new gallery service
+func NewGalleryService(db *gorm.DB, cfg *config.Config) *GalleryService {
+	return &GalleryService{
+		db:      db,
+		secret:  []byte(cfg.JWTSecret),
+		ttl:     time.Duration(cfg.ThumbnailURLTTL) * time.Minute,
+		streams: NewFileStreamService(db, cfg),
+	}
+}
+
+// ThumbnailExpiry returns when thumbnail URLs signed now expire. Expiries are
+// aligned to half the URL lifetime, so listing a folder again within that
+// window returns the same URLs and browsers reuse the thumbnails they cached;
+// every URL stays valid for at least half its lifetime.
+func (s *GalleryService) ThumbnailExpiry() time.Time {
+	window := int64(s.ttl.Seconds()) / 2
+	return time.Unix((time.Now().Unix()/window+2)*window, 0)
+}
+
+// HasThumbnail reports whether a thumbnail can be rendered for the file.
+// Content encrypted by the client, quarantined, archived or, in strict mode,
+// not yet scanned gets none.
+func (s *GalleryService) HasThumbnail(file *models.File) bool {
+	if file.IsEncrypted || file.IsQuarantined || !preview.Supports(file.MimeType) {
+		return false
+	}
+	if file.StorageTier != "" && file.StorageTier != models.StorageTierHot {
+		return false
+	}
+	return s.streams.CheckScanned(file) == nil
+}
+
+// ThumbnailURL returns the file's thumbnail URL signed until expiresAt
+func (s *GalleryService) ThumbnailURL(file *models.File, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	return fmt.Sprintf("/thumbnails/%s?expires=%d&sig=%s", file.ID, expires, s.sign(file.ID, file.FileHashID, expires))
+}
+
+// sign signs over the file and its content, so a URL stops working when the
+// file's content is replaced
+func (s *GalleryService) sign(fileID, fileHashID uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("thumbnail\x00" + fileID.String() + "\x00" + fileHashID.String() + "\x00" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ThumbnailFile checks a signed thumbnail URL and returns the file it was
+// signed for, with its FileHash, and how much longer the URL is valid
+func (s *GalleryService) ThumbnailFile(fileID uuid.UUID, expiresParam, signature string) (*models.File, time.Duration, error) {
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil {
+		return nil, 0, ErrThumbnailURLInvalid
+	}
+	remaining := time.Until(time.Unix(expires, 0))
+	if remaining <= 0 {
+		return nil, 0, ErrThumbnailURLInvalid
+	}
+
+	var file models.File
+	if err := s.db.Preload("FileHash").First(&file, "id = ?", fileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrThumbnailURLInvalid
+		}
+		return nil, 0, fmt.Errorf("error fetching file: %w", err)
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(file.ID, file.FileHashID, expires))) {
+		return nil, 0, ErrThumbnailURLInvalid
+	}
+	return &file, remaining, nil
+}
+
+// Timeline groups the user's images and videos into periods by when they
+// were taken: the EXIF capture time where a photo has one, and otherwise the
+// upload time. Capture times are the camera's wall-clock time and are taken
+// as they are; upload times are converted to loc. Periods holding files are
+// returned newest first.
+func (s *GalleryService) Timeline(userID uuid.UUID, granularity string, loc *time.Location) ([]TimelineBucket, error) {
+	var files []models.File
+	if err := s.db.Model(&models.File{}).Scopes(OwnedFiles(userID)).
+		Where("(files.mime_type LIKE ? OR files.mime_type LIKE ?)", "image/%", "video/%").
+		Select(GalleryFileColumns).
+		Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("error fetching media files: %w", err)
+	}
+
+	media, err := s.Inspect(files)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[time.Time]*TimelineBucket)
+	for i := range files {
+		file := &files[i]
+		taken := file.CreatedAt.In(loc)
+		if capturedAt := media[file.FileHashID].CapturedAt; capturedAt != nil {
+			c := capturedAt.UTC()
+			taken = time.Date(c.Year(), c.Month(), c.Day(), c.Hour(), c.Minute(), c.Second(), 0, loc)
+		}
+
+		start, end, period := timelinePeriod(taken, granularity)
+		bucket, ok := buckets[start]
+		if !ok {
+			bucket = &TimelineBucket{Period: period, Start: start, End: end}
+			buckets[start] = bucket
+		}
+		if strings.HasPrefix(file.MimeType, "video/") {
+			bucket.Videos++
+		} else {
+			bucket.Images++
+		}
+		if s.HasThumbnail(file) && (bucket.Cover == nil || taken.After(bucket.coverTaken)) {
+			bucket.Cover, bucket.CoverMedia, bucket.coverTaken = file, media[file.FileHashID], taken
+		}
+	}
+
+	timeline := make([]TimelineBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		timeline = append(timeline, *bucket)
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		return timeline[i].Start.After(timeline[j].Start)
+	})
+	return timeline, nil
+}
+
+// AlbumItem is a photo or video of a shared album
+type AlbumItem struct {
+	File    models.File
+	Media   MediaInfo
+	TakenAt time.Time // the EXIF capture time where known, otherwise the upload time
+}
+
+// Album returns the photos and videos directly in a folder in the order they
+// were taken, oldest first, for album share links
+func (s *GalleryService) Album(folderID uuid.UUID) ([]AlbumItem, error) {
+	var files []models.File
+	if err := s.db.Model(&models.File{}).
+		Where("files.deleted_at IS NULL AND files.folder_id = ?", folderID).
+		Where("(files.mime_type LIKE ? OR files.mime_type LIKE ?)", "image/%", "video/%").
+		Select(GalleryFileColumns).
+		Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("error fetching album files: %w", err)
+	}
+
+	media, err := s.Inspect(files)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]AlbumItem, len(files))
+	for i := range files {
+		items[i] = AlbumItem{File: files[i], Media: media[files[i].FileHashID], TakenAt: files[i].CreatedAt}
+		if capturedAt := items[i].Media.CapturedAt; capturedAt != nil {
+			items[i].TakenAt = *capturedAt
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].TakenAt.Equal(items[j].TakenAt) {
+			return items[i].TakenAt.Before(items[j].TakenAt)
+		}
+		return items[i].File.OriginalFilename < items[j].File.OriginalFilename
+	})
+	return items, nil
+}
+
+// AlbumFiles returns the photos and videos among fileIDs that are directly in
+// a folder, for opening or downloading items of a shared album. Other IDs
+// are left out.
+func (s *GalleryService) AlbumFiles(folderID uuid.UUID, fileIDs []uuid.UUID) ([]models.File, error) {
+	var files []models.File
+	if err := s.db.Where("files.deleted_at IS NULL AND files.folder_id = ? AND files.id IN ?", folderID, fileIDs).
+		Where("(files.mime_type LIKE ? OR files.mime_type LIKE ?)", "image/%", "video/%").
+		Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("error fetching album files: %w", err)
+	}
+	return files, nil
+}
+
+// timelinePeriod returns the start and end of the period holding t, and its name
+func timelinePeriod(t time.Time, granularity string) (time.Time, time.Time, string) {
+	switch granularity {
+	case TimelineDay:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 1), start.Format("2006-01-02")
+	case TimelineYear:
+		start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(1, 0, 0), start.Format("2006")
+	default:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0), start.Format("2006-01")
+	}
+}
+
+// measurable reports whether image dimensions can be read from content of a
+// MIME type
+func measurable(mimeType string) bool {
+	switch strings.ToLower(mimeType) {
+	case "image/png", "image/jpeg", "image/jpg", "image/gif":
+		return true
+	}
+	return false
+}
+
+// Inspect returns what is known of the images among files, keyed by
+// FileHashID. Content inspected before is not read again; the rest has its
+// dimensions and EXIF capture time read from its header and recorded,
+// including images that cannot be read so they are not tried again.
+// Archived content is skipped until it is restored.
+func (s *GalleryService) Inspect(files []models.File) (map[uuid.UUID]MediaInfo, error) {
+	fileIDs := make(map[uuid.UUID]uuid.UUID)
+	var hashIDs []uuid.UUID
+	for _, file := range files {
+		if file.IsEncrypted || !measurable(file.MimeType) {
+			continue
+		}
+		if _, seen := fileIDs[file.FileHashID]; !seen {
+			fileIDs[file.FileHashID] = file.ID
+			hashIDs = append(hashIDs, file.FileHashID)
+		}
+	}
+
+	media := make(map[uuid.UUID]MediaInfo)
+	for len(hashIDs) > 0 {
+		batch := hashIDs[:min(len(hashIDs), inspectBatchSize)]
+		hashIDs = hashIDs[len(batch):]
+
+		var hashes []models.FileHash
+		if err := s.db.Select("id", "size", "storage_path", "storage_tier", "width", "height", "captured_at").
+			Where("id IN ?", batch).Find(&hashes).Error; err != nil {
+			return nil, fmt.Errorf("error fetching file hashes: %w", err)
+		}
+
+		for _, fileHash := range hashes {
+			if fileHash.Width == nil || fileHash.Height == nil {
+				file := models.File{FileHashID: fileHash.ID, FileHash: &fileHash}
+				file.ID = fileIDs[fileHash.ID]
+				stream, err := s.streams.Open(&file)
+				if err != nil {
+					// Archived or missing content
+					continue
+				}
+				info, err := inspectImage(stream)
+				if err != nil {
+					continue
+				}
+				if err := s.db.Model(&models.FileHash{}).Where("id = ?", fileHash.ID).
+					UpdateColumns(map[string]interface{}{"width": info.Width, "height": info.Height, "captured_at": info.CapturedAt}).Error; err != nil {
+					return nil, fmt.Errorf("error recording image metadata: %w", err)
+				}
+				fileHash.Width, fileHash.Height, fileHash.CapturedAt = &info.Width, &info.Height, info.CapturedAt
+			}
+			info := MediaInfo{CapturedAt: fileHash.CapturedAt}
+			if *fileHash.Width > 0 && *fileHash.Height > 0 {
+				info.Width, info.Height = *fileHash.Width, *fileHash.Height
+			}
+			media[fileHash.ID] = info
+		}
+	}
+	return media, nil
+}
+
+// inspectImage reads an image's dimensions and capture time from its header.
+// Content that is not a readable image measures 0 by 0; an error means the
+// file could not be read and is worth trying again.
+func inspectImage(stream *FileStream) (MediaInfo, error) {
+	f, err := stream.Reader(context.Background())
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	defer f.Close()
+
+	var info MediaInfo
+	if capturedAt, err := exif.CaptureTime(f); err == nil {
+		info.CapturedAt = &capturedAt
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return MediaInfo{}, err
+	}
+	if cfg, _, err := image.DecodeConfig(f); err == nil {
+		info.Width, info.Height = cfg.Width, cfg.Height
+	}
+	return info, nil
+}
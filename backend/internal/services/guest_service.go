@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// ErrGuestLifetime is returned when an owner asks for a guest account that
+// outlives the configured maximum
+var ErrGuestLifetime = errors.New("guest account lifetime exceeds the allowed maximum")
+
+// GuestService creates expiring guest accounts for external collaborators
+// and disables them once they expire
+type GuestService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewGuestService creates a new guest account service
+func NewGuestService(db *gorm.DB, cfg *config.Config) *GuestService {
+	return &GuestService{db: db, cfg: cfg}
+}
+
+// GuestCredentials are the generated login details of a new guest account.
+// The password is only available when the account is created
+type GuestCredentials struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Password  string    `json:"password"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Invite makes sure email can receive a share. Existing users are left alone,
+// except that an active guest has its expiry pushed out to cover the new
+// invitation; guests that were disabled or have expired stay so. Otherwise a
+// guest account is created and its credentials are returned; days of zero
+// uses the configured default lifetime
+func (s *GuestService) Invite(email string, invitedBy uuid.UUID, days int) (*GuestCredentials, error) {
+	if days <= 0 {
+		days = s.cfg.GuestAccountDays
+	}
+	if days > s.cfg.GuestAccountMaxDays {
+		return nil, fmt.Errorf("%w of %d days", ErrGuestLifetime, s.cfg.GuestAccountMaxDays)
+	}
+	expiresAt := time.Now().AddDate(0, 0, days)
+
+	// Guests join the tenant of the user inviting them
+	var inviter models.User
+	if err := s.db.Select("id", "tenant_id").Where("id = ?", invitedBy).Take(&inviter).Error; err != nil {
+		return nil, fmt.Errorf("error finding inviting user: %w", err)
+	}
+
+	var existing models.User
+	err := s.db.Where("email = ?", email).First(&existing).Error
+	if err == nil {
+		if existing.Role != models.RoleGuest || !models.SameTenant(existing.TenantID, inviter.TenantID) {
+			return nil, nil
+		}
+		// Inviting never brings back an account an admin disabled or that expired
+		if !existing.IsActive || (existing.GuestExpiresAt != nil && existing.GuestExpiresAt.Before(time.Now())) {
+			return nil, nil
+		}
+		if existing.GuestExpiresAt == nil || existing.GuestExpiresAt.Before(expiresAt) {
+			if err := s.db.Model(&existing).Where("is_active = ?", true).
+				Update("guest_expires_at", expiresAt).Error; err != nil {
+				return nil, fmt.Errorf("error extending guest account: %w", err)
+			}
+		}
+		return nil, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error finding user: %w", err)
+	}
+
+	password, err := generateSecureToken(12)
+	if err != nil {
+		return nil, fmt.Errorf("error generating guest password: %w", err)
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing guest password: %w", err)
+	}
+	suffix, err := generateSecureToken(4)
+	if err != nil {
+		return nil, fmt.Errorf("error generating guest username: %w", err)
+	}
+
+	guest := models.User{
+		BaseModel:      models.BaseModel{ID: uuid.New()},
+		Username:       "guest-" + suffix,
+		Email:          email,
+		PasswordHash:   string(passwordHash),
+		FirstName:      strings.SplitN(email, "@", 2)[0],
+		Role:           models.RoleGuest,
+		StorageQuota:   0,
+		IsActive:       true,
+		GuestExpiresAt: &expiresAt,
+		InvitedBy:      &invitedBy,
+		TenantID:       inviter.TenantID,
+	}
+	if err := s.db.Create(&guest).Error; err != nil {
+		return nil, fmt.Errorf("error creating guest account: %w", err)
+	}
+
+	return &GuestCredentials{
+		UserID:    guest.ID,
+		Username:  guest.Username,
+		Email:     guest.Email,
+		Password:  password,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Start runs guest expiry passes in the background
+func (s *GuestService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			disabled, err := s.ExpireGuests()
+			if err != nil {
+				log.Printf("Guest account expiry failed: %v", err)
+			} else if disabled > 0 {
+				log.Printf("Guest accounts: disabled %d expired account(s)", disabled)
+			}
+		}
+	}()
+}
+
+// ExpireGuests disables guest accounts whose lifetime has passed
+func (s *GuestService) ExpireGuests() (int64, error) {
+	result := s.db.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND guest_expires_at <= ?", models.RoleGuest, true, time.Now()).
+		Update("is_active", false)
+	if result.Error != nil {
+		return 0, fmt.Errorf("error disabling expired guest accounts: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
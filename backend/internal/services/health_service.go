@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// Component statuses reported by the readiness check
+const (
+	ComponentStatusUp       = "up"
+	ComponentStatusDegraded = "degraded"
+	ComponentStatusDown     = "down"
+)
+
+// Overall readiness statuses
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusDegraded    = "degraded"
+	HealthStatusUnavailable = "unavailable"
+)
+
+const (
+	// healthCheckTimeout bounds each dependency check so a hung database
+	// cannot stall a probe past its deadline
+	healthCheckTimeout = 2 * time.Second
+	// staleRestoreAge is how long a restore may wait before the queue is degraded
+	staleRestoreAge = time.Hour
+	// staleReplicationAge is how long a blob may wait for replication before the queue is degraded
+	staleReplicationAge = time.Hour
+	// staleBackupAge is how long a backup may run before it is considered stuck
+	staleBackupAge = 24 * time.Hour
+)
+
+// ComponentHealth is the result of checking a single dependency
+type ComponentHealth struct {
+	Status    string                 `json:"status"`
+	Message   string                 `json:"message,omitempty"`
+	LatencyMs int64                  `json:"latency_ms"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// ReadinessReport is the component-level result of a readiness check
+type ReadinessReport struct {
+	Status     string                      `json:"status"`
+	Ready      bool                        `json:"ready"`
+	Timestamp  time.Time                   `json:"timestamp"`
+	Uptime     string                      `json:"uptime"`
+	Components map[string]*ComponentHealth `json:"components"`
+}
+
+// HealthService checks the dependencies the server needs to serve traffic
+type HealthService struct {
+	db        *gorm.DB
+	cfg       *config.Config
+	startedAt time.Time
+}
+
+// NewHealthService creates a new health service
+func NewHealthService(db *gorm.DB, cfg *config.Config) *HealthService {
+	return &HealthService{
+		db:        db,
+		cfg:       cfg,
+		startedAt: time.Now(),
+	}
+}
+
+// Uptime returns how long the server has been running
+func (s *HealthService) Uptime() time.Duration {
+	return time.Since(s.startedAt)
+}
+
+// Readiness checks the database, storage and background job queue. The
+// server is ready when the database and storage are up; a backed-up job
+// queue only degrades the report.
+func (s *HealthService) Readiness(ctx context.Context) *ReadinessReport {
+	report := &ReadinessReport{
+		Timestamp: time.Now(),
+		Uptime:    s.Uptime().Round(time.Second).String(),
+		Components: map[string]*ComponentHealth{
+			"database": s.checkDatabase(ctx),
+			"storage":  s.checkStorage(),
+		},
+	}
+
+	report.Ready = report.Components["database"].Status == ComponentStatusUp &&
+		report.Components["storage"].Status == ComponentStatusUp
+
+	// The queue lives in the database, so skip it when the database is down
+	if report.Components["database"].Status == ComponentStatusUp {
+		report.Components["jobs"] = s.checkJobs(ctx)
+	}
+
+	switch {
+	case !report.Ready:
+		report.Status = HealthStatusUnavailable
+	case report.Components["jobs"].Status != ComponentStatusUp:
+		report.Status = HealthStatusDegraded
+	default:
+		report.Status = HealthStatusOK
+	}
+
+	return report
+}
+
+// checkDatabase pings the database
+func (s *HealthService) checkDatabase(ctx context.Context) *ComponentHealth {
+	start := time.Now()
+	result := &ComponentHealth{Status: ComponentStatusUp}
+
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		result.Status = ComponentStatusDown
+		result.Message = "database handle unavailable"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		result.Status = ComponentStatusDown
+		result.Message = fmt.Sprintf("ping failed: %v", err)
+	}
+
+	stats := sqlDB.Stats()
+	result.Details = map[string]interface{}{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+	}
+	result.LatencyMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// checkStorage verifies blob storage and the upload staging directory accept writes
+func (s *HealthService) checkStorage() *ComponentHealth {
+	start := time.Now()
+	result := &ComponentHealth{Status: ComponentStatusUp}
+
+	for _, dir := range []string{s.cfg.StoragePath, s.cfg.UploadTempDir} {
+		if err := probeWritable(dir); err != nil {
+			result.Status = ComponentStatusDown
+			result.Message = err.Error()
+			break
+		}
+	}
+	if result.Status == ComponentStatusUp && s.cfg.IsObjectStorage() {
+		if err := s.probeBucket(); err != nil {
+			result.Status = ComponentStatusDown
+			result.Message = err.Error()
+		}
+	}
+
+	result.LatencyMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// probeWritable writes and removes a small file in dir
+func probeWritable(dir string) error {
+	probe, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	name := probe.Name()
+	defer os.Remove(name)
+
+	if _, err := probe.Write([]byte("ok")); err != nil {
+		probe.Close()
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	if err := probe.Close(); err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	return nil
+}
+
+// probeBucket writes and removes a small object in the blob bucket
+func (s *HealthService) probeBucket() error {
+	store, err := OpenBlobStore(s.cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	key := ".healthcheck-" + uuid.NewString()
+	if err := store.Put(ctx, key, strings.NewReader("ok"), 2); err != nil {
+		return fmt.Errorf("blob bucket is not writable: %w", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("blob bucket is not writable: %w", err)
+	}
+	return nil
+}
+
+// checkJobs looks for background work that has stalled: backups that never
+// finished, restores nobody is processing and a growing replication backlog
+func (s *HealthService) checkJobs(ctx context.Context) *ComponentHealth {
+	start := time.Now()
+	result := &ComponentHealth{Status: ComponentStatusUp, Details: map[string]interface{}{}}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+
+	var runningBackups, staleBackups int64
+	if err := db.Model(&models.BackupJob{}).
+		Where("status IN ?", []models.BackupStatus{models.BackupStatusPending, models.BackupStatusRunning}).
+		Count(&runningBackups).Error; err != nil {
+		return jobsCheckFailed(result, start, err)
+	}
+	if err := db.Model(&models.BackupJob{}).
+		Where("status IN ?", []models.BackupStatus{models.BackupStatusPending, models.BackupStatusRunning}).
+		Where("created_at < ?", now.Add(-staleBackupAge)).
+		Count(&staleBackups).Error; err != nil {
+		return jobsCheckFailed(result, start, err)
+	}
+	result.Details["running_backups"] = runningBackups
+	if staleBackups > 0 {
+		result.Status = ComponentStatusDegraded
+		result.Message = fmt.Sprintf("%d backup job(s) running for over %s", staleBackups, staleBackupAge)
+	}
+
+	if s.cfg.EnableArchiving {
+		var pendingRestores, staleRestores int64
+		if err := db.Model(&models.FileHash{}).
+			Where("storage_tier = ?", models.StorageTierRestoring).
+			Count(&pendingRestores).Error; err != nil {
+			return jobsCheckFailed(result, start, err)
+		}
+		if err := db.Model(&models.FileHash{}).
+			Where("storage_tier = ? AND restore_requested_at < ?", models.StorageTierRestoring, now.Add(-staleRestoreAge)).
+			Count(&staleRestores).Error; err != nil {
+			return jobsCheckFailed(result, start, err)
+		}
+		result.Details["pending_restores"] = pendingRestores
+		if staleRestores > 0 {
+			result.Status = ComponentStatusDegraded
+			result.Message = fmt.Sprintf("%d restore(s) waiting for over %s", staleRestores, staleRestoreAge)
+		}
+	}
+
+	if s.cfg.EnableReplication {
+		var pendingReplication, staleReplication int64
+		if err := db.Model(&models.FileHash{}).
+			Where("replication_status = ?", models.ReplicationPending).
+			Count(&pendingReplication).Error; err != nil {
+			return jobsCheckFailed(result, start, err)
+		}
+		if err := db.Model(&models.FileHash{}).
+			Where("replication_status = ? AND created_at < ?", models.ReplicationPending, now.Add(-staleReplicationAge)).
+			Count(&staleReplication).Error; err != nil {
+			return jobsCheckFailed(result, start, err)
+		}
+		result.Details["pending_replication"] = pendingReplication
+		if staleReplication > 0 {
+			result.Status = ComponentStatusDegraded
+			result.Message = fmt.Sprintf("%d blob(s) waiting for replication for over %s", staleReplication, staleReplicationAge)
+		}
+	}
+
+	result.LatencyMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// jobsCheckFailed marks the job queue degraded when it could not be inspected
+func jobsCheckFailed(result *ComponentHealth, start time.Time, err error) *ComponentHealth {
+	result.Status = ComponentStatusDegraded
+	result.Message = fmt.Sprintf("failed to inspect job queue: %v", err)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	return result
+}
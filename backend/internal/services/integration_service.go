@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// maxIntegrationsPerOwner limits how many integrations a user, tenant or the
+// platform may connect
+const maxIntegrationsPerOwner = 10
+
+// integrationTimeout bounds a single webhook delivery
+const integrationTimeout = 10 * time.Second
+
+var (
+	// ErrInvalidIntegration is returned when an integration request fails validation
+	ErrInvalidIntegration = errors.New("invalid integration")
+	// ErrIntegrationNotFound is returned when an integration does not exist for its owner
+	ErrIntegrationNotFound = errors.New("integration not found")
+	// ErrIntegrationLimit is returned when the owner already has the most integrations allowed
+	ErrIntegrationLimit = errors.New("integration limit reached")
+)
+
+// IntegrationOwner names who an integration belongs to: a user, a tenant, or
+// the platform when both are nil
+type IntegrationOwner struct {
+	UserID   *uuid.UUID
+	TenantID *uuid.UUID
+}
+
+// IntegrationRequest creates or replaces an integration. An empty webhook URL
+// on update keeps the current one, since responses never reveal it
+type IntegrationRequest struct {
+	Kind       models.IntegrationKind    `json:"kind" binding:"required,oneof=slack teams"`
+	Name       string                    `json:"name" binding:"required,max=100"`
+	WebhookURL string                    `json:"webhook_url"`
+	Events     []models.IntegrationEvent `json:"events" binding:"required,min=1"`
+	IsActive   *bool                     `json:"is_active"`
+}
+
+// IntegrationMessage is an event posted to the integrations subscribed to it.
+// It reaches the personal integrations of the user it is about, the team
+// integrations of their tenant and the platform integrations
+type IntegrationMessage struct {
+	Event  models.IntegrationEvent
+	UserID uuid.UUID
+	Title  string
+	Text   string
+	URL    string // optional link shown with the message
+
+	username string // the user's name, shown in team and platform channels
+}
+
+// IntegrationService manages Slack and Microsoft Teams integrations and
+// delivers event messages to their webhooks
+type IntegrationService struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	client *http.Client
+}
+
+// NewIntegrationService creates a new integration service
+func NewIntegrationService(db *gorm.DB, cfg *config.Config) *IntegrationService {
+	return &IntegrationService{
+		db:  db,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: integrationTimeout,
+			// A redirect could lead the request to a host that is not allowed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// ownedBy scopes an integrations query to one owner
+func ownedBy(owner IntegrationOwner) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case owner.UserID != nil:
+			return db.Where("user_id = ?", *owner.UserID)
+		case owner.TenantID != nil:
+			return db.Where("tenant_id = ?", *owner.TenantID)
+		default:
+			return db.Where("user_id IS NULL AND tenant_id IS NULL")
+		}
+	}
+}
+
+// ListIntegrations returns the owner's integrations, oldest first
+func (s *IntegrationService) ListIntegrations(owner IntegrationOwner) ([]models.Integration, error) {
+	var integrations []models.Integration
+	if err := s.db.Scopes(ownedBy(owner)).Order("created_at").Find(&integrations).Error; err != nil {
+		return nil, fmt.Errorf("error fetching integrations: %w", err)
+	}
+	return integrations, nil
+}
+
+// GetIntegration returns one of the owner's integrations
+func (s *IntegrationService) GetIntegration(owner IntegrationOwner, id uuid.UUID) (*models.Integration, error) {
+	var integration models.Integration
+	if err := s.db.Scopes(ownedBy(owner)).First(&integration, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIntegrationNotFound
+		}
+		return nil, fmt.Errorf("error fetching integration: %w", err)
+	}
+	return &integration, nil
+}
+
+// CreateIntegration connects a webhook for the owner
+func (s *IntegrationService) CreateIntegration(owner IntegrationOwner, createdBy uuid.UUID, req IntegrationRequest) (*models.Integration, error) {
+	var count int64
+	if err := s.db.Model(&models.Integration{}).Scopes(ownedBy(owner)).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("error counting integrations: %w", err)
+	}
+	if count >= maxIntegrationsPerOwner {
+		return nil, ErrIntegrationLimit
+	}
+
+	integration := &models.Integration{
+		UserID:    owner.UserID,
+		TenantID:  owner.TenantID,
+		IsActive:  true,
+		CreatedBy: createdBy,
+	}
+	if err := s.applyRequest(integration, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(integration).Error; err != nil {
+		return nil, fmt.Errorf("error creating integration: %w", err)
+	}
+	return integration, nil
+}
+
+// UpdateIntegration replaces one of the owner's integrations
+func (s *IntegrationService) UpdateIntegration(owner IntegrationOwner, id uuid.UUID, req IntegrationRequest) (*models.Integration, error) {
+	integration, err := s.GetIntegration(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyRequest(integration, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(integration).Error; err != nil {
+		return nil, fmt.Errorf("error updating integration: %w", err)
+	}
+	return integration, nil
+}
+
+// DeleteIntegration disconnects one of the owner's integrations
+func (s *IntegrationService) DeleteIntegration(owner IntegrationOwner, id uuid.UUID) error {
+	result := s.db.Scopes(ownedBy(owner)).Delete(&models.Integration{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("error deleting integration: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrIntegrationNotFound
+	}
+	return nil
+}
+
+// TestIntegration posts a test message to one of the owner's integrations
+// and waits for the outcome
+func (s *IntegrationService) TestIntegration(owner IntegrationOwner, id uuid.UUID) (*models.Integration, error) {
+	integration, err := s.GetIntegration(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	s.deliver(integration, IntegrationMessage{
+		Title: "Test message",
+		Text:  fmt.Sprintf("The integration %s is connected.", integration.Name),
+		URL:   s.cfg.PublicBaseURL,
+	})
+	return integration, nil
+}
+
+func (s *IntegrationService) applyRequest(integration *models.Integration, req IntegrationRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidIntegration)
+	}
+
+	events := make(models.StringArray, 0, len(req.Events))
+	for _, event := range req.Events {
+		if !isIntegrationEvent(event) {
+			return fmt.Errorf("%w: unknown event %s", ErrInvalidIntegration, event)
+		}
+		if !events.Contains(string(event)) {
+			events = append(events, string(event))
+		}
+	}
+
+	webhookURL := strings.TrimSpace(req.WebhookURL)
+	switch {
+	case webhookURL != "":
+		if err := s.validateWebhookURL(webhookURL); err != nil {
+			return err
+		}
+		integration.WebhookURL = webhookURL
+	case integration.WebhookURL == "":
+		return fmt.Errorf("%w: webhook_url is required", ErrInvalidIntegration)
+	}
+
+	integration.Kind = req.Kind
+	integration.Name = name
+	integration.Events = events
+	if req.IsActive != nil {
+		integration.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+// validateWebhookURL accepts https URLs on INTEGRATION_WEBHOOK_HOSTS, so an
+// integration cannot make the server call into its own network. Plain http
+// is accepted outside production for testing
+func (s *IntegrationService) validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: webhook_url is not a valid URL", ErrInvalidIntegration)
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || s.cfg.IsProduction()) {
+		return fmt.Errorf("%w: webhook_url must use https", ErrInvalidIntegration)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: webhook_url must not contain credentials", ErrInvalidIntegration)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.cfg.IntegrationWebhookHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return nil
+			}
+		} else if allowed != "" && host == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: webhook_url host %s is not allowed", ErrInvalidIntegration, host)
+}
+
+func isIntegrationEvent(event models.IntegrationEvent) bool {
+	for _, e := range models.IntegrationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatch posts the message to every active integration subscribed to its
+// event, in the background
+func (s *IntegrationService) Dispatch(message IntegrationMessage) {
+	go func() {
+		var user models.User
+		if err := s.db.Select("id", "username", "tenant_id").First(&user, "id = ?", message.UserID).Error; err != nil {
+			log.Printf("Failed to dispatch %s to integrations: %v", message.Event, err)
+			return
+		}
+		message.username = user.Username
+
+		query := s.db.Where("is_active = ?", true)
+		if user.TenantID != nil {
+			query = query.Where("user_id = ? OR tenant_id = ? OR (user_id IS NULL AND tenant_id IS NULL)", user.ID, *user.TenantID)
+		} else {
+			query = query.Where("user_id = ? OR (user_id IS NULL AND tenant_id IS NULL)", user.ID)
+		}
+		var integrations []models.Integration
+		if err := query.Find(&integrations).Error; err != nil {
+			log.Printf("Failed to dispatch %s to integrations: %v", message.Event, err)
+			return
+		}
+
+		for i := range integrations {
+			if integrations[i].Events.Contains(string(message.Event)) {
+				s.deliver(&integrations[i], message)
+			}
+		}
+	}()
+}
+
+// LinkAccessed posts that a visitor opened or downloaded the named file or
+// folder through a share link made by createdBy. The visitor is described by
+// IP address, unless withheld, and country when known
+func (s *IntegrationService) LinkAccessed(createdBy uuid.UUID, kind, name, action, ipAddress, country, linkURL string) {
+	visitor := "A visitor"
+	if ipAddress != "" {
+		visitor += " at " + ipAddress
+	}
+	if country != "" {
+		visitor += " (" + country + ")"
+	}
+	verb := "opened"
+	if action == "download" {
+		verb = "downloaded"
+	}
+	s.Dispatch(IntegrationMessage{
+		Event:  models.IntegrationEventLinkAccessed,
+		UserID: createdBy,
+		Title:  "Share link " + verb,
+		Text:   fmt.Sprintf("%s %s the %s %s through a share link.", visitor, verb, kind, name),
+		URL:    linkURL,
+	})
+}
+
+// deliver posts the message to the integration's webhook and records the
+// outcome on the integration
+func (s *IntegrationService) deliver(integration *models.Integration, message IntegrationMessage) {
+	updates := map[string]interface{}{}
+	if err := s.post(integration, message); err != nil {
+		log.Printf("Failed to post to integration %s: %v", integration.ID, err)
+		integration.LastError = err.Error()
+	} else {
+		now := time.Now()
+		integration.LastDeliveredAt = &now
+		integration.LastError = ""
+		updates["last_delivered_at"] = now
+	}
+	updates["last_error"] = integration.LastError
+
+	// Recording the outcome leaves updated_at for changes by the owner
+	if err := s.db.Model(&models.Integration{}).Where("id = ?", integration.ID).
+		UpdateColumns(updates).Error; err != nil {
+		log.Printf("Failed to record delivery to integration %s: %v", integration.ID, err)
+	}
+}
+
+func (s *IntegrationService) post(integration *models.Integration, message IntegrationMessage) error {
+	// Shared channels need to know whom the message is about
+	if integration.UserID == nil && message.username != "" {
+		message.Text += " (" + message.username + ")"
+	}
+	// Chat clients cannot open links relative to this server, which is what
+	// they are until PUBLIC_BASE_URL is set
+	if !strings.HasPrefix(message.URL, "https://") && !strings.HasPrefix(message.URL, "http://") {
+		message.URL = ""
+	}
+
+	var payload interface{}
+	switch integration.Kind {
+	case models.IntegrationTeams:
+		payload = teamsPayload(message)
+	default:
+		payload = slackPayload(message)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding message: %w", err)
+	}
+
+	resp, err := s.client.Post(integration.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The error names the URL, which must not end up in last_error
+		return errors.New("webhook request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// slackEscape escapes the characters Slack reserves for links and mentions
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+// slackPayload formats a message for a Slack incoming webhook
+func slackPayload(message IntegrationMessage) map[string]interface{} {
+	text := "*" + slackEscape(message.Title) + "*\n" + slackEscape(message.Text)
+	if message.URL != "" {
+		text += "\n<" + message.URL + "|Open File Vault>"
+	}
+	return map[string]interface{}{
+		"text": message.Title + ": " + message.Text,
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type": "section",
+				"text": map[string]interface{}{"type": "mrkdwn", "text": text},
+			},
+		},
+	}
+}
+
+// teamsPayload formats a message as a card for a Microsoft Teams incoming
+// webhook
+func teamsPayload(message IntegrationMessage) map[string]interface{} {
+	payload := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    message.Title,
+		"themeColor": "0076D7",
+		"title":      message.Title,
+		"text":       message.Text,
+	}
+	if message.URL != "" {
+		payload["potentialAction"] = []interface{}{
+			map[string]interface{}{
+				"@type":   "OpenUri",
+				"name":    "Open File Vault",
+				"targets": []interface{}{map[string]interface{}{"os": "default", "uri": message.URL}},
+			},
+		}
+	}
+	return payload
+}
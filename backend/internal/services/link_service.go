@@ -0,0 +1,67 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+)
+
+// LinkService builds the URLs handed out in API responses and emails. Paths
+// are joined to PUBLIC_BASE_URL, and share links are served on the share
+// domain of the creator's tenant, or else on SHARE_DOMAIN. Links stay
+// relative when none of these is set
+type LinkService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewLinkService creates a new link service
+func NewLinkService(db *gorm.DB, cfg *config.Config) *LinkService {
+	return &LinkService{db: db, cfg: cfg}
+}
+
+// URL returns the link to a path on this server
+func (s *LinkService) URL(path string) string {
+	return s.cfg.PublicBaseURL + path
+}
+
+// FileDownloadURL returns the API link that downloads a file
+func (s *LinkService) FileDownloadURL(fileID uuid.UUID) string {
+	return s.URL("/api/v1/files/" + fileID.String() + "/download")
+}
+
+// ShareLinkURL returns the URL of a file share link
+func (s *LinkService) ShareLinkURL(createdBy uuid.UUID, token string) string {
+	return s.shareURL(createdBy, "/share/"+token)
+}
+
+// FolderShareLinkURL returns the URL of a folder share link
+func (s *LinkService) FolderShareLinkURL(createdBy uuid.UUID, token string) string {
+	return s.shareURL(createdBy, "/folder-share/"+token)
+}
+
+func (s *LinkService) shareURL(createdBy uuid.UUID, path string) string {
+	domain := s.cfg.ShareDomain
+	if tenantDomain := TenantShareDomain(s.db, createdBy); tenantDomain != "" {
+		domain = tenantDomain
+	}
+	if domain == "" {
+		return s.URL(path)
+	}
+	return s.cfg.ShareLinkScheme + "://" + domain + path
+}
+
+// TenantShareDomain returns the custom share domain of the user's tenant, or
+// "" when the user is outside any tenant or the tenant has none
+func TenantShareDomain(db *gorm.DB, userID uuid.UUID) string {
+	var domains []string
+	db.Table("users").
+		Joins("JOIN tenants ON tenants.id = users.tenant_id").
+		Where("users.id = ? AND tenants.share_domain IS NOT NULL", userID).
+		Pluck("tenants.share_domain", &domains)
+	if len(domains) == 0 {
+		return ""
+	}
+	return domains[0]
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// LoginHistoryService records sign-in attempts and warns users when their
+// account is used from a browser or place it has not been used from before
+type LoginHistoryService struct {
+	db                  *gorm.DB
+	notificationService *NotificationService
+	mailService         *MailService
+}
+
+// NewLoginHistoryService creates a new login history service
+func NewLoginHistoryService(db *gorm.DB, notificationService *NotificationService, mailService *MailService) *LoginHistoryService {
+	return &LoginHistoryService{
+		db:                  db,
+		notificationService: notificationService,
+		mailService:         mailService,
+	}
+}
+
+// Record stores a sign-in attempt. For a successful one it decides whether
+// it came from a new device and, if so, tells the user
+func (s *LoginHistoryService) Record(event *models.LoginEvent) error {
+	if event.Success && event.UserID != nil {
+		isNew, firstLogin, err := s.isNewDevice(event)
+		if err != nil {
+			return err
+		}
+		event.NewDevice = isNew
+
+		if err := s.db.Create(event).Error; err != nil {
+			return fmt.Errorf("error recording login: %w", err)
+		}
+		// A user's very first login is expected; only later ones warn
+		if isNew && !firstLogin {
+			s.notifyNewDevice(event)
+		}
+		return nil
+	}
+
+	if err := s.db.Create(event).Error; err != nil {
+		return fmt.Errorf("error recording login: %w", err)
+	}
+	return nil
+}
+
+// isNewDevice reports whether no earlier successful login of the user came
+// from the same user agent and location. Location is the country when known
+// and the IP address otherwise
+func (s *LoginHistoryService) isNewDevice(event *models.LoginEvent) (isNew, firstLogin bool, err error) {
+	var previous int64
+	if err := s.db.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND success = ?", *event.UserID, true).
+		Count(&previous).Error; err != nil {
+		return false, false, fmt.Errorf("error checking login history: %w", err)
+	}
+	if previous == 0 {
+		return true, true, nil
+	}
+
+	query := s.db.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND success = ? AND user_agent = ?", *event.UserID, true, event.UserAgent)
+	if event.Country != "" {
+		query = query.Where("country = ?", event.Country)
+	} else {
+		query = query.Where("ip_address = ?", event.IPAddress)
+	}
+	var matching int64
+	if err := query.Count(&matching).Error; err != nil {
+		return false, false, fmt.Errorf("error checking login history: %w", err)
+	}
+	return matching == 0, false, nil
+}
+
+func (s *LoginHistoryService) notifyNewDevice(event *models.LoginEvent) {
+	where := event.IPAddress
+	if event.Country != "" {
+		where += " (" + event.Country + ")"
+	}
+	message := fmt.Sprintf("Your account was signed in from a new device at %s. If this was not you, sign that device out and change your password.", where)
+
+	if err := s.notificationService.Notify(*event.UserID, NotifyParams{
+		Type:     models.NotificationNewLogin,
+		Severity: models.NotificationSeverityWarning,
+		Title:    "New sign-in to your account",
+		Message:  message,
+		Details: models.NotificationDetails{
+			"login_id":   event.ID,
+			"device_id":  event.DeviceID,
+			"ip_address": event.IPAddress,
+			"country":    event.Country,
+			"user_agent": event.UserAgent,
+			"method":     event.Method,
+		},
+	}); err != nil {
+		log.Printf("Failed to notify user %s about new device login: %v", *event.UserID, err)
+	}
+
+	if s.mailService.Enabled() && event.Email != "" && s.notificationService.WantsEmail(*event.UserID, models.NotificationNewLogin) {
+		if err := s.mailService.Send(event.Email, EmailNewLogin, NewLoginEmail{
+			Message:   message,
+			Time:      event.CreatedAt.UTC().Format(time.RFC1123),
+			UserAgent: event.UserAgent,
+		}); err != nil {
+			log.Printf("Failed to email user %s about new device login: %v", *event.UserID, err)
+		}
+	}
+}
+
+// History returns a page of the user's sign-in attempts, newest first
+func (s *LoginHistoryService) History(userID uuid.UUID, limit, offset int) ([]models.LoginEvent, int64, error) {
+	query := s.db.Model(&models.LoginEvent{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting login history: %w", err)
+	}
+
+	var logins []models.LoginEvent
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&logins).Error; err != nil {
+		return nil, 0, fmt.Errorf("error listing login history: %w", err)
+	}
+	return logins, total, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+
+	"file-vault-system/backend/internal/config"
+)
+
+// MailService renders the email templates and sends the result, in plain
+// text and HTML, through the configured SMTP server
+type MailService struct {
+	cfg       *config.Config
+	templates map[string]*emailTemplate
+}
+
+// NewMailService creates a new mail service
+func NewMailService(cfg *config.Config) *MailService {
+	return &MailService{cfg: cfg, templates: loadEmailTemplates(cfg.EmailTemplatesDir)}
+}
+
+// Enabled reports whether an SMTP server is configured
+func (s *MailService) Enabled() bool {
+	return s.cfg.SMTPHost != ""
+}
+
+// Send renders one of the email templates with data and delivers it to a
+// single recipient
+func (s *MailService) Send(to, template string, data interface{}) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	tmpl, ok := s.templates[template]
+	if !ok {
+		return fmt.Errorf("unknown email template %q", template)
+	}
+	subject, text, html, err := tmpl.render(data)
+	if err != nil {
+		return fmt.Errorf("error rendering %s email: %w", template, err)
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return fmt.Errorf("error building email: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return fmt.Errorf("error building email: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return fmt.Errorf("error building email: %w", err)
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return fmt.Errorf("error building email: %w", err)
+	}
+
+	var auth smtp.Auth
+	if s.cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", s.cfg.SMTPUsername, s.cfg.SMTPPassword, s.cfg.SMTPHost)
+	}
+
+	// Line breaks in a header would let its value start new headers
+	headerValue := strings.NewReplacer("\r", "", "\n", "").Replace
+	message := strings.Join([]string{
+		"From: " + headerValue(s.cfg.SMTPFrom),
+		"To: " + headerValue(to),
+		"Subject: " + mime.QEncoding.Encode("utf-8", headerValue(subject)),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + parts.Boundary(),
+		"",
+		body.String(),
+	}, "\r\n")
+
+	addr := fmt.Sprintf("%s:%d", s.cfg.SMTPHost, s.cfg.SMTPPort)
+	if err := smtp.SendMail(addr, auth, s.cfg.SMTPFrom, []string{to}, []byte(message)); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return nil
+}
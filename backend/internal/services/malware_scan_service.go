@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/malware"
+)
+
+// malwareScanBatchSize is the number of stored blobs scanned per query
+const malwareScanBatchSize = 20
+
+// ErrMalwareScannerUnavailable is returned when no scanner is configured
+var ErrMalwareScannerUnavailable = errors.New("malware scanner is not configured")
+
+// MalwareScanService scans uploads before they are stored and rescans stored
+// blobs in the background. Infected uploads are rejected; blobs found to be
+// infected after they were stored quarantine every file that uses them.
+type MalwareScanService struct {
+	db                *gorm.DB
+	cfg               *config.Config
+	quarantineService *QuarantineService
+	scanner           malware.Scanner
+}
+
+// NewMalwareScanService creates a malware scan service with the scanner from
+// the configuration
+func NewMalwareScanService(db *gorm.DB, cfg *config.Config, quarantineService *QuarantineService) *MalwareScanService {
+	s := &MalwareScanService{
+		db:                db,
+		cfg:               cfg,
+		quarantineService: quarantineService,
+	}
+
+	var err error
+	switch cfg.MalwareScanner {
+	case "clamd":
+		var scanner *malware.ClamdScanner
+		if scanner, err = malware.NewClamdScanner(cfg.ClamdAddress); err == nil {
+			s.scanner = scanner
+		}
+	case "external":
+		if cfg.MalwareExternalURL == "" {
+			err = fmt.Errorf("MALWARE_EXTERNAL_URL is not set")
+		} else {
+			s.scanner = malware.NewHTTPScanner(cfg.MalwareExternalURL, cfg.MalwareExternalToken, time.Duration(cfg.MalwareScanTimeout)*time.Second)
+		}
+	default:
+		err = fmt.Errorf("unknown scanner %q", cfg.MalwareScanner)
+	}
+	if err != nil && cfg.EnableMalwareScan {
+		log.Printf("Malware scanning unavailable: %v", err)
+	}
+
+	return s
+}
+
+// Enabled reports whether uploads and stored blobs should be scanned
+func (s *MalwareScanService) Enabled() bool {
+	return s.cfg.EnableMalwareScan
+}
+
+// ScanFile scans a file on disk, such as a staged upload
+func (s *MalwareScanService) ScanFile(ctx context.Context, path string) (*malware.Result, error) {
+	if s.scanner == nil {
+		return nil, ErrMalwareScannerUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.MalwareScanTimeout)*time.Second)
+	defer cancel()
+	return s.scanner.ScanFile(ctx, path)
+}
+
+// MarkFilesScanned records that the blobs behind newly uploaded files were
+// found clean, so the background scan skips them until they are due for a rescan
+func (s *MalwareScanService) MarkFilesScanned(fileIDs []uuid.UUID) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	if err := s.markClean(s.db.Where("id IN (SELECT file_hash_id FROM files WHERE id IN ?)", fileIDs)); err != nil {
+		return err
+	}
+
+	// Other files sharing the blobs were waiting for the same scan
+	if err := s.db.Model(&models.File{}).
+		Where("file_hash_id IN (SELECT file_hash_id FROM files WHERE id IN ?)", fileIDs).
+		Where("processing_status IN ?", []models.ProcessingStatus{models.ProcessingStatusPendingScan, models.ProcessingStatusFailed}).
+		Update("processing_status", models.ProcessingStatusClean).Error; err != nil {
+		return fmt.Errorf("error updating processing status: %w", err)
+	}
+	return nil
+}
+
+// markClean records a clean scan of the blobs selected by query
+func (s *MalwareScanService) markClean(query *gorm.DB) error {
+	if err := query.Model(&models.FileHash{}).Updates(map[string]interface{}{
+		"malware_scanned_at": time.Now(),
+		"malware_signature":  "",
+	}).Error; err != nil {
+		return fmt.Errorf("error recording malware scan: %w", err)
+	}
+	return nil
+}
+
+// setProcessingStatus moves the files using a blob from one of the given
+// statuses to status. Quarantined files keep their status until an admin
+// reviews them.
+func (s *MalwareScanService) setProcessingStatus(fileHashID uuid.UUID, status models.ProcessingStatus, from ...models.ProcessingStatus) error {
+	if err := s.db.Model(&models.File{}).
+		Where("file_hash_id = ? AND processing_status IN ?", fileHashID, from).
+		Update("processing_status", status).Error; err != nil {
+		return fmt.Errorf("error updating processing status: %w", err)
+	}
+	return nil
+}
+
+// ClearPending marks files still waiting for a scan clean. It runs when
+// scanning is turned off, since nothing would ever scan them.
+func (s *MalwareScanService) ClearPending() error {
+	if err := s.db.Model(&models.File{}).
+		Where("processing_status IN ?", []models.ProcessingStatus{models.ProcessingStatusPendingScan, models.ProcessingStatusFailed}).
+		Update("processing_status", models.ProcessingStatusClean).Error; err != nil {
+		return fmt.Errorf("error clearing processing status: %w", err)
+	}
+	return nil
+}
+
+// Start runs scans of stored blobs in the background
+func (s *MalwareScanService) Start() {
+	interval := time.Duration(s.cfg.MalwareScanInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if scanned, infected := s.ScanStored(); scanned > 0 {
+				log.Printf("Malware scan: scanned %d blob(s), %d infected", scanned, infected)
+			}
+		}
+	}()
+}
+
+// ScanStored scans blobs that were never scanned, or are due for a rescan,
+// in batches until none are left. Archived blobs wait until they are
+// restored. It returns the number of blobs scanned and found infected.
+func (s *MalwareScanService) ScanStored() (scanned, infected int) {
+	if s.scanner == nil {
+		return 0, 0
+	}
+
+	// Blobs scanned before the pass started are not picked up again by it
+	started := time.Now()
+	for {
+		query := s.db.Where("storage_tier = ?", models.StorageTierHot).
+			Where("EXISTS (SELECT 1 FROM files WHERE files.file_hash_id = file_hashes.id AND files.deleted_at IS NULL)")
+		if s.cfg.MalwareRescanHours > 0 {
+			cutoff := started.Add(-time.Duration(s.cfg.MalwareRescanHours) * time.Hour)
+			query = query.Where("malware_scanned_at IS NULL OR malware_scanned_at < ?", cutoff)
+		} else {
+			query = query.Where("malware_scanned_at IS NULL")
+		}
+
+		var fileHashes []models.FileHash
+		if err := query.Order("malware_scanned_at ASC NULLS FIRST").
+			Limit(malwareScanBatchSize).
+			Find(&fileHashes).Error; err != nil {
+			log.Printf("Malware scan: failed to fetch blobs: %v", err)
+			return scanned, infected
+		}
+
+		progressed := false
+		for i := range fileHashes {
+			result, ok := s.scanBlob(&fileHashes[i])
+			if !ok {
+				continue
+			}
+			progressed = true
+			scanned++
+			if result.Infected {
+				infected++
+			}
+		}
+
+		if len(fileHashes) < malwareScanBatchSize || !progressed {
+			return scanned, infected
+		}
+	}
+}
+
+// scanBlob scans one stored blob and quarantines its files when infected. It
+// reports false when the blob could not be scanned; it is retried next pass.
+func (s *MalwareScanService) scanBlob(fileHash *models.FileHash) (*malware.Result, bool) {
+	path, release, ok := locateBlob(context.Background(), s.cfg, fileHash)
+	if !ok {
+		log.Printf("Malware scan: blob %s not found in storage", fileHash.Hash)
+		return nil, false
+	}
+	defer release()
+
+	result, err := s.ScanFile(context.Background(), path)
+	if err != nil {
+		log.Printf("Malware scan: failed to scan blob %s: %v", fileHash.Hash, err)
+		if err := s.setProcessingStatus(fileHash.ID, models.ProcessingStatusFailed, models.ProcessingStatusPendingScan); err != nil {
+			log.Printf("Malware scan: %v", err)
+		}
+		return nil, false
+	}
+
+	if !result.Infected {
+		if err := s.markClean(s.db.Where("id = ?", fileHash.ID)); err != nil {
+			log.Printf("Malware scan: %v", err)
+			return nil, false
+		}
+		if err := s.setProcessingStatus(fileHash.ID, models.ProcessingStatusClean, models.ProcessingStatusPendingScan, models.ProcessingStatusFailed); err != nil {
+			log.Printf("Malware scan: %v", err)
+		}
+		return result, true
+	}
+
+	if err := s.quarantineBlob(fileHash, result); err != nil {
+		log.Printf("Malware scan: failed to quarantine files of blob %s: %v", fileHash.Hash, err)
+		return nil, false
+	}
+	return result, true
+}
+
+// quarantineBlob locks every live file that uses an infected blob, then
+// notifies owners and admins. Files that already have a malware entry are
+// skipped, so a rescan neither duplicates a pending entry nor overrides an
+// admin's release
+func (s *MalwareScanService) quarantineBlob(fileHash *models.FileHash, result *malware.Result) error {
+	var entries []*models.FileQuarantine
+	var filenames []string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(fileHash).Updates(map[string]interface{}{
+			"malware_scanned_at": time.Now(),
+			"malware_signature":  result.Signature,
+		}).Error; err != nil {
+			return fmt.Errorf("error recording malware scan: %w", err)
+		}
+
+		var files []models.File
+		if err := tx.Where("file_hash_id = ?", fileHash.ID).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM file_quarantines fq
+				WHERE fq.file_id = files.id AND fq.source = ?)`, models.QuarantineSourceMalware).
+			Find(&files).Error; err != nil {
+			return fmt.Errorf("error fetching files: %w", err)
+		}
+
+		for _, file := range files {
+			entry, err := s.quarantineService.Quarantine(tx, QuarantineParams{
+				FileID:   file.ID,
+				OwnerID:  file.OwnerID,
+				Filename: file.OriginalFilename,
+				Source:   models.QuarantineSourceMalware,
+				Reason:   DescribeMalware(result),
+				Findings: MalwareFindings(result),
+			})
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			filenames = append(filenames, file.OriginalFilename)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		s.quarantineService.NotifyQuarantined(entry, filenames[i])
+	}
+	return nil
+}
+
+// DescribeMalware summarises a positive scan for users and reviewers
+func DescribeMalware(result *malware.Result) string {
+	if result.Signature == "" {
+		return "Malware detected"
+	}
+	return "Malware detected: " + result.Signature
+}
+
+// MalwareFindings converts a positive scan for storage with a quarantine entry
+func MalwareFindings(result *malware.Result) models.QuarantineFindings {
+	label := result.Signature
+	if label == "" {
+		label = "Malware"
+	}
+	return models.QuarantineFindings{{
+		Rule:   "malware",
+		Label:  label,
+		Count:  1,
+		Source: result.Scanner,
+	}}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrNotificationNotFound is returned when a notification does not exist for the user
+	ErrNotificationNotFound = errors.New("notification not found")
+	// ErrUnknownNotificationType is returned for email settings of a type
+	// that is never emailed
+	ErrUnknownNotificationType = errors.New("unknown notification type")
+)
+
+// EmailNotificationTypes are the notifications users also get by email while
+// SMTP is configured, unless they turn that off. All others are in-app only
+var EmailNotificationTypes = []models.NotificationType{
+	models.NotificationShareReceived,
+	models.NotificationDownload,
+	models.NotificationQuotaGrace,
+	models.NotificationNewLogin,
+	models.NotificationAccountLifecycle,
+}
+
+// integrationEvents maps the notifications that are also posted to Slack and
+// Teams integrations to their integration event
+var integrationEvents = map[models.NotificationType]models.IntegrationEvent{
+	models.NotificationShareReceived: models.IntegrationEventShareReceived,
+	models.NotificationQuotaGrace:    models.IntegrationEventQuotaAlert,
+	models.NotificationQuarantine:    models.IntegrationEventModeration,
+}
+
+// NotificationService delivers and manages in-app notifications, and passes
+// those integrations subscribe to on to them
+type NotificationService struct {
+	db           *gorm.DB
+	cfg          *config.Config
+	integrations *IntegrationService
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *gorm.DB, cfg *config.Config) *NotificationService {
+	return &NotificationService{db: db, cfg: cfg, integrations: NewIntegrationService(db, cfg)}
+}
+
+// NotifyParams describes a notification to deliver
+type NotifyParams struct {
+	Type     models.NotificationType
+	Severity models.NotificationSeverity
+	Title    string
+	Message  string
+	Details  models.NotificationDetails
+}
+
+// Notify delivers a notification to a single user
+func (s *NotificationService) Notify(userID uuid.UUID, params NotifyParams) error {
+	if err := s.db.Create(newNotification(userID, params)).Error; err != nil {
+		return err
+	}
+
+	if event, ok := integrationEvents[params.Type]; ok {
+		s.integrations.Dispatch(IntegrationMessage{
+			Event:  event,
+			UserID: userID,
+			Title:  params.Title,
+			Text:   params.Message,
+			URL:    s.cfg.PublicBaseURL,
+		})
+	}
+	return nil
+}
+
+// NotifyAdmins delivers a notification to every active admin
+func (s *NotificationService) NotifyAdmins(params NotifyParams) error {
+	var adminIDs []uuid.UUID
+	if err := s.db.Model(&models.User{}).
+		Where("role = ? AND is_active = true", models.RoleAdmin).
+		Pluck("id", &adminIDs).Error; err != nil {
+		return fmt.Errorf("error finding admins: %w", err)
+	}
+
+	if len(adminIDs) == 0 {
+		return nil
+	}
+
+	notifications := make([]*models.Notification, 0, len(adminIDs))
+	for _, adminID := range adminIDs {
+		notifications = append(notifications, newNotification(adminID, params))
+	}
+
+	return s.db.Create(&notifications).Error
+}
+
+// GetNotifications returns a user's notifications, newest first, and the unread count
+func (s *NotificationService) GetNotifications(userID uuid.UUID, unreadOnly bool, limit, offset int) ([]models.Notification, int64, error) {
+	var notifications []models.Notification
+
+	query := s.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&notifications).Error; err != nil {
+		return nil, 0, fmt.Errorf("error fetching notifications: %w", err)
+	}
+
+	var unread int64
+	if err := s.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&unread).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting notifications: %w", err)
+	}
+
+	return notifications, unread, nil
+}
+
+// MarkAsRead marks one of a user's notifications as read
+func (s *NotificationService) MarkAsRead(userID, notificationID uuid.UUID) error {
+	result := s.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", notificationID, userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("error updating notification: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		var count int64
+		s.db.Model(&models.Notification{}).Where("id = ? AND user_id = ?", notificationID, userID).Count(&count)
+		if count == 0 {
+			return ErrNotificationNotFound
+		}
+	}
+
+	return nil
+}
+
+// MarkAllAsRead marks all of a user's notifications as read
+func (s *NotificationService) MarkAllAsRead(userID uuid.UUID) error {
+	return s.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error
+}
+
+// EmailSettings returns whether the user gets each of EmailNotificationTypes
+// by email
+func (s *NotificationService) EmailSettings(userID uuid.UUID) (map[models.NotificationType]bool, error) {
+	var preferences []models.NotificationPreference
+	if err := s.db.Where("user_id = ?", userID).Find(&preferences).Error; err != nil {
+		return nil, fmt.Errorf("error fetching notification preferences: %w", err)
+	}
+
+	settings := make(map[models.NotificationType]bool, len(EmailNotificationTypes))
+	for _, t := range EmailNotificationTypes {
+		settings[t] = true
+	}
+	for _, preference := range preferences {
+		if _, ok := settings[preference.Type]; ok {
+			settings[preference.Type] = preference.Email
+		}
+	}
+	return settings, nil
+}
+
+// UpdateEmailSettings turns email on or off for the given types, leaving the
+// others as they are, and returns the resulting settings
+func (s *NotificationService) UpdateEmailSettings(userID uuid.UUID, changes map[models.NotificationType]bool) (map[models.NotificationType]bool, error) {
+	preferences := make([]models.NotificationPreference, 0, len(changes))
+	for t, email := range changes {
+		if !isEmailNotificationType(t) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownNotificationType, t)
+		}
+		preferences = append(preferences, models.NotificationPreference{UserID: userID, Type: t, Email: email})
+	}
+
+	if len(preferences) > 0 {
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"email", "updated_at"}),
+		}).Create(&preferences).Error; err != nil {
+			return nil, fmt.Errorf("error saving notification preferences: %w", err)
+		}
+	}
+
+	return s.EmailSettings(userID)
+}
+
+// WantsEmail reports whether the user gets notifications of a type by email.
+// Email is sent when the preference cannot be read, so security notices are
+// not lost
+func (s *NotificationService) WantsEmail(userID uuid.UUID, t models.NotificationType) bool {
+	if !isEmailNotificationType(t) {
+		return false
+	}
+	var emails []bool
+	if err := s.db.Model(&models.NotificationPreference{}).
+		Where("user_id = ? AND type = ?", userID, t).
+		Pluck("email", &emails).Error; err != nil || len(emails) == 0 {
+		return true
+	}
+	return emails[0]
+}
+
+func isEmailNotificationType(t models.NotificationType) bool {
+	for _, emailType := range EmailNotificationTypes {
+		if t == emailType {
+			return true
+		}
+	}
+	return false
+}
+
+func newNotification(userID uuid.UUID, params NotifyParams) *models.Notification {
+	severity := params.Severity
+	if severity == "" {
+		severity = models.NotificationSeverityInfo
+	}
+
+	return &models.Notification{
+		UserID:   userID,
+		Type:     params.Type,
+		Severity: severity,
+		Title:    params.Title,
+		Message:  params.Message,
+		Details:  params.Details,
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// orphanBlobBatchSize is how many unreferenced blobs a sweep loads at a time
+const orphanBlobBatchSize = 500
+
+// OrphanBlobService deletes the content of blobs no file references. Deleted
+// files still point at their hash row, so the row stays and only its content
+// goes; uploading the same content again stores it afresh.
+type OrphanBlobService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewOrphanBlobService creates a new orphan blob service
+func NewOrphanBlobService(db *gorm.DB, cfg *config.Config) *OrphanBlobService {
+	return &OrphanBlobService{db: db, cfg: cfg}
+}
+
+// Start sweeps unreferenced blobs in the background every interval
+func (s *OrphanBlobService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if removed := s.Sweep(); removed > 0 {
+				log.Printf("Orphan blobs: removed the content of %d unreferenced blobs", removed)
+			}
+		}
+	}()
+}
+
+// Sweep removes the content of every unreferenced blob in primary storage and
+// returns the number removed. Blobs that fail are logged and left for the
+// next sweep. Archived blobs are left to cold storage.
+func (s *OrphanBlobService) Sweep() int {
+	removed := 0
+	failed := []uuid.UUID{}
+	for {
+		query := s.db.Where("reference_count <= 0 AND content_removed_at IS NULL AND storage_tier = ?", models.StorageTierHot)
+		if len(failed) > 0 {
+			query = query.Where("id NOT IN ?", failed)
+		}
+		var fileHashes []models.FileHash
+		if err := query.Order("created_at ASC").Limit(orphanBlobBatchSize).Find(&fileHashes).Error; err != nil {
+			log.Printf("Orphan blobs: failed to fetch blobs: %v", err)
+			return removed
+		}
+
+		for i := range fileHashes {
+			ok, err := s.remove(&fileHashes[i])
+			if err != nil {
+				log.Printf("Orphan blobs: failed to remove blob %s: %v", fileHashes[i].Hash, err)
+				failed = append(failed, fileHashes[i].ID)
+				continue
+			}
+			if ok {
+				removed++
+			}
+		}
+
+		if len(fileHashes) < orphanBlobBatchSize {
+			return removed
+		}
+	}
+}
+
+// remove deletes one blob's content if nothing references it by now. The row
+// is marked first, which holds its lock until the content is gone: an upload
+// of the same content waits for the sweep, then finds the blob missing and
+// stores it again. The replica's copy goes too, and the blob is replicated
+// afresh once referenced again.
+func (s *OrphanBlobService) remove(fileHash *models.FileHash) (bool, error) {
+	store, err := OpenBlobStore(s.cfg)
+	if err != nil {
+		return false, err
+	}
+
+	removed := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.FileHash{}).
+			Where("id = ? AND reference_count <= 0 AND content_removed_at IS NULL", fileHash.ID).
+			Updates(map[string]interface{}{
+				"content_removed_at":   time.Now(),
+				"replication_status":   models.ReplicationPending,
+				"replication_attempts": 0,
+				"replication_error":    "",
+				"replicated_at":        nil,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("error marking blob: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		for _, p := range blobPathCandidates(fileHash.StoragePath) {
+			if err := store.Delete(context.Background(), p); err != nil {
+				return fmt.Errorf("error deleting %s: %w", p, err)
+			}
+			if s.cfg.ReplicaStoragePath == "" {
+				continue
+			}
+			if err := os.Remove(filepath.Join(s.cfg.ReplicaStoragePath, p)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("error deleting replica of %s: %w", p, err)
+			}
+		}
+		removed = true
+		return nil
+	})
+	return removed, err
+}
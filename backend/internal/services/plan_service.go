@@ -0,0 +1,246 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrInvalidPlan is returned when a plan request fails validation
+	ErrInvalidPlan = errors.New("invalid plan")
+	// ErrPlanInUse is returned when deleting a plan that still has users
+	ErrPlanInUse = errors.New("plan is still assigned to users")
+	// ErrPlanUserNotFound is returned when assigning a plan to a missing user
+	ErrPlanUserNotFound = errors.New("user not found")
+	// ErrPublicSharingNotAllowed is returned when a user's plan forbids public sharing
+	ErrPublicSharingNotAllowed = apperrors.ErrForbidden.WithCode("PUBLIC_SHARING_NOT_ALLOWED", "your plan does not allow public sharing")
+)
+
+// PlanService manages quota plans and the users assigned to them
+type PlanService struct {
+	db *gorm.DB
+}
+
+// NewPlanService creates a new plan service
+func NewPlanService(db *gorm.DB) *PlanService {
+	return &PlanService{db: db}
+}
+
+// PlanRequest is the admin-editable part of a plan
+type PlanRequest struct {
+	Name               string `json:"name" binding:"required,max=100"`
+	Description        string `json:"description"`
+	StorageQuota       int64  `json:"storage_quota" binding:"min=0"`
+	MaxFileSize        int64  `json:"max_file_size" binding:"min=0"`
+	RateLimit          int    `json:"rate_limit" binding:"min=0"`
+	RateLimitBurst     int    `json:"rate_limit_burst" binding:"min=0"`
+	AllowPublicSharing *bool  `json:"allow_public_sharing"`
+	IsDefault          bool   `json:"is_default"`
+}
+
+// PlanWithUsage is a plan together with the number of users assigned to it
+type PlanWithUsage struct {
+	models.Plan
+	UserCount int64 `json:"user_count"`
+}
+
+// MigratePlanRequest selects the users to move onto a plan, either every
+// user on another plan or an explicit list
+type MigratePlanRequest struct {
+	FromPlanID *uuid.UUID  `json:"from_plan_id"`
+	UserIDs    []uuid.UUID `json:"user_ids"`
+}
+
+// ListPlans returns every plan with its user count, smallest quota first
+func (s *PlanService) ListPlans() ([]PlanWithUsage, error) {
+	var plans []PlanWithUsage
+	if err := s.db.Model(&models.Plan{}).
+		Select("plans.*, (SELECT COUNT(*) FROM users WHERE users.plan_id = plans.id AND users.deleted_at IS NULL) AS user_count").
+		Order("plans.storage_quota ASC, plans.name ASC").
+		Scan(&plans).Error; err != nil {
+		return nil, fmt.Errorf("error fetching plans: %w", err)
+	}
+	return plans, nil
+}
+
+// DefaultPlan returns the plan assigned to new users, or nil when none is set
+func (s *PlanService) DefaultPlan() (*models.Plan, error) {
+	var plan models.Plan
+	if err := s.db.Where("is_default = true").First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching default plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// CreatePlan adds a new plan
+func (s *PlanService) CreatePlan(req PlanRequest) (*models.Plan, error) {
+	plan := &models.Plan{AllowPublicSharing: true}
+	if err := applyPlanRequest(plan, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if plan.IsDefault {
+			if err := clearDefaultPlan(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Create(plan).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating plan: %w", err)
+	}
+	return plan, nil
+}
+
+// UpdatePlan replaces the settings of a plan. A changed quota is applied to
+// every user on the plan
+func (s *PlanService) UpdatePlan(id uuid.UUID, req PlanRequest) (*models.Plan, error) {
+	var plan models.Plan
+	if err := s.db.First(&plan, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := applyPlanRequest(&plan, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if plan.IsDefault {
+			if err := clearDefaultPlan(tx.Where("id <> ?", plan.ID)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&plan).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("plan_id = ?", plan.ID).
+			Update("storage_quota", plan.StorageQuota).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// DeletePlan removes a plan that no user is assigned to
+func (s *PlanService) DeletePlan(id uuid.UUID) error {
+	var users int64
+	if err := s.db.Model(&models.User{}).Where("plan_id = ?", id).Count(&users).Error; err != nil {
+		return fmt.Errorf("error counting plan users: %w", err)
+	}
+	if users > 0 {
+		return ErrPlanInUse
+	}
+
+	result := s.db.Delete(&models.Plan{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("error deleting plan: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AssignPlan moves a single user onto a plan and applies its quota
+func (s *PlanService) AssignPlan(userID, planID uuid.UUID) (*models.Plan, error) {
+	var plan models.Plan
+	if err := s.db.First(&plan, "id = ?", planID).Error; err != nil {
+		return nil, err
+	}
+
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"plan_id":       plan.ID,
+		"storage_quota": plan.StorageQuota,
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("error assigning plan: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrPlanUserNotFound
+	}
+	return &plan, nil
+}
+
+// MigrateUsers moves users onto a plan in bulk and returns how many moved
+func (s *PlanService) MigrateUsers(planID uuid.UUID, req MigratePlanRequest) (*models.Plan, int64, error) {
+	if req.FromPlanID == nil && len(req.UserIDs) == 0 {
+		return nil, 0, fmt.Errorf("%w: from_plan_id or user_ids is required", ErrInvalidPlan)
+	}
+	if req.FromPlanID != nil && *req.FromPlanID == planID {
+		return nil, 0, fmt.Errorf("%w: users are already on this plan", ErrInvalidPlan)
+	}
+
+	var plan models.Plan
+	if err := s.db.First(&plan, "id = ?", planID).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Model(&models.User{})
+	if req.FromPlanID != nil {
+		query = query.Where("plan_id = ?", *req.FromPlanID)
+	}
+	if len(req.UserIDs) > 0 {
+		query = query.Where("id IN ?", req.UserIDs)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"plan_id":       plan.ID,
+		"storage_quota": plan.StorageQuota,
+	})
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("error migrating users: %w", result.Error)
+	}
+	return &plan, result.RowsAffected, nil
+}
+
+// CheckPublicSharing returns ErrPublicSharingNotAllowed when the user's plan
+// forbids public files and share links
+func CheckPublicSharing(db *gorm.DB, userID uuid.UUID) error {
+	var user models.User
+	if err := db.Select("id", "plan_id").Preload("Plan").First(&user, "id = ?", userID).Error; err != nil {
+		return fmt.Errorf("error loading user plan: %w", err)
+	}
+	if !user.AllowsPublicSharing() {
+		return ErrPublicSharingNotAllowed
+	}
+	return nil
+}
+
+func clearDefaultPlan(tx *gorm.DB) error {
+	return tx.Model(&models.Plan{}).Where("is_default = true").Update("is_default", false).Error
+}
+
+func applyPlanRequest(plan *models.Plan, req PlanRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPlan)
+	}
+	if req.StorageQuota <= 0 {
+		return fmt.Errorf("%w: storage_quota must be positive", ErrInvalidPlan)
+	}
+
+	plan.Name = name
+	plan.Description = req.Description
+	plan.StorageQuota = req.StorageQuota
+	plan.MaxFileSize = req.MaxFileSize
+	plan.RateLimit = req.RateLimit
+	plan.RateLimitBurst = req.RateLimitBurst
+	if req.AllowPublicSharing != nil {
+		plan.AllowPublicSharing = *req.AllowPublicSharing
+	}
+	plan.IsDefault = req.IsDefault
+	return nil
+}
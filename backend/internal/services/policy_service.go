@@ -0,0 +1,287 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// PolicyDocumentTypes lists the policies users must accept, in display order
+var PolicyDocumentTypes = []models.PolicyDocumentType{
+	models.PolicyDocumentTerms,
+	models.PolicyDocumentPrivacy,
+}
+
+var (
+	// ErrInvalidPolicyDocument is returned when a policy document request fails validation
+	ErrInvalidPolicyDocument = errors.New("invalid policy document")
+	// ErrPolicyVersionExists is returned when publishing a version that already exists
+	ErrPolicyVersionExists = errors.New("policy version already exists")
+	// ErrPolicyNotCurrent is returned when accepting a document that is not the current version
+	ErrPolicyNotCurrent = errors.New("policy document is not the current version")
+)
+
+// PolicyService publishes terms-of-service and privacy policy versions and
+// tracks which versions each user has accepted
+type PolicyService struct {
+	db *gorm.DB
+}
+
+// NewPolicyService creates a new policy service
+func NewPolicyService(db *gorm.DB) *PolicyService {
+	return &PolicyService{db: db}
+}
+
+// PolicyDocumentRequest publishes a new version of a policy
+type PolicyDocumentRequest struct {
+	Type        models.PolicyDocumentType `json:"type" binding:"required,oneof=terms privacy"`
+	Version     string                    `json:"version" binding:"required,max=50"`
+	Title       string                    `json:"title" binding:"required,max=255"`
+	Content     string                    `json:"content" binding:"required"`
+	EffectiveAt *time.Time                `json:"effective_at"`
+}
+
+// PolicyStatus is a current policy document and whether a user has accepted it
+type PolicyStatus struct {
+	Document   models.PolicyDocument `json:"document"`
+	Accepted   bool                  `json:"accepted"`
+	AcceptedAt *time.Time            `json:"accepted_at,omitempty"`
+}
+
+// PolicyDocumentSummary is a published policy version with how many users accepted it
+type PolicyDocumentSummary struct {
+	models.PolicyDocument
+	IsCurrent       bool  `json:"is_current"`
+	AcceptanceCount int64 `json:"acceptance_count"`
+}
+
+// CurrentPolicies returns the newest effective version of each policy type.
+// Types with no published version are skipped.
+func (s *PolicyService) CurrentPolicies() ([]models.PolicyDocument, error) {
+	now := time.Now()
+	var current []models.PolicyDocument
+
+	for _, docType := range PolicyDocumentTypes {
+		var doc models.PolicyDocument
+		err := s.db.Where("type = ? AND effective_at <= ?", docType, now).
+			Order("effective_at DESC, created_at DESC").
+			First(&doc).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error fetching current %s policy: %w", docType, err)
+		}
+		current = append(current, doc)
+	}
+
+	return current, nil
+}
+
+// PolicyStatusForUser returns the current policies with the user's acceptance of each
+func (s *PolicyService) PolicyStatusForUser(userID uuid.UUID) ([]PolicyStatus, error) {
+	current, err := s.CurrentPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := s.acceptedDocuments(userID, current)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]PolicyStatus, len(current))
+	for i, doc := range current {
+		statuses[i] = PolicyStatus{Document: doc}
+		if acceptedAt, ok := accepted[doc.ID]; ok {
+			statuses[i].Accepted = true
+			statuses[i].AcceptedAt = &acceptedAt
+		}
+	}
+	return statuses, nil
+}
+
+// PendingPolicies returns the current policies the user has not yet accepted
+func (s *PolicyService) PendingPolicies(userID uuid.UUID) ([]models.PolicyDocument, error) {
+	current, err := s.CurrentPolicies()
+	if err != nil {
+		return nil, err
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	accepted, err := s.acceptedDocuments(userID, current)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []models.PolicyDocument
+	for _, doc := range current {
+		if _, ok := accepted[doc.ID]; !ok {
+			pending = append(pending, doc)
+		}
+	}
+	return pending, nil
+}
+
+// AcceptPolicies records the user's acceptance of current policy documents.
+// Accepting a document twice keeps the original acceptance.
+func (s *PolicyService) AcceptPolicies(userID uuid.UUID, documentIDs []uuid.UUID, ipAddress, userAgent string) ([]models.PolicyAcceptance, error) {
+	current, err := s.CurrentPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	currentByID := make(map[uuid.UUID]models.PolicyDocument, len(current))
+	for _, doc := range current {
+		currentByID[doc.ID] = doc
+	}
+
+	acceptances := make([]models.PolicyAcceptance, 0, len(documentIDs))
+	for _, id := range documentIDs {
+		doc, ok := currentByID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPolicyNotCurrent, id)
+		}
+		acceptances = append(acceptances, models.PolicyAcceptance{
+			UserID:     userID,
+			DocumentID: doc.ID,
+			Type:       doc.Type,
+			Version:    doc.Version,
+			IPAddress:  ipAddress,
+			UserAgent:  userAgent,
+		})
+	}
+
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "document_id"}},
+		DoNothing: true,
+	}).Create(&acceptances).Error; err != nil {
+		return nil, fmt.Errorf("error recording policy acceptance: %w", err)
+	}
+
+	return acceptances, nil
+}
+
+// PublishPolicy adds a new version of a policy. Users must accept it once its
+// effective date passes.
+func (s *PolicyService) PublishPolicy(req PolicyDocumentRequest, createdBy uuid.UUID) (*models.PolicyDocument, error) {
+	doc := &models.PolicyDocument{
+		Type:        req.Type,
+		Version:     strings.TrimSpace(req.Version),
+		Title:       strings.TrimSpace(req.Title),
+		Content:     req.Content,
+		EffectiveAt: time.Now(),
+		CreatedBy:   &createdBy,
+	}
+	if req.EffectiveAt != nil {
+		doc.EffectiveAt = *req.EffectiveAt
+	}
+
+	if doc.Version == "" || doc.Title == "" || strings.TrimSpace(doc.Content) == "" {
+		return nil, fmt.Errorf("%w: version, title and content must not be blank", ErrInvalidPolicyDocument)
+	}
+
+	var existing int64
+	if err := s.db.Model(&models.PolicyDocument{}).
+		Where("type = ? AND version = ?", doc.Type, doc.Version).
+		Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("error checking policy versions: %w", err)
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrPolicyVersionExists, doc.Type, doc.Version)
+	}
+
+	if err := s.db.Create(doc).Error; err != nil {
+		return nil, fmt.Errorf("error publishing policy: %w", err)
+	}
+	return doc, nil
+}
+
+// ListPolicies returns every published version, newest first, with acceptance counts
+func (s *PolicyService) ListPolicies(docType string) ([]PolicyDocumentSummary, error) {
+	var docs []models.PolicyDocument
+	query := s.db.Order("type ASC, effective_at DESC, created_at DESC")
+	if docType != "" {
+		query = query.Where("type = ?", docType)
+	}
+	if err := query.Find(&docs).Error; err != nil {
+		return nil, fmt.Errorf("error fetching policies: %w", err)
+	}
+
+	var counts []struct {
+		DocumentID uuid.UUID
+		Count      int64
+	}
+	if err := s.db.Model(&models.PolicyAcceptance{}).
+		Select("document_id, COUNT(*) AS count").
+		Group("document_id").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("error counting policy acceptances: %w", err)
+	}
+	countByID := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		countByID[c.DocumentID] = c.Count
+	}
+
+	current, err := s.CurrentPolicies()
+	if err != nil {
+		return nil, err
+	}
+	currentIDs := make(map[uuid.UUID]bool, len(current))
+	for _, doc := range current {
+		currentIDs[doc.ID] = true
+	}
+
+	summaries := make([]PolicyDocumentSummary, len(docs))
+	for i, doc := range docs {
+		summaries[i] = PolicyDocumentSummary{
+			PolicyDocument:  doc,
+			IsCurrent:       currentIDs[doc.ID],
+			AcceptanceCount: countByID[doc.ID],
+		}
+	}
+	return summaries, nil
+}
+
+// UserAcceptances returns every policy version a user has accepted, newest first
+func (s *PolicyService) UserAcceptances(userID uuid.UUID) ([]models.PolicyAcceptance, error) {
+	var acceptances []models.PolicyAcceptance
+	if err := s.db.Where("user_id = ?", userID).
+		Order("accepted_at DESC").
+		Find(&acceptances).Error; err != nil {
+		return nil, fmt.Errorf("error fetching policy acceptances: %w", err)
+	}
+	return acceptances, nil
+}
+
+// acceptedDocuments maps the documents the user has accepted to when they accepted them
+func (s *PolicyService) acceptedDocuments(userID uuid.UUID, docs []models.PolicyDocument) (map[uuid.UUID]time.Time, error) {
+	accepted := make(map[uuid.UUID]time.Time)
+	if len(docs) == 0 {
+		return accepted, nil
+	}
+
+	ids := make([]uuid.UUID, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+
+	var acceptances []models.PolicyAcceptance
+	if err := s.db.Where("user_id = ? AND document_id IN ?", userID, ids).
+		Find(&acceptances).Error; err != nil {
+		return nil, fmt.Errorf("error fetching policy acceptances: %w", err)
+	}
+	for _, a := range acceptances {
+		accepted[a.DocumentID] = a.AcceptedAt
+	}
+	return accepted, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/cdn"
+)
+
+// PrewarmService gets a file ready for outside visitors as soon as it is made
+// public or shared by link: small content is loaded into the hot blob cache
+// and the blob is pushed to the CDN origin, so the first visitor does not pay
+// for a cold disk read or an origin miss. Warming runs in the background and
+// never fails the request that triggered it.
+type PrewarmService struct {
+	db      *gorm.DB
+	cfg     *config.Config
+	streams *FileStreamService
+	origin  *cdn.Origin
+}
+
+// NewPrewarmService creates a new pre-warm service
+func NewPrewarmService(db *gorm.DB, cfg *config.Config) *PrewarmService {
+	s := &PrewarmService{
+		db:      db,
+		cfg:     cfg,
+		streams: NewFileStreamService(db, cfg),
+	}
+	if cfg.CDNOriginURL != "" {
+		s.origin = cdn.NewOrigin(cfg.CDNOriginURL, cfg.CDNOriginToken, time.Duration(cfg.CDNPushTimeout)*time.Second)
+	}
+	return s
+}
+
+// WarmShareLink marks a new share link pending and warms its file in the
+// background, recording the outcome on the link
+func (s *PrewarmService) WarmShareLink(link *models.ShareLink) {
+	if !s.cfg.EnablePrewarm {
+		return
+	}
+	if err := s.db.Model(link).Update("warm_status", models.WarmStatusPending).Error; err != nil {
+		log.Printf("Prewarm: failed to mark share link %s pending: %v", link.ID, err)
+		return
+	}
+	link.WarmStatus = models.WarmStatusPending
+
+	linkID, fileID := link.ID, link.FileID
+	go func() {
+		updates := map[string]interface{}{
+			"warm_status": models.WarmStatusWarm,
+			"warmed_at":   time.Now(),
+			"warm_error":  "",
+		}
+		if err := s.warm(fileID); err != nil {
+			log.Printf("Prewarm: failed to warm file %s for share link %s: %v", fileID, linkID, err)
+			updates = map[string]interface{}{
+				"warm_status": models.WarmStatusFailed,
+				"warm_error":  err.Error(),
+			}
+		}
+		s.db.Model(&models.ShareLink{}).Where("id = ?", linkID).Updates(updates)
+	}()
+}
+
+// WarmFile warms a file that was just made public in the background
+func (s *PrewarmService) WarmFile(fileID uuid.UUID) {
+	if !s.cfg.EnablePrewarm {
+		return
+	}
+	go func() {
+		if err := s.warm(fileID); err != nil {
+			log.Printf("Prewarm: failed to warm public file %s: %v", fileID, err)
+		}
+	}()
+}
+
+// warm loads a file's content into the hot cache and pushes it to the CDN
+// origin. Quarantined files are never pushed.
+func (s *PrewarmService) warm(fileID uuid.UUID) error {
+	var file models.File
+	if err := s.db.Preload("FileHash").First(&file, "id = ?", fileID).Error; err != nil {
+		return fmt.Errorf("error fetching file: %w", err)
+	}
+	if file.IsQuarantined {
+		return fmt.Errorf("file is quarantined")
+	}
+
+	stream, err := s.streams.Open(&file)
+	if err != nil {
+		return err
+	}
+	if err := s.streams.Prefetch(stream); err != nil {
+		return err
+	}
+
+	if s.origin == nil {
+		return nil
+	}
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	path, done, err := s.streams.LocalPath(context.Background(), stream)
+	if err != nil {
+		return err
+	}
+	defer done()
+	if err := s.origin.Push(context.Background(), stream.Hash.Hash, path, mimeType); err != nil {
+		return fmt.Errorf("error pushing to CDN origin: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrQuarantineNotFound is returned when a quarantine entry does not exist
+	ErrQuarantineNotFound = errors.New("quarantine entry not found")
+	// ErrQuarantineReviewed is returned when a quarantine entry has already been reviewed
+	ErrQuarantineReviewed = errors.New("quarantine entry has already been reviewed")
+	// ErrQuarantineRetained is returned when deleting a quarantined file still under WORM retention
+	ErrQuarantineRetained = errors.New("quarantined file is under retention and cannot be deleted")
+)
+
+// QuarantineService locks flagged files and manages the admin review queue
+type QuarantineService struct {
+	db                  *gorm.DB
+	notificationService *NotificationService
+	auditService        *AuditService
+}
+
+// NewQuarantineService creates a new quarantine service
+func NewQuarantineService(db *gorm.DB, notificationService *NotificationService, auditService *AuditService) *QuarantineService {
+	return &QuarantineService{
+		db:                  db,
+		notificationService: notificationService,
+		auditService:        auditService,
+	}
+}
+
+// QuarantineParams describes why a file is being quarantined
+type QuarantineParams struct {
+	FileID   uuid.UUID
+	OwnerID  uuid.UUID
+	Filename string
+	Source   models.QuarantineSource
+	Reason   string
+	Findings models.QuarantineFindings
+}
+
+// QuarantineReview is an admin's decision on a quarantine entry, with the
+// request details recorded in the audit log
+type QuarantineReview struct {
+	ReviewerID uuid.UUID
+	Note       string
+	IPAddress  string
+	UserAgent  string
+}
+
+// Quarantine locks a file, queues it for review and records it in the
+// owner's audit trail. It runs inside the caller's transaction so the file is
+// never visible unlocked.
+func (s *QuarantineService) Quarantine(tx *gorm.DB, params QuarantineParams) (*models.FileQuarantine, error) {
+	entry := &models.FileQuarantine{
+		FileID:   params.FileID,
+		OwnerID:  params.OwnerID,
+		Source:   params.Source,
+		Reason:   params.Reason,
+		Findings: params.Findings,
+		Status:   models.QuarantineStatusPending,
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return nil, fmt.Errorf("error creating quarantine entry: %w", err)
+	}
+
+	if err := tx.Model(&models.File{}).Where("id = ?", params.FileID).
+		Updates(map[string]interface{}{
+			"is_quarantined":    true,
+			"processing_status": models.ProcessingStatusQuarantined,
+		}).Error; err != nil {
+		return nil, fmt.Errorf("error quarantining file: %w", err)
+	}
+
+	if s.auditService != nil {
+		if err := s.auditService.Enqueue(tx, LogActivityParams{
+			UserID:       entry.OwnerID,
+			Action:       models.AuditActionQuarantine,
+			ResourceType: models.AuditResourceFile,
+			ResourceID:   &entry.FileID,
+			ResourceName: &params.Filename,
+			Details: models.AuditLogDetails{
+				"quarantine_id": entry.ID,
+				"source":        entry.Source,
+				"reason":        entry.Reason,
+				"timestamp":     time.Now().Unix(),
+			},
+			Status: models.AuditStatusSuccess,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// NotifyQuarantined tells the owner and admins about a new quarantine entry.
+// Call it after the transaction that created the entry has committed.
+func (s *QuarantineService) NotifyQuarantined(entry *models.FileQuarantine, filename string) {
+	if s.notificationService == nil {
+		return
+	}
+
+	details := models.NotificationDetails{
+		"quarantine_id": entry.ID,
+		"file_id":       entry.FileID,
+		"filename":      filename,
+		"source":        entry.Source,
+	}
+
+	if err := s.notificationService.Notify(entry.OwnerID, NotifyParams{
+		Type:     models.NotificationQuarantine,
+		Severity: models.NotificationSeverityWarning,
+		Title:    "File quarantined",
+		Message:  fmt.Sprintf("%s was quarantined pending review: %s", filename, entry.Reason),
+		Details:  details,
+	}); err != nil {
+		log.Printf("Failed to notify owner of quarantined file %s: %v", entry.FileID, err)
+	}
+
+	if err := s.notificationService.NotifyAdmins(NotifyParams{
+		Type:     models.NotificationQuarantine,
+		Severity: models.NotificationSeverityWarning,
+		Title:    "File awaiting quarantine review",
+		Message:  fmt.Sprintf("%s was quarantined: %s", filename, entry.Reason),
+		Details:  details,
+	}); err != nil {
+		log.Printf("Failed to notify admins of quarantined file %s: %v", entry.FileID, err)
+	}
+}
+
+// ListQuarantine returns review queue entries, newest first. An empty status
+// returns every entry.
+func (s *QuarantineService) ListQuarantine(status models.QuarantineStatus, limit, offset int) ([]models.FileQuarantine, int64, error) {
+	query := s.db.Model(&models.FileQuarantine{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting quarantine entries: %w", err)
+	}
+
+	var entries []models.FileQuarantine
+	if err := query.Preload("File").Preload("Owner").
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("error fetching quarantine entries: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// Release clears a quarantine entry and unlocks the file unless another
+// pending entry still holds it
+func (s *QuarantineService) Release(id uuid.UUID, review QuarantineReview) (*models.FileQuarantine, error) {
+	return s.review(id, review, models.QuarantineStatusReleased, models.AuditActionRelease)
+}
+
+// Reject confirms the file should stay locked. The owner can still delete it.
+func (s *QuarantineService) Reject(id uuid.UUID, review QuarantineReview) (*models.FileQuarantine, error) {
+	return s.review(id, review, models.QuarantineStatusRejected, models.AuditActionReject)
+}
+
+// Delete removes a quarantined file as if its owner had deleted it and closes
+// every open entry for the file. Files under WORM retention cannot be deleted.
+func (s *QuarantineService) Delete(id uuid.UUID, review QuarantineReview) (*models.FileQuarantine, error) {
+	var entry models.FileQuarantine
+	reviewerID := review.ReviewerID
+	note := review.Note
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("File").First(&entry, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrQuarantineNotFound
+			}
+			return fmt.Errorf("error fetching quarantine entry: %w", err)
+		}
+
+		if entry.Status != models.QuarantineStatusPending && entry.Status != models.QuarantineStatusRejected {
+			return ErrQuarantineReviewed
+		}
+		if entry.File != nil && entry.File.RetentionLocked() {
+			return ErrQuarantineRetained
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.FileQuarantine{}).
+			Where("file_id = ? AND status IN ?", entry.FileID,
+				[]models.QuarantineStatus{models.QuarantineStatusPending, models.QuarantineStatusRejected}).
+			Updates(map[string]interface{}{
+				"status":      models.QuarantineStatusDeleted,
+				"reviewed_by": reviewerID,
+				"reviewed_at": now,
+				"review_note": note,
+			}).Error; err != nil {
+			return fmt.Errorf("error updating quarantine entries: %w", err)
+		}
+		entry.Status = models.QuarantineStatusDeleted
+		entry.ReviewedBy = &reviewerID
+		entry.ReviewedAt = &now
+		entry.ReviewNote = note
+
+		if err := s.logReview(tx, &entry, review, models.AuditActionDelete); err != nil {
+			return err
+		}
+
+		if entry.File == nil || entry.File.IsDeleted() {
+			return nil
+		}
+		return deleteQuarantinedFile(tx, &entry, reviewerID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyReviewed(&entry)
+	return &entry, nil
+}
+
+// deleteQuarantinedFile soft-deletes a quarantined file, releases its blob
+// reference and updates the owner's storage statistics, matching a delete by
+// the owner
+func deleteQuarantinedFile(tx *gorm.DB, entry *models.FileQuarantine, reviewerID uuid.UUID, now time.Time) error {
+	file := entry.File
+	if err := tx.Model(file).Updates(map[string]interface{}{
+		"deleted_at": now,
+		"updated_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("error deleting file: %w", err)
+	}
+
+	if err := RecordFileEvent(tx, FileEventParams{
+		FileID:  file.ID,
+		OwnerID: file.OwnerID,
+		ActorID: &reviewerID,
+		Type:    models.FileEventDeleted,
+		Payload: models.FileEventPayload{
+			"filename":      file.OriginalFilename,
+			"folder_id":     file.FolderID,
+			"quarantine_id": entry.ID,
+			"reason":        entry.Reason,
+		},
+	}); err != nil {
+		return err
+	}
+
+	var fileHash models.FileHash
+	if err := tx.First(&fileHash, "id = ?", file.FileHashID).Error; err != nil {
+		return fmt.Errorf("error fetching file hash: %w", err)
+	}
+
+	newRefCount := fileHash.ReferenceCount - 1
+	if err := tx.Model(&fileHash).Update("reference_count", newRefCount).Error; err != nil {
+		return fmt.Errorf("error updating reference count: %w", err)
+	}
+
+	actualStorageFreed := int64(0)
+	if newRefCount <= 0 {
+		actualStorageFreed = file.Size
+	}
+	released, err := ContentCharge(tx, file.OwnerID, file.FileHashID, file.ID, file.Size)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", file.OwnerID).Updates(map[string]interface{}{
+		"storage_used":         gorm.Expr("storage_used - ?", released),
+		"actual_storage_bytes": gorm.Expr("actual_storage_bytes - ?", actualStorageFreed),
+	}).Error; err != nil {
+		return fmt.Errorf("error updating user storage stats: %w", err)
+	}
+	return AdjustFolderStats(tx, file.FolderID, -1, -file.Size)
+}
+
+func (s *QuarantineService) review(id uuid.UUID, review QuarantineReview, status models.QuarantineStatus, action models.AuditLogAction) (*models.FileQuarantine, error) {
+	var entry models.FileQuarantine
+	reviewerID := review.ReviewerID
+	note := review.Note
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("File").First(&entry, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrQuarantineNotFound
+			}
+			return fmt.Errorf("error fetching quarantine entry: %w", err)
+		}
+
+		if entry.Status != models.QuarantineStatusPending {
+			return ErrQuarantineReviewed
+		}
+
+		now := time.Now()
+		if err := tx.Model(&entry).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": reviewerID,
+			"reviewed_at": now,
+			"review_note": note,
+		}).Error; err != nil {
+			return fmt.Errorf("error updating quarantine entry: %w", err)
+		}
+		entry.Status = status
+		entry.ReviewedBy = &reviewerID
+		entry.ReviewedAt = &now
+		entry.ReviewNote = note
+
+		if err := s.logReview(tx, &entry, review, action); err != nil {
+			return err
+		}
+
+		if status != models.QuarantineStatusReleased {
+			return nil
+		}
+
+		var blocking int64
+		if err := tx.Model(&models.FileQuarantine{}).
+			Where("file_id = ? AND id <> ? AND status IN ?", entry.FileID, entry.ID,
+				[]models.QuarantineStatus{models.QuarantineStatusPending, models.QuarantineStatusRejected}).
+			Count(&blocking).Error; err != nil {
+			return fmt.Errorf("error checking quarantine entries: %w", err)
+		}
+
+		// A release is an admin's judgement that the content is safe
+		if blocking == 0 {
+			if err := tx.Model(&models.File{}).Where("id = ?", entry.FileID).
+				Updates(map[string]interface{}{
+					"is_quarantined":    false,
+					"processing_status": models.ProcessingStatusClean,
+				}).Error; err != nil {
+				return fmt.Errorf("error releasing file: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyReviewed(&entry)
+	return &entry, nil
+}
+
+// logReview records an admin's quarantine decision in the review's transaction
+func (s *QuarantineService) logReview(tx *gorm.DB, entry *models.FileQuarantine, review QuarantineReview, action models.AuditLogAction) error {
+	if s.auditService == nil {
+		return nil
+	}
+
+	params := LogActivityParams{
+		UserID:       review.ReviewerID,
+		Action:       action,
+		ResourceType: models.AuditResourceFile,
+		ResourceID:   &entry.FileID,
+		Details: models.AuditLogDetails{
+			"quarantine_id": entry.ID,
+			"owner_id":      entry.OwnerID,
+			"source":        entry.Source,
+			"status":        entry.Status,
+			"note":          entry.ReviewNote,
+			"timestamp":     time.Now().Unix(),
+		},
+		Status: models.AuditStatusSuccess,
+	}
+	if entry.File != nil {
+		params.ResourceName = &entry.File.OriginalFilename
+	}
+	if review.IPAddress != "" {
+		params.IPAddress = &review.IPAddress
+	}
+	if review.UserAgent != "" {
+		params.UserAgent = &review.UserAgent
+	}
+
+	return s.auditService.Enqueue(tx, params)
+}
+
+// notifyReviewed tells the owner how their quarantined file was reviewed
+func (s *QuarantineService) notifyReviewed(entry *models.FileQuarantine) {
+	if s.notificationService == nil {
+		return
+	}
+
+	filename := entry.FileID.String()
+	if entry.File != nil {
+		filename = entry.File.OriginalFilename
+	}
+
+	message := fmt.Sprintf("%s was released from quarantine", filename)
+	severity := models.NotificationSeverityInfo
+	switch entry.Status {
+	case models.QuarantineStatusRejected:
+		message = fmt.Sprintf("%s will remain quarantined", filename)
+		severity = models.NotificationSeverityWarning
+	case models.QuarantineStatusDeleted:
+		message = fmt.Sprintf("%s was deleted by an administrator", filename)
+		severity = models.NotificationSeverityWarning
+	}
+
+	if err := s.notificationService.Notify(entry.OwnerID, NotifyParams{
+		Type:     models.NotificationQuarantine,
+		Severity: severity,
+		Title:    "Quarantine review complete",
+		Message:  message,
+		Details: models.NotificationDetails{
+			"quarantine_id": entry.ID,
+			"file_id":       entry.FileID,
+			"status":        entry.Status,
+		},
+	}); err != nil {
+		log.Printf("Failed to notify owner of quarantine review for %s: %v", entry.FileID, err)
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// QuotaGraceService lets users exceed their storage quota by a configured
+// percentage for a limited time, and revokes the overage once the window ends
+type QuotaGraceService struct {
+	db                  *gorm.DB
+	cfg                 *config.Config
+	notificationService *NotificationService
+	mailService         *MailService
+}
+
+// NewQuotaGraceService creates a new quota grace service
+func NewQuotaGraceService(db *gorm.DB, cfg *config.Config, notificationService *NotificationService, mailService *MailService) *QuotaGraceService {
+	return &QuotaGraceService{
+		db:                  db,
+		cfg:                 cfg,
+		notificationService: notificationService,
+		mailService:         mailService,
+	}
+}
+
+// Enabled reports whether grace overage is configured
+func (s *QuotaGraceService) Enabled() bool {
+	return s.cfg.QuotaGracePercent > 0
+}
+
+// Limit returns the storage the user may currently occupy, including any grace
+func (s *QuotaGraceService) Limit(user *models.User) int64 {
+	return user.QuotaLimit(s.cfg.QuotaGracePercent, time.Now())
+}
+
+// Begin opens a grace window for a user whose usage has just gone over quota.
+// The user is updated in place; it returns false when no new window was opened
+func (s *QuotaGraceService) Begin(tx *gorm.DB, user *models.User) (bool, error) {
+	if !s.Enabled() || user.StorageUsed <= user.StorageQuota || user.QuotaGraceExpiresAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(s.cfg.QuotaGraceHours) * time.Hour)
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"quota_grace_started_at": now,
+		"quota_grace_expires_at": expiresAt,
+		"quota_grace_revoked_at": nil,
+	}).Error; err != nil {
+		return false, fmt.Errorf("error starting quota grace: %w", err)
+	}
+
+	user.QuotaGraceStartedAt = &now
+	user.QuotaGraceExpiresAt = &expiresAt
+	user.QuotaGraceRevokedAt = nil
+	return true, nil
+}
+
+// Warning describes an active grace window for upload responses
+func (s *QuotaGraceService) Warning(user *models.User) string {
+	return fmt.Sprintf("Storage quota exceeded by %.2f MB; the overage is allowed until %s. Free up space before then to keep uploading.",
+		float64(user.StorageUsed-user.StorageQuota)/(1024*1024),
+		user.QuotaGraceExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// NotifyStarted tells the user that their grace window has opened
+func (s *QuotaGraceService) NotifyStarted(user *models.User) {
+	s.notify(user, NotifyParams{
+		Type:     models.NotificationQuotaGrace,
+		Severity: models.NotificationSeverityWarning,
+		Title:    "Storage quota exceeded",
+		Message:  s.Warning(user),
+		Details: models.NotificationDetails{
+			"storage_quota": user.StorageQuota,
+			"storage_used":  user.StorageUsed,
+			"expires_at":    user.QuotaGraceExpiresAt,
+		},
+	})
+}
+
+// Start runs grace enforcement passes in the background
+func (s *QuotaGraceService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			revoked, cleared, err := s.Enforce()
+			if err != nil {
+				log.Printf("Quota grace enforcement failed: %v", err)
+			} else if revoked > 0 || cleared > 0 {
+				log.Printf("Quota grace: revoked %d, cleared %d", revoked, cleared)
+			}
+		}
+	}()
+}
+
+// Enforce revokes grace for users still over quota after their window expired
+// and clears grace state for users who are back within quota
+func (s *QuotaGraceService) Enforce() (revoked, cleared int64, err error) {
+	now := time.Now()
+
+	var expired []models.User
+	if err := s.db.Where("quota_grace_expires_at < ? AND quota_grace_revoked_at IS NULL AND storage_used > storage_quota", now).
+		Find(&expired).Error; err != nil {
+		return 0, 0, fmt.Errorf("error finding expired quota grace: %w", err)
+	}
+
+	for i := range expired {
+		user := &expired[i]
+		if err := s.db.Model(user).Update("quota_grace_revoked_at", now).Error; err != nil {
+			return revoked, 0, fmt.Errorf("error revoking quota grace: %w", err)
+		}
+		revoked++
+		s.notifyRevoked(user)
+	}
+
+	result := s.db.Model(&models.User{}).
+		Where("quota_grace_started_at IS NOT NULL AND storage_used <= storage_quota").
+		Updates(map[string]interface{}{
+			"quota_grace_started_at": nil,
+			"quota_grace_expires_at": nil,
+			"quota_grace_revoked_at": nil,
+		})
+	if result.Error != nil {
+		return revoked, 0, fmt.Errorf("error clearing quota grace: %w", result.Error)
+	}
+
+	return revoked, result.RowsAffected, nil
+}
+
+func (s *QuotaGraceService) notifyRevoked(user *models.User) {
+	s.notify(user, NotifyParams{
+		Type:     models.NotificationQuotaGrace,
+		Severity: models.NotificationSeverityCritical,
+		Title:    "Storage quota grace period ended",
+		Message: fmt.Sprintf("You are still %.2f MB over your storage quota. Uploads are blocked until you free up space.",
+			float64(user.StorageUsed-user.StorageQuota)/(1024*1024)),
+		Details: models.NotificationDetails{
+			"storage_quota": user.StorageQuota,
+			"storage_used":  user.StorageUsed,
+		},
+	})
+}
+
+// notify delivers a quota notice in-app and, unless the user turned it off,
+// by email
+func (s *QuotaGraceService) notify(user *models.User, params NotifyParams) {
+	if err := s.notificationService.Notify(user.ID, params); err != nil {
+		log.Printf("Failed to notify user %s about quota grace: %v", user.ID, err)
+	}
+
+	if s.mailService.Enabled() && user.Email != "" && s.notificationService.WantsEmail(user.ID, params.Type) {
+		if err := s.mailService.Send(user.Email, EmailQuotaWarning, QuotaWarningEmail{
+			Username: user.Username,
+			Title:    params.Title,
+			Message:  params.Message,
+		}); err != nil {
+			log.Printf("Failed to email user %s about quota grace: %v", user.ID, err)
+		}
+	}
+}
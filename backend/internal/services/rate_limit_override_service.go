@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrInvalidRateLimitOverride is returned when an override request fails validation
+	ErrInvalidRateLimitOverride = errors.New("invalid rate limit override")
+	// ErrRateLimitUserNotFound is returned when overriding limits of a missing user
+	ErrRateLimitUserNotFound = errors.New("user not found")
+)
+
+// RateLimitOverrideService manages per-user rate limit overrides
+type RateLimitOverrideService struct {
+	db *gorm.DB
+}
+
+// NewRateLimitOverrideService creates a new rate limit override service
+func NewRateLimitOverrideService(db *gorm.DB) *RateLimitOverrideService {
+	return &RateLimitOverrideService{db: db}
+}
+
+// RateLimitOverrideRequest sets exactly one of exempt, multiplier or rate_limit
+type RateLimitOverrideRequest struct {
+	Exempt         bool       `json:"exempt"`
+	Multiplier     float64    `json:"multiplier" binding:"min=0,max=1000"`
+	RateLimit      int        `json:"rate_limit" binding:"min=0"`
+	RateLimitBurst int        `json:"rate_limit_burst" binding:"min=0"`
+	Reason         string     `json:"reason" binding:"max=500"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// RateLimitOverrideWithUser is an override together with the user it applies to
+type RateLimitOverrideWithUser struct {
+	models.RateLimitOverride
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// ListOverrides returns every override, newest first
+func (s *RateLimitOverrideService) ListOverrides() ([]RateLimitOverrideWithUser, error) {
+	var overrides []RateLimitOverrideWithUser
+	if err := s.db.Model(&models.RateLimitOverride{}).
+		Select("rate_limit_overrides.*, users.username, users.email").
+		Joins("JOIN users ON users.id = rate_limit_overrides.user_id").
+		Order("rate_limit_overrides.updated_at DESC").
+		Scan(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("error fetching rate limit overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// SetOverride creates or replaces a user's override. It takes effect on this
+// server at once.
+func (s *RateLimitOverrideService) SetOverride(userID, adminID uuid.UUID, req RateLimitOverrideRequest) (*models.RateLimitOverride, error) {
+	modes := 0
+	for _, set := range []bool{req.Exempt, req.Multiplier > 0, req.RateLimit > 0} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		return nil, fmt.Errorf("%w: set exactly one of exempt, multiplier or rate_limit", ErrInvalidRateLimitOverride)
+	}
+	if req.RateLimitBurst > 0 && req.RateLimit == 0 {
+		return nil, fmt.Errorf("%w: rate_limit_burst requires rate_limit", ErrInvalidRateLimitOverride)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidRateLimitOverride)
+	}
+
+	var users int64
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).Count(&users).Error; err != nil {
+		return nil, fmt.Errorf("error checking user: %w", err)
+	}
+	if users == 0 {
+		return nil, ErrRateLimitUserNotFound
+	}
+
+	override := &models.RateLimitOverride{
+		UserID:         userID,
+		Exempt:         req.Exempt,
+		Multiplier:     req.Multiplier,
+		RateLimit:      req.RateLimit,
+		RateLimitBurst: req.RateLimitBurst,
+		Reason:         req.Reason,
+		ExpiresAt:      req.ExpiresAt,
+		CreatedBy:      &adminID,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"exempt", "multiplier", "rate_limit", "rate_limit_burst", "reason", "expires_at", "created_by", "updated_at"}),
+	}).Create(override).Error; err != nil {
+		return nil, fmt.Errorf("error saving rate limit override: %w", err)
+	}
+	middleware.InvalidateRateLimitOverride(userID)
+
+	if err := s.db.Where("user_id = ?", userID).First(override).Error; err != nil {
+		return nil, fmt.Errorf("error reloading rate limit override: %w", err)
+	}
+	return override, nil
+}
+
+// DeleteOverride removes a user's override so the normal limits apply again
+func (s *RateLimitOverrideService) DeleteOverride(userID uuid.UUID) error {
+	result := s.db.Where("user_id = ?", userID).Delete(&models.RateLimitOverride{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting rate limit override: %w", result.Error)
+	}
+	middleware.InvalidateRateLimitOverride(userID)
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+)
+
+// RateLimitWindowService purges database rate limit windows that ended long
+// ago. The database limiter keeps one row per user and endpoint, so without
+// this the table grows with every endpoint anyone has ever called.
+type RateLimitWindowService struct {
+	db *gorm.DB
+}
+
+// NewRateLimitWindowService creates a new rate limit window service
+func NewRateLimitWindowService(db *gorm.DB) *RateLimitWindowService {
+	return &RateLimitWindowService{db: db}
+}
+
+// Start purges windows that ended more than one interval ago, every interval
+func (s *RateLimitWindowService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			purged, err := s.Purge(time.Now().Add(-interval))
+			if err != nil {
+				log.Printf("Rate limit window purge failed: %v", err)
+			} else if purged > 0 {
+				log.Printf("Rate limit windows: purged %d stale windows", purged)
+			}
+		}
+	}()
+}
+
+// Purge deletes windows that ended before the cutoff and returns how many.
+// A user calling the endpoint again simply starts a new window.
+func (s *RateLimitWindowService) Purge(cutoff time.Time) (int64, error) {
+	windowEnd := database.TimePlusNanos(s.db, "window_start", "window_duration")
+	result := s.db.Where(windowEnd+" < "+database.Time(s.db, "?"), cutoff).
+		Delete(&models.APIRateLimit{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error purging rate limit windows: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
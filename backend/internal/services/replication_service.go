@@ -0,0 +1,182 @@
+package services
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// ReplicationService asynchronously copies blobs to the replica storage
+// location and tracks per-blob replication state on FileHash
+type ReplicationService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewReplicationService creates a new replication service
+func NewReplicationService(db *gorm.DB, cfg *config.Config) *ReplicationService {
+	return &ReplicationService{db: db, cfg: cfg}
+}
+
+// ReplicationStatusReport summarises replication progress and lag
+type ReplicationStatusReport struct {
+	Enabled             bool       `json:"enabled"`
+	ReplicatedBlobs     int64      `json:"replicated_blobs"`
+	PendingBlobs        int64      `json:"pending_blobs"`
+	PendingBytes        int64      `json:"pending_bytes"`
+	FailedBlobs         int64      `json:"failed_blobs"`
+	ExhaustedBlobs      int64      `json:"exhausted_blobs"` // failed blobs that will no longer be retried
+	OldestPendingAt     *time.Time `json:"oldest_pending_at,omitempty"`
+	LagSeconds          float64    `json:"lag_seconds"`
+	LastReplicatedAt    *time.Time `json:"last_replicated_at,omitempty"`
+	ReplicaAvailable    bool       `json:"replica_available"`
+	ReplicationInterval int        `json:"replication_interval_seconds"`
+}
+
+// Start runs replication passes in the background
+func (s *ReplicationService) Start() {
+	interval := time.Duration(s.cfg.ReplicationInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			s.ReplicatePending()
+		}
+	}()
+}
+
+// ReplicatePending copies pending and retryable failed blobs to the replica in
+// batches until none are left. It returns the number of blobs replicated.
+func (s *ReplicationService) ReplicatePending() int {
+	batchSize := s.cfg.ReplicationBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	replicated := 0
+	for {
+		var fileHashes []models.FileHash
+		if err := s.db.Where("replication_status IN ? AND replication_attempts < ? AND content_removed_at IS NULL",
+			[]models.ReplicationStatus{models.ReplicationPending, models.ReplicationFailed}, s.cfg.ReplicationMaxAttempts).
+			Order("created_at ASC").
+			Limit(batchSize).
+			Find(&fileHashes).Error; err != nil {
+			log.Printf("Replication: failed to fetch pending blobs: %v", err)
+			return replicated
+		}
+
+		progressed := false
+		for i := range fileHashes {
+			if s.replicate(&fileHashes[i]) {
+				replicated++
+				progressed = true
+			}
+		}
+
+		// Stop when the batch was not full or nothing succeeded, so a
+		// replica outage doesn't spin through every retry at once
+		if len(fileHashes) < batchSize || !progressed {
+			return replicated
+		}
+	}
+}
+
+// replicate copies a single blob and records the outcome
+func (s *ReplicationService) replicate(fileHash *models.FileHash) bool {
+	srcPath := filepath.Join(s.cfg.StoragePath, fileHash.StoragePath)
+	destPath := filepath.Join(s.cfg.ReplicaStoragePath, fileHash.StoragePath)
+
+	if _, err := utils.CopyFileVerified(srcPath, destPath, fileHash.Hash); err != nil {
+		s.db.Model(fileHash).Updates(map[string]interface{}{
+			"replication_status":   models.ReplicationFailed,
+			"replication_attempts": gorm.Expr("replication_attempts + 1"),
+			"replication_error":    err.Error(),
+		})
+		log.Printf("Replication: failed to replicate blob %s: %v", fileHash.Hash, err)
+		return false
+	}
+
+	s.db.Model(fileHash).Updates(map[string]interface{}{
+		"replication_status":   models.ReplicationReplicated,
+		"replication_attempts": gorm.Expr("replication_attempts + 1"),
+		"replication_error":    "",
+		"replicated_at":        time.Now(),
+	})
+	return true
+}
+
+// GetStatus reports replication counts and lag. Lag is the age of the oldest
+// blob still waiting to be replicated. Blobs whose content the orphan blob
+// sweep removed are left out.
+func (s *ReplicationService) GetStatus() (*ReplicationStatusReport, error) {
+	report := &ReplicationStatusReport{
+		Enabled:             s.cfg.EnableReplication,
+		ReplicationInterval: s.cfg.ReplicationInterval,
+	}
+
+	var counts []struct {
+		ReplicationStatus models.ReplicationStatus
+		Count             int64
+		Total             int64
+		Exhausted         int64
+	}
+	if err := s.db.Model(&models.FileHash{}).
+		Select("replication_status, COUNT(*) as count, COALESCE(SUM(size), 0) as total, "+
+			"COUNT(*) FILTER (WHERE replication_attempts >= ?) as exhausted", s.cfg.ReplicationMaxAttempts).
+		Where("content_removed_at IS NULL").
+		Group("replication_status").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range counts {
+		switch row.ReplicationStatus {
+		case models.ReplicationReplicated:
+			report.ReplicatedBlobs = row.Count
+		case models.ReplicationFailed:
+			report.FailedBlobs = row.Count
+			report.ExhaustedBlobs = row.Exhausted
+			report.PendingBytes += row.Total
+		default:
+			report.PendingBlobs += row.Count
+			report.PendingBytes += row.Total
+		}
+	}
+
+	var oldestPending models.FileHash
+	if err := s.db.Where("replication_status <> ? AND content_removed_at IS NULL", models.ReplicationReplicated).
+		Order("created_at ASC").
+		Limit(1).
+		Find(&oldestPending).Error; err == nil && oldestPending.ID != uuid.Nil {
+		report.OldestPendingAt = &oldestPending.CreatedAt
+		report.LagSeconds = time.Since(oldestPending.CreatedAt).Seconds()
+	}
+
+	var lastReplicated models.FileHash
+	if err := s.db.Where("replicated_at IS NOT NULL").
+		Order("replicated_at DESC").
+		Limit(1).
+		Find(&lastReplicated).Error; err == nil && lastReplicated.ReplicatedAt != nil {
+		report.LastReplicatedAt = lastReplicated.ReplicatedAt
+	}
+
+	if s.cfg.ReplicaStoragePath != "" {
+		if info, err := os.Stat(s.cfg.ReplicaStoragePath); err == nil && info.IsDir() {
+			report.ReplicaAvailable = true
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+)
+
+var (
+	// ErrInvalidRetention is returned when a WORM retention period is rejected
+	ErrInvalidRetention = errors.New("invalid retention period")
+	// ErrFolderNotFound is returned when a folder does not exist for the user
+	ErrFolderNotFound = errors.New("folder not found")
+)
+
+// RetentionService enforces write-once (WORM) retention on folders and the
+// files placed in them
+type RetentionService struct {
+	db           *gorm.DB
+	auditService *AuditService
+}
+
+// NewRetentionService creates a new retention service
+func NewRetentionService(db *gorm.DB, auditService *AuditService) *RetentionService {
+	return &RetentionService{
+		db:           db,
+		auditService: auditService,
+	}
+}
+
+// EnableWORM turns a folder into a WORM folder, or extends its retention.
+// WORM cannot be switched off and retention can never be shortened. Files
+// already in the folder tree are locked from now.
+func (s *RetentionService) EnableWORM(folderID, ownerID uuid.UUID, retentionDays int) (*models.Folder, error) {
+	if retentionDays <= 0 {
+		return nil, fmt.Errorf("%w: retention_days must be positive", ErrInvalidRetention)
+	}
+
+	var folder models.Folder
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND owner_id = ?", folderID, ownerID).First(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFolderNotFound
+			}
+			return fmt.Errorf("error fetching folder: %w", err)
+		}
+
+		if folder.IsWORM && retentionDays < folder.RetentionDays {
+			return fmt.Errorf("%w: retention can only be extended (currently %d days)", ErrInvalidRetention, folder.RetentionDays)
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"is_worm":        true,
+			"retention_days": retentionDays,
+		}
+		if folder.WORMEnabledAt == nil {
+			updates["worm_enabled_at"] = now
+		}
+		if err := tx.Model(&folder).Updates(updates).Error; err != nil {
+			return fmt.Errorf("error enabling WORM: %w", err)
+		}
+
+		// Lock files that are not locked yet, then recompute every lock in the
+		// tree so an extended retention applies to files already held
+		subtree := tx.Model(&models.File{}).
+			Where("owner_id = ?", ownerID).
+			Where("folder_id IN (?)", tx.Model(&models.Folder{}).Select("id").
+				Where("owner_id = ? AND (path = ? OR path LIKE ?)", ownerID, folder.Path, folder.Path+"/%")).
+			Session(&gorm.Session{})
+
+		if err := subtree.Where("worm_locked_at IS NULL").
+			Update("worm_locked_at", now).Error; err != nil {
+			return fmt.Errorf("error locking files: %w", err)
+		}
+
+		retainUntil := database.TimePlusDays(tx, "worm_locked_at", "?")
+		if err := subtree.Where("(retain_until IS NULL OR "+database.Time(tx, "retain_until")+" < "+database.Time(tx, retainUntil)+")", retentionDays).
+			Update("retain_until", gorm.Expr(retainUntil, retentionDays)).Error; err != nil {
+			return fmt.Errorf("error applying retention: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.First(&folder, "id = ?", folderID).Error; err != nil {
+		return nil, fmt.Errorf("error reloading folder: %w", err)
+	}
+	return &folder, nil
+}
+
+// RetentionDaysForFolder returns the longest retention of the folder or any
+// WORM folder above it. Zero means files there are not retained.
+func (s *RetentionService) RetentionDaysForFolder(tx *gorm.DB, folderID *uuid.UUID) (int, error) {
+	if folderID == nil {
+		return 0, nil
+	}
+
+	var folder models.Folder
+	if err := tx.Select("id", "owner_id", "path").First(&folder, "id = ?", *folderID).Error; err != nil {
+		return 0, fmt.Errorf("error fetching folder: %w", err)
+	}
+
+	var days int
+	if err := tx.Model(&models.Folder{}).
+		Select("COALESCE(MAX(retention_days), 0)").
+		Where("owner_id = ? AND is_worm = true", folder.OwnerID).
+		Where("(path = ? OR ? LIKE path || '/%')", folder.Path, folder.Path).
+		Scan(&days).Error; err != nil {
+		return 0, fmt.Errorf("error fetching folder retention: %w", err)
+	}
+
+	return days, nil
+}
+
+// LockFile applies the retention of its folder to a file placed there. It
+// never shortens an existing lock.
+func (s *RetentionService) LockFile(tx *gorm.DB, file *models.File) error {
+	days, err := s.RetentionDaysForFolder(tx, file.FolderID)
+	if err != nil || days == 0 {
+		return err
+	}
+
+	now := time.Now()
+	retainUntil := now.AddDate(0, 0, days)
+	if file.RetainUntil != nil && file.RetainUntil.After(retainUntil) {
+		retainUntil = *file.RetainUntil
+	}
+
+	if err := tx.Model(file).Updates(map[string]interface{}{
+		"worm_locked_at": now,
+		"retain_until":   retainUntil,
+	}).Error; err != nil {
+		return fmt.Errorf("error locking file: %w", err)
+	}
+
+	file.WORMLockedAt = &now
+	file.RetainUntil = &retainUntil
+	return nil
+}
+
+// FolderTreeLocked reports whether a folder, its subfolders or the files in
+// them are under WORM retention, which prevents renaming, moving or deleting it
+func (s *RetentionService) FolderTreeLocked(folder *models.Folder) (bool, error) {
+	inTree := "owner_id = ? AND (path = ? OR path LIKE ?)"
+
+	var wormFolders int64
+	if err := s.db.Model(&models.Folder{}).
+		Where(inTree, folder.OwnerID, folder.Path, folder.Path+"/%").
+		Where("is_worm = true").
+		Count(&wormFolders).Error; err != nil {
+		return false, fmt.Errorf("error checking WORM folders: %w", err)
+	}
+	if wormFolders > 0 {
+		return true, nil
+	}
+
+	var lockedFiles int64
+	if err := s.db.Model(&models.File{}).
+		Where("retain_until > ?", time.Now()).
+		Where("folder_id IN (?)", s.db.Model(&models.Folder{}).Select("id").
+			Where(inTree, folder.OwnerID, folder.Path, folder.Path+"/%")).
+		Count(&lockedFiles).Error; err != nil {
+		return false, fmt.Errorf("error checking retained files: %w", err)
+	}
+
+	return lockedFiles > 0, nil
+}
+
+// CountRetainedFiles returns how many of a user's files are under retention
+func (s *RetentionService) CountRetainedFiles(ownerID uuid.UUID) (int64, error) {
+	var count int64
+	if err := s.db.Model(&models.File{}).
+		Where("owner_id = ? AND retain_until > ?", ownerID, time.Now()).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("error counting retained files: %w", err)
+	}
+	return count, nil
+}
+
+// LogBlocked records an attempt to change a retained resource
+func (s *RetentionService) LogBlocked(c *gin.Context, userID uuid.UUID, action models.AuditLogAction, resourceType models.AuditLogResourceType, resourceID uuid.UUID, resourceName string, retainUntil *time.Time) {
+	if s.auditService == nil {
+		return
+	}
+
+	details := models.AuditLogDetails{
+		"reason":    "worm_retention",
+		"timestamp": time.Now().Unix(),
+	}
+	if retainUntil != nil {
+		details["retain_until"] = retainUntil
+	}
+
+	if err := s.auditService.LogActivityFromGin(c, LogActivityParams{
+		UserID:       userID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   &resourceID,
+		ResourceName: &resourceName,
+		Details:      details,
+		Status:       models.AuditStatusFailed,
+	}); err != nil {
+		log.Printf("Failed to log blocked WORM action: %v", err)
+	}
+}
+
+// LogEnabled records that WORM retention was enabled or extended on a folder
+func (s *RetentionService) LogEnabled(c *gin.Context, userID uuid.UUID, folder *models.Folder) {
+	if s.auditService == nil {
+		return
+	}
+
+	if err := s.auditService.LogActivityFromGin(c, LogActivityParams{
+		UserID:       userID,
+		Action:       models.AuditActionUpdate,
+		ResourceType: models.AuditResourceFolder,
+		ResourceID:   &folder.ID,
+		ResourceName: &folder.Name,
+		Details: models.AuditLogDetails{
+			"worm":           true,
+			"retention_days": folder.RetentionDays,
+			"timestamp":      time.Now().Unix(),
+		},
+		Status: models.AuditStatusSuccess,
+	}); err != nil {
+		log.Printf("Failed to log WORM change: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/models"
+)
+
+func TestEnableWORM(t *testing.T) {
+	db := openTestDB(t)
+	fx := &accessFixture{t: t, db: db}
+
+	owner := fx.user("owner")
+	folderID := fx.folder(owner, "records")
+	sub := models.Folder{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Name:      "2026",
+		ParentID:  &folderID,
+		OwnerID:   owner,
+		Path:      "/records/2026",
+	}
+	fx.create(&sub)
+	other := fx.folder(owner, "records-old")
+
+	inFolder := fx.file(owner, &folderID, models.StorageTierHot)
+	inSubfolder := fx.file(owner, &sub.ID, models.StorageTierHot)
+	outside := fx.file(owner, &other, models.StorageTierHot)
+
+	svc := NewRetentionService(db, nil)
+	lockedFor := func(file *models.File) time.Duration {
+		t.Helper()
+		var stored models.File
+		if err := db.First(&stored, "id = ?", file.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if stored.WORMLockedAt == nil || stored.RetainUntil == nil {
+			return 0
+		}
+		return stored.RetainUntil.Sub(*stored.WORMLockedAt).Round(time.Second)
+	}
+
+	tests := []struct {
+		name    string
+		days    int
+		err     error
+		want    time.Duration
+		outside time.Duration
+	}{
+		{"enable", 30, nil, 30 * 24 * time.Hour, 0},
+		{"extend", 60, nil, 60 * 24 * time.Hour, 0},
+		{"shorten", 10, ErrInvalidRetention, 60 * 24 * time.Hour, 0},
+		{"invalid", 0, ErrInvalidRetention, 60 * 24 * time.Hour, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.EnableWORM(folderID, owner, tt.days)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			for name, file := range map[string]*models.File{"folder": inFolder, "subfolder": inSubfolder} {
+				if got := lockedFor(file); got != tt.want {
+					t.Errorf("file in %s: retained for %v, want %v", name, got, tt.want)
+				}
+			}
+			if got := lockedFor(outside); got != tt.outside {
+				t.Errorf("file outside the folder: retained for %v, want %v", got, tt.outside)
+			}
+		})
+	}
+}
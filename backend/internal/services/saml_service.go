@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// samlRequestTTL is how long a login started here may take at the identity provider
+const samlRequestTTL = 10 * time.Minute
+
+var (
+	// ErrSSOUnknownUser is returned when an asserted user has no account and
+	// just-in-time provisioning is off
+	ErrSSOUnknownUser = errors.New("no account exists for this single sign-on user")
+	// ErrSSOAccountDisabled is returned when the asserted user's account is disabled
+	ErrSSOAccountDisabled = errors.New("account is disabled")
+)
+
+// SAMLService makes the server a SAML 2.0 service provider: it publishes SP
+// metadata, sends users to the identity provider and turns the assertions
+// that come back into local accounts, creating them on first login
+type SAMLService struct {
+	db  *gorm.DB
+	cfg *config.Config
+	sp  *saml.ServiceProvider
+
+	mu      sync.Mutex
+	pending map[string]time.Time // IDs of outstanding authentication requests
+}
+
+// NewSAMLService creates a new SAML service. When SAML is enabled but cannot
+// be configured the error is logged and single sign-on stays off
+func NewSAMLService(db *gorm.DB, cfg *config.Config) *SAMLService {
+	s := &SAMLService{db: db, cfg: cfg, pending: make(map[string]time.Time)}
+	if !cfg.EnableSAML {
+		return s
+	}
+
+	sp, err := newServiceProvider(cfg)
+	if err != nil {
+		log.Printf("SAML single sign-on disabled: %v", err)
+		return s
+	}
+	s.sp = sp
+	return s
+}
+
+func newServiceProvider(cfg *config.Config) (*saml.ServiceProvider, error) {
+	keyPair, err := tls.LoadX509KeyPair(cfg.SAMLCertFile, cfg.SAMLKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading SP certificate and key: %w", err)
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("SP key must be an RSA key")
+	}
+	if keyPair.Leaf == nil {
+		return nil, fmt.Errorf("SP certificate could not be parsed")
+	}
+
+	idpMetadata, err := loadIDPMetadata(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rootURL, err := url.Parse(strings.TrimRight(cfg.SAMLRootURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML root URL: %w", err)
+	}
+	metadataURL := rootURL.JoinPath("/api/v1/auth/saml/metadata")
+	acsURL := rootURL.JoinPath("/api/v1/auth/saml/acs")
+
+	entityID := cfg.SAMLEntityID
+	if entityID == "" {
+		entityID = metadataURL.String()
+	}
+
+	return &saml.ServiceProvider{
+		EntityID:          entityID,
+		Key:               key,
+		Certificate:       keyPair.Leaf,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idpMetadata,
+		AllowIDPInitiated: cfg.SAMLAllowIDPInitiated,
+	}, nil
+}
+
+// loadIDPMetadata reads the identity provider's metadata from the configured
+// file, or fetches it from the configured URL
+func loadIDPMetadata(cfg *config.Config) (*saml.EntityDescriptor, error) {
+	var data []byte
+	switch {
+	case cfg.SAMLIDPMetadataFile != "":
+		b, err := os.ReadFile(cfg.SAMLIDPMetadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading IdP metadata: %w", err)
+		}
+		data = b
+	case cfg.SAMLIDPMetadataURL != "":
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.SAMLIDPMetadataURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IdP metadata URL: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching IdP metadata: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error fetching IdP metadata: status %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("error reading IdP metadata: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("neither SAML_IDP_METADATA_FILE nor SAML_IDP_METADATA_URL is set")
+	}
+
+	// Metadata may hold a single entity or a list of them; use the first
+	// entity describing an identity provider
+	entity := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(data, entity); err == nil {
+		return entity, nil
+	}
+	entities := &saml.EntitiesDescriptor{}
+	if err := xml.Unmarshal(data, entities); err != nil {
+		return nil, fmt.Errorf("error parsing IdP metadata: %w", err)
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, fmt.Errorf("IdP metadata describes no identity provider")
+}
+
+// Enabled reports whether single sign-on is configured and ready
+func (s *SAMLService) Enabled() bool {
+	return s.sp != nil
+}
+
+// Enforced reports whether password login is turned off in favour of SSO
+func (s *SAMLService) Enforced() bool {
+	return s.Enabled() && s.cfg.SAMLEnforce
+}
+
+// PasswordLoginAllowed reports whether the user may still sign in with a
+// password. Guests always may, as they are not known to the identity
+// provider, and admins may when the break-glass option is on
+func (s *SAMLService) PasswordLoginAllowed(user *models.User) bool {
+	if !s.Enforced() || user.Role == models.RoleGuest {
+		return true
+	}
+	return user.Role == models.RoleAdmin && s.cfg.SAMLAllowAdminPassword
+}
+
+// Metadata returns the service provider metadata for the identity provider
+func (s *SAMLService) Metadata() ([]byte, error) {
+	data, err := xml.MarshalIndent(s.sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding SP metadata: %w", err)
+	}
+	return data, nil
+}
+
+// LoginURL starts an SP-initiated login and returns the identity provider
+// URL to send the user to
+func (s *SAMLService) LoginURL(relayState string) (string, error) {
+	req, err := s.sp.MakeAuthenticationRequest(
+		s.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", fmt.Errorf("error creating authentication request: %w", err)
+	}
+	redirect, err := req.Redirect(relayState, s.sp)
+	if err != nil {
+		return "", fmt.Errorf("error encoding authentication request: %w", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for id, started := range s.pending {
+		if now.Sub(started) > samlRequestTTL {
+			delete(s.pending, id)
+		}
+	}
+	s.pending[req.ID] = now
+	s.mu.Unlock()
+
+	return redirect.String(), nil
+}
+
+// Authenticate validates the SAML response posted to the ACS endpoint and
+// returns the matching local user, provisioning or updating it as configured
+func (s *SAMLService) Authenticate(r *http.Request) (*models.User, error) {
+	assertion, err := s.sp.ParseResponse(r, s.pendingRequestIDs())
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			log.Printf("Rejected SAML response: %v", invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("invalid SAML response: %w", err)
+	}
+	s.forgetRequest(assertion)
+
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, fmt.Errorf("SAML assertion has no subject")
+	}
+	return s.provision(assertion.Subject.NameID.Value, samlAttributes(assertion))
+}
+
+func (s *SAMLService) pendingRequestIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// forgetRequest drops the request an assertion answers so it cannot be replayed
+func (s *SAMLService) forgetRequest(assertion *saml.Assertion) {
+	if assertion.Subject == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, confirmation := range assertion.Subject.SubjectConfirmations {
+		if confirmation.SubjectConfirmationData != nil {
+			delete(s.pending, confirmation.SubjectConfirmationData.InResponseTo)
+		}
+	}
+}
+
+// samlAttributes collects assertion attribute values by both name and friendly name
+func samlAttributes(assertion *saml.Assertion) map[string][]string {
+	attrs := make(map[string][]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			for _, value := range attr.Values {
+				attrs[attr.Name] = append(attrs[attr.Name], value.Value)
+				if attr.FriendlyName != "" && attr.FriendlyName != attr.Name {
+					attrs[attr.FriendlyName] = append(attrs[attr.FriendlyName], value.Value)
+				}
+			}
+		}
+	}
+	return attrs
+}
+
+func firstAttribute(attrs map[string][]string, name string) string {
+	if name == "" || len(attrs[name]) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(attrs[name][0])
+}
+
+// mappedRole returns the role granted by the role attribute, or "" when role
+// mapping is not configured
+func (s *SAMLService) mappedRole(attrs map[string][]string) models.UserRoleType {
+	if s.cfg.SAMLRoleAttribute == "" {
+		return ""
+	}
+	for _, value := range attrs[s.cfg.SAMLRoleAttribute] {
+		for _, admin := range s.cfg.SAMLAdminValues {
+			if strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(admin)) {
+				return models.RoleAdmin
+			}
+		}
+	}
+	return models.RoleUser
+}
+
+// provision finds the user for an asserted subject, linking an existing
+// account by email on first SSO login or creating one when allowed
+func (s *SAMLService) provision(subject string, attrs map[string][]string) (*models.User, error) {
+	email := firstAttribute(attrs, s.cfg.SAMLEmailAttribute)
+	if email == "" && strings.Contains(subject, "@") {
+		email = subject
+	}
+	role := s.mappedRole(attrs)
+
+	var user models.User
+	err := s.db.Where("sso_subject = ?", subject).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && email != "" {
+		err = s.db.Where("email = ?", email).First(&user).Error
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error finding user: %w", err)
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !s.cfg.SAMLJITProvisioning {
+			return nil, ErrSSOUnknownUser
+		}
+		if email == "" {
+			return nil, fmt.Errorf("SAML assertion carries no email address")
+		}
+		return s.createUser(subject, email, role, attrs)
+	}
+
+	if !user.IsActive {
+		return nil, ErrSSOAccountDisabled
+	}
+
+	updates := map[string]interface{}{}
+	if user.SSOSubject == nil || *user.SSOSubject != subject {
+		updates["sso_subject"] = subject
+	}
+	if role != "" && role != user.Role && roleMappable(&user, role) {
+		updates["role"] = role
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("error updating SSO user: %w", err)
+		}
+		if _, ok := updates["role"]; ok {
+			user.Role = role
+		}
+		user.SSOSubject = &subject
+	}
+	return &user, nil
+}
+
+// roleMappable reports whether role mapping may give the user the role. The
+// identity provider only moves users between user and admin: guests and
+// tenant admins keep the role they were given, and tenant members cannot be
+// platform admins
+func roleMappable(user *models.User, role models.UserRoleType) bool {
+	if user.Role != models.RoleUser && user.Role != models.RoleAdmin {
+		return false
+	}
+	return role != models.RoleAdmin || user.TenantID == nil
+}
+
+func (s *SAMLService) createUser(subject, email string, role models.UserRoleType, attrs map[string][]string) (*models.User, error) {
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	username := firstAttribute(attrs, s.cfg.SAMLUsernameAttribute)
+	if username == "" {
+		username = strings.SplitN(email, "@", 2)[0]
+	}
+	var taken int64
+	if err := s.db.Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+		return nil, fmt.Errorf("error checking username: %w", err)
+	}
+	if taken > 0 {
+		suffix, err := generateSecureToken(3)
+		if err != nil {
+			return nil, fmt.Errorf("error generating username: %w", err)
+		}
+		username += "-" + suffix
+	}
+
+	// SSO users have no usable password; the hash is of a random secret
+	secret, err := generateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("error generating password: %w", err)
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	user := models.User{
+		BaseModel:     models.BaseModel{ID: uuid.New()},
+		Username:      username,
+		Email:         email,
+		PasswordHash:  string(passwordHash),
+		FirstName:     firstAttribute(attrs, s.cfg.SAMLFirstNameAttribute),
+		LastName:      firstAttribute(attrs, s.cfg.SAMLLastNameAttribute),
+		Role:          role,
+		StorageQuota:  s.cfg.DefaultUserQuota,
+		IsActive:      true,
+		EmailVerified: true,
+		SSOSubject:    &subject,
+	}
+	if role == models.RoleAdmin {
+		user.StorageQuota = s.cfg.AdminQuota
+	}
+
+	defaultPlan, err := NewPlanService(s.db).DefaultPlan()
+	if err != nil {
+		return nil, fmt.Errorf("error loading default plan: %w", err)
+	}
+	if defaultPlan != nil {
+		user.PlanID = &defaultPlan.ID
+		user.StorageQuota = defaultPlan.StorageQuota
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return fmt.Errorf("error creating SSO user: %w", err)
+		}
+		var userRole models.Role
+		if err := tx.Where("name = ?", "user").First(&userRole).Error; err == nil {
+			return tx.Create(&models.UserRole{ID: uuid.New(), UserID: user.ID, RoleID: userRole.ID}).Error
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned SSO user %s (%s)", user.Username, user.Email)
+	return &user, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"file-vault-system/backend/internal/config"
+)
+
+// ShareLinkCredentials open a password-protected share link: the access
+// token issued when the link was unlocked, or the password itself on the
+// deprecated ?password= query parameter
+type ShareLinkCredentials struct {
+	AccessToken string
+	Password    string
+}
+
+// ShareAccess is the access token issued when a share link is unlocked. It
+// only opens that one link, only until it expires, and stops working when
+// the link's password changes
+type ShareAccess struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// shareAccessTokens issues and checks share link access tokens. A token is
+// "<expiry>.<signature>", signed over the kind and ID of the link and its
+// password hash, so it cannot be replayed against another link or after the
+// password is changed
+type shareAccessTokens struct {
+	secret     []byte
+	ttl        time.Duration
+	allowQuery bool
+}
+
+func newShareAccessTokens(cfg *config.Config) shareAccessTokens {
+	return shareAccessTokens{
+		secret:     []byte(cfg.JWTSecret),
+		ttl:        time.Duration(cfg.ShareUnlockTTL) * time.Minute,
+		allowQuery: cfg.AllowSharePasswordQuery,
+	}
+}
+
+func (t shareAccessTokens) sign(kind string, linkID uuid.UUID, passwordHash string, expires int64) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte("share-access\x00" + kind + "\x00" + linkID.String() + "\x00" + passwordHash + "\x00" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issue returns a new access token for the link
+func (t shareAccessTokens) issue(kind string, linkID uuid.UUID, passwordHash string) *ShareAccess {
+	expiresAt := time.Now().Add(t.ttl).Truncate(time.Second)
+	expires := expiresAt.Unix()
+	return &ShareAccess{
+		Token:     strconv.FormatInt(expires, 10) + "." + t.sign(kind, linkID, passwordHash, expires),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// valid reports whether token is an unexpired access token for the link
+func (t shareAccessTokens) valid(kind string, linkID uuid.UUID, passwordHash, token string) bool {
+	expiresPart, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiresPart, 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(t.sign(kind, linkID, passwordHash, expires)))
+}
+
+// check admits a request to a link protected by passwordHash, by access
+// token or, while the query parameter is still accepted, by password. An
+// expired token asks for the password again
+func (t shareAccessTokens) check(kind string, linkID uuid.UUID, passwordHash string, creds ShareLinkCredentials, matches func(string) bool) error {
+	if passwordHash == "" {
+		return nil
+	}
+	if creds.AccessToken != "" && t.valid(kind, linkID, passwordHash, creds.AccessToken) {
+		return nil
+	}
+	if creds.Password == "" || !t.allowQuery {
+		return ErrSharePasswordRequired
+	}
+	if !matches(creds.Password) {
+		return ErrSharePasswordInvalid
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// ShareNotifier tells users about files and folders shared with them, with an
+// in-app notification and, unless they turned it off, an email
+type ShareNotifier struct {
+	db                  *gorm.DB
+	cfg                 *config.Config
+	notificationService *NotificationService
+	mailService         *MailService
+}
+
+// NewShareNotifier creates a new share notifier
+func NewShareNotifier(db *gorm.DB, cfg *config.Config, notificationService *NotificationService, mailService *MailService) *ShareNotifier {
+	return &ShareNotifier{
+		db:                  db,
+		cfg:                 cfg,
+		notificationService: notificationService,
+		mailService:         mailService,
+	}
+}
+
+// sharedItem describes a share for the recipient's notice
+type sharedItem struct {
+	kind       string // "file" or "folder"
+	id         uuid.UUID
+	shareID    uuid.UUID
+	name       string
+	sharedBy   uuid.UUID
+	sharedWith uuid.UUID
+	permission models.SharePermission
+	message    string
+	expiresAt  *time.Time
+}
+
+// FileShared notifies the recipient of a file share in the background
+func (n *ShareNotifier) FileShared(share *models.FileShare) {
+	item := sharedItem{
+		kind:       "file",
+		id:         share.FileID,
+		shareID:    share.ID,
+		sharedBy:   share.SharedBy,
+		sharedWith: share.SharedWith,
+		permission: share.Permission,
+		message:    share.Message,
+		expiresAt:  share.ExpiresAt,
+	}
+	go func() {
+		if err := n.db.Model(&models.File{}).Where("id = ?", item.id).
+			Pluck("original_filename", &item.name).Error; err != nil {
+			log.Printf("Failed to notify share recipient: error finding file: %v", err)
+			return
+		}
+		if err := n.notify(item); err != nil {
+			log.Printf("Failed to notify share recipient: %v", err)
+		}
+	}()
+}
+
+// FolderShared notifies the recipient of a folder share in the background
+func (n *ShareNotifier) FolderShared(share *models.FolderShare) {
+	item := sharedItem{
+		kind:       "folder",
+		id:         share.FolderID,
+		shareID:    share.ID,
+		sharedBy:   share.SharedBy,
+		sharedWith: share.SharedWith,
+		permission: share.Permission,
+		message:    share.Message,
+	}
+	go func() {
+		if err := n.db.Model(&models.Folder{}).Where("id = ?", item.id).
+			Pluck("name", &item.name).Error; err != nil {
+			log.Printf("Failed to notify share recipient: error finding folder: %v", err)
+			return
+		}
+		if err := n.notify(item); err != nil {
+			log.Printf("Failed to notify share recipient: %v", err)
+		}
+	}()
+}
+
+func (n *ShareNotifier) notify(item sharedItem) error {
+	var sharer, recipient models.User
+	if err := n.db.Select("id", "username").First(&sharer, "id = ?", item.sharedBy).Error; err != nil {
+		return fmt.Errorf("error finding sharer: %w", err)
+	}
+	if err := n.db.Select("id", "username", "email").First(&recipient, "id = ?", item.sharedWith).Error; err != nil {
+		return fmt.Errorf("error finding recipient: %w", err)
+	}
+
+	if err := n.notificationService.Notify(recipient.ID, NotifyParams{
+		Type:     models.NotificationShareReceived,
+		Severity: models.NotificationSeverityInfo,
+		Title:    "New " + item.kind + " shared with you",
+		Message:  fmt.Sprintf("%s shared the %s %s with you.", sharer.Username, item.kind, item.name),
+		Details: models.NotificationDetails{
+			item.kind + "_id": item.id,
+			"share_id":        item.shareID,
+			"shared_by":       sharer.ID,
+			"permission":      item.permission,
+		},
+	}); err != nil {
+		return fmt.Errorf("error creating notification: %w", err)
+	}
+
+	if !n.mailService.Enabled() || recipient.Email == "" || !n.notificationService.WantsEmail(recipient.ID, models.NotificationShareReceived) {
+		return nil
+	}
+	email := ShareReceivedEmail{
+		Username:   recipient.Username,
+		SharedBy:   sharer.Username,
+		ItemKind:   item.kind,
+		ItemName:   item.name,
+		Permission: string(item.permission),
+		Note:       item.message,
+		SignInURL:  n.cfg.PublicBaseURL,
+	}
+	if item.expiresAt != nil {
+		email.ExpiresAt = item.expiresAt.UTC().Format(time.RFC1123)
+	}
+	return n.mailService.Send(recipient.Email, EmailShareReceived, email)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// ErrShareNotFound is returned when a share does not exist for the recipient
+var ErrShareNotFound = errors.New("share not found")
+
+// ShareListFilter selects the shares a recipient's shared listing returns
+type ShareListFilter struct {
+	Status        models.ShareStatus // empty for pending and accepted shares
+	IncludeHidden bool
+}
+
+// scope applies the filter to a query on file_shares or folder_shares
+func (f ShareListFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	} else {
+		db = db.Where("status <> ?", models.ShareStatusDeclined)
+	}
+	if !f.IncludeHidden {
+		db = db.Where("hidden_at IS NULL")
+	}
+	return db
+}
+
+// respondToShare records a recipient accepting or declining one of their
+// shares. Shares can be answered again, so a declined share can still be
+// accepted later.
+func respondToShare(query *gorm.DB, shareID, recipientID uuid.UUID, status models.ShareStatus) error {
+	result := query.Where("id = ? AND shared_with = ?", shareID, recipientID).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"responded_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error updating share: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// setShareHidden hides one of a recipient's shares from their shared listing
+// or shows it again
+func setShareHidden(query *gorm.DB, shareID, recipientID uuid.UUID, hidden bool) error {
+	var hiddenAt *time.Time
+	if hidden {
+		now := time.Now()
+		hiddenAt = &now
+	}
+
+	result := query.Where("id = ? AND shared_with = ?", shareID, recipientID).
+		Update("hidden_at", hiddenAt)
+	if result.Error != nil {
+		return fmt.Errorf("error updating share: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// RespondToFileShare records the recipient's answer to a file share
+func (s *SharingService) RespondToFileShare(shareID, recipientID uuid.UUID, status models.ShareStatus) error {
+	return respondToShare(s.activeFileShares(), shareID, recipientID, status)
+}
+
+// SetFileShareHidden hides a file share from the recipient or shows it again
+func (s *SharingService) SetFileShareHidden(shareID, recipientID uuid.UUID, hidden bool) error {
+	return setShareHidden(s.activeFileShares(), shareID, recipientID, hidden)
+}
+
+// activeFileShares queries the file shares that have not been revoked
+func (s *SharingService) activeFileShares() *gorm.DB {
+	return s.db.Model(&models.FileShare{}).Where("is_active = true")
+}
+
+// RespondToFolderShare records the recipient's answer to a folder share
+func (s *FolderSharingService) RespondToFolderShare(shareID, recipientID uuid.UUID, status models.ShareStatus) error {
+	return respondToShare(s.db.Model(&models.FolderShare{}), shareID, recipientID, status)
+}
+
+// SetFolderShareHidden hides a folder share from the recipient or shows it again
+func (s *FolderSharingService) SetFolderShareHidden(shareID, recipientID uuid.UUID, hidden bool) error {
+	return setShareHidden(s.db.Model(&models.FolderShare{}), shareID, recipientID, hidden)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
@@ -12,15 +13,50 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
 	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/watermark"
+)
+
+var (
+	// ErrFileNotSharable is returned when a file does not exist or the user may not share it
+	ErrFileNotSharable = apperrors.ErrNotFound.With("file not found or you don't have permission to share it")
+	// ErrShareQuarantined is returned when sharing a quarantined file
+	ErrShareQuarantined = apperrors.ErrForbidden.WithCode("FILE_QUARANTINED", "file is quarantined pending review and cannot be shared")
+	// ErrShareEncrypted is returned when sharing a file of an encrypted folder on its own
+	ErrShareEncrypted = apperrors.ErrConflict.WithCode("FILE_ENCRYPTED", "file is end-to-end encrypted and cannot be shared on its own; share its folder instead")
+	// ErrShareLinkEncrypted is returned when creating a share link to encrypted content
+	ErrShareLinkEncrypted = apperrors.ErrConflict.WithCode("FILE_ENCRYPTED", "end-to-end encrypted content cannot be shared by link")
+	// ErrEncryptedNotPublic is returned when making encrypted content public
+	ErrEncryptedNotPublic = apperrors.ErrConflict.WithCode("FILE_ENCRYPTED", "end-to-end encrypted files cannot be made public")
+	// ErrWatermarkUnsupported is returned when asking for a watermark on a file that cannot carry one
+	ErrWatermarkUnsupported = apperrors.ErrInvalidInput.WithCode("WATERMARK_UNSUPPORTED", "only PDFs and PNG, JPEG and GIF images can be watermarked")
+	// ErrShareLinkNotFound is returned for unknown or revoked share link tokens
+	ErrShareLinkNotFound = apperrors.ErrNotFound.With("share link not found or expired")
+	// ErrShareLinkExpired is returned when a share link is past its expiry
+	ErrShareLinkExpired = apperrors.ErrGone.WithCode("SHARE_LINK_EXPIRED", "share link has expired")
+	// ErrShareLinkExhausted is returned when a share link has no downloads left
+	ErrShareLinkExhausted = apperrors.ErrGone.WithCode("SHARE_LINK_EXHAUSTED", "share link download limit exceeded")
+	// ErrSharePasswordRequired is returned when a protected share link is opened without a password
+	ErrSharePasswordRequired = apperrors.ErrUnauthorized.WithCode("SHARE_PASSWORD_REQUIRED", "password required")
+	// ErrSharePasswordInvalid is returned when a share link password is wrong
+	ErrSharePasswordInvalid = apperrors.ErrUnauthorized.WithCode("SHARE_PASSWORD_INVALID", "invalid password")
+	// ErrFileShareNotFound is returned when a file share is missing or not the user's
+	ErrFileShareNotFound = apperrors.ErrNotFound.With("file share not found or you don't have permission to revoke it")
+	// ErrOwnShareLinkNotFound is returned when a share link is missing or not the user's
+	ErrOwnShareLinkNotFound = apperrors.ErrNotFound.With("share link not found or you don't have permission to change it")
 )
 
 type SharingService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	access *AccessService
+	tokens shareAccessTokens
+	counts *AccessCountService
 }
 
-func NewSharingService(db *gorm.DB) *SharingService {
-	return &SharingService{db: db}
+func NewSharingService(db *gorm.DB, cfg *config.Config) *SharingService {
+	return &SharingService{db: db, access: NewAccessService(db), tokens: newShareAccessTokens(cfg), counts: NewAccessCountService(db, cfg)}
 }
 
 // ShareFileRequest represents a request to share a file
@@ -35,49 +71,60 @@ type ShareFileRequest struct {
 
 // CreateShareLinkRequest represents a request to create a shareable link
 type CreateShareLinkRequest struct {
-	FileID       uuid.UUID              `json:"file_id" binding:"required"`
-	CreatedBy    uuid.UUID              `json:"created_by" binding:"required"`
-	Password     string                 `json:"password"`
-	MaxDownloads *int                   `json:"max_downloads"`
-	ExpiresAt    *time.Time             `json:"expires_at"`
-	Permission   models.SharePermission `json:"permission"`
+	FileID           uuid.UUID              `json:"file_id" binding:"required"`
+	CreatedBy        uuid.UUID              `json:"created_by" binding:"required"`
+	Password         string                 `json:"password"`
+	MaxDownloads     *int                   `json:"max_downloads"`
+	ExpiresAt        *time.Time             `json:"expires_at"`
+	Permission       models.SharePermission `json:"permission"`
+	NotifyOnDownload bool                   `json:"notify_on_download"`
+	Watermark        bool                   `json:"watermark"`
+	WatermarkText    string                 `json:"watermark_text"`
 }
 
 // ShareFileWithUser shares a file with another user by email
 func (s *SharingService) ShareFileWithUser(req ShareFileRequest) (*models.FileShare, error) {
-	// Find the user by email
+	// Find the user by email; files are only shared within the sharer's tenant
 	var user models.User
-	if err := s.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := s.db.Scopes(models.InSameTenantAs(req.SharedBy)).Where("email = ?", req.Email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("user with email %s not found", req.Email)
+			return nil, apperrors.ErrNotFound.With(fmt.Sprintf("user with email %s not found", req.Email))
 		}
 		return nil, fmt.Errorf("error finding user: %w", err)
 	}
 
 	// Check if file exists and belongs to the sharer
-	var file models.File
-	if err := s.db.Where("id = ? AND owner_id = ?", req.FileID, req.SharedBy).First(&file).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("file not found or you don't have permission to share it")
-		}
-		return nil, fmt.Errorf("error finding file: %w", err)
+	file, err := s.userSharableFile(req.FileID, req.SharedBy)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if already shared with this user
 	var existingShare models.FileShare
-	err := s.db.Where("file_id = ? AND shared_by = ? AND shared_with = ?",
+	err = s.db.Where("file_id = ? AND shared_by = ? AND shared_with = ?",
 		req.FileID, req.SharedBy, user.ID).First(&existingShare).Error
 
 	if err == nil {
-		// Update existing share
+		// Update existing share. A revoked share made again asks the
+		// recipient afresh.
+		if !existingShare.IsActive {
+			existingShare.Status = models.ShareStatusPending
+			existingShare.RespondedAt = nil
+			existingShare.HiddenAt = nil
+		}
 		existingShare.Permission = req.Permission
 		existingShare.Message = req.Message
 		existingShare.ExpiresAt = req.ExpiresAt
 		existingShare.IsActive = true
 		existingShare.UpdatedAt = time.Now()
 
-		if err := s.db.Save(&existingShare).Error; err != nil {
-			return nil, fmt.Errorf("error updating existing share: %w", err)
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&existingShare).Error; err != nil {
+				return fmt.Errorf("error updating existing share: %w", err)
+			}
+			return RecordFileShared(tx, file, req.SharedBy, FileSharePayload(&existingShare))
+		}); err != nil {
+			return nil, err
 		}
 		return &existingShare, nil
 	}
@@ -93,8 +140,13 @@ func (s *SharingService) ShareFileWithUser(req ShareFileRequest) (*models.FileSh
 		IsActive:   true,
 	}
 
-	if err := s.db.Create(&fileShare).Error; err != nil {
-		return nil, fmt.Errorf("error creating file share: %w", err)
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&fileShare).Error; err != nil {
+			return fmt.Errorf("error creating file share: %w", err)
+		}
+		return RecordFileShared(tx, file, req.SharedBy, FileSharePayload(&fileShare))
+	}); err != nil {
+		return nil, err
 	}
 
 	return &fileShare, nil
@@ -103,12 +155,23 @@ func (s *SharingService) ShareFileWithUser(req ShareFileRequest) (*models.FileSh
 // CreateShareLink creates a shareable link for a file
 func (s *SharingService) CreateShareLink(req CreateShareLinkRequest) (*models.ShareLink, error) {
 	// Check if file exists and belongs to the creator
-	var file models.File
-	if err := s.db.Where("id = ? AND owner_id = ?", req.FileID, req.CreatedBy).First(&file).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("file not found or you don't have permission to share it")
-		}
-		return nil, fmt.Errorf("error finding file: %w", err)
+	file, err := s.sharableFile(req.FileID, req.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.IsQuarantined {
+		return nil, ErrShareQuarantined
+	}
+	if file.IsEncrypted {
+		return nil, ErrShareLinkEncrypted
+	}
+	if req.Watermark && !watermark.Supports(file.MimeType) {
+		return nil, ErrWatermarkUnsupported
+	}
+
+	if err := CheckPublicSharing(s.db, req.CreatedBy); err != nil {
+		return nil, err
 	}
 
 	// Generate unique share token
@@ -129,31 +192,109 @@ func (s *SharingService) CreateShareLink(req CreateShareLinkRequest) (*models.Sh
 
 	// Create share link
 	shareLink := models.ShareLink{
-		FileID:        req.FileID,
-		CreatedBy:     req.CreatedBy,
-		ShareToken:    token,
-		Permission:    req.Permission,
-		PasswordHash:  passwordHash,
-		MaxDownloads:  req.MaxDownloads,
-		ExpiresAt:     req.ExpiresAt,
-		IsActive:      true,
-		DownloadCount: 0,
+		FileID:           req.FileID,
+		CreatedBy:        req.CreatedBy,
+		ShareToken:       token,
+		Permission:       req.Permission,
+		PasswordHash:     passwordHash,
+		MaxDownloads:     req.MaxDownloads,
+		ExpiresAt:        req.ExpiresAt,
+		IsActive:         true,
+		DownloadCount:    0,
+		NotifyOnDownload: req.NotifyOnDownload,
+		Watermark:        req.Watermark,
+		WatermarkText:    req.WatermarkText,
 	}
 
-	if err := s.db.Create(&shareLink).Error; err != nil {
-		return nil, fmt.Errorf("error creating share link: %w", err)
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shareLink).Error; err != nil {
+			return fmt.Errorf("error creating share link: %w", err)
+		}
+		return RecordFileShared(tx, file, req.CreatedBy, ShareLinkPayload(&shareLink))
+	}); err != nil {
+		return nil, err
 	}
 
 	return &shareLink, nil
 }
 
-// GetSharedFiles returns files shared with a user
-func (s *SharingService) GetSharedFiles(userID uuid.UUID) ([]models.FileShare, error) {
+// RecordFileShared adds a shared event to a file's history
+func RecordFileShared(tx *gorm.DB, file *models.File, actorID uuid.UUID, payload models.FileEventPayload) error {
+	return RecordFileEvent(tx, FileEventParams{
+		FileID:  file.ID,
+		OwnerID: file.OwnerID,
+		ActorID: &actorID,
+		Type:    models.FileEventShared,
+		Payload: payload,
+	})
+}
+
+// FileSharePayload describes a share with a user for a file's history
+func FileSharePayload(share *models.FileShare) models.FileEventPayload {
+	return models.FileEventPayload{
+		"share_id":    share.ID,
+		"shared_with": share.SharedWith,
+		"permission":  share.Permission,
+		"expires_at":  share.ExpiresAt,
+	}
+}
+
+// ShareLinkPayload describes a share link for a file's history
+func ShareLinkPayload(link *models.ShareLink) models.FileEventPayload {
+	return models.FileEventPayload{
+		"share_link_id":      link.ID,
+		"permission":         link.Permission,
+		"expires_at":         link.ExpiresAt,
+		"max_downloads":      link.MaxDownloads,
+		"password_protected": link.PasswordHash != "",
+		"watermark":          link.Watermark,
+	}
+}
+
+// CheckSharable checks that the user may share a file with other users: the
+// file must be the user's own, and neither quarantined nor encrypted
+func (s *SharingService) CheckSharable(fileID, userID uuid.UUID) error {
+	_, err := s.userSharableFile(fileID, userID)
+	return err
+}
+
+// userSharableFile returns a file the user may share with other users
+func (s *SharingService) userSharableFile(fileID, userID uuid.UUID) (*models.File, error) {
+	file, err := s.sharableFile(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if file.IsQuarantined {
+		return nil, ErrShareQuarantined
+	}
+	if file.IsEncrypted {
+		return nil, ErrShareEncrypted
+	}
+	return file, nil
+}
+
+// sharableFile returns a file the user may share: only the owner of a live
+// file can
+func (s *SharingService) sharableFile(fileID, userID uuid.UUID) (*models.File, error) {
+	access, err := s.access.CanEdit(context.Background(), userID, fileID)
+	if err != nil {
+		if errors.Is(err, ErrAccessFileNotFound) || errors.Is(err, ErrAccessDenied) {
+			return nil, ErrFileNotSharable
+		}
+		return nil, fmt.Errorf("error finding file: %w", err)
+	}
+	return access.File, nil
+}
+
+// GetSharedFiles returns files shared with a user that pass the filter
+func (s *SharingService) GetSharedFiles(userID uuid.UUID, filter ShareListFilter) ([]models.FileShare, error) {
 	var fileShares []models.FileShare
 
 	err := s.db.Preload("File").Preload("File.FileHash").Preload("SharedByUser").
 		Where("shared_with = ? AND is_active = true", userID).
+		Scopes(filter.scope).
 		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("file_id IN (SELECT id FROM files WHERE deleted_at IS NULL)").
 		Find(&fileShares).Error
 
 	if err != nil {
@@ -194,7 +335,49 @@ func (s *SharingService) GetShareLinks(userID uuid.UUID) ([]models.ShareLink, er
 }
 
 // ValidateShareLink validates and returns a share link by token
-func (s *SharingService) ValidateShareLink(token string, password string) (*models.ShareLink, error) {
+func (s *SharingService) ValidateShareLink(token string, creds ShareLinkCredentials) (*models.ShareLink, error) {
+	shareLink, err := s.findShareLink(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check password if required
+	if err := s.tokens.check("file", shareLink.ID, shareLink.PasswordHash, creds, func(password string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(shareLink.PasswordHash), []byte(password)) == nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Update last accessed time
+	shareLink.LastAccessedAt = &[]time.Time{time.Now()}[0]
+	s.db.Save(shareLink)
+
+	return shareLink, nil
+}
+
+// UnlockShareLink checks the password of a share link and issues an access
+// token for the link's later requests
+func (s *SharingService) UnlockShareLink(token string, password string) (*ShareAccess, error) {
+	shareLink, err := s.findShareLink(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if shareLink.PasswordHash != "" {
+		if password == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(shareLink.PasswordHash), []byte(password)); err != nil {
+			return nil, ErrSharePasswordInvalid
+		}
+	}
+
+	return s.tokens.issue("file", shareLink.ID, shareLink.PasswordHash), nil
+}
+
+// findShareLink returns an active share link that has neither expired nor
+// run out of downloads
+func (s *SharingService) findShareLink(token string) (*models.ShareLink, error) {
 	var shareLink models.ShareLink
 
 	err := s.db.Preload("File").Preload("File.FileHash").
@@ -202,35 +385,21 @@ func (s *SharingService) ValidateShareLink(token string, password string) (*mode
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("share link not found or expired")
+			return nil, ErrShareLinkNotFound
 		}
 		return nil, fmt.Errorf("error finding share link: %w", err)
 	}
 
 	// Check if expired
 	if shareLink.ExpiresAt != nil && shareLink.ExpiresAt.Before(time.Now()) {
-		return nil, fmt.Errorf("share link has expired")
+		return nil, ErrShareLinkExpired
 	}
 
 	// Check download limit
 	if shareLink.MaxDownloads != nil && shareLink.DownloadCount >= *shareLink.MaxDownloads {
-		return nil, fmt.Errorf("share link download limit exceeded")
+		return nil, ErrShareLinkExhausted
 	}
 
-	// Check password if required
-	if shareLink.PasswordHash != "" {
-		if password == "" {
-			return nil, fmt.Errorf("password required")
-		}
-		if err := bcrypt.CompareHashAndPassword([]byte(shareLink.PasswordHash), []byte(password)); err != nil {
-			return nil, fmt.Errorf("invalid password")
-		}
-	}
-
-	// Update last accessed time
-	shareLink.LastAccessedAt = &[]time.Time{time.Now()}[0]
-	s.db.Save(&shareLink)
-
 	return &shareLink, nil
 }
 
@@ -245,7 +414,7 @@ func (s *SharingService) RevokeFileShare(shareID uuid.UUID, ownerID uuid.UUID) e
 	}
 
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("file share not found or you don't have permission to revoke it")
+		return ErrFileShareNotFound
 	}
 
 	return nil
@@ -262,24 +431,53 @@ func (s *SharingService) RevokeShareLink(linkID uuid.UUID, ownerID uuid.UUID) er
 	}
 
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("share link not found or you don't have permission to revoke it")
+		return ErrOwnShareLinkNotFound
 	}
 
 	return nil
 }
 
-// RecordShareLinkAccess records an access to a share link
-func (s *SharingService) RecordShareLinkAccess(shareLink *models.ShareLink, ipAddress, userAgent, action string) error {
-	accessLog := models.ShareLinkAccessLog{
-		ShareLinkID: shareLink.ID,
-		IPAddress:   ipAddress,
-		UserAgent:   userAgent,
-		Action:      action,
-		AccessedAt:  time.Now(),
+// SetShareLinkNotifications turns notifications about downloads through one
+// of the owner's share links on or off
+func (s *SharingService) SetShareLinkNotifications(linkID uuid.UUID, ownerID uuid.UUID, enabled bool) error {
+	result := s.db.Model(&models.ShareLink{}).
+		Where("id = ? AND created_by = ?", linkID, ownerID).
+		Update("notify_on_download", enabled)
+
+	if result.Error != nil {
+		return fmt.Errorf("error updating share link: %w", result.Error)
 	}
 
-	if err := s.db.Create(&accessLog).Error; err != nil {
-		return fmt.Errorf("error recording access log: %w", err)
+	if result.RowsAffected == 0 {
+		return ErrOwnShareLinkNotFound
+	}
+
+	return nil
+}
+
+// RecordShareLinkAccess records an access to a share link, or only counts it
+// when anonymous access tracking is set to counts. An empty ipAddress is
+// stored as none
+func (s *SharingService) RecordShareLinkAccess(shareLink *models.ShareLink, ipAddress, userAgent, action string) error {
+	if s.counts.CountsOnly() {
+		if err := s.counts.Record(models.AccessCountFile, shareLink.FileID, action); err != nil {
+			return err
+		}
+	} else {
+		accessLog := models.ShareLinkAccessLog{
+			ShareLinkID: shareLink.ID,
+			IPAddress:   ipAddress,
+			UserAgent:   userAgent,
+			Action:      action,
+			AccessedAt:  time.Now(),
+		}
+		query := s.db
+		if ipAddress == "" {
+			query = query.Omit("IPAddress")
+		}
+		if err := query.Create(&accessLog).Error; err != nil {
+			return fmt.Errorf("error recording access log: %w", err)
+		}
 	}
 
 	// Update download count if action is download
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/middleware"
+	"file-vault-system/backend/internal/models"
+)
+
+// SigningKeyService rotates the keys login tokens are signed with. A
+// rotation makes a new key current; tokens signed with the keys it replaces
+// keep working for the grace period, so users are not logged out at once.
+type SigningKeyService struct {
+	db           *gorm.DB
+	rotationDays int
+	grace        time.Duration
+}
+
+// NewSigningKeyService creates a new signing key service
+func NewSigningKeyService(db *gorm.DB, cfg *config.Config) *SigningKeyService {
+	return &SigningKeyService{
+		db:           db,
+		rotationDays: cfg.JWTKeyRotationDays,
+		grace:        time.Duration(cfg.JWTKeyGraceHours) * time.Hour,
+	}
+}
+
+// Enabled reports whether keys are rotated on a schedule
+func (s *SigningKeyService) Enabled() bool {
+	return s.rotationDays > 0
+}
+
+// Grace returns how long tokens signed with a replaced key keep working
+func (s *SigningKeyService) Grace() time.Duration {
+	return s.grace
+}
+
+// EnsureCurrent creates the first signing key when there is none. Tokens
+// issued before, signed with JWT_SECRET and without a kid, are recorded as
+// the legacy key and keep working for the grace period.
+func (s *SigningKeyService) EnsureCurrent() error {
+	var current int64
+	if err := s.db.Model(&models.SigningKey{}).Where("retired_at IS NULL").Count(&current).Error; err != nil {
+		return fmt.Errorf("error checking signing keys: %w", err)
+	}
+	if current > 0 {
+		return middleware.ReloadSigningKeys()
+	}
+
+	legacy := models.SigningKey{ID: models.LegacySigningKeyID}
+	if err := s.db.Where(legacy).FirstOrCreate(&legacy).Error; err != nil {
+		return fmt.Errorf("error recording legacy signing key: %w", err)
+	}
+	_, err := s.Rotate(s.grace)
+	return err
+}
+
+// List returns every signing key, newest first
+func (s *SigningKeyService) List() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	if err := s.db.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("error listing signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Rotate makes a new key current. The keys it replaces accept tokens for
+// grace longer, or no longer at all with a zero grace, as after a key was
+// compromised; a shorter grace also cuts short earlier rotations' grace.
+func (s *SigningKeyService) Rotate(grace time.Duration) (*models.SigningKey, error) {
+	if grace < 0 {
+		grace = 0
+	}
+	kid, err := generateSecureToken(8)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key ID: %w", err)
+	}
+	salt, err := generateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key salt: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(grace)
+	key := &models.SigningKey{ID: kid, Salt: salt, CreatedAt: now}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SigningKey{}).Where("retired_at IS NULL").
+			Update("retired_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SigningKey{}).Where("expires_at IS NULL OR expires_at > ?", expiresAt).
+			Update("expires_at", expiresAt).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error rotating signing key: %w", err)
+	}
+
+	if err := middleware.ReloadSigningKeys(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RotateIfDue rotates the current key once it is older than the rotation
+// period, and returns the new key or nil when none was due
+func (s *SigningKeyService) RotateIfDue() (*models.SigningKey, error) {
+	var current models.SigningKey
+	err := s.db.Where("retired_at IS NULL").Order("created_at DESC").Take(&current).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("error finding current signing key: %w", err)
+	}
+	if err == nil && time.Since(current.CreatedAt) < time.Duration(s.rotationDays)*24*time.Hour {
+		return nil, nil
+	}
+	return s.Rotate(s.grace)
+}
+
+// Start runs scheduled rotations in the background
+func (s *SigningKeyService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			key, err := s.RotateIfDue()
+			if err != nil {
+				log.Printf("Signing key rotation failed: %v", err)
+			} else if key != nil {
+				log.Printf("Signing key rotated: %s is now current", key.ID)
+			}
+		}
+	}()
+}
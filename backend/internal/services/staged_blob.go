@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/storage"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// PlaceStagedBlob moves staged upload content into the blob store once the
+// blob row for its hash is committed, and reports whether it did. Nothing is
+// moved when no row exists, when the blob is archived, or when the store
+// already holds the content, and never with null storage, which leaves the
+// staged copy to be removed. The staged copy is checked against the hash
+// first, so a damaged temp file never becomes a blob.
+func PlaceStagedBlob(db *gorm.DB, cfg *config.Config, hash, stagedPath string) (bool, error) {
+	fileHash, store, err := blobAwaitingContent(db, cfg, hash)
+	if err != nil || fileHash == nil {
+		return false, err
+	}
+
+	actual, err := utils.CalculateFileHash(stagedPath)
+	if err != nil {
+		return false, fmt.Errorf("error verifying staged content: %w", err)
+	}
+	if actual != hash {
+		return false, fmt.Errorf("staged content for blob %s does not match: got %s", hash, actual)
+	}
+
+	if err := storage.PutFile(context.Background(), store, fileHash.StoragePath, stagedPath); err != nil {
+		return false, fmt.Errorf("error moving staged content into storage: %w", err)
+	}
+	return true, nil
+}
+
+// PlaceObjectBlob copies content uploaded to the blob store under key to the
+// blob for its hash, as PlaceStagedBlob moves staged content. The caller has
+// checked the content against the hash, and removes the uploaded copy.
+func PlaceObjectBlob(db *gorm.DB, cfg *config.Config, hash, key string) (bool, error) {
+	fileHash, store, err := blobAwaitingContent(db, cfg, hash)
+	if err != nil || fileHash == nil {
+		return false, err
+	}
+	if err := storage.Copy(context.Background(), store, key, fileHash.StoragePath); err != nil {
+		return false, fmt.Errorf("error copying uploaded content into storage: %w", err)
+	}
+	return true, nil
+}
+
+// blobAwaitingContent returns the blob row for hash and the store its content
+// belongs in, or no row when there is nothing to place
+func blobAwaitingContent(db *gorm.DB, cfg *config.Config, hash string) (*models.FileHash, storage.Storage, error) {
+	if cfg.IsNullStorage() {
+		return nil, nil, nil
+	}
+
+	var fileHash models.FileHash
+	if err := db.Where("hash = ?", hash).First(&fileHash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("error loading blob: %w", err)
+	}
+	if fileHash.StorageTier != "" && fileHash.StorageTier != models.StorageTierHot {
+		return nil, nil, nil
+	}
+	store, err := OpenBlobStore(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range blobPathCandidates(fileHash.StoragePath) {
+		if _, err := store.Stat(context.Background(), p); err == nil {
+			return nil, nil, nil
+		} else if !errors.Is(err, storage.ErrNotExist) {
+			return nil, nil, fmt.Errorf("error checking blob store: %w", err)
+		}
+	}
+	return &fileHash, store, nil
+}
+
+// RecoverStagedUploads finishes uploads interrupted by a crash between the
+// database commit and the move into storage. Each staged file whose blob row
+// exists without content is moved into place; every other finished staged
+// file belongs to an upload that never committed and is removed. It returns
+// the number of files placed and removed. Run it before the stale sweep.
+func RecoverStagedUploads(db *gorm.DB, cfg *config.Config) (int, int, error) {
+	staged, err := utils.ListStagedFiles(cfg.UploadTempDir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error listing staged uploads: %w", err)
+	}
+
+	placed, removed := 0, 0
+	for stagedPath, hash := range staged {
+		ok, err := PlaceStagedBlob(db, cfg, hash, stagedPath)
+		if err != nil {
+			// Keep the staged copy; the stale sweep removes it eventually
+			log.Printf("Failed to recover staged upload %s: %v", filepath.Base(stagedPath), err)
+			continue
+		}
+		if ok {
+			placed++
+			continue
+		}
+		if err := os.Remove(stagedPath); err == nil {
+			removed++
+		}
+	}
+	return placed, removed, nil
+}
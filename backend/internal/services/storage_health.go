@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/events"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/storage"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// Storage health statuses, ordered by severity
+const (
+	StorageStatusHealthy  = "healthy"
+	StorageStatusWarning  = "warning"
+	StorageStatusCritical = "critical"
+)
+
+// StorageHealthService reports blob storage capacity and alerts admins
+// when it runs low
+type StorageHealthService struct {
+	db                  *gorm.DB
+	cfg                 *config.Config
+	notificationService *NotificationService
+
+	mu             sync.Mutex
+	lastAlertLevel string
+}
+
+// NewStorageHealthService creates a new storage health service
+func NewStorageHealthService(db *gorm.DB, cfg *config.Config, notificationService *NotificationService) *StorageHealthService {
+	return &StorageHealthService{
+		db:                  db,
+		cfg:                 cfg,
+		notificationService: notificationService,
+		lastAlertLevel:      StorageStatusHealthy,
+	}
+}
+
+// DiskCapacity describes the filesystem backing blob storage
+type DiskCapacity struct {
+	TotalBytes  uint64  `json:"total_bytes"`
+	UsedBytes   uint64  `json:"used_bytes"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// BucketUsage describes the blobs in the bucket backing object storage. A
+// bucket has no fixed capacity, so TotalBytes is always null.
+type BucketUsage struct {
+	ObjectCount int64   `json:"object_count"`
+	UsedBytes   int64   `json:"used_bytes"`
+	TotalBytes  *uint64 `json:"total_bytes"`
+}
+
+// StorageGrowth summarises how quickly new blobs are being written
+type StorageGrowth struct {
+	WindowDays  int     `json:"window_days"`
+	BlobsAdded  int64   `json:"blobs_added"`
+	BytesAdded  int64   `json:"bytes_added"`
+	BytesPerDay float64 `json:"bytes_per_day"`
+}
+
+// StorageAlert is a single threshold breach
+type StorageAlert struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// StorageThresholds echoes the configured alert thresholds
+type StorageThresholds struct {
+	WarningPercent  int `json:"warning_percent"`
+	CriticalPercent int `json:"critical_percent"`
+	ExhaustionDays  int `json:"exhaustion_days"`
+}
+
+// StorageHealthReport is the result of a storage capacity check
+type StorageHealthReport struct {
+	Status                  string            `json:"status"`
+	Backend                 string            `json:"backend"`
+	Disk                    *DiskCapacity     `json:"disk,omitempty"`
+	DiskError               string            `json:"disk_error,omitempty"`
+	Bucket                  *BucketUsage      `json:"bucket,omitempty"`
+	BucketError             string            `json:"bucket_error,omitempty"`
+	BlobCount               int64             `json:"blob_count"`
+	BlobBytes               int64             `json:"blob_bytes"`
+	Growth                  StorageGrowth     `json:"growth"`
+	DaysUntilExhaustion     *float64          `json:"days_until_exhaustion,omitempty"`
+	ProjectedExhaustionDate *time.Time        `json:"projected_exhaustion_date,omitempty"`
+	Alerts                  []StorageAlert    `json:"alerts"`
+	Thresholds              StorageThresholds `json:"thresholds"`
+	CheckedAt               time.Time         `json:"checked_at"`
+}
+
+// Check gathers current capacity, blob statistics and growth, and evaluates
+// them against the configured thresholds. Only a disk has a capacity to run
+// out of: a bucket reports what it holds, and null storage holds nothing.
+func (s *StorageHealthService) Check() (*StorageHealthReport, error) {
+	now := time.Now()
+	report := &StorageHealthReport{
+		Status:  StorageStatusHealthy,
+		Backend: s.backendName(),
+		Alerts:  []StorageAlert{},
+		Thresholds: StorageThresholds{
+			WarningPercent:  s.cfg.StorageWarningPercent,
+			CriticalPercent: s.cfg.StorageCriticalPercent,
+			ExhaustionDays:  s.cfg.StorageExhaustionDays,
+		},
+		CheckedAt: now,
+	}
+
+	// Blob statistics come from file_hashes since every stored blob has exactly
+	// one row. Blobs no file references are left out: the orphan blob sweep
+	// removes their content
+	var blobs struct {
+		Count int64
+		Total int64
+	}
+	if err := s.db.Model(&models.FileHash{}).
+		Select("COUNT(*) as count, COALESCE(SUM(size), 0) as total").
+		Where("reference_count > 0").
+		Scan(&blobs).Error; err != nil {
+		return nil, fmt.Errorf("error fetching blob statistics: %w", err)
+	}
+	report.BlobCount = blobs.Count
+	report.BlobBytes = blobs.Total
+
+	windowDays := s.cfg.StorageGrowthWindowDays
+	if windowDays <= 0 {
+		windowDays = 30
+	}
+	var growth struct {
+		Count int64
+		Total int64
+	}
+	if err := s.db.Model(&models.FileHash{}).
+		Select("COUNT(*) as count, COALESCE(SUM(size), 0) as total").
+		Where("created_at >= ?", now.AddDate(0, 0, -windowDays)).
+		Scan(&growth).Error; err != nil {
+		return nil, fmt.Errorf("error fetching storage growth: %w", err)
+	}
+	report.Growth = StorageGrowth{
+		WindowDays:  windowDays,
+		BlobsAdded:  growth.Count,
+		BytesAdded:  growth.Total,
+		BytesPerDay: float64(growth.Total) / float64(windowDays),
+	}
+
+	switch {
+	case s.cfg.IsNullStorage():
+		return report, nil
+	case s.cfg.IsObjectStorage():
+		s.checkBucket(report)
+		return report, nil
+	}
+
+	usage, err := utils.GetDiskUsage(s.cfg.StoragePath)
+	if err != nil {
+		report.DiskError = "Unable to read filesystem statistics for storage backend"
+		log.Printf("Storage health: failed to stat storage path: %v", err)
+		return report, nil
+	}
+	report.Disk = &DiskCapacity{
+		TotalBytes:  usage.Total,
+		UsedBytes:   usage.Used,
+		FreeBytes:   usage.Free,
+		UsedPercent: math.Round(usage.UsedPercent()*100) / 100,
+	}
+
+	if report.Growth.BytesPerDay > 0 {
+		days := float64(usage.Free) / report.Growth.BytesPerDay
+		exhaustion := now.Add(time.Duration(days * float64(24*time.Hour)))
+		days = math.Round(days*10) / 10
+		report.DaysUntilExhaustion = &days
+		report.ProjectedExhaustionDate = &exhaustion
+	}
+
+	s.evaluateThresholds(report)
+	return report, nil
+}
+
+// backendName names the storage backend in reports
+func (s *StorageHealthService) backendName() string {
+	switch {
+	case s.cfg.IsNullStorage():
+		return "null"
+	case s.cfg.IsObjectStorage():
+		return "s3"
+	default:
+		return "local"
+	}
+}
+
+// checkBucket totals the blobs in the object storage bucket. Listing a large
+// bucket takes a request per thousand objects, so it is given a minute.
+func (s *StorageHealthService) checkBucket(report *StorageHealthReport) {
+	store, err := OpenBlobStore(s.cfg)
+	if err != nil {
+		report.BucketError = "Unable to open the storage bucket"
+		log.Printf("Storage health: failed to open bucket: %v", err)
+		return
+	}
+	reporter, ok := store.(storage.UsageReporter)
+	if !ok {
+		report.BucketError = "Storage backend cannot report bucket usage"
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	usage, err := reporter.Usage(ctx, "storage/")
+	if err != nil {
+		report.BucketError = "Unable to list the storage bucket"
+		log.Printf("Storage health: failed to list bucket: %v", err)
+		return
+	}
+	report.Bucket = &BucketUsage{
+		ObjectCount: usage.Objects,
+		UsedBytes:   usage.Bytes,
+	}
+}
+
+// evaluateThresholds fills in alerts and the overall status of a report
+func (s *StorageHealthService) evaluateThresholds(report *StorageHealthReport) {
+	usedPercent := report.Disk.UsedPercent
+
+	switch {
+	case s.cfg.StorageCriticalPercent > 0 && usedPercent >= float64(s.cfg.StorageCriticalPercent):
+		report.Alerts = append(report.Alerts, StorageAlert{
+			Severity: StorageStatusCritical,
+			Code:     "DISK_USAGE_CRITICAL",
+			Message:  fmt.Sprintf("Storage disk is %.1f%% full (critical threshold %d%%)", usedPercent, s.cfg.StorageCriticalPercent),
+		})
+	case s.cfg.StorageWarningPercent > 0 && usedPercent >= float64(s.cfg.StorageWarningPercent):
+		report.Alerts = append(report.Alerts, StorageAlert{
+			Severity: StorageStatusWarning,
+			Code:     "DISK_USAGE_HIGH",
+			Message:  fmt.Sprintf("Storage disk is %.1f%% full (warning threshold %d%%)", usedPercent, s.cfg.StorageWarningPercent),
+		})
+	}
+
+	if report.DaysUntilExhaustion != nil && s.cfg.StorageExhaustionDays > 0 &&
+		*report.DaysUntilExhaustion <= float64(s.cfg.StorageExhaustionDays) {
+		report.Alerts = append(report.Alerts, StorageAlert{
+			Severity: StorageStatusWarning,
+			Code:     "PROJECTED_EXHAUSTION",
+			Message: fmt.Sprintf("At the current growth rate storage will be full in %.1f days (%s)",
+				*report.DaysUntilExhaustion, report.ProjectedExhaustionDate.Format("2006-01-02")),
+		})
+	}
+
+	for _, alert := range report.Alerts {
+		if storageStatusRank(alert.Severity) > storageStatusRank(report.Status) {
+			report.Status = alert.Severity
+		}
+	}
+}
+
+// CheckAndAlert runs a capacity check and notifies admins when the status has
+// escalated since the last alert. Recovering resets the level so a later
+// breach alerts again.
+func (s *StorageHealthService) CheckAndAlert() (*StorageHealthReport, error) {
+	report, err := s.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	escalated := storageStatusRank(report.Status) > storageStatusRank(s.lastAlertLevel)
+	s.lastAlertLevel = report.Status
+	s.mu.Unlock()
+
+	if !escalated {
+		return report, nil
+	}
+
+	messages := make([]string, 0, len(report.Alerts))
+	for _, alert := range report.Alerts {
+		messages = append(messages, alert.Message)
+	}
+
+	severity := models.NotificationSeverityWarning
+	if report.Status == StorageStatusCritical {
+		severity = models.NotificationSeverityCritical
+	}
+
+	details := models.NotificationDetails{
+		"status":     report.Status,
+		"alerts":     report.Alerts,
+		"blob_count": report.BlobCount,
+		"blob_bytes": report.BlobBytes,
+	}
+	if report.Disk != nil {
+		details["used_percent"] = report.Disk.UsedPercent
+		details["free_bytes"] = report.Disk.FreeBytes
+	}
+	if report.ProjectedExhaustionDate != nil {
+		details["projected_exhaustion_date"] = report.ProjectedExhaustionDate.Format("2006-01-02")
+	}
+
+	eventSeverity := events.SeverityWarning
+	if report.Status == StorageStatusCritical {
+		eventSeverity = events.SeverityError
+	}
+	events.Publish(events.Event{
+		Type:     events.TypeStorageError,
+		Severity: eventSeverity,
+		Message:  "Storage capacity " + report.Status + ": " + strings.Join(messages, ". "),
+		Details:  details,
+	})
+
+	if err := s.notificationService.NotifyAdmins(NotifyParams{
+		Type:     models.NotificationStorageCapacity,
+		Severity: severity,
+		Title:    "Storage capacity " + report.Status,
+		Message:  strings.Join(messages, ". "),
+		Details:  details,
+	}); err != nil {
+		log.Printf("Storage health: failed to notify admins: %v", err)
+	}
+
+	return report, nil
+}
+
+// StartMonitor runs CheckAndAlert in the background at the given interval
+func (s *StorageHealthService) StartMonitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if _, err := s.CheckAndAlert(); err != nil {
+				log.Printf("Storage health check failed: %v", err)
+				events.Publish(events.Event{
+					Type:     events.TypeStorageError,
+					Severity: events.SeverityError,
+					Message:  "Storage health check failed",
+					Details:  map[string]interface{}{"error": err.Error()},
+				})
+			}
+		}
+	}()
+}
+
+func storageStatusRank(status string) int {
+	switch status {
+	case StorageStatusCritical:
+		return 2
+	case StorageStatusWarning:
+		return 1
+	default:
+		return 0
+	}
+}
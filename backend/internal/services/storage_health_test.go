@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+func TestStorageHealthBackends(t *testing.T) {
+	db := openTestDB(t)
+	fx := &accessFixture{t: t, db: db}
+	fx.file(fx.user("owner"), nil, models.StorageTierHot)
+
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		backend string
+		disk    bool
+	}{
+		{"disk", &config.Config{StorageBackend: "disk", StoragePath: t.TempDir()}, "local", true},
+		{"null", &config.Config{StorageBackend: "null"}, "null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := NewStorageHealthService(db, tt.cfg, nil).Check()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Backend != tt.backend {
+				t.Errorf("got backend %q, want %q", report.Backend, tt.backend)
+			}
+			if (report.Disk != nil) != tt.disk {
+				t.Errorf("got disk %+v, want disk reported %v", report.Disk, tt.disk)
+			}
+			if !tt.disk && (report.DaysUntilExhaustion != nil || len(report.Alerts) > 0) {
+				t.Errorf("projected exhaustion without a capacity: %+v", report)
+			}
+			if report.BlobCount != 1 {
+				t.Errorf("got %d blobs, want 1", report.BlobCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// StorageRollupService keeps the daily storage rollups current, so storage
+// trends are read from one row per day instead of the file table
+type StorageRollupService struct {
+	db *gorm.DB
+}
+
+// NewStorageRollupService creates a new storage rollup service
+func NewStorageRollupService(db *gorm.DB) *StorageRollupService {
+	return &StorageRollupService{db: db}
+}
+
+// Start refreshes today's rollup in the background every interval. The last
+// refresh of a day becomes its final value.
+func (s *StorageRollupService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if err := s.RollupToday(); err != nil {
+				log.Printf("Storage rollup failed: %v", err)
+			}
+		}
+	}()
+}
+
+// RollupToday records the current global storage totals as today's rollup
+func (s *StorageRollupService) RollupToday() error {
+	var files struct {
+		Total int64
+		Count int64
+	}
+	if err := s.db.Model(&models.File{}).
+		Select("COALESCE(SUM(size), 0) as total, COUNT(*) as count").
+		Scan(&files).Error; err != nil {
+		return fmt.Errorf("error summing files: %w", err)
+	}
+
+	var blobs struct {
+		Total int64
+		Count int64
+	}
+	if err := s.db.Model(&models.FileHash{}).
+		Select("COALESCE(SUM(size), 0) as total, COUNT(*) as count").
+		Where("reference_count > 0").
+		Scan(&blobs).Error; err != nil {
+		return fmt.Errorf("error summing blobs: %w", err)
+	}
+
+	saved := files.Total - blobs.Total
+	if saved < 0 {
+		saved = 0
+	}
+	now := time.Now()
+	rollup := models.StorageRollup{
+		Day:          time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		LogicalBytes: files.Total,
+		ActualBytes:  blobs.Total,
+		SavedBytes:   saved,
+		FileCount:    files.Count,
+		BlobCount:    blobs.Count,
+		UpdatedAt:    now,
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"logical_bytes", "actual_bytes", "saved_bytes", "file_count", "blob_count", "updated_at"}),
+	}).Create(&rollup).Error
+}
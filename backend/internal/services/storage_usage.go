@@ -0,0 +1,28 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// ContentCharge returns how many bytes a file of size bytes with the content
+// fileHashID counts towards its owner's storage usage. A user's usage counts
+// each distinct content they hold once, so the file counts at its full size
+// unless another of the owner's live files has the same content. fileID is
+// the file itself, which is left out of the check.
+func ContentCharge(tx *gorm.DB, ownerID, fileHashID, fileID uuid.UUID, size int64) (int64, error) {
+	var others int64
+	if err := tx.Model(&models.File{}).
+		Where("owner_id = ? AND file_hash_id = ? AND id <> ?", ownerID, fileHashID, fileID).
+		Count(&others).Error; err != nil {
+		return 0, fmt.Errorf("error checking the owner's files: %w", err)
+	}
+	if others > 0 {
+		return 0, nil
+	}
+	return size, nil
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+func TestTrashFileReleasesContentOnce(t *testing.T) {
+	db := openTestDB(t)
+	fx := &accessFixture{t: t, db: db}
+
+	owner := fx.user("owner")
+	other := fx.user("other")
+	first := fx.file(owner, nil, models.StorageTierHot)
+
+	// A second copy for the owner and one for another user share the content
+	copyOf := func(file *models.File, ownerID uuid.UUID) *models.File {
+		dup := *file
+		dup.ID = uuid.New()
+		dup.OwnerID = ownerID
+		fx.create(&dup)
+		if err := db.Model(&models.FileHash{}).Where("id = ?", file.FileHashID).
+			Update("reference_count", gorm.Expr("reference_count + 1")).Error; err != nil {
+			t.Fatal(err)
+		}
+		return &dup
+	}
+	second := copyOf(first, owner)
+	theirs := copyOf(first, other)
+	if err := db.Model(&models.User{}).Where("id = ?", owner).Update("storage_used", first.Size).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	usage := func() int64 {
+		t.Helper()
+		var user models.User
+		if err := db.First(&user, "id = ?", owner).Error; err != nil {
+			t.Fatal(err)
+		}
+		return user.StorageUsed
+	}
+
+	tests := []struct {
+		name  string
+		file  *models.File
+		used  int64
+		freed int64
+	}{
+		{"one of two copies", first, first.Size, 0},
+		{"last copy", second, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freed, err := TrashFile(db, tt.file, &owner, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if freed != tt.freed {
+				t.Errorf("freed %d bytes, want %d", freed, tt.freed)
+			}
+			if got := usage(); got != tt.used {
+				t.Errorf("owner uses %d bytes, want %d", got, tt.used)
+			}
+		})
+	}
+
+	// The other user's copy keeps the content stored
+	charge, err := ContentCharge(db, other, theirs.FileHashID, uuid.New(), theirs.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge != 0 {
+		t.Errorf("another copy for a user who holds the content is charged %d bytes", charge)
+	}
+}
+
+func TestOrphanBlobSweep(t *testing.T) {
+	db := openTestDB(t)
+	fx := &accessFixture{t: t, db: db}
+
+	owner := fx.user("owner")
+	kept := fx.file(owner, nil, models.StorageTierHot)
+	orphan := fx.file(owner, nil, models.StorageTierHot)
+	if _, err := TrashFile(db, orphan, &owner, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{StoragePath: t.TempDir()}
+	blobPath := func(file *models.File) string {
+		var fileHash models.FileHash
+		if err := db.First(&fileHash, "id = ?", file.FileHashID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return filepath.Join(cfg.StoragePath, fileHash.StoragePath)
+	}
+	for _, file := range []*models.File{kept, orphan} {
+		if err := os.MkdirAll(filepath.Dir(blobPath(file)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(blobPath(file), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := NewOrphanBlobService(db, cfg)
+	if removed := svc.Sweep(); removed != 1 {
+		t.Fatalf("first sweep removed %d blobs, want 1", removed)
+	}
+	if _, err := os.Stat(blobPath(orphan)); !os.IsNotExist(err) {
+		t.Errorf("unreferenced blob still stored: %v", err)
+	}
+	if _, err := os.Stat(blobPath(kept)); err != nil {
+		t.Errorf("referenced blob removed: %v", err)
+	}
+	var fileHash models.FileHash
+	if err := db.First(&fileHash, "id = ?", orphan.FileHashID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if fileHash.ContentRemovedAt == nil {
+		t.Error("removed blob is not marked")
+	}
+	if removed := svc.Sweep(); removed != 0 {
+		t.Errorf("second sweep removed %d blobs, want 0", removed)
+	}
+}
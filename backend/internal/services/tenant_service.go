@@ -0,0 +1,351 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrInvalidTenant is returned when a tenant request fails validation
+	ErrInvalidTenant = errors.New("invalid tenant")
+	// ErrTenantSlugTaken is returned when another tenant already uses a slug
+	ErrTenantSlugTaken = errors.New("tenant slug is already in use")
+	// ErrTenantShareDomainTaken is returned when another tenant already
+	// serves its share links on a domain
+	ErrTenantShareDomainTaken = errors.New("share domain is already in use")
+	// ErrTenantUserNotFound is returned when a user is not a member of the tenant
+	ErrTenantUserNotFound = errors.New("user not found in tenant")
+	// ErrInvalidTenantUser is returned when a tenant admin's change to a
+	// member is not allowed
+	ErrInvalidTenantUser = errors.New("invalid tenant user change")
+)
+
+// tenantSlugPattern matches slugs that are valid DNS labels
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// colorPattern matches #rrggbb colors
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// TenantQuotaError is returned when an upload would take a tenant's members
+// over the storage quota they share
+type TenantQuotaError struct {
+	StorageQuota int64
+	StorageUsed  int64
+	Additional   int64
+}
+
+func (e *TenantQuotaError) Error() string {
+	return fmt.Sprintf("organization is limited to %d bytes and already holds %d bytes", e.StorageQuota, e.StorageUsed)
+}
+
+// TenantService manages tenants, their settings and their members
+type TenantService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewTenantService creates a new tenant service
+func NewTenantService(db *gorm.DB, cfg *config.Config) *TenantService {
+	return &TenantService{db: db, cfg: cfg}
+}
+
+// TenantRequest is the platform-admin-editable part of a tenant
+type TenantRequest struct {
+	Slug              string `json:"slug" binding:"required,max=63"`
+	Name              string `json:"name" binding:"required,max=255"`
+	IsActive          *bool  `json:"is_active"`
+	DefaultUserQuota  int64  `json:"default_user_quota" binding:"min=0"`
+	StorageQuota      int64  `json:"storage_quota" binding:"min=0"`
+	AllowRegistration *bool  `json:"allow_registration"`
+	ShareDomain       string `json:"share_domain" binding:"max=255"` // one of SHARE_DOMAINS; empty serves links on SHARE_DOMAIN
+	TenantSettingsRequest
+}
+
+// TenantSettingsRequest is the part of a tenant its own admins may edit
+type TenantSettingsRequest struct {
+	LogoURL      string `json:"logo_url" binding:"max=2048"`
+	PrimaryColor string `json:"primary_color"`
+	SupportEmail string `json:"support_email" binding:"omitempty,email,max=255"`
+}
+
+// TenantWithUsage is a tenant together with its member count and the storage
+// they use
+type TenantWithUsage struct {
+	models.Tenant
+	UserCount   int64 `json:"user_count"`
+	StorageUsed int64 `json:"storage_used"`
+}
+
+// TenantUserUpdate is a tenant admin's change to one of the tenant's members
+type TenantUserUpdate struct {
+	Role     *string `json:"role" binding:"omitempty,oneof=user tenant_admin"`
+	IsActive *bool   `json:"is_active"`
+}
+
+// tenantUsage selects a tenant's member count and storage used
+const tenantUsage = `tenants.*,
+	(SELECT COUNT(*) FROM users WHERE users.tenant_id = tenants.id AND users.deleted_at IS NULL) AS user_count,
+	(SELECT COALESCE(SUM(users.storage_used), 0) FROM users WHERE users.tenant_id = tenants.id AND users.deleted_at IS NULL) AS storage_used`
+
+// ListTenants returns every tenant with its usage, by name
+func (s *TenantService) ListTenants() ([]TenantWithUsage, error) {
+	var tenants []TenantWithUsage
+	if err := s.db.Model(&models.Tenant{}).
+		Select(tenantUsage).
+		Order("tenants.name ASC").
+		Scan(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// GetTenant returns a tenant with its usage
+func (s *TenantService) GetTenant(id uuid.UUID) (*TenantWithUsage, error) {
+	var tenants []TenantWithUsage
+	if err := s.db.Model(&models.Tenant{}).
+		Select(tenantUsage).
+		Where("tenants.id = ?", id).
+		Scan(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tenant: %w", err)
+	}
+	if len(tenants) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &tenants[0], nil
+}
+
+// CreateTenant adds a new tenant
+func (s *TenantService) CreateTenant(req TenantRequest) (*models.Tenant, error) {
+	tenant := &models.Tenant{IsActive: true, AllowRegistration: true}
+	if err := s.applyTenantRequest(tenant, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkUnique(tenant, nil); err != nil {
+		return nil, err
+	}
+
+	tenant.ID = uuid.New()
+	if err := s.db.Create(tenant).Error; err != nil {
+		return nil, fmt.Errorf("error creating tenant: %w", err)
+	}
+	return tenant, nil
+}
+
+// UpdateTenant replaces the settings of a tenant
+func (s *TenantService) UpdateTenant(id uuid.UUID, req TenantRequest) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := s.db.First(&tenant, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.applyTenantRequest(&tenant, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkUnique(&tenant, &tenant.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(&tenant).Error; err != nil {
+		return nil, fmt.Errorf("error updating tenant: %w", err)
+	}
+	return &tenant, nil
+}
+
+// UpdateSettings replaces a tenant's name and branding and, when given,
+// whether it allows registration. Tenant admins may not change the slug,
+// quotas or active status, which the platform admin controls
+func (s *TenantService) UpdateSettings(id uuid.UUID, name string, allowRegistration *bool, req TenantSettingsRequest) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := s.db.First(&tenant, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTenant)
+	}
+	tenant.Name = name
+	if allowRegistration != nil {
+		tenant.AllowRegistration = *allowRegistration
+	}
+	if err := applyTenantSettings(&tenant, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(&tenant).Error; err != nil {
+		return nil, fmt.Errorf("error updating tenant settings: %w", err)
+	}
+	return &tenant, nil
+}
+
+// ListUsers returns a page of the tenant's members, newest first, and how
+// many there are
+func (s *TenantService) ListUsers(tenantID uuid.UUID, page, limit int) ([]models.User, int64, error) {
+	query := s.db.Model(&models.User{}).Scopes(models.InTenant(&tenantID))
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting tenant users: %w", err)
+	}
+
+	var users []models.User
+	if err := query.Order("created_at DESC, id").
+		Limit(limit).Offset((page - 1) * limit).
+		Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("error fetching tenant users: %w", err)
+	}
+	return users, total, nil
+}
+
+// UpdateUser changes the role or active status of one of the tenant's
+// members. Admins cannot change their own account, so a tenant always keeps
+// the admin making the change
+func (s *TenantService) UpdateUser(tenantID, adminID, userID uuid.UUID, req TenantUserUpdate) (*models.User, error) {
+	var user models.User
+	if err := s.db.Scopes(models.InTenant(&tenantID)).First(&user, "users.id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantUserNotFound
+		}
+		return nil, fmt.Errorf("error finding tenant user: %w", err)
+	}
+	if user.ID == adminID {
+		return nil, fmt.Errorf("%w: you cannot change your own account", ErrInvalidTenantUser)
+	}
+	if user.Role == models.RoleGuest && req.Role != nil {
+		return nil, fmt.Errorf("%w: guest accounts keep the guest role", ErrInvalidTenantUser)
+	}
+
+	updates := map[string]interface{}{}
+	if req.Role != nil {
+		updates["role"] = *req.Role
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if len(updates) == 0 {
+		return &user, nil
+	}
+
+	if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("error updating tenant user: %w", err)
+	}
+	return &user, nil
+}
+
+// CheckQuota reports a *TenantQuotaError when adding bytes would take the
+// tenant's members over the storage quota they share. Platform users and
+// tenants without a quota are not limited
+func (s *TenantService) CheckQuota(tenantID *uuid.UUID, bytes int64) error {
+	if tenantID == nil || bytes <= 0 {
+		return nil
+	}
+
+	tenant, err := s.GetTenant(*tenantID)
+	if err != nil {
+		return fmt.Errorf("error checking tenant quota: %w", err)
+	}
+	if tenant.StorageQuota > 0 && tenant.StorageUsed+bytes > tenant.StorageQuota {
+		return &TenantQuotaError{
+			StorageQuota: tenant.StorageQuota,
+			StorageUsed:  tenant.StorageUsed,
+			Additional:   bytes,
+		}
+	}
+	return nil
+}
+
+// checkUnique returns ErrTenantSlugTaken or ErrTenantShareDomainTaken when
+// a tenant other than except uses the tenant's slug or share domain
+func (s *TenantService) checkUnique(tenant *models.Tenant, except *uuid.UUID) error {
+	taken, err := s.taken("slug", tenant.Slug, except)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrTenantSlugTaken
+	}
+	if tenant.ShareDomain == nil {
+		return nil
+	}
+	if taken, err = s.taken("share_domain", *tenant.ShareDomain, except); err != nil {
+		return err
+	}
+	if taken {
+		return ErrTenantShareDomainTaken
+	}
+	return nil
+}
+
+// taken reports whether a tenant other than except has the value in column
+func (s *TenantService) taken(column, value string, except *uuid.UUID) (bool, error) {
+	query := s.db.Model(&models.Tenant{}).Where(column+" = ?", value)
+	if except != nil {
+		query = query.Where("id <> ?", *except)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, fmt.Errorf("error checking tenant %s: %w", column, err)
+	}
+	return count > 0, nil
+}
+
+func (s *TenantService) applyTenantRequest(tenant *models.Tenant, req TenantRequest) error {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !tenantSlugPattern.MatchString(slug) {
+		return fmt.Errorf("%w: slug must be lowercase letters, digits and inner hyphens", ErrInvalidTenant)
+	}
+
+	// Custom share domains must be configured, since the reverse proxy needs
+	// a certificate for them, and SHARE_DOMAIN belongs to the platform
+	var shareDomain *string
+	if domain := strings.ToLower(strings.TrimSpace(req.ShareDomain)); domain != "" {
+		if !slices.Contains(s.cfg.ShareDomains, domain) || domain == s.cfg.ShareDomain {
+			return fmt.Errorf("%w: share_domain must be one of SHARE_DOMAINS other than SHARE_DOMAIN", ErrInvalidTenant)
+		}
+		shareDomain = &domain
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTenant)
+	}
+	if err := applyTenantSettings(tenant, req.TenantSettingsRequest); err != nil {
+		return err
+	}
+
+	tenant.Slug = slug
+	tenant.Name = name
+	tenant.ShareDomain = shareDomain
+	if req.IsActive != nil {
+		tenant.IsActive = *req.IsActive
+	}
+	tenant.DefaultUserQuota = req.DefaultUserQuota
+	tenant.StorageQuota = req.StorageQuota
+	if req.AllowRegistration != nil {
+		tenant.AllowRegistration = *req.AllowRegistration
+	}
+	return nil
+}
+
+func applyTenantSettings(tenant *models.Tenant, req TenantSettingsRequest) error {
+	logoURL := strings.TrimSpace(req.LogoURL)
+	if logoURL != "" && !strings.HasPrefix(logoURL, "https://") && !strings.HasPrefix(logoURL, "http://") {
+		return fmt.Errorf("%w: logo_url must be an http or https URL", ErrInvalidTenant)
+	}
+	color := strings.TrimSpace(req.PrimaryColor)
+	if color != "" && !colorPattern.MatchString(color) {
+		return fmt.Errorf("%w: primary_color must look like #1a2b3c", ErrInvalidTenant)
+	}
+
+	tenant.LogoURL = logoURL
+	tenant.PrimaryColor = strings.ToLower(color)
+	tenant.SupportEmail = strings.TrimSpace(req.SupportEmail)
+	return nil
+}
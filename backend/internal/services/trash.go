@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// ErrFileAlreadyTrashed is returned by TrashFile when the file was deleted in
+// the meantime
+var ErrFileAlreadyTrashed = errors.New("file already in the trash")
+
+// TrashFile moves a file to the trash in the caller's transaction: it marks
+// the file deleted, releases its reference to the content and takes it out of
+// the owner's storage and its folders' totals. The hash record stays, since
+// deleted files still point at it; the orphan blob sweep removes its content
+// once nothing references it. It returns how many bytes of content are
+// no longer stored at all.
+func TrashFile(tx *gorm.DB, file *models.File, actorID *uuid.UUID, payload models.FileEventPayload) (int64, error) {
+	now := time.Now()
+	result := tx.Model(&models.File{}).Where("id = ?", file.ID).Updates(map[string]interface{}{
+		"deleted_at": now,
+		"updated_at": now,
+	})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error deleting file: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrFileAlreadyTrashed
+	}
+
+	var fileHash models.FileHash
+	if err := tx.Where("id = ?", file.FileHashID).First(&fileHash).Error; err != nil {
+		return 0, fmt.Errorf("error fetching file hash: %w", err)
+	}
+	newRefCount := fileHash.ReferenceCount - 1
+	if err := tx.Model(&fileHash).Update("reference_count", newRefCount).Error; err != nil {
+		return 0, fmt.Errorf("error updating reference count: %w", err)
+	}
+
+	// With no more references the content no longer counts as stored, and the
+	// owner stops paying for it with their last file of that content
+	actualStorageFreed := int64(0)
+	if newRefCount <= 0 {
+		actualStorageFreed = file.Size
+	}
+	released, err := ContentCharge(tx, file.OwnerID, file.FileHashID, file.ID, file.Size)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", file.OwnerID).Updates(map[string]interface{}{
+		"storage_used":         gorm.Expr("storage_used - ?", released),
+		"actual_storage_bytes": gorm.Expr("actual_storage_bytes - ?", actualStorageFreed),
+	}).Error; err != nil {
+		return 0, fmt.Errorf("error updating storage stats: %w", err)
+	}
+
+	if err := AdjustFolderStats(tx, file.FolderID, -1, -file.Size); err != nil {
+		return 0, err
+	}
+
+	if payload == nil {
+		payload = models.FileEventPayload{}
+	}
+	payload["filename"] = file.OriginalFilename
+	payload["folder_id"] = file.FolderID
+	if err := RecordFileEvent(tx, FileEventParams{
+		FileID:  file.ID,
+		OwnerID: file.OwnerID,
+		ActorID: actorID,
+		Type:    models.FileEventDeleted,
+		Payload: payload,
+	}); err != nil {
+		return 0, err
+	}
+	return actualStorageFreed, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/database"
+)
+
+// TriggerFile is a file the user uploaded, as returned by the new-files
+// polling trigger
+type TriggerFile struct {
+	ID        int64      `json:"id"`     // sequence of the upload's event; unique and stable
+	Cursor    string     `json:"cursor"` // pass as since to get only newer files
+	FileID    uuid.UUID  `json:"file_id"`
+	Filename  string     `json:"filename"`
+	Size      int64      `json:"size"`
+	MimeType  string     `json:"mime_type"`
+	FolderID  *uuid.UUID `json:"folder_id"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TriggerShare is a file shared with the user, as returned by the
+// new-shares polling trigger
+type TriggerShare struct {
+	ID               int64      `json:"id"`     // sequence of the share's event; unique and stable
+	Cursor           string     `json:"cursor"` // pass as since to get only newer shares
+	ShareID          string     `json:"share_id"`
+	FileID           uuid.UUID  `json:"file_id"`
+	Filename         string     `json:"filename"`
+	Size             int64      `json:"size"`
+	MimeType         string     `json:"mime_type"`
+	Permission       string     `json:"permission"`
+	SharedByID       *uuid.UUID `json:"shared_by_id"`
+	SharedByUsername string     `json:"shared_by_username"`
+	ExpiresAt        *string    `json:"expires_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// TriggerService answers the polling triggers of automation services such as
+// Zapier and IFTTT. Triggers read the file event log, so their items keep
+// the ids and order of the change feed
+type TriggerService struct {
+	db *gorm.DB
+}
+
+// NewTriggerService creates a new trigger service
+func NewTriggerService(db *gorm.DB) *TriggerService {
+	return &TriggerService{db: db}
+}
+
+// NewFiles returns the files the user uploaded that still exist, newest
+// first. See pollEvents for since and limit; an invalid since is
+// ErrInvalidChangeCursor
+func (s *TriggerService) NewFiles(ctx context.Context, userID uuid.UUID, since string, limit int) ([]TriggerFile, error) {
+	query := s.db.WithContext(ctx).Table("file_events e").
+		Select("e.sequence AS id, e.tx_id, e.file_id, f.original_filename AS filename, f.size, f.mime_type, f.folder_id, e.created_at").
+		Joins("JOIN files f ON f.id = e.file_id AND f.deleted_at IS NULL").
+		Where("e.owner_id = ? AND e.type = ?", userID, models.FileEventCreated)
+
+	query, ascending, err := s.pollEvents(query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		TriggerFile
+		TxID int64
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching new files: %w", err)
+	}
+
+	files := make([]TriggerFile, len(rows))
+	for i, row := range rows {
+		if ascending {
+			i = len(rows) - 1 - i
+		}
+		files[i] = row.TriggerFile
+		files[i].Cursor = fmt.Sprintf("%d-%d", row.TxID, row.ID)
+	}
+	return files, nil
+}
+
+// NewShares returns the files other users shared with the user that still
+// exist, newest first. Sharing a file again with a changed permission or
+// expiry counts as a new share. See pollEvents for since and limit
+func (s *TriggerService) NewShares(ctx context.Context, userID uuid.UUID, since string, limit int) ([]TriggerShare, error) {
+	query := s.db.WithContext(ctx).Table("file_events e").
+		Select(`e.sequence AS id, e.tx_id, e.payload->>'share_id' AS share_id, e.file_id,
+			f.original_filename AS filename, f.size, f.mime_type, e.payload->>'permission' AS permission,
+			e.actor_id AS shared_by_id, COALESCE(u.username, '') AS shared_by_username,
+			e.payload->>'expires_at' AS expires_at, e.created_at`).
+		Joins("JOIN files f ON f.id = e.file_id AND f.deleted_at IS NULL").
+		Joins("LEFT JOIN users u ON u.id = e.actor_id").
+		Where("e.type = ? AND e.payload->>'shared_with' = ?", models.FileEventShared, userID.String())
+
+	query, ascending, err := s.pollEvents(query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		TriggerShare
+		TxID int64
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error fetching new shares: %w", err)
+	}
+
+	shares := make([]TriggerShare, len(rows))
+	for i, row := range rows {
+		if ascending {
+			i = len(rows) - 1 - i
+		}
+		shares[i] = row.TriggerShare
+		shares[i].Cursor = fmt.Sprintf("%d-%d", row.TxID, row.ID)
+	}
+	return shares, nil
+}
+
+// pollEvents pages a file event query for a trigger. Without since it selects
+// the latest limit events, newest first. With since, the cursor of an earlier
+// item, it selects the oldest limit events after it in ascending order, for
+// the caller to reverse; a client that always passes the cursor of the newest
+// item it has seen then never skips any. Like the change feed, events of
+// transactions still running are held back until they are all finished, so
+// none can commit behind a cursor.
+func (s *TriggerService) pollEvents(query *gorm.DB, since string, limit int) (*gorm.DB, bool, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	txID, sequence, err := parseChangeCursor(since)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// SQLite runs one write transaction at a time, so events commit in
+	// sequence order and tx_id is always zero
+	sqlite := database.IsSQLite(s.db)
+	if !sqlite {
+		query = query.Where("e.tx_id < pg_snapshot_xmin(pg_current_snapshot())")
+	}
+
+	direction := "DESC"
+	if since != "" {
+		direction = "ASC"
+		if sqlite {
+			query = query.Where("e.sequence > ?", sequence)
+		} else {
+			query = query.Where("(e.tx_id, e.sequence) > (?::text::xid8, ?)", txID, sequence)
+		}
+	}
+	if sqlite {
+		query = query.Order("e.sequence " + direction)
+	} else {
+		query = query.Order("e.tx_id " + direction).Order("e.sequence " + direction)
+	}
+
+	return query.Limit(limit), since != "", nil
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// TusChecksumAlgorithms lists the Upload-Checksum algorithms accepted, for
+// the Tus-Checksum-Algorithm header
+const TusChecksumAlgorithms = "md5,sha1,sha256"
+
+var tusChecksumHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+var (
+	// ErrTusUploadNotFound is returned for uploads that do not exist, belong to someone else or expired
+	ErrTusUploadNotFound = apperrors.ErrNotFound.WithCode("UPLOAD_NOT_FOUND", "upload not found or expired")
+	// ErrTusOffsetMismatch is returned for data sent for an offset the upload is not at
+	ErrTusOffsetMismatch = apperrors.ErrConflict.WithCode("UPLOAD_OFFSET_MISMATCH", "Upload-Offset does not match the offset of the upload").
+				Explain("Ask for the upload's offset with a HEAD request and resume from there")
+	// ErrTusUploadLocked is returned while another request is writing to the upload
+	ErrTusUploadLocked = apperrors.ErrConflict.WithCode("UPLOAD_LOCKED", "another request is writing to this upload")
+	// ErrTusChecksumUnsupported is returned for Upload-Checksum headers that cannot be checked
+	ErrTusChecksumUnsupported = apperrors.ErrInvalidInput.WithCode("CHECKSUM_UNSUPPORTED", "Upload-Checksum must be one of "+TusChecksumAlgorithms+" and a base64 digest")
+	// ErrTusChecksumMismatch is returned for data that does not match its Upload-Checksum
+	ErrTusChecksumMismatch = apperrors.ErrInvalidInput.WithCode("CHECKSUM_MISMATCH", "the data does not match its Upload-Checksum").
+				Explain("The data was corrupted on the way and was not stored. Send it again from the same offset")
+)
+
+// tusLocks holds the IDs of uploads a request is writing to, so two requests
+// never append to the same upload at once
+var tusLocks sync.Map
+
+// TusChecksum is the checksum a client sent with the data of a PATCH
+type TusChecksum struct {
+	newHash func() hash.Hash
+	sum     []byte
+}
+
+// ParseTusChecksum reads an Upload-Checksum header, the algorithm and the
+// base64 digest separated by a space
+func ParseTusChecksum(header string) (*TusChecksum, error) {
+	algorithm, digest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	newHash, ok := tusChecksumHashes[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, ErrTusChecksumUnsupported
+	}
+	sum, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(sum) != newHash().Size() {
+		return nil, ErrTusChecksumUnsupported
+	}
+	return &TusChecksum{newHash: newHash, sum: sum}, nil
+}
+
+// CreateTusUploadParams describes an upload to be sent with the tus protocol
+type CreateTusUploadParams struct {
+	OwnerID          uuid.UUID
+	FolderID         *uuid.UUID
+	Filename         string
+	MimeType         string
+	Size             int64
+	Metadata         string
+	IsPublic         bool
+	EncryptionHeader string
+}
+
+// TusUploadService keeps tus uploads while their data arrives. The data is
+// appended to one file per upload under the upload temp directory until the
+// upload completes or expires.
+type TusUploadService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewTusUploadService creates a new tus upload service
+func NewTusUploadService(db *gorm.DB, cfg *config.Config) *TusUploadService {
+	return &TusUploadService{db: db, cfg: cfg}
+}
+
+// Create starts an upload of params.Size bytes
+func (s *TusUploadService) Create(params CreateTusUploadParams) (*models.TusUpload, error) {
+	upload := &models.TusUpload{
+		ID:               uuid.New(),
+		OwnerID:          params.OwnerID,
+		FolderID:         params.FolderID,
+		Filename:         params.Filename,
+		MimeType:         params.MimeType,
+		Size:             params.Size,
+		Metadata:         params.Metadata,
+		IsPublic:         params.IsPublic,
+		EncryptionHeader: params.EncryptionHeader,
+		ExpiresAt:        time.Now().Add(time.Duration(s.cfg.UploadSessionTTL) * time.Hour),
+	}
+	if err := s.db.Create(upload).Error; err != nil {
+		return nil, fmt.Errorf("error creating tus upload: %w", err)
+	}
+	return upload, nil
+}
+
+// Get returns an owner's unexpired upload
+func (s *TusUploadService) Get(ownerID, uploadID uuid.UUID) (*models.TusUpload, error) {
+	var upload models.TusUpload
+	err := s.db.Where("id = ? AND owner_id = ? AND expires_at > ?", uploadID, ownerID, time.Now()).
+		First(&upload).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTusUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tus upload: %w", err)
+	}
+	return &upload, nil
+}
+
+// path returns where an upload's data is kept
+func (s *TusUploadService) path(uploadID uuid.UUID) string {
+	return filepath.Join(s.cfg.UploadTempDir, "tus", uploadID.String())
+}
+
+// Append writes the data read from r to the upload at offset, which must be
+// the upload's current offset, and returns the upload with its new offset.
+// Data past the upload's length is ignored. Without a checksum, whatever
+// arrived before a dropped connection is kept so the client resumes after
+// it; with one, the data counts only if all of it arrived and matches.
+func (s *TusUploadService) Append(upload *models.TusUpload, offset int64, r io.Reader, checksum *TusChecksum) (*models.TusUpload, error) {
+	if offset != upload.Offset {
+		return nil, ErrTusOffsetMismatch.WithDetail("offset", upload.Offset)
+	}
+	if _, busy := tusLocks.LoadOrStore(upload.ID, struct{}{}); busy {
+		return nil, ErrTusUploadLocked
+	}
+	defer tusLocks.Delete(upload.ID)
+
+	if err := os.MkdirAll(filepath.Dir(s.path(upload.ID)), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	file, err := os.OpenFile(s.path(upload.ID), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	// Drop anything written past the recorded offset by a request that failed
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate upload file: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek upload file: %w", err)
+	}
+
+	var dst io.Writer = file
+	var hasher hash.Hash
+	if checksum != nil {
+		hasher = checksum.newHash()
+		dst = io.MultiWriter(file, hasher)
+	}
+	n, copyErr := io.Copy(dst, io.LimitReader(r, upload.Size-offset))
+	if copyErr == nil {
+		copyErr = file.Sync()
+	}
+	if closeErr := file.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil && (checksum != nil || n == 0) {
+		return nil, fmt.Errorf("failed to store upload data: %w", copyErr)
+	}
+	if checksum != nil && !bytes.Equal(hasher.Sum(nil), checksum.sum) {
+		return nil, ErrTusChecksumMismatch
+	}
+
+	if n > 0 {
+		result := s.db.Model(&models.TusUpload{}).
+			Where("id = ? AND upload_offset = ?", upload.ID, offset).
+			Update("upload_offset", offset+n)
+		if result.Error != nil {
+			return nil, fmt.Errorf("error recording upload offset: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrTusOffsetMismatch
+		}
+	}
+	if copyErr != nil {
+		return nil, fmt.Errorf("failed to store upload data: %w", copyErr)
+	}
+
+	updated := *upload
+	updated.Offset = offset + n
+	return &updated, nil
+}
+
+// Open returns the content of a complete upload
+func (s *TusUploadService) Open(upload *models.TusUpload) (io.ReadCloser, error) {
+	// Empty uploads never receive a PATCH, so they have no file
+	if upload.Size == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return os.Open(s.path(upload.ID))
+}
+
+// Delete discards an upload and its data
+func (s *TusUploadService) Delete(upload *models.TusUpload) error {
+	if err := s.db.Delete(&models.TusUpload{}, "id = ?", upload.ID).Error; err != nil {
+		return fmt.Errorf("error deleting tus upload: %w", err)
+	}
+	if err := os.Remove(s.path(upload.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove upload data: %w", err)
+	}
+	return nil
+}
+
+// Start discards expired uploads in the background
+func (s *TusUploadService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if removed, err := s.DeleteExpired(); err != nil {
+				log.Printf("tus upload cleanup failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("tus upload cleanup: discarded %d expired upload(s)", removed)
+			}
+		}
+	}()
+}
+
+// DeleteExpired discards uploads past their expiry and returns how many
+func (s *TusUploadService) DeleteExpired() (int, error) {
+	var uploads []models.TusUpload
+	if err := s.db.Select("id").Where("expires_at <= ?", time.Now()).Find(&uploads).Error; err != nil {
+		return 0, fmt.Errorf("error fetching expired tus uploads: %w", err)
+	}
+	for i := range uploads {
+		if err := s.Delete(&uploads[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(uploads), nil
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// Upload policy violation codes
+const (
+	PolicyCodeExtensionBlocked = "EXTENSION_BLOCKED"
+	PolicyCodeTypeBlocked      = "FILE_TYPE_BLOCKED"
+	PolicyCodeSizeExceeded     = "POLICY_SIZE_EXCEEDED"
+	PolicyCodePublicNotAllowed = "PUBLIC_SHARING_NOT_ALLOWED"
+)
+
+// ErrInvalidPolicy is returned when an upload policy request fails validation
+var ErrInvalidPolicy = errors.New("invalid upload policy")
+
+// UploadPolicyService manages admin-defined upload policies and evaluates files against them
+type UploadPolicyService struct {
+	db *gorm.DB
+}
+
+// NewUploadPolicyService creates a new upload policy service
+func NewUploadPolicyService(db *gorm.DB) *UploadPolicyService {
+	return &UploadPolicyService{db: db}
+}
+
+// UploadPolicyRequest is the admin-editable part of an upload policy
+type UploadPolicyRequest struct {
+	Role        string                 `json:"role"`
+	MatchType   models.PolicyMatchType `json:"match_type" binding:"required"`
+	Pattern     string                 `json:"pattern" binding:"required"`
+	Blocked     bool                   `json:"blocked"`
+	MaxSize     *int64                 `json:"max_size"`
+	AllowPublic *bool                  `json:"allow_public"`
+	Description string                 `json:"description"`
+}
+
+// PolicyFile describes a file to evaluate against upload policies
+type PolicyFile struct {
+	Filename string `json:"filename" binding:"required"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+// PolicyDecision is the outcome of evaluating a file against upload policies
+type PolicyDecision struct {
+	Filename        string      `json:"filename"`
+	Allowed         bool        `json:"allowed"`
+	Code            string      `json:"code,omitempty"`
+	Reason          string      `json:"reason,omitempty"`
+	MaxSize         *int64      `json:"max_size,omitempty"`
+	PublicAllowed   bool        `json:"public_allowed"`
+	MatchedPolicies []uuid.UUID `json:"matched_policies"`
+}
+
+// ListPolicies returns every policy, or only those applying to a role when one is given
+func (s *UploadPolicyService) ListPolicies(role string) ([]models.UploadPolicy, error) {
+	var policies []models.UploadPolicy
+
+	query := s.db.Order("role ASC, match_type ASC, pattern ASC")
+	if role != "" {
+		query = query.Where("role = '' OR role = ?", role)
+	}
+
+	if err := query.Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("error fetching upload policies: %w", err)
+	}
+	return policies, nil
+}
+
+// CreatePolicy adds a new upload policy
+func (s *UploadPolicyService) CreatePolicy(req UploadPolicyRequest, createdBy uuid.UUID) (*models.UploadPolicy, error) {
+	policy := &models.UploadPolicy{CreatedBy: &createdBy}
+	if err := applyPolicyRequest(policy, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(policy).Error; err != nil {
+		return nil, fmt.Errorf("error creating upload policy: %w", err)
+	}
+	return policy, nil
+}
+
+// UpdatePolicy replaces the settings of an existing upload policy
+func (s *UploadPolicyService) UpdatePolicy(id uuid.UUID, req UploadPolicyRequest) (*models.UploadPolicy, error) {
+	var policy models.UploadPolicy
+	if err := s.db.First(&policy, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := applyPolicyRequest(&policy, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(&policy).Error; err != nil {
+		return nil, fmt.Errorf("error updating upload policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// DeletePolicy removes an upload policy
+func (s *UploadPolicyService) DeletePolicy(id uuid.UUID) error {
+	result := s.db.Delete(&models.UploadPolicy{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("error deleting upload policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Evaluate checks files against the policies for a role. Every matching
+// policy applies: any block wins, the smallest size limit wins, and public
+// sharing is only allowed when no matching policy forbids it.
+func (s *UploadPolicyService) Evaluate(role string, files []PolicyFile, isPublic bool) ([]PolicyDecision, error) {
+	policies, err := s.ListPolicies(role)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := make([]PolicyDecision, 0, len(files))
+	for _, file := range files {
+		decisions = append(decisions, EvaluatePolicies(policies, file, isPublic))
+	}
+	return decisions, nil
+}
+
+// EvaluatePolicies checks a file against an already loaded set of policies
+func EvaluatePolicies(policies []models.UploadPolicy, file PolicyFile, isPublic bool) PolicyDecision {
+	decision := PolicyDecision{
+		Filename:        file.Filename,
+		Allowed:         true,
+		PublicAllowed:   true,
+		MatchedPolicies: []uuid.UUID{},
+	}
+
+	extension := strings.ToLower(filepath.Ext(file.Filename))
+	mimeType := strings.ToLower(file.MimeType)
+
+	for _, policy := range policies {
+		if !policyMatches(policy, extension, mimeType) {
+			continue
+		}
+		decision.MatchedPolicies = append(decision.MatchedPolicies, policy.ID)
+
+		if policy.Blocked && decision.Code == "" {
+			decision.Allowed = false
+			if policy.MatchType == models.PolicyMatchExtension {
+				decision.Code = PolicyCodeExtensionBlocked
+				decision.Reason = fmt.Sprintf("Files with the %s extension are not allowed", extension)
+			} else {
+				decision.Code = PolicyCodeTypeBlocked
+				decision.Reason = fmt.Sprintf("Files of type %s are not allowed", file.MimeType)
+			}
+		}
+
+		if policy.MaxSize != nil && (decision.MaxSize == nil || *policy.MaxSize < *decision.MaxSize) {
+			limit := *policy.MaxSize
+			decision.MaxSize = &limit
+		}
+
+		if !policy.AllowPublic {
+			decision.PublicAllowed = false
+		}
+	}
+
+	if decision.Allowed && decision.MaxSize != nil && file.Size > *decision.MaxSize {
+		decision.Allowed = false
+		decision.Code = PolicyCodeSizeExceeded
+		decision.Reason = fmt.Sprintf("Files of this type may not exceed %.2f MB", float64(*decision.MaxSize)/(1024*1024))
+	}
+
+	if decision.Allowed && isPublic && !decision.PublicAllowed {
+		decision.Allowed = false
+		decision.Code = PolicyCodePublicNotAllowed
+		decision.Reason = "Files of this type cannot be shared publicly"
+	}
+
+	return decision
+}
+
+// policyMatches reports whether a policy applies to a file's extension or MIME type.
+// MIME patterns may end in "/*" to match a whole family such as "video/*".
+func policyMatches(policy models.UploadPolicy, extension, mimeType string) bool {
+	pattern := strings.ToLower(policy.Pattern)
+
+	switch policy.MatchType {
+	case models.PolicyMatchExtension:
+		return extension != "" && extension == pattern
+	case models.PolicyMatchMime:
+		if pattern == "*" || pattern == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") {
+			return strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*"))
+		}
+		return mimeType == pattern
+	}
+	return false
+}
+
+// applyPolicyRequest validates a request and copies it onto a policy
+func applyPolicyRequest(policy *models.UploadPolicy, req UploadPolicyRequest) error {
+	pattern := strings.ToLower(strings.TrimSpace(req.Pattern))
+
+	switch req.MatchType {
+	case models.PolicyMatchExtension:
+		if !strings.HasPrefix(pattern, ".") {
+			pattern = "." + pattern
+		}
+	case models.PolicyMatchMime:
+		if pattern != "*" && !strings.Contains(pattern, "/") {
+			return fmt.Errorf("%w: mime pattern must look like 'type/subtype' or 'type/*'", ErrInvalidPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: match_type must be 'mime' or 'extension'", ErrInvalidPolicy)
+	}
+
+	if req.Role != "" && req.Role != string(models.RoleUser) && req.Role != string(models.RoleAdmin) {
+		return fmt.Errorf("%w: role must be empty, 'user' or 'admin'", ErrInvalidPolicy)
+	}
+
+	if req.MaxSize != nil && *req.MaxSize <= 0 {
+		return fmt.Errorf("%w: max_size must be positive", ErrInvalidPolicy)
+	}
+
+	policy.Role = req.Role
+	policy.MatchType = req.MatchType
+	policy.Pattern = pattern
+	policy.Blocked = req.Blocked
+	policy.MaxSize = req.MaxSize
+	policy.AllowPublic = req.AllowPublic == nil || *req.AllowPublic
+	policy.Description = req.Description
+	return nil
+}
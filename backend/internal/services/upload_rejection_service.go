@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/models"
+)
+
+// UploadRejectionService records refused uploads and summarises them for admins
+type UploadRejectionService struct {
+	db *gorm.DB
+}
+
+// NewUploadRejectionService creates a new upload rejection service
+func NewUploadRejectionService(db *gorm.DB) *UploadRejectionService {
+	return &UploadRejectionService{db: db}
+}
+
+// UploadRejectionFilter selects rejections to list and aggregate
+type UploadRejectionFilter struct {
+	Reason string
+	UserID *uuid.UUID
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+	Offset int
+}
+
+// RejectionReasonSummary counts rejections for one reason
+type RejectionReasonSummary struct {
+	Reason   models.UploadRejectionReason `json:"reason"`
+	Count    int64                        `json:"count"`
+	Users    int64                        `json:"users"`
+	LastSeen time.Time                    `json:"last_seen"`
+}
+
+// RejectionMimeSummary counts rejections for a declared and detected MIME type pair
+type RejectionMimeSummary struct {
+	Reason           models.UploadRejectionReason `json:"reason"`
+	DeclaredMimeType string                       `json:"declared_mime_type"`
+	DetectedMimeType string                       `json:"detected_mime_type"`
+	Count            int64                        `json:"count"`
+}
+
+// RejectionUserSummary counts rejections for one user
+type RejectionUserSummary struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Count    int64     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// UploadRejectionReport is a page of rejections with aggregates over the whole filter
+type UploadRejectionReport struct {
+	Rejections []models.UploadRejection
+	Total      int64
+	ByReason   []RejectionReasonSummary
+	ByMimeType []RejectionMimeSummary
+	TopUsers   []RejectionUserSummary
+}
+
+// Record stores a rejected upload. Failures are logged rather than returned
+// so diagnostics never change the response the client gets.
+func (s *UploadRejectionService) Record(rejection *models.UploadRejection) {
+	if err := s.db.Create(rejection).Error; err != nil {
+		log.Printf("Failed to record upload rejection for %s: %v", rejection.Filename, err)
+	}
+}
+
+// Report lists matching rejections, newest first, with aggregates by reason,
+// MIME type pair and user
+func (s *UploadRejectionService) Report(filter UploadRejectionFilter) (*UploadRejectionReport, error) {
+	query := s.db.Model(&models.UploadRejection{})
+	if filter.Reason != "" {
+		query = query.Where("upload_rejections.reason = ?", filter.Reason)
+	}
+	if filter.UserID != nil {
+		query = query.Where("upload_rejections.user_id = ?", *filter.UserID)
+	}
+	if filter.Since != nil {
+		query = query.Where("upload_rejections.created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("upload_rejections.created_at <= ?", *filter.Until)
+	}
+
+	report := &UploadRejectionReport{}
+
+	if err := query.Session(&gorm.Session{}).Count(&report.Total).Error; err != nil {
+		return nil, fmt.Errorf("error counting upload rejections: %w", err)
+	}
+
+	if err := query.Session(&gorm.Session{}).
+		Preload("User").
+		Order("upload_rejections.created_at DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&report.Rejections).Error; err != nil {
+		return nil, fmt.Errorf("error fetching upload rejections: %w", err)
+	}
+
+	if err := query.Session(&gorm.Session{}).
+		Select("reason, COUNT(*) AS count, COUNT(DISTINCT user_id) AS users, MAX(created_at) AS last_seen").
+		Group("reason").
+		Order("count DESC").
+		Scan(&report.ByReason).Error; err != nil {
+		return nil, fmt.Errorf("error aggregating upload rejections by reason: %w", err)
+	}
+
+	if err := query.Session(&gorm.Session{}).
+		Select("reason, declared_mime_type, detected_mime_type, COUNT(*) AS count").
+		Where("reason IN ?", []models.UploadRejectionReason{models.RejectionMimeMismatch, models.RejectionMimeNotAllowed, models.RejectionPolicyViolation}).
+		Group("reason, declared_mime_type, detected_mime_type").
+		Order("count DESC").
+		Limit(20).
+		Scan(&report.ByMimeType).Error; err != nil {
+		return nil, fmt.Errorf("error aggregating upload rejections by MIME type: %w", err)
+	}
+
+	if err := query.Session(&gorm.Session{}).
+		Select("upload_rejections.user_id, users.username, users.email, COUNT(*) AS count, MAX(upload_rejections.created_at) AS last_seen").
+		Joins("JOIN users ON users.id = upload_rejections.user_id").
+		Group("upload_rejections.user_id, users.username, users.email").
+		Order("count DESC").
+		Limit(10).
+		Scan(&report.TopUsers).Error; err != nil {
+		return nil, fmt.Errorf("error aggregating upload rejections by user: %w", err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/models"
+)
+
+// MaxUploadRules is how many upload rules a user may keep
+const MaxUploadRules = 50
+
+var (
+	// ErrUploadRuleFolderNotFound is returned for rules naming a folder the user does not own
+	ErrUploadRuleFolderNotFound = apperrors.ErrNotFound.WithCode("FOLDER_NOT_FOUND", "target folder not found")
+	// ErrUploadRuleFolderEncrypted is returned for rules naming a folder of an encrypted vault
+	ErrUploadRuleFolderEncrypted = apperrors.ErrInvalidInput.WithCode("ENCRYPTED_FOLDER", "uploads cannot be sent to an encrypted folder by a rule").
+					Explain("Files in encrypted folders are encrypted by the client before upload, so upload them to the folder directly")
+	// ErrInvalidUploadRule is returned for rules that match nothing or are malformed
+	ErrInvalidUploadRule = apperrors.ErrInvalidInput.WithCode("INVALID_UPLOAD_RULE", "each rule needs a mime_type such as image/* or application/pdf, an extension, or both")
+)
+
+// UploadRouting is where a user's uploads that name no folder go: to the
+// folder of the first matching rule, else to the default folder, else to
+// the root folder
+type UploadRouting struct {
+	DefaultFolderID *uuid.UUID
+	Rules           []models.UploadRule
+}
+
+// Route returns the folder for a file of the given name and detected type,
+// or nil for the root folder
+func (r *UploadRouting) Route(filename, mimeType string) *uuid.UUID {
+	for i := range r.Rules {
+		if r.Rules[i].Matches(filename, mimeType) {
+			return &r.Rules[i].FolderID
+		}
+	}
+	return r.DefaultFolderID
+}
+
+// UploadRuleService keeps users' default upload folder and upload rules
+type UploadRuleService struct {
+	db *gorm.DB
+}
+
+// NewUploadRuleService creates a new upload rule service
+func NewUploadRuleService(db *gorm.DB) *UploadRuleService {
+	return &UploadRuleService{db: db}
+}
+
+// Get returns a user's default upload folder and rules, in order
+func (s *UploadRuleService) Get(userID uuid.UUID) (*UploadRouting, error) {
+	var user models.User
+	if err := s.db.Select("id", "default_upload_folder_id").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("error fetching user: %w", err)
+	}
+	routing := &UploadRouting{DefaultFolderID: user.DefaultUploadFolderID}
+	if err := s.db.Where("user_id = ?", userID).Order("position").Find(&routing.Rules).Error; err != nil {
+		return nil, fmt.Errorf("error fetching upload rules: %w", err)
+	}
+	return routing, nil
+}
+
+// ForUpload returns a user's routing with the folders uploads can no longer
+// go to left out: those moved to the trash or into an encrypted vault since
+// the rules were saved
+func (s *UploadRuleService) ForUpload(userID uuid.UUID) (*UploadRouting, error) {
+	routing, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	usable, err := s.usableFolders(userID, routing)
+	if err != nil {
+		return nil, err
+	}
+
+	if routing.DefaultFolderID != nil && !usable[*routing.DefaultFolderID] {
+		routing.DefaultFolderID = nil
+	}
+	rules := routing.Rules[:0]
+	for _, rule := range routing.Rules {
+		if usable[rule.FolderID] {
+			rules = append(rules, rule)
+		}
+	}
+	routing.Rules = rules
+	return routing, nil
+}
+
+// usableFolders returns which of the folders named by a routing the user
+// owns, outside the trash and any encrypted vault
+func (s *UploadRuleService) usableFolders(userID uuid.UUID, routing *UploadRouting) (map[uuid.UUID]bool, error) {
+	ids := make([]uuid.UUID, 0, len(routing.Rules)+1)
+	if routing.DefaultFolderID != nil {
+		ids = append(ids, *routing.DefaultFolderID)
+	}
+	for _, rule := range routing.Rules {
+		ids = append(ids, rule.FolderID)
+	}
+	usable := make(map[uuid.UUID]bool, len(ids))
+	if len(ids) == 0 {
+		return usable, nil
+	}
+
+	var folders []models.Folder
+	if err := s.db.Select("id").Where("id IN ? AND owner_id = ? AND vault_id IS NULL", ids, userID).Find(&folders).Error; err != nil {
+		return nil, fmt.Errorf("error fetching upload folders: %w", err)
+	}
+	for _, folder := range folders {
+		usable[folder.ID] = true
+	}
+	return usable, nil
+}
+
+// Replace sets a user's default upload folder and replaces their rules with
+// the given ones, in order. Each rule's pattern is normalized, and every
+// folder must be the user's own and not encrypted.
+func (s *UploadRuleService) Replace(userID uuid.UUID, defaultFolderID *uuid.UUID, rules []models.UploadRule) (*UploadRouting, error) {
+	if len(rules) > MaxUploadRules {
+		return nil, ErrInvalidUploadRule.WithDetail("max_rules", MaxUploadRules)
+	}
+	routing := &UploadRouting{DefaultFolderID: defaultFolderID, Rules: make([]models.UploadRule, len(rules))}
+	for i, rule := range rules {
+		normalized, err := normalizeUploadRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		normalized.ID = uuid.New()
+		normalized.UserID = userID
+		normalized.Position = i
+		routing.Rules[i] = normalized
+	}
+
+	if err := s.checkFolders(userID, routing); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("default_upload_folder_id", defaultFolderID).Error; err != nil {
+			return fmt.Errorf("error updating default upload folder: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UploadRule{}).Error; err != nil {
+			return fmt.Errorf("error clearing upload rules: %w", err)
+		}
+		if len(routing.Rules) > 0 {
+			if err := tx.Create(&routing.Rules).Error; err != nil {
+				return fmt.Errorf("error saving upload rules: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return routing, nil
+}
+
+// checkFolders makes sure every folder a routing names can take uploads
+func (s *UploadRuleService) checkFolders(userID uuid.UUID, routing *UploadRouting) error {
+	check := func(folderID uuid.UUID) error {
+		var folder models.Folder
+		err := s.db.Select("id", "vault_id").Where("id = ? AND owner_id = ?", folderID, userID).First(&folder).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUploadRuleFolderNotFound.WithDetail("folder_id", folderID)
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching folder: %w", err)
+		}
+		if folder.IsEncrypted() {
+			return ErrUploadRuleFolderEncrypted.WithDetail("folder_id", folderID)
+		}
+		return nil
+	}
+
+	if routing.DefaultFolderID != nil {
+		if err := check(*routing.DefaultFolderID); err != nil {
+			return err
+		}
+	}
+	checked := make(map[uuid.UUID]bool)
+	for _, rule := range routing.Rules {
+		if checked[rule.FolderID] {
+			continue
+		}
+		if err := check(rule.FolderID); err != nil {
+			return err
+		}
+		checked[rule.FolderID] = true
+	}
+	return nil
+}
+
+// normalizeUploadRule lower-cases a rule's patterns and drops the dot of its
+// extension, and refuses rules that match nothing or cannot match
+func normalizeUploadRule(rule models.UploadRule) (models.UploadRule, error) {
+	rule.MimeType = strings.ToLower(strings.TrimSpace(rule.MimeType))
+	rule.Extension = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(rule.Extension), "."))
+
+	if rule.MimeType == "" && rule.Extension == "" {
+		return rule, ErrInvalidUploadRule
+	}
+	if rule.MimeType != "" && !validMimePattern(rule.MimeType) {
+		return rule, ErrInvalidUploadRule.WithDetail("mime_type", rule.MimeType)
+	}
+	if strings.ContainsAny(rule.Extension, "./\\ ") || len(rule.Extension) > 20 {
+		return rule, ErrInvalidUploadRule.WithDetail("extension", rule.Extension)
+	}
+	return rule, nil
+}
+
+// validMimePattern reports whether a lower-case pattern names a type such as
+// application/pdf or a family such as image/*
+func validMimePattern(pattern string) bool {
+	family, subtype, ok := strings.Cut(pattern, "/")
+	if !ok || family == "" || family == "*" || subtype == "" {
+		return false
+	}
+	if subtype == "*" {
+		return true
+	}
+	_, _, err := mime.ParseMediaType(pattern)
+	return err == nil && !strings.Contains(pattern, ";")
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+// minUploadChunkSize keeps clients from splitting an upload into so many
+// chunks that the requests cost more than the data
+const minUploadChunkSize = 64 << 10 // 64KB
+
+// Checksum algorithms clients may verify chunks with
+const (
+	ChunkChecksumSHA256 = "sha256"
+	ChunkChecksumCRC32C = "crc32c"
+)
+
+var (
+	// ErrUploadSessionNotFound is returned for sessions that do not exist, belong to someone else or expired
+	ErrUploadSessionNotFound = apperrors.ErrNotFound.WithCode("UPLOAD_SESSION_NOT_FOUND", "upload session not found or expired")
+	// ErrChunkOutOfRange is returned for chunk indexes past the end of the upload
+	ErrChunkOutOfRange = apperrors.ErrInvalidInput.WithCode("CHUNK_OUT_OF_RANGE", "chunk index is outside the upload")
+	// ErrChunkChecksumRequired is returned for chunks sent without a usable checksum
+	ErrChunkChecksumRequired = apperrors.ErrInvalidInput.WithCode("CHUNK_CHECKSUM_REQUIRED", "chunks must be sent with a hex X-Chunk-SHA256 or X-Chunk-CRC32C header")
+	// ErrChunkChecksumMismatch is returned for chunks whose content does not match their checksum
+	ErrChunkChecksumMismatch = apperrors.ErrInvalidInput.WithCode("CHUNK_CHECKSUM_MISMATCH", "chunk content does not match its checksum").
+					Explain("The chunk was corrupted on the way and was not stored. Send it again")
+	// ErrChunkSizeMismatch is returned for chunks longer or shorter than their place in the upload
+	ErrChunkSizeMismatch = apperrors.ErrInvalidInput.WithCode("CHUNK_SIZE_MISMATCH", "chunk is not the expected size")
+	// ErrUploadIncomplete is returned when completing a session that is still missing chunks
+	ErrUploadIncomplete = apperrors.ErrConflict.WithCode("UPLOAD_INCOMPLETE", "not every chunk of the upload has been received")
+)
+
+// ChunkChecksum is the hex-encoded checksum a client sent with a chunk
+type ChunkChecksum struct {
+	Algorithm string
+	Value     string
+}
+
+// newHash returns a hash computing the checksum, or nil when the checksum
+// cannot be one of its algorithm
+func (c ChunkChecksum) newHash() hash.Hash {
+	switch {
+	case c.Algorithm == ChunkChecksumSHA256 && len(c.Value) == sha256.Size*2:
+		return sha256.New()
+	case c.Algorithm == ChunkChecksumCRC32C && len(c.Value) == crc32.Size*2:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	}
+	return nil
+}
+
+// CreateUploadSessionParams describes an upload to be sent in chunks
+type CreateUploadSessionParams struct {
+	OwnerID          uuid.UUID
+	FolderID         *uuid.UUID
+	Filename         string
+	MimeType         string
+	Size             int64
+	ChunkSize        int64 // 0 for the configured default
+	IsPublic         bool
+	EncryptionHeader string
+}
+
+// UploadSessionService keeps resumable uploads while their chunks arrive.
+// Each verified chunk is kept as its own file under the upload temp
+// directory until the session completes or expires, so a corrupt chunk never
+// overwrites a good one and chunks may be sent in any order.
+type UploadSessionService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+// NewUploadSessionService creates a new upload session service
+func NewUploadSessionService(db *gorm.DB, cfg *config.Config) *UploadSessionService {
+	return &UploadSessionService{db: db, cfg: cfg}
+}
+
+// ChunkSizeLimits returns the smallest and largest chunk sizes clients may choose
+func (s *UploadSessionService) ChunkSizeLimits() (int64, int64) {
+	return min(minUploadChunkSize, s.cfg.UploadChunkMaxSize), s.cfg.UploadChunkMaxSize
+}
+
+// Create starts a session for an upload of params.Size bytes
+func (s *UploadSessionService) Create(params CreateUploadSessionParams) (*models.UploadSession, error) {
+	chunkSize := params.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = s.cfg.UploadChunkSize
+	}
+	if minSize, maxSize := s.ChunkSizeLimits(); chunkSize < minSize || chunkSize > maxSize {
+		return nil, apperrors.ErrInvalidInput.WithCode("INVALID_CHUNK_SIZE", fmt.Sprintf("chunk_size must be between %d and %d bytes", minSize, maxSize))
+	}
+
+	// Empty files still take one, empty, chunk
+	chunkCount := int(max((params.Size+chunkSize-1)/chunkSize, 1))
+	session := &models.UploadSession{
+		ID:               uuid.New(),
+		OwnerID:          params.OwnerID,
+		FolderID:         params.FolderID,
+		Filename:         params.Filename,
+		MimeType:         params.MimeType,
+		Size:             params.Size,
+		ChunkSize:        chunkSize,
+		ChunkCount:       chunkCount,
+		ReceivedChunks:   make([]byte, (chunkCount+7)/8),
+		IsPublic:         params.IsPublic,
+		EncryptionHeader: params.EncryptionHeader,
+		ExpiresAt:        time.Now().Add(time.Duration(s.cfg.UploadSessionTTL) * time.Hour),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("error creating upload session: %w", err)
+	}
+	return session, nil
+}
+
+// Get returns an owner's unexpired session
+func (s *UploadSessionService) Get(ownerID, sessionID uuid.UUID) (*models.UploadSession, error) {
+	var session models.UploadSession
+	err := s.db.Where("id = ? AND owner_id = ? AND expires_at > ?", sessionID, ownerID, time.Now()).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUploadSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching upload session: %w", err)
+	}
+	return &session, nil
+}
+
+// dir returns where a session's chunks are kept
+func (s *UploadSessionService) dir(sessionID uuid.UUID) string {
+	return filepath.Join(s.cfg.UploadTempDir, "chunks", sessionID.String())
+}
+
+// chunkPath returns where chunk i of a session is kept
+func (s *UploadSessionService) chunkPath(sessionID uuid.UUID, i int) string {
+	return filepath.Join(s.dir(sessionID), strconv.Itoa(i))
+}
+
+// PutChunk stores chunk i of a session from r after checking its size and
+// checksum, and returns the session with the chunk marked as received. A
+// chunk that was received before is replaced by the new copy.
+func (s *UploadSessionService) PutChunk(session *models.UploadSession, i int, r io.Reader, checksum ChunkChecksum) (*models.UploadSession, error) {
+	if i < 0 || i >= session.ChunkCount {
+		return nil, ErrChunkOutOfRange.WithDetail("chunk_count", session.ChunkCount)
+	}
+	hasher := checksum.newHash()
+	if hasher == nil {
+		return nil, ErrChunkChecksumRequired
+	}
+
+	dir := s.dir(session.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, strconv.Itoa(i)+".part-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Read one byte past the expected length to tell an overlong chunk apart
+	expected := session.ChunkLength(i)
+	n, copyErr := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(r, expected+1))
+	if closeErr := tmp.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		return nil, fmt.Errorf("failed to store chunk: %w", copyErr)
+	}
+	if n != expected {
+		return nil, ErrChunkSizeMismatch.WithDetail("expected_size", expected)
+	}
+	if !strings.EqualFold(hex.EncodeToString(hasher.Sum(nil)), checksum.Value) {
+		return nil, ErrChunkChecksumMismatch
+	}
+
+	if err := os.Rename(tmp.Name(), s.chunkPath(session.ID, i)); err != nil {
+		return nil, fmt.Errorf("failed to store chunk: %w", err)
+	}
+
+	// Chunks of one session may arrive concurrently, so the row is written
+	// before the bitmap is read: that takes the row lock on PostgreSQL and
+	// the database write lock on SQLite, and concurrent chunks queue behind it
+	var updated models.UploadSession
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.UploadSession{}).Where("id = ?", session.ID).Update("updated_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("error locking upload session: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadSessionNotFound
+		}
+		if err := tx.First(&updated, "id = ?", session.ID).Error; err != nil {
+			return fmt.Errorf("error fetching upload session: %w", err)
+		}
+		if !updated.MarkChunk(i) {
+			return nil
+		}
+		if err := tx.Model(&updated).Updates(map[string]interface{}{
+			"received_chunks": updated.ReceivedChunks,
+			"received_count":  updated.ReceivedCount,
+		}).Error; err != nil {
+			return fmt.Errorf("error recording chunk: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Open returns the content of a complete session, reading its chunks in order
+func (s *UploadSessionService) Open(session *models.UploadSession) (io.ReadCloser, error) {
+	if !session.Complete() {
+		return nil, ErrUploadIncomplete.WithDetail("missing_chunks", session.MissingChunks())
+	}
+	paths := make([]string, session.ChunkCount)
+	for i := range paths {
+		paths[i] = s.chunkPath(session.ID, i)
+	}
+	return &chunkReader{paths: paths}, nil
+}
+
+// Delete discards a session and its chunks
+func (s *UploadSessionService) Delete(session *models.UploadSession) error {
+	if err := s.db.Delete(&models.UploadSession{}, "id = ?", session.ID).Error; err != nil {
+		return fmt.Errorf("error deleting upload session: %w", err)
+	}
+	if err := os.RemoveAll(s.dir(session.ID)); err != nil {
+		return fmt.Errorf("failed to remove chunks: %w", err)
+	}
+	return nil
+}
+
+// Start discards expired sessions in the background
+func (s *UploadSessionService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if removed, err := s.DeleteExpired(); err != nil {
+				log.Printf("Upload session cleanup failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("Upload session cleanup: discarded %d expired upload(s)", removed)
+			}
+		}
+	}()
+}
+
+// DeleteExpired discards sessions past their expiry and returns how many
+func (s *UploadSessionService) DeleteExpired() (int, error) {
+	var sessions []models.UploadSession
+	if err := s.db.Select("id").Where("expires_at <= ?", time.Now()).Find(&sessions).Error; err != nil {
+		return 0, fmt.Errorf("error fetching expired upload sessions: %w", err)
+	}
+	for i := range sessions {
+		if err := s.Delete(&sessions[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// chunkReader reads chunk files one after another, opening each only when
+// it is reached so large uploads do not hold a file open per chunk
+type chunkReader struct {
+	paths []string
+	file  *os.File
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.file == nil {
+			if len(r.paths) == 0 {
+				return 0, io.EOF
+			}
+			file, err := os.Open(r.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			r.file, r.paths = file, r.paths[1:]
+		}
+
+		n, err := r.file.Read(p)
+		if err == io.EOF {
+			r.file.Close()
+			r.file = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+	"file-vault-system/backend/pkg/utils"
+)
+
+const (
+	// maxImportURLLength is the longest URL that can be imported
+	maxImportURLLength = 2048
+	// maxImportRedirects is how many redirects an import follows
+	maxImportRedirects = 5
+	// importProgressInterval is the least time between progress updates of an import
+	importProgressInterval = time.Second
+	// urlImportRetention is how long finished imports are kept for polling
+	urlImportRetention = 7 * 24 * time.Hour
+	// defaultImportFilename names imports whose URL and response name no file
+	defaultImportFilename = "download"
+)
+
+var (
+	// ErrURLImportNotFound is returned when a URL import does not exist for the user
+	ErrURLImportNotFound = apperrors.ErrNotFound.WithCode("URL_IMPORT_NOT_FOUND", "URL import not found")
+	// ErrInvalidImportURL is returned for URLs that are not absolute http or https URLs
+	ErrInvalidImportURL = apperrors.ErrInvalidInput.WithCode("INVALID_IMPORT_URL", "invalid import URL")
+	// ErrURLImportLimit is returned when the user already has the most imports running
+	ErrURLImportLimit = apperrors.ErrConflict.WithCode("URL_IMPORT_LIMIT", "too many URL imports in progress")
+)
+
+// errBlockedAddress is returned when an import would connect to an address
+// inside the server's network
+var errBlockedAddress = errors.New("address not allowed")
+
+// URLImportError is why downloading a URL import failed, as recorded on it
+type URLImportError struct {
+	Code    string
+	Message string
+}
+
+func (e *URLImportError) Error() string {
+	return e.Message
+}
+
+// URLImportParams is what a URL import downloads and where it is stored
+type URLImportParams struct {
+	URL      string
+	Filename string // optional; taken from the response or the URL when empty
+	FolderID *uuid.UUID
+	IsPublic bool
+}
+
+// FetchedImport is the downloaded content of a URL import
+type FetchedImport struct {
+	Staged   *utils.StagedFile
+	Filename string
+	MimeType string // as declared by the server, empty when it declared none
+}
+
+// URLImportService downloads files from URLs on users' behalf and keeps the
+// progress of each import for clients to poll. Unless configured otherwise,
+// imports can only reach public unicast addresses, wherever the URL or its
+// redirects lead.
+type URLImportService struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	client *http.Client
+}
+
+// NewURLImportService creates a new URL import service
+func NewURLImportService(db *gorm.DB, cfg *config.Config) *URLImportService {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !cfg.URLImportAllowPrivate {
+		dialer.Control = blockPrivateAddresses
+	}
+
+	return &URLImportService{
+		db:  db,
+		cfg: cfg,
+		client: &http.Client{
+			// Imports connect directly rather than through a proxy, so the
+			// dialer checks the address of the server the content comes from
+			Transport: &http.Transport{
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: 30 * time.Second,
+				IdleConnTimeout:       90 * time.Second,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxImportRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxImportRedirects)
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return fmt.Errorf("redirected to an unsupported %s URL", req.URL.Scheme)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// nonPublicPrefixes are special-purpose ranges that are global unicast to
+// net/netip but not reachable on the public internet, or that embed IPv4
+// addresses a gateway would translate to
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved
+	netip.MustParsePrefix("::/96"),           // IPv4-compatible
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local NAT64
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4
+}
+
+// blockPrivateAddresses refuses connections to any address but a public
+// unicast one, so imports cannot reach services inside the server's network
+func blockPrivateAddresses(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !publicAddress(addrPort.Addr()) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// publicAddress reports whether addr is a unicast address on the public
+// internet. IPv4-mapped IPv6 addresses are judged by their IPv4 address
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// validImportURL checks that rawURL is an absolute http or https URL
+func validImportURL(rawURL string) error {
+	if len(rawURL) > maxImportURLLength {
+		return ErrInvalidImportURL.Explain(fmt.Sprintf("URLs may be at most %d characters long", maxImportURLLength))
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidImportURL.Explain("Only absolute http and https URLs can be imported")
+	}
+	return nil
+}
+
+// Create records a new import for the user. The caller starts it with Fetch
+func (s *URLImportService) Create(userID uuid.UUID, params URLImportParams) (*models.URLImport, error) {
+	if err := validImportURL(params.URL); err != nil {
+		return nil, err
+	}
+
+	if s.cfg.URLImportMaxActive > 0 {
+		var active int64
+		if err := s.db.Model(&models.URLImport{}).
+			Where("user_id = ? AND status IN ?", userID, []models.URLImportStatus{models.URLImportPending, models.URLImportDownloading, models.URLImportProcessing}).
+			Count(&active).Error; err != nil {
+			return nil, fmt.Errorf("error counting URL imports: %w", err)
+		}
+		if active >= int64(s.cfg.URLImportMaxActive) {
+			return nil, ErrURLImportLimit.WithDetail("max_active", s.cfg.URLImportMaxActive)
+		}
+	}
+
+	job := &models.URLImport{
+		UserID:   userID,
+		URL:      params.URL,
+		Filename: params.Filename,
+		FolderID: params.FolderID,
+		IsPublic: params.IsPublic,
+		Status:   models.URLImportPending,
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("error creating URL import: %w", err)
+	}
+	return job, nil
+}
+
+// Get returns one of the user's imports
+func (s *URLImportService) Get(userID, id uuid.UUID) (*models.URLImport, error) {
+	var job models.URLImport
+	if err := s.db.Where("user_id = ?", userID).First(&job, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrURLImportNotFound
+		}
+		return nil, fmt.Errorf("error fetching URL import: %w", err)
+	}
+	return &job, nil
+}
+
+// Fetch downloads an import's URL into the upload staging directory,
+// recording its progress on the import. Content larger than maxSize is not
+// downloaded. Download failures are returned as a *URLImportError; the
+// caller removes the staged file.
+func (s *URLImportService) Fetch(job *models.URLImport, maxSize int64) (*FetchedImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.URLImportTimeout)*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	job.Status = models.URLImportDownloading
+	job.StartedAt = &now
+	if err := s.db.Model(job).Updates(map[string]interface{}{
+		"status":     job.Status,
+		"started_at": now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("error updating URL import: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.URL, nil)
+	if err != nil {
+		return nil, &URLImportError{Code: "INVALID_IMPORT_URL", Message: err.Error()}
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, importFetchError(job, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &URLImportError{Code: "REMOTE_ERROR", Message: fmt.Sprintf("The server answered %s", resp.Status)}
+	}
+	if resp.ContentLength > maxSize {
+		return nil, importTooLarge(maxSize)
+	}
+	if resp.ContentLength >= 0 {
+		total := resp.ContentLength
+		job.TotalBytes = &total
+		if err := s.db.Model(job).Update("total_bytes", total).Error; err != nil {
+			return nil, fmt.Errorf("error updating URL import: %w", err)
+		}
+	}
+
+	progress := &importProgress{db: s.db, job: job, reader: io.LimitReader(resp.Body, maxSize+1), saved: time.Now()}
+	staged, err := utils.StageReader(s.cfg.UploadTempDir, progress, 0)
+	progress.save()
+	if err != nil {
+		if progress.err != nil {
+			return nil, importFetchError(job, progress.err)
+		}
+		return nil, err
+	}
+	if staged.Size > maxSize {
+		os.Remove(staged.Path)
+		return nil, importTooLarge(maxSize)
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return &FetchedImport{
+		Staged:   staged,
+		Filename: importFilename(job, resp),
+		MimeType: mimeType,
+	}, nil
+}
+
+// importFetchError describes why a download failed. Other failures are
+// logged, as their details describe the server's network
+func importFetchError(job *models.URLImport, err error) *URLImportError {
+	switch {
+	case errors.Is(err, errBlockedAddress):
+		return &URLImportError{Code: "BLOCKED_ADDRESS", Message: "The URL leads to an address files cannot be imported from"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &URLImportError{Code: "IMPORT_TIMEOUT", Message: "The download did not finish in time"}
+	default:
+		log.Printf("URL import %s could not be downloaded: %v", job.ID, err)
+		return &URLImportError{Code: "FETCH_FAILED", Message: "The file could not be downloaded"}
+	}
+}
+
+// importTooLarge reports a download larger than the user may upload
+func importTooLarge(maxSize int64) *URLImportError {
+	return &URLImportError{Code: "FILE_TOO_LARGE", Message: fmt.Sprintf("File exceeds the maximum size of %d bytes", maxSize)}
+}
+
+// importFilename names a downloaded file: as the user asked, as the server
+// suggested, or after the last segment of the URL it was finally fetched from
+func importFilename(job *models.URLImport, resp *http.Response) string {
+	name := job.Filename
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+			name = params["filename"]
+		}
+	}
+	if name == "" {
+		name = path.Base(resp.Request.URL.Path)
+	}
+
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = defaultImportFilename
+	}
+	if len(name) > 255 {
+		// Keep the end, which has the extension the file's type is checked against
+		name = strings.ToValidUTF8(name[len(name)-255:], "")
+	}
+	return name
+}
+
+// MarkProcessing records that an import was downloaded and is being stored
+func (s *URLImportService) MarkProcessing(job *models.URLImport) {
+	job.Status = models.URLImportProcessing
+	if err := s.db.Model(job).Update("status", job.Status).Error; err != nil {
+		log.Printf("Failed to update URL import %s: %v", job.ID, err)
+	}
+}
+
+// Complete records the file an import was stored as
+func (s *URLImportService) Complete(job *models.URLImport, fileID uuid.UUID) {
+	now := time.Now()
+	job.Status = models.URLImportCompleted
+	job.FileID = &fileID
+	job.CompletedAt = &now
+	if err := s.db.Model(job).Updates(map[string]interface{}{
+		"status":       job.Status,
+		"file_id":      fileID,
+		"completed_at": now,
+	}).Error; err != nil {
+		log.Printf("Failed to update URL import %s: %v", job.ID, err)
+	}
+}
+
+// Fail records why an import failed
+func (s *URLImportService) Fail(job *models.URLImport, code, message string) {
+	now := time.Now()
+	job.Status = models.URLImportFailed
+	job.ErrorCode = code
+	job.Error = message
+	job.CompletedAt = &now
+	if err := s.db.Model(job).Updates(map[string]interface{}{
+		"status":       job.Status,
+		"error_code":   code,
+		"error":        message,
+		"completed_at": now,
+	}).Error; err != nil {
+		log.Printf("Failed to update URL import %s: %v", job.ID, err)
+	}
+}
+
+// Start fails the imports a previous run left unfinished, then removes
+// finished imports past their retention in the background
+func (s *URLImportService) Start(interval time.Duration) {
+	if interrupted, err := s.FailInterrupted(); err != nil {
+		log.Printf("URL import recovery failed: %v", err)
+	} else if interrupted > 0 {
+		log.Printf("URL import recovery: %d import(s) interrupted by a restart", interrupted)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			if removed, err := s.DeleteFinished(); err != nil {
+				log.Printf("URL import cleanup failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("URL import cleanup: removed %d finished import(s)", removed)
+			}
+		}
+	}()
+}
+
+// FailInterrupted marks the imports that were running when the server
+// stopped as failed, since nothing will finish them, and returns how many
+func (s *URLImportService) FailInterrupted() (int64, error) {
+	result := s.db.Model(&models.URLImport{}).
+		Where("status IN ?", []models.URLImportStatus{models.URLImportPending, models.URLImportDownloading, models.URLImportProcessing}).
+		Updates(map[string]interface{}{
+			"status":       models.URLImportFailed,
+			"error_code":   "IMPORT_INTERRUPTED",
+			"error":        "The server restarted before the import finished",
+			"completed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error failing interrupted URL imports: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteFinished removes imports that finished more than urlImportRetention
+// ago and returns how many
+func (s *URLImportService) DeleteFinished() (int64, error) {
+	result := s.db.Where("completed_at < ?", time.Now().Add(-urlImportRetention)).Delete(&models.URLImport{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error deleting finished URL imports: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// importProgress counts the bytes of an import as they are downloaded and
+// records them on the import at most every importProgressInterval
+type importProgress struct {
+	db     *gorm.DB
+	job    *models.URLImport
+	reader io.Reader
+	saved  time.Time
+	err    error // why reading the response failed, if it did
+}
+
+func (p *importProgress) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.job.BytesReceived += int64(n)
+	if err != nil && err != io.EOF {
+		p.err = err
+	}
+	if time.Since(p.saved) >= importProgressInterval {
+		p.save()
+	}
+	return n, err
+}
+
+// save records the bytes received so far
+func (p *importProgress) save() {
+	p.saved = time.Now()
+	if err := p.db.Model(p.job).Update("bytes_received", p.job.BytesReceived).Error; err != nil {
+		log.Printf("Failed to update URL import %s: %v", p.job.ID, err)
+	}
+}
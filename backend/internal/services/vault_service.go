@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"file-vault-system/backend/internal/apperrors"
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/internal/models"
+)
+
+var (
+	// ErrVaultFolderNotFound is returned when a folder does not exist or is not the user's
+	ErrVaultFolderNotFound = apperrors.ErrNotFound.With("folder not found")
+	// ErrVaultsDisabled is returned when creating an encrypted folder on a
+	// server that does not allow them
+	ErrVaultsDisabled = apperrors.ErrForbidden.WithCode("ENCRYPTED_FOLDERS_DISABLED", "encrypted folders are not enabled on this server")
+	// ErrVaultKeyRequired is returned when an encrypted folder is created
+	// without the owner's wrapped key
+	ErrVaultKeyRequired = apperrors.ErrInvalidInput.WithCode("VAULT_KEY_REQUIRED", "wrapped_key is required to create an encrypted folder")
+	// ErrVaultEscrowRequired is returned when an encrypted folder is created
+	// without the escrow copy of its key on a server that keeps one
+	ErrVaultEscrowRequired = apperrors.ErrInvalidInput.WithCode("VAULT_ESCROW_REQUIRED", "escrow_wrapped_key is required: this server keeps an escrow copy of every encrypted folder's key")
+	// ErrNotVault is returned for key requests on a folder that is not encrypted
+	ErrNotVault = apperrors.ErrConflict.WithCode("NOT_ENCRYPTED_FOLDER", "folder is not encrypted")
+	// ErrVaultKeyNotFound is returned when no key of an encrypted folder was
+	// given to the user, or no escrow copy was kept
+	ErrVaultKeyNotFound = apperrors.ErrNotFound.WithCode("VAULT_KEY_NOT_FOUND", "no key of this encrypted folder was given to you")
+	// ErrVaultRecipientNoAccess is returned when giving an encrypted folder's
+	// key to a user it is not shared with
+	ErrVaultRecipientNoAccess = apperrors.ErrConflict.WithCode("VAULT_NOT_SHARED", "share the folder with the user before giving them its key")
+	// ErrVaultBoundary is returned when a file or folder would move into or
+	// out of an encrypted folder, which would mix ciphertext and plaintext
+	ErrVaultBoundary = apperrors.ErrConflict.WithCode("VAULT_BOUNDARY", "items cannot be moved into, out of or between encrypted folders")
+	// ErrPublicKeyNotFound is returned when a user has not registered a public key
+	ErrPublicKeyNotFound = apperrors.ErrNotFound.WithCode("PUBLIC_KEY_NOT_FOUND", "user has no public key for encrypted folders")
+)
+
+// VaultService keeps the keys of end-to-end encrypted folders. Clients
+// encrypt files before upload with a key per vault, the top encrypted folder
+// and everything in it, and give the vault to other users by wrapping its
+// key with their public keys. The server only stores what it cannot read:
+// ciphertext, wrapped keys and public keys.
+type VaultService struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	access *AccessService
+}
+
+// NewVaultService creates a new vault service
+func NewVaultService(db *gorm.DB, cfg *config.Config) *VaultService {
+	return &VaultService{db: db, cfg: cfg, access: NewAccessService(db)}
+}
+
+// Enabled reports whether users may create encrypted folders
+func (s *VaultService) Enabled() bool {
+	return s.cfg.EnableEncryptedFolders
+}
+
+// EscrowPublicKey returns the public key every vault key is also wrapped
+// for, or "" when no escrow copy is kept
+func (s *VaultService) EscrowPublicKey() string {
+	return s.cfg.VaultEscrowPublicKey
+}
+
+// CheckNewVault checks the keys sent to create an encrypted folder
+func (s *VaultService) CheckNewVault(wrappedKey, escrowWrappedKey string) error {
+	if !s.Enabled() {
+		return ErrVaultsDisabled
+	}
+	if wrappedKey == "" {
+		return ErrVaultKeyRequired
+	}
+	if s.EscrowPublicKey() != "" && escrowWrappedKey == "" {
+		return ErrVaultEscrowRequired
+	}
+	return nil
+}
+
+// CreateVaultKeys stores the owner's wrapped key of a new vault and its
+// escrow copy, when the server keeps one
+func (s *VaultService) CreateVaultKeys(tx *gorm.DB, vault *models.Folder, wrappedKey, escrowWrappedKey string) error {
+	grants := []models.VaultKeyGrant{{
+		VaultID:     vault.ID,
+		RecipientID: &vault.OwnerID,
+		WrappedKey:  wrappedKey,
+		GrantedBy:   vault.OwnerID,
+	}}
+	if s.EscrowPublicKey() != "" {
+		grants = append(grants, models.VaultKeyGrant{
+			VaultID:    vault.ID,
+			WrappedKey: escrowWrappedKey,
+			GrantedBy:  vault.OwnerID,
+		})
+	}
+	if err := tx.Create(&grants).Error; err != nil {
+		return fmt.Errorf("error storing vault keys: %w", err)
+	}
+	return nil
+}
+
+// SetPublicKey registers or replaces the user's public key
+func (s *VaultService) SetPublicKey(userID uuid.UUID, algorithm, publicKey string) (*models.UserPublicKey, error) {
+	key := models.UserPublicKey{UserID: userID, Algorithm: algorithm, PublicKey: publicKey}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"algorithm", "public_key", "updated_at"}),
+	}).Create(&key).Error; err != nil {
+		return nil, fmt.Errorf("error saving public key: %w", err)
+	}
+	return s.GetPublicKey(userID)
+}
+
+// GetPublicKey returns a user's public key, or ErrPublicKeyNotFound
+func (s *VaultService) GetPublicKey(userID uuid.UUID) (*models.UserPublicKey, error) {
+	var key models.UserPublicKey
+	if err := s.db.Where("user_id = ?", userID).Take(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPublicKeyNotFound
+		}
+		return nil, fmt.Errorf("error fetching public key: %w", err)
+	}
+	return &key, nil
+}
+
+// MyKey returns the user's wrapped key of the vault a folder they can open
+// belongs to
+func (s *VaultService) MyKey(ctx context.Context, userID, folderID uuid.UUID) (*models.VaultKeyGrant, error) {
+	access, err := s.access.CanViewFolder(ctx, userID, folderID)
+	if err != nil {
+		if errors.Is(err, ErrAccessFolderNotFound) || errors.Is(err, ErrAccessUserNotFound) {
+			return nil, ErrVaultFolderNotFound
+		}
+		return nil, err
+	}
+	if !access.Folder.IsEncrypted() {
+		return nil, ErrNotVault
+	}
+	return s.findGrant(s.db.WithContext(ctx).Where("recipient_id = ?", userID), *access.Folder.VaultID)
+}
+
+// ListKeys returns who has been given the key of the vault an owned folder
+// belongs to, including the escrow copy
+func (s *VaultService) ListKeys(ownerID, folderID uuid.UUID) ([]models.VaultKeyGrant, error) {
+	vaultID, err := s.ownedVault(ownerID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	var grants []models.VaultKeyGrant
+	if err := s.db.Where("vault_id = ?", vaultID).Order("created_at").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("error listing vault keys: %w", err)
+	}
+	return grants, nil
+}
+
+// GrantKey gives a user the key of the vault an owned folder belongs to,
+// wrapped with their public key. The vault, or a folder in it, must already
+// be shared with them; giving a key again replaces it.
+func (s *VaultService) GrantKey(ownerID, folderID, recipientID uuid.UUID, wrappedKey string) (*models.VaultKeyGrant, error) {
+	vaultID, err := s.ownedVault(ownerID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if recipientID != ownerID {
+		var shares int64
+		if err := s.db.Model(&models.FolderShare{}).
+			Where("shared_with = ? AND folder_id IN (?)", recipientID,
+				s.db.Model(&models.Folder{}).Select("id").Where("vault_id = ?", vaultID)).
+			Count(&shares).Error; err != nil {
+			return nil, fmt.Errorf("error checking folder shares: %w", err)
+		}
+		if shares == 0 {
+			return nil, ErrVaultRecipientNoAccess
+		}
+	}
+
+	grant := models.VaultKeyGrant{
+		VaultID:     vaultID,
+		RecipientID: &recipientID,
+		WrappedKey:  wrappedKey,
+		GrantedBy:   ownerID,
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("vault_id = ? AND recipient_id = ?", vaultID, recipientID).
+			Delete(&models.VaultKeyGrant{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&grant).Error
+	}); err != nil {
+		return nil, fmt.Errorf("error granting vault key: %w", err)
+	}
+	return &grant, nil
+}
+
+// RevokeKey removes a user's copy of the key of the vault an owned folder
+// belongs to. They may have kept the key itself, so files that must stay
+// unreadable to them belong in a new vault.
+func (s *VaultService) RevokeKey(ownerID, folderID, recipientID uuid.UUID) error {
+	vaultID, err := s.ownedVault(ownerID, folderID)
+	if err != nil {
+		return err
+	}
+	if recipientID == ownerID {
+		return apperrors.ErrInvalidInput.With("the owner's key cannot be revoked")
+	}
+	result := s.db.Where("vault_id = ? AND recipient_id = ?", vaultID, recipientID).Delete(&models.VaultKeyGrant{})
+	if result.Error != nil {
+		return fmt.Errorf("error revoking vault key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrVaultKeyNotFound
+	}
+	return nil
+}
+
+// RevokeUnshared removes the key of a vault from a user once no folder in it
+// is shared with them any more
+func (s *VaultService) RevokeUnshared(folderID, recipientID uuid.UUID) error {
+	var folder models.Folder
+	if err := s.db.Unscoped().Select("id", "vault_id").Take(&folder, "id = ?", folderID).Error; err != nil {
+		return fmt.Errorf("error fetching folder: %w", err)
+	}
+	if !folder.IsEncrypted() {
+		return nil
+	}
+	return s.db.Where("vault_id = ? AND recipient_id = ?", *folder.VaultID, recipientID).
+		Where("NOT EXISTS (?)", s.db.Model(&models.FolderShare{}).Select("1").
+			Where("shared_with = ? AND folder_id IN (?)", recipientID,
+				s.db.Model(&models.Folder{}).Select("id").Where("vault_id = ?", *folder.VaultID))).
+		Delete(&models.VaultKeyGrant{}).Error
+}
+
+// EscrowKey returns the escrow copy of the key of the vault a folder belongs
+// to, for an admin recovering it
+func (s *VaultService) EscrowKey(folderID uuid.UUID) (*models.Folder, *models.VaultKeyGrant, error) {
+	var folder models.Folder
+	if err := s.db.Take(&folder, "id = ?", folderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrVaultFolderNotFound
+		}
+		return nil, nil, fmt.Errorf("error fetching folder: %w", err)
+	}
+	if !folder.IsEncrypted() {
+		return nil, nil, ErrNotVault
+	}
+	grant, err := s.findGrant(s.db.Where("recipient_id IS NULL"), *folder.VaultID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &folder, grant, nil
+}
+
+// ownedVault returns the vault an owned folder belongs to
+func (s *VaultService) ownedVault(ownerID, folderID uuid.UUID) (uuid.UUID, error) {
+	var folder models.Folder
+	if err := s.db.Where("id = ? AND owner_id = ?", folderID, ownerID).Take(&folder).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrVaultFolderNotFound
+		}
+		return uuid.Nil, fmt.Errorf("error fetching folder: %w", err)
+	}
+	if !folder.IsEncrypted() {
+		return uuid.Nil, ErrNotVault
+	}
+	return *folder.VaultID, nil
+}
+
+// findGrant returns the grant of a vault the query selects
+func (s *VaultService) findGrant(query *gorm.DB, vaultID uuid.UUID) (*models.VaultKeyGrant, error) {
+	var grant models.VaultKeyGrant
+	if err := query.Where("vault_id = ?", vaultID).Take(&grant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVaultKeyNotFound
+		}
+		return nil, fmt.Errorf("error fetching vault key: %w", err)
+	}
+	return &grant, nil
+}
+
+// VaultOf returns the vault a folder belongs to, nil for folders outside
+// vaults and for the root
+func VaultOf(tx *gorm.DB, folderID *uuid.UUID) (*uuid.UUID, error) {
+	if folderID == nil {
+		return nil, nil
+	}
+	var folder models.Folder
+	if err := tx.Select("id", "vault_id").Take(&folder, "id = ?", *folderID).Error; err != nil {
+		return nil, fmt.Errorf("error fetching folder: %w", err)
+	}
+	return folder.VaultID, nil
+}
+
+// SameVault reports whether two folders' vault IDs are the same vault, or
+// both outside vaults
+func SameVault(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
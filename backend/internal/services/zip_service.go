@@ -0,0 +1,283 @@
+package services
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/pkg/utils"
+)
+
+// ZipEntry is one item of a ZIP archive. Entries without a stream are
+// directories, and their names end in a slash
+type ZipEntry struct {
+	Name     string
+	Stream   *FileStream
+	Modified time.Time
+}
+
+// compressedEntry is an entry ready to be copied into the archive. Deflated
+// data is spooled to a temp file; stored entries are copied from the blob
+type compressedEntry struct {
+	header *zip.FileHeader
+	stream *FileStream // read when the original content is stored
+	path   string      // the deflated content, in a temp file
+	temp   bool
+	err    error
+}
+
+// ZipService streams ZIP archives of stored files. Entries are compressed by
+// a pool of workers running ahead of the writer, content that is already
+// compressed is stored as is, and sizes are tracked in 64 bits so archives
+// switch to ZIP64 past 4GB or 65,535 entries instead of failing.
+type ZipService struct {
+	cfg *config.Config
+}
+
+// NewZipService creates a new ZIP service
+func NewZipService(cfg *config.Config) *ZipService {
+	return &ZipService{cfg: cfg}
+}
+
+// workers returns the number of entries compressed at the same time
+func (s *ZipService) workers() int {
+	if s.cfg.ZipCompressionWorkers > 0 {
+		return s.cfg.ZipCompressionWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// Write streams entries to w as a ZIP archive in the order given. Writing
+// stops at the first error, leaving w with a truncated archive.
+func (s *ZipService) Write(ctx context.Context, w io.Writer, entries []ZipEntry) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := s.workers()
+	results := make([]chan compressedEntry, len(entries))
+	for i := range results {
+		results[i] = make(chan compressedEntry, 1)
+	}
+
+	// The window bounds how far compression runs ahead of the writer, and
+	// with it the temp space in use
+	window := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range entries {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] <- s.compress(ctx, entries[i])
+			}
+		}()
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+		// Drop entries compressed ahead of a failed write
+		for _, result := range results {
+			select {
+			case r := <-result:
+				r.cleanup()
+			default:
+			}
+		}
+	}()
+
+	zw := zip.NewWriter(w)
+	for i := range entries {
+		var r compressedEntry
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		err := writeEntry(ctx, zw, r)
+		r.cleanup()
+		<-window
+		if err != nil {
+			return fmt.Errorf("error writing %s to archive: %w", entries[i].Name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// compress prepares an entry: it deflates the content into a temp file, or
+// checksums it for storing when the content is already compressed or does
+// not shrink
+func (s *ZipService) compress(ctx context.Context, entry ZipEntry) compressedEntry {
+	header := &zip.FileHeader{Name: entry.Name, Method: zip.Store}
+	if !entry.Modified.IsZero() {
+		// CreateRaw takes the MS-DOS time fields as given, so fill them too
+		header.SetModTime(entry.Modified)
+	}
+	if entry.Stream == nil {
+		return compressedEntry{header: header}
+	}
+	r := compressedEntry{header: header, stream: entry.Stream}
+
+	src, err := entry.Stream.Reader(ctx)
+	if err != nil {
+		r.err = fmt.Errorf("error opening blob: %w", err)
+		return r
+	}
+	defer src.Close()
+
+	crc := crc32.NewIEEE()
+	if isCompressedMimeType(entry.Stream.File.MimeType) {
+		n, err := io.Copy(crc, &contextReader{ctx: ctx, r: src})
+		if err != nil {
+			r.err = fmt.Errorf("error reading blob: %w", err)
+			return r
+		}
+		header.CRC32 = crc.Sum32()
+		header.CompressedSize64 = uint64(n)
+		header.UncompressedSize64 = uint64(n)
+		return r
+	}
+
+	if err := utils.EnsureDir(s.cfg.UploadTempDir); err != nil {
+		r.err = fmt.Errorf("error creating temp directory: %w", err)
+		return r
+	}
+	// The staging prefix lets the startup sweep clear spools left by a crash
+	tmp, err := os.CreateTemp(s.cfg.UploadTempDir, "upload-zip-*")
+	if err != nil {
+		r.err = fmt.Errorf("error creating temp file: %w", err)
+		return r
+	}
+	defer tmp.Close()
+	r.path, r.temp = tmp.Name(), true
+
+	fw, _ := flate.NewWriter(tmp, flate.DefaultCompression)
+	n, err := io.Copy(io.MultiWriter(fw, crc), &contextReader{ctx: ctx, r: src})
+	if err == nil {
+		err = fw.Close()
+	}
+	if err != nil {
+		r.err = fmt.Errorf("error compressing blob: %w", err)
+		return r
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		r.err = fmt.Errorf("error compressing blob: %w", err)
+		return r
+	}
+
+	header.CRC32 = crc.Sum32()
+	header.UncompressedSize64 = uint64(n)
+	if info.Size() >= n {
+		// Compression did not help, so store the original bytes
+		r.cleanup()
+		r.path = ""
+		header.CompressedSize64 = uint64(n)
+		return r
+	}
+	header.Method = zip.Deflate
+	header.CompressedSize64 = uint64(info.Size())
+	return r
+}
+
+// writeEntry copies a prepared entry into the archive
+func writeEntry(ctx context.Context, zw *zip.Writer, r compressedEntry) error {
+	if r.err != nil {
+		return r.err
+	}
+	if strings.HasSuffix(r.header.Name, "/") {
+		_, err := zw.CreateHeader(r.header)
+		return err
+	}
+
+	w, err := zw.CreateRaw(r.header)
+	if err != nil {
+		return err
+	}
+	var src io.ReadCloser
+	if r.temp {
+		src, err = os.Open(r.path)
+	} else {
+		src, err = r.stream.Reader(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	n, err := io.Copy(w, src)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != r.header.CompressedSize64 {
+		return fmt.Errorf("content changed while archiving: %d bytes, %d expected", n, r.header.CompressedSize64)
+	}
+	return nil
+}
+
+// cleanup removes the entry's temp file, if it has one
+func (r *compressedEntry) cleanup() {
+	if r.temp {
+		os.Remove(r.path)
+		r.temp = false
+	}
+}
+
+// compressedMimePrefixes are content types that gain nothing from deflate
+var compressedMimePrefixes = []string{
+	"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif", "image/heic",
+	"video/", "audio/mpeg", "audio/aac", "audio/ogg", "audio/mp4", "audio/webm",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-xz", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/vnd.rar", "application/zstd",
+	"application/vnd.openxmlformats-officedocument.", "application/vnd.oasis.opendocument.",
+	"application/epub+zip", "application/java-archive",
+}
+
+// isCompressedMimeType reports whether content of the type is already compressed
+func isCompressedMimeType(mimeType string) bool {
+	for _, prefix := range compressedMimePrefixes {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextReader stops a copy once its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
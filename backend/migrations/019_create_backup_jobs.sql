@@ -0,0 +1,19 @@
+-- Migration: Track admin-triggered backup jobs
+
+CREATE TABLE IF NOT EXISTS backup_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    mode VARCHAR(20) NOT NULL DEFAULT 'full',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    started_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    backup_dir TEXT,
+    blob_count INTEGER DEFAULT 0,
+    blobs_copied INTEGER DEFAULT 0,
+    bytes_copied BIGINT DEFAULT 0,
+    missing_blobs INTEGER DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_backup_jobs_created_at ON backup_jobs(created_at DESC);
@@ -0,0 +1,9 @@
+-- Migration: Track replication of blobs to the secondary storage backend
+
+ALTER TABLE file_hashes
+    ADD COLUMN IF NOT EXISTS replication_status VARCHAR(20) DEFAULT 'pending',
+    ADD COLUMN IF NOT EXISTS replication_attempts INTEGER DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS replication_error TEXT,
+    ADD COLUMN IF NOT EXISTS replicated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_file_hashes_replication_status ON file_hashes(replication_status, created_at);
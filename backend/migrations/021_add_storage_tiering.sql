@@ -0,0 +1,15 @@
+-- Migration: Cold storage tiering for blobs that have not been accessed recently
+
+ALTER TABLE file_hashes
+    ADD COLUMN IF NOT EXISTS storage_tier VARCHAR(20) DEFAULT 'hot',
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS restored_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS restore_requested_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS restore_requested_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- Mirrored onto files so listings can show archive status without a join
+ALTER TABLE files
+    ADD COLUMN IF NOT EXISTS storage_tier VARCHAR(20) DEFAULT 'hot';
+
+CREATE INDEX IF NOT EXISTS idx_file_hashes_storage_tier ON file_hashes(storage_tier);
+CREATE INDEX IF NOT EXISTS idx_download_stats_file_downloaded ON download_stats(file_id, downloaded_at);
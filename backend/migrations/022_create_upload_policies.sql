@@ -0,0 +1,17 @@
+-- Migration: Admin-defined upload policies per role and file type
+
+CREATE TABLE IF NOT EXISTS upload_policies (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    role VARCHAR(20) NOT NULL DEFAULT '', -- empty applies to every role
+    match_type VARCHAR(20) NOT NULL,      -- 'mime' or 'extension'
+    pattern VARCHAR(100) NOT NULL,        -- e.g. 'video/*', 'image/png', '.exe'
+    blocked BOOLEAN DEFAULT false,
+    max_size BIGINT,
+    allow_public BOOLEAN DEFAULT true,
+    description TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_policies_role ON upload_policies(role);
@@ -0,0 +1,24 @@
+-- Migration: Quarantine for uploads flagged by content inspection
+-- Quarantined files stay in storage but cannot be read or shared until an
+-- admin releases them from the review queue
+
+ALTER TABLE files
+    ADD COLUMN IF NOT EXISTS is_quarantined BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS file_quarantines (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    findings JSONB,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    review_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_quarantines_status_created ON file_quarantines(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_file_quarantines_file ON file_quarantines(file_id);
+CREATE INDEX IF NOT EXISTS idx_files_quarantined ON files(is_quarantined) WHERE is_quarantined = TRUE;
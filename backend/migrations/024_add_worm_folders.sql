@@ -0,0 +1,15 @@
+-- Migration: Write-once (WORM) folders for compliance archiving
+-- Files placed in a WORM folder cannot be deleted, moved or renamed until
+-- their retention period has elapsed
+
+ALTER TABLE folders
+    ADD COLUMN IF NOT EXISTS is_worm BOOLEAN DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS retention_days INTEGER DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS worm_enabled_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE files
+    ADD COLUMN IF NOT EXISTS worm_locked_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS retain_until TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_folders_worm ON folders(owner_id, path) WHERE is_worm = TRUE;
+CREATE INDEX IF NOT EXISTS idx_files_retain_until ON files(retain_until) WHERE retain_until IS NOT NULL;
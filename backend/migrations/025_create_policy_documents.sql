@@ -0,0 +1,31 @@
+-- Migration: Versioned terms-of-service and privacy policies
+-- Users must accept the current version of each policy before uploading;
+-- acceptances are kept per user and version for compliance
+
+CREATE TABLE IF NOT EXISTS policy_documents (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type VARCHAR(20) NOT NULL,    -- 'terms' or 'privacy'
+    version VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (type, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_documents_type_effective ON policy_documents(type, effective_at DESC);
+
+CREATE TABLE IF NOT EXISTS policy_acceptances (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    document_id UUID NOT NULL REFERENCES policy_documents(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    accepted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, document_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_acceptances_document ON policy_acceptances(document_id);
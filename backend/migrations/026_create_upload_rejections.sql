@@ -0,0 +1,20 @@
+-- Migration: Record rejected uploads for admin diagnostics
+
+CREATE TABLE IF NOT EXISTS upload_rejections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason VARCHAR(30) NOT NULL, -- e.g. 'mime_mismatch', 'policy_violation', 'quota_exceeded'
+    code VARCHAR(50),
+    message TEXT,
+    filename VARCHAR(255),
+    declared_mime_type VARCHAR(100),
+    detected_mime_type VARCHAR(100),
+    size BIGINT DEFAULT 0,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_created ON upload_rejections(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_reason_created ON upload_rejections(reason, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_user_created ON upload_rejections(user_id, created_at DESC);
@@ -0,0 +1,7 @@
+-- Migration: Track temporary storage quota overage ("grace") per user
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quota_grace_started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quota_grace_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quota_grace_revoked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_quota_grace_expires ON users(quota_grace_expires_at) WHERE quota_grace_expires_at IS NOT NULL;
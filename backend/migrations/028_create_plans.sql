@@ -0,0 +1,21 @@
+-- Migration: Quota plans assigned to users
+
+CREATE TABLE IF NOT EXISTS plans (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    storage_quota BIGINT NOT NULL,
+    max_file_size BIGINT DEFAULT 0,    -- 0 uses the server limit
+    rate_limit INTEGER DEFAULT 0,      -- requests per second; 0 uses the server limit
+    rate_limit_burst INTEGER DEFAULT 0,
+    allow_public_sharing BOOLEAN DEFAULT true,
+    is_default BOOLEAN DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one plan is assigned to new users
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plans_single_default ON plans(is_default) WHERE is_default = true;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan_id UUID REFERENCES plans(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_users_plan_id ON users(plan_id);
@@ -0,0 +1,19 @@
+-- Migration: Searchable text extracted from blobs, including OCR of images and scanned PDFs
+
+CREATE TABLE IF NOT EXISTS content_index (
+    file_hash_id UUID PRIMARY KEY REFERENCES file_hashes(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'indexed', 'failed', 'unsupported'
+    method VARCHAR(10),                            -- 'text' or 'ocr'
+    engine VARCHAR(50),
+    mime_type VARCHAR(100),
+    extracted_text TEXT,
+    char_count INTEGER DEFAULT 0,
+    attempts INTEGER DEFAULT 0,
+    error TEXT,
+    indexed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_index_pending ON content_index(created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_content_index_text_gin ON content_index USING GIN(to_tsvector('english', COALESCE(extracted_text, '')));
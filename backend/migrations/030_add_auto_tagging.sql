@@ -0,0 +1,10 @@
+-- Migration: Automatic file classification
+-- Tags applied by the classification worker are kept apart from user tags
+
+ALTER TABLE files ADD COLUMN IF NOT EXISTS auto_tags TEXT[];
+ALTER TABLE files ADD COLUMN IF NOT EXISTS classified_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auto_tagging_enabled BOOLEAN NOT NULL DEFAULT true;
+
+CREATE INDEX IF NOT EXISTS idx_files_auto_tags_gin ON files USING GIN(auto_tags);
+CREATE INDEX IF NOT EXISTS idx_files_unclassified ON files(created_at) WHERE classified_at IS NULL AND is_deleted = false;
@@ -0,0 +1,7 @@
+-- Migration: Malware scanning of uploads and stored blobs
+-- Blobs flagged after they were stored quarantine every file that uses them
+
+ALTER TABLE file_hashes ADD COLUMN IF NOT EXISTS malware_scanned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE file_hashes ADD COLUMN IF NOT EXISTS malware_signature VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_file_hashes_malware_scanned ON file_hashes(malware_scanned_at NULLS FIRST);
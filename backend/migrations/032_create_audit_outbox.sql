@@ -0,0 +1,21 @@
+-- Migration: Transactional audit outbox
+-- Entries are written in the same transaction as the audited action and moved
+-- into audit_logs by a background worker, keeping the same id and created_at
+
+CREATE TABLE IF NOT EXISTS audit_outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id UUID,
+    resource_name VARCHAR(255),
+    details JSONB,
+    ip_address INET,
+    user_agent TEXT,
+    status VARCHAR(20) DEFAULT 'success',
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_outbox_created_at ON audit_outbox(created_at);
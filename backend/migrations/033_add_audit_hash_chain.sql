@@ -0,0 +1,41 @@
+-- Migration: Tamper-evident audit log
+-- Entries are sealed into a hash chain in the order they were written: each
+-- row stores its sequence number, the hash of the previous row and its own hash
+
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS sequence BIGINT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_sequence ON audit_logs(sequence);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_unsealed ON audit_logs(created_at, id) WHERE sequence IS NULL;
+
+-- Entries are never edited, so updated_at no longer needs maintaining
+DROP TRIGGER IF EXISTS update_audit_logs_updated_at ON audit_logs;
+
+-- Sealed entries cannot be changed. Unsealed entries may only receive their
+-- chain columns. Deletes are allowed only for retention pruning, which sets
+-- audit.allow_prune for its transaction and records what it removed.
+CREATE OR REPLACE FUNCTION protect_audit_logs()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF current_setting('audit.allow_prune', true) = 'on' THEN
+            RETURN OLD;
+        END IF;
+        RAISE EXCEPTION 'audit log entries cannot be deleted';
+    END IF;
+
+    IF OLD.sequence IS NOT NULL OR
+       (to_jsonb(NEW) - 'sequence' - 'prev_hash' - 'hash') IS DISTINCT FROM
+       (to_jsonb(OLD) - 'sequence' - 'prev_hash' - 'hash') THEN
+        RAISE EXCEPTION 'audit log entries cannot be modified';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS protect_audit_logs ON audit_logs;
+CREATE TRIGGER protect_audit_logs
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW
+    EXECUTE FUNCTION protect_audit_logs();
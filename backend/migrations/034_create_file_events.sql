@@ -0,0 +1,53 @@
+-- Migration: Append-only file lifecycle events
+-- Each create, move, share and delete of a file is recorded with what changed.
+-- File history and the change feed are read from this table.
+--
+-- tx_id is the writing transaction. The change feed only returns events of
+-- transactions older than every one still running and orders them by tx_id,
+-- so an event that commits late can never fall behind a client's cursor.
+
+CREATE TABLE IF NOT EXISTS file_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sequence BIGSERIAL UNIQUE,
+    tx_id XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    file_id UUID NOT NULL,
+    owner_id UUID NOT NULL,
+    actor_id UUID,
+    type VARCHAR(20) NOT NULL,
+    payload JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- No foreign keys: history is kept after files and users are removed
+CREATE INDEX IF NOT EXISTS idx_file_events_file ON file_events(file_id, sequence);
+CREATE INDEX IF NOT EXISTS idx_file_events_owner ON file_events(owner_id, tx_id, sequence);
+
+-- Backfill a created event for files uploaded before events were recorded
+INSERT INTO file_events (file_id, owner_id, actor_id, type, payload, created_at)
+SELECT f.id, f.owner_id, f.owner_id, 'created',
+    jsonb_build_object('filename', f.original_filename, 'size', f.size, 'mime_type', f.mime_type,
+        'folder_id', f.folder_id, 'backfilled', true),
+    f.created_at
+FROM files f
+WHERE NOT EXISTS (SELECT 1 FROM file_events e WHERE e.file_id = f.id)
+ORDER BY f.created_at;
+
+INSERT INTO file_events (file_id, owner_id, actor_id, type, payload, created_at)
+SELECT f.id, f.owner_id, NULL, 'deleted', jsonb_build_object('backfilled', true),
+    COALESCE(f.deleted_at, f.updated_at)
+FROM files f
+WHERE f.is_deleted = true
+    AND NOT EXISTS (SELECT 1 FROM file_events e WHERE e.file_id = f.id AND e.type = 'deleted')
+ORDER BY f.deleted_at;
+
+CREATE OR REPLACE FUNCTION reject_file_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'file_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS file_events_append_only ON file_events;
+CREATE TRIGGER file_events_append_only
+    BEFORE UPDATE OR DELETE ON file_events
+    FOR EACH ROW EXECUTE FUNCTION reject_file_event_changes();
@@ -0,0 +1,19 @@
+-- Migration: One soft-delete marker for files
+-- Files were marked deleted by both is_deleted and deleted_at, and some code
+-- set only one of them, so a deleted file could still show up in queries that
+-- checked the other. deleted_at is now the only marker: GORM filters on it
+-- automatically and raw queries check deleted_at IS NULL.
+
+-- A file either flag marks as deleted stays deleted
+UPDATE files
+SET deleted_at = COALESCE(updated_at, CURRENT_TIMESTAMP)
+WHERE is_deleted = true AND deleted_at IS NULL;
+
+-- Dropping the column also drops the partial indexes built on it
+ALTER TABLE files DROP COLUMN IF EXISTS is_deleted;
+
+CREATE INDEX IF NOT EXISTS idx_files_owner_folder ON files(owner_id, folder_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_mime_size ON files(mime_type, size) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_created_owner ON files(created_at DESC, owner_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_public_active ON files(is_public) WHERE is_public = TRUE AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_unclassified ON files(created_at) WHERE classified_at IS NULL AND deleted_at IS NULL;
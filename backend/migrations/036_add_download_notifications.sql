@@ -0,0 +1,6 @@
+-- Migration: Per-file and per-share-link download notifications
+-- Owners can ask to be notified whenever someone else downloads a file,
+-- either every download of the file or only those through one share link.
+
+ALTER TABLE files ADD COLUMN IF NOT EXISTS notify_on_download BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE share_links ADD COLUMN IF NOT EXISTS notify_on_download BOOLEAN NOT NULL DEFAULT FALSE;
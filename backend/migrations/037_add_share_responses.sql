@@ -0,0 +1,14 @@
+-- Migration: Recipient responses to file and folder shares
+-- Recipients accept or decline shares and hide those they no longer want to
+-- see. Existing shares start out pending.
+
+ALTER TABLE file_shares ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';
+ALTER TABLE file_shares ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE file_shares ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE folder_shares ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';
+ALTER TABLE folder_shares ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE folder_shares ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_file_shares_recipient_status ON file_shares(shared_with, status);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_recipient_status ON folder_shares(shared_with, status);
@@ -0,0 +1,12 @@
+-- Migration: Expiring guest accounts
+-- Owners can invite an external collaborator as a guest while sharing. Guests
+-- have no storage, see only what is shared with them and are disabled once
+-- guest_expires_at passes.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS guest_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invited_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_guest_expiry ON users(guest_expires_at) WHERE role = 'guest' AND is_active = TRUE;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS check_user_role;
+ALTER TABLE users ADD CONSTRAINT check_user_role CHECK (role IN ('user', 'admin', 'guest'));
@@ -0,0 +1,7 @@
+-- Migration: SAML single sign-on
+-- Users signing in through the identity provider are linked by the NameID it
+-- asserts, so a changed email address still reaches the same account.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sso_subject VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_sso_subject ON users(sso_subject) WHERE sso_subject IS NOT NULL;
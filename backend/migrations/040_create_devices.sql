@@ -0,0 +1,19 @@
+-- Migration: Devices
+-- Each login registers the client it came from. Tokens carry the device ID,
+-- so a user can see where they are signed in and revoke one device without
+-- signing out everywhere.
+
+CREATE TABLE IF NOT EXISTS devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100),
+    platform VARCHAR(50),
+    client VARCHAR(20) NOT NULL DEFAULT 'web',
+    user_agent TEXT,
+    last_ip VARCHAR(45),
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_devices_user ON devices(user_id, last_seen_at DESC) WHERE revoked_at IS NULL;
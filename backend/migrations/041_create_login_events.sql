@@ -0,0 +1,20 @@
+-- Migration: Login history
+-- Every sign-in attempt, successful or not, is recorded so users can review
+-- where their account was used and spot logins they do not recognise.
+
+CREATE TABLE IF NOT EXISTS login_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(50),
+    method VARCHAR(20) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    country VARCHAR(2),
+    device_id UUID REFERENCES devices(id) ON DELETE SET NULL,
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_user ON login_events(user_id, created_at DESC);
@@ -0,0 +1,34 @@
+-- Migration: Folder size limits
+-- Owners and admins can cap how much a folder and its subfolders may hold.
+-- Running totals per folder subtree are kept in folder_stats so the cap can
+-- be checked without summing the file table on every upload or move.
+
+ALTER TABLE folders
+    ADD COLUMN IF NOT EXISTS size_limit BIGINT;
+
+ALTER TABLE folders DROP CONSTRAINT IF EXISTS check_folder_size_limit;
+ALTER TABLE folders ADD CONSTRAINT check_folder_size_limit CHECK (size_limit IS NULL OR size_limit >= 0);
+
+CREATE TABLE IF NOT EXISTS folder_stats (
+    folder_id UUID PRIMARY KEY REFERENCES folders(id) ON DELETE CASCADE,
+    file_count BIGINT NOT NULL DEFAULT 0,
+    total_size BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Backfill: every live file counts towards its folder and all ancestors
+INSERT INTO folder_stats (folder_id, file_count, total_size)
+WITH RECURSIVE ancestry AS (
+    SELECT id AS folder_id, id AS ancestor_id, parent_id
+    FROM folders
+    WHERE deleted_at IS NULL
+    UNION ALL
+    SELECT a.folder_id, f.id, f.parent_id
+    FROM ancestry a
+    JOIN folders f ON f.id = a.parent_id
+)
+SELECT a.ancestor_id, COUNT(fi.id), COALESCE(SUM(fi.size), 0)
+FROM ancestry a
+JOIN files fi ON fi.folder_id = a.folder_id AND fi.deleted_at IS NULL
+GROUP BY a.ancestor_id
+ON CONFLICT (folder_id) DO NOTHING;
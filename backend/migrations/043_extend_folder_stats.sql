@@ -0,0 +1,49 @@
+-- Migration: Folder statistics for listings
+-- folder_stats gains the number of direct subfolders and the time anything in
+-- the subtree last changed, and every live folder gets a row, so listings and
+-- deletes can read counts and sizes instead of counting files and folders.
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'folder_stats' AND column_name = 'updated_at') THEN
+        ALTER TABLE folder_stats RENAME COLUMN updated_at TO last_modified;
+    END IF;
+END $$;
+
+ALTER TABLE folder_stats
+    ADD COLUMN IF NOT EXISTS child_count BIGINT NOT NULL DEFAULT 0;
+
+INSERT INTO folder_stats (folder_id, last_modified)
+SELECT id, updated_at FROM folders WHERE deleted_at IS NULL
+ON CONFLICT (folder_id) DO NOTHING;
+
+UPDATE folder_stats s
+SET child_count = c.child_count
+FROM (
+    SELECT parent_id, COUNT(*) AS child_count
+    FROM folders
+    WHERE deleted_at IS NULL AND parent_id IS NOT NULL
+    GROUP BY parent_id
+) c
+WHERE s.folder_id = c.parent_id;
+
+-- Backfill: the latest change to a live file anywhere below each folder
+WITH RECURSIVE ancestry AS (
+    SELECT id AS folder_id, id AS ancestor_id, parent_id
+    FROM folders
+    WHERE deleted_at IS NULL
+    UNION ALL
+    SELECT a.folder_id, f.id, f.parent_id
+    FROM ancestry a
+    JOIN folders f ON f.id = a.parent_id
+)
+UPDATE folder_stats s
+SET last_modified = GREATEST(s.last_modified, m.last_modified)
+FROM (
+    SELECT a.ancestor_id, MAX(fi.updated_at) AS last_modified
+    FROM ancestry a
+    JOIN files fi ON fi.folder_id = a.folder_id AND fi.deleted_at IS NULL
+    GROUP BY a.ancestor_id
+) m
+WHERE s.folder_id = m.ancestor_id;
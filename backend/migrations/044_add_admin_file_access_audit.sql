@@ -0,0 +1,7 @@
+-- Migration: Admin file access audit
+-- Admins must give a reason to open or download a user's file, and each such
+-- access is logged with the file owner in details so owners can review it.
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_admin_access_owner
+    ON audit_logs ((details->>'owner_id'), created_at DESC)
+    WHERE action = 'admin_file_access';
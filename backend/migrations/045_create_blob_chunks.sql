@@ -0,0 +1,14 @@
+-- Migration: Download manifest chunk checksums
+-- Large blobs are split into fixed-size segments so clients can download them
+-- in parallel and verify each segment. The checksums are cached per blob and
+-- segment size, and go away with the blob.
+
+CREATE TABLE IF NOT EXISTS blob_chunks (
+    file_hash_id UUID NOT NULL REFERENCES file_hashes(id) ON DELETE CASCADE,
+    chunk_size BIGINT NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    "offset" BIGINT NOT NULL,
+    length BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    PRIMARY KEY (file_hash_id, chunk_size, chunk_index)
+);
@@ -0,0 +1,10 @@
+-- Migration: Share link pre-warm status
+-- With pre-warming on, creating a share link loads the file into the server's
+-- caches and pushes it to the CDN origin in the background; the link records
+-- how that went so owners can see whether the first visitor will be served
+-- warm.
+
+ALTER TABLE share_links
+    ADD COLUMN IF NOT EXISTS warm_status VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS warmed_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS warm_error TEXT NOT NULL DEFAULT '';
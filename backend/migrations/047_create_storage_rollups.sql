@@ -0,0 +1,33 @@
+-- Migration: Daily storage rollups
+-- One row per day with the logical size of all live files and the actual size
+-- of the blobs storing them, so the savings from deduplication can be charted
+-- without scanning the file table. The current day is refreshed by the server;
+-- earlier days are backfilled here from creation and deletion times. Blobs
+-- are deleted outright, so backfilled actual sizes only count blobs still
+-- stored today.
+
+CREATE TABLE IF NOT EXISTS storage_rollups (
+    day DATE PRIMARY KEY,
+    logical_bytes BIGINT NOT NULL DEFAULT 0,
+    actual_bytes BIGINT NOT NULL DEFAULT 0,
+    saved_bytes BIGINT NOT NULL DEFAULT 0,
+    file_count BIGINT NOT NULL DEFAULT 0,
+    blob_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO storage_rollups (day, logical_bytes, actual_bytes, saved_bytes, file_count, blob_count)
+SELECT d.day, f.logical_bytes, h.actual_bytes, GREATEST(f.logical_bytes - h.actual_bytes, 0), f.file_count, h.blob_count
+FROM generate_series(CURRENT_DATE - INTERVAL '90 days', CURRENT_DATE - INTERVAL '1 day', INTERVAL '1 day') AS d(day)
+CROSS JOIN LATERAL (
+    SELECT COALESCE(SUM(size), 0) AS logical_bytes, COUNT(*) AS file_count
+    FROM files
+    WHERE created_at < d.day + INTERVAL '1 day'
+      AND (deleted_at IS NULL OR deleted_at >= d.day + INTERVAL '1 day')
+) f
+CROSS JOIN LATERAL (
+    SELECT COALESCE(SUM(size), 0) AS actual_bytes, COUNT(*) AS blob_count
+    FROM file_hashes
+    WHERE created_at < d.day + INTERVAL '1 day'
+) h
+ON CONFLICT (day) DO NOTHING;
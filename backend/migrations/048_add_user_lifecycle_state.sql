@@ -0,0 +1,10 @@
+-- Migration: Inactive account lifecycle
+-- Accounts without a login for a configured number of days are warned, then
+-- made read-only, then archived; the user records the stage reached and when
+-- it was entered, so each stage's warning period can be honoured.
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS lifecycle_state VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS lifecycle_changed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_users_lifecycle_state ON users(lifecycle_state);
@@ -0,0 +1,20 @@
+-- Migration: Per-user rate limit overrides
+-- Admins can exempt a user from rate limiting, scale the limits that would
+-- otherwise apply, or replace them with an absolute limit. An override may
+-- expire, after which the normal limits apply again.
+
+CREATE TABLE IF NOT EXISTS rate_limit_overrides (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    exempt BOOLEAN NOT NULL DEFAULT false,
+    multiplier DOUBLE PRECISION NOT NULL DEFAULT 0, -- 0 leaves the limits unscaled
+    rate_limit INTEGER NOT NULL DEFAULT 0,          -- 0 uses the normal limits
+    rate_limit_burst INTEGER NOT NULL DEFAULT 0,
+    reason TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rate_limit_overrides_user_id ON rate_limit_overrides(user_id);
@@ -0,0 +1,28 @@
+-- Migration: Atomic database rate limit counting
+-- The database limiter now counts each request with a single upsert on
+-- (user_id, endpoint), so that pair must be unique, and stores the window
+-- length in nanoseconds as the server reads it. Windows that ended long ago
+-- are purged by the server.
+
+-- Keep the most recent window of any duplicated pair
+DELETE FROM api_rate_limits a
+USING api_rate_limits b
+WHERE a.user_id = b.user_id
+  AND a.endpoint = b.endpoint
+  AND (a.window_start, a.id) < (b.window_start, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_rate_limits_user_endpoint ON api_rate_limits(user_id, endpoint);
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'api_rate_limits' AND column_name = 'window_duration' AND data_type = 'interval'
+    ) THEN
+        ALTER TABLE api_rate_limits ALTER COLUMN window_duration DROP DEFAULT;
+        ALTER TABLE api_rate_limits ALTER COLUMN window_duration TYPE BIGINT
+            USING (EXTRACT(EPOCH FROM window_duration) * 1000000000)::BIGINT;
+    END IF;
+END $$;
+
+ALTER TABLE api_rate_limits ALTER COLUMN window_duration SET DEFAULT 1000000000;
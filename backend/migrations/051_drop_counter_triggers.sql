@@ -0,0 +1,26 @@
+-- Migration: Count blob references and storage usage in the application only
+-- The server has always updated file_hashes.reference_count and
+-- users.storage_used itself when files are uploaded, replaced and deleted.
+-- These triggers counted every upload a second time, so usage grew twice as
+-- fast as files were added and blobs were never released.
+
+DROP TRIGGER IF EXISTS update_file_hash_references ON files;
+DROP FUNCTION IF EXISTS update_file_hash_ref_count();
+
+DROP TRIGGER IF EXISTS update_user_storage ON files;
+DROP FUNCTION IF EXISTS update_user_storage_usage();
+
+-- Recount both from the live files
+UPDATE file_hashes SET reference_count = (
+    SELECT COUNT(*) FROM files
+    WHERE files.file_hash_id = file_hashes.id AND files.deleted_at IS NULL
+);
+
+-- A user's storage counts each distinct content they hold once
+UPDATE users SET storage_used = (
+    SELECT COALESCE(SUM(file_hashes.size), 0) FROM file_hashes
+    WHERE file_hashes.id IN (
+        SELECT files.file_hash_id FROM files
+        WHERE files.owner_id = users.id AND files.deleted_at IS NULL
+    )
+);
@@ -0,0 +1,30 @@
+-- Migration: Tenants hosting several organizations on one deployment
+-- Users outside any tenant (tenant_id NULL) belong to the platform itself,
+-- so existing installs keep working unchanged.
+
+CREATE TABLE IF NOT EXISTS tenants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(63) NOT NULL UNIQUE,   -- subdomain and header value that select the tenant
+    name VARCHAR(255) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    default_user_quota BIGINT NOT NULL DEFAULT 0,  -- 0 uses the server default
+    storage_quota BIGINT NOT NULL DEFAULT 0,       -- shared by all members; 0 is unlimited
+    allow_registration BOOLEAN NOT NULL DEFAULT true,
+    logo_url TEXT,
+    primary_color VARCHAR(7),
+    support_email VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id UUID REFERENCES tenants(id) ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users(tenant_id);
+
+-- Tenant admins manage their own tenant; platform admins stay outside tenants
+ALTER TABLE users DROP CONSTRAINT IF EXISTS check_user_role;
+ALTER TABLE users ADD CONSTRAINT check_user_role CHECK (role IN ('user', 'admin', 'guest', 'tenant_admin'));
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS check_user_tenant_role;
+ALTER TABLE users ADD CONSTRAINT check_user_tenant_role CHECK (
+    (role <> 'admin' OR tenant_id IS NULL) AND (role <> 'tenant_admin' OR tenant_id IS NOT NULL)
+);
@@ -0,0 +1,5 @@
+-- Migration: Custom domains serving a tenant's share links
+-- The reverse proxy terminates TLS for the domain and forwards to this server.
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS share_domain VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_share_domain ON tenants(share_domain) WHERE share_domain IS NOT NULL;
@@ -0,0 +1,11 @@
+-- Migration: Per-user email preferences for notifications
+-- Notifications are always delivered in-app; a row with email = false keeps
+-- that type of notification out of the user's inbox.
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    email BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, type)
+);
@@ -0,0 +1,25 @@
+-- Migration: Slack and Microsoft Teams integrations
+-- An integration belongs to a user (user_id), a tenant (tenant_id) or, with
+-- neither, to the platform, and posts the events it subscribes to to an
+-- incoming webhook.
+
+CREATE TABLE IF NOT EXISTS integrations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    webhook_url TEXT NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_delivered_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_integration_kind CHECK (kind IN ('slack', 'teams')),
+    CONSTRAINT check_integration_owner CHECK (user_id IS NULL OR tenant_id IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_integrations_user_id ON integrations(user_id);
+CREATE INDEX IF NOT EXISTS idx_integrations_tenant_id ON integrations(tenant_id);
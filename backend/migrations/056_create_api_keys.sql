@@ -0,0 +1,19 @@
+-- Migration: API keys for automation services
+-- Keys are stored as SHA-256 hashes; prefix keeps the first characters so
+-- users can tell their keys apart.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+-- The new-shares trigger finds the shared events naming a recipient
+CREATE INDEX IF NOT EXISTS idx_file_events_shared_with ON file_events((payload->>'shared_with'));
@@ -0,0 +1,15 @@
+-- Migration: Daily counts of anonymous access
+-- With ANONYMOUS_ACCESS_TRACKING=counts, visits to share links and public
+-- files are not logged one by one; each file or folder gets one row per day
+-- with how often it was viewed and downloaded.
+
+CREATE TABLE IF NOT EXISTS access_counts (
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id UUID NOT NULL,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL DEFAULT 0,
+    downloads BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (resource_type, resource_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_counts_day ON access_counts(day);
@@ -0,0 +1,12 @@
+-- Migration: Rotating JWT signing keys
+-- Login tokens name the key they were signed with in their kid header. Only
+-- a salt is stored; the key is derived from it and JWT_SECRET. Replaced
+-- keys keep validating tokens until expires_at.
+
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    id VARCHAR(32) PRIMARY KEY,
+    salt VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
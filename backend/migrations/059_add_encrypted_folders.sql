@@ -0,0 +1,33 @@
+-- Migration: End-to-end encrypted (vault) folders
+-- Files in a vault folder are encrypted by the client. The server keeps the
+-- ciphertext, each file's opaque encryption header, and the vault's key
+-- wrapped for every recipient and optionally for an escrow key.
+
+ALTER TABLE folders
+    ADD COLUMN IF NOT EXISTS vault_id UUID;
+
+ALTER TABLE files
+    ADD COLUMN IF NOT EXISTS is_encrypted BOOLEAN DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS encryption_header TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_folders_vault_id ON folders(vault_id) WHERE vault_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS user_public_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    algorithm VARCHAR(50) NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS vault_key_grants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    vault_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    recipient_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    wrapped_key TEXT NOT NULL,
+    granted_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vault_key_grants_recipient ON vault_key_grants(vault_id, recipient_id) WHERE recipient_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vault_key_grants_escrow ON vault_key_grants(vault_id) WHERE recipient_id IS NULL;
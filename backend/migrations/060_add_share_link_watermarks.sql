@@ -0,0 +1,6 @@
+-- Migration: Watermarked share links
+-- Content opened through a watermarked link is stamped with who opened it,
+-- from where and when, plus an optional label chosen by the link's creator.
+
+ALTER TABLE share_links ADD COLUMN IF NOT EXISTS watermark BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE share_links ADD COLUMN IF NOT EXISTS watermark_text VARCHAR(100) NOT NULL DEFAULT '';
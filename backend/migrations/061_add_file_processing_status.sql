@@ -0,0 +1,16 @@
+-- Migration: File processing status
+-- Tracks how far the scanning pipeline has got with each file's content:
+-- pending_scan until the background malware scan has looked at it, then
+-- clean, quarantined or failed.
+
+ALTER TABLE files ADD COLUMN IF NOT EXISTS processing_status VARCHAR(20) NOT NULL DEFAULT 'clean';
+
+UPDATE files SET processing_status = 'quarantined' WHERE is_quarantined = TRUE;
+
+UPDATE files SET processing_status = 'pending_scan'
+WHERE is_quarantined = FALSE
+  AND is_encrypted = FALSE
+  AND EXISTS (
+      SELECT 1 FROM file_hashes fh
+      WHERE fh.id = files.file_hash_id AND fh.malware_scanned_at IS NULL
+  );
@@ -0,0 +1,6 @@
+-- Migration: Image dimensions
+-- Width and height of image content for gallery listings. They are measured
+-- the first time the content is listed, so existing rows start out NULL.
+
+ALTER TABLE file_hashes ADD COLUMN IF NOT EXISTS width INTEGER;
+ALTER TABLE file_hashes ADD COLUMN IF NOT EXISTS height INTEGER;
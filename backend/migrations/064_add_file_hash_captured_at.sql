@@ -0,0 +1,8 @@
+-- Migration: Photo capture time
+-- When photos were taken, from their EXIF metadata, for the timeline. Images
+-- already measured for the gallery are measured again so their capture time
+-- is read too.
+
+ALTER TABLE file_hashes ADD COLUMN IF NOT EXISTS captured_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE file_hashes SET width = NULL, height = NULL WHERE width IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Migration: Album share links
+-- Folder share links opened as a photo album rather than a file listing.
+
+ALTER TABLE folder_share_links ADD COLUMN IF NOT EXISTS mode VARCHAR(20) NOT NULL DEFAULT 'folder';
@@ -0,0 +1,23 @@
+-- Migration: tus resumable uploads
+-- Uploads sent with the tus protocol arrive in order, so only the offset
+-- reached so far is recorded; the content received is appended to one file
+-- in the upload temp directory.
+
+CREATE TABLE IF NOT EXISTS tus_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    metadata TEXT,
+    is_public BOOLEAN DEFAULT FALSE,
+    encryption_header TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tus_uploads_owner_id ON tus_uploads(owner_id);
+CREATE INDEX IF NOT EXISTS idx_tus_uploads_expires_at ON tus_uploads(expires_at);
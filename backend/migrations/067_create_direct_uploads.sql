@@ -0,0 +1,21 @@
+-- Migration: direct uploads to object storage
+-- The client uploads the content itself with a presigned URL; the row keeps
+-- what it declared until it finalizes the upload.
+
+CREATE TABLE IF NOT EXISTS direct_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    object_key VARCHAR(255) NOT NULL,
+    is_public BOOLEAN DEFAULT FALSE,
+    encryption_header TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_direct_uploads_owner_id ON direct_uploads(owner_id);
+CREATE INDEX IF NOT EXISTS idx_direct_uploads_expires_at ON direct_uploads(expires_at);
@@ -0,0 +1,17 @@
+-- Migration: Default upload folder and upload rules
+-- Uploads that name no folder go to the first folder whose rule matches their
+-- type, else to the user's default upload folder, else to the root folder.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS default_upload_folder_id UUID REFERENCES folders(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS upload_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    mime_type VARCHAR(100),
+    extension VARCHAR(20),
+    folder_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_rules_user_id ON upload_rules(user_id, position);
@@ -0,0 +1,25 @@
+-- Migration: URL imports
+-- Files the server downloads from a URL on a user's behalf and stores like
+-- an upload, with the progress clients poll.
+
+CREATE TABLE IF NOT EXISTS url_imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    filename VARCHAR(255),
+    folder_id UUID REFERENCES folders(id) ON DELETE SET NULL,
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    bytes_received BIGINT NOT NULL DEFAULT 0,
+    total_bytes BIGINT,
+    file_id UUID REFERENCES files(id) ON DELETE SET NULL,
+    error_code VARCHAR(50),
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_imports_user_id ON url_imports(user_id);
+CREATE INDEX IF NOT EXISTS idx_url_imports_status ON url_imports(status);
@@ -0,0 +1,9 @@
+-- Migration: Download permission on folder shares
+-- Folder shares are granted with the same view and download permissions as
+-- file shares, but the table still only allowed 'view' and the never-used
+-- 'edit', so sharing a folder with download permission failed.
+
+UPDATE folder_shares SET permission = 'view' WHERE permission NOT IN ('view', 'download');
+
+ALTER TABLE folder_shares DROP CONSTRAINT IF EXISTS folder_shares_permission_check;
+ALTER TABLE folder_shares ADD CONSTRAINT folder_shares_permission_check CHECK (permission IN ('view', 'download'));
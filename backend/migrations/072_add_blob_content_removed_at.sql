@@ -0,0 +1,7 @@
+-- Migration: Remove the content of blobs that no file references
+-- Deleted files keep pointing at their hash row, so a blob stays in
+-- file_hashes after its reference count drops to zero. The orphan blob sweep
+-- deletes its content from storage and records when; uploading the content
+-- again stores it afresh.
+
+ALTER TABLE file_hashes ADD COLUMN IF NOT EXISTS content_removed_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,833 @@
+-- Migration: 001_initial_schema (SQLite)
+-- Description: Schema for SQLite deployments, matching the PostgreSQL schema
+-- after migration 050. Later PostgreSQL migrations that change the schema need
+-- a counterpart in this directory.
+--
+-- UUID columns are stored as text and filled by gen_random_uuid(), which the
+-- server registers on every SQLite connection. JSONB, INET and TEXT[] columns
+-- are stored as text. Timestamps are declared TIMESTAMP so the driver reads
+-- them back as times.
+
+CREATE TABLE IF NOT EXISTS roles (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS plans (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    storage_quota BIGINT NOT NULL,
+    max_file_size BIGINT DEFAULT 0,
+    rate_limit INTEGER DEFAULT 0,
+    rate_limit_burst INTEGER DEFAULT 0,
+    allow_public_sharing BOOLEAN DEFAULT TRUE,
+    is_default BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    username VARCHAR(100) UNIQUE NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    first_name VARCHAR(100),
+    last_name VARCHAR(100),
+    role VARCHAR(20) NOT NULL DEFAULT 'user' CONSTRAINT check_user_role CHECK (role IN ('user', 'admin', 'guest')),
+    storage_quota BIGINT DEFAULT 10485760,
+    storage_used BIGINT DEFAULT 0,
+    plan_id TEXT REFERENCES plans(id) ON DELETE SET NULL,
+    quota_grace_started_at TIMESTAMP,
+    quota_grace_expires_at TIMESTAMP,
+    quota_grace_revoked_at TIMESTAMP,
+    total_uploaded_bytes BIGINT DEFAULT 0,
+    actual_storage_bytes BIGINT DEFAULT 0,
+    saved_bytes BIGINT DEFAULT 0,
+    auto_tagging_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    is_active BOOLEAN DEFAULT TRUE,
+    email_verified BOOLEAN DEFAULT FALSE,
+    last_login TIMESTAMP,
+    lifecycle_state VARCHAR(20) NOT NULL DEFAULT 'active',
+    lifecycle_changed_at TIMESTAMP,
+    guest_expires_at TIMESTAMP,
+    invited_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    sso_subject VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
+CREATE INDEX IF NOT EXISTS idx_users_last_login ON users(last_login);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
+CREATE INDEX IF NOT EXISTS idx_users_plan_id ON users(plan_id);
+CREATE INDEX IF NOT EXISTS idx_users_quota_grace_expires ON users(quota_grace_expires_at) WHERE quota_grace_expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_guest_expiry ON users(guest_expires_at) WHERE role = 'guest' AND is_active = TRUE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_sso_subject ON users(sso_subject) WHERE sso_subject IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_lifecycle_state ON users(lifecycle_state);
+
+-- At most one plan is assigned to new users
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plans_single_default ON plans(is_default) WHERE is_default = TRUE;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id TEXT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    assigned_by TEXT REFERENCES users(id),
+    UNIQUE (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles(role_id);
+
+CREATE TABLE IF NOT EXISTS file_hashes (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    hash VARCHAR(64) UNIQUE NOT NULL,
+    size BIGINT NOT NULL,
+    storage_path TEXT NOT NULL,
+    reference_count INTEGER DEFAULT 0,
+    replication_status VARCHAR(20) DEFAULT 'pending',
+    replication_attempts INTEGER DEFAULT 0,
+    replication_error TEXT,
+    replicated_at TIMESTAMP,
+    storage_tier VARCHAR(20) DEFAULT 'hot',
+    archived_at TIMESTAMP,
+    restored_at TIMESTAMP,
+    restore_requested_at TIMESTAMP,
+    restore_requested_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    malware_scanned_at TIMESTAMP,
+    malware_signature VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_hashes_size ON file_hashes(size);
+CREATE INDEX IF NOT EXISTS idx_file_hashes_reference_count ON file_hashes(reference_count);
+CREATE INDEX IF NOT EXISTS idx_file_hashes_replication_status ON file_hashes(replication_status, created_at);
+CREATE INDEX IF NOT EXISTS idx_file_hashes_storage_tier ON file_hashes(storage_tier);
+CREATE INDEX IF NOT EXISTS idx_file_hashes_malware_scanned ON file_hashes(malware_scanned_at);
+
+CREATE TABLE IF NOT EXISTS folders (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    name VARCHAR(255) NOT NULL,
+    parent_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+    owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    is_worm BOOLEAN DEFAULT FALSE,
+    retention_days INTEGER DEFAULT 0,
+    worm_enabled_at TIMESTAMP,
+    size_limit BIGINT CONSTRAINT check_folder_size_limit CHECK (size_limit IS NULL OR size_limit >= 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    UNIQUE (owner_id, parent_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_folders_parent_id ON folders(parent_id);
+CREATE INDEX IF NOT EXISTS idx_folders_owner_id ON folders(owner_id);
+CREATE INDEX IF NOT EXISTS idx_folders_path ON folders(path);
+CREATE INDEX IF NOT EXISTS idx_folders_name ON folders(name);
+CREATE INDEX IF NOT EXISTS idx_folders_deleted_at ON folders(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_folders_worm ON folders(owner_id, path) WHERE is_worm = TRUE;
+
+CREATE TABLE IF NOT EXISTS files (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    filename VARCHAR(255) NOT NULL,
+    original_filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    file_hash_id TEXT NOT NULL REFERENCES file_hashes(id) ON DELETE RESTRICT,
+    owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL,
+    tags TEXT,
+    auto_tags TEXT,
+    classified_at TIMESTAMP,
+    description TEXT,
+    content_hash VARCHAR(64),
+    is_public BOOLEAN DEFAULT FALSE,
+    storage_tier VARCHAR(20) DEFAULT 'hot',
+    is_quarantined BOOLEAN DEFAULT FALSE,
+    notify_on_download BOOLEAN NOT NULL DEFAULT FALSE,
+    worm_locked_at TIMESTAMP,
+    retain_until TIMESTAMP,
+    share_count INTEGER DEFAULT 0,
+    is_shared BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_files_filename ON files(filename);
+CREATE INDEX IF NOT EXISTS idx_files_original_filename ON files(original_filename);
+CREATE INDEX IF NOT EXISTS idx_files_mime_type ON files(mime_type);
+CREATE INDEX IF NOT EXISTS idx_files_size ON files(size);
+CREATE INDEX IF NOT EXISTS idx_files_file_hash_id ON files(file_hash_id);
+CREATE INDEX IF NOT EXISTS idx_files_owner_id ON files(owner_id);
+CREATE INDEX IF NOT EXISTS idx_files_folder_id ON files(folder_id);
+CREATE INDEX IF NOT EXISTS idx_files_created_at ON files(created_at);
+CREATE INDEX IF NOT EXISTS idx_files_updated_at ON files(updated_at);
+CREATE INDEX IF NOT EXISTS idx_files_deleted_at ON files(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_files_is_public ON files(is_public);
+CREATE INDEX IF NOT EXISTS idx_files_quarantined ON files(is_quarantined) WHERE is_quarantined = TRUE;
+CREATE INDEX IF NOT EXISTS idx_files_retain_until ON files(retain_until) WHERE retain_until IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_files_owner_folder ON files(owner_id, folder_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_mime_size ON files(mime_type, size) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_created_owner ON files(created_at DESC, owner_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_public_active ON files(is_public) WHERE is_public = TRUE AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_unclassified ON files(created_at) WHERE classified_at IS NULL AND deleted_at IS NULL;
+
+-- Legacy share tables, kept so download statistics can reference them
+CREATE TABLE IF NOT EXISTS shared_links (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    token VARCHAR(255) UNIQUE NOT NULL,
+    file_id TEXT REFERENCES files(id) ON DELETE CASCADE,
+    folder_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+    shared_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    share_type VARCHAR(20) NOT NULL CHECK (share_type IN ('public', 'private', 'password')),
+    password_hash VARCHAR(255),
+    expires_at TIMESTAMP,
+    max_downloads INTEGER,
+    download_count INTEGER DEFAULT 0,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    CONSTRAINT check_file_or_folder CHECK (
+        (file_id IS NOT NULL AND folder_id IS NULL) OR
+        (file_id IS NULL AND folder_id IS NOT NULL)
+    )
+);
+
+CREATE TABLE IF NOT EXISTS user_file_shares (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    file_id TEXT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    shared_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_with TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(20) DEFAULT 'read' CHECK (permission IN ('read', 'write', 'admin')),
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (file_id, shared_with)
+);
+
+CREATE TABLE IF NOT EXISTS download_stats (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    file_id TEXT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    downloaded_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    shared_link_id TEXT REFERENCES shared_links(id) ON DELETE SET NULL,
+    ip_address TEXT,
+    user_agent TEXT,
+    download_size BIGINT,
+    downloaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_download_stats_file_id ON download_stats(file_id);
+CREATE INDEX IF NOT EXISTS idx_download_stats_downloaded_by ON download_stats(downloaded_by);
+CREATE INDEX IF NOT EXISTS idx_download_stats_downloaded_at ON download_stats(downloaded_at);
+CREATE INDEX IF NOT EXISTS idx_download_stats_file_downloaded ON download_stats(file_id, downloaded_at);
+
+CREATE TABLE IF NOT EXISTS file_shares (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    file_id TEXT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    shared_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_with TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(20) NOT NULL DEFAULT 'view',
+    message TEXT,
+    expires_at TIMESTAMP,
+    is_active BOOLEAN DEFAULT TRUE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    responded_at TIMESTAMP,
+    hidden_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    UNIQUE (file_id, shared_by, shared_with)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_shares_file_id ON file_shares(file_id);
+CREATE INDEX IF NOT EXISTS idx_file_shares_shared_by ON file_shares(shared_by);
+CREATE INDEX IF NOT EXISTS idx_file_shares_shared_with ON file_shares(shared_with);
+CREATE INDEX IF NOT EXISTS idx_file_shares_deleted_at ON file_shares(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_file_shares_recipient_status ON file_shares(shared_with, status);
+
+CREATE TABLE IF NOT EXISTS share_links (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    file_id TEXT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    created_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    share_token VARCHAR(128) UNIQUE NOT NULL,
+    permission VARCHAR(20) NOT NULL DEFAULT 'view',
+    password_hash VARCHAR(255),
+    max_downloads INTEGER,
+    download_count INTEGER DEFAULT 0,
+    expires_at TIMESTAMP,
+    is_active BOOLEAN DEFAULT TRUE,
+    last_accessed_at TIMESTAMP,
+    notify_on_download BOOLEAN NOT NULL DEFAULT FALSE,
+    warm_status VARCHAR(20) NOT NULL DEFAULT '',
+    warmed_at TIMESTAMP,
+    warm_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_file_id ON share_links(file_id);
+CREATE INDEX IF NOT EXISTS idx_share_links_created_by ON share_links(created_by);
+CREATE INDEX IF NOT EXISTS idx_share_links_deleted_at ON share_links(deleted_at);
+
+CREATE TABLE IF NOT EXISTS share_link_access_logs (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    share_link_id TEXT NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+    ip_address TEXT,
+    user_agent TEXT,
+    action VARCHAR(50) NOT NULL,
+    accessed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_link_access_logs_link_id ON share_link_access_logs(share_link_id);
+CREATE INDEX IF NOT EXISTS idx_share_link_access_logs_accessed_at ON share_link_access_logs(accessed_at);
+
+CREATE TABLE IF NOT EXISTS folder_shares (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    folder_id TEXT NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    shared_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_with TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(20) NOT NULL DEFAULT 'view' CHECK (permission IN ('view', 'edit')),
+    message TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    responded_at TIMESTAMP,
+    hidden_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_shares_folder_id ON folder_shares(folder_id);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_shared_by ON folder_shares(shared_by);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_shared_with ON folder_shares(shared_with);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_deleted_at ON folder_shares(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_recipient_status ON folder_shares(shared_with, status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_unique_folder_share ON folder_shares(folder_id, shared_by, shared_with) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS folder_share_links (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    folder_id TEXT NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    created_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255),
+    permission VARCHAR(20) NOT NULL DEFAULT 'view' CHECK (permission IN ('view', 'download')),
+    expires_at TIMESTAMP,
+    is_active BOOLEAN DEFAULT TRUE,
+    max_downloads INTEGER,
+    download_count INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_share_links_folder_id ON folder_share_links(folder_id);
+CREATE INDEX IF NOT EXISTS idx_folder_share_links_created_by ON folder_share_links(created_by);
+CREATE INDEX IF NOT EXISTS idx_folder_share_links_deleted_at ON folder_share_links(deleted_at);
+
+CREATE TABLE IF NOT EXISTS folder_share_link_access_logs (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    share_link_id TEXT NOT NULL REFERENCES folder_share_links(id) ON DELETE CASCADE,
+    accessed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ip_address TEXT,
+    user_agent TEXT,
+    action VARCHAR(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_share_link_access_logs_share_link_id ON folder_share_link_access_logs(share_link_id);
+CREATE INDEX IF NOT EXISTS idx_folder_share_link_access_logs_accessed_at ON folder_share_link_access_logs(accessed_at);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id TEXT,
+    resource_name VARCHAR(255),
+    details TEXT,
+    ip_address TEXT,
+    user_agent TEXT,
+    status VARCHAR(20) DEFAULT 'success',
+    sequence BIGINT,
+    prev_hash VARCHAR(64),
+    hash VARCHAR(64),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_action ON audit_logs(user_id, action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_sequence ON audit_logs(sequence);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_unsealed ON audit_logs(created_at, id) WHERE sequence IS NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_admin_access_owner
+    ON audit_logs ((details->>'owner_id'), created_at DESC)
+    WHERE action = 'admin_file_access';
+
+CREATE TABLE IF NOT EXISTS audit_outbox (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id TEXT,
+    resource_name VARCHAR(255),
+    details TEXT,
+    ip_address TEXT,
+    user_agent TEXT,
+    status VARCHAR(20) DEFAULT 'success',
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_outbox_created_at ON audit_outbox(created_at);
+
+-- Retention pruning adds a row here for the length of its transaction; audit
+-- entries can only be deleted while it exists. It stands in for the
+-- audit.allow_prune setting used on PostgreSQL.
+CREATE TABLE IF NOT EXISTS audit_prune_grants (
+    id INTEGER PRIMARY KEY
+);
+
+-- sequence is the table's rowid, so it increases with every insert like the
+-- BIGSERIAL column on PostgreSQL. SQLite runs one write transaction at a
+-- time, so events commit in sequence order and tx_id is not needed.
+CREATE TABLE IF NOT EXISTS file_events (
+    sequence INTEGER PRIMARY KEY AUTOINCREMENT,
+    id TEXT UNIQUE NOT NULL DEFAULT (gen_random_uuid()),
+    tx_id BIGINT NOT NULL DEFAULT 0,
+    file_id TEXT NOT NULL,
+    owner_id TEXT NOT NULL,
+    actor_id TEXT,
+    type VARCHAR(20) NOT NULL,
+    payload TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_events_file ON file_events(file_id, sequence);
+CREATE INDEX IF NOT EXISTS idx_file_events_owner ON file_events(owner_id, sequence);
+
+CREATE TABLE IF NOT EXISTS api_rate_limits (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    endpoint VARCHAR(255) NOT NULL,
+    request_count INTEGER DEFAULT 0,
+    window_start TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    window_duration BIGINT DEFAULT 1000000000,
+    max_requests INTEGER DEFAULT 2
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_rate_limits_user_endpoint ON api_rate_limits(user_id, endpoint);
+CREATE INDEX IF NOT EXISTS idx_api_rate_limits_window_start ON api_rate_limits(window_start);
+
+CREATE TABLE IF NOT EXISTS rate_limit_overrides (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    exempt BOOLEAN NOT NULL DEFAULT FALSE,
+    multiplier DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rate_limit INTEGER NOT NULL DEFAULT 0,
+    rate_limit_burst INTEGER NOT NULL DEFAULT 0,
+    reason TEXT,
+    expires_at TIMESTAMP,
+    created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rate_limit_overrides_user_id ON rate_limit_overrides(user_id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    severity VARCHAR(20) NOT NULL DEFAULT 'info',
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    details TEXT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS backup_jobs (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    mode VARCHAR(20) NOT NULL DEFAULT 'full',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    started_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    backup_dir TEXT,
+    blob_count INTEGER DEFAULT 0,
+    blobs_copied INTEGER DEFAULT 0,
+    bytes_copied BIGINT DEFAULT 0,
+    missing_blobs INTEGER DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_backup_jobs_created_at ON backup_jobs(created_at DESC);
+
+CREATE TABLE IF NOT EXISTS upload_policies (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    role VARCHAR(20) NOT NULL DEFAULT '',
+    match_type VARCHAR(20) NOT NULL,
+    pattern VARCHAR(100) NOT NULL,
+    blocked BOOLEAN DEFAULT FALSE,
+    max_size BIGINT,
+    allow_public BOOLEAN DEFAULT TRUE,
+    description TEXT,
+    created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_policies_role ON upload_policies(role);
+
+CREATE TABLE IF NOT EXISTS file_quarantines (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    file_id TEXT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    findings TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reviewed_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    review_note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_quarantines_status_created ON file_quarantines(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_file_quarantines_file ON file_quarantines(file_id);
+
+CREATE TABLE IF NOT EXISTS policy_documents (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    type VARCHAR(20) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    effective_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (type, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_documents_type_effective ON policy_documents(type, effective_at DESC);
+
+CREATE TABLE IF NOT EXISTS policy_acceptances (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    document_id TEXT NOT NULL REFERENCES policy_documents(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    accepted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, document_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_policy_acceptances_document ON policy_acceptances(document_id);
+
+CREATE TABLE IF NOT EXISTS upload_rejections (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason VARCHAR(30) NOT NULL,
+    code VARCHAR(50),
+    message TEXT,
+    filename VARCHAR(255),
+    declared_mime_type VARCHAR(100),
+    detected_mime_type VARCHAR(100),
+    size BIGINT DEFAULT 0,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_created ON upload_rejections(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_reason_created ON upload_rejections(reason, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_upload_rejections_user_created ON upload_rejections(user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS content_index (
+    file_hash_id TEXT PRIMARY KEY REFERENCES file_hashes(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    method VARCHAR(10),
+    engine VARCHAR(50),
+    mime_type VARCHAR(100),
+    extracted_text TEXT,
+    char_count INTEGER DEFAULT 0,
+    attempts INTEGER DEFAULT 0,
+    error TEXT,
+    indexed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_index_pending ON content_index(created_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS devices (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100),
+    platform VARCHAR(50),
+    client VARCHAR(20) NOT NULL DEFAULT 'web',
+    user_agent TEXT,
+    last_ip VARCHAR(45),
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_devices_user ON devices(user_id, last_seen_at DESC) WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS login_events (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(50),
+    method VARCHAR(20) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    country VARCHAR(2),
+    device_id TEXT REFERENCES devices(id) ON DELETE SET NULL,
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_user ON login_events(user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS folder_stats (
+    folder_id TEXT PRIMARY KEY REFERENCES folders(id) ON DELETE CASCADE,
+    file_count BIGINT NOT NULL DEFAULT 0,
+    total_size BIGINT NOT NULL DEFAULT 0,
+    child_count BIGINT NOT NULL DEFAULT 0,
+    last_modified TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS blob_chunks (
+    file_hash_id TEXT NOT NULL REFERENCES file_hashes(id) ON DELETE CASCADE,
+    chunk_size BIGINT NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    "offset" BIGINT NOT NULL,
+    length BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    PRIMARY KEY (file_hash_id, chunk_size, chunk_index)
+);
+
+CREATE TABLE IF NOT EXISTS storage_rollups (
+    day DATE PRIMARY KEY,
+    logical_bytes BIGINT NOT NULL DEFAULT 0,
+    actual_bytes BIGINT NOT NULL DEFAULT 0,
+    saved_bytes BIGINT NOT NULL DEFAULT 0,
+    file_count BIGINT NOT NULL DEFAULT 0,
+    blob_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Keep updated_at current for updates that do not set it
+CREATE TRIGGER IF NOT EXISTS update_users_updated_at AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_files_updated_at AFTER UPDATE ON files
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE files SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_folders_updated_at AFTER UPDATE ON folders
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE folders SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_file_shares_updated_at AFTER UPDATE ON file_shares
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE file_shares SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_share_links_updated_at AFTER UPDATE ON share_links
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE share_links SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_share_link_access_logs_updated_at AFTER UPDATE ON share_link_access_logs
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE share_link_access_logs SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+-- Count the files referencing each blob
+CREATE TRIGGER IF NOT EXISTS file_hash_references_insert AFTER INSERT ON files
+FOR EACH ROW
+BEGIN
+    UPDATE file_hashes SET reference_count = reference_count + 1 WHERE id = NEW.file_hash_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_hash_references_delete AFTER DELETE ON files
+FOR EACH ROW
+BEGIN
+    UPDATE file_hashes SET reference_count = reference_count - 1 WHERE id = OLD.file_hash_id;
+END;
+
+-- Charge file sizes to their owner's storage
+CREATE TRIGGER IF NOT EXISTS user_storage_insert AFTER INSERT ON files
+FOR EACH ROW
+BEGIN
+    UPDATE users SET storage_used = storage_used + NEW.size WHERE id = NEW.owner_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS user_storage_delete AFTER DELETE ON files
+FOR EACH ROW
+BEGIN
+    UPDATE users SET storage_used = storage_used - OLD.size WHERE id = OLD.owner_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS user_storage_owner_change AFTER UPDATE OF owner_id ON files
+FOR EACH ROW WHEN OLD.owner_id != NEW.owner_id
+BEGIN
+    UPDATE users SET storage_used = storage_used - OLD.size WHERE id = OLD.owner_id;
+    UPDATE users SET storage_used = storage_used + NEW.size WHERE id = NEW.owner_id;
+END;
+
+-- Keep each file's share count and shared flag in step with its active
+-- shares and share links
+CREATE TRIGGER IF NOT EXISTS file_sharing_stats_file_shares_insert AFTER INSERT ON file_shares
+FOR EACH ROW
+BEGIN
+    UPDATE files SET
+        share_count = (SELECT COUNT(*) FROM file_shares WHERE file_id = NEW.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = NEW.file_id AND is_active = TRUE),
+        is_shared = TRUE
+    WHERE id = NEW.file_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_sharing_stats_file_shares_update AFTER UPDATE ON file_shares
+FOR EACH ROW
+BEGIN
+    UPDATE files SET
+        share_count = (SELECT COUNT(*) FROM file_shares WHERE file_id = NEW.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = NEW.file_id AND is_active = TRUE),
+        is_shared = (SELECT COUNT(*) FROM file_shares WHERE file_id = NEW.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = NEW.file_id AND is_active = TRUE) > 0
+    WHERE id = NEW.file_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_sharing_stats_file_shares_delete AFTER DELETE ON file_shares
+FOR EACH ROW
+BEGIN
+    UPDATE files SET
+        share_count = (SELECT COUNT(*) FROM file_shares WHERE file_id = OLD.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = OLD.file_id AND is_active = TRUE),
+        is_shared = (SELECT COUNT(*) FROM file_shares WHERE file_id = OLD.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = OLD.file_id AND is_active = TRUE) > 0
+    WHERE id = OLD.file_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_sharing_stats_share_links_insert AFTER INSERT ON share_links
+FOR EACH ROW
+BEGIN
+    UPDATE files SET
+        share_count = (SELECT COUNT(*) FROM file_shares WHERE file_id = NEW.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = NEW.file_id AND is_active = TRUE),
+        is_shared = TRUE
+    WHERE id = NEW.file_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_sharing_stats_share_links_update AFTER UPDATE ON share_links
+FOR EACH ROW
+BEGIN
+    UPDATE files SET
+        share_count = (SELECT COUNT(*) FROM file_shares WHERE file_id = NEW.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = NEW.file_id AND is_active = TRUE),
+        is_shared = (SELECT COUNT(*) FROM file_shares WHERE file_id = NEW.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = NEW.file_id AND is_active = TRUE) > 0
+    WHERE id = NEW.file_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_sharing_stats_share_links_delete AFTER DELETE ON share_links
+FOR EACH ROW
+BEGIN
+    UPDATE files SET
+        share_count = (SELECT COUNT(*) FROM file_shares WHERE file_id = OLD.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = OLD.file_id AND is_active = TRUE),
+        is_shared = (SELECT COUNT(*) FROM file_shares WHERE file_id = OLD.file_id AND is_active = TRUE)
+            + (SELECT COUNT(*) FROM share_links WHERE file_id = OLD.file_id AND is_active = TRUE) > 0
+    WHERE id = OLD.file_id;
+END;
+
+-- Sealed audit entries cannot be changed. Unsealed entries may only receive
+-- their chain columns. Deletes are allowed only while retention pruning holds
+-- a grant.
+CREATE TRIGGER IF NOT EXISTS protect_audit_logs_update BEFORE UPDATE ON audit_logs
+FOR EACH ROW WHEN OLD.sequence IS NOT NULL
+    OR NEW.id IS NOT OLD.id
+    OR NEW.user_id IS NOT OLD.user_id
+    OR NEW.action IS NOT OLD.action
+    OR NEW.resource_type IS NOT OLD.resource_type
+    OR NEW.resource_id IS NOT OLD.resource_id
+    OR NEW.resource_name IS NOT OLD.resource_name
+    OR NEW.details IS NOT OLD.details
+    OR NEW.ip_address IS NOT OLD.ip_address
+    OR NEW.user_agent IS NOT OLD.user_agent
+    OR NEW.status IS NOT OLD.status
+    OR NEW.created_at IS NOT OLD.created_at
+    OR NEW.updated_at IS NOT OLD.updated_at
+BEGIN
+    SELECT RAISE(ABORT, 'audit log entries cannot be modified');
+END;
+
+CREATE TRIGGER IF NOT EXISTS protect_audit_logs_delete BEFORE DELETE ON audit_logs
+FOR EACH ROW WHEN NOT EXISTS (SELECT 1 FROM audit_prune_grants)
+BEGIN
+    SELECT RAISE(ABORT, 'audit log entries cannot be deleted');
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_events_append_only_update BEFORE UPDATE ON file_events
+BEGIN
+    SELECT RAISE(ABORT, 'file_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS file_events_append_only_delete BEFORE DELETE ON file_events
+BEGIN
+    SELECT RAISE(ABORT, 'file_events is append-only');
+END;
+
+-- Default roles and administrator (password: admin - change it after the
+-- first login)
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'System administrator with full access'),
+    ('user', 'Regular user with standard file operations')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO users (username, email, password_hash, first_name, last_name, role, storage_quota, is_active, email_verified)
+VALUES ('admin', 'admin@gmail.com', '$2a$10$EgduKhY6.IILNytQ0Ooes.Pdpxy.MZkBuLsEUnkMfXKGXv7sNAJ9e',
+    'System', 'Administrator', 'admin', 107374182400, TRUE, TRUE)
+ON CONFLICT (username) DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id
+FROM users u, roles r
+WHERE u.username = 'admin' AND r.name = 'admin'
+ON CONFLICT (user_id, role_id) DO NOTHING;
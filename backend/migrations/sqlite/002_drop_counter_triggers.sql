@@ -0,0 +1,26 @@
+-- Migration: Count blob references and storage usage in the application only
+-- Mirrors 051_drop_counter_triggers.sql: the server updates
+-- file_hashes.reference_count and users.storage_used itself, so these
+-- triggers counted every upload a second time.
+
+DROP TRIGGER IF EXISTS file_hash_references_insert;
+DROP TRIGGER IF EXISTS file_hash_references_delete;
+
+DROP TRIGGER IF EXISTS user_storage_insert;
+DROP TRIGGER IF EXISTS user_storage_delete;
+DROP TRIGGER IF EXISTS user_storage_owner_change;
+
+-- Recount both from the live files
+UPDATE file_hashes SET reference_count = (
+    SELECT COUNT(*) FROM files
+    WHERE files.file_hash_id = file_hashes.id AND files.deleted_at IS NULL
+);
+
+-- A user's storage counts each distinct content they hold once
+UPDATE users SET storage_used = (
+    SELECT COALESCE(SUM(file_hashes.size), 0) FROM file_hashes
+    WHERE file_hashes.id IN (
+        SELECT files.file_hash_id FROM files
+        WHERE files.owner_id = users.id AND files.deleted_at IS NULL
+    )
+);
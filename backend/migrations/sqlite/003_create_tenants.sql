@@ -0,0 +1,99 @@
+-- Migration: Tenants hosting several organizations on one deployment
+-- Mirrors 052_create_tenants.sql. SQLite cannot change a CHECK constraint in
+-- place, so the users table is rebuilt with foreign key enforcement paused.
+
+CREATE TABLE IF NOT EXISTS tenants (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    default_user_quota BIGINT NOT NULL DEFAULT 0,
+    storage_quota BIGINT NOT NULL DEFAULT 0,
+    allow_registration BOOLEAN NOT NULL DEFAULT TRUE,
+    logo_url TEXT,
+    primary_color VARCHAR(7),
+    support_email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE users_new (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    username VARCHAR(100) UNIQUE NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    first_name VARCHAR(100),
+    last_name VARCHAR(100),
+    role VARCHAR(20) NOT NULL DEFAULT 'user' CONSTRAINT check_user_role CHECK (role IN ('user', 'admin', 'guest', 'tenant_admin')),
+    tenant_id TEXT REFERENCES tenants(id) ON DELETE RESTRICT,
+    storage_quota BIGINT DEFAULT 10485760,
+    storage_used BIGINT DEFAULT 0,
+    plan_id TEXT REFERENCES plans(id) ON DELETE SET NULL,
+    quota_grace_started_at TIMESTAMP,
+    quota_grace_expires_at TIMESTAMP,
+    quota_grace_revoked_at TIMESTAMP,
+    total_uploaded_bytes BIGINT DEFAULT 0,
+    actual_storage_bytes BIGINT DEFAULT 0,
+    saved_bytes BIGINT DEFAULT 0,
+    auto_tagging_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    is_active BOOLEAN DEFAULT TRUE,
+    email_verified BOOLEAN DEFAULT FALSE,
+    last_login TIMESTAMP,
+    lifecycle_state VARCHAR(20) NOT NULL DEFAULT 'active',
+    lifecycle_changed_at TIMESTAMP,
+    guest_expires_at TIMESTAMP,
+    invited_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    sso_subject VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    CONSTRAINT check_user_tenant_role CHECK (
+        (role <> 'admin' OR tenant_id IS NULL) AND (role <> 'tenant_admin' OR tenant_id IS NOT NULL)
+    )
+);
+
+INSERT INTO users_new (
+    id, username, email, password_hash, first_name, last_name, role,
+    storage_quota, storage_used, plan_id,
+    quota_grace_started_at, quota_grace_expires_at, quota_grace_revoked_at,
+    total_uploaded_bytes, actual_storage_bytes, saved_bytes, auto_tagging_enabled,
+    is_active, email_verified, last_login, lifecycle_state, lifecycle_changed_at,
+    guest_expires_at, invited_by, sso_subject, created_at, updated_at, deleted_at
+)
+SELECT
+    id, username, email, password_hash, first_name, last_name, role,
+    storage_quota, storage_used, plan_id,
+    quota_grace_started_at, quota_grace_expires_at, quota_grace_revoked_at,
+    total_uploaded_bytes, actual_storage_bytes, saved_bytes, auto_tagging_enabled,
+    is_active, email_verified, last_login, lifecycle_state, lifecycle_changed_at,
+    guest_expires_at, invited_by, sso_subject, created_at, updated_at, deleted_at
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
+CREATE INDEX IF NOT EXISTS idx_users_last_login ON users(last_login);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
+CREATE INDEX IF NOT EXISTS idx_users_plan_id ON users(plan_id);
+CREATE INDEX IF NOT EXISTS idx_users_quota_grace_expires ON users(quota_grace_expires_at) WHERE quota_grace_expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_guest_expiry ON users(guest_expires_at) WHERE role = 'guest' AND is_active = TRUE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_sso_subject ON users(sso_subject) WHERE sso_subject IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_lifecycle_state ON users(lifecycle_state);
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users(tenant_id);
+
+-- Keep updated_at current for updates that do not set it
+CREATE TRIGGER IF NOT EXISTS update_users_updated_at AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,5 @@
+-- Migration: Custom domains serving a tenant's share links
+-- Mirrors 053_add_tenant_share_domain.sql.
+
+ALTER TABLE tenants ADD COLUMN share_domain VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_share_domain ON tenants(share_domain) WHERE share_domain IS NOT NULL;
@@ -0,0 +1,23 @@
+-- Migration: Slack and Microsoft Teams integrations
+-- Mirrors 055_create_integrations.sql.
+
+CREATE TABLE IF NOT EXISTS integrations (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id TEXT REFERENCES tenants(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    webhook_url TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_delivered_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_integration_kind CHECK (kind IN ('slack', 'teams')),
+    CONSTRAINT check_integration_owner CHECK (user_id IS NULL OR tenant_id IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_integrations_user_id ON integrations(user_id);
+CREATE INDEX IF NOT EXISTS idx_integrations_tenant_id ON integrations(tenant_id);
@@ -0,0 +1,13 @@
+-- Migration: Daily counts of anonymous access
+-- Mirrors 057_create_access_counts.sql.
+
+CREATE TABLE IF NOT EXISTS access_counts (
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id TEXT NOT NULL,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL DEFAULT 0,
+    downloads BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (resource_type, resource_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_counts_day ON access_counts(day);
@@ -0,0 +1,10 @@
+-- Migration: Rotating JWT signing keys
+-- Mirrors 058_create_jwt_signing_keys.sql.
+
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    id VARCHAR(32) PRIMARY KEY,
+    salt VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
@@ -0,0 +1,29 @@
+-- Migration: End-to-end encrypted (vault) folders
+-- Mirrors 059_add_encrypted_folders.sql.
+
+ALTER TABLE folders ADD COLUMN vault_id TEXT;
+
+ALTER TABLE files ADD COLUMN is_encrypted BOOLEAN DEFAULT FALSE;
+ALTER TABLE files ADD COLUMN encryption_header TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_folders_vault_id ON folders(vault_id) WHERE vault_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS user_public_keys (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    algorithm VARCHAR(50) NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS vault_key_grants (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    vault_id TEXT NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    recipient_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    wrapped_key TEXT NOT NULL,
+    granted_by TEXT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vault_key_grants_recipient ON vault_key_grants(vault_id, recipient_id) WHERE recipient_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vault_key_grants_escrow ON vault_key_grants(vault_id) WHERE recipient_id IS NULL;
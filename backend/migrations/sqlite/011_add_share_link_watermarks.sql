@@ -0,0 +1,5 @@
+-- Migration: Watermarked share links
+-- Mirrors 060_add_share_link_watermarks.sql.
+
+ALTER TABLE share_links ADD COLUMN watermark BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE share_links ADD COLUMN watermark_text VARCHAR(100) NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- Migration: File processing status
+-- Mirrors 061_add_file_processing_status.sql.
+
+ALTER TABLE files ADD COLUMN processing_status VARCHAR(20) NOT NULL DEFAULT 'clean';
+
+UPDATE files SET processing_status = 'quarantined' WHERE is_quarantined = TRUE;
+
+UPDATE files SET processing_status = 'pending_scan'
+WHERE is_quarantined = FALSE
+  AND is_encrypted = FALSE
+  AND EXISTS (
+      SELECT 1 FROM file_hashes fh
+      WHERE fh.id = files.file_hash_id AND fh.malware_scanned_at IS NULL
+  );
@@ -0,0 +1,23 @@
+-- Migration: Resumable chunked uploads
+-- Mirrors 062_create_upload_sessions.sql.
+
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    chunk_size BIGINT NOT NULL,
+    chunk_count INTEGER NOT NULL,
+    received_chunks BLOB NOT NULL,
+    received_count INTEGER NOT NULL DEFAULT 0,
+    is_public BOOLEAN DEFAULT FALSE,
+    encryption_header TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_owner_id ON upload_sessions(owner_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
@@ -0,0 +1,5 @@
+-- Migration: Image dimensions
+-- Mirrors 063_add_file_hash_dimensions.sql.
+
+ALTER TABLE file_hashes ADD COLUMN width INTEGER;
+ALTER TABLE file_hashes ADD COLUMN height INTEGER;
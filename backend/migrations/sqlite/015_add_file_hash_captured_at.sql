@@ -0,0 +1,6 @@
+-- Migration: Photo capture time
+-- Mirrors 064_add_file_hash_captured_at.sql.
+
+ALTER TABLE file_hashes ADD COLUMN captured_at TIMESTAMP;
+
+UPDATE file_hashes SET width = NULL, height = NULL WHERE width IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Migration: Album share links
+-- Mirrors 065_add_folder_share_link_mode.sql.
+
+ALTER TABLE folder_share_links ADD COLUMN mode VARCHAR(20) NOT NULL DEFAULT 'folder';
@@ -0,0 +1,21 @@
+-- Migration: tus resumable uploads
+-- Mirrors 066_create_tus_uploads.sql.
+
+CREATE TABLE IF NOT EXISTS tus_uploads (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    metadata TEXT,
+    is_public BOOLEAN DEFAULT FALSE,
+    encryption_header TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tus_uploads_owner_id ON tus_uploads(owner_id);
+CREATE INDEX IF NOT EXISTS idx_tus_uploads_expires_at ON tus_uploads(expires_at);
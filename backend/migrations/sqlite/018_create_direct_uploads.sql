@@ -0,0 +1,20 @@
+-- Migration: direct uploads to object storage
+-- Mirrors 067_create_direct_uploads.sql.
+
+CREATE TABLE IF NOT EXISTS direct_uploads (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    owner_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    object_key VARCHAR(255) NOT NULL,
+    is_public BOOLEAN DEFAULT FALSE,
+    encryption_header TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_direct_uploads_owner_id ON direct_uploads(owner_id);
+CREATE INDEX IF NOT EXISTS idx_direct_uploads_expires_at ON direct_uploads(expires_at);
@@ -0,0 +1,16 @@
+-- Migration: Default upload folder and upload rules
+-- Mirrors 068_create_upload_rules.sql.
+
+ALTER TABLE users ADD COLUMN default_upload_folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS upload_rules (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    mime_type VARCHAR(100),
+    extension VARCHAR(20),
+    folder_id TEXT NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_rules_user_id ON upload_rules(user_id, position);
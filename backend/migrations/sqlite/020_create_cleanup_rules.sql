@@ -0,0 +1,21 @@
+-- Migration: Cleanup rules
+-- Mirrors 069_create_cleanup_rules.sql.
+
+CREATE TABLE IF NOT EXISTS cleanup_rules (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    folder_id TEXT REFERENCES folders(id) ON DELETE CASCADE,
+    include_subfolders BOOLEAN NOT NULL DEFAULT FALSE,
+    name_pattern VARCHAR(255),
+    mime_type VARCHAR(100),
+    tag VARCHAR(50),
+    older_than_days INTEGER NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_run_at TIMESTAMP,
+    last_trashed INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_cleanup_rules_user_id ON cleanup_rules(user_id);
@@ -0,0 +1,24 @@
+-- Migration: URL imports
+-- Mirrors 070_create_url_imports.sql.
+
+CREATE TABLE IF NOT EXISTS url_imports (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    filename VARCHAR(255),
+    folder_id TEXT REFERENCES folders(id) ON DELETE SET NULL,
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    bytes_received BIGINT NOT NULL DEFAULT 0,
+    total_bytes BIGINT,
+    file_id TEXT REFERENCES files(id) ON DELETE SET NULL,
+    error_code VARCHAR(50),
+    error TEXT,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_imports_user_id ON url_imports(user_id);
+CREATE INDEX IF NOT EXISTS idx_url_imports_status ON url_imports(status);
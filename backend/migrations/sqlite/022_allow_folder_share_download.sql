@@ -0,0 +1,47 @@
+-- Migration: Download permission on folder shares
+-- Mirrors 071_allow_folder_share_download.sql. SQLite cannot change a CHECK
+-- constraint in place, so the folder_shares table is rebuilt with foreign key
+-- enforcement paused.
+
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE folder_shares_new (
+    id TEXT PRIMARY KEY DEFAULT (gen_random_uuid()),
+    folder_id TEXT NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    shared_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_with TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(20) NOT NULL DEFAULT 'view' CHECK (permission IN ('view', 'download')),
+    message TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    responded_at TIMESTAMP,
+    hidden_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+INSERT INTO folder_shares_new (
+    id, folder_id, shared_by, shared_with, permission, message,
+    status, responded_at, hidden_at, created_at, updated_at, deleted_at
+)
+SELECT
+    id, folder_id, shared_by, shared_with,
+    CASE WHEN permission IN ('view', 'download') THEN permission ELSE 'view' END,
+    message, status, responded_at, hidden_at, created_at, updated_at, deleted_at
+FROM folder_shares;
+
+DROP TABLE folder_shares;
+ALTER TABLE folder_shares_new RENAME TO folder_shares;
+
+CREATE INDEX IF NOT EXISTS idx_folder_shares_folder_id ON folder_shares(folder_id);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_shared_by ON folder_shares(shared_by);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_shared_with ON folder_shares(shared_with);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_deleted_at ON folder_shares(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_folder_shares_recipient_status ON folder_shares(shared_with, status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_unique_folder_share ON folder_shares(folder_id, shared_by, shared_with) WHERE deleted_at IS NULL;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,4 @@
+-- Migration: Remove the content of blobs that no file references
+-- Mirrors 072_add_blob_content_removed_at.sql.
+
+ALTER TABLE file_hashes ADD COLUMN content_removed_at TIMESTAMP;
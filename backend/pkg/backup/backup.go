@@ -0,0 +1,224 @@
+// Package backup snapshots the database and blob store to a secondary
+// location and restores them again.
+//
+// A backup location holds a shared, content-addressed blob directory plus one
+// directory per backup containing the database dump and a manifest:
+//
+//	<location>/blobs/<sha256>
+//	<location>/<backup name>/database.dump
+//	<location>/<backup name>/manifest.json
+//
+// Because blobs are named by hash, incremental backups only copy hashes that
+// are not already present in the blob directory.
+package backup
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"file-vault-system/backend/internal/config"
+	"file-vault-system/backend/pkg/utils"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+const (
+	// ManifestFile is the name of the manifest inside a backup directory
+	ManifestFile = "manifest.json"
+	// DatabaseDumpFile is the name of the pg_dump archive, or the SQLite
+	// database copy, inside a backup directory
+	DatabaseDumpFile = "database.dump"
+	// BlobDir is the shared blob directory inside a backup location
+	BlobDir = "blobs"
+
+	manifestVersion = 1
+)
+
+// Manifest describes the contents of a single backup
+type Manifest struct {
+	Version        int            `json:"version"`
+	ID             string         `json:"id"`
+	Mode           string         `json:"mode"`
+	CreatedAt      time.Time      `json:"created_at"`
+	CompletedAt    time.Time      `json:"completed_at"`
+	DatabaseDump   string         `json:"database_dump"`
+	DatabaseSHA256 string         `json:"database_sha256"`
+	Blobs          []ManifestBlob `json:"blobs"`
+	BlobsCopied    int            `json:"blobs_copied"`
+	BytesCopied    int64          `json:"bytes_copied"`
+	MissingBlobs   []string       `json:"missing_blobs,omitempty"`
+}
+
+// ManifestBlob is a blob referenced by the database at snapshot time
+type ManifestBlob struct {
+	Hash        string `json:"hash"`
+	Size        int64  `json:"size"`
+	StoragePath string `json:"storage_path"`
+}
+
+// WriteManifest saves a manifest into a backup directory
+func WriteManifest(backupDir string, manifest *Manifest) error {
+	manifest.Version = manifestVersion
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(backupDir, ManifestFile), data, 0644)
+}
+
+// ReadManifest loads the manifest from a backup directory
+func ReadManifest(backupDir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(backupDir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	return &manifest, nil
+}
+
+// DumpDatabase writes a pg_dump custom-format archive to destPath, or a copy
+// of the database file for SQLite
+func DumpDatabase(cfg *config.Config, destPath string) error {
+	if cfg.IsSQLite() {
+		return dumpSQLite(cfg, destPath)
+	}
+
+	args := append([]string{"--format=custom", "--no-owner", "--file=" + destPath}, connectionArgs(cfg)...)
+	return runPostgresTool(cfg, cfg.PgDumpPath, args)
+}
+
+// RestoreDatabase replaces the database contents with a pg_dump archive, or
+// the database file with its copy for SQLite
+func RestoreDatabase(cfg *config.Config, dumpPath string) error {
+	if cfg.IsSQLite() {
+		return restoreSQLite(cfg, dumpPath)
+	}
+
+	args := append([]string{"--clean", "--if-exists", "--no-owner", "--single-transaction"}, connectionArgs(cfg)...)
+	args = append(args, dumpPath)
+	return runPostgresTool(cfg, cfg.PgRestorePath, args)
+}
+
+// connectionArgs builds libpq connection flags from the database configuration
+func connectionArgs(cfg *config.Config) []string {
+	if cfg.DatabaseURL != "" {
+		return []string{"--dbname=" + cfg.DatabaseURL}
+	}
+
+	return []string{
+		"--host=" + cfg.DatabaseHost,
+		"--port=" + cfg.DatabasePort,
+		"--username=" + cfg.DatabaseUser,
+		"--dbname=" + cfg.DatabaseName,
+	}
+}
+
+func runPostgresTool(cfg *config.Config, tool string, args []string) error {
+	cmd := exec.Command(tool, args...)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+cfg.DatabasePassword, "PGSSLMODE="+cfg.DatabaseSSLMode)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", filepath.Base(tool), err, output)
+	}
+
+	return nil
+}
+
+// dumpSQLite writes a consistent copy of the SQLite database, taken while
+// the server keeps running
+func dumpSQLite(cfg *config.Config, destPath string) error {
+	// VACUUM INTO refuses to overwrite a file
+	if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old database copy: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", cfg.SQLitePath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("VACUUM INTO ?", destPath); err != nil {
+		return fmt.Errorf("failed to copy database: %w", err)
+	}
+	return nil
+}
+
+// restoreSQLite replaces the SQLite database file with a copy. The server
+// must be stopped; its write-ahead log belongs to the replaced file and is
+// removed.
+func restoreSQLite(cfg *config.Config, dumpPath string) error {
+	hash, err := FileSHA256(dumpPath)
+	if err != nil {
+		return fmt.Errorf("failed to read database copy: %w", err)
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(cfg.SQLitePath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", cfg.SQLitePath+suffix, err)
+		}
+	}
+
+	if _, err := utils.CopyFileVerified(dumpPath, cfg.SQLitePath, hash); err != nil {
+		return fmt.Errorf("failed to restore database file: %w", err)
+	}
+	return nil
+}
+
+// SyncBlob copies a blob into the backup blob directory unless it is already
+// there and overwrite is false. It reports whether the blob was copied.
+func SyncBlob(srcPath, location, hash string, overwrite bool) (bool, int64, error) {
+	destPath := filepath.Join(location, BlobDir, hash)
+	if !overwrite {
+		if _, err := os.Stat(destPath); err == nil {
+			return false, 0, nil
+		}
+	}
+
+	size, err := utils.CopyFileVerified(srcPath, destPath, hash)
+	if err != nil {
+		return false, 0, err
+	}
+	return true, size, nil
+}
+
+// RestoreBlob copies a blob from the backup blob directory into storage
+func RestoreBlob(location string, blob ManifestBlob, storagePath string) (int64, error) {
+	srcPath := filepath.Join(location, BlobDir, blob.Hash)
+	return utils.CopyFileVerified(srcPath, filepath.Join(storagePath, blob.StoragePath), blob.Hash)
+}
+
+// FileSHA256 returns the hex SHA-256 of a file
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,67 @@
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Origin pushes content to a CDN origin so edge caches can fill from it.
+//
+// Each blob is uploaded with PUT to {url}/{key}, carrying its content type,
+// and the origin must answer with a 2xx status. Keys are content hashes, so
+// pushing the same blob twice is harmless.
+type Origin struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewOrigin creates a pusher for the origin at url
+func NewOrigin(url, token string, timeout time.Duration) *Origin {
+	return &Origin{
+		url:    strings.TrimRight(url, "/"),
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Push uploads the file at path to the origin under key
+func (o *Origin) Push(ctx context.Context, key, path, contentType string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, o.url+"/"+key, file)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", contentType)
+	if o.token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.token)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package classify assigns category tags to files from their MIME type,
+// filename and extracted text.
+//
+// Rules are evaluated in order. A rule applies when the MIME type is one it
+// accepts and either the type alone is enough, the filename matches its
+// pattern, or the text contains enough of its keywords.
+package classify
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Rule describes one tag and the evidence that earns it
+type Rule struct {
+	Tag string
+	// MimePrefixes limits the rule to these types; empty accepts any type
+	MimePrefixes []string
+	// MimeSufficient are types that earn the tag without further evidence
+	MimeSufficient []string
+	// NamePattern is matched against the filename without its extension
+	NamePattern *regexp.Regexp
+	// Keywords are counted in the lower-cased text, each at most once
+	Keywords    []string
+	MinKeywords int
+	// Excludes skips the rule when any of these tags was already assigned
+	Excludes []string
+}
+
+// BuiltinRules are the rules used by Classify
+var BuiltinRules = []Rule{
+	{
+		Tag:          "screenshot",
+		MimePrefixes: []string{"image/"},
+		NamePattern:  regexp.MustCompile(`(?i)(screen[ _-]?shot|screen[ _-]?capture|^scr[_-]?\d|^snip)`),
+	},
+	{
+		Tag:            "photo",
+		MimePrefixes:   []string{"image/"},
+		MimeSufficient: []string{"image/jpeg", "image/jpg", "image/heic", "image/heif"},
+		NamePattern:    regexp.MustCompile(`(?i)^(img|dsc|dscn|dcim|pxl|photo)[_-]?\d`),
+		Excludes:       []string{"screenshot"},
+	},
+	{
+		Tag:          "invoice",
+		MimePrefixes: []string{"application/pdf", "text/", "image/", "application/msword", "application/vnd.openxmlformats-officedocument"},
+		NamePattern:  regexp.MustCompile(`(?i)(invoice|inv[_-]?\d|bill[_-]?\d)`),
+		Keywords:     []string{"invoice", "bill to", "amount due", "total due", "due date", "payment terms", "subtotal", "invoice number", "tax invoice", "remit to"},
+		MinKeywords:  3,
+	},
+	{
+		Tag:          "contract",
+		MimePrefixes: []string{"application/pdf", "text/", "image/", "application/msword", "application/vnd.openxmlformats-officedocument"},
+		NamePattern:  regexp.MustCompile(`(?i)(contract|agreement|(^|[^a-z])nda([^a-z]|$)|terms[ _-]of[ _-]service|lease)`),
+		Keywords:     []string{"agreement", "contract", "hereinafter", "hereby", "party", "parties", "governing law", "in witness whereof", "termination", "indemnif", "signature"},
+		MinKeywords:  4,
+	},
+}
+
+// Classify returns the tags of every rule the file satisfies
+func Classify(filename, mimeType, text string) []string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	text = strings.ToLower(text)
+
+	var tags []string
+	for _, rule := range BuiltinRules {
+		if rule.matches(name, mimeType, text, tags) {
+			tags = append(tags, rule.Tag)
+		}
+	}
+	return tags
+}
+
+func (r Rule) matches(name, mimeType, text string, assigned []string) bool {
+	for _, excluded := range r.Excludes {
+		for _, tag := range assigned {
+			if tag == excluded {
+				return false
+			}
+		}
+	}
+
+	if len(r.MimePrefixes) > 0 && !hasAnyPrefix(mimeType, r.MimePrefixes) {
+		return false
+	}
+	for _, sufficient := range r.MimeSufficient {
+		if mimeType == sufficient {
+			return true
+		}
+	}
+	if r.NamePattern != nil && r.NamePattern.MatchString(name) {
+		return true
+	}
+	if len(r.Keywords) > 0 && text != "" {
+		found := 0
+		for _, keyword := range r.Keywords {
+			if strings.Contains(text, keyword) {
+				found++
+			}
+		}
+		return found >= r.MinKeywords
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,10 +5,14 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"file-vault-system/backend/internal/config"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -22,27 +26,60 @@ func Initialize(cfg *config.Config) (*gorm.DB, error) {
 		logLevel = logger.Info
 	}
 
-	// Connect to database
-	db, err := gorm.Open(postgres.Open(cfg.GetDatabaseDSN()), &gorm.Config{
+	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logLevel),
-	})
+	}
+
+	// Connect to database
+	var db *gorm.DB
+	var err error
+	if cfg.IsSQLite() {
+		db, err = openSQLite(cfg, gormConfig)
+	} else {
+		db, err = openPostgres(cfg, gormConfig)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	if err := registerTimeoutTracking(db); err != nil {
+		return nil, fmt.Errorf("failed to register query timeout tracking: %w", err)
+	}
+
 	// Configure connection pool
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database instance: %w", err)
 	}
 
-	// Connection pool settings
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
+	// Connection pool settings. Capping open connections makes requests queue
+	// for a connection under load instead of exhausting the server's
+	// max_connections; recycling them lets a failover or pgbouncer restart
+	// drain old connections.
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DBConnMaxLifetime) * time.Minute)
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.DBConnMaxIdleTime) * time.Minute)
+	publishPoolStats(sqlDB)
 
 	return db, nil
 }
 
+// openPostgres connects to PostgreSQL
+func openPostgres(cfg *config.Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	// Postgres cancels any statement running longer than the configured
+	// timeout, on every pooled connection
+	connConfig, err := pgx.ParseConfig(cfg.GetDatabaseDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database configuration: %w", err)
+	}
+	if cfg.DBStatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.Itoa(cfg.DBStatementTimeout * 1000)
+	}
+
+	return gorm.Open(postgres.New(postgres.Config{Conn: stdlib.OpenDB(*connConfig)}), gormConfig)
+}
+
 // RunMigrations executes SQL migration files in order
 func RunMigrations(db *gorm.DB, cfg *config.Config) error {
 	// Create migrations tracking table first
@@ -50,8 +87,12 @@ func RunMigrations(db *gorm.DB, cfg *config.Config) error {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
-	// Get the migrations directory path
+	// Get the migrations directory path. SQLite has its own schema, since
+	// the PostgreSQL migrations use features it lacks.
 	migrationsDir := filepath.Join("./migrations")
+	if IsSQLite(db) {
+		migrationsDir = filepath.Join(migrationsDir, "sqlite")
+	}
 
 	// Read migration files
 	files, err := ioutil.ReadDir(migrationsDir)
@@ -88,8 +129,18 @@ func RunMigrations(db *gorm.DB, cfg *config.Config) error {
 			return fmt.Errorf("failed to read migration file %s: %w", filename, err)
 		}
 
-		// Execute the migration
-		if err := db.Exec(string(content)).Error; err != nil {
+		// Execute the migration on one connection with the statement timeout
+		// lifted, since rewriting large tables can take a while
+		if err := db.Connection(func(conn *gorm.DB) error {
+			if IsSQLite(conn) {
+				return conn.Exec(string(content)).Error
+			}
+			if err := conn.Exec("SET statement_timeout = 0").Error; err != nil {
+				return err
+			}
+			defer conn.Exec("RESET statement_timeout")
+			return conn.Exec(string(content)).Error
+		}); err != nil {
 			return fmt.Errorf("failed to execute migration %s: %w", filename, err)
 		}
 
@@ -106,6 +157,16 @@ func RunMigrations(db *gorm.DB, cfg *config.Config) error {
 
 // CreateMigrationTable creates a migrations tracking table
 func CreateMigrationTable(db *gorm.DB) error {
+	if IsSQLite(db) {
+		return db.Exec(`
+		CREATE TABLE IF NOT EXISTS migrations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			filename VARCHAR(255) UNIQUE NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`).Error
+	}
+
 	return db.Exec(`
 		CREATE TABLE IF NOT EXISTS migrations (
 			id SERIAL PRIMARY KEY,
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// IsSQLite reports whether db is a SQLite database. Queries that use
+// PostgreSQL features check this to pick an equivalent.
+func IsSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == "sqlite"
+}
+
+// ILike returns the case-insensitive LIKE operator. SQLite's LIKE already
+// ignores case for ASCII letters.
+func ILike(db *gorm.DB) string {
+	if IsSQLite(db) {
+		return "LIKE"
+	}
+	return "ILIKE"
+}
+
+// PrefixBefore returns SQL for the part of a text column before the first
+// occurrence of sep, which must be a single-quoted SQL literal
+func PrefixBefore(db *gorm.DB, column, sep string) string {
+	if IsSQLite(db) {
+		return fmt.Sprintf("SUBSTR(%s, 1, INSTR(%s, %s) - 1)", column, column, sep)
+	}
+	return fmt.Sprintf("SUBSTRING(%s FROM 1 FOR POSITION(%s IN %s) - 1)", column, sep, column)
+}
+
+// Time returns SQL for a timestamp expression in a form that compares
+// correctly with the result of TimePlusNanos. SQLite stores times as text,
+// so they are compared as Julian day numbers there.
+func Time(db *gorm.DB, expr string) string {
+	if IsSQLite(db) {
+		return fmt.Sprintf("julianday(%s)", expr)
+	}
+	return expr
+}
+
+// TimePlusNanos returns SQL for a timestamp expression plus a number of
+// nanoseconds, comparable with the result of Time
+func TimePlusNanos(db *gorm.DB, expr, nanos string) string {
+	if IsSQLite(db) {
+		return fmt.Sprintf("(julianday(%s) + %s / 86400000000000.0)", expr, nanos)
+	}
+	return fmt.Sprintf("(%s + %s / 1000 * INTERVAL '1 microsecond')", expr, nanos)
+}
+
+// TimePlusDays returns SQL for a timestamp expression plus a number of days,
+// as a timestamp that can be stored in a column. Wrap it in Time to compare
+// it with another time.
+func TimePlusDays(db *gorm.DB, expr, days string) string {
+	if IsSQLite(db) {
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:%%f', %s, '+' || %s || ' days')", expr, days)
+	}
+	return fmt.Sprintf("(%s + make_interval(days => %s))", expr, days)
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"database/sql"
+	"expvar"
+	"sync"
+)
+
+var (
+	poolStatsOnce sync.Once
+	poolStatsMu   sync.RWMutex
+	poolStatsDB   *sql.DB
+)
+
+// PoolStats is a snapshot of the connection pool
+type PoolStats struct {
+	MaxOpen           int   `json:"max_open"`
+	Open              int   `json:"open"`
+	InUse             int   `json:"in_use"`
+	Idle              int   `json:"idle"`
+	WaitCount         int64 `json:"wait_count"`           // requests that waited for a connection
+	WaitDurationMs    int64 `json:"wait_duration_ms"`     // total time spent waiting for connections
+	MaxIdleClosed     int64 `json:"max_idle_closed"`      // connections closed because the idle pool was full
+	MaxIdleTimeClosed int64 `json:"max_idle_time_closed"` // connections closed after sitting idle too long
+	MaxLifetimeClosed int64 `json:"max_lifetime_closed"`  // connections closed after reaching their lifetime
+}
+
+// NewPoolStats converts database/sql pool statistics
+func NewPoolStats(stats sql.DBStats) PoolStats {
+	return PoolStats{
+		MaxOpen:           stats.MaxOpenConnections,
+		Open:              stats.OpenConnections,
+		InUse:             stats.InUse,
+		Idle:              stats.Idle,
+		WaitCount:         stats.WaitCount,
+		WaitDurationMs:    stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:     stats.MaxIdleClosed,
+		MaxIdleTimeClosed: stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed: stats.MaxLifetimeClosed,
+	}
+}
+
+// publishPoolStats reports the pool under db_pool in /debug/vars
+func publishPoolStats(db *sql.DB) {
+	poolStatsMu.Lock()
+	poolStatsDB = db
+	poolStatsMu.Unlock()
+
+	poolStatsOnce.Do(func() {
+		expvar.Publish("db_pool", expvar.Func(func() interface{} {
+			poolStatsMu.RLock()
+			defer poolStatsMu.RUnlock()
+			if poolStatsDB == nil {
+				return nil
+			}
+			return NewPoolStats(poolStatsDB.Stats())
+		}))
+	})
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"file-vault-system/backend/internal/config"
+
+	"github.com/glebarez/go-sqlite"
+	gormsqlite "github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gorm.io/gorm"
+)
+
+// sqliteBusyTimeout is how long a connection waits for another one's write
+// transaction to finish before giving up
+const sqliteBusyTimeout = 5 * time.Second
+
+var registerSQLiteFunctions sync.Once
+
+// openSQLite opens the SQLite database file, creating it if needed.
+//
+// Write-ahead logging lets readers run alongside the single writer, and
+// transactions take the write lock when they begin, so two transactions never
+// both read and then fail to upgrade to writing. SQLite compares times as
+// text, so GORM's timestamps are set in UTC like CURRENT_TIMESTAMP.
+func openSQLite(cfg *config.Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	registerSQLiteFunctions.Do(func() {
+		// Column defaults generate IDs the same way PostgreSQL does
+		sqlite.MustRegisterScalarFunction("gen_random_uuid", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+			return uuid.NewString(), nil
+		})
+		// Text arrays are stored in their PostgreSQL text form
+		sqlite.MustRegisterDeterministicScalarFunction("array_contains", 2, arrayContains)
+	})
+
+	if dir := filepath.Dir(cfg.SQLitePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeout.Milliseconds()))
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+
+	gormConfig.NowFunc = func() time.Time {
+		return time.Now().UTC()
+	}
+	return gorm.Open(gormsqlite.Open(cfg.SQLitePath+"?"+params.Encode()), gormConfig)
+}
+
+// arrayContains reports whether an array in PostgreSQL text form, such as
+// {"a","b"}, holds a value
+func arrayContains(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	var elements []*string
+	if err := pgtype.NewMap().SQLScanner(&elements).Scan(args[0]); err != nil {
+		return nil, err
+	}
+	want := fmt.Sprint(args[1])
+	for _, element := range elements {
+		if element != nil && *element == want {
+			return int64(1), nil
+		}
+	}
+	return int64(0), nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// queryCanceledCode is the Postgres error raised when statement_timeout
+// cancels a statement
+const queryCanceledCode = "57014"
+
+// IsTimeout reports whether a query failed because it ran out of time,
+// either on the server's statement timeout or on its context deadline
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode
+}
+
+// TimeoutTracker records whether any query run with its context timed out,
+// so a request can be answered as retryable even when the handler only
+// reported a generic failure
+type TimeoutTracker struct {
+	timedOut atomic.Bool
+}
+
+type timeoutTrackerKey struct{}
+
+// TrackTimeouts returns a context whose queries report timeouts to the
+// returned tracker
+func TrackTimeouts(ctx context.Context) (context.Context, *TimeoutTracker) {
+	tracker := &TimeoutTracker{}
+	return context.WithValue(ctx, timeoutTrackerKey{}, tracker), tracker
+}
+
+// TimedOut reports whether a tracked query timed out
+func (t *TimeoutTracker) TimedOut() bool {
+	return t.timedOut.Load()
+}
+
+// registerTimeoutTracking marks the context's tracker after every statement
+// that timed out
+func registerTimeoutTracking(db *gorm.DB) error {
+	mark := func(tx *gorm.DB) {
+		if tx.Statement == nil || tx.Statement.Context == nil || !IsTimeout(tx.Error) {
+			return
+		}
+		if tracker, ok := tx.Statement.Context.Value(timeoutTrackerKey{}).(*TimeoutTracker); ok {
+			tracker.timedOut.Store(true)
+		}
+	}
+
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Query().After("gorm:query").Register("timeouts:query", mark),
+		callbacks.Create().After("gorm:create").Register("timeouts:create", mark),
+		callbacks.Update().After("gorm:update").Register("timeouts:update", mark),
+		callbacks.Delete().After("gorm:delete").Register("timeouts:delete", mark),
+		callbacks.Row().After("gorm:row").Register("timeouts:row", mark),
+		callbacks.Raw().After("gorm:raw").Register("timeouts:raw", mark),
+	)
+}
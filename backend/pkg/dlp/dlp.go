@@ -0,0 +1,206 @@
+// Package dlp inspects file content for sensitive data such as social
+// security numbers and payment card numbers.
+//
+// Scanners are pluggable: the built-in PatternScanner matches regular
+// expressions locally, HTTPScanner forwards content to an external DLP
+// service, and MultiScanner runs several scanners and merges their findings.
+package dlp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Finding is a single category of sensitive data detected in a file
+type Finding struct {
+	Rule    string   `json:"rule"`
+	Label   string   `json:"label"`
+	Count   int      `json:"count"`
+	Samples []string `json:"samples,omitempty"` // masked so findings never repeat the sensitive value
+	Source  string   `json:"source"`
+}
+
+// Scanner inspects content and reports any sensitive data it finds
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, content []byte, mimeType string) ([]Finding, error)
+}
+
+// maxSamples caps how many masked matches are kept per finding
+const maxSamples = 3
+
+// Rule is a named regular expression with an optional validator used to
+// discard matches that only look like sensitive data
+type Rule struct {
+	Name     string
+	Label    string
+	Pattern  *regexp.Regexp
+	Validate func(match string) bool
+}
+
+// BuiltinRules are the pattern rules available by name
+var BuiltinRules = map[string]Rule{
+	"ssn": {
+		Name:    "ssn",
+		Label:   "US Social Security number",
+		Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		Validate: func(match string) bool {
+			// Area 000, 666 and 9xx, group 00 and serial 0000 are never issued
+			area, group, serial := match[0:3], match[4:6], match[7:11]
+			return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+		},
+	},
+	"credit_card": {
+		Name:     "credit_card",
+		Label:    "Payment card number",
+		Pattern:  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Validate: luhnValid,
+	},
+}
+
+// PatternScanner matches content against regular expression rules
+type PatternScanner struct {
+	rules []Rule
+}
+
+// NewPatternScanner creates a scanner for the named built-in rules
+func NewPatternScanner(ruleNames []string) (*PatternScanner, error) {
+	scanner := &PatternScanner{}
+	for _, name := range ruleNames {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		rule, ok := BuiltinRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown DLP rule %q", name)
+		}
+		scanner.rules = append(scanner.rules, rule)
+	}
+	return scanner, nil
+}
+
+// Name identifies the scanner in findings
+func (s *PatternScanner) Name() string {
+	return "pattern"
+}
+
+// Scan reports one finding per rule that matched at least once
+func (s *PatternScanner) Scan(ctx context.Context, content []byte, mimeType string) ([]Finding, error) {
+	var findings []Finding
+
+	for _, rule := range s.rules {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		finding := Finding{Rule: rule.Name, Label: rule.Label, Source: s.Name()}
+		for _, match := range rule.Pattern.FindAll(content, -1) {
+			value := string(match)
+			if rule.Validate != nil && !rule.Validate(value) {
+				continue
+			}
+			finding.Count++
+			if len(finding.Samples) < maxSamples {
+				finding.Samples = append(finding.Samples, Mask(value))
+			}
+		}
+
+		if finding.Count > 0 {
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings, nil
+}
+
+// MultiScanner runs each scanner in turn and merges their findings
+type MultiScanner []Scanner
+
+// Name identifies the scanner in findings
+func (m MultiScanner) Name() string {
+	return "multi"
+}
+
+// Scan stops at the first scanner error so callers can decide whether to fail
+// open or closed
+func (m MultiScanner) Scan(ctx context.Context, content []byte, mimeType string) ([]Finding, error) {
+	var findings []Finding
+	for _, scanner := range m {
+		result, err := scanner.Scan(ctx, content, mimeType)
+		if err != nil {
+			return findings, fmt.Errorf("%s scanner: %w", scanner.Name(), err)
+		}
+		findings = append(findings, result...)
+	}
+	return findings, nil
+}
+
+// IsScannable reports whether content of this MIME type is text that the
+// scanners can inspect directly
+func IsScannable(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+
+	switch mimeType {
+	case "application/json", "application/xml", "application/csv",
+		"application/x-yaml", "application/yaml", "application/sql",
+		"application/javascript", "application/x-sh":
+		return true
+	}
+	return false
+}
+
+// Mask hides all but the last four digits of a matched value
+func Mask(value string) string {
+	digits := 0
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+
+	var masked strings.Builder
+	seen := 0
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			seen++
+			if seen <= digits-4 {
+				masked.WriteRune('*')
+				continue
+			}
+		}
+		masked.WriteRune(r)
+	}
+	return masked.String()
+}
+
+// luhnValid checks the card number checksum, ignoring separators
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	digits := 0
+
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
@@ -0,0 +1,77 @@
+package dlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPScanner forwards content to an external DLP service.
+//
+// The service receives the raw content as the request body with the MIME type
+// in Content-Type, and must respond with JSON of the form
+// {"findings": [{"rule": "...", "label": "...", "count": 1}]}.
+type HTTPScanner struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPScanner creates a scanner for the external service at url
+func NewHTTPScanner(url, token string, timeout time.Duration) *HTTPScanner {
+	return &HTTPScanner{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name identifies the scanner in findings
+func (s *HTTPScanner) Name() string {
+	return "external"
+}
+
+// Scan posts the content to the external service
+func (s *HTTPScanner) Scan(ctx context.Context, content []byte, mimeType string) ([]Finding, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", mimeType)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Findings []Finding `json:"findings"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	for i := range result.Findings {
+		result.Findings[i].Source = s.Name()
+		if result.Findings[i].Count == 0 {
+			result.Findings[i].Count = 1
+		}
+		for j, sample := range result.Findings[i].Samples {
+			result.Findings[i].Samples[j] = Mask(sample)
+		}
+	}
+	return result.Findings, nil
+}
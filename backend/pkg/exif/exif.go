@@ -0,0 +1,159 @@
+// Package exif reads when a photo was taken from the EXIF metadata cameras
+// write into JPEG files. Only the few tags needed for that are understood;
+// everything else in the metadata is skipped.
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxSegmentsRead bounds how far into a file the metadata is looked for, since
+// it precedes the image data
+const maxSegmentsRead = 1 << 20 // 1MB
+
+// ErrNotFound is returned for content without a usable capture time
+var ErrNotFound = errors.New("no capture time in EXIF metadata")
+
+// EXIF tags holding times, and the pointer to the IFD holding the camera's
+const (
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+)
+
+// CaptureTime returns when a JPEG photo was taken: DateTimeOriginal, or
+// failing that DateTimeDigitized or DateTime. EXIF times are the camera's
+// wall-clock time without a zone, so they are returned as that wall-clock
+// time in UTC.
+func CaptureTime(r io.Reader) (time.Time, error) {
+	segment, err := exifSegment(bufio.NewReader(io.LimitReader(r, maxSegmentsRead)))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	tiff := segment[len("Exif\x00\x00"):]
+	if len(tiff) < 8 {
+		return time.Time{}, ErrNotFound
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, ErrNotFound
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	var exifIFD map[uint16][]byte
+	if pointer, ok := ifd0[tagExifIFD]; ok && len(pointer) == 4 {
+		exifIFD = readIFD(tiff, order, order.Uint32(pointer))
+	}
+
+	for _, value := range [][]byte{exifIFD[tagDateTimeOriginal], exifIFD[tagDateTimeDigitized], ifd0[tagDateTime]} {
+		if t, ok := parseTime(value); ok {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrNotFound
+}
+
+// exifSegment returns the APP1 segment holding EXIF metadata, which comes
+// before the image data of a JPEG
+func exifSegment(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, ErrNotFound
+	}
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0xFF {
+			return nil, ErrNotFound
+		}
+		marker := header[1]
+		// Start of scan or end of image: the metadata would have come before
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, ErrNotFound
+		}
+		length := int(binary.BigEndian.Uint16(header[2:])) - 2
+		if length < 0 {
+			return nil, ErrNotFound
+		}
+
+		if marker != 0xE1 {
+			if _, err := r.Discard(length); err != nil {
+				return nil, ErrNotFound
+			}
+			continue
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, ErrNotFound
+		}
+		// APP1 also carries XMP, which is skipped
+		if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment, nil
+		}
+	}
+}
+
+// readIFD returns the ASCII and LONG values of an IFD's entries by tag. Other
+// types and entries pointing outside the metadata are left out.
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	entries := make(map[uint16][]byte)
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return entries
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+
+	for i := 0; i < count; i++ {
+		entry := start + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[entry:])
+		kind := order.Uint16(tiff[entry+2:])
+		n := int(order.Uint32(tiff[entry+4:]))
+
+		switch kind {
+		case 2: // ASCII, inline when it fits in four bytes
+			if n <= 4 {
+				entries[tag] = tiff[entry+8 : entry+8+n]
+				continue
+			}
+			valueOffset := int(order.Uint32(tiff[entry+8:]))
+			if valueOffset >= 0 && n <= len(tiff) && valueOffset <= len(tiff)-n {
+				entries[tag] = tiff[valueOffset : valueOffset+n]
+			}
+		case 4: // LONG
+			if n == 1 {
+				entries[tag] = tiff[entry+8 : entry+12]
+			}
+		}
+	}
+	return entries
+}
+
+// parseTime parses an EXIF time such as "2021:07:14 18:03:27". Cameras with
+// an unset clock write zeros, which do not count.
+func parseTime(value []byte) (time.Time, bool) {
+	s := strings.TrimRight(string(value), "\x00 ")
+	if s == "" || strings.HasPrefix(s, "0000") {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
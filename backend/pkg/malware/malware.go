@@ -0,0 +1,128 @@
+// Package malware scans file content for viruses and other malicious code.
+//
+// Scanners are pluggable: ClamdScanner streams content to a ClamAV daemon,
+// and HTTPScanner forwards content to an external scanning service.
+package malware
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Result is the verdict for a scanned file
+type Result struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature,omitempty"` // name of the detected threat
+	Scanner   string `json:"scanner"`
+}
+
+// Scanner inspects a file on disk for malware
+type Scanner interface {
+	Name() string
+	ScanFile(ctx context.Context, path string) (*Result, error)
+}
+
+// clamdChunkSize is the size of each INSTREAM chunk. It must stay below
+// clamd's StreamMaxLength
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner streams files to a ClamAV daemon with the INSTREAM command
+type ClamdScanner struct {
+	network string
+	address string
+}
+
+// NewClamdScanner creates a scanner for a clamd address such as
+// tcp://localhost:3310 or unix:///var/run/clamav/clamd.ctl
+func NewClamdScanner(address string) (*ClamdScanner, error) {
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		return &ClamdScanner{network: "tcp", address: strings.TrimPrefix(address, "tcp://")}, nil
+	case strings.HasPrefix(address, "unix://"):
+		return &ClamdScanner{network: "unix", address: strings.TrimPrefix(address, "unix://")}, nil
+	default:
+		return nil, fmt.Errorf("clamd address must start with tcp:// or unix://: %q", address)
+	}
+}
+
+// Name identifies the scanner in results
+func (s *ClamdScanner) Name() string {
+	return "clamav"
+}
+
+// ScanFile streams the file to clamd and parses its verdict
+func (s *ClamdScanner) ScanFile(ctx context.Context, path string) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send command to clamd: %w", err)
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := file.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read file: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return s.parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseReply interprets replies such as "stream: OK" and
+// "stream: Eicar-Signature FOUND"
+func (s *ClamdScanner) parseReply(reply string) (*Result, error) {
+	reply = strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case reply == "OK":
+		return &Result{Scanner: s.Name()}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{
+			Infected:  true,
+			Signature: strings.TrimSuffix(reply, " FOUND"),
+			Scanner:   s.Name(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("clamd error: %s", reply)
+	}
+}
@@ -0,0 +1,72 @@
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HTTPEngine forwards content to an external OCR service.
+//
+// The service receives the raw content as the request body with the MIME type
+// in Content-Type, and must respond with JSON of the form {"text": "..."}.
+type HTTPEngine struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPEngine creates an engine for the external service at url
+func NewHTTPEngine(url, token string, timeout time.Duration) *HTTPEngine {
+	return &HTTPEngine{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name identifies the engine in index entries
+func (e *HTTPEngine) Name() string {
+	return "external"
+}
+
+// ExtractText posts the file to the external service
+func (e *HTTPEngine) ExtractText(ctx context.Context, path, mimeType string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", mimeType)
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	return result.Text, nil
+}
@@ -0,0 +1,143 @@
+// Package ocr extracts text from images and scanned PDFs.
+//
+// Engines are pluggable: TesseractEngine runs the tesseract command line tool
+// locally (rasterising PDFs with pdftoppm first), and HTTPEngine forwards
+// content to an external OCR service.
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Engine extracts the text visible in an image or PDF
+type Engine interface {
+	Name() string
+	ExtractText(ctx context.Context, path, mimeType string) (string, error)
+}
+
+// Supports reports whether a MIME type can be sent to an OCR engine
+func Supports(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch mimeType {
+	case "image/png", "image/jpeg", "image/jpg", "image/tiff", "image/bmp",
+		"image/gif", "image/webp", "application/pdf":
+		return true
+	}
+	return false
+}
+
+// TesseractEngine runs tesseract on images, and on PDF pages rendered by pdftoppm
+type TesseractEngine struct {
+	tesseractPath string
+	pdftoppmPath  string
+	languages     string
+	maxPDFPages   int
+}
+
+// NewTesseractEngine creates an engine using the given binaries. It fails when
+// tesseract cannot be found so callers can disable OCR instead of failing every file
+func NewTesseractEngine(tesseractPath, pdftoppmPath string, languages []string, maxPDFPages int) (*TesseractEngine, error) {
+	if _, err := exec.LookPath(tesseractPath); err != nil {
+		return nil, fmt.Errorf("tesseract not found: %w", err)
+	}
+
+	langs := make([]string, 0, len(languages))
+	for _, lang := range languages {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	if len(langs) == 0 {
+		langs = []string{"eng"}
+	}
+
+	return &TesseractEngine{
+		tesseractPath: tesseractPath,
+		pdftoppmPath:  pdftoppmPath,
+		languages:     strings.Join(langs, "+"),
+		maxPDFPages:   maxPDFPages,
+	}, nil
+}
+
+// Name identifies the engine in index entries
+func (e *TesseractEngine) Name() string {
+	return "tesseract"
+}
+
+// ExtractText recognises the text in an image, or in each page of a PDF
+func (e *TesseractEngine) ExtractText(ctx context.Context, path, mimeType string) (string, error) {
+	if strings.HasPrefix(strings.ToLower(mimeType), "application/pdf") {
+		return e.extractPDF(ctx, path)
+	}
+	return e.recognize(ctx, path)
+}
+
+func (e *TesseractEngine) recognize(ctx context.Context, path string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.tesseractPath, path, "stdout", "-l", e.languages)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// extractPDF renders the first pages of a PDF to images and recognises each one
+func (e *TesseractEngine) extractPDF(ctx context.Context, path string) (string, error) {
+	if _, err := exec.LookPath(e.pdftoppmPath); err != nil {
+		return "", fmt.Errorf("pdftoppm not found: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "ocr-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create page directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"-r", "300", "-png"}
+	if e.maxPDFPages > 0 {
+		args = append(args, "-l", strconv.Itoa(e.maxPDFPages))
+	}
+	args = append(args, path, filepath.Join(dir, "page"))
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.pdftoppmPath, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("pdftoppm failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	pages, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(pages, func(i, j int) bool { return pageNumber(pages[i]) < pageNumber(pages[j]) })
+
+	texts := make([]string, 0, len(pages))
+	for _, page := range pages {
+		text, err := e.recognize(ctx, page)
+		if err != nil {
+			return "", err
+		}
+		if text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// pageNumber parses the page number from pdftoppm output such as page-07.png
+func pageNumber(path string) int {
+	name := strings.TrimSuffix(filepath.Base(path), ".png")
+	n, _ := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	return n
+}
@@ -0,0 +1,202 @@
+// Package preview renders files as images for viewers who may look at them
+// but not obtain the original: PDF pages are rasterized with pdftoppm, and
+// images are decoded, scaled down and encoded again, which also drops their
+// metadata.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MaxImagePixels bounds the images Render decodes, since a small compressed
+// file can expand to gigabytes in memory
+const MaxImagePixels = 50_000_000
+
+var (
+	// ErrUnsupported is returned for content that cannot be rendered
+	ErrUnsupported = errors.New("content type cannot be rendered")
+	// ErrPageNotFound is returned for pages past the end of the content
+	ErrPageNotFound = errors.New("page not found")
+	// ErrTooLarge is returned for images with more pixels than MaxImagePixels
+	ErrTooLarge = errors.New("image is too large to render")
+)
+
+// Supports reports whether content of a MIME type can be rendered
+func Supports(mimeType string) bool {
+	return format(mimeType) != ""
+}
+
+// format maps a MIME type to the format Render handles it as
+func format(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch mimeType {
+	case "application/pdf":
+		return "pdf"
+	case "image/png":
+		return "png"
+	case "image/jpeg", "image/jpg":
+		return "jpeg"
+	case "image/gif":
+		return "gif"
+	}
+	return ""
+}
+
+// Renderer renders pages no larger than maxSize pixels on their longest side
+type Renderer struct {
+	pdftoppmPath string
+	maxSize      int
+}
+
+// NewRenderer creates a renderer using the given pdftoppm binary for PDFs
+func NewRenderer(pdftoppmPath string, maxSize int) *Renderer {
+	return &Renderer{pdftoppmPath: pdftoppmPath, maxSize: maxSize}
+}
+
+// Render returns a page of the content at path, counted from 1, and the MIME
+// type it is encoded as. Images have a single page; JPEG images stay JPEG and
+// the others become PNG, as do PDF pages.
+func (r *Renderer) Render(ctx context.Context, path, mimeType string, page int) ([]byte, string, error) {
+	if page < 1 {
+		return nil, "", ErrPageNotFound
+	}
+	switch f := format(mimeType); f {
+	case "pdf":
+		data, err := r.renderPDF(ctx, path, page)
+		return data, "image/png", err
+	case "":
+		return nil, "", ErrUnsupported
+	default:
+		if page != 1 {
+			return nil, "", ErrPageNotFound
+		}
+		return r.renderImage(path, f)
+	}
+}
+
+// renderPDF rasterizes one page of a PDF
+func (r *Renderer) renderPDF(ctx context.Context, path string, page int) ([]byte, error) {
+	if _, err := exec.LookPath(r.pdftoppmPath); err != nil {
+		return nil, fmt.Errorf("pdftoppm not found: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "preview-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create page directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := strconv.Itoa(page)
+	args := []string{"-f", n, "-l", n, "-singlefile", "-png", "-scale-to", strconv.Itoa(r.maxSize), path, filepath.Join(dir, "page")}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.pdftoppmPath, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// pdftoppm refuses a first page after the last one
+		if strings.Contains(stderr.String(), "Wrong page range") {
+			return nil, ErrPageNotFound
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("pdftoppm failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "page.png"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrPageNotFound
+	}
+	return data, err
+}
+
+// renderImage decodes an image, scales it down to fit and encodes it again
+func (r *Renderer) renderImage(path, f string) ([]byte, string, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, "", ErrTooLarge
+	}
+
+	var img image.Image
+	switch f {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(src))
+	case "gif":
+		img, err = gif.Decode(bytes.NewReader(src))
+	default:
+		img, err = png.Decode(bytes.NewReader(src))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	scaled := fit(img, r.maxSize)
+	var out bytes.Buffer
+	if f == "jpeg" {
+		err = jpeg.Encode(&out, scaled, &jpeg.Options{Quality: 85})
+		return out.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&out, scaled)
+	return out.Bytes(), "image/png", err
+}
+
+// fit returns the image scaled down so its longest side is at most maxSize,
+// averaging the source pixels each target pixel covers. Smaller images are
+// copied unchanged.
+func fit(img image.Image, maxSize int) *image.RGBA {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	width, height := bounds.Dx(), bounds.Dy()
+	longest := max(width, height)
+	if maxSize <= 0 || longest <= maxSize {
+		return src
+	}
+	dstWidth := max(1, width*maxSize/longest)
+	dstHeight := max(1, height*maxSize/longest)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0, y1 := y*height/dstHeight, max((y+1)*height/dstHeight, y*height/dstHeight+1)
+		for x := 0; x < dstWidth; x++ {
+			x0, x1 := x*width/dstWidth, max((x+1)*width/dstWidth, x*width/dstWidth+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for i := range sum {
+				dst.Pix[offset+i] = uint8(sum[i] / count)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"file-vault-system/backend/pkg/utils"
+)
+
+// Local stores objects as files under a root directory, each at its key
+type Local struct {
+	root string
+}
+
+// NewLocal creates a store rooted at dir
+func NewLocal(dir string) *Local {
+	return &Local{root: dir}
+}
+
+// Path returns where the object under key is kept on disk
+func (l *Local) Path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(key))
+}
+
+// Put writes the object to a temp file beside its destination and renames it
+// into place, so readers never see a partly written object
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	dest := l.Path(key)
+	if err := utils.EnsureDir(filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("wrote %d bytes, %d expected", n, size)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// PutFile moves a local file into place, renaming it when it is on the same
+// filesystem
+func (l *Local) PutFile(ctx context.Context, key, path string) error {
+	return utils.CommitStagedFile(path, l.Path(key))
+}
+
+func (l *Local) Get(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
+	f, err := os.Open(l.Path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(l.Path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (Info, error) {
+	info, err := os.Stat(l.Path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Info{}, ErrNotExist
+		}
+		return Info{}, err
+	}
+	return Info{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
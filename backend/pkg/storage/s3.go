@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// maxPresignTTL is the longest S3 honours a presigned URL for
+const maxPresignTTL = 7 * 24 * time.Hour
+
+// maxErrorBody bounds how much of an error response is read for its code
+const maxErrorBody = 4 << 10 // 4KB
+
+// S3Options configures an S3-compatible store
+type S3Options struct {
+	Endpoint        string // such as https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region          string
+	Bucket          string
+	Prefix          string // prepended to every key, for sharing a bucket
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool   // address the bucket in the path rather than the host name, as MinIO expects
+	PublicEndpoint  string // the endpoint presigned URLs point at, when clients reach the service elsewhere
+}
+
+// S3 stores objects in a bucket of an S3-compatible service, speaking its
+// REST API directly. Requests are signed with AWS Signature Version 4. Each
+// object is uploaded with a single PUT, which S3 limits to 5GB.
+type S3 struct {
+	opts           S3Options
+	endpoint       *url.URL
+	publicEndpoint *url.URL
+	client         *http.Client
+}
+
+// NewS3 creates a store for the bucket described by opts
+func NewS3(opts S3Options) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("no S3 bucket configured")
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	opts.Prefix = strings.Trim(opts.Prefix, "/")
+	s := &S3{opts: opts, endpoint: endpoint, client: &http.Client{}}
+	if opts.PublicEndpoint != "" {
+		if s.publicEndpoint, err = url.Parse(strings.TrimRight(opts.PublicEndpoint, "/")); err != nil || s.publicEndpoint.Host == "" {
+			return nil, fmt.Errorf("invalid S3 public endpoint %q", opts.PublicEndpoint)
+		}
+	}
+	return s, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, io.NopCloser(r), size, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, header)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if err == ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	header := http.Header{}
+	header.Set("X-Amz-Checksum-Mode", "ENABLED")
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, header)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+
+	info := Info{Size: resp.ContentLength}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modified
+	}
+	// Objects uploaded in parts carry a checksum of checksums, which does
+	// not decode to a digest of the content
+	if sum, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-Amz-Checksum-Sha256")); err == nil && len(sum) == sha256.Size {
+		info.SHA256 = hex.EncodeToString(sum)
+	}
+	return info, nil
+}
+
+// Copy copies the object under src to dst within the bucket, which S3 limits
+// to objects of 5GB like a single PUT
+func (s *S3) Copy(ctx context.Context, src, dst string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", "/"+s.opts.Bucket+"/"+escapePath(s.objectKey(src)))
+	resp, err := s.do(ctx, http.MethodPut, dst, nil, 0, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A copy that fails part way still answers 200, with an error body
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("S3 copy %s to %s failed: %s %s", src, dst, result.Code, result.Message)
+	}
+	return nil
+}
+
+// PresignPut returns a URL on the public endpoint that accepts a PUT of the
+// object under key until expires, at most a week away as S3 allows. The
+// signature covers the length and the SHA-256 checksum header, so S3 refuses
+// a body of another size or content.
+func (s *S3) PresignPut(key string, size int64, sha256Hex string, expires time.Time) (string, http.Header, error) {
+	sum, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(sum) != sha256.Size {
+		return "", nil, fmt.Errorf("invalid SHA-256 digest %q", sha256Hex)
+	}
+	now := time.Now().UTC()
+	ttl := expires.Sub(now)
+	if ttl > maxPresignTTL {
+		ttl = maxPresignTTL
+	}
+	if ttl <= 0 {
+		return "", nil, fmt.Errorf("presigned URL would already have expired")
+	}
+
+	endpoint := s.endpoint
+	if s.publicEndpoint != nil {
+		endpoint = s.publicEndpoint
+	}
+	target := s.addressObject(endpoint, key)
+	header := http.Header{}
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	header.Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum))
+
+	signed := map[string]string{
+		"content-length":        header.Get("Content-Length"),
+		"host":                  target.Host,
+		"x-amz-checksum-sha256": header.Get("X-Amz-Checksum-Sha256"),
+	}
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.opts.AccessKeyID+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "content-length;host;x-amz-checksum-sha256")
+	_, signature := s.signature(http.MethodPut, target, query, signed, "UNSIGNED-PAYLOAD", now)
+	query.Set("X-Amz-Signature", signature)
+
+	target.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	return target.String(), header, nil
+}
+
+// Usage lists the objects under prefix, a page of up to 1000 at a time, and
+// totals them
+func (s *S3) Usage(ctx context.Context, prefix string) (Usage, error) {
+	var usage Usage
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.objectKey(prefix))
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		target := s.bucketURL()
+		target.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+		resp, err := s.send(ctx, http.MethodGet, target, "list "+prefix, nil, 0, nil)
+		if err != nil {
+			return Usage{}, err
+		}
+		var page struct {
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+			Contents              []struct {
+				Size int64 `xml:"Size"`
+			} `xml:"Contents"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return Usage{}, fmt.Errorf("failed to read S3 listing: %w", err)
+		}
+		for _, object := range page.Contents {
+			usage.Objects++
+			usage.Bytes += object.Size
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return usage, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// do sends a signed request for the object under key. Missing objects fail
+// with ErrNotExist and other error statuses with the service's error code.
+func (s *S3) do(ctx context.Context, method, key string, body io.ReadCloser, size int64, header http.Header) (*http.Response, error) {
+	return s.send(ctx, method, s.objectURL(key), key, body, size, header)
+}
+
+// send sends a signed request to target, naming it what in errors
+func (s *S3) send(ctx context.Context, method string, target *url.URL, what string, body io.ReadCloser, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build S3 request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.Body, req.ContentLength = body, size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		// Streamed bodies are not hashed up front; the signature still covers
+		// the request itself
+		payloadHash = "UNSIGNED-PAYLOAD"
+	}
+	s.sign(req, target, payloadHash, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s %s failed: %w", method, what, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	xml.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&s3Err)
+	if s3Err.Code == "" {
+		s3Err.Code = resp.Status
+	}
+	return nil, fmt.Errorf("S3 %s %s failed: %s %s", method, what, s3Err.Code, s3Err.Message)
+}
+
+// objectURL addresses an object, with the bucket in the host name or, in path
+// style, the first path segment
+func (s *S3) objectURL(key string) *url.URL {
+	return s.addressObject(s.endpoint, key)
+}
+
+// bucketURL addresses the bucket itself, for listing it
+func (s *S3) bucketURL() *url.URL {
+	target := *s.endpoint
+	if s.opts.PathStyle {
+		target.Path = s.endpoint.Path + "/" + s.opts.Bucket
+	} else {
+		target.Host = s.opts.Bucket + "." + s.endpoint.Host
+		target.Path = s.endpoint.Path + "/"
+	}
+	target.RawPath = escapePath(target.Path)
+	return &target
+}
+
+// addressObject addresses an object on the given endpoint
+func (s *S3) addressObject(endpoint *url.URL, key string) *url.URL {
+	objectPath := s.objectKey(key)
+	target := *endpoint
+	if s.opts.PathStyle {
+		target.Path = endpoint.Path + "/" + s.opts.Bucket + "/" + objectPath
+	} else {
+		target.Host = s.opts.Bucket + "." + endpoint.Host
+		target.Path = endpoint.Path + "/" + objectPath
+	}
+	target.RawPath = escapePath(target.Path)
+	return &target
+}
+
+// objectKey returns the key in the bucket of the object under key
+func (s *S3) objectKey(key string) string {
+	objectPath := strings.TrimLeft(key, "/")
+	if s.opts.Prefix != "" {
+		objectPath = s.opts.Prefix + "/" + objectPath
+	}
+	return objectPath
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req, covering
+// the host, any range and every x-amz- header
+func (s *S3) sign(req *http.Request, target *url.URL, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	req.Host = target.Host
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := map[string]string{"host": target.Host}
+	if r := req.Header.Get("Range"); r != "" {
+		signed["range"] = r
+	}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			signed[lower] = req.Header.Get(name)
+		}
+	}
+	signedHeaders, signature := s.signature(req.Method, target, target.Query(), signed, payloadHash, now)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.opts.AccessKeyID+"/"+s.scope(now)+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// signature computes the Signature Version 4 signature of a request with the
+// given query and signed headers, keyed by lower-case name, and returns it
+// with the list of signed headers
+func (s *S3) signature(method string, target *url.URL, query url.Values, signed map[string]string, payloadHash string, now time.Time) (string, string) {
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(signed[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		target.EscapedPath(),
+		// Query values are escaped as SigV4 expects, spaces as %20
+		strings.ReplaceAll(query.Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + s.scope(now) + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretAccessKey), date)
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// scope is the credential scope of requests signed at now
+func (s *S3) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.opts.Region + "/s3/aws4_request"
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath percent-encodes a path the way SigV4 expects: every byte but
+// unreserved characters and the slashes between segments
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestS3Usage(t *testing.T) {
+	// Two pages of listing, the second asked for with the first's token
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vault" || r.URL.Query().Get("list-type") != "2" || r.URL.Query().Get("prefix") != "tenant/storage/" {
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		switch token := r.URL.Query().Get("continuation-token"); token {
+		case "":
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>next page</NextContinuationToken>`+
+				`<Contents><Key>tenant/storage/a</Key><Size>10</Size></Contents>`+
+				`<Contents><Key>tenant/storage/b</Key><Size>20</Size></Contents></ListBucketResult>`)
+		case "next page":
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated>`+
+				`<Contents><Key>tenant/storage/c</Key><Size>5</Size></Contents></ListBucketResult>`)
+		default:
+			t.Errorf("unexpected continuation token %q", token)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewS3(S3Options{Endpoint: server.URL, Bucket: "vault", Prefix: "tenant", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage, err := s.Usage(context.Background(), "storage/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != (Usage{Objects: 3, Bytes: 35}) {
+		t.Errorf("got %+v, want 3 objects of 35 bytes", usage)
+	}
+}
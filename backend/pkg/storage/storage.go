@@ -0,0 +1,180 @@
+// Package storage keeps blob content behind a small interface, so it can live
+// on local disk or in an S3-compatible object store such as AWS S3 or MinIO.
+// Objects are addressed by slash-separated keys, like storage/ab/cd/abcd...
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrNotExist is returned for keys that hold no object
+var ErrNotExist = errors.New("object does not exist")
+
+// Info describes a stored object
+type Info struct {
+	Size    int64
+	ModTime time.Time
+	SHA256  string // hex digest of the content, when the store keeps one
+}
+
+// Storage stores objects by key. Writing a key that exists replaces its
+// object, and deleting a key that does not exist is not an error.
+type Storage interface {
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get reads the object under key from offset to its end
+	Get(ctx context.Context, key string, offset int64) (io.ReadCloser, error)
+	// Delete removes the object under key
+	Delete(ctx context.Context, key string) error
+	// Stat describes the object under key, or fails with ErrNotExist
+	Stat(ctx context.Context, key string) (Info, error)
+}
+
+// Presigner is implemented by stores that can let a client upload an object
+// itself, without the content passing through the server
+type Presigner interface {
+	// PresignPut returns a URL accepting a PUT of size bytes hashing to
+	// sha256Hex under key until expires, and the headers the PUT must carry.
+	// The store refuses content that does not match the hash.
+	PresignPut(key string, size int64, sha256Hex string, expires time.Time) (string, http.Header, error)
+}
+
+// Usage is the number and total size of stored objects
+type Usage struct {
+	Objects int64
+	Bytes   int64
+}
+
+// UsageReporter is implemented by stores that can total what they hold
+// without a filesystem to ask
+type UsageReporter interface {
+	// Usage totals the objects whose keys start with prefix
+	Usage(ctx context.Context, prefix string) (Usage, error)
+}
+
+// Copier is implemented by stores that can copy an object without reading it
+// back through the server
+type Copier interface {
+	// Copy stores the object under src under dst as well
+	Copy(ctx context.Context, src, dst string) error
+}
+
+// Copy stores the object under src under dst as well, within the store when
+// it can and by reading it back otherwise
+func Copy(ctx context.Context, s Storage, src, dst string) error {
+	if c, ok := s.(Copier); ok {
+		return c.Copy(ctx, src, dst)
+	}
+
+	info, err := s.Stat(ctx, src)
+	if err != nil {
+		return err
+	}
+	body, err := s.Get(ctx, src, 0)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return s.Put(ctx, dst, body, info.Size)
+}
+
+// filePutter is implemented by stores that can take over a local file more
+// cheaply than by copying it
+type filePutter interface {
+	PutFile(ctx context.Context, key, path string) error
+}
+
+// PutFile stores the file at path under key and removes the file once it is
+// stored. The file is left in place when storing fails.
+func PutFile(ctx context.Context, s Storage, key, path string) error {
+	if fp, ok := s.(filePutter); ok {
+		return fp.PutFile(ctx, key, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = s.Put(ctx, key, f, info.Size())
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// objectReader reads an object of known size as a seekable stream. A read
+// after a seek reopens the object at the new offset, so serving a range reads
+// only that range.
+type objectReader struct {
+	ctx    context.Context
+	s      Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// NewReadSeeker returns a seekable reader over the object under key, which
+// holds size bytes. Nothing is read until the first Read.
+func NewReadSeeker(ctx context.Context, s Storage, key string, size int64) io.ReadSeekCloser {
+	return &objectReader{ctx: ctx, s: s, key: key, size: size}
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.s.Get(r.ctx, r.key, r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		return n, fmt.Errorf("object %s ended after %d of %d bytes: %w", r.key, r.offset, r.size, io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of object")
+	}
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *objectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,16 @@
+package utils
+
+// DiskUsage describes capacity of the filesystem holding a path
+type DiskUsage struct {
+	Total uint64 // total bytes on the filesystem
+	Free  uint64 // bytes available to unprivileged users
+	Used  uint64 // bytes in use
+}
+
+// UsedPercent returns the share of the filesystem that is in use
+func (d *DiskUsage) UsedPercent() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Used) / float64(d.Total) * 100
+}
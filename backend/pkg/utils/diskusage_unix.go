@@ -0,0 +1,22 @@
+//go:build !windows
+
+package utils
+
+import "syscall"
+
+// GetDiskUsage reports capacity of the filesystem that contains path
+func GetDiskUsage(path string) (*DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, err
+	}
+
+	total := stat.Blocks * uint64(stat.Bsize)
+	free := stat.Bavail * uint64(stat.Bsize)
+
+	return &DiskUsage{
+		Total: total,
+		Free:  free,
+		Used:  total - stat.Bfree*uint64(stat.Bsize),
+	}, nil
+}
@@ -1,18 +1,97 @@
 package utils
 
 import (
+	"bytes"
+	"io"
 	"mime"
 	"net/http"
 	"path/filepath"
 	"strings"
 )
 
-// MimeTypeValidator provides MIME type validation functionality
-type MimeTypeValidator struct{}
+// DefaultSniffLength is the number of leading bytes inspected when no
+// length is configured; it is all http.DetectContentType ever looks at
+const DefaultSniffLength = 512
 
-// NewMimeTypeValidator creates a new MIME type validator
-func NewMimeTypeValidator() *MimeTypeValidator {
-	return &MimeTypeValidator{}
+// deepInspectors recognise types whose leading bytes alone do not identify
+// them, such as Office documents that sniff as plain ZIP archives. Each is
+// given the head of the file only, never the whole content.
+var deepInspectors = map[string]func(head []byte) bool{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ooxmlInspector("word/"),
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ooxmlInspector("xl/"),
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ooxmlInspector("ppt/"),
+	"image/svg+xml":    inspectSVG,
+	"application/json": inspectJSON,
+}
+
+// extensionMimeTypes covers common extensions the platform's MIME table may
+// not know, so validation does not depend on /etc/mime.types being installed
+var extensionMimeTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".doc":  "application/msword",
+	".xls":  "application/vnd.ms-excel",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".csv":  "text/csv",
+	".txt":  "text/plain",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tar":  "application/x-tar",
+	".7z":   "application/x-7z-compressed",
+	".rar":  "application/x-rar-compressed",
+	".mp4":  "video/mp4",
+	".m4v":  "video/x-m4v",
+	".mov":  "video/quicktime",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".ogg":  "audio/ogg",
+}
+
+// MimeTypeValidator provides MIME type validation functionality. It only
+// ever sees the first sniffLength bytes of a file, so uploads can be checked
+// while they stream instead of after they are buffered.
+type MimeTypeValidator struct {
+	sniffLength int
+	deepInspect map[string]bool
+}
+
+// NewMimeTypeValidator creates a new MIME type validator inspecting the
+// first sniffLength bytes of each file. Types in deepInspectTypes are checked
+// against their structure instead of being trusted by extension; types
+// without a built-in check are ignored.
+func NewMimeTypeValidator(sniffLength int, deepInspectTypes []string) *MimeTypeValidator {
+	if sniffLength < DefaultSniffLength {
+		sniffLength = DefaultSniffLength
+	}
+	deepInspect := make(map[string]bool)
+	for _, mimeType := range deepInspectTypes {
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		if _, ok := deepInspectors[mimeType]; ok {
+			deepInspect[mimeType] = true
+		}
+	}
+	return &MimeTypeValidator{sniffLength: sniffLength, deepInspect: deepInspect}
+}
+
+// SniffLength returns the number of leading bytes the validator inspects
+func (v *MimeTypeValidator) SniffLength() int {
+	return v.sniffLength
+}
+
+// Sniff reads the head of a stream for validation and returns a reader that
+// replays it followed by the rest of the stream
+func (v *MimeTypeValidator) Sniff(r io.Reader) ([]byte, io.Reader, error) {
+	head := make([]byte, v.sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+	return head, io.MultiReader(bytes.NewReader(head), r), nil
 }
 
 // DetectMimeType detects the actual MIME type of file content
@@ -24,14 +103,23 @@ func (v *MimeTypeValidator) DetectMimeType(content []byte) string {
 func (v *MimeTypeValidator) GetMimeTypeFromExtension(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
 	mimeType := mime.TypeByExtension(ext)
+	if mimeType == "" {
+		mimeType = extensionMimeTypes[ext]
+	}
 	if mimeType == "" {
 		return "application/octet-stream"
 	}
 	return mimeType
 }
 
-// ValidateMimeType validates that the actual content matches the declared MIME type
+// ValidateMimeType validates that the actual content matches the declared
+// MIME type. content is the head of the file; anything past the sniff length
+// is ignored.
 func (v *MimeTypeValidator) ValidateMimeType(content []byte, declaredMimeType string, filename string) (bool, string, string) {
+	if len(content) > v.sniffLength {
+		content = content[:v.sniffLength]
+	}
+
 	// Detect actual MIME type from content
 	actualMimeType := v.DetectMimeType(content)
 
@@ -74,6 +162,22 @@ func (v *MimeTypeValidator) ValidateMimeType(content []byte, declaredMimeType st
 	declaredMimeType = strings.Split(declaredMimeType, ";")[0]
 	expectedMimeType = strings.Split(expectedMimeType, ";")[0]
 
+	// Types configured for deep inspection must show their structure in the
+	// head; the extension alone is not enough
+	if v.deepInspect[expectedMimeType] {
+		if !deepInspectors[expectedMimeType](content) {
+			return false, actualMimeType, "File content doesn't match the file extension - possible file type mismatch or security threat"
+		}
+		actualMimeType = expectedMimeType
+	}
+
+	// Large binaries such as video and archives often start with a container
+	// the sniffer does not know. Their type is taken from the extension rather
+	// than rejecting them or reading further into the file.
+	if actualMimeType == "application/octet-stream" && isBinaryContainer(expectedMimeType, mimeTypeGroups) {
+		actualMimeType = expectedMimeType
+	}
+
 	// Check if actual content matches expected extension
 	if v.isMimeTypeCompatible(actualMimeType, expectedMimeType, mimeTypeGroups) {
 		// Check if declared MIME type is also compatible
@@ -88,6 +192,46 @@ func (v *MimeTypeValidator) ValidateMimeType(content []byte, declaredMimeType st
 	return false, actualMimeType, "File content doesn't match the file extension - possible file type mismatch or security threat"
 }
 
+// isBinaryContainer reports whether a type is audio, video or an archive
+func isBinaryContainer(mimeType string, groups map[string][]string) bool {
+	if strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "audio/") {
+		return true
+	}
+	for _, archive := range groups["archive"] {
+		if archive == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ooxmlInspector recognises an Office Open XML package: a ZIP archive whose
+// first entries include the content types manifest or the document's part
+// directory
+func ooxmlInspector(partPrefix string) func(head []byte) bool {
+	return func(head []byte) bool {
+		if !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+			return false
+		}
+		return bytes.Contains(head, []byte("[Content_Types].xml")) || bytes.Contains(head, []byte(partPrefix))
+	}
+}
+
+// inspectSVG recognises an SVG document, which sniffs as generic XML or text
+func inspectSVG(head []byte) bool {
+	return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// inspectJSON recognises JSON text: no binary bytes and an object or array
+// at the start
+func inspectJSON(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
 // isMimeTypeCompatible checks if two MIME types are compatible within the same group
 func (v *MimeTypeValidator) isMimeTypeCompatible(mimeType1, mimeType2 string, groups map[string][]string) bool {
 	// Exact match
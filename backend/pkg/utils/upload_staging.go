@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stagedFilePrefix marks files created by StageReader so the startup sweep
+// never touches anything else that happens to live in the temp directory
+const stagedFilePrefix = "upload-"
+
+// stagedHashLength is the length of the hex SHA-256 that names finished staged files
+const stagedHashLength = 64
+
+// StagedFile describes upload content that has been streamed to a temp file
+type StagedFile struct {
+	Path string // location of the temp file
+	Size int64  // number of bytes written
+	Hash string // SHA-256 of the content
+	Head []byte // leading bytes for MIME sniffing
+}
+
+// headWriter keeps the first limit bytes written to it
+type headWriter struct {
+	buf   []byte
+	limit int
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if remaining := w.limit - len(w.buf); remaining > 0 {
+		if len(p) < remaining {
+			remaining = len(p)
+		}
+		w.buf = append(w.buf, p[:remaining]...)
+	}
+	return len(p), nil
+}
+
+// StageReader streams reader into a new temp file inside dir while hashing it,
+// so large uploads never need to be held in memory. The finished file is
+// renamed after its hash so crash recovery can tell which blob it holds. The
+// temp file is removed if anything goes wrong. The first headLength bytes are
+// kept in memory for MIME sniffing.
+func StageReader(dir string, reader io.Reader, headLength int) (*StagedFile, error) {
+	if err := EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, stagedFilePrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	hasher := sha256.New()
+	head := &headWriter{limit: headLength}
+
+	size, copyErr := io.Copy(io.MultiWriter(tmp, hasher, head), reader)
+	closeErr := tmp.Close()
+	if copyErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		if copyErr != nil {
+			return nil, fmt.Errorf("failed to stage upload: %w", copyErr)
+		}
+		return nil, fmt.Errorf("failed to stage upload: %w", closeErr)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	suffix := strings.TrimPrefix(filepath.Base(tmp.Name()), stagedFilePrefix)
+	stagedPath := filepath.Join(dir, stagedFilePrefix+hash+"-"+suffix)
+	if err := os.Rename(tmp.Name(), stagedPath); err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+
+	return &StagedFile{
+		Path: stagedPath,
+		Size: size,
+		Hash: hash,
+		Head: head.buf,
+	}, nil
+}
+
+// StagedFileHash returns the content hash a finished staged file is named
+// after. Files still being written, and other temp files, have none.
+func StagedFileHash(name string) (string, bool) {
+	if !strings.HasPrefix(name, stagedFilePrefix) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(name, stagedFilePrefix)
+	if len(rest) <= stagedHashLength || rest[stagedHashLength] != '-' {
+		return "", false
+	}
+	hash := rest[:stagedHashLength]
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", false
+	}
+	return hash, true
+}
+
+// ListStagedFiles returns the finished staged files in dir, keyed by path,
+// with the content hash each is named after
+func ListStagedFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	staged := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if hash, ok := StagedFileHash(entry.Name()); ok {
+			staged[filepath.Join(dir, entry.Name())] = hash
+		}
+	}
+	return staged, nil
+}
+
+// CommitStagedFile moves a staged file to its final destination, falling back
+// to a copy when the temp directory lives on a different filesystem
+func CommitStagedFile(stagedPath, destPath string) error {
+	if err := EnsureDir(filepath.Dir(destPath)); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if err := os.Rename(stagedPath, destPath); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(stagedPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(destPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(destPath)
+		return err
+	}
+
+	return os.Remove(stagedPath)
+}
+
+// SweepStaleTempFiles removes staged uploads in dir older than maxAge, which
+// are left behind when the process dies mid-upload. It returns the number of
+// files removed.
+func SweepStaleTempFiles(dir string, maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), stagedFilePrefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// CopyFileVerified copies srcPath to destPath through a temp file and only
+// moves it into place when the content matches the expected SHA-256
+func CopyFileVerified(srcPath, destPath, expectedHash string) (int64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	if err := EnsureDir(filepath.Dir(destPath)); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), ".partial-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expectedHash {
+		return 0, fmt.Errorf("checksum mismatch for blob %s: got %s", expectedHash, actual)
+	}
+
+	if err := os.Rename(tmp.Name(), destPath); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
@@ -0,0 +1,122 @@
+package watermark
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// MaxImagePixels bounds the images Apply decodes, since a small compressed
+// file can expand to gigabytes in memory
+const MaxImagePixels = 50_000_000
+
+// Spacing of the bitmap font in glyph pixels
+const (
+	cellWidth  = glyphWidth + 1
+	lineHeight = glyphHeight + 3
+)
+
+var (
+	inkColor    = color.NRGBA{R: 255, G: 255, B: 255, A: 140}
+	shadowColor = color.NRGBA{R: 0, G: 0, B: 0, A: 90}
+)
+
+// stampImage decodes an image, tiles the lines across it and encodes it
+// again as JPEG when it was one and PNG otherwise
+func stampImage(dst io.Writer, src []byte, f string, lines []string) (string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return "", ErrTooLarge
+	}
+
+	var img image.Image
+	switch f {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(src))
+	case "gif":
+		img, err = gif.Decode(bytes.NewReader(src))
+	default:
+		img, err = png.Decode(bytes.NewReader(src))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	canvas := image.NewRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Src)
+	tile(canvas, lines)
+
+	if f == "jpeg" {
+		return "image/jpeg", jpeg.Encode(dst, canvas, &jpeg.Options{Quality: 90})
+	}
+	return "image/png", png.Encode(dst, canvas)
+}
+
+// tile repeats the block of lines over the whole image in staggered rows.
+// The text is scaled so a block spans about 40% of the image width, and
+// drawn in translucent white over a dark shadow so it shows on light and
+// dark content alike.
+func tile(canvas *image.RGBA, lines []string) {
+	columns := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > columns {
+			columns = n
+		}
+	}
+	if columns == 0 {
+		return
+	}
+
+	bounds := canvas.Bounds()
+	scale := bounds.Dx() * 2 / 5 / (columns * cellWidth)
+	if maxScale := bounds.Dy() / 4 / (len(lines) * lineHeight); scale > maxScale {
+		scale = maxScale
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	blockWidth := columns * cellWidth * scale
+	blockHeight := len(lines) * lineHeight * scale
+	stepX := blockWidth + blockWidth/2
+	stepY := blockHeight * 3
+	shadow := scale/3 + 1
+
+	for row, y := 0, bounds.Min.Y+blockHeight; y < bounds.Max.Y; row, y = row+1, y+stepY {
+		x := bounds.Min.X - (row%2)*stepX/2
+		for ; x < bounds.Max.X; x += stepX {
+			drawBlock(canvas, lines, x+shadow, y+shadow, scale, shadowColor)
+			drawBlock(canvas, lines, x, y, scale, inkColor)
+		}
+	}
+}
+
+// drawBlock draws the lines with their top left corner at x, y
+func drawBlock(canvas *image.RGBA, lines []string, x, y, scale int, c color.Color) {
+	ink := image.NewUniform(c)
+	for i, line := range lines {
+		top := y + i*lineHeight*scale
+		for j, r := range []rune(line) {
+			left := x + j*cellWidth*scale
+			g := glyph(r)
+			for gx := 0; gx < glyphWidth; gx++ {
+				for gy := 0; gy < glyphHeight; gy++ {
+					if !lit(g, gx, gy) {
+						continue
+					}
+					px := image.Rect(left+gx*scale, top+gy*scale, left+(gx+1)*scale, top+(gy+1)*scale)
+					draw.Draw(canvas, px, ink, image.Point{}, draw.Over)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,1137 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A PDF is watermarked with an incremental update: each page is written
+// again with one more content stream drawing the text, and a new
+// cross-reference section points readers at the new versions. Values are
+// parsed only as far as needed to find the pages; everything else, strings
+// and numbers included, is copied byte for byte.
+
+// maxPDFPages bounds the pages walked in a document
+const maxPDFPages = 10000
+
+// Names of the resources the watermark adds to each page, unlikely to clash
+// with the document's own
+const (
+	pdfFontName  = "/FVWatermarkFont"
+	pdfStateName = "/FVWatermarkGS"
+)
+
+// errMalformed is returned for documents whose structure cannot be followed
+var errMalformed = errors.New("malformed PDF")
+
+type pdfName string // a name, with its leading slash
+type pdfRaw string  // a number, string, boolean or null, as written
+
+type pdfRef struct{ num, gen int }
+
+type pdfArray []interface{}
+
+// pdfDict keeps its keys in order so documents are rewritten as they were
+type pdfDict struct {
+	keys []pdfName
+	vals map[pdfName]interface{}
+}
+
+type pdfStream struct {
+	dict *pdfDict
+	data []byte
+}
+
+func newDict() *pdfDict {
+	return &pdfDict{vals: map[pdfName]interface{}{}}
+}
+
+func (d *pdfDict) get(key pdfName) interface{} {
+	return d.vals[key]
+}
+
+func (d *pdfDict) set(key pdfName, v interface{}) {
+	if _, ok := d.vals[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.vals[key] = v
+}
+
+func (d *pdfDict) copy() *pdfDict {
+	c := newDict()
+	for _, k := range d.keys {
+		c.set(k, d.vals[k])
+	}
+	return c
+}
+
+// pdfParser reads values from a buffer
+type pdfParser struct {
+	buf []byte
+	pos int
+}
+
+func isWhite(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+func isDelim(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+// skipSpace skips white space and comments
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.buf) {
+		switch b := p.buf[p.pos]; {
+		case isWhite(b):
+			p.pos++
+		case b == '%':
+			for p.pos < len(p.buf) && p.buf[p.pos] != '\n' && p.buf[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads a run of regular characters
+func (p *pdfParser) token() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.buf) && !isWhite(p.buf[p.pos]) && !isDelim(p.buf[p.pos]) {
+		p.pos++
+	}
+	return string(p.buf[start:p.pos])
+}
+
+// integer reads a token that must be a non-negative integer
+func (p *pdfParser) integer() (int, error) {
+	n, err := strconv.Atoi(p.token())
+	if err != nil || n < 0 {
+		return 0, errMalformed
+	}
+	return n, nil
+}
+
+// value reads one value; integers followed by "gen R" are references
+func (p *pdfParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.buf) {
+		return nil, errMalformed
+	}
+
+	switch p.buf[p.pos] {
+	case '/':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.buf) && !isWhite(p.buf[p.pos]) && !isDelim(p.buf[p.pos]) {
+			p.pos++
+		}
+		return pdfName(p.buf[start:p.pos]), nil
+
+	case '<':
+		if p.pos+1 < len(p.buf) && p.buf[p.pos+1] == '<' {
+			return p.dict()
+		}
+		end := bytes.IndexByte(p.buf[p.pos:], '>')
+		if end < 0 {
+			return nil, errMalformed
+		}
+		start := p.pos
+		p.pos += end + 1
+		return pdfRaw(p.buf[start:p.pos]), nil
+
+	case '[':
+		p.pos++
+		var arr pdfArray
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.buf) {
+				return nil, errMalformed
+			}
+			if p.buf[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+
+	case '(':
+		start := p.pos
+		depth := 0
+		for ; p.pos < len(p.buf); p.pos++ {
+			switch p.buf[p.pos] {
+			case '\\':
+				p.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					p.pos++
+					return pdfRaw(p.buf[start:p.pos]), nil
+				}
+			}
+		}
+		return nil, errMalformed
+
+	case ')', '>', ']', '{', '}':
+		return nil, errMalformed
+	}
+
+	tok := p.token()
+	if tok == "" {
+		return nil, errMalformed
+	}
+	if num, err := strconv.Atoi(tok); err == nil && num >= 0 {
+		save := p.pos
+		if gen, err := strconv.Atoi(p.token()); err == nil && gen >= 0 && p.token() == "R" {
+			return pdfRef{num, gen}, nil
+		}
+		p.pos = save
+	}
+	return pdfRaw(tok), nil
+}
+
+// dict reads a dictionary starting at "<<"
+func (p *pdfParser) dict() (*pdfDict, error) {
+	p.pos += 2
+	d := newDict()
+	for {
+		p.skipSpace()
+		if p.pos+1 < len(p.buf) && p.buf[p.pos] == '>' && p.buf[p.pos+1] == '>' {
+			p.pos += 2
+			return d, nil
+		}
+		key, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, errMalformed
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		d.set(name, v)
+	}
+}
+
+// xrefEntry locates an object: at an offset in the file, or as the index-th
+// object of an object stream
+type xrefEntry struct {
+	inStream bool
+	offset   int
+	gen      int
+	stream   int
+	index    int
+}
+
+// pdfDoc is a parsed document
+type pdfDoc struct {
+	buf        []byte
+	xref       map[int]xrefEntry
+	trailer    *pdfDict
+	lastXref   int
+	xrefStream bool // the newest cross-reference section is a stream
+	objects    map[int]interface{}
+	objStreams map[int]map[int]interface{}
+}
+
+// openPDF reads the cross-reference sections and trailer of a document
+func openPDF(buf []byte) (*pdfDoc, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(buf[:min(len(buf), 1024)], "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, errMalformed
+	}
+	at := bytes.LastIndex(buf, []byte("startxref"))
+	if at < 0 {
+		return nil, errMalformed
+	}
+	p := &pdfParser{buf: buf, pos: at + len("startxref")}
+	offset, err := p.integer()
+	if err != nil || offset >= len(buf) {
+		return nil, errMalformed
+	}
+
+	d := &pdfDoc{
+		buf:        buf,
+		xref:       map[int]xrefEntry{},
+		lastXref:   offset,
+		objects:    map[int]interface{}{},
+		objStreams: map[int]map[int]interface{}{},
+	}
+	if err := d.loadXref(offset, map[int]bool{}); err != nil {
+		return nil, err
+	}
+	if d.trailer == nil {
+		return nil, errMalformed
+	}
+	if d.trailer.get("/Encrypt") != nil {
+		return nil, ErrEncrypted
+	}
+	return d, nil
+}
+
+// loadXref reads the cross-reference section at offset and the ones before
+// it. Entries already read from newer sections win.
+func (d *pdfDoc) loadXref(offset int, seen map[int]bool) error {
+	if seen[offset] || offset < 0 || offset >= len(d.buf) {
+		return errMalformed
+	}
+	seen[offset] = true
+	newest := d.trailer == nil
+
+	p := &pdfParser{buf: d.buf, pos: offset}
+	var trailer *pdfDict
+	if p.token() == "xref" {
+		for {
+			save := p.pos
+			if p.token() == "trailer" {
+				break
+			}
+			p.pos = save
+			start, err := p.integer()
+			if err != nil {
+				return err
+			}
+			count, err := p.integer()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < count; i++ {
+				off, err := p.integer()
+				if err != nil {
+					return err
+				}
+				gen, err := p.integer()
+				if err != nil {
+					return err
+				}
+				kind := p.token()
+				if _, ok := d.xref[start+i]; !ok && kind == "n" {
+					d.xref[start+i] = xrefEntry{offset: off, gen: gen}
+				}
+			}
+		}
+		p.skipSpace()
+		if !bytes.HasPrefix(p.buf[p.pos:], []byte("<<")) {
+			return errMalformed
+		}
+		var err error
+		if trailer, err = p.dict(); err != nil {
+			return err
+		}
+		if d.trailer == nil {
+			d.trailer = trailer
+		}
+		// Hybrid files keep the entries of object streams in a stream
+		if stm, ok := trailer.get("/XRefStm").(pdfRaw); ok {
+			if off, err := strconv.Atoi(string(stm)); err == nil {
+				if err := d.loadXref(off, seen); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		_, _, v, err := d.parseObjectAt(offset)
+		if err != nil {
+			return err
+		}
+		stream, ok := v.(*pdfStream)
+		if !ok || stream.dict.get("/Type") != pdfName("/XRef") {
+			return errMalformed
+		}
+		if err := d.readXrefStream(stream); err != nil {
+			return err
+		}
+		trailer = stream.dict
+		if d.trailer == nil {
+			d.trailer = trailer
+			d.xrefStream = newest
+		}
+	}
+
+	if prev, ok := trailer.get("/Prev").(pdfRaw); ok {
+		off, err := strconv.Atoi(string(prev))
+		if err != nil {
+			return errMalformed
+		}
+		return d.loadXref(off, seen)
+	}
+	return nil
+}
+
+// readXrefStream adds the entries of a cross-reference stream
+func (d *pdfDoc) readXrefStream(stream *pdfStream) error {
+	data, err := d.decode(stream)
+	if err != nil {
+		return err
+	}
+	widths, ok := d.ints(stream.dict.get("/W"))
+	if !ok || len(widths) != 3 {
+		return errMalformed
+	}
+	index, ok := d.ints(stream.dict.get("/Index"))
+	if !ok {
+		size, err := d.integer(stream.dict.get("/Size"))
+		if err != nil {
+			return err
+		}
+		index = []int{0, size}
+	}
+
+	rowLen := widths[0] + widths[1] + widths[2]
+	field := func(row []byte, i int) int {
+		start := 0
+		for _, w := range widths[:i] {
+			start += w
+		}
+		n := 0
+		for _, b := range row[start : start+widths[i]] {
+			n = n<<8 | int(b)
+		}
+		return n
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		for num := index[i]; num < index[i]+index[i+1]; num++ {
+			if pos+rowLen > len(data) {
+				return errMalformed
+			}
+			row := data[pos : pos+rowLen]
+			pos += rowLen
+			kind := 1
+			if widths[0] > 0 {
+				kind = field(row, 0)
+			}
+			if _, ok := d.xref[num]; ok {
+				continue
+			}
+			switch kind {
+			case 1:
+				d.xref[num] = xrefEntry{offset: field(row, 1), gen: field(row, 2)}
+			case 2:
+				d.xref[num] = xrefEntry{inStream: true, stream: field(row, 1), index: field(row, 2)}
+			}
+		}
+	}
+	return nil
+}
+
+// parseObjectAt reads the indirect object "num gen obj ... endobj" at offset
+func (d *pdfDoc) parseObjectAt(offset int) (int, int, interface{}, error) {
+	p := &pdfParser{buf: d.buf, pos: offset}
+	num, err := p.integer()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	gen, err := p.integer()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if p.token() != "obj" {
+		return 0, 0, nil, errMalformed
+	}
+	v, err := p.value()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	dict, ok := v.(*pdfDict)
+	if !ok {
+		return num, gen, v, nil
+	}
+	save := p.pos
+	if p.token() != "stream" {
+		p.pos = save
+		return num, gen, v, nil
+	}
+	if p.pos < len(p.buf) && p.buf[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.buf) && p.buf[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+	length, err := d.integer(dict.get("/Length"))
+	if err != nil || start+length > len(d.buf) {
+		// Fall back to the end marker when the length is wrong
+		end := bytes.Index(d.buf[start:], []byte("endstream"))
+		if end < 0 {
+			return 0, 0, nil, errMalformed
+		}
+		length = end
+	}
+	return num, gen, &pdfStream{dict: dict, data: d.buf[start : start+length]}, nil
+}
+
+// object returns an indirect object, or nil for free and missing ones
+func (d *pdfDoc) object(num int) (interface{}, error) {
+	if v, ok := d.objects[num]; ok {
+		return v, nil
+	}
+	entry, ok := d.xref[num]
+	if !ok {
+		return nil, nil
+	}
+
+	var v interface{}
+	if entry.inStream {
+		objects, err := d.objectStream(entry.stream)
+		if err != nil {
+			return nil, err
+		}
+		v = objects[entry.index]
+	} else {
+		var err error
+		if _, _, v, err = d.parseObjectAt(entry.offset); err != nil {
+			return nil, err
+		}
+	}
+	d.objects[num] = v
+	return v, nil
+}
+
+// objectStream returns the objects of an object stream by index
+func (d *pdfDoc) objectStream(num int) (map[int]interface{}, error) {
+	if objects, ok := d.objStreams[num]; ok {
+		return objects, nil
+	}
+	entry, ok := d.xref[num]
+	if !ok || entry.inStream {
+		return nil, errMalformed
+	}
+	_, _, v, err := d.parseObjectAt(entry.offset)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := v.(*pdfStream)
+	if !ok {
+		return nil, errMalformed
+	}
+	data, err := d.decode(stream)
+	if err != nil {
+		return nil, err
+	}
+	n, err := d.integer(stream.dict.get("/N"))
+	if err != nil {
+		return nil, err
+	}
+	first, err := d.integer(stream.dict.get("/First"))
+	if err != nil || first > len(data) {
+		return nil, errMalformed
+	}
+
+	objects := map[int]interface{}{}
+	header := &pdfParser{buf: data[:first]}
+	for i := 0; i < n; i++ {
+		if _, err := header.integer(); err != nil {
+			return nil, err
+		}
+		off, err := header.integer()
+		if err != nil {
+			return nil, err
+		}
+		p := &pdfParser{buf: data, pos: first + off}
+		if objects[i], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	d.objStreams[num] = objects
+	return objects, nil
+}
+
+// resolve follows references to the value they point at
+func (d *pdfDoc) resolve(v interface{}) (interface{}, error) {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v, nil
+		}
+		var err error
+		if v, err = d.object(ref.num); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errMalformed
+}
+
+func (d *pdfDoc) integer(v interface{}) (int, error) {
+	v, err := d.resolve(v)
+	if err != nil {
+		return 0, err
+	}
+	raw, ok := v.(pdfRaw)
+	if !ok {
+		return 0, errMalformed
+	}
+	n, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, errMalformed
+	}
+	return n, nil
+}
+
+func (d *pdfDoc) ints(v interface{}) ([]int, bool) {
+	v, err := d.resolve(v)
+	if err != nil {
+		return nil, false
+	}
+	arr, ok := v.(pdfArray)
+	if !ok {
+		return nil, false
+	}
+	out := make([]int, len(arr))
+	for i, item := range arr {
+		if out[i], err = d.integer(item); err != nil {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+// decode returns the content of a stream stored raw or Flate compressed,
+// with PNG predictors undone
+func (d *pdfDoc) decode(stream *pdfStream) ([]byte, error) {
+	filter, err := d.resolve(stream.dict.get("/Filter"))
+	if err != nil {
+		return nil, err
+	}
+	parms, err := d.resolve(stream.dict.get("/DecodeParms"))
+	if err != nil {
+		return nil, err
+	}
+	if arr, ok := filter.(pdfArray); ok && len(arr) == 1 {
+		filter = arr[0]
+		if parmsArr, ok := parms.(pdfArray); ok && len(parmsArr) == 1 {
+			parms, _ = d.resolve(parmsArr[0])
+		}
+	}
+
+	switch filter {
+	case nil:
+		return stream.data, nil
+	case pdfName("/FlateDecode"):
+	default:
+		return nil, fmt.Errorf("%w: unsupported stream filter", errMalformed)
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(stream.data))
+	if err != nil {
+		return nil, errMalformed
+	}
+	data, err := io.ReadAll(r)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, errMalformed
+	}
+
+	dict, ok := parms.(*pdfDict)
+	if !ok || dict.get("/Predictor") == nil {
+		return data, nil
+	}
+	predictor, err := d.integer(dict.get("/Predictor"))
+	if err != nil || predictor == 1 {
+		return data, err
+	}
+	if predictor < 10 {
+		return nil, fmt.Errorf("%w: unsupported predictor", errMalformed)
+	}
+	columns := 1
+	if dict.get("/Columns") != nil {
+		if columns, err = d.integer(dict.get("/Columns")); err != nil {
+			return nil, err
+		}
+	}
+	return unpredictPNG(data, columns)
+}
+
+// unpredictPNG undoes the PNG row filters of one byte per pixel rows
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	if columns <= 0 {
+		return nil, errMalformed
+	}
+	var out []byte
+	prev := make([]byte, columns)
+	for pos := 0; pos+columns+1 <= len(data); pos += columns + 1 {
+		kind, row := data[pos], append([]byte(nil), data[pos+1:pos+1+columns]...)
+		for i := range row {
+			var left, upLeft byte
+			if i > 0 {
+				left, upLeft = row[i-1], prev[i-1]
+			}
+			up := prev[i]
+			switch kind {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, errMalformed
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pdfPage is a page with the attributes it inherits from the page tree
+type pdfPage struct {
+	ref       pdfRef
+	dict      *pdfDict
+	resources interface{}
+	mediaBox  interface{}
+}
+
+// pages returns the pages of the document in order
+func (d *pdfDoc) pages() ([]pdfPage, error) {
+	root, err := d.resolve(d.trailer.get("/Root"))
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(*pdfDict)
+	if !ok {
+		return nil, errMalformed
+	}
+	tree, ok := catalog.get("/Pages").(pdfRef)
+	if !ok {
+		return nil, errMalformed
+	}
+
+	var pages []pdfPage
+	seen := map[int]bool{}
+	var walk func(ref pdfRef, resources, mediaBox interface{}) error
+	walk = func(ref pdfRef, resources, mediaBox interface{}) error {
+		if seen[ref.num] || len(pages) >= maxPDFPages {
+			return errMalformed
+		}
+		seen[ref.num] = true
+		v, err := d.object(ref.num)
+		if err != nil {
+			return err
+		}
+		node, ok := v.(*pdfDict)
+		if !ok {
+			return errMalformed
+		}
+		if r := node.get("/Resources"); r != nil {
+			resources = r
+		}
+		if b := node.get("/MediaBox"); b != nil {
+			mediaBox = b
+		}
+
+		kids, err := d.resolve(node.get("/Kids"))
+		if err != nil {
+			return err
+		}
+		if node.get("/Type") == pdfName("/Page") || kids == nil {
+			pages = append(pages, pdfPage{ref: ref, dict: node, resources: resources, mediaBox: mediaBox})
+			return nil
+		}
+		arr, ok := kids.(pdfArray)
+		if !ok {
+			return errMalformed
+		}
+		for _, kid := range arr {
+			kidRef, ok := kid.(pdfRef)
+			if !ok {
+				return errMalformed
+			}
+			if err := walk(kidRef, resources, mediaBox); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(tree, nil, nil); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// box returns the page size from its media box, Letter when it has none
+func (d *pdfDoc) box(v interface{}) [4]float64 {
+	box := [4]float64{0, 0, 612, 792}
+	v, err := d.resolve(v)
+	arr, ok := v.(pdfArray)
+	if err != nil || !ok || len(arr) != 4 {
+		return box
+	}
+	var parsed [4]float64
+	for i, item := range arr {
+		item, err := d.resolve(item)
+		raw, ok := item.(pdfRaw)
+		if err != nil || !ok {
+			return box
+		}
+		if parsed[i], err = strconv.ParseFloat(string(raw), 64); err != nil {
+			return box
+		}
+	}
+	return parsed
+}
+
+// pdfWriter appends the objects of an incremental update
+type pdfWriter struct {
+	base    int
+	buf     bytes.Buffer
+	offsets map[int]int
+	gens    map[int]int
+	next    int
+}
+
+func (w *pdfWriter) begin(num, gen int) {
+	w.offsets[num] = w.base + w.buf.Len()
+	w.gens[num] = gen
+	fmt.Fprintf(&w.buf, "%d %d obj\n", num, gen)
+}
+
+// add writes a new object and returns a reference to it
+func (w *pdfWriter) add(v interface{}) pdfRef {
+	num := w.next
+	w.next++
+	w.begin(num, 0)
+	writeValue(&w.buf, v)
+	w.buf.WriteString("\nendobj\n")
+	return pdfRef{num, 0}
+}
+
+// addStream writes a new Flate compressed stream and returns a reference to
+// it
+func (w *pdfWriter) addStream(data []byte) pdfRef {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	num := w.next
+	w.next++
+	w.begin(num, 0)
+	fmt.Fprintf(&w.buf, "<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	compressed.WriteTo(&w.buf)
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return pdfRef{num, 0}
+}
+
+// replace writes a new version of an existing object
+func (w *pdfWriter) replace(ref pdfRef, v interface{}) {
+	w.begin(ref.num, ref.gen)
+	writeValue(&w.buf, v)
+	w.buf.WriteString("\nendobj\n")
+}
+
+func writeValue(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case pdfName:
+		b.WriteString(string(v))
+	case pdfRaw:
+		b.WriteString(string(v))
+	case pdfRef:
+		fmt.Fprintf(b, "%d %d R", v.num, v.gen)
+	case pdfArray:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeValue(b, item)
+		}
+		b.WriteByte(']')
+	case *pdfDict:
+		b.WriteString("<<")
+		for _, k := range v.keys {
+			b.WriteString(string(k))
+			b.WriteByte(' ')
+			writeValue(b, v.vals[k])
+			b.WriteByte('\n')
+		}
+		b.WriteString(">>")
+	case *pdfStream:
+		// Streams are never rewritten, only referenced
+		b.WriteString("null")
+	default:
+		b.WriteString("null")
+	}
+}
+
+// stampPDF writes the document followed by an update that draws the lines
+// across every page
+func stampPDF(dst io.Writer, src []byte, lines []string) error {
+	doc, err := openPDF(src)
+	if err != nil {
+		return err
+	}
+	pages, err := doc.pages()
+	if err != nil {
+		return err
+	}
+	size, err := doc.integer(doc.trailer.get("/Size"))
+	if err != nil {
+		return err
+	}
+
+	w := &pdfWriter{base: len(src) + 1, offsets: map[int]int{}, gens: map[int]int{}, next: size}
+
+	font := newDict()
+	font.set("/Type", pdfName("/Font"))
+	font.set("/Subtype", pdfName("/Type1"))
+	font.set("/BaseFont", pdfName("/Helvetica"))
+	font.set("/Encoding", pdfName("/WinAnsiEncoding"))
+	fontRef := w.add(font)
+
+	state := newDict()
+	state.set("/Type", pdfName("/ExtGState"))
+	state.set("/ca", pdfRaw("0.3"))
+	state.set("/CA", pdfRaw("0.3"))
+	stateRef := w.add(state)
+
+	// The page's own content is wrapped in q ... Q so graphics state it
+	// leaves behind does not move or hide the watermark
+	saveRef := w.addStream([]byte("q\n"))
+
+	for _, page := range pages {
+		resources, err := doc.pageResources(page.resources, fontRef, stateRef)
+		if err != nil {
+			return err
+		}
+
+		contents := pdfArray{saveRef}
+		orig, err := doc.resolve(page.dict.get("/Contents"))
+		if err != nil {
+			return err
+		}
+		switch orig.(type) {
+		case pdfArray:
+			contents = append(contents, orig.(pdfArray)...)
+		case nil:
+		default:
+			contents = append(contents, page.dict.get("/Contents"))
+		}
+		contents = append(contents, w.addStream(pdfStampContent(doc.box(page.mediaBox), lines)))
+
+		updated := page.dict.copy()
+		updated.set("/Resources", resources)
+		updated.set("/Contents", contents)
+		gen := 0
+		if entry, ok := doc.xref[page.ref.num]; ok && !entry.inStream {
+			gen = entry.gen
+		}
+		w.replace(pdfRef{page.ref.num, gen}, updated)
+	}
+
+	trailer := newDict()
+	for _, key := range []pdfName{"/Root", "/Info", "/ID"} {
+		if v := doc.trailer.get(key); v != nil {
+			trailer.set(key, v)
+		}
+	}
+	trailer.set("/Prev", pdfRaw(strconv.Itoa(doc.lastXref)))
+	if doc.xrefStream {
+		w.writeXrefStream(trailer)
+	} else {
+		w.writeXrefTable(trailer)
+	}
+
+	if _, err := dst.Write(src); err != nil {
+		return err
+	}
+	if _, err := dst.Write([]byte("\n")); err != nil {
+		return err
+	}
+	_, err = w.buf.WriteTo(dst)
+	return err
+}
+
+// pageResources returns a copy of a page's resources with the watermark's
+// font and graphics state added
+func (d *pdfDoc) pageResources(v interface{}, fontRef, stateRef pdfRef) (*pdfDict, error) {
+	v, err := d.resolve(v)
+	if err != nil {
+		return nil, err
+	}
+	resources := newDict()
+	if dict, ok := v.(*pdfDict); ok {
+		resources = dict.copy()
+	}
+
+	for _, add := range []struct {
+		category pdfName
+		name     pdfName
+		ref      pdfRef
+	}{{"/Font", pdfFontName, fontRef}, {"/ExtGState", pdfStateName, stateRef}} {
+		sub, err := d.resolve(resources.get(add.category))
+		if err != nil {
+			return nil, err
+		}
+		entries := newDict()
+		if dict, ok := sub.(*pdfDict); ok {
+			entries = dict.copy()
+		}
+		entries.set(add.name, add.ref)
+		resources.set(add.category, entries)
+	}
+	return resources, nil
+}
+
+// pdfStampContent draws the lines in grey, tiled diagonally across a page
+func pdfStampContent(box [4]float64, lines []string) []byte {
+	columns := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > columns {
+			columns = n
+		}
+	}
+	width, height := math.Abs(box[2]-box[0]), math.Abs(box[3]-box[1])
+	if columns == 0 || width == 0 || height == 0 {
+		return []byte("Q\n")
+	}
+
+	// Helvetica averages about half an em per character
+	fontSize := math.Max(6, math.Min(36, 0.4*width/(float64(columns)*0.5)))
+	leading := fontSize * 1.3
+	blockWidth := float64(columns) * fontSize * 0.5
+	blockHeight := float64(len(lines)) * leading
+	stepX, stepY := blockWidth*1.5, blockHeight*3
+	diagonal := math.Hypot(width, height)
+
+	var b bytes.Buffer
+	b.WriteString("Q\nq\n")
+	fmt.Fprintf(&b, "%s gs 0.5 g\n", pdfStateName)
+	fmt.Fprintf(&b, "0.7071 0.7071 -0.7071 0.7071 %.2f %.2f cm\n", box[0]+width/2, box[1]+height/2)
+	fmt.Fprintf(&b, "BT %s %.2f Tf\n", pdfFontName, fontSize)
+	for row, y := 0, -diagonal/2; y < diagonal/2; row, y = row+1, y+stepY {
+		x := -diagonal/2 - float64(row%2)*stepX/2
+		for ; x < diagonal/2; x += stepX {
+			for i, line := range lines {
+				fmt.Fprintf(&b, "1 0 0 1 %.2f %.2f Tm (%s) Tj\n", x, y-float64(i)*leading, pdfString(line))
+			}
+		}
+	}
+	b.WriteString("ET\nQ\n")
+	return b.Bytes()
+}
+
+// pdfString escapes text for a literal string, replacing what Helvetica's
+// WinAnsi encoding cannot show
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// objectRuns groups the written object numbers into runs of consecutive
+// numbers, as cross-reference sections list them
+func (w *pdfWriter) objectRuns() [][]int {
+	nums := make([]int, 0, len(w.offsets))
+	for num := range w.offsets {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	var runs [][]int
+	for _, num := range nums {
+		if n := len(runs); n > 0 && runs[n-1][len(runs[n-1])-1] == num-1 {
+			runs[n-1] = append(runs[n-1], num)
+		} else {
+			runs = append(runs, []int{num})
+		}
+	}
+	return runs
+}
+
+// writeXrefTable ends the update with a classic cross-reference table
+func (w *pdfWriter) writeXrefTable(trailer *pdfDict) {
+	start := w.base + w.buf.Len()
+	w.buf.WriteString("xref\n")
+	for _, run := range w.objectRuns() {
+		fmt.Fprintf(&w.buf, "%d %d\n", run[0], len(run))
+		for _, num := range run {
+			fmt.Fprintf(&w.buf, "%010d %05d n\r\n", w.offsets[num], w.gens[num])
+		}
+	}
+	trailer.set("/Size", pdfRaw(strconv.Itoa(w.next)))
+	w.buf.WriteString("trailer\n")
+	writeValue(&w.buf, trailer)
+	fmt.Fprintf(&w.buf, "\nstartxref\n%d\n%%%%EOF\n", start)
+}
+
+// writeXrefStream ends the update with a cross-reference stream, for
+// documents whose newest section is one
+func (w *pdfWriter) writeXrefStream(trailer *pdfDict) {
+	num := w.next
+	w.next++
+	start := w.base + w.buf.Len()
+	w.offsets[num] = start
+	w.gens[num] = 0
+
+	var index pdfArray
+	var data bytes.Buffer
+	for _, run := range w.objectRuns() {
+		index = append(index, pdfRaw(strconv.Itoa(run[0])), pdfRaw(strconv.Itoa(len(run))))
+		for _, n := range run {
+			off, gen := w.offsets[n], w.gens[n]
+			data.Write([]byte{1, byte(off >> 24), byte(off >> 16), byte(off >> 8), byte(off), byte(gen >> 8), byte(gen)})
+		}
+	}
+
+	trailer.set("/Type", pdfName("/XRef"))
+	trailer.set("/Size", pdfRaw(strconv.Itoa(w.next)))
+	trailer.set("/W", pdfArray{pdfRaw("1"), pdfRaw("4"), pdfRaw("2")})
+	trailer.set("/Index", index)
+	trailer.set("/Length", pdfRaw(strconv.Itoa(data.Len())))
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", num)
+	writeValue(&w.buf, trailer)
+	w.buf.WriteString("\nstream\n")
+	data.WriteTo(&w.buf)
+	fmt.Fprintf(&w.buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+}
@@ -0,0 +1,58 @@
+// Package watermark stamps identifying text onto PDF and image content, so
+// that copies of documents opened through share links can be traced back to
+// whoever opened them.
+//
+// Images are decoded, stamped with the text tiled across them in a built-in
+// bitmap font and encoded again. PDFs get an incremental update that adds the
+// text to every page and leaves the original objects untouched.
+package watermark
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrUnsupported is returned for content that cannot be watermarked
+	ErrUnsupported = errors.New("content type cannot be watermarked")
+	// ErrEncrypted is returned for password-protected PDFs
+	ErrEncrypted = errors.New("encrypted PDFs cannot be watermarked")
+	// ErrTooLarge is returned for images with more pixels than MaxImagePixels
+	ErrTooLarge = errors.New("image is too large to watermark")
+)
+
+// Supports reports whether content of a MIME type can be watermarked
+func Supports(mimeType string) bool {
+	return format(mimeType) != ""
+}
+
+// format maps a MIME type to the format Apply handles it as
+func format(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch mimeType {
+	case "application/pdf":
+		return "pdf"
+	case "image/png":
+		return "png"
+	case "image/jpeg", "image/jpg":
+		return "jpeg"
+	case "image/gif":
+		return "gif"
+	}
+	return ""
+}
+
+// Apply writes src with the lines stamped onto it and returns the MIME type
+// of what it wrote. Animated GIFs keep only their first frame and are
+// written as PNG.
+func Apply(dst io.Writer, src []byte, mimeType string, lines []string) (string, error) {
+	switch f := format(mimeType); f {
+	case "pdf":
+		return "application/pdf", stampPDF(dst, src, lines)
+	case "":
+		return "", ErrUnsupported
+	default:
+		return stampImage(dst, src, f, lines)
+	}
+}
@@ -91,37 +91,38 @@ interface AdminUser {
   id: string;
   username: string;
   email: string;
-  firstName: string;
-  lastName: string;
+  first_name: string;
+  last_name: string;
   role: 'user' | 'admin';
-  storageQuota: number;
-  storageUsed: number;
-  totalUploadedBytes: number;
-  actualStorageBytes: number;
-  savedBytes: number;
-  isActive: boolean;
-  emailVerified: boolean;
-  lastLogin?: string;
-  createdAt: string;
+  storage_quota: number;
+  storage_used: number;
+  total_uploaded_bytes: number;
+  actual_storage_bytes: number;
+  saved_bytes: number;
+  is_active: boolean;
+  lifecycle_state?: 'active' | 'warned' | 'read_only' | 'archived';
+  email_verified: boolean;
+  last_login?: string;
+  created_at: string;
 }
 
 interface AdminFile {
   id: string;
   filename: string;
-  originalFilename: string;
-  mimeType: string;
+  original_filename: string;
+  mime_type: string;
   size: number;
-  createdAt: string;
-  updatedAt: string;
+  created_at: string;
+  updated_at: string;
   owner: AdminUser;
   folder?: {
     id: string;
     name: string;
     path: string;
   };
-  downloadCount: number;
-  lastDownload?: string;
-  uniqueDownloaders: number;
+  download_count: number;
+  last_download?: string;
+  unique_downloaders: number;
 }
 
 interface FileStats {
@@ -205,6 +206,7 @@ export const AdminPanel: React.FC = () => {
 
   // File preview state
   const [previewFile, setPreviewFile] = useState<AdminFile | null>(null);
+  const [accessReason, setAccessReason] = useState('');
   const [previewOpen, setPreviewOpen] = useState(false);
 
   // Deduplication state
@@ -498,7 +500,16 @@ export const AdminPanel: React.FC = () => {
   };
 
   // File preview functions
+  // Opening another user's file is audited and needs a stated reason
+  const askAccessReason = (): string | null => {
+    const reason = window.prompt("Why do you need to access this user's file? The reason is recorded in the audit log.");
+    return reason && reason.trim() ? reason.trim() : null;
+  };
+
   const openFilePreview = (file: AdminFile) => {
+    const reason = askAccessReason();
+    if (!reason) return;
+    setAccessReason(reason);
     setPreviewFile(file);
     setPreviewOpen(true);
   };
@@ -506,11 +517,14 @@ export const AdminPanel: React.FC = () => {
   const closeFilePreview = () => {
     setPreviewFile(null);
     setPreviewOpen(false);
+    setAccessReason('');
   };
 
   const handleDownloadFromPreview = async (file: any) => {
+    const reason = previewOpen && accessReason ? accessReason : askAccessReason();
+    if (!reason) return;
     try {
-      const response = await fetch(`${API_BASE}/admin/files/${file.id}/download`, {
+      const response = await fetch(`${API_BASE}/admin/files/${file.id}/download?reason=${encodeURIComponent(reason)}`, {
         headers: {
           'Authorization': `Bearer ${token}`,
         },
@@ -1417,7 +1431,7 @@ export const AdminPanel: React.FC = () => {
                                 {user.username}
                               </Typography>
                               <Typography variant="caption" sx={{ color: 'rgba(255, 255, 255, 0.7)' }}>
-                                {user.firstName} {user.lastName}
+                                {user.first_name} {user.last_name}
                               </Typography>
                             </Box>
                           </Box>
@@ -1444,11 +1458,13 @@ export const AdminPanel: React.FC = () => {
                         </TableCell>
                         <TableCell sx={{ color: 'white' }}>
                           <Chip
-                            label={user.isActive ? 'Active' : 'Inactive'}
-                            color={user.isActive ? 'default' : 'default'}
+                            label={user.lifecycle_state && user.lifecycle_state !== 'active'
+                              ? user.lifecycle_state.replace('_', '-')
+                              : user.is_active ? 'Active' : 'Inactive'}
+                            color={user.is_active ? 'default' : 'default'}
                             size="small"
                             sx={{
-                              bgcolor: user.isActive ? 'rgba(76, 175, 80, 0.8)' : 'rgba(244, 67, 54, 0.8)',
+                              bgcolor: user.is_active ? 'rgba(76, 175, 80, 0.8)' : 'rgba(244, 67, 54, 0.8)',
                               color: 'white',
                               fontWeight: 600,
                               fontSize: '0.75rem',
@@ -1458,26 +1474,26 @@ export const AdminPanel: React.FC = () => {
                         </TableCell>
                         <TableCell sx={{ color: 'white' }}>
                           <Typography variant="body2" sx={{ color: 'white' }}>
-                            {(user.storageUsed / 1024 / 1024).toFixed(1)} MB / {(user.storageQuota / 1024 / 1024).toFixed(0)} MB
+                            {(user.storage_used / 1024 / 1024).toFixed(1)} MB / {(user.storage_quota / 1024 / 1024).toFixed(0)} MB
                           </Typography>
                         </TableCell>
                         <TableCell sx={{ color: 'white' }}>
                           <Box sx={{ display: 'flex', flexDirection: 'column', gap: 0.5 }}>
                             <Typography variant="body2" sx={{ color: 'white', fontWeight: 600 }}>
-                              {user.totalUploadedBytes > 0 
-                                ? `${((user.savedBytes / user.totalUploadedBytes) * 100).toFixed(1)}% saved`
+                              {user.total_uploaded_bytes > 0 
+                                ? `${((user.saved_bytes / user.total_uploaded_bytes) * 100).toFixed(1)}% saved`
                                 : '0% saved'
                               }
                             </Typography>
                             <Typography variant="caption" sx={{ color: 'rgba(255, 255, 255, 0.7)' }}>
-                              {((user.savedBytes || 0) / 1024 / 1024).toFixed(1)} MB saved
+                              {((user.saved_bytes || 0) / 1024 / 1024).toFixed(1)} MB saved
                             </Typography>
                           </Box>
                         </TableCell>
                         <TableCell sx={{ color: 'white' }}>
                           <Typography variant="body2" sx={{ color: 'rgba(255, 255, 255, 0.8)' }}>
-                            {user.lastLogin
-                              ? new Date(user.lastLogin).toLocaleDateString()
+                            {user.last_login
+                              ? new Date(user.last_login).toLocaleDateString()
                               : 'Never'}
                           </Typography>
                         </TableCell>
@@ -1889,10 +1905,10 @@ export const AdminPanel: React.FC = () => {
                             <Description sx={{ color: 'rgba(255, 255, 255, 0.7)' }} />
                             <Box>
                               <Typography variant="body2" sx={{ fontWeight: 600, color: 'white' }}>
-                                {file.originalFilename}
+                                {file.original_filename}
                               </Typography>
                               <Typography variant="caption" sx={{ color: 'rgba(255, 255, 255, 0.7)' }}>
-                                {file.mimeType}
+                                {file.mime_type}
                               </Typography>
                             </Box>
                           </Box>
@@ -1914,22 +1930,22 @@ export const AdminPanel: React.FC = () => {
                         </TableCell>
                         <TableCell sx={{ color: 'white' }}>
                           <Box sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
-                            <Badge badgeContent={file.downloadCount} color="primary" max={999}>
+                            <Badge badgeContent={file.download_count} color="primary" max={999}>
                               <Download sx={{ color: 'rgba(255, 255, 255, 0.7)', fontSize: 20 }} />
                             </Badge>
                             <Box>
                               <Typography variant="body2" sx={{ color: 'white' }}>
-                                {file.downloadCount}
+                                {file.download_count}
                               </Typography>
                               <Typography variant="caption" sx={{ color: 'rgba(255, 255, 255, 0.7)' }}>
-                                {file.uniqueDownloaders} unique
+                                {file.unique_downloaders} unique
                               </Typography>
                             </Box>
                           </Box>
                         </TableCell>
                         <TableCell sx={{ color: 'white' }}>
                           <Typography variant="body2" sx={{ color: 'rgba(255, 255, 255, 0.8)' }}>
-                            {new Date(file.createdAt).toLocaleDateString()}
+                            {new Date(file.created_at).toLocaleDateString()}
                           </Typography>
                         </TableCell>
                         <TableCell align="center" sx={{ color: 'white' }}>
@@ -2245,7 +2261,7 @@ export const AdminPanel: React.FC = () => {
                 {selectedUserDetails?.username} - Detailed View
               </Typography>
               <Typography variant="body2" sx={{ color: 'rgba(255, 255, 255, 0.7)' }}>
-                {selectedUserDetails?.firstName} {selectedUserDetails?.lastName}
+                {selectedUserDetails?.first_name} {selectedUserDetails?.last_name}
               </Typography>
             </Box>
           </DialogTitle>
@@ -2312,7 +2328,7 @@ export const AdminPanel: React.FC = () => {
                           Total Uploaded
                         </Typography>
                         <Typography variant="h6" sx={{ color: 'white' }}>
-                          {((selectedUserDetails?.totalUploadedBytes || 0) / 1024 / 1024).toFixed(1)} MB
+                          {((selectedUserDetails?.total_uploaded_bytes || 0) / 1024 / 1024).toFixed(1)} MB
                         </Typography>
                       </Box>
                       <Box>
@@ -2320,7 +2336,7 @@ export const AdminPanel: React.FC = () => {
                           Actual Storage
                         </Typography>
                         <Typography variant="h6" sx={{ color: 'white' }}>
-                          {((selectedUserDetails?.actualStorageBytes || 0) / 1024 / 1024).toFixed(1)} MB
+                          {((selectedUserDetails?.actual_storage_bytes || 0) / 1024 / 1024).toFixed(1)} MB
                         </Typography>
                       </Box>
                       <Box>
@@ -2328,7 +2344,7 @@ export const AdminPanel: React.FC = () => {
                           Space Saved
                         </Typography>
                         <Typography variant="h6" sx={{ color: '#4caf50' }}>
-                          {((selectedUserDetails?.savedBytes || 0) / 1024 / 1024).toFixed(1)} MB
+                          {((selectedUserDetails?.saved_bytes || 0) / 1024 / 1024).toFixed(1)} MB
                         </Typography>
                       </Box>
                     </Box>
@@ -2367,7 +2383,7 @@ export const AdminPanel: React.FC = () => {
                             <TableRow key={file.id} sx={{ '&:hover': { bgcolor: 'rgba(255, 255, 255, 0.05)' } }}>
                               <TableCell sx={{ color: 'white' }}>
                                 <Typography variant="body2" sx={{ fontWeight: 500 }}>
-                                  {file.originalFilename}
+                                  {file.original_filename}
                                 </Typography>
                               </TableCell>
                               <TableCell sx={{ color: 'white' }}>
@@ -2377,7 +2393,7 @@ export const AdminPanel: React.FC = () => {
                               </TableCell>
                               <TableCell sx={{ color: 'white' }}>
                                 <Chip
-                                  label={file.mimeType ? file.mimeType.split('/')[1]?.toUpperCase() || 'UNKNOWN' : 'UNKNOWN'}
+                                  label={file.mime_type ? file.mime_type.split('/')[1]?.toUpperCase() || 'UNKNOWN' : 'UNKNOWN'}
                                   size="small"
                                   sx={{
                                     bgcolor: 'rgba(255, 255, 255, 0.2)',
@@ -2388,7 +2404,7 @@ export const AdminPanel: React.FC = () => {
                               </TableCell>
                               <TableCell sx={{ color: 'rgba(255, 255, 255, 0.8)' }}>
                                 <Typography variant="body2">
-                                  {new Date(file.createdAt).toLocaleDateString()}
+                                  {new Date(file.created_at).toLocaleDateString()}
                                 </Typography>
                               </TableCell>
                               <TableCell sx={{ color: 'white' }}>
@@ -2437,8 +2453,8 @@ export const AdminPanel: React.FC = () => {
           file={previewFile ? {
             id: previewFile.id,
             filename: previewFile.filename,
-            original_filename: previewFile.originalFilename,
-            mime_type: previewFile.mimeType,
+            original_filename: previewFile.original_filename,
+            mime_type: previewFile.mime_type,
             size: previewFile.size,
             owner: previewFile.owner ? { id: previewFile.owner.id } : undefined
           } : null}
@@ -2447,6 +2463,7 @@ export const AdminPanel: React.FC = () => {
           onDownload={handleDownloadFromPreview}
           token={token}
           isAdmin={true}
+          accessReason={accessReason}
         />
 
         {/* Deduplication Details Dialog */}
@@ -89,6 +89,16 @@ interface TimeSeriesData {
   value: number;
 }
 
+interface DedupSavingsPoint {
+  date: string;
+  logicalBytes: number;
+  actualBytes: number;
+  savedBytes: number;
+  savedPercent: number;
+  fileCount: number;
+  blobCount: number;
+}
+
 interface FileTypeDistribution {
   type: string;
   count: number;
@@ -117,6 +127,7 @@ const AnalyticsPage: React.FC = () => {
   const [uploadTrend, setUploadTrend] = useState<TimeSeriesData[]>([]);
   const [downloadTrend, setDownloadTrend] = useState<TimeSeriesData[]>([]);
   const [storageTrend, setStorageTrend] = useState<TimeSeriesData[]>([]);
+  const [dedupTrend, setDedupTrend] = useState<DedupSavingsPoint[]>([]);
   const [fileTypes, setFileTypes] = useState<FileTypeDistribution[]>([]);
   const [topFiles, setTopFiles] = useState<TopFile[]>([]);
   const [userActivity, setUserActivity] = useState<UserActivityData[]>([]);
@@ -181,6 +192,7 @@ const AnalyticsPage: React.FC = () => {
         uploadTrendRes,
         downloadTrendRes,
         storageTrendRes,
+        dedupTrendRes,
         fileTypesRes,
         topFilesRes,
         userActivityRes
@@ -190,6 +202,7 @@ const AnalyticsPage: React.FC = () => {
         axios.get(`${API_BASE}/admin/analytics/file-upload-trend?days=${timeRange}`, config),
         axios.get(`${API_BASE}/admin/analytics/download-trend?days=${timeRange}`, config),
         axios.get(`${API_BASE}/admin/analytics/storage-usage-trend?days=${timeRange}`, config),
+        axios.get(`${API_BASE}/admin/analytics/dedup-savings-trend?days=${timeRange}`, config),
         axios.get(`${API_BASE}/admin/analytics/file-type-distribution`, config),
         axios.get(`${API_BASE}/admin/analytics/top-files?limit=10`, config),
         axios.get(`${API_BASE}/admin/analytics/user-activity`, config)
@@ -201,6 +214,7 @@ const AnalyticsPage: React.FC = () => {
         uploadTrend: uploadTrendRes.data,
         downloadTrend: downloadTrendRes.data,
         storageTrend: storageTrendRes.data,
+        dedupTrend: dedupTrendRes.data,
         fileTypes: fileTypesRes.data,
         topFiles: topFilesRes.data,
         userActivity: userActivityRes.data
@@ -213,6 +227,7 @@ const AnalyticsPage: React.FC = () => {
       setUploadTrend(uploadTrendRes.data || []);
       setDownloadTrend(downloadTrendRes.data || []);
       setStorageTrend(storageTrendRes.data || []);
+      setDedupTrend(dedupTrendRes.data || []);
       setFileTypes(fileTypesRes.data || []);
       setTopFiles(topFilesRes.data || []);
       setUserActivity(userActivityRes.data || []);
@@ -224,6 +239,7 @@ const AnalyticsPage: React.FC = () => {
       setUploadTrend([]);
       setDownloadTrend([]);
       setStorageTrend([]);
+      setDedupTrend([]);
       setFileTypes([]);
       setTopFiles([]);
       setUserActivity([]);
@@ -343,6 +359,38 @@ const AnalyticsPage: React.FC = () => {
     ]
   });
 
+  const toMB = (bytes: number) => bytes / (1024 * 1024);
+
+  const dedupChartData = {
+    labels: dedupTrend.map(d => new Date(d.date).toLocaleDateString()),
+    datasets: [
+      {
+        label: 'Logical (MB)',
+        data: dedupTrend.map(d => toMB(d.logicalBytes)),
+        borderColor: colorPaletteSolid.accent1,
+        backgroundColor: colorPalette.accent1,
+        fill: false,
+        tension: 0.4
+      },
+      {
+        label: 'Actual (MB)',
+        data: dedupTrend.map(d => toMB(d.actualBytes)),
+        borderColor: colorPaletteSolid.accent4,
+        backgroundColor: colorPalette.accent4,
+        fill: false,
+        tension: 0.4
+      },
+      {
+        label: 'Saved (MB)',
+        data: dedupTrend.map(d => toMB(d.savedBytes)),
+        borderColor: colorPaletteSolid.accent2,
+        backgroundColor: colorPalette.accent2,
+        fill: true,
+        tension: 0.4
+      }
+    ]
+  };
+
   const pieChartData = {
     labels: fileTypes.map(ft => ft.type.charAt(0).toUpperCase() + ft.type.slice(1)),
     datasets: [
@@ -585,6 +633,33 @@ const AnalyticsPage: React.FC = () => {
                   </Box>
                 </Paper>
               </Box>
+              <Box sx={{ flex: '1 1 500px', minWidth: '500px' }}>
+                <Paper sx={{ 
+                  p: 3, 
+                  bgcolor: 'rgba(255, 255, 255, 0.05)',
+                  border: '1px solid rgba(255, 255, 255, 0.1)',
+                  height: 400
+                }}>
+                  <Typography variant="h6" sx={{ color: 'white', mb: 2 }}>
+                    Deduplication Savings
+                    {dedupTrend.length > 0 && (
+                      <Typography component="span" variant="body2" sx={{ ml: 1, color: 'rgba(255, 255, 255, 0.6)' }}>
+                        {dedupTrend[dedupTrend.length - 1].savedPercent.toFixed(1)}% saved
+                      </Typography>
+                    )}
+                  </Typography>
+                  <Box sx={{ height: 300 }}>
+                    {dedupTrend.length > 0 ? (
+                      <Line 
+                        data={dedupChartData} 
+                        options={chartOptions} 
+                      />
+                    ) : (
+                      <EmptyDataMessage title="Deduplication" />
+                    )}
+                  </Box>
+                </Paper>
+              </Box>
             </Box>
           </Box>
         )}
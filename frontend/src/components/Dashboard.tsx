@@ -422,10 +422,10 @@ export const Dashboard: React.FC = () => {
         id: user.id,
         username: user.username,
         email: user.email,
-        firstName: user.firstName,
-        lastName: user.lastName,
-        storageQuota: user.storageQuota,
-        storageUsed: user.storageUsed,
+        firstName: user.first_name,
+        lastName: user.last_name,
+        storageQuota: user.storage_quota,
+        storageUsed: user.storage_used,
       });
     } else {
       console.log('❌ No user data available');
@@ -496,7 +496,7 @@ export const Dashboard: React.FC = () => {
     return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
   };
 
-  const storagePercentage = user ? (user.storageUsed / user.storageQuota) * 100 : 0;
+  const storagePercentage = user ? (user.storage_used / user.storage_quota) * 100 : 0;
 
   const refreshStats = async () => {
     if (!token) return;
@@ -797,7 +797,7 @@ export const Dashboard: React.FC = () => {
             <Box sx={{ display: 'flex', alignItems: 'center', justifyContent: 'space-between' }}>
               <Box sx={{ flex: 1 }}>
                 <Typography variant="body2" sx={{ color: 'text.secondary', mb: 1 }}>
-                  {formatBytes(user?.storageUsed || 0)} of {formatBytes(user?.storageQuota || 0)} used
+                  {formatBytes(user?.storage_used || 0)} of {formatBytes(user?.storage_quota || 0)} used
                 </Typography>
                 <LinearProgress 
                   variant="determinate" 
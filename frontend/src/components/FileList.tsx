@@ -53,7 +53,7 @@ interface File {
   original_filename: string;
   mime_type: string;
   size: number;
-  createdAt: string;
+  created_at: string;
   tags?: string[];
   description?: string;
 }
@@ -64,8 +64,8 @@ interface Folder {
   path: string;
   parent_id?: string;
   owner_id: string;
-  createdAt: string;
-  updatedAt?: string;
+  created_at: string;
+  updated_at?: string;
 }
 
 interface FileListProps {
@@ -646,7 +646,7 @@ export const FileList: React.FC<FileListProps> = ({ onFileDeleted, refreshTrigge
                   </TableCell>
                   <TableCell>
                     <Typography variant="body2" color="text.secondary">
-                      {formatDate(folder.createdAt)}
+                      {formatDate(folder.created_at)}
                     </Typography>
                   </TableCell>
                   <TableCell align="right">
@@ -737,7 +737,7 @@ export const FileList: React.FC<FileListProps> = ({ onFileDeleted, refreshTrigge
                   </TableCell>
                   <TableCell>
                     <Typography variant="body2" color="text.secondary">
-                      {formatDate(file.createdAt)}
+                      {formatDate(file.created_at)}
                     </Typography>
                   </TableCell>
                   <TableCell align="right">
@@ -846,7 +846,7 @@ export const FileList: React.FC<FileListProps> = ({ onFileDeleted, refreshTrigge
                   </Box>
                 </Box>
                 <Typography variant="caption" color="text.secondary" sx={{ mb: 1 }}>
-                  {formatDate(folder.createdAt)}
+                  {formatDate(folder.created_at)}
                 </Typography>
                 <Box sx={{ display: 'flex', gap: 0.5, mt: 'auto' }}>
                   <Tooltip title="Share Folder">
@@ -948,7 +948,7 @@ export const FileList: React.FC<FileListProps> = ({ onFileDeleted, refreshTrigge
                     variant="outlined"
                   />
                   <Typography variant="caption" color="text.secondary">
-                    {formatDate(file.createdAt)}
+                    {formatDate(file.created_at)}
                   </Typography>
                 </Box>
                 
@@ -34,6 +34,7 @@ interface FilePreviewProps {
   onDownload?: (file: any) => void;
   token: string | null;
   isAdmin?: boolean;
+  accessReason?: string; // required by the admin endpoint for other users' files
 }
 
 export const FilePreview: React.FC<FilePreviewProps> = ({
@@ -43,6 +44,7 @@ export const FilePreview: React.FC<FilePreviewProps> = ({
   onDownload,
   token,
   isAdmin = false,
+  accessReason = '',
 }) => {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
@@ -61,7 +63,7 @@ export const FilePreview: React.FC<FilePreviewProps> = ({
         
         // Use admin endpoint if in admin mode, otherwise use regular user endpoint
         const apiUrl = isAdmin 
-          ? `${process.env.REACT_APP_API_URL}/api/v1/admin/files/${file.id}/view`
+          ? `${process.env.REACT_APP_API_URL}/api/v1/admin/files/${file.id}/view?reason=${encodeURIComponent(accessReason)}`
           : `${process.env.REACT_APP_API_URL}/api/v1/files/${file.id}/view`;
         
         const response = await fetch(apiUrl, {
@@ -208,16 +208,14 @@ export const FileUpload: React.FC<FileUploadProps> = ({ onUploadComplete }) => {
           );
           
           // Check for deduplication information
-          if (result.results && Array.isArray(result.results)) {
-            result.results.forEach((fileResult: any) => {
+          if (result.files && Array.isArray(result.files)) {
+            result.files.forEach((fileResult: any) => {
               if (fileResult.is_duplicate) {
                 duplicateCount++;
               }
-              if (fileResult.saved_bytes) {
-                totalSaved += fileResult.saved_bytes;
-              }
             });
           }
+          totalSaved += Number(response.headers['x-saved-bytes'] || 0);
           
           return { success: true, file: file.name };
         } else {
@@ -47,7 +47,7 @@ interface FolderShareLink {
   shareToken: string;
   permission: string;
   passwordHash?: string;
-  createdAt: string;
+  created_at: string;
   expiresAt?: string;
   lastAccessedAt?: string;
   isActive: boolean;
@@ -371,7 +371,7 @@ export const FolderShareLinksView: React.FC = () => {
 
                         <Typography variant="caption" color="text.secondary" display="flex" alignItems="center">
                           <ScheduleIcon sx={{ fontSize: 16, mr: 0.5 }} />
-                          Created: {formatDate(link.createdAt)}
+                          Created: {formatDate(link.created_at)}
                         </Typography>
 
                         {link.expiresAt && (
@@ -420,14 +420,14 @@ export const FolderSharingModal: React.FC<FolderSharingModalProps> = ({ open, on
                 {folderShares.map((share) => (
                   <ListItem key={share.id} divider>
                     <ListItemText
-                      primary={share.sharedWithUser?.email || 'Unknown User'}
+                      primary={share.shared_with_user?.email || 'Unknown User'}
                       secondary={
                         <Box>
                           <Typography variant="body2">
                             Permission: {share.permission}
                           </Typography>
                           <Typography variant="caption">
-                            Shared: {formatDate(share.createdAt)}
+                            Shared: {formatDate(share.created_at)}
                           </Typography>
                           {share.message && (
                             <Typography variant="caption" display="block">
@@ -482,7 +482,7 @@ export const FolderSharingModal: React.FC<FolderSharingModalProps> = ({ open, on
                             Permission: {link.permission}
                           </Typography>
                           <Typography variant="caption">
-                            Created: {formatDate(link.createdAt)}
+                            Created: {formatDate(link.created_at)}
                           </Typography>
                           {link.expiresAt && (
                             <Typography variant="caption" display="block">
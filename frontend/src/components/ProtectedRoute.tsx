@@ -35,7 +35,7 @@ export const ProtectedRoute: React.FC<ProtectedRouteProps> = ({
     return <Navigate to="/auth" state={{ from: location }} replace />;
   }
 
-  if (requireAdmin && user && !user.roles.includes('ADMIN')) {
+  if (requireAdmin && user && user.role !== 'admin') {
     // User is authenticated but doesn't have admin privileges
     return <Navigate to="/dashboard" replace />;
   }
@@ -55,27 +55,27 @@ export const PublicSharePage: React.FC = () => {
     }
   }, [token]);
 
-  const fetchSharedFile = async (pwd?: string) => {
+  const fetchSharedFile = async () => {
     try {
       setLoading(true);
       setError(null);
 
+      // An unlocked link is opened by the access cookie set by /unlock
       const url = new URL(`/share/${token}`, window.location.origin);
-      if (pwd) {
-        url.searchParams.append('password', pwd);
-      }
-
       const response = await fetch(url.toString());
 
       if (response.ok) {
         const data = await response.json();
         setSharedFile(data);
         setPasswordRequired(false);
-      } else if (response.status === 404) {
+      } else if (response.status === 401 || response.status === 404 || response.status === 410) {
         const errorData = await response.json();
-        if (errorData.error.includes('password required')) {
+        if (errorData.code === 'SHARE_PASSWORD_REQUIRED') {
           setPasswordRequired(true);
           setError('This shared file is password protected.');
+        } else if (errorData.code === 'SHARE_PASSWORD_INVALID') {
+          setPasswordRequired(true);
+          setError('Incorrect password. Please try again.');
         } else {
           setError('This share link is invalid, expired, or has been revoked.');
         }
@@ -90,9 +90,36 @@ export const PublicSharePage: React.FC = () => {
     }
   };
 
-  const handlePasswordSubmit = () => {
-    if (password.trim()) {
-      fetchSharedFile(password);
+  const handlePasswordSubmit = async () => {
+    if (!password.trim()) return;
+
+    try {
+      setLoading(true);
+      setError(null);
+
+      // The password goes in the body, so it never appears in URLs or logs
+      const response = await fetch(new URL(`/share/${token}/unlock`, window.location.origin).toString(), {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ password }),
+      });
+
+      if (response.ok) {
+        await fetchSharedFile();
+        return;
+      }
+
+      const errorData = await response.json();
+      if (errorData.code === 'SHARE_PASSWORD_INVALID') {
+        setError('Incorrect password. Please try again.');
+      } else {
+        setPasswordRequired(false);
+        setError(errorData.error || 'This share link is invalid, expired, or has been revoked.');
+      }
+      setLoading(false);
+    } catch (error: any) {
+      setError('Network error. Please try again.');
+      setLoading(false);
     }
   };
 
@@ -103,10 +130,6 @@ export const PublicSharePage: React.FC = () => {
       setDownloading(true);
       
       const url = new URL(`/share/${token}/download`, window.location.origin);
-      if (password) {
-        url.searchParams.append('password', password);
-      }
-
       const response = await fetch(url.toString());
 
       if (response.ok) {
@@ -121,7 +144,7 @@ export const PublicSharePage: React.FC = () => {
         document.body.removeChild(a);
 
         // Refresh the share info to update download count
-        setTimeout(() => fetchSharedFile(password), 1000);
+        setTimeout(() => fetchSharedFile(), 1000);
       } else {
         const errorData = await response.json();
         setError(errorData.error || 'Download failed.');
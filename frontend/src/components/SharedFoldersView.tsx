@@ -41,12 +41,12 @@ interface SharedFolder {
     id: string;
     email: string;
     username?: string;
-    firstName?: string;
-    lastName?: string;
+    first_name?: string;
+    last_name?: string;
   };
   permission: string;
   message?: string;
-  createdAt: string;
+  created_at: string;
   expiresAt?: string;
 }
 
@@ -228,9 +228,9 @@ export const SharedFoldersView: React.FC = () => {
   const getUserDisplayName = (user: any) => {
     if (!user) return 'Unknown User';
     
-    // Try firstName + lastName first
-    if (user.firstName) {
-      return user.lastName ? `${user.firstName} ${user.lastName}` : user.firstName;
+    // Try first_name + last_name first
+    if (user.first_name) {
+      return user.last_name ? `${user.first_name} ${user.last_name}` : user.first_name;
     }
     
     // Fallback to username or email
@@ -240,8 +240,8 @@ export const SharedFoldersView: React.FC = () => {
   const getUserInitials = (user: any) => {
     if (!user) return 'U';
     
-    if (user.firstName) {
-      return user.lastName ? `${user.firstName[0]}${user.lastName[0]}`.toUpperCase() : user.firstName[0].toUpperCase();
+    if (user.first_name) {
+      return user.last_name ? `${user.first_name[0]}${user.last_name[0]}`.toUpperCase() : user.first_name[0].toUpperCase();
     }
     
     if (user.email) {
@@ -416,7 +416,7 @@ export const SharedFoldersView: React.FC = () => {
 
                       <Typography variant="caption" color="text.secondary" display="flex" alignItems="center">
                         <ScheduleIcon sx={{ fontSize: 16, mr: 0.5 }} />
-                        Shared: {formatDate(sharedFolder.createdAt)}
+                        Shared: {formatDate(sharedFolder.created_at)}
                       </Typography>
 
                       {sharedFolder.expiresAt && (
@@ -470,7 +470,7 @@ export const SharingModal: React.FC<SharingModalProps> = ({ open, onClose, file
                 {fileShares.map((share: any) => (
                   <ListItem key={share.id} divider>
                     <ListItemText
-                      primary={`${share.shared_with_user?.firstName || 'Unknown'} ${share.shared_with_user?.lastName || 'User'}`}
+                      primary={`${share.shared_with_user?.first_name || 'Unknown'} ${share.shared_with_user?.last_name || 'User'}`}
                       secondary={
                         <span>
                           {share.shared_with_user?.email || 'Unknown email'} • {share.permission}
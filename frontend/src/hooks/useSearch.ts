@@ -162,9 +162,9 @@ export const useSearch = (): UseSearchResult => {
         size: file.size,
         mimeType: file.mime_type,
         path: file.path || '',
-        uploadedAt: file.createdAt,
-        updatedAt: file.updatedAt,
-        uploaderName: file.owner?.username || file.owner?.firstName || 'Unknown',
+        uploadedAt: file.created_at,
+        updatedAt: file.updated_at,
+        uploaderName: file.owner?.username || file.owner?.first_name || 'Unknown',
         isPublic: file.is_public,
         downloadUrl: `/api/v1/files/${file.id}/download`,
         previewUrl: `/api/v1/files/${file.id}/view`,
@@ -3,15 +3,15 @@ export interface User {
   id: string;
   username: string;
   email: string;
-  firstName: string;
-  lastName: string;
+  first_name: string;
+  last_name: string;
   role: 'user' | 'admin';
-  storageQuota: number;
-  storageUsed: number;
+  storage_quota: number;
+  storage_used: number;
   roles: string[];
-  isActive: boolean;
-  createdAt: string;
-  lastLogin?: string;
+  is_active: boolean;
+  created_at: string;
+  last_login?: string;
 }
 
 export interface File {